@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/account"
+	"github.com/sxmpp/jackal/router/host"
+)
+
+// serviceName is the name under which admin operations are exposed.
+const serviceName = "Admin"
+
+// HostArgs represents the arguments of a virtual host admin operation.
+type HostArgs struct {
+	Name        string
+	Certificate []byte
+	PrivateKey  []byte
+}
+
+// AccountArgs represents the arguments of a user account admin operation.
+type AccountArgs struct {
+	Username  string
+	Domain    string
+	Status    string
+	Reason    string
+	ExpiresAt time.Time
+}
+
+// Reply represents an admin operation reply.
+type Reply struct{}
+
+// Server serves admin operations issued from command line against a running jackal instance,
+// over a local unix domain socket.
+type Server struct {
+	socket string
+	rpcSrv *rpc.Server
+
+	mu sync.Mutex
+	ln net.Listener
+	wg sync.WaitGroup
+}
+
+// New returns an admin server operating over a running instance hosts registry and account manager.
+func New(socket string, hosts *host.Hosts, accounts *account.Manager) (*Server, error) {
+	rpcSrv := rpc.NewServer()
+	if err := rpcSrv.RegisterName(serviceName, &service{hosts: hosts, accounts: accounts}); err != nil {
+		return nil, err
+	}
+	return &Server{socket: socket, rpcSrv: rpcSrv}, nil
+}
+
+// Start starts listening for admin connections.
+func (s *Server) Start() error {
+	// remove socket file left behind by a previous instance
+	if err := os.Remove(s.socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ln, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return err
+	}
+	// only the user running jackal is allowed to issue admin operations
+	if err := os.Chmod(s.socket, 0600); err != nil {
+		_ = ln.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.serve(ln)
+
+	log.Infof("admin: listening at %s...", s.socket)
+	return nil
+}
+
+// Shutdown stops listening for admin connections.
+func (s *Server) Shutdown(_ context.Context) error {
+	s.mu.Lock()
+	ln := s.ln
+	s.ln = nil
+	s.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	err := ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		go s.rpcSrv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+type service struct {
+	hosts    *host.Hosts
+	accounts *account.Manager
+}
+
+func (s *service) AddHost(args *HostArgs, _ *Reply) error {
+	return s.hosts.AddHost(context.Background(), args.Name, args.Certificate, args.PrivateKey)
+}
+
+func (s *service) EnableHost(args *HostArgs, _ *Reply) error {
+	return s.hosts.EnableHost(context.Background(), args.Name)
+}
+
+func (s *service) DisableHost(args *HostArgs, _ *Reply) error {
+	return s.hosts.DisableHost(context.Background(), args.Name)
+}
+
+func (s *service) RemoveHost(args *HostArgs, _ *Reply) error {
+	return s.hosts.RemoveHost(context.Background(), args.Name)
+}
+
+func (s *service) DeleteAccount(args *AccountArgs, _ *Reply) error {
+	ctx := context.Background()
+	if err := s.accounts.DeleteAccount(ctx, args.Username, args.Domain); err != nil {
+		return err
+	}
+	s.accounts.DisconnectSessions(ctx, args.Username, args.Domain)
+	return nil
+}
+
+func (s *service) DisableAccount(args *AccountArgs, _ *Reply) error {
+	return s.accounts.DisableAccount(context.Background(), args.Username, args.Domain, args.Status, args.Reason)
+}
+
+func (s *service) EnableAccount(args *AccountArgs, _ *Reply) error {
+	return s.accounts.EnableAccount(context.Background(), args.Username, args.Domain)
+}
+
+func (s *service) SetAccountExpiration(args *AccountArgs, _ *Reply) error {
+	return s.accounts.SetAccountExpiration(context.Background(), args.Username, args.Domain, args.ExpiresAt)
+}
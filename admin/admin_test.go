@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/module/account"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_NotRunning(t *testing.T) {
+	dir, socket := tUtilSocketPath(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	_, err := Dial(socket)
+	require.Equal(t, ErrServerNotRunning, err)
+}
+
+func TestAdmin_Hosts(t *testing.T) {
+	dir, socket := tUtilSocketPath(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im", Certificate: tls.Certificate{}}})
+	hostRep := memorystorage.NewHost()
+	require.Nil(t, hosts.Load(context.Background(), hostRep))
+
+	r, _ := router.New(hosts, c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()), nil)
+
+	srv, err := New(socket, hosts, nil)
+	require.Nil(t, err)
+	require.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	cl, err := Dial(socket)
+	require.Nil(t, err)
+	defer func() { _ = cl.Close() }()
+
+	certPEM, _ := ioutil.ReadFile("../testdata/cert/test.server.crt")
+	keyPEM, _ := ioutil.ReadFile("../testdata/cert/test.server.key")
+
+	require.NotNil(t, cl.AddHost(context.Background(), "jackal.im", certPEM, keyPEM)) // statically configured
+	require.Nil(t, cl.AddHost(context.Background(), "example.org", certPEM, keyPEM))
+	require.True(t, hosts.IsLocalHost("example.org"))
+
+	stored, _ := hostRep.FetchHost(context.Background(), "example.org")
+	require.NotNil(t, stored)
+
+	j, _ := jid.New("sxmpp", "example.org", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	// removing a host disconnects its streams
+	require.Nil(t, cl.RemoveHost(context.Background(), "example.org"))
+	require.False(t, hosts.IsLocalHost("example.org"))
+	require.True(t, stm.IsDisconnected())
+
+	require.Equal(t, host.ErrHostNotFound, cl.DisableHost(context.Background(), "example.org"))
+}
+
+func TestAdmin_Accounts(t *testing.T) {
+	dir, socket := tUtilSocketPath(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im", Certificate: tls.Certificate{}}})
+	userRep := memorystorage.NewUser()
+	r, _ := router.New(hosts, c2srouter.New(userRep, memorystorage.NewBlockList()), nil)
+
+	ctx := context.Background()
+	require.Nil(t, userRep.UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+
+	srv, err := New(socket, hosts, account.New(r, nil, userRep))
+	require.Nil(t, err)
+	require.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	cl, err := Dial(socket)
+	require.Nil(t, err)
+	defer func() { _ = cl.Close() }()
+
+	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(ctx, stm)
+
+	require.Equal(t, account.ErrAccountNotFound, cl.DisableAccount(ctx, "romeo", "jackal.im", model.UserBanned, "spam"))
+
+	// disabling an account closes its streams
+	require.Nil(t, cl.DisableAccount(ctx, "sxmpp", "jackal.im", model.UserBanned, "spam"))
+	require.True(t, stm.IsDisconnected())
+
+	usr, _ := userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, model.UserBanned, usr.Status)
+	require.Equal(t, "spam", usr.StatusReason)
+
+	require.Nil(t, cl.EnableAccount(ctx, "sxmpp", "jackal.im"))
+	usr, _ = userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, model.UserActive, usr.Status)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	require.Nil(t, cl.SetAccountExpiration(ctx, "sxmpp", "jackal.im", expiresAt))
+	usr, _ = userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.True(t, expiresAt.Equal(usr.ExpiresAt))
+
+	// deleting an account closes its streams
+	r.Unbind(ctx, j)
+	stm = stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(ctx, stm)
+
+	require.Nil(t, cl.DeleteAccount(ctx, "sxmpp", "jackal.im"))
+	require.True(t, stm.IsDisconnected())
+
+	usr, _ = userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, usr)
+}
+
+func tUtilSocketPath(t *testing.T) (dir, socket string) {
+	dir, err := ioutil.TempDir("", "jackal-admin")
+	require.Nil(t, err)
+	return dir, filepath.Join(dir, "admin.sock")
+}
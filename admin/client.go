@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"syscall"
+	"time"
+
+	"github.com/sxmpp/jackal/module/account"
+	"github.com/sxmpp/jackal/router/host"
+)
+
+const dialTimeout = time.Second * 5
+
+// ErrServerNotRunning will be returned by Dial in case no jackal instance is listening at admin socket.
+var ErrServerNotRunning = errors.New("admin: server not running")
+
+// knownErrors contains those errors that are restored on client side, so that callers can compare against them.
+var knownErrors = []error{host.ErrHostNotFound, host.ErrStaticHost, account.ErrAccountNotFound}
+
+// Client issues admin operations against a running jackal instance.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects to the admin server listening at socket.
+func Dial(socket string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, ErrServerNotRunning
+		}
+		return nil, err
+	}
+	return &Client{c: jsonrpc.NewClient(conn)}, nil
+}
+
+// AddHost registers a new virtual host, or replaces certificate of a previously added one.
+func (c *Client) AddHost(ctx context.Context, name string, certPEM, keyPEM []byte) error {
+	return c.call(ctx, "AddHost", &HostArgs{Name: name, Certificate: certPEM, PrivateKey: keyPEM})
+}
+
+// EnableHost enables a previously disabled virtual host.
+func (c *Client) EnableHost(ctx context.Context, name string) error {
+	return c.call(ctx, "EnableHost", &HostArgs{Name: name})
+}
+
+// DisableHost stops serving a virtual host, disconnecting its streams.
+func (c *Client) DisableHost(ctx context.Context, name string) error {
+	return c.call(ctx, "DisableHost", &HostArgs{Name: name})
+}
+
+// RemoveHost removes a virtual host, disconnecting its streams.
+func (c *Client) RemoveHost(ctx context.Context, name string) error {
+	return c.call(ctx, "RemoveHost", &HostArgs{Name: name})
+}
+
+// DeleteAccount removes an account, cancelling its presence subscriptions and closing every stream bound to it.
+func (c *Client) DeleteAccount(ctx context.Context, username, domain string) error {
+	return c.call(ctx, "DeleteAccount", &AccountArgs{Username: username, Domain: domain})
+}
+
+// DisableAccount sets an account status either to disabled or banned, closing every stream bound to it.
+func (c *Client) DisableAccount(ctx context.Context, username, domain, status, reason string) error {
+	return c.call(ctx, "DisableAccount", &AccountArgs{Username: username, Domain: domain, Status: status, Reason: reason})
+}
+
+// EnableAccount reactivates a disabled or banned account.
+func (c *Client) EnableAccount(ctx context.Context, username, domain string) error {
+	return c.call(ctx, "EnableAccount", &AccountArgs{Username: username, Domain: domain})
+}
+
+// SetAccountExpiration sets the time at which an account will be disabled.
+func (c *Client) SetAccountExpiration(ctx context.Context, username, domain string, expiresAt time.Time) error {
+	return c.call(ctx, "SetAccountExpiration", &AccountArgs{Username: username, Domain: domain, ExpiresAt: expiresAt})
+}
+
+// Close closes the connection to the admin server.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+func (c *Client) call(ctx context.Context, method string, args interface{}) error {
+	call := c.c.Go(serviceName+"."+method, args, &Reply{}, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if serverErr, ok := call.Error.(rpc.ServerError); ok {
+			for _, err := range knownErrors {
+				if err.Error() == string(serverErr) {
+					return err
+				}
+			}
+			return errors.New(string(serverErr))
+		}
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/module/account"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const neverExpires = "never"
+
+// accountAdmin represents the set of user account admin operations, satisfied by both account manager
+// and admin client.
+type accountAdmin interface {
+	DeleteAccount(ctx context.Context, username, domain string) error
+	DisableAccount(ctx context.Context, username, domain, status, reason string) error
+	EnableAccount(ctx context.Context, username, domain string) error
+	SetAccountExpiration(ctx context.Context, username, domain string, expiresAt time.Time) error
+}
+
+// runAccount runs an 'account' subcommand, managing a user account held in configured storage.
+// Whenever a jackal instance is listening at configured admin socket, changes are applied through it.
+// Deleting an account requires a running instance, so that its contacts are notified.
+func (a *Application) runAccount(args []string) error {
+	if len(args) == 0 {
+		return errors.New("account: missing command (delete, disable, ban, enable or expire)")
+	}
+	cmd := args[0]
+
+	var configFile, reason, expiresAt string
+
+	fs := flag.NewFlagSet("jackal account", flag.ExitOnError)
+	fs.SetOutput(a.output)
+	fs.StringVar(&configFile, "config", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&configFile, "c", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&reason, "reason", "", "Account status reason.")
+	fs.StringVar(&expiresAt, "at", "", "Account expiration time (RFC 3339), or 'never'.")
+	_ = fs.Parse(args[1:])
+
+	if fs.NArg() == 0 {
+		return errors.New("account: missing user")
+	}
+	j, err := jid.NewWithString(fs.Arg(0), false)
+	if err != nil {
+		return err
+	}
+	if len(j.Node()) == 0 {
+		return fmt.Errorf("account: invalid user: %s", fs.Arg(0))
+	}
+	var expiresTm time.Time
+	if cmd == "expire" && expiresAt != neverExpires {
+		if len(expiresAt) == 0 {
+			return errors.New("account: expiration time must be specified")
+		}
+		if expiresTm, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+			return err
+		}
+	}
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// apply changes through running instance, if any, so that account sessions are closed
+	var accounts accountAdmin
+	cl, err := a.dialAdmin(&cfg)
+	if err != nil {
+		return err
+	}
+	if cl != nil {
+		defer func() { _ = cl.Close() }()
+		accounts = cl
+	} else {
+		if cmd == "delete" {
+			return errors.New("account: delete requires a running jackal instance listening at admin socket")
+		}
+		_, rep, err := a.openStorage(configFile, a.output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rep.Close(context.Background()) }()
+
+		accounts = account.New(nil, nil, rep.User())
+	}
+	switch cmd {
+	case "delete":
+		err = accounts.DeleteAccount(ctx, j.Node(), j.Domain())
+	case "disable":
+		err = accounts.DisableAccount(ctx, j.Node(), j.Domain(), model.UserDisabled, reason)
+	case "ban":
+		err = accounts.DisableAccount(ctx, j.Node(), j.Domain(), model.UserBanned, reason)
+	case "enable":
+		err = accounts.EnableAccount(ctx, j.Node(), j.Domain())
+	case "expire":
+		err = accounts.SetAccountExpiration(ctx, j.Node(), j.Domain(), expiresTm)
+	default:
+		return fmt.Errorf("account: unrecognized command: %s", cmd)
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(a.output, "account %s: %s\n", cmd, j.ToBareJID().String())
+	return nil
+}
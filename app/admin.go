@@ -0,0 +1,24 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package app
+
+import (
+	"github.com/sxmpp/jackal/admin"
+)
+
+// dialAdmin connects to the admin server of a running jackal instance. A nil client is returned in case
+// no admin socket is configured or no instance is listening at it, so that the caller can operate
+// directly over storage.
+func (a *Application) dialAdmin(cfg *Config) (*admin.Client, error) {
+	if len(cfg.Admin.Socket) == 0 {
+		return nil, nil
+	}
+	cl, err := admin.Dial(cfg.Admin.Socket)
+	if err == admin.ErrServerNotRunning {
+		return nil, nil
+	}
+	return cl, err
+}
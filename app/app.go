@@ -22,16 +22,20 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/sxmpp/jackal/admin"
 	"github.com/sxmpp/jackal/c2s"
 	c2srouter "github.com/sxmpp/jackal/c2s/router"
 	"github.com/sxmpp/jackal/component"
 	"github.com/sxmpp/jackal/log"
 	"github.com/sxmpp/jackal/module"
+	"github.com/sxmpp/jackal/module/account"
 	"github.com/sxmpp/jackal/router"
 	"github.com/sxmpp/jackal/router/host"
 	"github.com/sxmpp/jackal/s2s"
 	s2srouter "github.com/sxmpp/jackal/s2s/router"
 	"github.com/sxmpp/jackal/storage"
+	"github.com/sxmpp/jackal/storage/encrypted"
+	"github.com/sxmpp/jackal/util/runqueue"
 	"github.com/sxmpp/jackal/version"
 )
 
@@ -56,9 +60,39 @@ var logoStr = []string{
 
 const usageStr = `
 Usage: jackal [options]
+       jackal migrate <up|down|status> [options]
+       jackal export [options]
+       jackal import [options] <file>
+       jackal host <add|enable|disable|remove> [options] <domain>
+       jackal account <delete|disable|ban|enable|expire> [options] <jid>
 
 Server Options:
     -c, --Config <file>    Configuration file path
+Migrate Commands:
+    up                     Apply every pending storage schema migration
+    down                   Revert latest applied storage schema migration
+    status                 Show storage schema migrations status
+Export Options:
+    -u, --user <jid>       Export a single user account
+    -d, --domain <domain>  Export every user account of a domain
+    -o, --output <file>    Output file path (defaults to standard output)
+Host Commands:
+    add                    Register a virtual host (requires -cert and -key)
+    enable                 Enable a previously disabled virtual host
+    disable                Stop serving a virtual host, keeping its data
+    remove                 Remove a virtual host
+Host Options:
+    --cert <file>          Host certificate file path
+    --key <file>           Host private key file path
+Account Commands:
+    delete                 Delete a user account (requires a running server)
+    disable                Disable a user account
+    ban                    Ban a user account
+    enable                 Reactivate a disabled or banned user account
+    expire                 Set a user account expiration time (requires -at)
+Account Options:
+    --reason <text>        Disable or ban reason
+    --at <time|never>      Expiration time, in RFC 3339 format
 Common Options:
     -h, --help             Show this message
     -v, --version          Show version
@@ -76,6 +110,8 @@ type Application struct {
 	s2s              *s2s.S2S
 	c2s              *c2s.C2S
 	debugSrv         *http.Server
+	adminSrv         *admin.Server
+	cancelRotation   context.CancelFunc
 	waitStopCh       chan os.Signal
 	shutDownWaitSecs time.Duration
 }
@@ -94,6 +130,21 @@ func (a *Application) Run() error {
 	if len(a.args) == 0 {
 		return errors.New("empty command-line arguments")
 	}
+	if len(a.args) > 1 && a.args[1] == "migrate" {
+		return a.runMigrate(a.args[2:])
+	}
+	if len(a.args) > 1 && a.args[1] == "export" {
+		return a.runExport(a.args[2:])
+	}
+	if len(a.args) > 1 && a.args[1] == "import" {
+		return a.runImport(a.args[2:])
+	}
+	if len(a.args) > 1 && a.args[1] == "host" {
+		return a.runHost(a.args[2:])
+	}
+	if len(a.args) > 1 && a.args[1] == "account" {
+		return a.runAccount(a.args[2:])
+	}
 	var configFile string
 	var showVersion, showUsage bool
 
@@ -150,6 +201,9 @@ func (a *Application) Run() error {
 	// show jackal's fancy logo
 	a.printLogo(allocID)
 
+	// set module and stream queues configuration
+	runqueue.SetDefaultConfig(cfg.RunQueue)
+
 	// initialize storage
 	repContainer, err := storage.New(&cfg.Storage)
 	if err != nil {
@@ -160,10 +214,30 @@ func (a *Application) Run() error {
 	}
 
 	// initialize hosts
-	hosts, err := host.New(cfg.Hosts)
+	hostsCfg := make([]host.Config, 0, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		hostsCfg = append(hostsCfg, h.Config)
+		if h.Modules != nil {
+			if _, ok := cfg.Modules.Hosts[h.Name]; ok {
+				return fmt.Errorf("modules overrides for host %s defined twice", h.Name)
+			}
+			if cfg.Modules.Hosts == nil {
+				cfg.Modules.Hosts = make(map[string]module.HostConfig)
+			}
+			cfg.Modules.Hosts[h.Name] = *h.Modules
+		}
+	}
+	hosts, err := host.New(hostsCfg)
 	if err != nil {
 		return err
 	}
+	if err := hosts.Load(context.Background(), repContainer.Host()); err != nil {
+		return err
+	}
+	// re-encrypt stored data in background
+	if ec, ok := repContainer.(*encrypted.Container); ok {
+		a.rotateEncryptionKeys(ec, hosts.HostNames())
+	}
 	// initialize router
 	var s2sRouter router.S2SRouter
 
@@ -182,7 +256,8 @@ func (a *Application) Run() error {
 
 	// initialize modules & components...
 	a.mods = module.New(&cfg.Modules, a.router, repContainer, allocID)
-	a.comps = component.New(&cfg.Components, a.mods.DiscoInfo)
+	a.comps = component.New(&cfg.Components, a.router, repContainer, a.mods.DiscoInfo)
+	a.router.SetComponentRouter(a.comps)
 
 	// start serving s2s...
 	if err := a.setRLimit(); err != nil {
@@ -205,6 +280,12 @@ func (a *Application) Run() error {
 			return err
 		}
 	}
+	// initialize admin server...
+	if len(cfg.Admin.Socket) > 0 {
+		if err := a.initAdminServer(cfg.Admin.Socket, hosts, a.mods.Accounts); err != nil {
+			return err
+		}
+	}
 
 	// ...wait for stop signal to shutdown
 	sig := a.waitForStopSignal()
@@ -213,6 +294,16 @@ func (a *Application) Run() error {
 	return a.gracefullyShutdown()
 }
 
+func (a *Application) rotateEncryptionKeys(ec *encrypted.Container, domains []string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelRotation = cancel
+	go func() {
+		if _, err := ec.Rotate(ctx, domains); err != nil && ctx.Err() == nil {
+			log.Error(err)
+		}
+	}()
+}
+
 func (a *Application) showVersion() {
 	_, _ = fmt.Fprintf(a.output, "jackal version: %v\n", version.ApplicationVersion)
 }
@@ -278,6 +369,18 @@ func (a *Application) initDebugServer(port int) error {
 	return nil
 }
 
+func (a *Application) initAdminServer(socket string, hosts *host.Hosts, accounts *account.Manager) error {
+	srv, err := admin.New(socket, hosts, accounts)
+	if err != nil {
+		return err
+	}
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	a.adminSrv = srv
+	return nil
+}
+
 func (a *Application) waitForStopSignal() os.Signal {
 	signal.Notify(a.waitStopCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 	return <-a.waitStopCh
@@ -308,11 +411,19 @@ func (a *Application) shutdown(ctx context.Context) <-chan bool {
 }
 
 func (a *Application) doShutdown(ctx context.Context) error {
+	if a.cancelRotation != nil {
+		a.cancelRotation()
+	}
 	if a.debugSrv != nil {
 		if err := a.debugSrv.Shutdown(ctx); err != nil {
 			return err
 		}
 	}
+	if a.adminSrv != nil {
+		if err := a.adminSrv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	a.c2s.Shutdown(ctx)
 
 	if err := a.comps.Shutdown(ctx); err != nil {
@@ -7,13 +7,22 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/sxmpp/jackal/admin"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/module/account"
+	"github.com/sxmpp/jackal/router/host"
+	"github.com/sxmpp/jackal/storage"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
 	"github.com/sxmpp/jackal/version"
 	"github.com/stretchr/testify/require"
 )
@@ -59,6 +68,232 @@ func TestApplicationPrintVersion(t *testing.T) {
 	require.Equal(t, fmt.Sprintf("jackal version: %v\n", version.ApplicationVersion), w.String())
 }
 
+func TestApplicationMigrate(t *testing.T) {
+	w := newWriterBuffer()
+	err := New(w, []string{"./jackal", "migrate"}).Run()
+	require.NotNil(t, err)
+
+	// memory storage can't be migrated
+	err = New(w, []string{"./jackal", "migrate", "status", "--config=../testdata/config_basic.yml"}).Run()
+	require.NotNil(t, err)
+}
+
+func TestApplicationExportImport(t *testing.T) {
+	defer func() { _ = os.Remove("test.jackal.log") }()
+
+	w := newWriterBuffer()
+	err := New(w, []string{"./jackal", "export", "-u", "ortuman@jackal.im", "-d", "jackal.im", "--config=../testdata/config_basic.yml"}).Run()
+	require.NotNil(t, err)
+
+	// export empty memory storage
+	err = New(w, []string{"./jackal", "export", "-o", "test.jackal.xml", "--config=../testdata/config_basic.yml"}).Run()
+	require.Nil(t, err)
+	defer func() { _ = os.Remove("test.jackal.xml") }()
+
+	err = New(w, []string{"./jackal", "import", "--config=../testdata/config_basic.yml"}).Run()
+	require.NotNil(t, err)
+
+	w = newWriterBuffer()
+	err = New(w, []string{"./jackal", "import", "--config=../testdata/config_basic.yml", "test.jackal.xml"}).Run()
+	require.Nil(t, err)
+	require.Contains(t, w.String(), "imported 0 user(s)")
+}
+
+func TestApplicationHost(t *testing.T) {
+	cfgArg := "--config=../testdata/config_basic.yml"
+
+	w := newWriterBuffer()
+	err := New(w, []string{"./jackal", "host"}).Run()
+	require.NotNil(t, err) // missing command
+
+	err = New(w, []string{"./jackal", "host", "add", cfgArg}).Run()
+	require.NotNil(t, err) // missing host name
+
+	err = New(w, []string{"./jackal", "host", "add", cfgArg, "example.org"}).Run()
+	require.NotNil(t, err) // missing certificate
+
+	err = New(w, []string{"./jackal", "host", "rename", cfgArg, "example.org"}).Run()
+	require.NotNil(t, err)
+
+	certArgs := []string{"-cert", "../testdata/cert/test.server.crt", "-key", "../testdata/cert/test.server.key"}
+
+	args := append([]string{"./jackal", "host", "add", cfgArg}, certArgs...)
+	err = New(w, append(args, "localhost")).Run()
+	require.Equal(t, host.ErrStaticHost, err)
+
+	w = newWriterBuffer()
+	err = New(w, append(args, "example.org")).Run()
+	require.Nil(t, err)
+	require.Contains(t, w.String(), "host add: example.org")
+
+	// memory storage doesn't outlive a single run
+	err = New(w, []string{"./jackal", "host", "disable", cfgArg, "example.org"}).Run()
+	require.Equal(t, host.ErrHostNotFound, err)
+
+	err = New(w, []string{"./jackal", "host", "remove", cfgArg, "localhost"}).Run()
+	require.Equal(t, host.ErrStaticHost, err)
+}
+
+func TestApplicationHostRunningInstance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jackal")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	socket := filepath.Join(dir, "admin.sock")
+	cfgFile := filepath.Join(dir, "jackal.yml")
+	require.Nil(t, ioutil.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+storage:
+  type: memory
+admin:
+  socket: %s
+`, socket)), 0644))
+	cfgArg := "--config=" + cfgFile
+
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im"}})
+	require.Nil(t, hosts.Load(context.Background(), memorystorage.NewHost()))
+
+	srv, err := admin.New(socket, hosts, nil)
+	require.Nil(t, err)
+	require.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	certArgs := []string{"-cert", "../testdata/cert/test.server.crt", "-key", "../testdata/cert/test.server.key"}
+
+	// changes are applied by running instance
+	w := newWriterBuffer()
+	err = New(w, append(append([]string{"./jackal", "host", "add", cfgArg}, certArgs...), "example.org")).Run()
+	require.Nil(t, err)
+	require.Contains(t, w.String(), "host add: example.org")
+	require.True(t, hosts.IsLocalHost("example.org"))
+
+	require.Nil(t, New(w, []string{"./jackal", "host", "disable", cfgArg, "example.org"}).Run())
+	require.True(t, hosts.IsDisabledHost("example.org"))
+
+	err = New(w, []string{"./jackal", "host", "remove", cfgArg, "jackal.im"}).Run()
+	require.NotNil(t, err)
+}
+
+func TestApplicationAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jackal")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	cfgFile := filepath.Join(dir, "jackal.yml")
+	require.Nil(t, ioutil.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+logger:
+  level: error
+  log_path: %s
+storage:
+  type: sqlite
+  sqlite:
+    path: %s
+`, filepath.Join(dir, "jackal.log"), filepath.Join(dir, "jackal.db"))), 0644))
+	cfgArg := "--config=" + cfgFile
+
+	var cfg Config
+	require.Nil(t, cfg.FromFile(cfgFile))
+	rep, err := storage.New(&cfg.Storage)
+	require.Nil(t, err)
+	require.Nil(t, rep.User().UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	_ = rep.Close(context.Background())
+
+	fetchUser := func() *model.User {
+		rep, err := storage.New(&cfg.Storage)
+		require.Nil(t, err)
+		defer func() { _ = rep.Close(context.Background()) }()
+
+		usr, err := rep.User().FetchUser(context.Background(), "sxmpp", "jackal.im")
+		require.Nil(t, err)
+		require.NotNil(t, usr)
+		return usr
+	}
+	w := newWriterBuffer()
+	require.NotNil(t, New(w, []string{"./jackal", "account"}).Run())                                      // missing command
+	require.NotNil(t, New(w, []string{"./jackal", "account", "disable", cfgArg}).Run())                   // missing user
+	require.NotNil(t, New(w, []string{"./jackal", "account", "disable", cfgArg, "jackal.im"}).Run())      // not a user
+	require.NotNil(t, New(w, []string{"./jackal", "account", "expire", cfgArg, "sxmpp@jackal.im"}).Run()) // missing expiration time
+	require.NotNil(t, New(w, []string{"./jackal", "account", "suspend", cfgArg, "sxmpp@jackal.im"}).Run())
+
+	err = New(w, []string{"./jackal", "account", "disable", cfgArg, "romeo@jackal.im"}).Run()
+	require.Equal(t, account.ErrAccountNotFound, err)
+
+	w = newWriterBuffer()
+	err = New(w, []string{"./jackal", "account", "ban", cfgArg, "-reason", "spam", "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.Contains(t, w.String(), "account ban: sxmpp@jackal.im")
+
+	usr := fetchUser()
+	require.Equal(t, model.UserBanned, usr.Status)
+	require.Equal(t, "spam", usr.StatusReason)
+
+	err = New(w, []string{"./jackal", "account", "enable", cfgArg, "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.Equal(t, model.UserActive, fetchUser().Status)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	err = New(w, []string{"./jackal", "account", "expire", cfgArg, "-at", expiresAt.Format(time.RFC3339), "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.True(t, expiresAt.Equal(fetchUser().ExpiresAt))
+
+	err = New(w, []string{"./jackal", "account", "expire", cfgArg, "-at", "never", "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.True(t, fetchUser().ExpiresAt.IsZero())
+
+	// already reached expiration times disable the account
+	err = New(w, []string{"./jackal", "account", "expire", cfgArg, "-at", "2020-01-01T00:00:00Z", "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.Equal(t, model.UserDisabled, fetchUser().Status)
+}
+
+func TestApplicationAccountRunningInstance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jackal")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	socket := filepath.Join(dir, "admin.sock")
+	cfgFile := filepath.Join(dir, "jackal.yml")
+	require.Nil(t, ioutil.WriteFile(cfgFile, []byte(fmt.Sprintf(`
+storage:
+  type: memory
+admin:
+  socket: %s
+`, socket)), 0644))
+	cfgArg := "--config=" + cfgFile
+
+	ctx := context.Background()
+	userRep := memorystorage.NewUser()
+	require.Nil(t, userRep.UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im"}})
+	srv, err := admin.New(socket, hosts, account.New(nil, nil, userRep))
+	require.Nil(t, err)
+	require.Nil(t, srv.Start())
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	// changes are applied by running instance
+	w := newWriterBuffer()
+	err = New(w, []string{"./jackal", "account", "disable", cfgArg, "-reason", "inactivity", "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	require.Contains(t, w.String(), "account disable: sxmpp@jackal.im")
+
+	usr, _ := userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, model.UserDisabled, usr.Status)
+	require.Equal(t, "inactivity", usr.StatusReason)
+
+	err = New(w, []string{"./jackal", "account", "enable", cfgArg, "romeo@jackal.im"}).Run()
+	require.Equal(t, account.ErrAccountNotFound, err)
+
+	err = New(w, []string{"./jackal", "account", "delete", cfgArg, "sxmpp@jackal.im"}).Run()
+	require.Nil(t, err)
+	usr, _ = userRep.FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, usr)
+
+	// deleting an account requires a running instance
+	_ = srv.Shutdown(context.Background())
+	err = New(w, []string{"./jackal", "account", "delete", cfgArg, "sxmpp@jackal.im"}).Run()
+	require.NotNil(t, err)
+}
+
 func TestApplication_Run(t *testing.T) {
 	w := newWriterBuffer()
 	args := []string{"./jackal", "--config=../testdata/config_basic.yml"}
@@ -15,6 +15,7 @@ import (
 	"github.com/sxmpp/jackal/router/host"
 	"github.com/sxmpp/jackal/s2s"
 	"github.com/sxmpp/jackal/storage"
+	"github.com/sxmpp/jackal/util/runqueue"
 	"gopkg.in/yaml.v2"
 )
 
@@ -23,18 +24,46 @@ type debugConfig struct {
 	Port int `yaml:"port"`
 }
 
+// adminConfig represents admin server configuration.
+type adminConfig struct {
+	Socket string `yaml:"socket"`
+}
+
 type loggerConfig struct {
 	Level   string `yaml:"level"`
 	LogPath string `yaml:"log_path"`
 }
 
+// hostConfig represents a virtual host configuration along with its modules overrides.
+type hostConfig struct {
+	host.Config
+	Modules *module.HostConfig
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *hostConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&c.Config); err != nil {
+		return err
+	}
+	p := struct {
+		Modules *module.HostConfig `yaml:"modules"`
+	}{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Modules = p.Modules
+	return nil
+}
+
 // Config represents a global configuration.
 type Config struct {
 	PIDFile    string           `yaml:"pid_path"`
 	Debug      debugConfig      `yaml:"debug"`
+	Admin      adminConfig      `yaml:"admin"`
 	Logger     loggerConfig     `yaml:"logger"`
+	RunQueue   runqueue.Config  `yaml:"run_queue"`
 	Storage    storage.Config   `yaml:"storage"`
-	Hosts      []host.Config    `yaml:"hosts"`
+	Hosts      []hostConfig     `yaml:"hosts"`
 	Modules    module.Config    `yaml:"modules"`
 	Components component.Config `yaml:"components"`
 	C2S        []c2s.Config     `yaml:"c2s"`
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/sxmpp/jackal/router/host"
+)
+
+// hostAdmin represents the set of virtual host admin operations, satisfied by both hosts registry
+// and admin client.
+type hostAdmin interface {
+	AddHost(ctx context.Context, name string, certPEM, keyPEM []byte) error
+	EnableHost(ctx context.Context, name string) error
+	DisableHost(ctx context.Context, name string) error
+	RemoveHost(ctx context.Context, name string) error
+}
+
+// runHost runs a 'host' subcommand, managing virtual hosts registered in configured storage.
+// Whenever a jackal instance is listening at configured admin socket, changes are applied through it.
+func (a *Application) runHost(args []string) error {
+	if len(args) == 0 {
+		return errors.New("host: missing command (add, enable, disable or remove)")
+	}
+	cmd := args[0]
+
+	var configFile, certFile, keyFile string
+
+	fs := flag.NewFlagSet("jackal host", flag.ExitOnError)
+	fs.SetOutput(a.output)
+	fs.StringVar(&configFile, "config", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&configFile, "c", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&certFile, "cert", "", "Host certificate file path.")
+	fs.StringVar(&keyFile, "key", "", "Host private key file path.")
+	_ = fs.Parse(args[1:])
+
+	if fs.NArg() == 0 {
+		return errors.New("host: missing host name")
+	}
+	name := fs.Arg(0)
+
+	var certPEM, keyPEM []byte
+	if cmd == "add" {
+		if len(certFile) == 0 || len(keyFile) == 0 {
+			return errors.New("host: certificate and private key files must be specified")
+		}
+		var err error
+		if certPEM, err = ioutil.ReadFile(certFile); err != nil {
+			return err
+		}
+		if keyPEM, err = ioutil.ReadFile(keyFile); err != nil {
+			return err
+		}
+	}
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// apply changes through running instance, if any
+	var hosts hostAdmin
+	cl, err := a.dialAdmin(&cfg)
+	if err != nil {
+		return err
+	}
+	if cl != nil {
+		defer func() { _ = cl.Close() }()
+		hosts = cl
+	} else {
+		_, rep, err := a.openStorage(configFile, a.output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rep.Close(context.Background()) }()
+
+		// go through hosts registry in order to validate changes against statically configured hosts
+		hostsCfg := make([]host.Config, 0, len(cfg.Hosts))
+		for _, h := range cfg.Hosts {
+			hostsCfg = append(hostsCfg, h.Config)
+		}
+		registry, err := host.New(hostsCfg)
+		if err != nil {
+			return err
+		}
+		if err := registry.Load(ctx, rep.Host()); err != nil {
+			return err
+		}
+		hosts = registry
+	}
+	switch cmd {
+	case "add":
+		err = hosts.AddHost(ctx, name, certPEM, keyPEM)
+	case "enable":
+		err = hosts.EnableHost(ctx, name)
+	case "disable":
+		err = hosts.DisableHost(ctx, name)
+	case "remove":
+		err = hosts.RemoveHost(ctx, name)
+	default:
+		return fmt.Errorf("host: unrecognized command: %s", cmd)
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(a.output, "host %s: %s\n", cmd, name)
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sxmpp/jackal/storage"
+	"github.com/sxmpp/jackal/storage/migration"
+)
+
+const appliedAtLayout = "2006-01-02 15:04:05"
+
+// runMigrate runs a 'migrate' subcommand against configured storage.
+func (a *Application) runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate: missing command (up, down or status)")
+	}
+	cmd := args[0]
+
+	var configFile string
+
+	fs := flag.NewFlagSet("jackal migrate", flag.ExitOnError)
+	fs.SetOutput(a.output)
+	fs.StringVar(&configFile, "config", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&configFile, "c", a.getDefaultConfigDir(), "Configuration file path.")
+	_ = fs.Parse(args[1:])
+
+	// load configuration
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return err
+	}
+	if err := a.initLogger(&cfg.Logger, a.output); err != nil {
+		return err
+	}
+	m, err := storage.NewMigrator(&cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = m.Close() }()
+
+	ctx := context.Background()
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(a.output, "applied %d migration(s), schema version is %d\n", n, m.Latest())
+
+	case "down":
+		version, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(a.output, "reverted migration %d\n", version)
+
+	case "status":
+		sts, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		a.printMigrationStatus(sts)
+
+	default:
+		return fmt.Errorf("migrate: unrecognized command: %s", cmd)
+	}
+	return nil
+}
+
+func (a *Application) printMigrationStatus(sts []migration.Status) {
+	for _, st := range sts {
+		state := "pending"
+		if st.Applied {
+			state = "applied at " + st.AppliedAt.Format(appliedAtLayout)
+		}
+		_, _ = fmt.Fprintf(a.output, "%4d  %-32s %s\n", st.Version, st.Description, state)
+	}
+}
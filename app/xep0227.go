@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sxmpp/jackal/storage"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/storage/xep0227"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// runExport runs an 'export' subcommand, writing XEP-0227 user data from configured storage.
+func (a *Application) runExport(args []string) error {
+	var configFile, userJID, domain, outFile string
+
+	fs := flag.NewFlagSet("jackal export", flag.ExitOnError)
+	fs.SetOutput(a.output)
+	fs.StringVar(&configFile, "config", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&configFile, "c", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&userJID, "user", "", "Export a single user account.")
+	fs.StringVar(&userJID, "u", "", "Export a single user account.")
+	fs.StringVar(&domain, "domain", "", "Export every user account of a domain.")
+	fs.StringVar(&domain, "d", "", "Export every user account of a domain.")
+	fs.StringVar(&outFile, "output", "", "Output file path.")
+	fs.StringVar(&outFile, "o", "", "Output file path.")
+	_ = fs.Parse(args)
+
+	if len(userJID) > 0 && len(domain) > 0 {
+		return errors.New("export: user and domain options are mutually exclusive")
+	}
+	// when exporting to standard output, keep log lines out of the document
+	w, logOutput := a.output, ioutil.Discard
+	if len(outFile) > 0 {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w, logOutput = f, a.output
+	}
+	cfg, rep, err := a.openStorage(configFile, logOutput)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rep.Close(context.Background()) }()
+
+	ctx := context.Background()
+	switch {
+	case len(userJID) > 0:
+		j, err := jid.NewWithString(userJID, false)
+		if err != nil {
+			return err
+		}
+		if len(j.Node()) == 0 {
+			return fmt.Errorf("export: invalid user: %s", userJID)
+		}
+		return xep0227.ExportUser(ctx, w, rep, j.Node(), j.Domain())
+
+	case len(domain) > 0:
+		return xep0227.Export(ctx, w, rep, domain)
+
+	default:
+		domains, err := exportDomains(ctx, cfg, rep)
+		if err != nil {
+			return err
+		}
+		return xep0227.Export(ctx, w, rep, domains...)
+	}
+}
+
+// runImport runs an 'import' subcommand, storing XEP-0227 user data into configured storage.
+func (a *Application) runImport(args []string) error {
+	var configFile string
+	var opts xep0227.ImportOptions
+
+	fs := flag.NewFlagSet("jackal import", flag.ExitOnError)
+	fs.SetOutput(a.output)
+	fs.StringVar(&configFile, "config", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.StringVar(&configFile, "c", a.getDefaultConfigDir(), "Configuration file path.")
+	fs.BoolVar(&opts.SkipPasswordless, "skip-passwordless", false, "Skip new user accounts lacking a password instead of failing.")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("import: missing input file")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	_, rep, err := a.openStorage(configFile, a.output)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rep.Close(context.Background()) }()
+
+	n, err := xep0227.Import(context.Background(), f, rep, opts)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(a.output, "imported %d user(s)\n", n)
+	return nil
+}
+
+func (a *Application) openStorage(configFile string, logOutput io.Writer) (*Config, repository.Container, error) {
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return nil, nil, err
+	}
+	if err := a.initLogger(&cfg.Logger, logOutput); err != nil {
+		return nil, nil, err
+	}
+	rep, err := storage.New(&cfg.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cfg, rep, nil
+}
+
+// exportDomains returns every configured and dynamically registered host domain.
+func exportDomains(ctx context.Context, cfg *Config, rep repository.Container) ([]string, error) {
+	set := make(map[string]struct{})
+	for _, h := range cfg.Hosts {
+		set[h.Name] = struct{}{}
+	}
+	hosts, err := rep.Host().FetchHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		set[h.Name] = struct{}{}
+	}
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
@@ -8,6 +8,7 @@ package auth
 import (
 	"context"
 
+	"github.com/sxmpp/jackal/model"
 	"github.com/sxmpp/jackal/xmpp"
 )
 
@@ -40,10 +41,11 @@ type Authenticator interface {
 // SASLError represents specific SASL error type.
 type SASLError struct {
 	reason string
+	text   string
 }
 
 func newSASLError(reason string) error {
-	return &SASLError{reason}
+	return &SASLError{reason: reason}
 }
 
 // Element returs sasl error XML representation.
@@ -51,12 +53,20 @@ func (se *SASLError) Element() xmpp.XElement {
 	return xmpp.NewElementName(se.reason)
 }
 
+// Text returns sasl error descriptive text, if any.
+func (se *SASLError) Text() string {
+	return se.text
+}
+
 // Error satisfies error interface.
 func (se *SASLError) Error() string {
 	return se.reason
 }
 
 var (
+	// ErrSASLAccountDisabled represents a 'account-disabled' authentication error.
+	ErrSASLAccountDisabled = newSASLError("account-disabled")
+
 	// ErrSASLIncorrectEncoding represents a 'incorrect-encoding' authentication error.
 	ErrSASLIncorrectEncoding = newSASLError("incorrect-encoding")
 
@@ -69,3 +79,15 @@ var (
 	// ErrSASLTemporaryAuthFailure represents a 'temporary-auth-failure' authentication error.
 	ErrSASLTemporaryAuthFailure = newSASLError("temporary-auth-failure")
 )
+
+// accountDisabledError returns an 'account-disabled' authentication error describing why user account is disabled.
+func accountDisabledError(user *model.User) error {
+	text := user.StatusReason
+	if len(text) == 0 && user.IsExpired() {
+		text = "account expired"
+	}
+	if len(text) == 0 {
+		return ErrSASLAccountDisabled
+	}
+	return &SASLError{reason: "account-disabled", text: text}
+}
@@ -8,6 +8,7 @@ package auth
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sxmpp/jackal/model"
@@ -31,13 +32,27 @@ func authTestSetup(user *model.User) (*stream.MockC2S, *memorystorage.User) {
 }
 
 func TestAuthError(t *testing.T) {
+	require.Equal(t, "account-disabled", ErrSASLAccountDisabled.(*SASLError).Error())
 	require.Equal(t, "incorrect-encoding", ErrSASLIncorrectEncoding.(*SASLError).Error())
 	require.Equal(t, "malformed-request", ErrSASLMalformedRequest.(*SASLError).Error())
 	require.Equal(t, "not-authorized", ErrSASLNotAuthorized.(*SASLError).Error())
 	require.Equal(t, "temporary-auth-failure", ErrSASLTemporaryAuthFailure.(*SASLError).Error())
 
+	require.Equal(t, "account-disabled", ErrSASLAccountDisabled.(*SASLError).Element().Name())
 	require.Equal(t, "incorrect-encoding", ErrSASLIncorrectEncoding.(*SASLError).Element().Name())
 	require.Equal(t, "malformed-request", ErrSASLMalformedRequest.(*SASLError).Element().Name())
 	require.Equal(t, "not-authorized", ErrSASLNotAuthorized.(*SASLError).Element().Name())
 	require.Equal(t, "temporary-auth-failure", ErrSASLTemporaryAuthFailure.(*SASLError).Element().Name())
 }
+
+func TestAuthAccountDisabledError(t *testing.T) {
+	err := accountDisabledError(&model.User{Status: model.UserDisabled})
+	require.Equal(t, ErrSASLAccountDisabled, err)
+
+	err = accountDisabledError(&model.User{Status: model.UserBanned, StatusReason: "spam"})
+	require.Equal(t, "account-disabled", err.(*SASLError).Element().Name())
+	require.Equal(t, "spam", err.(*SASLError).Text())
+
+	err = accountDisabledError(&model.User{Status: model.UserActive, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.Equal(t, "account expired", err.(*SASLError).Text())
+}
@@ -70,13 +70,16 @@ func (p *Plain) ProcessElement(ctx context.Context, elem xmpp.XElement) error {
 	password := string(s[2])
 
 	// validate user and password
-	user, err := p.userRep.FetchUser(ctx, username)
+	user, err := p.userRep.FetchUser(ctx, username, p.stm.Domain())
 	if err != nil {
 		return err
 	}
-	if user == nil || user.Password != password {
+	if user == nil || len(user.Password) == 0 || user.Password != password {
 		return ErrSASLNotAuthorized
 	}
+	if user.IsDisabled() {
+		return accountDisabledError(user)
+	}
 	p.username = username
 	p.authenticated = true
 
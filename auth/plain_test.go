@@ -20,7 +20,7 @@ import (
 func TestAuthPlainAuthentication(t *testing.T) {
 	var err error
 
-	testStm, s := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	testStm, s := authTestSetup(&model.User{Username: "mariana", Domain: "localhost", Password: "1234"})
 
 	authr := NewPlain(testStm, s)
 	require.Equal(t, authr.Mechanism(), "PLAIN")
@@ -100,4 +100,43 @@ func TestAuthPlainAuthentication(t *testing.T) {
 	authr.Reset()
 	err = authr.ProcessElement(context.Background(), elem)
 	require.Equal(t, ErrSASLNotAuthorized, err)
+
+	// disabled account
+	user, _ := s.FetchUser(context.Background(), "mariana", "localhost")
+	user.Status = model.UserBanned
+	user.StatusReason = "spam"
+	_ = s.UpsertUser(context.Background(), user)
+
+	authr.Reset()
+	err = authr.ProcessElement(context.Background(), elem)
+	require.Equal(t, ErrSASLNotAuthorized, err) // incorrect password still reported as such
+
+	buf.Reset()
+	buf.WriteByte(0)
+	buf.WriteString("mariana")
+	buf.WriteByte(0)
+	buf.WriteString("1234")
+	elem.SetText(base64.StdEncoding.EncodeToString(buf.Bytes()))
+
+	authr.Reset()
+	err = authr.ProcessElement(context.Background(), elem)
+	require.NotNil(t, err)
+	require.Equal(t, "account-disabled", err.(*SASLError).Error())
+	require.Equal(t, "spam", err.(*SASLError).Text())
+	require.False(t, authr.Authenticated())
+
+	// accounts lacking a password can't be authenticated
+	user.Status = model.UserActive
+	user.Password = ""
+	_ = s.UpsertUser(context.Background(), user)
+
+	buf.Reset()
+	buf.WriteByte(0)
+	buf.WriteString("mariana")
+	buf.WriteByte(0)
+	elem.SetText(base64.StdEncoding.EncodeToString(buf.Bytes()))
+
+	authr.Reset()
+	err = authr.ProcessElement(context.Background(), elem)
+	require.Equal(t, ErrSASLNotAuthorized, err)
 }
@@ -200,11 +200,11 @@ func (s *Scram) handleStart(ctx context.Context, elem xmpp.XElement) error {
 	if len(username) == 0 || len(cNonce) == 0 {
 		return ErrSASLMalformedRequest
 	}
-	user, err := s.userRep.FetchUser(ctx, username)
+	user, err := s.userRep.FetchUser(ctx, username, s.stm.Domain())
 	if err != nil {
 		return err
 	}
-	if user == nil {
+	if user == nil || len(user.Password) == 0 {
 		return ErrSASLNotAuthorized
 	}
 	s.user = user
@@ -252,6 +252,9 @@ func (s *Scram) handleChallenged(ctx context.Context, elem xmpp.XElement) error
 	if clientFinalMessage != p {
 		return ErrSASLNotAuthorized
 	}
+	if s.user.IsDisabled() {
+		return accountDisabledError(s.user)
+	}
 	v := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
 
 	respElem := xmpp.NewElementNamespace("success", saslNamespace)
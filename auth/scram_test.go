@@ -44,15 +44,16 @@ type fakeTransport struct {
 	cbBytes []byte
 }
 
-func (ft *fakeTransport) Read(p []byte) (n int, err error)        { return 0, nil }
-func (ft *fakeTransport) Write(p []byte) (n int, err error)       { return 0, nil }
-func (ft *fakeTransport) Close() error                            { return nil }
-func (ft *fakeTransport) Type() transport.Type                    { return transport.Socket }
-func (ft *fakeTransport) Flush() error                            { return nil }
-func (ft *fakeTransport) SetWriteDeadline(_ time.Time) error      { return nil }
-func (ft *fakeTransport) WriteString(s string) (n int, err error) { return 0, nil }
-func (ft *fakeTransport) StartTLS(*tls.Config, bool)              { return }
-func (ft *fakeTransport) EnableCompression(compress.Level)        { return }
+func (ft *fakeTransport) Read(p []byte) (n int, err error)                 { return 0, nil }
+func (ft *fakeTransport) Write(p []byte) (n int, err error)                { return 0, nil }
+func (ft *fakeTransport) Close() error                                     { return nil }
+func (ft *fakeTransport) Type() transport.Type                             { return transport.Socket }
+func (ft *fakeTransport) Flush() error                                     { return nil }
+func (ft *fakeTransport) SetWriteDeadline(_ time.Time) error               { return nil }
+func (ft *fakeTransport) WriteString(s string) (n int, err error)          { return 0, nil }
+func (ft *fakeTransport) WritePrepared(*xmpp.PreparedStanza, string) error { return nil }
+func (ft *fakeTransport) StartTLS(*tls.Config, bool)                       { return }
+func (ft *fakeTransport) EnableCompression(compress.Level)                 { return }
 func (ft *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte {
 	return ft.cbBytes
 }
@@ -68,6 +69,7 @@ type scramAuthTestCase struct {
 	n           string
 	r           string
 	password    string
+	status      string
 	expectedErr error
 }
 
@@ -206,11 +208,35 @@ var tt = []scramAuthTestCase{
 		password:    "1234",
 		expectedErr: ErrSASLMalformedRequest,
 	},
+	{
+		// disabled account
+		id:          12,
+		scramType:   ScramSHA256,
+		usesCb:      false,
+		gs2BindFlag: "n",
+		n:           "sxmpp",
+		r:           "bb769406-eaa4-4f38-a279-2b90e596f6dd",
+		password:    "1234",
+		status:      model.UserDisabled,
+		expectedErr: ErrSASLAccountDisabled,
+	},
+	{
+		// disabled account and invalid password
+		id:          13,
+		scramType:   ScramSHA256,
+		usesCb:      false,
+		gs2BindFlag: "n",
+		n:           "sxmpp",
+		r:           "bb769406-eaa4-4f38-a279-2b90e596f6dd",
+		password:    "12345678",
+		status:      model.UserBanned,
+		expectedErr: ErrSASLNotAuthorized,
+	},
 }
 
 func TestScramMechanisms(t *testing.T) {
 	testTr := &fakeTransport{}
-	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Password: "1234"})
+	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Domain: "localhost", Password: "1234"})
 
 	authr := NewScram(testStm, testTr, ScramSHA1, false, s)
 	require.Equal(t, authr.Mechanism(), "SCRAM-SHA-1")
@@ -234,7 +260,7 @@ func TestScramMechanisms(t *testing.T) {
 
 func TestScramBadPayload(t *testing.T) {
 	testTr := &fakeTransport{}
-	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Password: "1234"})
+	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Domain: "localhost", Password: "1234"})
 
 	authr := NewScram(testStm, testTr, ScramSHA1, false, s)
 
@@ -260,12 +286,22 @@ func TestScramTestCases(t *testing.T) {
 	}
 }
 
+func TestScramAccountDisabled(t *testing.T) {
+	for _, tc := range tt {
+		if len(tc.status) == 0 {
+			continue
+		}
+		err := processScramTestCase(t, &tc)
+		require.Equal(t, tc.expectedErr, err, fmt.Sprintf("TC identifier: %d", tc.id))
+	}
+}
+
 func processScramTestCase(t *testing.T, tc *scramAuthTestCase) error {
 	tr := &fakeTransport{}
 	if tc.usesCb {
 		tr.cbBytes = tc.cbBytes
 	}
-	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Password: "1234"})
+	testStm, s := authTestSetup(&model.User{Username: "sxmpp", Domain: "localhost", Password: "1234", Status: tc.status})
 
 	authr := NewScram(testStm, tr, tc.scramType, tc.usesCb, s)
 
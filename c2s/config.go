@@ -22,6 +22,7 @@ const (
 	defaultTransportPort      = 5222
 	defaultTransportKeepAlive = time.Duration(120) * time.Second
 	defaultTransportURLPath   = "/xmpp/ws"
+	defaultCSIQueueSize       = 100
 )
 
 // ResourceConflictPolicy represents a resource conflict policy.
@@ -68,6 +69,37 @@ func (c *CompressConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return nil
 }
 
+// CSIConfig represents a client state indication (XEP-0352) traffic buffering configuration.
+type CSIConfig struct {
+	// QueueSize is the maximum number of elements buffered while client is inactive.
+	QueueSize int
+
+	// UrgentNamespaces contains the namespaces of those payloads that must be delivered right away.
+	UrgentNamespaces []string
+}
+
+type csiProxyType struct {
+	QueueSize        int      `yaml:"queue_size"`
+	UrgentNamespaces []string `yaml:"urgent_namespaces"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *CSIConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := csiProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.QueueSize < 0 {
+		return fmt.Errorf("c2s.CSIConfig: queue size must be a positive value")
+	}
+	c.QueueSize = p.QueueSize
+	if c.QueueSize == 0 {
+		c.QueueSize = defaultCSIQueueSize
+	}
+	c.UrgentNamespaces = p.UrgentNamespaces
+	return nil
+}
+
 // TransportConfig represents an XMPP stream transport configuration.
 type TransportConfig struct {
 	Type        transport.Type
@@ -130,6 +162,7 @@ type Config struct {
 	Transport        TransportConfig
 	SASL             []string
 	Compression      CompressConfig
+	CSI              CSIConfig
 }
 
 type configProxy struct {
@@ -144,6 +177,7 @@ type configProxy struct {
 	Transport        TransportConfig `yaml:"transport"`
 	SASL             []string        `yaml:"sasl"`
 	Compression      CompressConfig  `yaml:"compression"`
+	CSI              CSIConfig       `yaml:"csi"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -194,6 +228,10 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	cfg.Transport = p.Transport
 	cfg.SASL = p.SASL
 	cfg.Compression = p.Compression
+	cfg.CSI = p.CSI
+	if cfg.CSI.QueueSize == 0 {
+		cfg.CSI.QueueSize = defaultCSIQueueSize
+	}
 	return nil
 }
 
@@ -205,5 +243,6 @@ type streamConfig struct {
 	resourceConflict ResourceConflictPolicy
 	sasl             []string
 	compression      CompressConfig
+	csi              CSIConfig
 	onDisconnect     func(s stream.C2S)
 }
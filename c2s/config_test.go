@@ -36,6 +36,22 @@ func TestCompressionConfig(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestCSIConfig(t *testing.T) {
+	csi := CSIConfig{}
+	err := yaml.Unmarshal([]byte("{queue_size: 50, urgent_namespaces: [urn:xmpp:jingle-message:0]}"), &csi)
+	require.Nil(t, err)
+	require.Equal(t, 50, csi.QueueSize)
+	require.Equal(t, []string{"urn:xmpp:jingle-message:0"}, csi.UrgentNamespaces)
+
+	csi = CSIConfig{}
+	err = yaml.Unmarshal([]byte("{urgent_namespaces: []}"), &csi)
+	require.Nil(t, err)
+	require.Equal(t, defaultCSIQueueSize, csi.QueueSize)
+
+	err = yaml.Unmarshal([]byte("{queue_size: -1}"), &csi)
+	require.NotNil(t, err)
+}
+
 func TestTransportConfig(t *testing.T) {
 	s := TransportConfig{}
 
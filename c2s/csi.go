@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+const (
+	csiNamespace         = "urn:xmpp:csi:0"
+	pubSubEventNamespace = "http://jabber.org/protocol/pubsub#event"
+)
+
+// csiQueue buffers outgoing traffic while client is inactive (XEP-0352).
+// It is only accessed from the stream run queue, so no synchronization is required.
+type csiQueue struct {
+	maxSize          int
+	urgentNamespaces []string
+	elems            []xmpp.XElement
+}
+
+func newCSIQueue(config *CSIConfig) *csiQueue {
+	maxSize := config.QueueSize
+	if maxSize <= 0 {
+		maxSize = defaultCSIQueueSize
+	}
+	return &csiQueue{
+		maxSize:          maxSize,
+		urgentNamespaces: config.UrgentNamespaces,
+	}
+}
+
+// push handles an outgoing element while client is inactive, returning those elements
+// that must be written right away, in order.
+func (q *csiQueue) push(elem xmpp.XElement) []xmpp.XElement {
+	if q.isUrgent(elem) {
+		return append(q.flush(), elem)
+	}
+	switch stanza := elem.(type) {
+	case *xmpp.Presence:
+		// keep only latest presence update per contact
+		q.remove(stanza.FromJID().String())
+	case *xmpp.Message:
+		// hold PEP events
+		break
+	default:
+		// keep buffered traffic ordered ahead of any IQ
+		return append(q.flush(), elem)
+	}
+	q.elems = append(q.elems, elem)
+	if len(q.elems) >= q.maxSize {
+		return q.flush()
+	}
+	return nil
+}
+
+// flush empties the queue returning all buffered elements.
+func (q *csiQueue) flush() []xmpp.XElement {
+	elems := q.elems
+	q.elems = nil
+	return elems
+}
+
+func (q *csiQueue) len() int {
+	return len(q.elems)
+}
+
+func (q *csiQueue) remove(from string) {
+	for i, e := range q.elems {
+		if p, ok := e.(*xmpp.Presence); ok && p.FromJID().String() == from {
+			q.elems = append(q.elems[:i], q.elems[i+1:]...)
+			return
+		}
+	}
+}
+
+func (q *csiQueue) isUrgent(elem xmpp.XElement) bool {
+	for _, ns := range q.urgentNamespaces {
+		for _, child := range elem.Elements().All() {
+			if child.Namespace() == ns {
+				return true
+			}
+		}
+	}
+	switch stanza := elem.(type) {
+	case *xmpp.Presence:
+		// subscription management presences
+		return !stanza.IsAvailable() && !stanza.IsUnavailable()
+	case *xmpp.Message:
+		// anything but PEP events (chats, carbons, archive results...)
+		return !isPEPEvent(stanza)
+	}
+	return false
+}
+
+func isPEPEvent(message *xmpp.Message) bool {
+	if message.IsError() || message.IsMessageWithBody() {
+		return false
+	}
+	return message.Elements().ChildNamespace("event", pubSubEventNamespace) != nil
+}
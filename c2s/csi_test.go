@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSIQueue_Presences(t *testing.T) {
+	q := newCSIQueue(&CSIConfig{})
+	require.Equal(t, defaultCSIQueueSize, q.maxSize)
+
+	j1, _ := jid.New("romeo", "localhost", "garden", true)
+	j2, _ := jid.New("juliet", "localhost", "balcony", true)
+	to, _ := jid.New("user", "localhost", "balcony", true)
+
+	require.Nil(t, q.push(xmpp.NewPresence(j1, to, xmpp.AvailableType)))
+	require.Nil(t, q.push(xmpp.NewPresence(j2, to, xmpp.AvailableType)))
+
+	latest := xmpp.NewPresence(j1, to, xmpp.UnavailableType)
+	require.Nil(t, q.push(latest))
+	require.Equal(t, 2, q.len())
+
+	// subscription requests are urgent
+	subscribe := xmpp.NewPresence(j1.ToBareJID(), to.ToBareJID(), xmpp.SubscribeType)
+	elems := q.push(subscribe)
+	require.Len(t, elems, 3)
+	require.Equal(t, j2.String(), elems[0].From())
+	require.Equal(t, latest, elems[1])
+	require.Equal(t, subscribe, elems[2])
+	require.Equal(t, 0, q.len())
+}
+
+func TestCSIQueue_Messages(t *testing.T) {
+	q := newCSIQueue(&CSIConfig{QueueSize: 10, UrgentNamespaces: []string{"urn:xmpp:jingle-message:0"}})
+
+	from, _ := jid.New("romeo", "localhost", "garden", true)
+	to, _ := jid.New("user", "localhost", "balcony", true)
+
+	// PEP event
+	event := xmpp.NewMessageType(uuid.New().String(), xmpp.HeadlineType)
+	event.SetFromJID(from.ToBareJID())
+	event.SetToJID(to)
+	event.AppendElement(xmpp.NewElementNamespace("event", "http://jabber.org/protocol/pubsub#event"))
+	require.Nil(t, q.push(event))
+
+	// IQs flush buffered elements
+	iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	require.Equal(t, []xmpp.XElement{event, iq}, q.push(iq))
+	require.Equal(t, 0, q.len())
+
+	// urgent payload
+	require.Nil(t, q.push(event))
+
+	propose := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	propose.SetFromJID(from)
+	propose.SetToJID(to)
+	propose.AppendElement(xmpp.NewElementNamespace("propose", "urn:xmpp:jingle-message:0"))
+	require.Equal(t, []xmpp.XElement{event, propose}, q.push(propose))
+
+	// messages with body
+	require.Nil(t, q.push(event))
+
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	require.Equal(t, []xmpp.XElement{event, msg}, q.push(msg))
+
+	// forwarded carbons
+	require.Nil(t, q.push(event))
+
+	carbon := xmpp.NewMessageType(uuid.New().String(), xmpp.NormalType)
+	carbon.SetFromJID(to.ToBareJID())
+	carbon.SetToJID(to)
+	received := xmpp.NewElementNamespace("received", "urn:xmpp:carbons:2")
+	forwarded := xmpp.NewElementNamespace("forwarded", "urn:xmpp:forward:0")
+	forwarded.AppendElement(msg)
+	received.AppendElement(forwarded)
+	carbon.AppendElement(received)
+	require.Equal(t, []xmpp.XElement{event, carbon}, q.push(carbon))
+
+	// chat states
+	require.Nil(t, q.push(event))
+
+	composing := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	composing.SetFromJID(from)
+	composing.SetToJID(to)
+	composing.AppendElement(xmpp.NewElementNamespace("composing", "http://jabber.org/protocol/chatstates"))
+	require.Equal(t, []xmpp.XElement{event, composing}, q.push(composing))
+}
+
+func TestCSIQueue_Overflow(t *testing.T) {
+	q := newCSIQueue(&CSIConfig{QueueSize: 3})
+
+	to, _ := jid.New("user", "localhost", "balcony", true)
+	for _, username := range []string{"romeo", "juliet"} {
+		from, _ := jid.New(username, "localhost", "garden", true)
+		require.Nil(t, q.push(xmpp.NewPresence(from, to, xmpp.AvailableType)))
+	}
+	from, _ := jid.New("mercutio", "localhost", "garden", true)
+	require.Len(t, q.push(xmpp.NewPresence(from, to, xmpp.AvailableType)), 3)
+	require.Equal(t, 0, q.len())
+}
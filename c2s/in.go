@@ -61,6 +61,8 @@ type inStream struct {
 	authenticated  bool
 	sessStarted    bool
 	presence       *xmpp.Presence
+	inactive       bool
+	csi            *csiQueue
 	ctx            context.Context
 	ctxCancelFn    context.CancelFunc
 }
@@ -77,6 +79,7 @@ func newStream(id string, config *streamConfig, tr transport.Transport, mods *mo
 		comps:        comps,
 		id:           id,
 		runQueue:     runqueue.New(id),
+		csi:          newCSIQueue(&config.csi),
 		ctx:          ctx,
 		ctxCancelFn:  ctxCancelFn,
 	}
@@ -171,7 +174,12 @@ func (s *inStream) SendElement(ctx context.Context, elem xmpp.XElement) {
 	if s.getState() == disconnected {
 		return
 	}
-	s.runQueue.Run(func() { s.writeElement(ctx, elem) })
+	_ = s.runQueue.TryRun(func() {
+		s.sendElement(ctx, elem)
+	}, func() {
+		log.Warnf("c2s stream %s: element discarded due to stream congestion", s.id)
+		s.bounceElement(ctx, elem)
+	})
 }
 
 // Disconnect disconnects remote peer by closing the underlying TCP socket connection.
@@ -187,6 +195,19 @@ func (s *inStream) Disconnect(ctx context.Context, err error) {
 	<-waitCh
 }
 
+// bounceElement replies to the sender with a 'resource-constraint' stanza error
+// when an element couldn't be delivered due to stream congestion.
+func (s *inStream) bounceElement(ctx context.Context, elem xmpp.XElement) {
+	stanza, ok := elem.(xmpp.Stanza)
+	if !ok || stanza.IsError() || stanza.FromJID() == nil {
+		return
+	}
+	if iq, ok := stanza.(*xmpp.IQ); ok && !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	_ = s.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(stanza, xmpp.ErrResourceConstraint, nil))
+}
+
 func (s *inStream) initializeAuthenticators() {
 	tr := s.tr
 	hasChannelBinding := len(tr.ChannelBindingBytes(transport.TLSUnique)) > 0
@@ -292,7 +313,7 @@ func (s *inStream) unauthenticatedFeatures() []xmpp.XElement {
 	// allow In-band registration over encrypted stream only
 	allowRegistration := s.IsSecured()
 
-	if reg := s.mods.Register; reg != nil && allowRegistration {
+	if reg := s.modules().Register; reg != nil && allowRegistration {
 		registerFeature := xmpp.NewElementNamespace("register", "http://jabber.org/features/iq-register")
 		features = append(features, registerFeature)
 	}
@@ -322,10 +343,12 @@ func (s *inStream) authenticatedFeatures() []xmpp.XElement {
 	sessElem := xmpp.NewElementNamespace("session", "urn:ietf:params:xml:ns:xmpp-session")
 	features = append(features, sessElem)
 
-	if s.mods.Roster != nil {
+	if s.modules().Roster != nil {
 		ver := xmpp.NewElementNamespace("ver", "urn:xmpp:features:rosterver")
 		features = append(features, ver)
 	}
+	// XEP-0352: Client State Indication
+	features = append(features, xmpp.NewElementNamespace("csi", csiNamespace))
 	return features
 }
 
@@ -339,7 +362,7 @@ func (s *inStream) handleConnected(ctx context.Context, elem xmpp.XElement) {
 
 	case "iq":
 		iq := elem.(*xmpp.IQ)
-		if reg := s.mods.Register; reg != nil && reg.MatchesIQ(iq) {
+		if reg := s.modules().Register; reg != nil && reg.MatchesIQ(iq) {
 			if s.IsSecured() {
 				reg.ProcessIQWithStream(ctx, iq, s)
 			} else {
@@ -397,9 +420,13 @@ func (s *inStream) handleAuthenticated(ctx context.Context, elem xmpp.XElement)
 
 func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 	// reset ping timer deadline
-	if p := s.mods.Ping; p != nil {
+	if p := s.modules().Ping; p != nil {
 		p.SchedulePing(s)
 	}
+	if elem.Namespace() == csiNamespace {
+		s.processClientState(ctx, elem)
+		return
+	}
 	stanza, ok := elem.(xmpp.Stanza)
 	if !ok {
 		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
@@ -417,16 +444,12 @@ func (s *inStream) handleBound(ctx context.Context, elem xmpp.XElement) {
 			return
 		}
 	}
-	if comp := s.comps.Get(stanza.ToJID().Domain()); comp != nil { // component stanza?
-		switch stanza := stanza.(type) {
-		case *xmpp.IQ:
-			if di := s.mods.DiscoInfo; di != nil && di.MatchesIQ(stanza) {
-				di.ProcessIQ(ctx, stanza)
-				return
-			}
-			break
+	if s.comps.IsComponentHost(stanza.ToJID().Domain()) { // component stanza?
+		if presence, ok := stanza.(*xmpp.Presence); ok && presence.ToJID().IsFullWithUser() {
+			s.processPresence(ctx, presence) // keep track of directed presences
+			return
 		}
-		comp.ProcessStanza(ctx, stanza, s)
+		_ = s.comps.Route(ctx, stanza)
 		return
 	}
 	s.processStanza(ctx, stanza)
@@ -444,7 +467,7 @@ func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
 	s.setSecured(true)
 	s.writeElement(ctx, xmpp.NewElementNamespace("proceed", tlsNamespace))
 
-	s.tr.StartTLS(&tls.Config{Certificates: s.router.Hosts().Certificates()}, false)
+	s.tr.StartTLS(&tls.Config{GetCertificate: s.router.Hosts().GetCertificate}, false)
 
 	log.Infof("secured stream... id: %s", s.id)
 	s.restartSession()
@@ -507,10 +530,10 @@ func (s *inStream) startAuthentication(ctx context.Context, elem xmpp.XElement)
 func (s *inStream) continueAuthentication(ctx context.Context, elem xmpp.XElement, authr auth.Authenticator) error {
 	err := authr.ProcessElement(ctx, elem)
 	if saslErr, ok := err.(*auth.SASLError); ok {
-		s.failAuthentication(ctx, saslErr.Element())
+		s.failAuthentication(ctx, saslErr)
 	} else if err != nil {
 		log.Error(err)
-		s.failAuthentication(ctx, auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError).Element())
+		s.failAuthentication(ctx, auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError))
 	}
 	return err
 }
@@ -527,9 +550,15 @@ func (s *inStream) finishAuthentication(_ context.Context, username string) {
 	s.restartSession()
 }
 
-func (s *inStream) failAuthentication(ctx context.Context, elem xmpp.XElement) {
+func (s *inStream) failAuthentication(ctx context.Context, saslErr *auth.SASLError) {
 	failure := xmpp.NewElementNamespace("failure", saslNamespace)
-	failure.AppendElement(elem)
+	failure.AppendElement(saslErr.Element())
+	if text := saslErr.Text(); len(text) > 0 {
+		textEl := xmpp.NewElementName("text")
+		textEl.SetAttribute("xml:lang", "en")
+		textEl.SetText(text)
+		failure.AppendElement(textEl)
+	}
 	s.writeElement(ctx, failure)
 
 	if s.activeAuth != nil {
@@ -553,7 +582,7 @@ func (s *inStream) bindResource(ctx context.Context, iq *xmpp.IQ) {
 	}
 	// try binding...
 	var stm stream.C2S
-	streams := s.router.LocalStreams(s.JID().Node())
+	streams := s.router.LocalStreams(s.JID().Node(), s.JID().Domain())
 	for _, s := range streams {
 		if s.Resource() == resource {
 			stm = s
@@ -601,7 +630,7 @@ func (s *inStream) bindResource(ctx context.Context, iq *xmpp.IQ) {
 	s.writeElement(ctx, result)
 
 	// start pinging...
-	if p := s.mods.Ping; p != nil {
+	if p := s.modules().Ping; p != nil {
 		p.SchedulePing(s)
 	}
 }
@@ -646,56 +675,113 @@ func (s *inStream) processIQ(ctx context.Context, iq *xmpp.IQ) {
 }
 
 func (s *inStream) processPresence(ctx context.Context, presence *xmpp.Presence) {
+	replyOnBehalf := s.JID().MatchesWithOptions(presence.ToJID(), jid.MatchesBare)
+
 	if presence.ToJID().IsFullWithUser() {
+		// keep track of directed presences
+		if r := s.modules().Roster; r != nil && !replyOnBehalf && (presence.IsAvailable() || presence.IsUnavailable()) {
+			r.ProcessPresence(ctx, presence)
+			return
+		}
 		_ = s.router.Route(ctx, presence)
 		return
 	}
-	replyOnBehalf := s.JID().MatchesWithOptions(presence.ToJID(), jid.MatchesBare)
 
 	// update presence
 	if replyOnBehalf && (presence.IsAvailable() || presence.IsUnavailable()) {
 		s.setPresence(presence)
 	}
 	// process presence
-	if r := s.mods.Roster; r != nil {
+	if r := s.modules().Roster; r != nil {
 		r.ProcessPresence(ctx, presence)
 	}
 
 	// deliver offline messages
 	if replyOnBehalf && presence.IsAvailable() && presence.Priority() >= 0 {
-		if off := s.mods.Offline; off != nil {
+		if off := s.modules().Offline; off != nil {
 			off.DeliverOfflineMessages(ctx, s)
 		}
 	}
 }
 
 func (s *inStream) processMessage(ctx context.Context, message *xmpp.Message) {
+	// archive message into sender and recipient archives
+	if mam := s.modules().MAM; mam != nil {
+		mam.ArchiveOutgoingMessage(ctx, message)
+	}
+	if toDomain := message.ToJID().Domain(); s.router.Hosts().IsLocalHost(toDomain) {
+		if mam := s.mods.For(toDomain).MAM; mam != nil {
+			mam.ArchiveIncomingMessage(ctx, message, s.deliverMessage)
+			return
+		}
+	}
+	s.deliverMessage(ctx, message)
+}
+
+// deliverMessage routes a message sent by the stream user.
+// Since it can be called once message has been archived from another goroutine, errors are sent
+// by means of SendElement.
+func (s *inStream) deliverMessage(ctx context.Context, message *xmpp.Message) {
+	// send carbon copies to sender's other resources
+	if carbons := s.modules().Carbons; carbons != nil {
+		carbons.ProcessOutgoingMessage(ctx, message)
+	}
 	msg := message
 
 sendMessage:
 	err := s.router.Route(ctx, msg)
 	switch err {
 	case nil:
-		break
+		// send carbon copies to recipient's other resources
+		if toDomain := msg.ToJID().Domain(); s.router.Hosts().IsLocalHost(toDomain) {
+			if carbons := s.mods.For(toDomain).Carbons; carbons != nil {
+				carbons.ProcessIncomingMessage(ctx, msg)
+			}
+		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
 		msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
 		goto sendMessage
 	case router.ErrNotAuthenticated:
-		if off := s.mods.Offline; off != nil {
+		if off := s.mods.For(message.ToJID().Domain()).Offline; off != nil {
 			off.ArchiveMessage(ctx, message)
 			return
 		}
 		fallthrough
 	case router.ErrNotExistingAccount, router.ErrBlockedJID:
-		s.writeElement(ctx, message.ServiceUnavailableError())
+		s.SendElement(ctx, message.ServiceUnavailableError())
 	case router.ErrFailedRemoteConnect:
-		s.writeElement(ctx, message.RemoteServerNotFoundError())
+		s.SendElement(ctx, message.RemoteServerNotFoundError())
 	default:
 		log.Error(err)
 	}
 }
 
+func (s *inStream) processClientState(ctx context.Context, elem xmpp.XElement) {
+	switch elem.Name() {
+	case "active":
+		s.inactive = false
+		for _, e := range s.csi.flush() {
+			s.writeElement(ctx, e)
+		}
+	case "inactive":
+		s.inactive = true
+	default:
+		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+	}
+}
+
+// sendElement writes an element to the stream, buffering it instead whenever client is inactive.
+func (s *inStream) sendElement(ctx context.Context, elem xmpp.XElement) {
+	if !s.inactive {
+		s.writeElement(ctx, elem)
+		return
+	}
+	for _, e := range s.csi.push(elem) {
+		s.writeElement(ctx, e)
+	}
+}
+
 // Runs on it's own goroutine
 func (s *inStream) doRead() {
 	s.scheduleReadTimeout()
@@ -739,6 +825,16 @@ func (s *inStream) writeStanzaErrorResponse(ctx context.Context, elem xmpp.XElem
 }
 
 func (s *inStream) writeElement(ctx context.Context, elem xmpp.XElement) {
+	if elem.Name() == "iq" && s.csi.len() > 0 {
+		// keep buffered traffic ordered ahead of any IQ
+		for _, e := range s.csi.flush() {
+			s.sendToSession(ctx, e)
+		}
+	}
+	s.sendToSession(ctx, elem)
+}
+
+func (s *inStream) sendToSession(ctx context.Context, elem xmpp.XElement) {
 	if err := s.sess.Send(ctx, elem); err != nil {
 		log.Error(err)
 	}
@@ -782,13 +878,15 @@ func (s *inStream) disconnectWithStreamError(ctx context.Context, err *streamerr
 
 func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession, unbind bool) {
 	// stop pinging...
-	if p := s.mods.Ping; p != nil {
+	if p := s.modules().Ping; p != nil {
 		p.CancelPing(s)
 	}
 	// send 'unavailable' presence when disconnecting
-	if presence := s.Presence(); presence != nil && presence.IsAvailable() {
-		if r := s.mods.Roster; r != nil {
+	if r := s.modules().Roster; r != nil {
+		if presence := s.Presence(); presence != nil && presence.IsAvailable() {
 			r.ProcessPresence(ctx, xmpp.NewPresence(s.JID(), s.JID().ToBareJID(), xmpp.UnavailableType))
+		} else if s.JID().IsFullWithUser() {
+			r.ReleaseDirectedPresences(ctx, s.JID())
 		}
 	}
 	if closeSession {
@@ -797,6 +895,11 @@ func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession, u
 	// unregister stream
 	if unbind {
 		s.router.Unbind(ctx, s.JID())
+
+		// notify app servers the user is no longer reachable
+		if p := s.modules().Push; p != nil && s.JID().IsFullWithUser() {
+			p.NotifyDetach(context.Background(), s.JID())
+		}
 	}
 	s.ctxCancelFn()
 
@@ -811,7 +914,7 @@ func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession, u
 }
 
 func (s *inStream) isBlockedJID(ctx context.Context, j *jid.JID) bool {
-	blockList, err := s.blockListRep.FetchBlockListItems(ctx, s.Username())
+	blockList, err := s.blockListRep.FetchBlockListItems(ctx, s.Username(), s.Domain())
 	if err != nil {
 		log.Error(err)
 		return false
@@ -914,3 +1017,8 @@ func (s *inStream) setState(state uint32) {
 func (s *inStream) getState() uint32 {
 	return atomic.LoadUint32(&s.state)
 }
+
+// modules returns the set of modules configured for the stream domain.
+func (s *inStream) modules() *module.Modules {
+	return s.mods.For(s.Domain())
+}
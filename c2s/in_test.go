@@ -76,7 +76,7 @@ func TestStream_Features(t *testing.T) {
 func TestStream_TLS(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -97,7 +97,7 @@ func TestStream_TLS(t *testing.T) {
 func TestStream_FailAuthenticate(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	_, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -128,10 +128,30 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	require.NotNil(t, elem.Elements().Child("error"))
 }
 
+func TestStream_AccountDisabled(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil", Status: model.UserBanned, StatusReason: "spam"})
+
+	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	_, _ = conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2ls</auth>`))
+
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("account-disabled"))
+	require.NotNil(t, elem.Elements().Child("text"))
+	require.Equal(t, "spam", elem.Elements().Child("text").Text())
+	require.False(t, stm.IsAuthenticated())
+}
+
 func TestStream_Compression(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -175,7 +195,7 @@ func TestStream_Compression(t *testing.T) {
 func TestStream_StartSession(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -197,7 +217,7 @@ func TestStream_StartSession(t *testing.T) {
 func TestStream_SendIQ(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -234,7 +254,7 @@ func TestStream_SendIQ(t *testing.T) {
 func TestStream_SendPresence(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -275,10 +295,53 @@ func TestStream_SendPresence(t *testing.T) {
 	require.NotNil(t, x.Elements().Child("x"))
 }
 
+func TestStream_SendDirectedPresence(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamBind(conn, t)
+
+	require.Equal(t, bound, stm.getState())
+
+	jTo, _ := jid.New("room", "localhost", "nick", true)
+
+	stm2 := stream.NewMockC2S("abcd7890", jTo)
+	stm2.SetPresence(xmpp.NewPresence(jTo, jTo, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm2)
+
+	_, _ = conn.inboundWrite([]byte(`<presence to="room@localhost/nick"/>`))
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+
+	// directed presence receivers should be notified on disconnection
+	stm.Disconnect(context.Background(), nil)
+	require.True(t, conn.waitClose())
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, "user@localhost/balcony", elem.From())
+}
+
 func TestStream_SendMessage(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -330,7 +393,7 @@ func TestStream_SendMessage(t *testing.T) {
 func TestStream_SendToBlockedJID(t *testing.T) {
 	r, userRep, blockListRep := setupTest("localhost")
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Password: "pencil"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
 	tUtilStreamOpen(conn)
@@ -350,6 +413,7 @@ func TestStream_SendToBlockedJID(t *testing.T) {
 
 	_ = blockListRep.InsertBlockListItem(context.Background(), &model.BlockListItem{
 		Username: "user",
+		Domain:   "localhost",
 		JID:      "hamlet@localhost",
 	})
 
@@ -421,6 +485,7 @@ func tUtilStreamInit(r router.Router, userRep repository.User, blockListRep repo
 func tUtilInStreamDefaultConfig() *streamConfig {
 	return &streamConfig{
 		connectTimeout:   time.Second,
+		timeout:          time.Second,
 		keepAlive:        time.Second,
 		maxStanzaSize:    8192,
 		resourceConflict: Reject,
@@ -437,3 +502,75 @@ func tUtilInitModules(r router.Router) *module.Modules {
 	repContainer, _ := storage.New(&storage.Config{Type: storage.Memory})
 	return module.New(&module.Config{Enabled: modules}, r, repContainer, "alloc-1234")
 }
+
+func TestStream_ClientStateIndication(t *testing.T) {
+	r, userRep, blockListRep := setupTest("localhost")
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamInit(r, userRep, blockListRep)
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("csi", csiNamespace))
+
+	tUtilStreamBind(conn, t)
+	tUtilStreamStartSession(conn, t)
+
+	require.Equal(t, bound, stm.getState())
+
+	_, _ = conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	jFrom, _ := jid.New("sxmpp", "localhost", "garden", true)
+	jTo, _ := jid.New("user", "localhost", "balcony", true)
+
+	// presence updates should be buffered and deduplicated
+	stm.SendElement(context.Background(), xmpp.NewPresence(jFrom, jTo, xmpp.AvailableType))
+	stm.SendElement(context.Background(), xmpp.NewPresence(jFrom, jTo, xmpp.UnavailableType))
+
+	msgID := uuid.New().String()
+	msg := xmpp.NewMessageType(msgID, xmpp.ChatType)
+	msg.SetFromJID(jFrom)
+	msg.SetToJID(jTo)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi buddy!")
+	msg.AppendElement(body)
+
+	stm.SendElement(context.Background(), msg)
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msgID, elem.ID())
+
+	// buffered elements are written ahead of any IQ
+	stm.SendElement(context.Background(), xmpp.NewPresence(jFrom, jTo, xmpp.UnavailableType))
+	_, _ = conn.inboundWrite([]byte(`<iq type="get" id="csi-1" to="user@localhost/unknown"><query xmlns="urn:xmpp:unknown"/></iq>`))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, "csi-1", elem.ID())
+
+	// becoming active flushes buffered elements
+	stm.SendElement(context.Background(), xmpp.NewPresence(jFrom, jTo, xmpp.AvailableType))
+	_, _ = conn.inboundWrite([]byte(`<active xmlns="urn:xmpp:csi:0"/>`))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/sxmpp/jackal/xmpp"
 )
 
+// resources holds all streams bound to a single user.
+// streams slice is never mutated in place, so any returned snapshot can be safely iterated without holding the lock.
 type resources struct {
 	mu      sync.RWMutex
 	streams []stream.C2S
@@ -53,7 +55,9 @@ func (r *resources) bind(stm stream.C2S) {
 			return
 		}
 	}
-	r.streams = append(r.streams, stm)
+	streams := make([]stream.C2S, len(r.streams), len(r.streams)+1)
+	copy(streams, r.streams)
+	r.streams = append(streams, stm)
 }
 
 func (r *resources) unbind(res string) {
@@ -64,15 +68,19 @@ func (r *resources) unbind(res string) {
 		if s.Resource() != res {
 			continue
 		}
-		r.streams = append(r.streams[:i], r.streams[i+1:]...)
+		streams := make([]stream.C2S, 0, len(r.streams)-1)
+		streams = append(streams, r.streams[:i]...)
+		r.streams = append(streams, r.streams[i+1:]...)
 		return
 	}
 }
 
 func (r *resources) route(ctx context.Context, stanza xmpp.Stanza) error {
+	streams := r.allStreams()
+
 	toJID := stanza.ToJID()
 	if toJID.IsFullWithUser() {
-		for _, stm := range r.streams {
+		for _, stm := range streams {
 			if p := stm.Presence(); p != nil && p.IsAvailable() && stm.Resource() == toJID.Resource() {
 				stm.SendElement(ctx, stanza)
 				return nil
@@ -86,7 +94,7 @@ func (r *resources) route(ctx context.Context, stanza xmpp.Stanza) error {
 		var highestPriority int8
 		var recipient stream.C2S
 
-		for _, stm := range r.streams {
+		for _, stm := range streams {
 			if p := stm.Presence(); p != nil && p.IsAvailable() && p.Priority() > highestPriority {
 				recipient = stm
 				highestPriority = p.Priority()
@@ -101,7 +109,10 @@ func (r *resources) route(ctx context.Context, stanza xmpp.Stanza) error {
 
 broadcast:
 	// broadcast toJID all streams
-	for _, stm := range r.streams {
+	if len(streams) > 1 {
+		stanza = xmpp.NewPreparedStanza(stanza).Stanza(toJID) // render stanza just once
+	}
+	for _, stm := range streams {
 		if p := stm.Presence(); p != nil && p.IsAvailable() {
 			stm.SendElement(ctx, stanza)
 		}
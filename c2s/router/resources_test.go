@@ -37,6 +37,26 @@ func TestResources_Binding(t *testing.T) {
 	require.Len(t, res.allStreams(), 0)
 }
 
+func TestResources_AllStreamsSnapshot(t *testing.T) {
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/yard", true)
+	j2, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+
+	res := resources{}
+	res.bind(stream.NewMockC2S("id-1", j1))
+	res.bind(stream.NewMockC2S("id-2", j2))
+
+	snapshot := res.allStreams()
+
+	res.unbind("yard")
+	res.bind(stream.NewMockC2S("id-3", j1))
+
+	// previously returned snapshot should remain untouched
+	require.Len(t, snapshot, 2)
+	require.Equal(t, "yard", snapshot[0].Resource())
+	require.Equal(t, "id-1", snapshot[0].ID())
+	require.Equal(t, "balcony", snapshot[1].Resource())
+}
+
 func TestResources_Route(t *testing.T) {
 	j1, _ := jid.NewWithString("sxmpp@jackal.im/yard", true)
 	j2, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
@@ -17,19 +17,28 @@ import (
 	"github.com/sxmpp/jackal/xmpp/jid"
 )
 
+const shardCount = 64 // must be a power of two
+
+type shard struct {
+	mu  sync.RWMutex
+	tbl map[string]*resources
+}
+
 type c2sRouter struct {
-	mu           sync.RWMutex
-	tbl          map[string]*resources
+	shards       [shardCount]*shard
 	userRep      repository.User
 	blockListRep repository.BlockList
 }
 
 func New(userRep repository.User, blockListRep repository.BlockList) router.C2SRouter {
-	return &c2sRouter{
-		tbl:          make(map[string]*resources),
+	r := &c2sRouter{
 		userRep:      userRep,
 		blockListRep: blockListRep,
 	}
+	for i := 0; i < shardCount; i++ {
+		r.shards[i] = &shard{tbl: make(map[string]*resources)}
+	}
+	return r
 }
 
 func (r *c2sRouter) Route(ctx context.Context, stanza xmpp.Stanza, validateStanza bool) error {
@@ -37,16 +46,14 @@ func (r *c2sRouter) Route(ctx context.Context, stanza xmpp.Stanza, validateStanz
 	toJID := stanza.ToJID()
 
 	// validate if sender JID is blocked
-	if validateStanza && r.isBlockedJID(ctx, toJID, fromJID.Node()) {
+	if validateStanza && r.isBlockedJID(ctx, toJID, fromJID.Node(), fromJID.Domain()) {
 		return router.ErrBlockedJID
 	}
-	username := stanza.ToJID().Node()
-	r.mu.RLock()
-	rs := r.tbl[username]
-	r.mu.RUnlock()
-
+	username := toJID.Node()
+	domain := toJID.Domain()
+	rs := r.resources(username, domain)
 	if rs == nil {
-		exists, err := r.userRep.UserExists(ctx, username)
+		exists, err := r.userRep.UserExists(ctx, username, domain)
 		if err != nil {
 			return err
 		}
@@ -59,67 +66,87 @@ func (r *c2sRouter) Route(ctx context.Context, stanza xmpp.Stanza, validateStanz
 }
 
 func (r *c2sRouter) Bind(stm stream.C2S) {
-	user := stm.Username()
-	r.mu.RLock()
-	rs := r.tbl[user]
-	r.mu.RUnlock()
+	user := userKey(stm.Username(), stm.Domain())
+	sh := r.shardFor(user)
 
+	sh.mu.Lock()
+	rs := sh.tbl[user]
 	if rs == nil {
-		r.mu.Lock()
-		rs = r.tbl[user] // avoid double initialization
-		if rs == nil {
-			rs = &resources{}
-			r.tbl[user] = rs
-		}
-		r.mu.Unlock()
+		rs = &resources{}
+		sh.tbl[user] = rs
 	}
 	rs.bind(stm)
+	sh.mu.Unlock()
 
-	log.Infof("bound c2s stream... (%s/%s)", stm.Username(), stm.Resource())
+	log.Infof("bound c2s stream... (%s/%s)", user, stm.Resource())
 }
 
-func (r *c2sRouter) Unbind(user, resource string) {
-	r.mu.RLock()
-	rs := r.tbl[user]
-	r.mu.RUnlock()
+func (r *c2sRouter) Unbind(username, domain, resource string) {
+	user := userKey(username, domain)
+	sh := r.shardFor(user)
 
+	sh.mu.Lock()
+	rs := sh.tbl[user]
 	if rs == nil {
+		sh.mu.Unlock()
 		return
 	}
-	r.mu.Lock()
 	rs.unbind(resource)
 	if rs.len() == 0 {
-		delete(r.tbl, user)
+		delete(sh.tbl, user)
 	}
-	r.mu.Unlock()
+	sh.mu.Unlock()
 
 	log.Infof("unbound c2s stream... (%s/%s)", user, resource)
 }
 
-func (r *c2sRouter) Stream(username, resource string) stream.C2S {
-	r.mu.RLock()
-	rs := r.tbl[username]
-	r.mu.RUnlock()
-
+func (r *c2sRouter) Stream(username, domain, resource string) stream.C2S {
+	rs := r.resources(username, domain)
 	if rs == nil {
 		return nil
 	}
 	return rs.stream(resource)
 }
 
-func (r *c2sRouter) Streams(username string) []stream.C2S {
-	r.mu.RLock()
-	rs := r.tbl[username]
-	r.mu.RUnlock()
-
+func (r *c2sRouter) Streams(username, domain string) []stream.C2S {
+	rs := r.resources(username, domain)
 	if rs == nil {
 		return nil
 	}
 	return rs.allStreams()
 }
 
-func (r *c2sRouter) isBlockedJID(ctx context.Context, j *jid.JID, username string) bool {
-	blockList, err := r.blockListRep.FetchBlockListItems(ctx, username)
+func (r *c2sRouter) DomainStreams(domain string) []stream.C2S {
+	var stms []stream.C2S
+	for _, sh := range r.shards {
+		sh.mu.RLock()
+		for _, rs := range sh.tbl {
+			for _, stm := range rs.allStreams() {
+				if stm.JID().Domain() == domain {
+					stms = append(stms, stm)
+				}
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return stms
+}
+
+func (r *c2sRouter) resources(username, domain string) *resources {
+	user := userKey(username, domain)
+	sh := r.shardFor(user)
+	sh.mu.RLock()
+	rs := sh.tbl[user]
+	sh.mu.RUnlock()
+	return rs
+}
+
+func (r *c2sRouter) shardFor(user string) *shard {
+	return r.shards[fnv32(user)&(shardCount-1)]
+}
+
+func (r *c2sRouter) isBlockedJID(ctx context.Context, j *jid.JID, username, domain string) bool {
+	blockList, err := r.blockListRep.FetchBlockListItems(ctx, username, domain)
 	if err != nil {
 		log.Error(err)
 		return false
@@ -139,3 +166,21 @@ func (r *c2sRouter) isBlockedJID(ctx context.Context, j *jid.JID, username strin
 	}
 	return false
 }
+
+// fnv32 returns the 32-bit FNV-1a hash of a string avoiding any allocation.
+func fnv32(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+	return h
+}
+
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
@@ -7,6 +7,8 @@ package c2srouter
 
 import (
 	"context"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/sxmpp/jackal/model"
@@ -33,19 +35,59 @@ func TestRouter_Binding(t *testing.T) {
 	stm1.SetPresence(xmpp.NewPresence(j1.ToBareJID(), j1, xmpp.AvailableType))
 	stm2.SetPresence(xmpp.NewPresence(j2.ToBareJID(), j2, xmpp.AvailableType))
 
-	require.Len(t, r.Streams("sxmpp"), 2)
+	require.Len(t, r.Streams("sxmpp", "jackal.im"), 2)
 
-	require.NotNil(t, r.Stream("sxmpp", "yard"))
-	require.NotNil(t, r.Stream("sxmpp", "balcony"))
+	require.NotNil(t, r.Stream("sxmpp", "jackal.im", "yard"))
+	require.NotNil(t, r.Stream("sxmpp", "jackal.im", "balcony"))
 
-	r.Unbind("sxmpp", "yard")
-	r.Unbind("sxmpp", "balcony")
+	r.Unbind("sxmpp", "jackal.im", "yard")
+	r.Unbind("sxmpp", "jackal.im", "balcony")
 
-	require.Len(t, r.Streams("sxmpp"), 0)
+	require.Len(t, r.Streams("sxmpp", "jackal.im"), 0)
 
-	r.(*c2sRouter).mu.RLock()
-	require.Len(t, r.(*c2sRouter).tbl, 0)
-	r.(*c2sRouter).mu.RUnlock()
+	for _, sh := range r.(*c2sRouter).shards {
+		sh.mu.RLock()
+		require.Len(t, sh.tbl, 0)
+		sh.mu.RUnlock()
+	}
+}
+
+func TestRouter_BindingAcrossDomains(t *testing.T) {
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/yard", true)
+	j2, _ := jid.NewWithString("sxmpp@example.org/yard", true)
+
+	stm1 := stream.NewMockC2S("id-1", j1)
+	stm2 := stream.NewMockC2S("id-2", j2)
+
+	r, _, _ := setupTest()
+
+	r.Bind(stm1)
+	r.Bind(stm2)
+
+	require.Len(t, r.Streams("sxmpp", "jackal.im"), 1)
+	require.Len(t, r.Streams("sxmpp", "example.org"), 1)
+	require.Equal(t, stm2, r.Stream("sxmpp", "example.org", "yard"))
+
+	r.Unbind("sxmpp", "jackal.im", "yard")
+
+	require.Nil(t, r.Stream("sxmpp", "jackal.im", "yard"))
+	require.Equal(t, stm2, r.Stream("sxmpp", "example.org", "yard"))
+}
+
+func TestRouter_DomainStreams(t *testing.T) {
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/yard", true)
+	j2, _ := jid.NewWithString("romeo@example.org/balcony", true)
+
+	r, _, _ := setupTest()
+
+	r.Bind(stream.NewMockC2S("id-1", j1))
+	r.Bind(stream.NewMockC2S("id-2", j2))
+
+	stms := r.DomainStreams("example.org")
+	require.Len(t, stms, 1)
+	require.Equal(t, "romeo", stms[0].Username())
+
+	require.Len(t, r.DomainStreams("example.net"), 0)
 }
 
 func TestRouter_Routing(t *testing.T) {
@@ -59,8 +101,8 @@ func TestRouter_Routing(t *testing.T) {
 	err := r.Route(context.Background(), xmpp.NewPresence(j1, j1, xmpp.AvailableType), true)
 	require.Equal(t, router.ErrNotExistingAccount, err)
 
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "sxmpp"})
-	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "romeo"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im"})
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "romeo", Domain: "jackal.im"})
 
 	err = r.Route(context.Background(), xmpp.NewPresence(j1, j1, xmpp.AvailableType), true)
 	require.Equal(t, router.ErrNotAuthenticated, err)
@@ -77,6 +119,7 @@ func TestRouter_Routing(t *testing.T) {
 
 	_ = blockListRep.InsertBlockListItem(context.Background(), &model.BlockListItem{
 		Username: "sxmpp",
+		Domain:   "jackal.im",
 		JID:      "jackal.im/deadlyresource",
 	})
 
@@ -84,6 +127,74 @@ func TestRouter_Routing(t *testing.T) {
 	require.Equal(t, router.ErrBlockedJID, err)
 }
 
+func BenchmarkRouter_Bind(b *testing.B) {
+	r, _, _ := setupTest()
+
+	var n int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			r.Bind(&benchC2S{username: "user" + strconv.FormatInt(i, 10), resource: "yard"})
+		}
+	})
+}
+
+func BenchmarkRouter_Unbind(b *testing.B) {
+	r, _, _ := setupTest()
+	for i := 0; i < b.N; i++ {
+		r.Bind(&benchC2S{username: "user" + strconv.Itoa(i), resource: "yard"})
+	}
+	var n int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1) - 1
+			r.Unbind("user"+strconv.FormatInt(i, 10), "jackal.im", "yard")
+		}
+	})
+}
+
+func BenchmarkRouter_Route(b *testing.B) {
+	const userCount = 1024
+
+	r, userRep, _ := setupTest()
+
+	var messages []*xmpp.Message
+	for i := 0; i < userCount; i++ {
+		username := "user" + strconv.Itoa(i)
+		_ = userRep.UpsertUser(context.Background(), &model.User{Username: username, Domain: "jackal.im"})
+
+		j, _ := jid.New(username, "jackal.im", "yard", true)
+		stm := stream.NewMockC2S("id", j)
+		stm.SetPresence(xmpp.NewPresence(j.ToBareJID(), j, xmpp.AvailableType))
+		r.Bind(stm)
+
+		msg := xmpp.NewMessageType("id", xmpp.ChatType)
+		msg.SetFromJID(j)
+		msg.SetToJID(j)
+		messages = append(messages, msg)
+	}
+	var n int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			_ = r.Route(context.Background(), messages[i%userCount], false)
+		}
+	})
+}
+
+// benchC2S is a lightweight stream used to avoid mocked stream goroutines while benchmarking.
+type benchC2S struct {
+	stream.C2S
+	username string
+	resource string
+}
+
+func (s *benchC2S) Username() string { return s.username }
+func (s *benchC2S) Resource() string { return s.resource }
+
 func setupTest() (router.C2SRouter, repository.User, repository.BlockList) {
 	userRep := memorystorage.NewUser()
 	blockListRep := memorystorage.NewBlockList()
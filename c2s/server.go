@@ -115,6 +115,7 @@ func (s *server) startStream(tr transport.Transport, keepAlive time.Duration) {
 		maxStanzaSize:    s.cfg.MaxStanzaSize,
 		sasl:             s.cfg.SASL,
 		compression:      s.cfg.Compression,
+		csi:              s.cfg.CSI,
 		onDisconnect:     s.unregisterStream,
 	}
 	stm := newStream(s.nextID(), cfg, tr, s.mods, s.comps, s.router, s.userRep, s.blockListRep)
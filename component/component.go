@@ -7,40 +7,69 @@ package component
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/sxmpp/jackal/component/external"
+	"github.com/sxmpp/jackal/component/httpupload"
+	"github.com/sxmpp/jackal/component/muc"
 	"github.com/sxmpp/jackal/log"
 	"github.com/sxmpp/jackal/module/xep0030"
-	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
 	"github.com/sxmpp/jackal/xmpp"
 )
 
+// ErrComponentNotFound will be returned by Route in case no component serves stanza destination domain.
+var ErrComponentNotFound = errors.New("component: component not found")
+
 // Component represents a generic component interface.
 type Component interface {
+	// Host returns the domain served by the component.
 	Host() string
-	ProcessStanza(ctx context.Context, stanza xmpp.Stanza, stm stream.C2S)
+
+	// ProcessStanza processes a stanza addressed to the component domain.
+	ProcessStanza(ctx context.Context, stanza xmpp.Stanza)
+
+	// Shutdown shuts down component.
+	Shutdown() error
 }
 
 // Components represents a set of preconfigured components.
 type Components struct {
-	comps       map[string]Component
-	shutdownChs []chan<- chan bool
+	comps     map[string]Component
+	all       []Component
+	external  *external.Server
+	discoInfo *xep0030.DiscoInfo
 }
 
 // New returns a set of components derived from a concrete configuration.
-func New(config *Config, discoInfo *xep0030.DiscoInfo) *Components {
+func New(config *Config, router router.Router, reps repository.Container, discoInfo *xep0030.DiscoInfo) *Components {
 	comps := &Components{
-		comps: make(map[string]Component),
+		comps:     make(map[string]Component),
+		discoInfo: discoInfo,
 	}
-	cs, shutdownChs := loadComponents(config, discoInfo)
-	for _, c := range cs {
+	for _, c := range loadComponents(config, router, reps, discoInfo) {
 		host := c.Host()
 		if _, ok := comps.comps[host]; ok {
 			log.Fatal(fmt.Errorf("component host name conflict: %s", host))
 		}
 		comps.comps[host] = c
+		comps.all = append(comps.all, c)
+	}
+	// XEP-0114: Jabber Component Protocol (https://xmpp.org/extensions/xep-0114.html)
+	if config.External != nil {
+		for _, extComp := range config.External.Components {
+			if _, ok := comps.comps[extComp.Host]; ok {
+				log.Fatal(fmt.Errorf("component host name conflict: %s", extComp.Host))
+			}
+		}
+		ext, err := external.New(config.External, discoInfo, router)
+		if err != nil {
+			log.Fatal(err)
+		}
+		comps.external = ext
 	}
-	comps.shutdownChs = shutdownChs
 	return comps
 }
 
@@ -58,6 +87,32 @@ func (cs *Components) GetAll() []Component {
 	return ret
 }
 
+// IsComponentHost returns whether or not a domain is served by a component.
+func (cs *Components) IsComponentHost(domain string) bool {
+	if _, ok := cs.comps[domain]; ok {
+		return true
+	}
+	return cs.external != nil && cs.external.IsComponentHost(domain)
+}
+
+// Route delivers a stanza to the component serving its destination domain.
+// Service discovery requests are answered on behalf of the component.
+func (cs *Components) Route(ctx context.Context, stanza xmpp.Stanza) error {
+	comp := cs.comps[stanza.ToJID().Domain()]
+	if comp == nil {
+		if cs.external != nil && cs.external.IsComponentHost(stanza.ToJID().Domain()) {
+			return cs.external.Route(ctx, stanza) // external components answer their own disco requests
+		}
+		return ErrComponentNotFound
+	}
+	if iq, ok := stanza.(*xmpp.IQ); ok && cs.discoInfo != nil && cs.discoInfo.MatchesIQ(iq) {
+		cs.discoInfo.ProcessIQ(ctx, iq)
+		return nil
+	}
+	comp.ProcessStanza(ctx, stanza)
+	return nil
+}
+
 // Shutdown gracefully shuts down components instance.
 func (cs *Components) Shutdown(ctx context.Context) error {
 	select {
@@ -71,28 +126,36 @@ func (cs *Components) Shutdown(ctx context.Context) error {
 func (cs *Components) shutdown() <-chan bool {
 	c := make(chan bool)
 	go func() {
+		if cs.external != nil {
+			if err := cs.external.Shutdown(context.Background()); err != nil {
+				log.Error(err)
+			}
+		}
 		// shutdown components in reverse order
-		for i := len(cs.shutdownChs) - 1; i >= 0; i-- {
-			shutdownCh := cs.shutdownChs[i]
-			wc := make(chan bool, 1)
-			shutdownCh <- wc
-			<-wc
+		for i := len(cs.all) - 1; i >= 0; i-- {
+			if err := cs.all[i].Shutdown(); err != nil {
+				log.Error(err)
+			}
 		}
 		close(c)
 	}()
 	return c
 }
 
-func loadComponents(_ *Config, _ *xep0030.DiscoInfo) ([]Component, []chan<- chan bool) {
+func loadComponents(cfg *Config, router router.Router, reps repository.Container, discoInfo *xep0030.DiscoInfo) []Component {
 	var comps []Component
-	var shutdownChs []chan<- chan bool
-	/*
-		discoInfo := module.Modules().DiscoInfo
-		if cfg.HttpUpload != nil {
-			comp, shutdownCh := httpupload.New(cfg.HttpUpload, discoInfo)
-			comps = append(comps, comp)
-			shutdownChs = append(shutdownChs, shutdownCh)
+
+	// XEP-0045: Multi-User Chat (https://xmpp.org/extensions/xep-0045.html)
+	if cfg.Muc != nil {
+		comps = append(comps, muc.New(cfg.Muc, discoInfo, router, reps.Muc()))
+	}
+	// XEP-0363: HTTP File Upload (https://xmpp.org/extensions/xep-0363.html)
+	if cfg.HttpUpload != nil {
+		upload, err := httpupload.New(cfg.HttpUpload, discoInfo, router)
+		if err != nil {
+			log.Fatal(err)
 		}
-	*/
-	return comps, shutdownChs
+		comps = append(comps, upload)
+	}
+	return comps
 }
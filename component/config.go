@@ -5,7 +5,15 @@
 
 package component
 
+import (
+	"github.com/sxmpp/jackal/component/external"
+	"github.com/sxmpp/jackal/component/httpupload"
+	"github.com/sxmpp/jackal/component/muc"
+)
+
 // Config contains all components configuration.
 type Config struct {
-	// HttpUpload *httpupload.Config `yaml:"http_upload"`
+	Muc        *muc.Config        `yaml:"muc"`
+	HttpUpload *httpupload.Config `yaml:"http_upload"`
+	External   *external.Config   `yaml:"external"`
 }
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultBindAddr       = "0.0.0.0"
+	defaultPort           = 5275
+	defaultConnectTimeout = time.Duration(5) * time.Second
+	defaultKeepAlive      = time.Duration(10) * time.Minute
+	defaultTimeout        = time.Duration(20) * time.Second
+	defaultMaxStanzaSize  = 131072
+)
+
+// ComponentConfig represents an external component allowed to connect.
+type ComponentConfig struct {
+	// Host is the domain served by the external component (eg. irc.jackal.im).
+	Host string `yaml:"host"`
+
+	// Name is the component name advertised through service discovery.
+	Name string `yaml:"name"`
+
+	// Secret is the shared secret used to authenticate component handshake.
+	Secret string `yaml:"secret"`
+}
+
+// Config represents external component listener (XEP-0114) configuration.
+type Config struct {
+	BindAddr       string
+	Port           int
+	ConnectTimeout time.Duration
+	KeepAlive      time.Duration
+	Timeout        time.Duration
+	MaxStanzaSize  int
+	Components     []ComponentConfig
+}
+
+type configProxy struct {
+	BindAddr       string            `yaml:"bind_addr"`
+	Port           int               `yaml:"port"`
+	ConnectTimeout int               `yaml:"connect_timeout"`
+	KeepAlive      int               `yaml:"keep_alive"`
+	Timeout        int               `yaml:"timeout"`
+	MaxStanzaSize  int               `yaml:"max_stanza_size"`
+	Components     []ComponentConfig `yaml:"components"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Components) == 0 {
+		return errors.New("external.Config: at least one component must be specified")
+	}
+	hosts := make(map[string]struct{})
+	for _, comp := range p.Components {
+		if len(comp.Host) == 0 {
+			return errors.New("external.Config: component host value must be set")
+		}
+		if len(comp.Secret) == 0 {
+			return fmt.Errorf("external.Config: secret value must be set for component %s", comp.Host)
+		}
+		if _, ok := hosts[comp.Host]; ok {
+			return fmt.Errorf("external.Config: duplicated component host: %s", comp.Host)
+		}
+		hosts[comp.Host] = struct{}{}
+	}
+	cfg.Components = p.Components
+	cfg.BindAddr = p.BindAddr
+	if len(cfg.BindAddr) == 0 {
+		cfg.BindAddr = defaultBindAddr
+	}
+	cfg.Port = p.Port
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	cfg.ConnectTimeout = time.Duration(p.ConnectTimeout) * time.Second
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = defaultConnectTimeout
+	}
+	cfg.KeepAlive = time.Duration(p.KeepAlive) * time.Second
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	cfg.Timeout = time.Duration(p.Timeout) * time.Second
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.MaxStanzaSize = p.MaxStanzaSize
+	if cfg.MaxStanzaSize == 0 {
+		cfg.MaxStanzaSize = defaultMaxStanzaSize
+	}
+	return nil
+}
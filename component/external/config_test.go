@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExternalConfig(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte("port: 5275"), &cfg)
+	require.NotNil(t, err) // missing components
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("components:\n  - secret: s3cr3t"), &cfg)
+	require.NotNil(t, err) // missing host
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("components:\n  - host: irc.jackal.im"), &cfg)
+	require.NotNil(t, err) // missing secret
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`
+components:
+  - host: irc.jackal.im
+    secret: s3cr3t
+  - host: irc.jackal.im
+    secret: s3cr3t
+`), &cfg)
+	require.NotNil(t, err) // duplicated host
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("components:\n  - host: irc.jackal.im\n    secret: s3cr3t"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultBindAddr, cfg.BindAddr)
+	require.Equal(t, defaultPort, cfg.Port)
+	require.Equal(t, defaultConnectTimeout, cfg.ConnectTimeout)
+	require.Equal(t, defaultKeepAlive, cfg.KeepAlive)
+	require.Equal(t, defaultTimeout, cfg.Timeout)
+	require.Equal(t, defaultMaxStanzaSize, cfg.MaxStanzaSize)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`
+bind_addr: 127.0.0.1
+port: 5347
+connect_timeout: 3
+keep_alive: 120
+timeout: 10
+max_stanza_size: 65536
+components:
+  - host: irc.jackal.im
+    name: IRC gateway
+    secret: s3cr3t
+  - host: bot.jackal.im
+    secret: b0t
+`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "127.0.0.1", cfg.BindAddr)
+	require.Equal(t, 5347, cfg.Port)
+	require.Equal(t, time.Second*3, cfg.ConnectTimeout)
+	require.Equal(t, time.Minute*2, cfg.KeepAlive)
+	require.Equal(t, time.Second*10, cfg.Timeout)
+	require.Equal(t, 65536, cfg.MaxStanzaSize)
+	require.Len(t, cfg.Components, 2)
+	require.Equal(t, ComponentConfig{Host: "irc.jackal.im", Name: "IRC gateway", Secret: "s3cr3t"}, cfg.Components[0])
+}
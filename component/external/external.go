@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	streamerror "github.com/sxmpp/jackal/errors"
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/transport"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// ErrComponentNotConnected will be returned by Route in case destination component is not currently connected.
+var ErrComponentNotConnected = errors.New("external: component not connected")
+
+var listenerProvider = net.Listen
+
+// Server represents an external component (XEP-0114) listener.
+type Server struct {
+	cfg       *Config
+	inCfg     *inConfig
+	disco     *xep0030.DiscoInfo
+	router    router.Router
+	ln        net.Listener
+	listening uint32
+	mu        sync.RWMutex
+	streams   map[string]*inStream // connected streams by component host
+	inStreams map[string]*inStream // every accepted stream by id
+}
+
+// New returns an external component listener accepting connections from all configured components.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router) (*Server, error) {
+	s := &Server{
+		cfg:       config,
+		disco:     disco,
+		router:    router,
+		streams:   make(map[string]*inStream),
+		inStreams: make(map[string]*inStream),
+	}
+	s.inCfg = &inConfig{
+		components:     make(map[string]ComponentConfig),
+		connectTimeout: config.ConnectTimeout,
+		keepAlive:      config.KeepAlive,
+		timeout:        config.Timeout,
+		maxStanzaSize:  config.MaxStanzaSize,
+		onConnect:      s.registerComponent,
+		onDisconnect:   s.unregisterComponent,
+	}
+	for _, comp := range config.Components {
+		s.inCfg.components[comp.Host] = comp
+	}
+	address := config.BindAddr + ":" + strconv.Itoa(config.Port)
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s.ln = ln
+	atomic.StoreUint32(&s.listening, 1)
+
+	go s.accept()
+
+	log.Infof("external: listening at %s", ln.Addr())
+	return s, nil
+}
+
+// IsComponentHost returns whether or not a domain belongs to a configured external component.
+func (s *Server) IsComponentHost(domain string) bool {
+	_, ok := s.inCfg.components[domain]
+	return ok
+}
+
+// IsConnected returns whether or not the component serving a domain is currently connected.
+func (s *Server) IsConnected(domain string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.streams[domain]
+	return ok
+}
+
+// Route delivers a stanza to the external component serving its destination domain.
+// In case the component is not connected an error reply will be sent back to the stanza sender.
+func (s *Server) Route(ctx context.Context, stanza xmpp.Stanza) error {
+	s.mu.RLock()
+	stm := s.streams[stanza.ToJID().Domain()]
+	s.mu.RUnlock()
+
+	if stm == nil {
+		if stanza.Type() != xmpp.ErrorType && stanza.Type() != xmpp.ResultType {
+			_ = s.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(stanza, xmpp.ErrServiceUnavailable, nil))
+		}
+		return ErrComponentNotConnected
+	}
+	stm.SendElement(ctx, stanza)
+	return nil
+}
+
+// Shutdown stops listening and closes every external component connection.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapUint32(&s.listening, 1, 0) {
+		return nil
+	}
+	// stop listening...
+	if err := s.ln.Close(); err != nil {
+		return err
+	}
+	// close all connections...
+	s.mu.RLock()
+	var stms []*inStream
+	for _, stm := range s.inStreams {
+		stms = append(stms, stm)
+	}
+	s.mu.RUnlock()
+
+	for _, stm := range stms {
+		stm.Disconnect(ctx, streamerror.ErrSystemShutdown)
+	}
+	log.Infof("external: closed %d in connection(s)", len(stms))
+	return nil
+}
+
+func (s *Server) accept() {
+	for atomic.LoadUint32(&s.listening) == 1 {
+		conn, err := s.ln.Accept()
+		if err == nil {
+			go s.startInStream(transport.NewSocketTransport(conn))
+			continue
+		}
+	}
+}
+
+func (s *Server) startInStream(tr transport.Transport) {
+	stm := newInStream(s.inCfg, tr, s.router)
+
+	s.mu.Lock()
+	s.inStreams[stm.ID()] = stm
+	s.mu.Unlock()
+}
+
+func (s *Server) registerComponent(stm *inStream) {
+	host := stm.Host()
+
+	s.mu.Lock()
+	prevStm := s.streams[host]
+	s.streams[host] = stm
+	s.mu.Unlock()
+
+	if prevStm != nil {
+		// component reconnected... close former connection
+		prevStm.Disconnect(context.Background(), streamerror.ErrConflict)
+	} else if s.disco != nil {
+		s.disco.RegisterServerItem(xep0030.Item{Jid: host, Name: stm.comp.Name})
+	}
+	log.Infof("external: registered component %s... (id: %s)", host, stm.ID())
+}
+
+func (s *Server) unregisterComponent(stm *inStream) {
+	host := stm.Host()
+
+	s.mu.Lock()
+	delete(s.inStreams, stm.ID())
+	if len(host) == 0 || s.streams[host] != stm {
+		s.mu.Unlock()
+		return // not authenticated or already replaced by a newer connection
+	}
+	delete(s.streams, host)
+	s.mu.Unlock()
+
+	if s.disco != nil {
+		s.disco.UnregisterServerItem(xep0030.Item{Jid: host, Name: stm.comp.Name})
+	}
+	log.Infof("external: unregistered component %s... (id: %s)", host, stm.ID())
+}
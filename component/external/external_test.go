@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testComponentConn struct {
+	conn     net.Conn
+	pr       *xmpp.Parser
+	streamID string
+}
+
+func TestExternal_Handshake(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	s, addr := newTestServer(t, r, nil)
+	defer func() { _ = s.Shutdown(context.Background()) }()
+
+	// unknown component host
+	c := dialComponent(t, addr, "unknown.jackal.im")
+	require.Equal(t, "host-unknown", readStreamError(t, c))
+
+	// bad secret
+	c = dialComponent(t, addr, "irc.jackal.im")
+	writeString(t, c, "<handshake>"+handshakeDigest(c.streamID, "wrong")+"</handshake>")
+	require.Equal(t, "not-authorized", readStreamError(t, c))
+	require.False(t, s.IsConnected("irc.jackal.im"))
+
+	// valid handshake
+	c = dialComponent(t, addr, "irc.jackal.im")
+	authenticate(t, c, "s3cr3t")
+	require.True(t, waitFor(func() bool { return s.IsConnected("irc.jackal.im") }))
+}
+
+func TestExternal_Routing(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	s, addr := newTestServer(t, r, nil)
+	defer func() { _ = s.Shutdown(context.Background()) }()
+
+	compJID, _ := jid.New("", "irc.jackal.im", "", true)
+
+	// component not connected
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(compJID)
+	require.Equal(t, ErrComponentNotConnected, s.Route(context.Background(), iq))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrServiceUnavailable.Error(), elem.Error().Elements().All()[0].Name())
+
+	c := dialComponent(t, addr, "irc.jackal.im")
+	authenticate(t, c, "s3cr3t")
+	require.True(t, waitFor(func() bool { return s.IsConnected("irc.jackal.im") }))
+
+	// deliver to component
+	msgTo, _ := jid.New("channel", "irc.jackal.im", "", true)
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j)
+	msg.SetToJID(msgTo)
+	require.Nil(t, s.Route(context.Background(), msg))
+
+	elem = readElement(t, c)
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "ortuman@jackal.im/balcony", elem.From())
+
+	// deliver from component
+	writeString(t, c, `<message id="m1" to="ortuman@jackal.im/balcony" type="chat"><body>hi</body></message>`)
+	elem = stm.ReceiveElement()
+	require.Equal(t, "m1", elem.ID())
+	require.Equal(t, "irc.jackal.im", elem.From())
+
+	writeString(t, c, `<message id="m2" from="channel@irc.jackal.im/nick" to="ortuman@jackal.im/balcony" type="chat"><body>hi</body></message>`)
+	elem = stm.ReceiveElement()
+	require.Equal(t, "m2", elem.ID())
+	require.Equal(t, "channel@irc.jackal.im/nick", elem.From())
+
+	// spoofed 'from' address
+	writeString(t, c, `<message id="m3" from="noelia@jackal.im" to="ortuman@jackal.im/balcony" type="chat"><body>hi</body></message>`)
+	require.Equal(t, "invalid-from", readStreamError(t, c))
+	require.True(t, waitFor(func() bool { return !s.IsConnected("irc.jackal.im") }))
+}
+
+func TestExternal_Reconnect(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	disco := xep0030.New(r, memorystorage.NewRoster())
+	defer func() { _ = disco.Shutdown() }()
+
+	s, addr := newTestServer(t, r, disco)
+	defer func() { _ = s.Shutdown(context.Background()) }()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	c1 := dialComponent(t, addr, "irc.jackal.im")
+	authenticate(t, c1, "s3cr3t")
+	require.True(t, waitFor(func() bool { return s.IsConnected("irc.jackal.im") }))
+	require.True(t, waitFor(func() bool { return hasDiscoItem(disco, stm, "irc.jackal.im") }))
+
+	// component reconnects... former connection is replaced
+	c2 := dialComponent(t, addr, "irc.jackal.im")
+	authenticate(t, c2, "s3cr3t")
+	require.Equal(t, "conflict", readStreamError(t, c1))
+	require.True(t, s.IsConnected("irc.jackal.im"))
+	require.True(t, hasDiscoItem(disco, stm, "irc.jackal.im"))
+
+	// component goes away
+	_ = c2.conn.Close()
+	require.True(t, waitFor(func() bool { return !s.IsConnected("irc.jackal.im") }))
+	require.True(t, waitFor(func() bool { return !hasDiscoItem(disco, stm, "irc.jackal.im") }))
+}
+
+func newTestServer(t *testing.T, r router.Router, disco *xep0030.DiscoInfo) (*Server, string) {
+	s, err := New(&Config{
+		BindAddr:       "127.0.0.1",
+		ConnectTimeout: time.Second,
+		KeepAlive:      time.Minute,
+		Timeout:        time.Second,
+		MaxStanzaSize:  defaultMaxStanzaSize,
+		Components: []ComponentConfig{
+			{Host: "irc.jackal.im", Name: "IRC gateway", Secret: "s3cr3t"},
+		},
+	}, disco, r)
+	require.Nil(t, err)
+	return s, s.ln.Addr().String()
+}
+
+func dialComponent(t *testing.T, addr, domain string) *testComponentConn {
+	conn, err := net.Dial("tcp", addr)
+	require.Nil(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	c := &testComponentConn{conn: conn, pr: xmpp.NewParser(conn, xmpp.SocketStream, 0)}
+	writeString(t, c, fmt.Sprintf(`<stream:stream xmlns="jabber:component:accept" xmlns:stream="http://etherx.jabber.org/streams" to="%s">`, domain))
+
+	open := readElement(t, c)
+	require.Equal(t, "stream:stream", open.Name())
+	require.Equal(t, "jabber:component:accept", open.Namespace())
+	c.streamID = open.ID()
+	return c
+}
+
+func authenticate(t *testing.T, c *testComponentConn, secret string) {
+	writeString(t, c, "<handshake>"+handshakeDigest(c.streamID, secret)+"</handshake>")
+	elem := readElement(t, c)
+	require.Equal(t, "handshake", elem.Name())
+	require.Equal(t, "", elem.Text())
+}
+
+func readElement(t *testing.T, c *testComponentConn) xmpp.XElement {
+	for {
+		elem, err := c.pr.ParseElement()
+		require.Nil(t, err)
+		if elem != nil {
+			return elem
+		}
+	}
+}
+
+func readStreamError(t *testing.T, c *testComponentConn) string {
+	elem := readElement(t, c)
+	require.Equal(t, "stream:error", elem.Name())
+	return elem.Elements().All()[0].Name()
+}
+
+func writeString(t *testing.T, c *testComponentConn, s string) {
+	_, err := c.conn.Write([]byte(s))
+	require.Nil(t, err)
+}
+
+func hasDiscoItem(disco *xep0030.DiscoInfo, stm *stream.MockC2S, itemJID string) bool {
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(stm.JID())
+	iq.SetToJID(srvJID)
+	iq.AppendElement(xmpp.NewElementNamespace("query", "http://jabber.org/protocol/disco#items"))
+	disco.ProcessIQ(context.Background(), iq)
+
+	elem := stm.ReceiveElement()
+	for _, item := range elem.Elements().Child("query").Elements().Children("item") {
+		if item.Attributes().Get("jid") == itemJID {
+			return true
+		}
+	}
+	return false
+}
+
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	return false
+}
+
+func setupTest(domain string) router.Router {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
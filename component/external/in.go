@@ -0,0 +1,347 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package external
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	streamerror "github.com/sxmpp/jackal/errors"
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/session"
+	"github.com/sxmpp/jackal/transport"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const (
+	inConnecting uint32 = iota
+	inHandshaking
+	inConnected
+	inDisconnected
+)
+
+type inConfig struct {
+	components     map[string]ComponentConfig
+	connectTimeout time.Duration
+	keepAlive      time.Duration
+	timeout        time.Duration
+	maxStanzaSize  int
+	onConnect      func(s *inStream)
+	onDisconnect   func(s *inStream)
+}
+
+type inStream struct {
+	id            string
+	cfg           *inConfig
+	router        router.Router
+	tr            transport.Transport
+	state         uint32
+	mu            sync.RWMutex
+	comp          ComponentConfig
+	connectTm     *time.Timer
+	readTimeoutTm *time.Timer
+	sess          *session.Session
+	runQueue      *runqueue.RunQueue
+}
+
+func newInStream(config *inConfig, tr transport.Transport, router router.Router) *inStream {
+	id := nextInID()
+	s := &inStream{
+		id:       id,
+		cfg:      config,
+		tr:       tr,
+		router:   router,
+		runQueue: runqueue.New(id),
+	}
+	j, _ := jid.New("", router.Hosts().DefaultHostName(), "", true)
+	s.sess = session.New(s.id, &session.Config{
+		JID:           j,
+		MaxStanzaSize: config.maxStanzaSize,
+		IsComponent:   true,
+	}, s.tr, s.router.Hosts())
+
+	if config.connectTimeout > 0 {
+		s.connectTm = time.AfterFunc(config.connectTimeout, s.connectTimeout)
+	}
+	go s.doRead() // start reading transport...
+	return s
+}
+
+func (s *inStream) ID() string {
+	return s.id
+}
+
+// Host returns the domain served by the external component.
+func (s *inStream) Host() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.comp.Host
+}
+
+// SendElement writes an element to the external component.
+func (s *inStream) SendElement(ctx context.Context, elem xmpp.XElement) {
+	s.runQueue.Run(func() {
+		if s.getState() != inConnected {
+			return
+		}
+		s.writeElement(ctx, elem)
+	})
+}
+
+func (s *inStream) Disconnect(ctx context.Context, err error) {
+	if s.getState() == inDisconnected {
+		return
+	}
+	waitCh := make(chan struct{})
+	s.runQueue.Run(func() {
+		s.disconnect(ctx, err)
+		close(waitCh)
+	})
+	<-waitCh
+}
+
+func (s *inStream) connectTimeout() {
+	s.runQueue.Run(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.timeout)
+		defer cancel()
+		s.disconnect(ctx, streamerror.ErrConnectionTimeout)
+	})
+}
+
+// runs on its own goroutine
+func (s *inStream) doRead() {
+	s.scheduleReadTimeout()
+	elem, sErr := s.sess.Receive()
+	s.cancelReadTimeout()
+
+	if sErr == nil {
+		s.runQueue.Run(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.timeout)
+			defer cancel()
+			s.readElement(ctx, elem)
+		})
+	} else {
+		s.runQueue.Run(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.timeout)
+			defer cancel()
+			if s.getState() == inDisconnected {
+				return // already disconnected...
+			}
+			s.handleSessionError(ctx, sErr)
+		})
+	}
+}
+
+func (s *inStream) handleElement(ctx context.Context, elem xmpp.XElement) {
+	switch s.getState() {
+	case inConnecting:
+		s.handleConnecting(ctx, elem)
+	case inHandshaking:
+		s.handleHandshaking(ctx, elem)
+	case inConnected:
+		s.handleConnected(ctx, elem)
+	}
+}
+
+func (s *inStream) handleConnecting(ctx context.Context, elem xmpp.XElement) {
+	// cancel connection timeout timer
+	if s.connectTm != nil {
+		s.connectTm.Stop()
+		s.connectTm = nil
+	}
+	comp, ok := s.cfg.components[elem.To()]
+	if !ok {
+		s.disconnectWithStreamError(ctx, streamerror.ErrHostUnknown)
+		return
+	}
+	s.mu.Lock()
+	s.comp = comp
+	s.mu.Unlock()
+
+	j, _ := jid.New("", comp.Host, "", true)
+	s.sess.SetJID(j)
+	s.sess.SetRemoteDomain(comp.Host)
+
+	s.setState(inHandshaking)
+	_ = s.sess.Open(ctx, nil)
+}
+
+func (s *inStream) handleHandshaking(ctx context.Context, elem xmpp.XElement) {
+	if elem.Name() != "handshake" {
+		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
+		return
+	}
+	expected := handshakeDigest(s.sess.StreamID(), s.comp.Secret)
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(elem.Text())), []byte(expected)) != 1 {
+		log.Infof("external: failed handshake for component %s... (id: %s)", s.comp.Host, s.id)
+		s.disconnectWithStreamError(ctx, streamerror.ErrNotAuthorized)
+		return
+	}
+	s.setState(inConnected)
+	s.writeElement(ctx, xmpp.NewElementName("handshake"))
+
+	if s.cfg.onConnect != nil {
+		s.cfg.onConnect(s)
+	}
+	log.Infof("external: component %s connected... (id: %s)", s.comp.Host, s.id)
+}
+
+func (s *inStream) handleConnected(ctx context.Context, elem xmpp.XElement) {
+	stanza, ok := elem.(xmpp.Stanza)
+	if !ok {
+		s.disconnectWithStreamError(ctx, streamerror.ErrUnsupportedStanzaType)
+		return
+	}
+	switch s.router.Route(ctx, stanza) {
+	case router.ErrResourceNotFound:
+		if msg, ok := stanza.(*xmpp.Message); ok {
+			// treat the stanza as if it were addressed to <node@domain>
+			msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
+			_ = s.router.Route(ctx, msg)
+			return
+		}
+		s.replyServiceUnavailable(ctx, stanza)
+	case router.ErrFailedRemoteConnect:
+		if iq, ok := stanza.(*xmpp.IQ); ok && (iq.IsGet() || iq.IsSet()) {
+			s.writeElement(ctx, iq.RemoteServerNotFoundError())
+		}
+	case router.ErrBlockedJID:
+		s.replyServiceUnavailable(ctx, stanza)
+	}
+}
+
+func (s *inStream) replyServiceUnavailable(ctx context.Context, stanza xmpp.Stanza) {
+	if iq, ok := stanza.(*xmpp.IQ); ok && (iq.IsGet() || iq.IsSet()) {
+		s.writeElement(ctx, iq.ServiceUnavailableError())
+	}
+}
+
+func (s *inStream) writeStanzaErrorResponse(ctx context.Context, elem xmpp.XElement, stanzaErr *xmpp.StanzaError) {
+	resp := xmpp.NewElementFromElement(elem)
+	resp.SetType(xmpp.ErrorType)
+	resp.SetFrom(elem.To())
+	resp.SetTo(elem.From())
+	resp.AppendElement(stanzaErr.Element())
+	s.writeElement(ctx, resp)
+}
+
+func (s *inStream) writeElement(ctx context.Context, elem xmpp.XElement) {
+	if err := s.sess.Send(ctx, elem); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *inStream) readElement(ctx context.Context, elem xmpp.XElement) {
+	if elem != nil {
+		s.handleElement(ctx, elem)
+	}
+	if s.getState() != inDisconnected {
+		go s.doRead()
+	}
+}
+
+func (s *inStream) handleSessionError(ctx context.Context, sErr *session.Error) {
+	switch err := sErr.UnderlyingErr.(type) {
+	case nil:
+		s.disconnect(ctx, nil)
+	case *streamerror.Error:
+		s.disconnectWithStreamError(ctx, err)
+	case *xmpp.StanzaError:
+		s.writeStanzaErrorResponse(ctx, sErr.Element, err)
+	default:
+		log.Error(err)
+		s.disconnectWithStreamError(ctx, streamerror.ErrUndefinedCondition)
+	}
+}
+
+func (s *inStream) disconnect(ctx context.Context, err error) {
+	if s.getState() == inDisconnected {
+		return
+	}
+	switch err {
+	case nil:
+		s.disconnectClosingSession(ctx, false)
+	default:
+		if stmErr, ok := err.(*streamerror.Error); ok {
+			s.disconnectWithStreamError(ctx, stmErr)
+		} else {
+			log.Error(err)
+			s.disconnectClosingSession(ctx, false)
+		}
+	}
+}
+
+func (s *inStream) disconnectWithStreamError(ctx context.Context, err *streamerror.Error) {
+	if s.getState() == inConnecting {
+		_ = s.sess.Open(ctx, nil)
+	}
+	s.writeElement(ctx, err.Element())
+	s.disconnectClosingSession(ctx, true)
+}
+
+func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession bool) {
+	if closeSession {
+		_ = s.sess.Close(ctx)
+	}
+	if s.cfg.onDisconnect != nil {
+		s.cfg.onDisconnect(s)
+	}
+
+	s.setState(inDisconnected)
+	_ = s.tr.Close()
+
+	s.runQueue.Stop(nil) // stop processing messages
+}
+
+func (s *inStream) scheduleReadTimeout() {
+	s.mu.Lock()
+	s.readTimeoutTm = time.AfterFunc(s.cfg.keepAlive, s.readTimeout)
+	s.mu.Unlock()
+}
+
+func (s *inStream) cancelReadTimeout() {
+	s.mu.Lock()
+	s.readTimeoutTm.Stop()
+	s.mu.Unlock()
+}
+
+func (s *inStream) readTimeout() {
+	s.runQueue.Run(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.timeout)
+		defer cancel()
+		s.disconnect(ctx, streamerror.ErrConnectionTimeout)
+	})
+}
+
+func (s *inStream) setState(state uint32) {
+	atomic.StoreUint32(&s.state, state)
+}
+
+func (s *inStream) getState() uint32 {
+	return atomic.LoadUint32(&s.state)
+}
+
+// handshakeDigest returns the expected component handshake value (XEP-0114 section 3).
+func handshakeDigest(streamID, secret string) string {
+	h := sha1.Sum([]byte(streamID + secret))
+	return hex.EncodeToString(h[:])
+}
+
+var inStreamCounter uint64
+
+func nextInID() string {
+	return fmt.Sprintf("external:in:%d", atomic.AddUint64(&inStreamCounter, 1))
+}
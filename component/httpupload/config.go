@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultServiceName = "HTTP File Upload"
+	defaultBindAddr    = "0.0.0.0"
+	defaultPort        = 5443
+	defaultMaxFileSize = 10 * 1024 * 1024
+	defaultSlotTTL     = time.Minute * 5
+)
+
+// Config represents HTTP File Upload component (XEP-0363) configuration.
+type Config struct {
+	// Host is the domain the upload service is hosted at (eg. upload.jackal.im).
+	Host string
+
+	// Name is the service name advertised through service discovery.
+	Name string
+
+	// BaseURL is the public URL prefix handed out in upload and download slots.
+	BaseURL string
+
+	// BindAddr and Port define the embedded HTTP server listening address.
+	BindAddr string
+	Port     int
+
+	// CertFile and PrivKeyFile enable TLS on the embedded HTTP server.
+	CertFile    string
+	PrivKeyFile string
+
+	// Secret is the HMAC key slot URLs are signed with.
+	Secret string
+
+	// StorePath is the directory uploaded files are stored at.
+	StorePath string
+
+	// MaxFileSize is the maximum size in bytes of a single uploaded file.
+	MaxFileSize int64
+
+	// Quota is the maximum number of bytes a user can keep stored. Zero value means no limit at all.
+	Quota int64
+
+	// AllowedTypes restricts uploads to a set of MIME types (eg. image/png or image/*).
+	// Any content type is accepted in case it's empty.
+	AllowedTypes []string
+
+	// SlotTTL defines how long an upload slot remains valid.
+	SlotTTL time.Duration
+
+	// ExpireAfter defines uploaded files lifetime. Zero value means files never expire.
+	ExpireAfter time.Duration
+
+	basePath string
+}
+
+type configProxy struct {
+	Host         string   `yaml:"host"`
+	Name         string   `yaml:"name"`
+	BaseURL      string   `yaml:"base_url"`
+	BindAddr     string   `yaml:"bind_addr"`
+	Port         int      `yaml:"port"`
+	CertFile     string   `yaml:"cert_path"`
+	PrivKeyFile  string   `yaml:"privkey_path"`
+	Secret       string   `yaml:"secret"`
+	StorePath    string   `yaml:"store_path"`
+	MaxFileSize  int64    `yaml:"max_file_size"`
+	Quota        int64    `yaml:"quota"`
+	AllowedTypes []string `yaml:"allowed_types"`
+	SlotTTL      int      `yaml:"slot_ttl"`
+	ExpireAfter  int      `yaml:"expire_after"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("httpupload.Config: host value must be set")
+	}
+	if len(p.Secret) == 0 {
+		return errors.New("httpupload.Config: secret value must be set")
+	}
+	if len(p.StorePath) == 0 {
+		return errors.New("httpupload.Config: store_path value must be set")
+	}
+	if (len(p.CertFile) > 0) != (len(p.PrivKeyFile) > 0) {
+		return errors.New("httpupload.Config: both cert_path and privkey_path must be set")
+	}
+	if p.MaxFileSize < 0 || p.Quota < 0 || p.SlotTTL < 0 || p.ExpireAfter < 0 {
+		return errors.New("httpupload.Config: max_file_size, quota, slot_ttl and expire_after must be positive values")
+	}
+	cfg.Host = p.Host
+	cfg.Name = p.Name
+	if len(cfg.Name) == 0 {
+		cfg.Name = defaultServiceName
+	}
+	cfg.BindAddr = p.BindAddr
+	if len(cfg.BindAddr) == 0 {
+		cfg.BindAddr = defaultBindAddr
+	}
+	cfg.Port = p.Port
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	cfg.CertFile = p.CertFile
+	cfg.PrivKeyFile = p.PrivKeyFile
+	cfg.BaseURL = p.BaseURL
+	if len(cfg.BaseURL) == 0 {
+		scheme := "http"
+		if len(cfg.CertFile) > 0 {
+			scheme = "https"
+		}
+		cfg.BaseURL = fmt.Sprintf("%s://%s:%d", scheme, cfg.Host, cfg.Port)
+	}
+	cfg.Secret = p.Secret
+	cfg.StorePath = p.StorePath
+	cfg.MaxFileSize = p.MaxFileSize
+	if cfg.MaxFileSize == 0 {
+		cfg.MaxFileSize = defaultMaxFileSize
+	}
+	cfg.Quota = p.Quota
+	cfg.AllowedTypes = p.AllowedTypes
+	cfg.SlotTTL = time.Duration(p.SlotTTL) * time.Second
+	if cfg.SlotTTL == 0 {
+		cfg.SlotTTL = defaultSlotTTL
+	}
+	cfg.ExpireAfter = time.Duration(p.ExpireAfter) * time.Second
+	return cfg.parseBaseURL()
+}
+
+func (cfg *Config) parseBaseURL() error {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("httpupload.Config: invalid base_url: %s", cfg.BaseURL)
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	cfg.basePath = strings.TrimSuffix(u.Path, "/")
+	return nil
+}
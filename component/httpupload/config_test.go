@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestHTTPUploadConfig(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte("secret: s3cr3t\nstore_path: /tmp/uploads"), &cfg)
+	require.NotNil(t, err) // missing host
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nstore_path: /tmp/uploads"), &cfg)
+	require.NotNil(t, err) // missing secret
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nsecret: s3cr3t\nstore_path: /tmp/uploads\nbase_url: ftp://upload.jackal.im"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nsecret: s3cr3t\nstore_path: /tmp/uploads\ncert_path: cert.pem"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: upload.jackal.im\nsecret: s3cr3t\nstore_path: /tmp/uploads"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultServiceName, cfg.Name)
+	require.Equal(t, "http://upload.jackal.im:5443", cfg.BaseURL)
+	require.Equal(t, int64(defaultMaxFileSize), cfg.MaxFileSize)
+	require.Equal(t, defaultSlotTTL, cfg.SlotTTL)
+	require.Equal(t, time.Duration(0), cfg.ExpireAfter)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`
+host: upload.jackal.im
+secret: s3cr3t
+store_path: /tmp/uploads
+base_url: https://jackal.im/upload/
+max_file_size: 1024
+quota: 4096
+allowed_types: [image/*]
+expire_after: 3600
+`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "https://jackal.im/upload", cfg.BaseURL)
+	require.Equal(t, "/upload", cfg.basePath)
+	require.Equal(t, int64(4096), cfg.Quota)
+	require.Equal(t, []string{"image/*"}, cfg.AllowedTypes)
+	require.Equal(t, time.Hour, cfg.ExpireAfter)
+}
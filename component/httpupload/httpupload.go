@@ -0,0 +1,319 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	uploadNamespace     = "urn:xmpp:http:upload:0"
+	discoInfoNamespace  = "http://jabber.org/protocol/disco#info"
+	defaultContentType  = "application/octet-stream"
+	maxFileSizeFieldVar = "max-file-size"
+)
+
+// expirationInterval defines how often expired files are purged.
+var expirationInterval = time.Minute
+
+// HTTPUpload represents an HTTP File Upload (XEP-0363) component.
+type HTTPUpload struct {
+	cfg      Config
+	disco    *xep0030.DiscoInfo
+	router   router.Router
+	runQueue *runqueue.RunQueue
+	srv      *http.Server
+	mu       sync.Mutex
+	usage    map[string]int64 // stored bytes by user bare JID
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// New returns an HTTP file upload component instance, serving uploads from its embedded HTTP server.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router) (*HTTPUpload, error) {
+	x := &HTTPUpload{
+		cfg:      *config,
+		disco:    disco,
+		router:   router,
+		runQueue: runqueue.New("httpupload"),
+		usage:    make(map[string]int64),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	if err := x.cfg.parseBaseURL(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(x.cfg.StorePath, os.FileMode(0700)); err != nil {
+		return nil, err
+	}
+	if err := x.loadUsage(); err != nil {
+		return nil, err
+	}
+	if x.cfg.ExpireAfter > 0 {
+		x.purgeExpiredFiles()
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", x.cfg.BindAddr, x.cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+	x.srv = &http.Server{Handler: http.HandlerFunc(x.handleRequest)}
+	go func() {
+		var err error
+		if len(x.cfg.CertFile) > 0 {
+			err = x.srv.ServeTLS(ln, x.cfg.CertFile, x.cfg.PrivKeyFile)
+		} else {
+			err = x.srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Error(err)
+		}
+	}()
+	log.Infof("httpupload: listening at %s", ln.Addr())
+
+	go x.loop()
+
+	if disco != nil {
+		disco.RegisterProvider(x.cfg.Host, x)
+		disco.RegisterServerItem(xep0030.Item{Jid: x.cfg.Host, Name: x.cfg.Name})
+	}
+	return x, nil
+}
+
+// Host returns HTTP file upload service domain.
+func (x *HTTPUpload) Host() string {
+	return x.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the HTTP file upload service.
+func (x *HTTPUpload) ProcessStanza(ctx context.Context, stanza xmpp.Stanza) {
+	iq, ok := stanza.(*xmpp.IQ)
+	if !ok {
+		return
+	}
+	x.runQueue.Run(func() {
+		x.processIQ(ctx, iq)
+	})
+}
+
+// Shutdown shuts down HTTP file upload component.
+func (x *HTTPUpload) Shutdown() error {
+	if x.disco != nil {
+		x.disco.UnregisterServerItem(xep0030.Item{Jid: x.cfg.Host, Name: x.cfg.Name})
+		x.disco.UnregisterProvider(x.cfg.Host)
+	}
+	close(x.stopCh)
+	<-x.doneCh
+
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return x.srv.Close()
+}
+
+// Identities satisfies xep0030.InfoProvider interface.
+func (x *HTTPUpload) Identities(_ context.Context, toJID, _ *jid.JID, node string) []xep0030.Identity {
+	if !toJID.IsServer() || len(node) > 0 {
+		return nil
+	}
+	return []xep0030.Identity{{Category: "store", Type: "file", Name: x.cfg.Name}}
+}
+
+// Items satisfies xep0030.InfoProvider interface.
+func (x *HTTPUpload) Items(_ context.Context, _, _ *jid.JID, _ string) ([]xep0030.Item, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// Features satisfies xep0030.InfoProvider interface.
+func (x *HTTPUpload) Features(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if !toJID.IsServer() || len(node) > 0 {
+		return nil, xmpp.ErrItemNotFound
+	}
+	return []xep0030.Feature{discoInfoNamespace, uploadNamespace}, nil
+}
+
+// Form satisfies xep0030.InfoProvider interface.
+func (x *HTTPUpload) Form(_ context.Context, toJID, _ *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if !toJID.IsServer() || len(node) > 0 {
+		return nil, nil
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{uploadNamespace}},
+			{Var: maxFileSizeFieldVar, Values: []string{strconv.FormatInt(x.cfg.MaxFileSize, 10)}},
+		},
+	}, nil
+}
+
+func (x *HTTPUpload) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	req := iq.Elements().ChildNamespace("request", uploadNamespace)
+	if req == nil {
+		if iq.IsGet() || iq.IsSet() {
+			_ = x.router.Route(ctx, iq.ServiceUnavailableError())
+		}
+		return
+	}
+	if !iq.IsGet() {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	fromJID := iq.FromJID()
+	if !x.router.Hosts().IsLocalHost(fromJID.Domain()) {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	filename := req.Attributes().Get("filename")
+	size, err := strconv.ParseInt(req.Attributes().Get("size"), 10, 64)
+	if err != nil || size <= 0 || !isValidFilename(filename) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	contentType := req.Attributes().Get("content-type")
+	if len(contentType) == 0 {
+		contentType = defaultContentType
+	} else if hasControlChars(contentType) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if size > x.cfg.MaxFileSize {
+		tooLarge := xmpp.NewElementNamespace("file-too-large", uploadNamespace)
+		tooLarge.AppendElement(xmpp.NewElementName("max-file-size").SetText(strconv.FormatInt(x.cfg.MaxFileSize, 10)))
+		_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(iq, xmpp.ErrNotAcceptable, []xmpp.XElement{tooLarge}))
+		return
+	}
+	if !x.isAllowedType(contentType) {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	owner := fromJID.ToBareJID().String()
+	if !x.hasQuotaFor(owner, size) {
+		_ = x.router.Route(ctx, iq.ResourceConstraintError())
+		return
+	}
+	token := uuid.New()
+	expires := strconv.FormatInt(time.Now().Add(x.cfg.SlotTTL).Unix(), 10)
+	sizeStr := strconv.FormatInt(size, 10)
+	filePath := token + "/" + filename
+	fileURL := x.cfg.BaseURL + "/" + token + "/" + url.PathEscape(filename)
+
+	putQuery := url.Values{}
+	putQuery.Set("size", sizeStr)
+	putQuery.Set("type", contentType)
+	putQuery.Set("user", owner)
+	putQuery.Set("expires", expires)
+	putQuery.Set("sig", x.sign(http.MethodPut, filePath, sizeStr, contentType, owner, expires))
+
+	getQuery := url.Values{}
+	getQuery.Set("sig", x.sign(http.MethodGet, filePath))
+
+	putEl := xmpp.NewElementName("put")
+	putEl.SetAttribute("url", fileURL+"?"+putQuery.Encode())
+	getEl := xmpp.NewElementName("get")
+	getEl.SetAttribute("url", fileURL+"?"+getQuery.Encode())
+
+	slot := xmpp.NewElementNamespace("slot", uploadNamespace)
+	slot.AppendElement(putEl)
+	slot.AppendElement(getEl)
+
+	res := iq.ResultIQ()
+	res.AppendElement(slot)
+	_ = x.router.Route(ctx, res)
+
+	log.Infof("httpupload: issued upload slot %s (%s, %d bytes) to %s", filePath, contentType, size, owner)
+}
+
+func (x *HTTPUpload) isAllowedType(contentType string) bool {
+	if len(x.cfg.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, allowed := range x.cfg.AllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *HTTPUpload) hasQuotaFor(owner string, size int64) bool {
+	if x.cfg.Quota == 0 {
+		return true
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.usage[owner]+size <= x.cfg.Quota
+}
+
+func (x *HTTPUpload) sign(parts ...string) string {
+	h := hmac.New(sha256.New, []byte(x.cfg.Secret))
+	for _, part := range parts {
+		// length-prefix every part, so that no field boundary can be forged
+		_, _ = h.Write([]byte(strconv.Itoa(len(part)) + ":" + part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (x *HTTPUpload) verify(sig string, parts ...string) bool {
+	return hmac.Equal([]byte(sig), []byte(x.sign(parts...)))
+}
+
+func (x *HTTPUpload) loop() {
+	defer close(x.doneCh)
+
+	if x.cfg.ExpireAfter == 0 {
+		<-x.stopCh
+		return
+	}
+	tc := time.NewTicker(expirationInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			x.purgeExpiredFiles()
+		case <-x.stopCh:
+			return
+		}
+	}
+}
+
+func isValidFilename(filename string) bool {
+	if len(filename) == 0 || filename == "." || filename == ".." {
+		return false
+	}
+	return path.Base(filename) == filename && !strings.ContainsAny(filename, "/\\") && !hasControlChars(filename)
+}
+
+func hasControlChars(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) != -1
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUpload_Slots(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x, dir := newTestUpload(t, r)
+	defer func() { _ = x.Shutdown(); _ = os.RemoveAll(dir) }()
+
+	// file too large
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "photo.png", 2048, "image/png"))
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+	tooLarge := elem.Error().Elements().ChildNamespace("file-too-large", uploadNamespace)
+	require.NotNil(t, tooLarge)
+	require.Equal(t, "1024", tooLarge.Elements().Child("max-file-size").Text())
+
+	// forbidden content type
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "script.sh", 10, "application/x-sh"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid file name
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "../passwd", 10, "text/plain"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "photo\n.png", 10, "image/png"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid content type
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "photo.png", 10, "image/png\nnoelia@jackal.im"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// upload
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "my photo.png", 1000, "image/png"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	slot := elem.Elements().ChildNamespace("slot", uploadNamespace)
+	require.NotNil(t, slot)
+	putURL := slot.Elements().Child("put").Attributes().Get("url")
+	getURL := slot.Elements().Child("get").Attributes().Get("url")
+	require.True(t, strings.HasPrefix(putURL, "http://upload.jackal.im/files/"))
+	require.True(t, strings.HasPrefix(getURL, "http://upload.jackal.im/files/"))
+
+	body := strings.Repeat("a", 1000)
+
+	require.Equal(t, http.StatusForbidden, doRequest(x, http.MethodPut, strings.Replace(putURL, "sig=", "sig=00", 1), body, "image/png").Code)
+	require.Equal(t, http.StatusBadRequest, doRequest(x, http.MethodPut, putURL, body[:999], "image/png").Code)
+	require.Equal(t, http.StatusBadRequest, doRequest(x, http.MethodPut, putURL, body, "text/plain").Code)
+	require.Equal(t, http.StatusNotFound, doRequest(x, http.MethodGet, getURL, "", "").Code)
+
+	require.Equal(t, http.StatusCreated, doRequest(x, http.MethodPut, putURL, body, "image/png").Code)
+	require.Equal(t, http.StatusConflict, doRequest(x, http.MethodPut, putURL, body, "image/png").Code)
+
+	require.Equal(t, http.StatusForbidden, doRequest(x, http.MethodGet, strings.Replace(getURL, "sig=", "sig=00", 1), "", "").Code)
+
+	rec := doRequest(x, http.MethodGet, getURL, "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, body, rec.Body.String())
+
+	// quota exceeded
+	x.ProcessStanza(context.Background(), slotRequestIQ(j, "other.png", 1000, "image/png"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrResourceConstraint.Error(), elem.Error().Elements().All()[0].Name())
+
+	// expired files
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), metadataExt) {
+			continue
+		}
+		token := strings.TrimSuffix(entry.Name(), metadataExt)
+		meta, _ := x.readMetadata(token)
+		meta.Created = time.Now().Add(-time.Hour * 2)
+		b, _ := json.Marshal(meta)
+		_ = ioutil.WriteFile(x.metadataPath(token), b, os.FileMode(0600))
+	}
+	x.purgeExpiredFiles()
+
+	require.Equal(t, http.StatusNotFound, doRequest(x, http.MethodGet, getURL, "", "").Code)
+	require.True(t, x.hasQuotaFor(j.ToBareJID().String(), 1000))
+}
+
+func TestHTTPUpload_Sign(t *testing.T) {
+	x, dir := newTestUpload(t, setupTest("jackal.im"))
+	defer func() { _ = x.Shutdown(); _ = os.RemoveAll(dir) }()
+
+	// moving a field boundary must change the signature
+	require.NotEqual(t, x.sign("a\nb", "c"), x.sign("a", "b\nc"))
+	require.NotEqual(t, x.sign("ab", "c"), x.sign("a", "bc"))
+	require.True(t, x.verify(x.sign("a", "b"), "a", "b"))
+}
+
+func TestHTTPUpload_DiscoInfo(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	x, dir := newTestUpload(t, r)
+	defer func() { _ = x.Shutdown(); _ = os.RemoveAll(dir) }()
+
+	serviceJID, _ := jid.NewWithString("upload.jackal.im", true)
+	fromJID, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+
+	features, sErr := x.Features(context.Background(), serviceJID, fromJID, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, uploadNamespace)
+
+	form, sErr := x.Form(context.Background(), serviceJID, fromJID, "")
+	require.Nil(t, sErr)
+	require.NotNil(t, form)
+	require.Equal(t, uploadNamespace, form.Fields[0].Values[0])
+	require.Equal(t, maxFileSizeFieldVar, form.Fields[1].Var)
+	require.Equal(t, "1024", form.Fields[1].Values[0])
+}
+
+func newTestUpload(t *testing.T, r router.Router) (*HTTPUpload, string) {
+	dir, err := ioutil.TempDir("", "httpupload")
+	require.Nil(t, err)
+
+	x, err := New(&Config{
+		Host:         "upload.jackal.im",
+		Name:         defaultServiceName,
+		BaseURL:      "http://upload.jackal.im/files",
+		BindAddr:     "127.0.0.1",
+		Secret:       "s3cr3t",
+		StorePath:    dir,
+		MaxFileSize:  1024,
+		Quota:        1500,
+		AllowedTypes: []string{"image/*", "text/plain"},
+		SlotTTL:      time.Minute,
+		ExpireAfter:  time.Hour,
+	}, nil, r)
+	require.Nil(t, err)
+	return x, dir
+}
+
+func slotRequestIQ(fromJID *jid.JID, filename string, size int, contentType string) *xmpp.IQ {
+	toJID, _ := jid.NewWithString("upload.jackal.im", true)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(toJID)
+	req := xmpp.NewElementNamespace("request", uploadNamespace)
+	req.SetAttribute("filename", filename)
+	req.SetAttribute("size", strconv.Itoa(size))
+	req.SetAttribute("content-type", contentType)
+	iq.AppendElement(req)
+	return iq
+}
+
+func doRequest(x *HTTPUpload, method, target, body, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	x.handleRequest(rec, req)
+	return rec
+}
+
+func setupTest(domain string) router.Router {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
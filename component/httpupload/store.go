@@ -0,0 +1,263 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/pborman/uuid"
+)
+
+const metadataExt = ".json"
+
+type fileMetadata struct {
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Created     time.Time `json:"created"`
+}
+
+func (x *HTTPUpload) handleRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, HEAD, GET, PUT")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	token, filename, ok := x.parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		x.handlePut(w, r, token, filename)
+	case http.MethodGet, http.MethodHead:
+		x.handleGet(w, r, token, filename)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (x *HTTPUpload) handlePut(w http.ResponseWriter, r *http.Request, token, filename string) {
+	q := r.URL.Query()
+	sizeStr, contentType, owner, expiresStr := q.Get("size"), q.Get("type"), q.Get("user"), q.Get("expires")
+	if !x.verify(q.Get("sig"), http.MethodPut, token+"/"+filename, sizeStr, contentType, owner, expiresStr) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	expires, _ := strconv.ParseInt(expiresStr, 10, 64)
+	if time.Now().Unix() > expires {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	size, _ := strconv.ParseInt(sizeStr, 10, 64)
+	if r.ContentLength != size {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); len(ct) > 0 && ct != contentType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(x.cfg.StorePath, token)); err == nil {
+		w.WriteHeader(http.StatusConflict) // slot already used
+		return
+	}
+	// reserve quota
+	x.mu.Lock()
+	if x.cfg.Quota > 0 && x.usage[owner]+size > x.cfg.Quota {
+		x.mu.Unlock()
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	x.usage[owner] += size
+	x.mu.Unlock()
+
+	status, err := x.storeFile(r.Body, token, &fileMetadata{
+		Owner:       owner,
+		Name:        filename,
+		Size:        size,
+		ContentType: contentType,
+		Created:     time.Now(),
+	})
+	if err != nil {
+		x.releaseUsage(owner, size)
+		if status == http.StatusInternalServerError {
+			log.Error(err)
+		}
+		w.WriteHeader(status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+
+	log.Infof("httpupload: stored file %s/%s (%d bytes) uploaded by %s", token, filename, size, owner)
+}
+
+func (x *HTTPUpload) storeFile(body io.Reader, token string, meta *fileMetadata) (int, error) {
+	dir := filepath.Join(x.cfg.StorePath, token)
+	if err := os.Mkdir(dir, os.FileMode(0700)); err != nil {
+		if os.IsExist(err) {
+			return http.StatusConflict, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".upload")
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return http.StatusInternalServerError, err
+	}
+	n, err := io.Copy(tmp, io.LimitReader(body, meta.Size+1))
+	_ = tmp.Close()
+	if err != nil || n != meta.Size {
+		_ = os.RemoveAll(dir)
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return http.StatusBadRequest, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, meta.Name)); err != nil {
+		_ = os.RemoveAll(dir)
+		return http.StatusInternalServerError, err
+	}
+	b, err := json.Marshal(meta)
+	if err == nil {
+		err = ioutil.WriteFile(x.metadataPath(token), b, os.FileMode(0600))
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
+
+func (x *HTTPUpload) handleGet(w http.ResponseWriter, r *http.Request, token, filename string) {
+	if !x.verify(r.URL.Query().Get("sig"), http.MethodGet, token+"/"+filename) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	meta, err := x.readMetadata(token)
+	if err != nil || meta.Name != filename || x.isExpired(meta) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(filepath.Join(x.cfg.StorePath, token, filename))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Type", meta.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	http.ServeContent(w, r, filename, meta.Created, f)
+}
+
+func (x *HTTPUpload) parsePath(p string) (token, filename string, ok bool) {
+	if !strings.HasPrefix(p, x.cfg.basePath+"/") {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(p, x.cfg.basePath+"/"), "/", 2)
+	if len(parts) != 2 || uuid.Parse(parts[0]) == nil || !isValidFilename(parts[1]) {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func (x *HTTPUpload) metadataPath(token string) string {
+	return filepath.Join(x.cfg.StorePath, token+metadataExt)
+}
+
+func (x *HTTPUpload) readMetadata(token string) (*fileMetadata, error) {
+	b, err := ioutil.ReadFile(x.metadataPath(token))
+	if err != nil {
+		return nil, err
+	}
+	var meta fileMetadata
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (x *HTTPUpload) isExpired(meta *fileMetadata) bool {
+	return x.cfg.ExpireAfter > 0 && time.Since(meta.Created) > x.cfg.ExpireAfter
+}
+
+func (x *HTTPUpload) releaseUsage(owner string, size int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.usage[owner] -= size
+	if x.usage[owner] <= 0 {
+		delete(x.usage, owner)
+	}
+}
+
+func (x *HTTPUpload) forEachStoredFile(fn func(token string, meta *fileMetadata)) error {
+	entries, err := ioutil.ReadDir(x.cfg.StorePath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), metadataExt) {
+			continue
+		}
+		token := strings.TrimSuffix(entry.Name(), metadataExt)
+		meta, err := x.readMetadata(token)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		fn(token, meta)
+	}
+	return nil
+}
+
+func (x *HTTPUpload) loadUsage() error {
+	return x.forEachStoredFile(func(_ string, meta *fileMetadata) {
+		x.usage[meta.Owner] += meta.Size
+	})
+}
+
+// purgeExpiredFiles removes every expired file from store directory, releasing owners quota.
+func (x *HTTPUpload) purgeExpiredFiles() {
+	var count int
+	err := x.forEachStoredFile(func(token string, meta *fileMetadata) {
+		if !x.isExpired(meta) {
+			return
+		}
+		if err := os.RemoveAll(filepath.Join(x.cfg.StorePath, token)); err != nil {
+			log.Error(err)
+			return
+		}
+		if err := os.Remove(x.metadataPath(token)); err != nil {
+			log.Error(err)
+			return
+		}
+		x.releaseUsage(meta.Owner, meta.Size)
+		count++
+	})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if count > 0 {
+		log.Infof("httpupload: purged expired files... count: %d", count)
+	}
+}
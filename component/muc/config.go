@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import "errors"
+
+const (
+	defaultServiceName = "Chatrooms"
+	defaultHistorySize = 20
+)
+
+// Config represents Multi-User Chat component (XEP-0045) configuration.
+type Config struct {
+	// Host is the domain the multi-user chat service is hosted at (eg. conference.jackal.im).
+	Host string
+
+	// Name is the service name advertised through service discovery.
+	Name string
+
+	// HistorySize is the maximum number of discussion history messages kept per room.
+	HistorySize int
+}
+
+type configProxy struct {
+	Host        string `yaml:"host"`
+	Name        string `yaml:"name"`
+	HistorySize *int   `yaml:"history_size"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("muc.Config: host value must be set")
+	}
+	cfg.Host = p.Host
+	cfg.Name = p.Name
+	if len(cfg.Name) == 0 {
+		cfg.Name = defaultServiceName
+	}
+	cfg.HistorySize = defaultHistorySize
+	if p.HistorySize != nil {
+		if *p.HistorySize < 0 {
+			return errors.New("muc.Config: history_size value must be a non-negative integer")
+		}
+		cfg.HistorySize = *p.HistorySize
+	}
+	return nil
+}
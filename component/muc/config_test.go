@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMucConfig(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(`name: Rooms`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: conference.jackal.im\nhistory_size: -1"), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`host: conference.jackal.im`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "conference.jackal.im", cfg.Host)
+	require.Equal(t, defaultServiceName, cfg.Name)
+	require.Equal(t, defaultHistorySize, cfg.HistorySize)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte("host: conference.jackal.im\nname: Rooms\nhistory_size: 0"), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "Rooms", cfg.Name)
+	require.Equal(t, 0, cfg.HistorySize)
+}
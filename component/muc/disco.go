@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const (
+	discoInfoNamespace  = "http://jabber.org/protocol/disco#info"
+	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
+
+	roomInfoFormType = "http://jabber.org/protocol/muc#roominfo"
+	roomUserItemNode = "x-roomuser-item"
+)
+
+// Identities satisfies xep0030.InfoProvider interface.
+func (x *Muc) Identities(_ context.Context, toJID, fromJID *jid.JID, node string) []xep0030.Identity {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if toJID.IsServer() {
+		return []xep0030.Identity{{Category: "conference", Type: "text", Name: x.cfg.Name}}
+	}
+	r := x.visibleRoom(toJID)
+	if r == nil {
+		return nil
+	}
+	if node == roomUserItemNode {
+		if nick, ok := r.reservations[fromJID.ToBareJID().String()]; ok {
+			return []xep0030.Identity{{Category: "conference", Type: "text", Name: nick}}
+		}
+		return nil
+	}
+	name := r.Config.Name
+	if len(name) == 0 {
+		name = r.jid.Node()
+	}
+	return []xep0030.Identity{{Category: "conference", Type: "text", Name: name}}
+}
+
+// Items satisfies xep0030.InfoProvider interface.
+func (x *Muc) Items(_ context.Context, toJID, _ *jid.JID, _ string) ([]xep0030.Item, *xmpp.StanzaError) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if !toJID.IsServer() {
+		if x.visibleRoom(toJID) == nil {
+			return nil, xmpp.ErrItemNotFound
+		}
+		return nil, nil // occupants are not disclosed
+	}
+	var items []xep0030.Item
+	for _, r := range x.rooms {
+		if r.locked || !r.Config.Public {
+			continue
+		}
+		name := r.Config.Name
+		if len(name) == 0 {
+			name = r.jid.Node()
+		}
+		items = append(items, xep0030.Item{Jid: r.JID, Name: name})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Jid < items[j].Jid })
+	return items, nil
+}
+
+// Features satisfies xep0030.InfoProvider interface.
+func (x *Muc) Features(_ context.Context, toJID, _ *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if toJID.IsServer() {
+		return []xep0030.Feature{discoInfoNamespace, discoItemsNamespace, mucNamespace}, nil
+	}
+	r := x.visibleRoom(toJID)
+	if r == nil {
+		return nil, xmpp.ErrItemNotFound
+	}
+	if node == roomUserItemNode {
+		return []xep0030.Feature{discoInfoNamespace}, nil
+	}
+	features := []xep0030.Feature{discoInfoNamespace, mucNamespace, registerNamespace}
+	features = append(features, featureFlag(r.Config.Persistent, "muc_persistent", "muc_temporary"))
+	features = append(features, featureFlag(r.Config.Public, "muc_public", "muc_hidden"))
+	features = append(features, featureFlag(r.Config.MembersOnly, "muc_membersonly", "muc_open"))
+	features = append(features, featureFlag(r.Config.Moderated, "muc_moderated", "muc_unmoderated"))
+	features = append(features, featureFlag(r.Config.PasswordProtected, "muc_passwordprotected", "muc_unsecured"))
+	features = append(features, featureFlag(r.Config.NonAnonymous, "muc_nonanonymous", "muc_semianonymous"))
+	return features, nil
+}
+
+// Form satisfies xep0030.InfoProvider interface.
+func (x *Muc) Form(_ context.Context, toJID, _ *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if toJID.IsServer() || len(node) > 0 {
+		return nil, nil
+	}
+	r := x.visibleRoom(toJID)
+	if r == nil {
+		return nil, nil
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{roomInfoFormType}},
+			{Var: "muc#roominfo_description", Label: "Description", Values: []string{r.Config.Description}},
+			{Var: "muc#roominfo_subject", Label: "Subject", Values: []string{r.Subject}},
+			{Var: "muc#roominfo_occupants", Label: "Number of occupants", Values: []string{strconv.Itoa(len(r.occupants))}},
+		},
+	}, nil
+}
+
+func (x *Muc) visibleRoom(toJID *jid.JID) *room {
+	if !toJID.IsBare() {
+		return nil
+	}
+	r := x.rooms[toJID.String()]
+	if r == nil || r.locked {
+		return nil
+	}
+	return r
+}
+
+func featureFlag(enabled bool, on, off string) string {
+	if enabled {
+		return on
+	}
+	return off
+}
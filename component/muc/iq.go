@@ -0,0 +1,442 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sxmpp/jackal/log"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const (
+	registerFormType     = "http://jabber.org/protocol/muc#register"
+	registerRoomNickVar  = "muc#register_roomnick"
+	registerInstructions = "Reserve a nickname within this room."
+)
+
+func (x *Muc) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	if !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	toJID := iq.ToJID()
+	if !toJID.IsBare() || toJID.IsServer() {
+		_ = x.router.Route(ctx, iq.ServiceUnavailableError())
+		return
+	}
+	r := x.rooms[toJID.String()]
+	if r == nil {
+		_ = x.router.Route(ctx, iq.ItemNotFoundError())
+		return
+	}
+	q := iq.Elements().Child("query")
+	if q == nil {
+		_ = x.router.Route(ctx, iq.ServiceUnavailableError())
+		return
+	}
+	switch q.Namespace() {
+	case mucAdminNamespace:
+		x.processAdminIQ(ctx, r, iq, q)
+	case mucOwnerNamespace:
+		x.processOwnerIQ(ctx, r, iq, q)
+	case registerNamespace:
+		x.processRegisterIQ(ctx, r, iq, q)
+	default:
+		_ = x.router.Route(ctx, iq.ServiceUnavailableError())
+	}
+}
+
+func (x *Muc) processAdminIQ(ctx context.Context, r *room, iq *xmpp.IQ, q xmpp.XElement) {
+	items := q.Elements().Children("item")
+	if len(items) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	requester := iq.FromJID()
+	if iq.IsGet() {
+		x.sendAdminList(ctx, r, iq, items[0])
+		return
+	}
+	// validate every change before applying any of them
+	targets := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if sErr := x.validateAdminItem(r, requester, item); sErr != nil {
+			_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+		// each target can only be changed once per request
+		target := "nick:" + item.Attributes().Get("nick")
+		if len(item.Attributes().Get("affiliation")) > 0 {
+			targetJID, _ := jid.NewWithString(item.Attributes().Get("jid"), false)
+			target = "jid:" + targetJID.ToBareJID().String()
+		}
+		if _, ok := targets[target]; ok {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+		targets[target] = struct{}{}
+	}
+	var updated bool
+	for _, item := range items {
+		if len(item.Attributes().Get("affiliation")) > 0 {
+			targetJID, _ := jid.NewWithString(item.Attributes().Get("jid"), false)
+			x.setAffiliation(ctx, r, targetJID.ToBareJID().String(), item.Attributes().Get("affiliation"))
+			updated = true
+			continue
+		}
+		// a former affiliation change may have already removed the occupant
+		if occ := r.occupants[item.Attributes().Get("nick")]; occ != nil {
+			x.setRole(ctx, r, occ, item.Attributes().Get("role"))
+		}
+	}
+	if updated && r.Config.Persistent {
+		if err := x.mucRep.UpsertMucRoom(ctx, &r.Room); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *Muc) sendAdminList(ctx context.Context, r *room, iq *xmpp.IQ, item xmpp.XElement) {
+	requester := iq.FromJID()
+	requesterAff := r.Affiliation(requester.ToBareJID().String())
+
+	q := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	if aff := item.Attributes().Get("affiliation"); len(aff) > 0 {
+		if requesterAff != mucmodel.Owner && requesterAff != mucmodel.Admin {
+			_ = x.router.Route(ctx, iq.ForbiddenError())
+			return
+		}
+		if !isAffiliation(aff) || aff == mucmodel.None {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+		for _, j := range sortedStrings(r.AffiliatedJIDs(aff)) {
+			el := xmpp.NewElementName("item")
+			el.SetAttribute("affiliation", aff)
+			el.SetAttribute("jid", j)
+			if nick, ok := r.reservations[j]; ok {
+				el.SetAttribute("nick", nick)
+			}
+			q.AppendElement(el)
+		}
+	} else {
+		role := item.Attributes().Get("role")
+		occ := r.occupantByJID(requester)
+		if occ == nil || occ.role != moderatorRole {
+			_ = x.router.Route(ctx, iq.ForbiddenError())
+			return
+		}
+		if role != moderatorRole && role != participantRole && role != visitorRole {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+			return
+		}
+		for _, o := range r.sortedOccupants() {
+			if o.role != role {
+				continue
+			}
+			el := xmpp.NewElementName("item")
+			el.SetAttribute("affiliation", r.occupantAffiliation(o))
+			el.SetAttribute("jid", o.jid.String())
+			el.SetAttribute("nick", o.nick)
+			el.SetAttribute("role", o.role)
+			q.AppendElement(el)
+		}
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(q)
+	_ = x.router.Route(ctx, res)
+}
+
+func (x *Muc) validateAdminItem(r *room, requester *jid.JID, item xmpp.XElement) *xmpp.StanzaError {
+	requesterAff := r.Affiliation(requester.ToBareJID().String())
+
+	if aff := item.Attributes().Get("affiliation"); len(aff) > 0 {
+		if !isAffiliation(aff) {
+			return xmpp.ErrBadRequest
+		}
+		targetJID, err := jid.NewWithString(item.Attributes().Get("jid"), false)
+		if err != nil {
+			return xmpp.ErrJidMalformed
+		}
+		target := targetJID.ToBareJID().String()
+		targetAff := r.Affiliation(target)
+
+		switch requesterAff {
+		case mucmodel.Owner:
+			if targetAff == mucmodel.Owner && aff != mucmodel.Owner && len(r.AffiliatedJIDs(mucmodel.Owner)) == 1 {
+				return xmpp.ErrConflict // room must keep at least one owner
+			}
+		case mucmodel.Admin:
+			if aff == mucmodel.Owner || aff == mucmodel.Admin || targetAff == mucmodel.Owner || targetAff == mucmodel.Admin {
+				return xmpp.ErrNotAllowed
+			}
+		default:
+			return xmpp.ErrForbidden
+		}
+		return nil
+	}
+	role := item.Attributes().Get("role")
+	switch role {
+	case moderatorRole, participantRole, visitorRole, noneRole:
+		break
+	default:
+		return xmpp.ErrBadRequest
+	}
+	occ := r.occupantByJID(requester)
+	if occ == nil || occ.role != moderatorRole {
+		return xmpp.ErrForbidden
+	}
+	target := r.occupants[item.Attributes().Get("nick")]
+	if target == nil {
+		return xmpp.ErrItemNotFound
+	}
+	if target == occ && role == noneRole {
+		return xmpp.ErrConflict
+	}
+	targetAff := r.occupantAffiliation(target)
+	if role != moderatorRole && (targetAff == mucmodel.Owner || targetAff == mucmodel.Admin) {
+		return xmpp.ErrNotAllowed
+	}
+	if (role == moderatorRole || target.role == moderatorRole) && requesterAff != mucmodel.Owner && requesterAff != mucmodel.Admin {
+		return xmpp.ErrForbidden
+	}
+	return nil
+}
+
+func (x *Muc) setRole(ctx context.Context, r *room, occ *occupant, role string) {
+	if role == noneRole {
+		x.removeOccupant(ctx, r, occ, kickedStatus)
+		return
+	}
+	occ.role = role
+	x.broadcastPresence(ctx, r, occ, xmpp.AvailableType)
+}
+
+func (x *Muc) setAffiliation(ctx context.Context, r *room, bareJID, affiliation string) {
+	r.SetAffiliation(bareJID, affiliation)
+
+	for _, occ := range r.occupantsByBareJID(bareJID) {
+		switch {
+		case affiliation == mucmodel.Outcast:
+			x.removeOccupant(ctx, r, occ, bannedStatus)
+		case affiliation == mucmodel.None && r.Config.MembersOnly:
+			x.removeOccupant(ctx, r, occ, affiliationRemovedStatus)
+		default:
+			occ.role = r.defaultRole(affiliation)
+			x.broadcastPresence(ctx, r, occ, xmpp.AvailableType)
+		}
+	}
+}
+
+func (x *Muc) processOwnerIQ(ctx context.Context, r *room, iq *xmpp.IQ, q xmpp.XElement) {
+	if r.Affiliation(iq.FromJID().ToBareJID().String()) != mucmodel.Owner {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		res := iq.ResultIQ()
+		query := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+		query.AppendElement(r.Config.Form().Element())
+		res.AppendElement(query)
+		_ = x.router.Route(ctx, res)
+		return
+	}
+	if destroy := q.Elements().Child("destroy"); destroy != nil {
+		x.destroyRoom(ctx, r, destroy)
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		return
+	}
+	formEl := q.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if formEl == nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(formEl)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if form.Type == xep0004.Cancel {
+		if r.locked {
+			x.destroyRoom(ctx, r, nil)
+		}
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		return
+	}
+	wasPersistent := r.Config.Persistent
+	wasLocked := r.locked
+	if err := r.Config.ApplySubmitForm(form); err != nil {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	r.locked = false
+
+	switch {
+	case r.Config.Persistent:
+		err = x.mucRep.UpsertMucRoom(ctx, &r.Room)
+	case wasPersistent:
+		err = x.mucRep.DeleteMucRoom(ctx, r.JID)
+		r.reservations = make(map[string]string)
+	}
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	_ = x.router.Route(ctx, iq.ResultIQ())
+
+	if r.Config.MembersOnly {
+		for _, occ := range r.sortedOccupants() {
+			if r.occupantAffiliation(occ) == mucmodel.None {
+				x.removeOccupant(ctx, r, occ, membersOnlyRemovedStatus)
+			}
+		}
+	}
+	if !wasLocked {
+		x.notifyConfigChange(ctx, r)
+	}
+	x.destroyIfUnused(ctx, r)
+}
+
+func (x *Muc) notifyConfigChange(ctx context.Context, r *room) {
+	for _, o := range r.sortedOccupants() {
+		userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+		userEl.AppendElement(statusElement(configChangedStatus))
+
+		msg := xmpp.NewMessageType("", xmpp.GroupChatType)
+		msg.SetFromJID(r.jid)
+		msg.SetToJID(o.jid)
+		msg.AppendElement(userEl)
+		_ = x.router.Route(ctx, msg)
+	}
+}
+
+func (x *Muc) destroyRoom(ctx context.Context, r *room, destroy xmpp.XElement) {
+	for _, o := range r.sortedOccupants() {
+		p := xmpp.NewPresence(r.occupantJID(o.nick), o.jid, xmpp.UnavailableType)
+		userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+		item := xmpp.NewElementName("item")
+		item.SetAttribute("affiliation", mucmodel.None)
+		item.SetAttribute("role", noneRole)
+		userEl.AppendElement(item)
+		if destroy != nil {
+			userEl.AppendElement(destroy)
+		}
+		p.AppendElement(userEl)
+		_ = x.router.Route(ctx, p)
+	}
+	delete(x.rooms, r.JID)
+	if err := x.mucRep.DeleteMucRoom(ctx, r.JID); err != nil {
+		log.Error(err)
+	}
+	log.Infof("muc: destroyed room %s", r.JID)
+}
+
+func (x *Muc) processRegisterIQ(ctx context.Context, r *room, iq *xmpp.IQ, q xmpp.XElement) {
+	bareJID := iq.FromJID().ToBareJID().String()
+	aff := r.Affiliation(bareJID)
+	if aff == mucmodel.Outcast {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		query := xmpp.NewElementNamespace("query", registerNamespace)
+		if nick, ok := r.reservations[bareJID]; ok {
+			query.AppendElement(xmpp.NewElementName("registered"))
+			query.AppendElement(xmpp.NewElementName("username").SetText(nick))
+		} else {
+			form := &xep0004.DataForm{
+				Type:  xep0004.Form,
+				Title: "Nickname registration",
+				Fields: xep0004.Fields{
+					{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{registerFormType}},
+					{Var: registerRoomNickVar, Type: xep0004.TextSingle, Label: "Desired nickname", Required: true},
+				},
+			}
+			query.AppendElement(xmpp.NewElementName("instructions").SetText(registerInstructions))
+			query.AppendElement(form.Element())
+		}
+		res := iq.ResultIQ()
+		res.AppendElement(query)
+		_ = x.router.Route(ctx, res)
+		return
+	}
+	if q.Elements().Child("remove") != nil {
+		if err := x.mucRep.DeleteMucNickReservation(ctx, r.JID, bareJID); err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		delete(r.reservations, bareJID)
+		_ = x.router.Route(ctx, iq.ResultIQ())
+		return
+	}
+	formEl := q.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if formEl == nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(formEl)
+	if err != nil || form.Type != xep0004.Submit {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	nick := form.Fields.ValueForFieldOfType(registerRoomNickVar, xep0004.TextSingle)
+	if len(nick) == 0 {
+		nick = form.Fields.ValueForField(registerRoomNickVar)
+	}
+	if len(nick) == 0 {
+		_ = x.router.Route(ctx, iq.NotAcceptableError())
+		return
+	}
+	if r.Config.MembersOnly && aff == mucmodel.None {
+		_ = x.router.Route(ctx, iq.NotAllowedError())
+		return
+	}
+	if !x.isNickAvailable(r, nick, bareJID) {
+		_ = x.router.Route(ctx, iq.ConflictError())
+		return
+	}
+	if aff == mucmodel.None {
+		r.SetAffiliation(bareJID, mucmodel.Member)
+	}
+	if err := x.storeReservation(ctx, r, &mucmodel.NickReservation{RoomJID: r.JID, JID: bareJID, Nick: nick}); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	r.reservations[bareJID] = nick
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *Muc) storeReservation(ctx context.Context, r *room, reservation *mucmodel.NickReservation) error {
+	if r.Config.Persistent {
+		if err := x.mucRep.UpsertMucRoom(ctx, &r.Room); err != nil {
+			return err
+		}
+	}
+	return x.mucRep.UpsertMucNickReservation(ctx, reservation)
+}
+
+func sortedStrings(ss []string) []string {
+	sort.Strings(ss)
+	return ss
+}
+
+func isAffiliation(s string) bool {
+	switch s {
+	case mucmodel.Owner, mucmodel.Admin, mucmodel.Member, mucmodel.Outcast, mucmodel.None:
+		return true
+	}
+	return false
+}
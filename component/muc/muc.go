@@ -0,0 +1,567 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const (
+	mucNamespace      = "http://jabber.org/protocol/muc"
+	mucUserNamespace  = "http://jabber.org/protocol/muc#user"
+	mucAdminNamespace = "http://jabber.org/protocol/muc#admin"
+	mucOwnerNamespace = "http://jabber.org/protocol/muc#owner"
+	registerNamespace = "jabber:iq:register"
+	delayNamespace    = "urn:xmpp:delay"
+)
+
+const stampLayout = "2006-01-02T15:04:05Z"
+
+const (
+	nonAnonymousStatus       = "100"
+	configChangedStatus      = "104"
+	selfPresenceStatus       = "110"
+	roomCreatedStatus        = "201"
+	bannedStatus             = "301"
+	nickChangedStatus        = "303"
+	kickedStatus             = "307"
+	affiliationRemovedStatus = "321"
+	membersOnlyRemovedStatus = "322"
+)
+
+// Muc represents a Multi-User Chat (XEP-0045) component.
+type Muc struct {
+	cfg      Config
+	disco    *xep0030.DiscoInfo
+	router   router.Router
+	mucRep   repository.Muc
+	runQueue *runqueue.RunQueue
+	mu       sync.RWMutex
+	rooms    map[string]*room
+}
+
+// New returns a multi-user chat component instance.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, mucRep repository.Muc) *Muc {
+	x := &Muc{
+		cfg:      *config,
+		disco:    disco,
+		router:   router,
+		mucRep:   mucRep,
+		runQueue: runqueue.New("muc"),
+		rooms:    make(map[string]*room),
+	}
+	if err := x.loadRooms(context.Background()); err != nil {
+		log.Error(err)
+	}
+	if disco != nil {
+		disco.RegisterProvider(x.cfg.Host, x)
+		disco.RegisterServerItem(xep0030.Item{Jid: x.cfg.Host, Name: x.cfg.Name})
+	}
+	return x
+}
+
+// Host returns multi-user chat service domain.
+func (x *Muc) Host() string {
+	return x.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the multi-user chat service or any of its rooms.
+func (x *Muc) ProcessStanza(ctx context.Context, stanza xmpp.Stanza) {
+	x.runQueue.Run(func() {
+		x.processStanza(ctx, stanza)
+	})
+}
+
+// Shutdown shuts down multi-user chat component.
+func (x *Muc) Shutdown() error {
+	if x.disco != nil {
+		x.disco.UnregisterServerItem(xep0030.Item{Jid: x.cfg.Host, Name: x.cfg.Name})
+		x.disco.UnregisterProvider(x.cfg.Host)
+	}
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Muc) loadRooms(ctx context.Context) error {
+	rooms, err := x.mucRep.FetchMucRooms(ctx, x.cfg.Host)
+	if err != nil {
+		return err
+	}
+	for i := range rooms {
+		roomJID, err := jid.NewWithString(rooms[i].JID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		r := newRoom(&rooms[i], roomJID)
+		reservations, err := x.mucRep.FetchMucNickReservations(ctx, rooms[i].JID)
+		if err != nil {
+			return err
+		}
+		for _, res := range reservations {
+			r.reservations[res.JID] = res.Nick
+		}
+		x.rooms[rooms[i].JID] = r
+	}
+	return nil
+}
+
+func (x *Muc) processStanza(ctx context.Context, stanza xmpp.Stanza) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	switch stanza := stanza.(type) {
+	case *xmpp.Presence:
+		x.processPresence(ctx, stanza)
+	case *xmpp.Message:
+		x.processMessage(ctx, stanza)
+	case *xmpp.IQ:
+		x.processIQ(ctx, stanza)
+	}
+}
+
+func (x *Muc) processPresence(ctx context.Context, presence *xmpp.Presence) {
+	toJID := presence.ToJID()
+	fromJID := presence.FromJID()
+	if toJID.IsServer() {
+		return
+	}
+	r := x.rooms[toJID.ToBareJID().String()]
+
+	switch {
+	case presence.IsUnavailable():
+		if r == nil {
+			return
+		}
+		if occ := r.occupantByJID(fromJID); occ != nil {
+			x.leaveRoom(ctx, r, occ, presence.Status())
+		}
+		return
+
+	case presence.IsError():
+		// occupant client is not reachable anymore
+		if r == nil {
+			return
+		}
+		if occ := r.occupantByJID(fromJID); occ != nil {
+			x.leaveRoom(ctx, r, occ, "")
+		}
+		return
+
+	case !presence.IsAvailable():
+		return
+	}
+	nick := toJID.Resource()
+	if len(nick) == 0 {
+		_ = x.router.Route(ctx, presence.JidMalformedError())
+		return
+	}
+	if r == nil {
+		x.createRoom(ctx, presence)
+		return
+	}
+	occ := r.occupantByJID(fromJID)
+	switch {
+	case occ == nil:
+		x.joinRoom(ctx, r, presence)
+	case occ.nick == nick:
+		occ.presence = presence
+		x.broadcastPresence(ctx, r, occ, xmpp.AvailableType)
+	default:
+		x.changeNick(ctx, r, occ, presence)
+	}
+}
+
+func (x *Muc) createRoom(ctx context.Context, presence *xmpp.Presence) {
+	toJID := presence.ToJID()
+	fromJID := presence.FromJID()
+
+	r := newRoom(&mucmodel.Room{
+		JID: toJID.ToBareJID().String(),
+		Config: mucmodel.RoomConfig{
+			Public:        true,
+			HistoryLength: x.cfg.HistorySize,
+		},
+	}, toJID.ToBareJID())
+	r.SetAffiliation(fromJID.ToBareJID().String(), mucmodel.Owner)
+
+	// legacy groupchat clients get an instant room
+	r.locked = presence.Elements().ChildNamespace("x", mucNamespace) != nil
+	x.rooms[r.JID] = r
+
+	occ := &occupant{
+		nick:     toJID.Resource(),
+		jid:      fromJID,
+		role:     moderatorRole,
+		presence: presence,
+	}
+	r.occupants[occ.nick] = occ
+
+	var statusCodes []string
+	if r.locked {
+		statusCodes = append(statusCodes, roomCreatedStatus)
+	}
+	_ = x.router.Route(ctx, r.occupantPresence(occ, occ, xmpp.AvailableType, statusCodes...))
+	x.sendSubject(ctx, r, occ)
+
+	log.Infof("muc: created room %s (owner: %s)", r.JID, fromJID.ToBareJID().String())
+}
+
+func (x *Muc) joinRoom(ctx context.Context, r *room, presence *xmpp.Presence) {
+	fromJID := presence.FromJID()
+	nick := presence.ToJID().Resource()
+	bareJID := fromJID.ToBareJID().String()
+
+	if r.locked {
+		_ = x.router.Route(ctx, presence.ItemNotFoundError())
+		return
+	}
+	aff := r.Affiliation(bareJID)
+	if aff == mucmodel.Outcast {
+		_ = x.router.Route(ctx, presence.ForbiddenError())
+		return
+	}
+	if r.Config.MembersOnly && aff == mucmodel.None {
+		_ = x.router.Route(ctx, presence.RegistrationRequiredError())
+		return
+	}
+	mucEl := presence.Elements().ChildNamespace("x", mucNamespace)
+	if r.Config.PasswordProtected {
+		var password string
+		if mucEl != nil {
+			if pwd := mucEl.Elements().Child("password"); pwd != nil {
+				password = pwd.Text()
+			}
+		}
+		if password != r.Config.Password {
+			_ = x.router.Route(ctx, presence.NotAuthorizedError())
+			return
+		}
+	}
+	if !x.isNickAvailable(r, nick, bareJID) {
+		_ = x.router.Route(ctx, presence.ConflictError())
+		return
+	}
+	if r.Config.MaxOccupants > 0 && len(r.occupants) >= r.Config.MaxOccupants && aff != mucmodel.Owner && aff != mucmodel.Admin {
+		_ = x.router.Route(ctx, presence.ServiceUnavailableError())
+		return
+	}
+	occ := &occupant{
+		nick:     nick,
+		jid:      fromJID,
+		role:     r.defaultRole(aff),
+		presence: presence,
+	}
+	// send current occupants presences
+	for _, o := range r.sortedOccupants() {
+		_ = x.router.Route(ctx, r.occupantPresence(o, occ, xmpp.AvailableType))
+	}
+	r.occupants[nick] = occ
+	x.broadcastPresence(ctx, r, occ, xmpp.AvailableType)
+
+	var history xmpp.XElement
+	if mucEl != nil {
+		history = mucEl.Elements().Child("history")
+	}
+	x.sendHistory(ctx, r, occ, history)
+	x.sendSubject(ctx, r, occ)
+}
+
+func (x *Muc) changeNick(ctx context.Context, r *room, occ *occupant, presence *xmpp.Presence) {
+	newNick := presence.ToJID().Resource()
+	if !x.isNickAvailable(r, newNick, occ.jid.ToBareJID().String()) {
+		_ = x.router.Route(ctx, presence.ConflictError())
+		return
+	}
+	for _, o := range r.sortedOccupants() {
+		p := r.occupantPresence(occ, o, xmpp.UnavailableType, nickChangedStatus)
+		p.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item").(*xmpp.Element).SetAttribute("nick", newNick)
+		_ = x.router.Route(ctx, p)
+	}
+	delete(r.occupants, occ.nick)
+	occ.nick = newNick
+	occ.presence = presence
+	r.occupants[newNick] = occ
+	x.broadcastPresence(ctx, r, occ, xmpp.AvailableType)
+}
+
+func (x *Muc) leaveRoom(ctx context.Context, r *room, occ *occupant, status string) {
+	occ.role = noneRole
+	for _, o := range r.sortedOccupants() {
+		p := r.occupantPresence(occ, o, xmpp.UnavailableType)
+		if len(status) > 0 {
+			st := xmpp.NewElementName("status")
+			st.SetText(status)
+			p.AppendElement(st)
+		}
+		_ = x.router.Route(ctx, p)
+	}
+	delete(r.occupants, occ.nick)
+	x.destroyIfUnused(ctx, r)
+}
+
+// removeOccupant removes an occupant from room as a consequence of a role or affiliation change.
+func (x *Muc) removeOccupant(ctx context.Context, r *room, occ *occupant, statusCode string) {
+	occ.role = noneRole
+	for _, o := range r.sortedOccupants() {
+		_ = x.router.Route(ctx, r.occupantPresence(occ, o, xmpp.UnavailableType, statusCode))
+	}
+	delete(r.occupants, occ.nick)
+}
+
+func (x *Muc) destroyIfUnused(ctx context.Context, r *room) {
+	if len(r.occupants) > 0 || (r.Config.Persistent && !r.locked) {
+		return
+	}
+	delete(x.rooms, r.JID)
+	if len(r.reservations) > 0 {
+		if err := x.mucRep.DeleteMucRoom(ctx, r.JID); err != nil {
+			log.Error(err)
+		}
+	}
+	log.Infof("muc: destroyed room %s", r.JID)
+}
+
+func (x *Muc) broadcastPresence(ctx context.Context, r *room, occ *occupant, presenceType string, statusCodes ...string) {
+	for _, o := range r.sortedOccupants() {
+		_ = x.router.Route(ctx, r.occupantPresence(occ, o, presenceType, statusCodes...))
+	}
+}
+
+func (x *Muc) isNickAvailable(r *room, nick, bareJID string) bool {
+	if o := r.occupants[nick]; o != nil && o.jid.ToBareJID().String() != bareJID {
+		return false
+	}
+	owner := r.reservationOwner(nick)
+	return len(owner) == 0 || owner == bareJID
+}
+
+func (x *Muc) sendHistory(ctx context.Context, r *room, occ *occupant, history xmpp.XElement) {
+	maxStanzas := -1
+	maxChars := -1
+	var since time.Time
+	if history != nil {
+		if v, err := strconv.Atoi(history.Attributes().Get("maxstanzas")); err == nil {
+			maxStanzas = v
+		}
+		if v, err := strconv.Atoi(history.Attributes().Get("maxchars")); err == nil {
+			maxChars = v
+		}
+		if v, err := strconv.Atoi(history.Attributes().Get("seconds")); err == nil {
+			since = time.Now().Add(-time.Duration(v) * time.Second)
+		}
+		if v, err := time.Parse(time.RFC3339, history.Attributes().Get("since")); err == nil && v.After(since) {
+			since = v
+		}
+	}
+	var messages []*xmpp.Message
+	var chars int
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if maxStanzas >= 0 && len(messages) >= maxStanzas {
+			break
+		}
+		h := r.history[i]
+		if h.stamp.Before(since) {
+			break
+		}
+		msg, _ := xmpp.NewMessageFromElement(h.message, h.message.FromJID(), occ.jid)
+		delay := xmpp.NewElementNamespace("delay", delayNamespace)
+		delay.SetAttribute("from", r.JID)
+		delay.SetAttribute("stamp", h.stamp.UTC().Format(stampLayout))
+		msg.AppendElement(delay)
+		if maxChars >= 0 {
+			chars += len(msg.String())
+			if chars > maxChars {
+				break
+			}
+		}
+		messages = append(messages, msg)
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		_ = x.router.Route(ctx, messages[i])
+	}
+}
+
+func (x *Muc) sendSubject(ctx context.Context, r *room, occ *occupant) {
+	msg := xmpp.NewMessageType("", xmpp.GroupChatType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(occ.jid)
+	msg.AppendElement(xmpp.NewElementName("subject").SetText(r.Subject))
+	_ = x.router.Route(ctx, msg)
+}
+
+func (x *Muc) processMessage(ctx context.Context, message *xmpp.Message) {
+	toJID := message.ToJID()
+	if toJID.IsServer() || message.IsError() {
+		return
+	}
+	r := x.rooms[toJID.ToBareJID().String()]
+	if r == nil || r.locked {
+		_ = x.router.Route(ctx, message.ItemNotFoundError())
+		return
+	}
+	occ := r.occupantByJID(message.FromJID())
+	switch {
+	case toJID.IsFullWithUser():
+		x.sendPrivateMessage(ctx, r, occ, message)
+	case message.IsGroupChat():
+		x.sendGroupChatMessage(ctx, r, occ, message)
+	default:
+		if userEl := message.Elements().ChildNamespace("x", mucUserNamespace); userEl != nil {
+			x.processUserMessage(ctx, r, occ, message, userEl)
+			return
+		}
+		_ = x.router.Route(ctx, message.BadRequestError())
+	}
+}
+
+func (x *Muc) sendGroupChatMessage(ctx context.Context, r *room, occ *occupant, message *xmpp.Message) {
+	if occ == nil {
+		_ = x.router.Route(ctx, message.NotAcceptableError())
+		return
+	}
+	if occ.role == visitorRole {
+		_ = x.router.Route(ctx, message.ForbiddenError())
+		return
+	}
+	subject := message.Elements().Child("subject")
+	isSubjectChange := subject != nil && message.Elements().Child("body") == nil
+	if isSubjectChange {
+		if occ.role != moderatorRole && !r.Config.ChangeSubject {
+			_ = x.router.Route(ctx, message.ForbiddenError())
+			return
+		}
+		r.Subject = subject.Text()
+		if r.Config.Persistent {
+			if err := x.mucRep.UpsertMucRoom(ctx, &r.Room); err != nil {
+				log.Error(err)
+				_ = x.router.Route(ctx, message.InternalServerError())
+				return
+			}
+		}
+	}
+	fromJID := r.occupantJID(occ.nick)
+	for _, o := range r.sortedOccupants() {
+		msg, _ := xmpp.NewMessageFromElement(message, fromJID, o.jid)
+		_ = x.router.Route(ctx, msg)
+	}
+	if !isSubjectChange {
+		msg, _ := xmpp.NewMessageFromElement(message, fromJID, r.jid)
+		r.appendHistory(msg, r.historyLength(x.cfg.HistorySize))
+	}
+}
+
+func (x *Muc) sendPrivateMessage(ctx context.Context, r *room, occ *occupant, message *xmpp.Message) {
+	if occ == nil {
+		_ = x.router.Route(ctx, message.NotAcceptableError())
+		return
+	}
+	if message.IsGroupChat() {
+		_ = x.router.Route(ctx, message.BadRequestError())
+		return
+	}
+	target := r.occupants[message.ToJID().Resource()]
+	if target == nil {
+		_ = x.router.Route(ctx, message.ItemNotFoundError())
+		return
+	}
+	msg, _ := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), target.jid)
+	msg.AppendElement(xmpp.NewElementNamespace("x", mucUserNamespace))
+	_ = x.router.Route(ctx, msg)
+}
+
+func (x *Muc) processUserMessage(ctx context.Context, r *room, occ *occupant, message *xmpp.Message, userEl xmpp.XElement) {
+	if decline := userEl.Elements().Child("decline"); decline != nil {
+		x.forwardDecline(ctx, r, message, decline)
+		return
+	}
+	invites := userEl.Elements().Children("invite")
+	if len(invites) == 0 {
+		_ = x.router.Route(ctx, message.BadRequestError())
+		return
+	}
+	if occ == nil {
+		_ = x.router.Route(ctx, message.NotAcceptableError())
+		return
+	}
+	aff := r.occupantAffiliation(occ)
+	if !r.Config.AllowInvites && aff != mucmodel.Owner && aff != mucmodel.Admin {
+		_ = x.router.Route(ctx, message.ForbiddenError())
+		return
+	}
+	var inviteeJIDs []*jid.JID
+	for _, invite := range invites {
+		inviteeJID, err := jid.NewWithString(invite.Attributes().Get("to"), false)
+		if err != nil {
+			_ = x.router.Route(ctx, message.JidMalformedError())
+			return
+		}
+		inviteeJIDs = append(inviteeJIDs, inviteeJID)
+	}
+	var updated bool
+	for i, invite := range invites {
+		inviteeJID := inviteeJIDs[i]
+		if r.Config.MembersOnly && r.Affiliation(inviteeJID.ToBareJID().String()) == mucmodel.None {
+			r.SetAffiliation(inviteeJID.ToBareJID().String(), mucmodel.Member)
+			updated = true
+		}
+		inv := xmpp.NewElementName("invite")
+		inv.SetAttribute("from", occ.jid.String())
+		if reason := invite.Elements().Child("reason"); reason != nil {
+			inv.AppendElement(reason)
+		}
+		userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+		userEl.AppendElement(inv)
+		if r.Config.PasswordProtected {
+			userEl.AppendElement(xmpp.NewElementName("password").SetText(r.Config.Password))
+		}
+		msg := xmpp.NewMessageType(message.ID(), xmpp.NormalType)
+		msg.SetFromJID(r.jid)
+		msg.SetToJID(inviteeJID)
+		msg.AppendElement(userEl)
+		_ = x.router.Route(ctx, msg)
+	}
+	if updated && r.Config.Persistent {
+		if err := x.mucRep.UpsertMucRoom(ctx, &r.Room); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (x *Muc) forwardDecline(ctx context.Context, r *room, message *xmpp.Message, decline xmpp.XElement) {
+	toJID, err := jid.NewWithString(decline.Attributes().Get("to"), false)
+	if err != nil {
+		_ = x.router.Route(ctx, message.JidMalformedError())
+		return
+	}
+	dec := xmpp.NewElementName("decline")
+	dec.SetAttribute("from", message.FromJID().ToBareJID().String())
+	if reason := decline.Elements().Child("reason"); reason != nil {
+		dec.AppendElement(reason)
+	}
+	userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+	userEl.AppendElement(dec)
+
+	msg := xmpp.NewMessageType(message.ID(), xmpp.NormalType)
+	msg.SetFromJID(r.jid)
+	msg.SetToJID(toJID)
+	msg.AppendElement(userEl)
+	_ = x.router.Route(ctx, msg)
+}
@@ -0,0 +1,496 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const roomJID = "coven@conference.jackal.im"
+
+func TestMuc_CreateAndJoin(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	j3, _ := jid.New("juliet", "jackal.im", "chamber", true)
+	stm1, stm2, stm3 := bindStream(r, j1), bindStream(r, j2), bindStream(r, j3)
+
+	x := New(&Config{Host: "conference.jackal.im", Name: "Chatrooms", HistorySize: 20}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	// create room
+	x.ProcessStanza(context.Background(), joinPresence(j1, "crone1", nil))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, roomJID+"/crone1", elem.From())
+	require.ElementsMatch(t, []string{selfPresenceStatus, roomCreatedStatus}, statusCodes(elem))
+	require.Equal(t, mucmodel.Owner, mucItem(elem).Attributes().Get("affiliation"))
+	require.Equal(t, moderatorRole, mucItem(elem).Attributes().Get("role"))
+
+	elem = stm1.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// room is locked
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// instant room
+	x.ProcessStanza(context.Background(), ownerIQ(j1, &xep0004.DataForm{Type: xep0004.Submit}))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// join room
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+
+	elem = stm2.ReceiveElement() // owner presence
+	require.Equal(t, roomJID+"/crone1", elem.From())
+	require.Equal(t, "", mucItem(elem).Attributes().Get("jid")) // semi-anonymous room
+
+	elem = stm2.ReceiveElement() // self presence
+	require.Equal(t, roomJID+"/wiccarocks", elem.From())
+	require.Equal(t, []string{selfPresenceStatus}, statusCodes(elem))
+	require.Equal(t, participantRole, mucItem(elem).Attributes().Get("role"))
+
+	elem = stm2.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	elem = stm1.ReceiveElement() // new occupant presence
+	require.Equal(t, roomJID+"/wiccarocks", elem.From())
+	require.Equal(t, j2.String(), mucItem(elem).Attributes().Get("jid"))
+
+	// nick conflict
+	x.ProcessStanza(context.Background(), joinPresence(j3, "wiccarocks", nil))
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// groupchat message
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(roomBareJID())
+	msg.AppendElement(xmpp.NewElementName("body").SetText("Harpier cries: 'tis time, 'tis time."))
+	x.ProcessStanza(context.Background(), msg)
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, roomJID+"/wiccarocks", elem.From())
+	require.Equal(t, j1.String(), elem.To())
+	elem = stm2.ReceiveElement()
+	require.Equal(t, roomJID+"/wiccarocks", elem.From())
+
+	// join receiving discussion history
+	hist := xmpp.NewElementName("history")
+	hist.SetAttribute("maxstanzas", "10")
+	x.ProcessStanza(context.Background(), joinPresence(j3, "hag66", hist))
+
+	require.Equal(t, roomJID+"/crone1", stm3.ReceiveElement().From())
+	require.Equal(t, roomJID+"/wiccarocks", stm3.ReceiveElement().From())
+	require.Equal(t, roomJID+"/hag66", stm3.ReceiveElement().From())
+
+	elem = stm3.ReceiveElement()
+	require.Equal(t, "Harpier cries: 'tis time, 'tis time.", elem.Elements().Child("body").Text())
+	require.NotNil(t, elem.Elements().ChildNamespace("delay", delayNamespace))
+
+	elem = stm3.ReceiveElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	_ = stm1.ReceiveElement()
+	_ = stm2.ReceiveElement()
+
+	// leave room
+	x.ProcessStanza(context.Background(), xmpp.NewPresence(j3, occupantJID("hag66"), xmpp.UnavailableType))
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{selfPresenceStatus}, statusCodes(elem))
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, roomJID+"/hag66", elem.From())
+}
+
+func TestMuc_Moderation(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	stm1, stm2 := bindStream(r, j1), bindStream(r, j2)
+
+	x := New(&Config{Host: "conference.jackal.im", HistorySize: 20}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	createInstantRoom(x, j1, stm1)
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	for i := 0; i < 3; i++ {
+		_ = stm2.ReceiveElement()
+	}
+	_ = stm1.ReceiveElement()
+
+	// only moderators can kick
+	x.ProcessStanza(context.Background(), adminIQ(j2, "nick", "crone1", "role", noneRole))
+	elem := stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// kick
+	x.ProcessStanza(context.Background(), adminIQ(j1, "nick", "wiccarocks", "role", noneRole))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{kickedStatus}, statusCodes(elem))
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.ElementsMatch(t, []string{kickedStatus, selfPresenceStatus}, statusCodes(elem))
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// ban
+	x.ProcessStanza(context.Background(), adminIQ(j1, "jid", j2.ToBareJID().String(), "affiliation", mucmodel.Outcast))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// last owner cannot be removed
+	x.ProcessStanza(context.Background(), adminIQ(j1, "jid", j1.ToBareJID().String(), "affiliation", mucmodel.Admin))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// outcast list
+	iq := adminIQ(j1, "", "", "affiliation", mucmodel.Outcast)
+	iq.SetType(xmpp.GetType)
+	x.ProcessStanza(context.Background(), iq)
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("query", mucAdminNamespace).Elements().Children("item")
+	require.Len(t, items, 1)
+	require.Equal(t, j2.ToBareJID().String(), items[0].Attributes().Get("jid"))
+}
+
+func TestMuc_AdminMultipleItems(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	stm1, stm2 := bindStream(r, j1), bindStream(r, j2)
+
+	x := New(&Config{Host: "conference.jackal.im", HistorySize: 20}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	createInstantRoom(x, j1, stm1)
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	for i := 0; i < 3; i++ {
+		_ = stm2.ReceiveElement()
+	}
+	_ = stm1.ReceiveElement()
+
+	// same target changed twice
+	iq := adminIQ(j1, "nick", "wiccarocks", "role", noneRole)
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("nick", "wiccarocks")
+	item.SetAttribute("role", participantRole)
+	iq.Elements().ChildNamespace("query", mucAdminNamespace).(*xmpp.Element).AppendElement(item)
+
+	x.ProcessStanza(context.Background(), iq)
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// ban and role change over an already removed occupant
+	iq = adminIQ(j1, "jid", j2.ToBareJID().String(), "affiliation", mucmodel.Outcast)
+	item = xmpp.NewElementName("item")
+	item.SetAttribute("nick", "wiccarocks")
+	item.SetAttribute("role", visitorRole)
+	iq.Elements().ChildNamespace("query", mucAdminNamespace).(*xmpp.Element).AppendElement(item)
+
+	x.ProcessStanza(context.Background(), iq)
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{bannedStatus}, statusCodes(elem))
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// room keeps processing requests
+	x.ProcessStanza(context.Background(), adminIQ(j1, "nick", "crone1", "role", visitorRole))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+}
+
+func TestMuc_ProtectedRooms(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	stm1, stm2 := bindStream(r, j1), bindStream(r, j2)
+
+	x := New(&Config{Host: "conference.jackal.im", HistorySize: 20}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	createInstantRoom(x, j1, stm1)
+
+	// invalid configuration
+	x.ProcessStanza(context.Background(), ownerIQ(j1, configForm(map[string]string{
+		"muc#roomconfig_passwordprotectedroom": "1",
+	})))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// only owners can configure room
+	x.ProcessStanza(context.Background(), ownerIQ(j2, configForm(nil)))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessStanza(context.Background(), ownerIQ(j1, configForm(map[string]string{
+		"muc#roomconfig_passwordprotectedroom": "1",
+		"muc#roomconfig_roomsecret":            "cauldronburn",
+	})))
+	elem = stm1.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	elem = stm1.ReceiveElement()
+	require.Equal(t, []string{configChangedStatus}, statusCodes(elem))
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrNotAuthorized.Error(), elem.Error().Elements().All()[0].Name())
+
+	// members-only
+	x.ProcessStanza(context.Background(), ownerIQ(j1, configForm(map[string]string{
+		"muc#roomconfig_passwordprotectedroom": "0",
+		"muc#roomconfig_membersonly":           "1",
+	})))
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+	_ = stm1.ReceiveElement()
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.ErrRegistrationRequired.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invitation grants membership
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(roomBareJID())
+	invite := xmpp.NewElementName("invite")
+	invite.SetAttribute("to", j2.ToBareJID().String())
+	userEl := xmpp.NewElementNamespace("x", mucUserNamespace)
+	userEl.AppendElement(invite)
+	msg.AppendElement(userEl)
+	x.ProcessStanza(context.Background(), msg)
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, roomJID, elem.From())
+	inv := elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("invite")
+	require.NotNil(t, inv)
+	require.Equal(t, j1.String(), inv.Attributes().Get("from"))
+
+	x.ProcessStanza(context.Background(), joinPresence(j2, "wiccarocks", nil))
+	require.Equal(t, roomJID+"/crone1", stm2.ReceiveElement().From())
+	require.Equal(t, roomJID+"/wiccarocks", stm2.ReceiveElement().From())
+}
+
+func TestMuc_PersistentRooms(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	stm1, stm2 := bindStream(r, j1), bindStream(r, j2)
+
+	x := New(&Config{Host: "conference.jackal.im", HistorySize: 20}, nil, r, rep)
+
+	createInstantRoom(x, j1, stm1)
+
+	x.ProcessStanza(context.Background(), ownerIQ(j1, configForm(map[string]string{
+		"muc#roomconfig_persistentroom": "1",
+		"muc#roomconfig_roomname":       "The Coven",
+	})))
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+	_ = stm1.ReceiveElement()
+
+	// reserve nick
+	x.ProcessStanza(context.Background(), registerIQ(j2, "wiccarocks"))
+	require.Equal(t, xmpp.ResultType, stm2.ReceiveElement().Type())
+
+	x.ProcessStanza(context.Background(), registerIQ(j1, "wiccarocks"))
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	room, _ := rep.FetchMucRoom(context.Background(), roomJID)
+	require.NotNil(t, room)
+	require.Equal(t, "The Coven", room.Config.Name)
+	require.Equal(t, mucmodel.Member, room.Affiliation(j2.ToBareJID().String()))
+
+	// room survives its last occupant and service restarts
+	x.ProcessStanza(context.Background(), xmpp.NewPresence(j1, occupantJID("crone1"), xmpp.UnavailableType))
+	_ = stm1.ReceiveElement()
+	_ = x.Shutdown()
+
+	x = New(&Config{Host: "conference.jackal.im", HistorySize: 20}, nil, r, rep)
+
+	features, sErr := x.Features(context.Background(), roomBareJID(), j2, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, "muc_persistent")
+
+	identities := x.Identities(context.Background(), roomBareJID(), j2, roomUserItemNode)
+	require.Len(t, identities, 1)
+	require.Equal(t, "wiccarocks", identities[0].Name)
+
+	serviceJID, _ := jid.NewWithString("conference.jackal.im", true)
+	items, _ := x.Items(context.Background(), serviceJID, j2, "")
+	require.Len(t, items, 1)
+	require.Equal(t, "The Coven", items[0].Name)
+
+	// destroy room
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(roomBareJID())
+	q := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	q.AppendElement(xmpp.NewElementName("destroy"))
+	iq.AppendElement(q)
+	x.ProcessStanza(context.Background(), iq)
+	require.Equal(t, xmpp.ResultType, stm1.ReceiveElement().Type())
+
+	room, _ = rep.FetchMucRoom(context.Background(), roomJID)
+	require.Nil(t, room)
+	reservations, _ := rep.FetchMucNickReservations(context.Background(), roomJID)
+	require.Len(t, reservations, 0)
+
+	_ = x.Shutdown()
+}
+
+func createInstantRoom(x *Muc, ownerJID *jid.JID, stm *stream.MockC2S) {
+	x.ProcessStanza(context.Background(), joinPresence(ownerJID, "crone1", nil))
+	_ = stm.ReceiveElement()
+	_ = stm.ReceiveElement()
+	x.ProcessStanza(context.Background(), ownerIQ(ownerJID, &xep0004.DataForm{Type: xep0004.Submit}))
+	_ = stm.ReceiveElement()
+}
+
+func joinPresence(fromJID *jid.JID, nick string, history xmpp.XElement) *xmpp.Presence {
+	p := xmpp.NewPresence(fromJID, occupantJID(nick), xmpp.AvailableType)
+	mucEl := xmpp.NewElementNamespace("x", mucNamespace)
+	if history != nil {
+		mucEl.AppendElement(history)
+	}
+	p.AppendElement(mucEl)
+	return p
+}
+
+func ownerIQ(fromJID *jid.JID, form *xep0004.DataForm) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(roomBareJID())
+	q := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	q.AppendElement(form.Element())
+	iq.AppendElement(q)
+	return iq
+}
+
+func adminIQ(fromJID *jid.JID, targetAttr, target, changeAttr, change string) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(roomBareJID())
+	item := xmpp.NewElementName("item")
+	if len(targetAttr) > 0 {
+		item.SetAttribute(targetAttr, target)
+	}
+	item.SetAttribute(changeAttr, change)
+	q := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	q.AppendElement(item)
+	iq.AppendElement(q)
+	return iq
+}
+
+func registerIQ(fromJID *jid.JID, nick string) *xmpp.IQ {
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{registerFormType}},
+			{Var: registerRoomNickVar, Values: []string{nick}},
+		},
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(roomBareJID())
+	q := xmpp.NewElementNamespace("query", registerNamespace)
+	q.AppendElement(form.Element())
+	iq.AppendElement(q)
+	return iq
+}
+
+func configForm(values map[string]string) *xep0004.DataForm {
+	form := &xep0004.DataForm{
+		Type:   xep0004.Submit,
+		Fields: xep0004.Fields{{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{mucmodel.RoomConfigFormType}}},
+	}
+	for k, v := range values {
+		form.Fields = append(form.Fields, xep0004.Field{Var: k, Values: []string{v}})
+	}
+	return form
+}
+
+func statusCodes(elem xmpp.XElement) []string {
+	var codes []string
+	userEl := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	if userEl == nil {
+		return nil
+	}
+	for _, st := range userEl.Elements().Children("status") {
+		codes = append(codes, st.Attributes().Get("code"))
+	}
+	return codes
+}
+
+func mucItem(elem xmpp.XElement) xmpp.XElement {
+	return elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item")
+}
+
+func roomBareJID() *jid.JID {
+	j, _ := jid.NewWithString(roomJID, true)
+	return j
+}
+
+func occupantJID(nick string) *jid.JID {
+	j, _ := jid.NewWithString(roomJID+"/"+nick, true)
+	return j
+}
+
+func bindStream(r router.Router, j *jid.JID) *stream.MockC2S {
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+	return stm
+}
+
+func setupTest(domain string) (router.Router, repository.Muc) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, memorystorage.NewMuc()
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"sort"
+	"time"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const (
+	moderatorRole   = "moderator"
+	participantRole = "participant"
+	visitorRole     = "visitor"
+	noneRole        = "none"
+)
+
+type occupant struct {
+	nick     string
+	jid      *jid.JID
+	role     string
+	presence *xmpp.Presence
+}
+
+type historyMessage struct {
+	message *xmpp.Message
+	stamp   time.Time
+}
+
+type room struct {
+	mucmodel.Room
+	jid          *jid.JID
+	locked       bool
+	occupants    map[string]*occupant // by nick
+	history      []historyMessage
+	reservations map[string]string // nick by user bare JID
+}
+
+func newRoom(r *mucmodel.Room, roomJID *jid.JID) *room {
+	return &room{
+		Room:         *r,
+		jid:          roomJID,
+		occupants:    make(map[string]*occupant),
+		reservations: make(map[string]string),
+	}
+}
+
+func (r *room) occupantJID(nick string) *jid.JID {
+	j, _ := jid.New(r.jid.Node(), r.jid.Domain(), nick, true)
+	return j
+}
+
+func (r *room) occupantByJID(j *jid.JID) *occupant {
+	for _, occ := range r.occupants {
+		if occ.jid.String() == j.String() {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantsByBareJID(bareJID string) []*occupant {
+	var occs []*occupant
+	for _, occ := range r.occupants {
+		if occ.jid.ToBareJID().String() == bareJID {
+			occs = append(occs, occ)
+		}
+	}
+	return occs
+}
+
+func (r *room) sortedOccupants() []*occupant {
+	occs := make([]*occupant, 0, len(r.occupants))
+	for _, occ := range r.occupants {
+		occs = append(occs, occ)
+	}
+	sort.Slice(occs, func(i, j int) bool { return occs[i].nick < occs[j].nick })
+	return occs
+}
+
+func (r *room) reservationOwner(nick string) string {
+	for bareJID, n := range r.reservations {
+		if n == nick {
+			return bareJID
+		}
+	}
+	return ""
+}
+
+func (r *room) occupantAffiliation(occ *occupant) string {
+	return r.Affiliation(occ.jid.ToBareJID().String())
+}
+
+// defaultRole returns the role assigned to an occupant given its room affiliation.
+func (r *room) defaultRole(affiliation string) string {
+	switch affiliation {
+	case mucmodel.Owner, mucmodel.Admin:
+		return moderatorRole
+	case mucmodel.None:
+		if r.Config.Moderated {
+			return visitorRole
+		}
+	}
+	return participantRole
+}
+
+func (r *room) appendHistory(message *xmpp.Message, maxLength int) {
+	if maxLength <= 0 {
+		r.history = nil
+		return
+	}
+	r.history = append(r.history, historyMessage{message: message, stamp: time.Now()})
+	if len(r.history) > maxLength {
+		r.history = r.history[len(r.history)-maxLength:]
+	}
+}
+
+func (r *room) historyLength(serviceMax int) int {
+	if r.Config.HistoryLength < serviceMax {
+		return r.Config.HistoryLength
+	}
+	return serviceMax
+}
+
+// occupantPresence returns occupant presence as seen by receiver.
+func (r *room) occupantPresence(occ *occupant, receiver *occupant, presenceType string, statusCodes ...string) *xmpp.Presence {
+	p := xmpp.NewPresence(r.occupantJID(occ.nick), receiver.jid, presenceType)
+	if occ.presence != nil {
+		for _, el := range occ.presence.Elements().All() {
+			if el.Name() == "x" && (el.Namespace() == mucNamespace || el.Namespace() == mucUserNamespace) {
+				continue
+			}
+			p.AppendElement(el)
+		}
+	}
+	x := xmpp.NewElementNamespace("x", mucUserNamespace)
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("affiliation", r.occupantAffiliation(occ))
+	item.SetAttribute("role", occ.role)
+	if r.Config.NonAnonymous || receiver.role == moderatorRole {
+		item.SetAttribute("jid", occ.jid.String())
+	}
+	x.AppendElement(item)
+	codes := append([]string(nil), statusCodes...)
+	if receiver == occ {
+		codes = append(codes, selfPresenceStatus)
+		if r.Config.NonAnonymous {
+			codes = append(codes, nonAnonymousStatus)
+		}
+	}
+	for _, code := range codes {
+		x.AppendElement(statusElement(code))
+	}
+	p.AppendElement(x)
+	return p
+}
+
+func statusElement(code string) *xmpp.Element {
+	st := xmpp.NewElementName("status")
+	st.SetAttribute("code", code)
+	return st
+}
@@ -24,6 +24,12 @@ var (
 	// ErrHostUnknown represents 'host-unknown' stream error.
 	ErrHostUnknown = newStreamError("host-unknown")
 
+	// ErrHostGone represents 'host-gone' stream error.
+	ErrHostGone = newStreamError("host-gone")
+
+	// ErrConflict represents 'conflict' stream error.
+	ErrConflict = newStreamError("conflict")
+
 	// ErrInvalidFrom represents 'invalid-from' stream error.
 	ErrInvalidFrom = newStreamError("invalid-from")
 
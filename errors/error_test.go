@@ -21,6 +21,9 @@ func TestStreamError(t *testing.T) {
 	require.Equal(t, "host-unknown", ErrHostUnknown.Error())
 	require.Equal(t, "host-unknown", ErrHostUnknown.Element().Elements().All()[0].Name())
 
+	require.Equal(t, "conflict", ErrConflict.Error())
+	require.Equal(t, "conflict", ErrConflict.Element().Elements().All()[0].Name())
+
 	require.Equal(t, "invalid-from", ErrInvalidFrom.Error())
 	require.Equal(t, "invalid-from", ErrInvalidFrom.Element().Elements().All()[0].Name())
 
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// Message represents a user archived message.
+type Message struct {
+	// ID is the archive identifier assigned to the message, also used as its stanza-id.
+	ID string
+
+	Username string
+	Domain   string
+
+	// With contains the full JID of the conversation peer.
+	With string
+
+	Message *xmpp.Message
+	Stamp   time.Time
+}
+
+// BareWith returns the bare JID of the conversation peer.
+func (m *Message) BareWith() string {
+	j, err := jid.NewWithString(m.With, true)
+	if err != nil {
+		return m.With
+	}
+	return j.ToBareJID().String()
+}
+
+// FromBytes deserializes a Message entity from its binary representation.
+func (m *Message) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&m.ID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.Domain); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.With); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.Stamp); err != nil {
+		return err
+	}
+	msg, err := xmpp.NewMessageFromBytes(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = msg
+	return nil
+}
+
+// ToBytes converts a Message entity to its binary representation.
+func (m *Message) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&m.ID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.Domain); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.With); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.Stamp); err != nil {
+		return err
+	}
+	return m.Message.ToBytes(buf)
+}
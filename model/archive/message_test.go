@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Serialization(t *testing.T) {
+	from, _ := jid.NewWithString("romeo@jackal.im/garden", true)
+	to, _ := jid.NewWithString("juliet@jackal.im/balcony", true)
+
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Art thou not Romeo, and a Montague?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	m := Message{
+		ID:       "28482-98726-73623",
+		Username: "juliet",
+		Domain:   "jackal.im",
+		With:     "romeo@jackal.im/garden",
+		Message:  msg,
+		Stamp:    time.Date(2020, 3, 21, 13, 5, 0, 0, time.UTC),
+	}
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, m.ToBytes(buf))
+
+	var m2 Message
+	require.Nil(t, m2.FromBytes(buf))
+
+	require.Equal(t, m.ID, m2.ID)
+	require.Equal(t, m.Username, m2.Username)
+	require.Equal(t, m.Domain, m2.Domain)
+	require.Equal(t, m.With, m2.With)
+	require.True(t, m.Stamp.Equal(m2.Stamp))
+	require.Equal(t, m.Message.String(), m2.Message.String())
+	require.Equal(t, "romeo@jackal.im", m2.BareWith())
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+const (
+	// Always represents the 'always' default archiving mode.
+	Always = "always"
+
+	// Never represents the 'never' default archiving mode.
+	Never = "never"
+
+	// Roster represents the 'roster' default archiving mode.
+	Roster = "roster"
+)
+
+// Preferences represents a user archiving preferences.
+type Preferences struct {
+	// Default is the archiving mode applied to those JIDs not contained in Always and Never lists.
+	Default string
+
+	// Always contains the bare JIDs whose messages must always be archived.
+	Always []string
+
+	// Never contains the bare JIDs whose messages must never be archived.
+	Never []string
+}
+
+// IsValidMode returns whether or not mode is a valid default archiving mode.
+func IsValidMode(mode string) bool {
+	switch mode {
+	case Always, Never, Roster:
+		return true
+	}
+	return false
+}
+
+// FromBytes deserializes a Preferences entity from its binary representation.
+func (p *Preferences) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&p.Default); err != nil {
+		return err
+	}
+	if err := dec.Decode(&p.Always); err != nil {
+		return err
+	}
+	return dec.Decode(&p.Never)
+}
+
+// ToBytes converts a Preferences entity to its binary representation.
+func (p *Preferences) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&p.Default); err != nil {
+		return err
+	}
+	if err := enc.Encode(&p.Always); err != nil {
+		return err
+	}
+	return enc.Encode(&p.Never)
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferences_Serialization(t *testing.T) {
+	p := Preferences{
+		Default: Roster,
+		Always:  []string{"romeo@jackal.im"},
+		Never:   []string{"tybalt@jackal.im", "mercutio@jackal.im"},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, p.ToBytes(buf))
+
+	var p2 Preferences
+	require.Nil(t, p2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&p, &p2))
+}
+
+func TestPreferences_IsValidMode(t *testing.T) {
+	require.True(t, IsValidMode(Always))
+	require.True(t, IsValidMode(Never))
+	require.True(t, IsValidMode(Roster))
+	require.False(t, IsValidMode(""))
+	require.False(t, IsValidMode("sometimes"))
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"strings"
+	"time"
+)
+
+// Query represents an archive query filter along with its result set paging parameters.
+type Query struct {
+	// With filters messages exchanged with a given JID. Bare JIDs match every peer resource.
+	With string
+
+	// Start and End restrict the time interval messages were archived in, when non-zero.
+	Start time.Time
+	End   time.Time
+
+	// After and Before restrict results to those archived after or before a given message identifier.
+	After  string
+	Before string
+
+	// Last requests the last page of the result set, even if Before is empty.
+	Last bool
+
+	// Max limits the number of returned messages. A non-positive value means no limit.
+	Max int
+}
+
+// IsBackward tells whether or not the result set must be paged backwards from its end.
+func (q *Query) IsBackward() bool {
+	return q.Last || len(q.Before) > 0
+}
+
+// Matches tells whether or not an archived message satisfies query filter, paging parameters aside.
+func (q *Query) Matches(m *Message) bool {
+	if len(q.With) > 0 {
+		if strings.Contains(q.With, "/") {
+			if m.With != q.With {
+				return false
+			}
+		} else if m.BareWith() != q.With {
+			return false
+		}
+	}
+	if !q.Start.IsZero() && m.Stamp.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && m.Stamp.After(q.End) {
+		return false
+	}
+	return true
+}
+
+// Filter applies query to a chronologically ordered message slice, returning the requested page.
+// A nil slice is returned in case After or Before identifiers are not found.
+func (q *Query) Filter(messages []Message) []Message {
+	from, to := 0, len(messages)
+	if len(q.After) > 0 {
+		idx := indexOf(messages, q.After)
+		if idx == -1 {
+			return nil
+		}
+		from = idx + 1
+	}
+	if len(q.Before) > 0 {
+		idx := indexOf(messages, q.Before)
+		if idx == -1 {
+			return nil
+		}
+		to = idx
+	}
+	var matching []Message
+	for i := from; i < to; i++ {
+		if q.Matches(&messages[i]) {
+			matching = append(matching, messages[i])
+		}
+	}
+	if q.Max > 0 && len(matching) > q.Max {
+		if q.IsBackward() {
+			matching = matching[len(matching)-q.Max:]
+		} else {
+			matching = matching[:q.Max]
+		}
+	}
+	return matching
+}
+
+func indexOf(messages []Message, id string) int {
+	for i, m := range messages {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package archivemodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Filter(t *testing.T) {
+	now := time.Now()
+
+	var messages []Message
+	for i, with := range []string{"romeo@jackal.im/garden", "nurse@jackal.im/home", "romeo@jackal.im/orchard", "romeo@jackal.im/garden", "nurse@jackal.im/home"} {
+		messages = append(messages, Message{
+			ID:    string('a' + rune(i)),
+			With:  with,
+			Stamp: now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	ids := func(ms []Message) string {
+		var s string
+		for _, m := range ms {
+			s += m.ID
+		}
+		return s
+	}
+	require.Equal(t, "abcde", ids((&Query{}).Filter(messages)))
+
+	// with
+	require.Equal(t, "acd", ids((&Query{With: "romeo@jackal.im"}).Filter(messages)))
+	require.Equal(t, "ad", ids((&Query{With: "romeo@jackal.im/garden"}).Filter(messages)))
+
+	// time interval
+	require.Equal(t, "bcd", ids((&Query{Start: now.Add(time.Minute), End: now.Add(3 * time.Minute)}).Filter(messages)))
+
+	// paging
+	require.Equal(t, "ab", ids((&Query{Max: 2}).Filter(messages)))
+	require.Equal(t, "cd", ids((&Query{After: "b", Max: 2}).Filter(messages)))
+	require.Equal(t, "de", ids((&Query{Last: true, Max: 2}).Filter(messages)))
+	require.Equal(t, "bc", ids((&Query{Before: "d", Max: 2}).Filter(messages)))
+	require.Equal(t, "cd", ids((&Query{With: "romeo@jackal.im", After: "a"}).Filter(messages)))
+	require.Equal(t, "c", ids((&Query{After: "b", Before: "d"}).Filter(messages)))
+
+	// unknown identifiers
+	require.Nil(t, (&Query{After: "z"}).Filter(messages))
+	require.Nil(t, (&Query{Before: "z"}).Filter(messages))
+}
@@ -13,6 +13,7 @@ import (
 // BlockListItem represents block list item storage entity.
 type BlockListItem struct {
 	Username string
+	Domain   string
 	JID      string
 }
 
@@ -22,6 +23,9 @@ func (bli *BlockListItem) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&bli.Username); err != nil {
 		return err
 	}
+	if err := dec.Decode(&bli.Domain); err != nil {
+		return err
+	}
 	return dec.Decode(&bli.JID)
 }
 
@@ -31,5 +35,8 @@ func (bli *BlockListItem) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&bli.Username); err != nil {
 		return err
 	}
+	if err := enc.Encode(&bli.Domain); err != nil {
+		return err
+	}
 	return enc.Encode(&bli.JID)
 }
@@ -14,7 +14,7 @@ import (
 
 func TestBlockListItem(t *testing.T) {
 	var bi1, bi2 BlockListItem
-	bi1 = BlockListItem{"sxmpp", "romeo@example.net"}
+	bi1 = BlockListItem{"sxmpp", "jackal.im", "romeo@example.net"}
 	buf := new(bytes.Buffer)
 	require.Nil(t, bi1.ToBytes(buf))
 	require.Nil(t, bi2.FromBytes(buf))
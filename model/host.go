@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Host represents a virtual host storage entity.
+type Host struct {
+	Name        string
+	Certificate []byte // PEM encoded certificate chain
+	PrivateKey  []byte // PEM encoded private key
+	Enabled     bool
+}
+
+// FromBytes deserializes a Host entity from its binary representation.
+func (h *Host) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&h.Name); err != nil {
+		return err
+	}
+	if err := dec.Decode(&h.Certificate); err != nil {
+		return err
+	}
+	if err := dec.Decode(&h.PrivateKey); err != nil {
+		return err
+	}
+	return dec.Decode(&h.Enabled)
+}
+
+// ToBytes converts a Host entity to its binary representation.
+func (h *Host) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&h.Name); err != nil {
+		return err
+	}
+	if err := enc.Encode(&h.Certificate); err != nil {
+		return err
+	}
+	if err := enc.Encode(&h.PrivateKey); err != nil {
+		return err
+	}
+	return enc.Encode(&h.Enabled)
+}
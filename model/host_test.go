@@ -0,0 +1,22 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHost(t *testing.T) {
+	var h1, h2 Host
+	h1 = Host{Name: "jackal.im", Certificate: []byte("cert"), PrivateKey: []byte("key"), Enabled: true}
+	buf := new(bytes.Buffer)
+	require.Nil(t, h1.ToBytes(buf))
+	require.Nil(t, h2.FromBytes(buf))
+	require.Equal(t, h1, h2)
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sxmpp/jackal/module/xep0004"
+)
+
+// RoomConfigFormType represents room configuration form type.
+const RoomConfigFormType = "http://jabber.org/protocol/muc#roomconfig"
+
+const (
+	roomNameFieldVar          = "muc#roomconfig_roomname"
+	roomDescFieldVar          = "muc#roomconfig_roomdesc"
+	persistentRoomFieldVar    = "muc#roomconfig_persistentroom"
+	publicRoomFieldVar        = "muc#roomconfig_publicroom"
+	membersOnlyFieldVar       = "muc#roomconfig_membersonly"
+	moderatedRoomFieldVar     = "muc#roomconfig_moderatedroom"
+	passwordProtectedFieldVar = "muc#roomconfig_passwordprotectedroom"
+	roomSecretFieldVar        = "muc#roomconfig_roomsecret"
+	maxUsersFieldVar          = "muc#roomconfig_maxusers"
+	whoisFieldVar             = "muc#roomconfig_whois"
+	allowInvitesFieldVar      = "muc#roomconfig_allowinvites"
+	changeSubjectFieldVar     = "muc#roomconfig_changesubject"
+	maxHistoryFetchFieldVar   = "muc#maxhistoryfetch"
+)
+
+const (
+	whoisModerators = "moderators"
+	whoisAnyone     = "anyone"
+	maxUsersNone    = "none"
+)
+
+// RoomConfig represents multi-user chat room configuration.
+type RoomConfig struct {
+	Name              string
+	Description       string
+	Persistent        bool
+	Public            bool
+	MembersOnly       bool
+	Moderated         bool
+	PasswordProtected bool
+	Password          string
+
+	// MaxOccupants is the maximum number of room occupants. Zero value means no limit at all.
+	MaxOccupants int
+
+	// NonAnonymous tells whether or not occupants real JIDs are exposed to every occupant,
+	// instead of only to moderators.
+	NonAnonymous bool
+
+	// AllowInvites tells whether or not occupants other than admins and owners are allowed to invite users.
+	AllowInvites bool
+
+	// ChangeSubject tells whether or not occupants other than moderators are allowed to change room subject.
+	ChangeSubject bool
+
+	// HistoryLength is the maximum number of discussion history messages sent to joining occupants.
+	HistoryLength int
+}
+
+// ApplySubmitForm updates room configuration with the values contained in a submit form.
+// Fields not present in form are left untouched.
+func (c *RoomConfig) ApplySubmitForm(form *xep0004.DataForm) error {
+	if form.Type != xep0004.Submit {
+		return errors.New("invalid form type")
+	}
+	formType := form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden)
+	if len(formType) > 0 && formType != RoomConfigFormType {
+		return fmt.Errorf("invalid FORM_TYPE value: %s", formType)
+	}
+	cfg := *c
+	for _, f := range form.Fields {
+		var val string
+		if len(f.Values) > 0 {
+			val = f.Values[0]
+		}
+		var err error
+		switch f.Var {
+		case roomNameFieldVar:
+			cfg.Name = val
+		case roomDescFieldVar:
+			cfg.Description = val
+		case persistentRoomFieldVar:
+			cfg.Persistent, err = parseBool(val)
+		case publicRoomFieldVar:
+			cfg.Public, err = parseBool(val)
+		case membersOnlyFieldVar:
+			cfg.MembersOnly, err = parseBool(val)
+		case moderatedRoomFieldVar:
+			cfg.Moderated, err = parseBool(val)
+		case passwordProtectedFieldVar:
+			cfg.PasswordProtected, err = parseBool(val)
+		case roomSecretFieldVar:
+			cfg.Password = val
+		case maxUsersFieldVar:
+			if val == maxUsersNone || len(val) == 0 {
+				cfg.MaxOccupants = 0
+				break
+			}
+			cfg.MaxOccupants, err = strconv.Atoi(val)
+			if err == nil && cfg.MaxOccupants < 0 {
+				err = errors.New("negative value")
+			}
+		case whoisFieldVar:
+			switch val {
+			case whoisAnyone:
+				cfg.NonAnonymous = true
+			case whoisModerators:
+				cfg.NonAnonymous = false
+			default:
+				err = errors.New("unrecognized value")
+			}
+		case allowInvitesFieldVar:
+			cfg.AllowInvites, err = parseBool(val)
+		case changeSubjectFieldVar:
+			cfg.ChangeSubject, err = parseBool(val)
+		case maxHistoryFetchFieldVar:
+			cfg.HistoryLength, err = strconv.Atoi(val)
+			if err == nil && cfg.HistoryLength < 0 {
+				err = errors.New("negative value")
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %s (%v)", f.Var, val, err)
+		}
+	}
+	if cfg.PasswordProtected && len(cfg.Password) == 0 {
+		return errors.New("password protected room requires a password")
+	}
+	*c = cfg
+	return nil
+}
+
+// Form returns room configuration form representation.
+func (c *RoomConfig) Form() *xep0004.DataForm {
+	form := &xep0004.DataForm{
+		Type:  xep0004.Form,
+		Title: "Room configuration",
+	}
+	maxUsers := maxUsersNone
+	if c.MaxOccupants > 0 {
+		maxUsers = strconv.Itoa(c.MaxOccupants)
+	}
+	whois := whoisModerators
+	if c.NonAnonymous {
+		whois = whoisAnyone
+	}
+	form.Fields = xep0004.Fields{
+		{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{RoomConfigFormType}},
+		{Var: roomNameFieldVar, Type: xep0004.TextSingle, Label: "Natural-language room name", Values: []string{c.Name}},
+		{Var: roomDescFieldVar, Type: xep0004.TextSingle, Label: "Short description of room", Values: []string{c.Description}},
+		{Var: persistentRoomFieldVar, Type: xep0004.Boolean, Label: "Make room persistent", Values: []string{boolValue(c.Persistent)}},
+		{Var: publicRoomFieldVar, Type: xep0004.Boolean, Label: "Make room publicly searchable", Values: []string{boolValue(c.Public)}},
+		{Var: membersOnlyFieldVar, Type: xep0004.Boolean, Label: "Make room members-only", Values: []string{boolValue(c.MembersOnly)}},
+		{Var: moderatedRoomFieldVar, Type: xep0004.Boolean, Label: "Make room moderated", Values: []string{boolValue(c.Moderated)}},
+		{Var: passwordProtectedFieldVar, Type: xep0004.Boolean, Label: "Password required to enter", Values: []string{boolValue(c.PasswordProtected)}},
+		{Var: roomSecretFieldVar, Type: xep0004.TextPrivate, Label: "Password", Values: []string{c.Password}},
+		{
+			Var:    maxUsersFieldVar,
+			Type:   xep0004.ListSingle,
+			Label:  "Maximum number of occupants",
+			Values: []string{maxUsers},
+			Options: []xep0004.Option{
+				{Value: "10"}, {Value: "20"}, {Value: "30"}, {Value: "50"}, {Value: "100"}, {Label: "None", Value: maxUsersNone},
+			},
+		},
+		{
+			Var:    whoisFieldVar,
+			Type:   xep0004.ListSingle,
+			Label:  "Who may discover real JIDs?",
+			Values: []string{whois},
+			Options: []xep0004.Option{
+				{Label: "Moderators only", Value: whoisModerators},
+				{Label: "Anyone", Value: whoisAnyone},
+			},
+		},
+		{Var: allowInvitesFieldVar, Type: xep0004.Boolean, Label: "Allow occupants to invite others", Values: []string{boolValue(c.AllowInvites)}},
+		{Var: changeSubjectFieldVar, Type: xep0004.Boolean, Label: "Allow occupants to change subject", Values: []string{boolValue(c.ChangeSubject)}},
+		{Var: maxHistoryFetchFieldVar, Type: xep0004.TextSingle, Label: "Maximum number of history messages returned by room", Values: []string{strconv.Itoa(c.HistoryLength)}},
+	}
+	return form
+}
+
+func parseBool(s string) (bool, error) {
+	if len(s) == 0 {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"testing"
+
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomConfig_Form(t *testing.T) {
+	c := RoomConfig{Name: "Lobby", Persistent: true, NonAnonymous: true, MaxOccupants: 20, HistoryLength: 10}
+
+	form := c.Form()
+	require.Equal(t, xep0004.Form, form.Type)
+	require.Equal(t, RoomConfigFormType, form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden))
+	require.Equal(t, "Lobby", form.Fields.ValueForFieldOfType(roomNameFieldVar, xep0004.TextSingle))
+	require.Equal(t, "1", form.Fields.ValueForFieldOfType(persistentRoomFieldVar, xep0004.Boolean))
+	require.Equal(t, "0", form.Fields.ValueForFieldOfType(membersOnlyFieldVar, xep0004.Boolean))
+	require.Equal(t, "20", form.Fields.ValueForFieldOfType(maxUsersFieldVar, xep0004.ListSingle))
+	require.Equal(t, whoisAnyone, form.Fields.ValueForFieldOfType(whoisFieldVar, xep0004.ListSingle))
+
+	// submitting the very same values leaves configuration untouched
+	form.Type = xep0004.Submit
+	var c2 RoomConfig
+	require.Nil(t, c2.ApplySubmitForm(form))
+	require.Equal(t, c, c2)
+}
+
+func TestRoomConfig_ApplySubmitForm(t *testing.T) {
+	c := RoomConfig{Name: "Lobby", MaxOccupants: 20}
+
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{RoomConfigFormType}},
+			{Var: membersOnlyFieldVar, Values: []string{"true"}},
+			{Var: passwordProtectedFieldVar, Values: []string{"1"}},
+			{Var: roomSecretFieldVar, Values: []string{"cauldronburn"}},
+			{Var: maxUsersFieldVar, Values: []string{maxUsersNone}},
+		},
+	}
+	require.Nil(t, c.ApplySubmitForm(form))
+	require.Equal(t, "Lobby", c.Name)
+	require.True(t, c.MembersOnly)
+	require.True(t, c.PasswordProtected)
+	require.Equal(t, "cauldronburn", c.Password)
+	require.Equal(t, 0, c.MaxOccupants)
+
+	// invalid forms
+	require.NotNil(t, c.ApplySubmitForm(&xep0004.DataForm{Type: xep0004.Form}))
+	require.NotNil(t, c.ApplySubmitForm(&xep0004.DataForm{
+		Type:   xep0004.Submit,
+		Fields: xep0004.Fields{{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{"urn:xmpp:foo"}}},
+	}))
+	require.NotNil(t, c.ApplySubmitForm(&xep0004.DataForm{
+		Type:   xep0004.Submit,
+		Fields: xep0004.Fields{{Var: whoisFieldVar, Values: []string{"nobody"}}},
+	}))
+	require.NotNil(t, c.ApplySubmitForm(&xep0004.DataForm{
+		Type:   xep0004.Submit,
+		Fields: xep0004.Fields{{Var: roomSecretFieldVar}},
+	}))
+
+	// failed submissions don't modify configuration
+	require.Equal(t, "cauldronburn", c.Password)
+}
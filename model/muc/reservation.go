@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// NickReservation represents a nickname registered by a user within a room.
+type NickReservation struct {
+	// RoomJID is the room bare address.
+	RoomJID string
+
+	// JID is the bare address of the user owning the nickname.
+	JID string
+
+	// Nick is the reserved room nickname.
+	Nick string
+}
+
+// FromBytes deserializes a NickReservation entity from its binary representation.
+func (r *NickReservation) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.RoomJID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.JID); err != nil {
+		return err
+	}
+	return dec.Decode(&r.Nick)
+}
+
+// ToBytes converts a NickReservation entity to its binary representation.
+func (r *NickReservation) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.RoomJID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.JID); err != nil {
+		return err
+	}
+	return enc.Encode(&r.Nick)
+}
@@ -0,0 +1,24 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNickReservation_Serialization(t *testing.T) {
+	r := NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	var r2 NickReservation
+	require.Nil(t, r2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&r, &r2))
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+)
+
+const (
+	// Owner represents 'owner' room affiliation.
+	Owner = "owner"
+
+	// Admin represents 'admin' room affiliation.
+	Admin = "admin"
+
+	// Member represents 'member' room affiliation.
+	Member = "member"
+
+	// Outcast represents 'outcast' room affiliation.
+	Outcast = "outcast"
+
+	// None represents the absence of a room affiliation.
+	None = "none"
+)
+
+// Room represents a multi-user chat room persistent state.
+type Room struct {
+	// JID is the room bare address.
+	JID string
+
+	// Config contains room configuration.
+	Config RoomConfig
+
+	// Subject is the current room subject.
+	Subject string
+
+	// Affiliations maps user bare JIDs to their room affiliation.
+	Affiliations map[string]string
+}
+
+// Service returns the domain of the multi-user chat service hosting the room.
+func (r *Room) Service() string {
+	return r.JID[strings.LastIndex(r.JID, "@")+1:]
+}
+
+// Affiliation returns the affiliation of a user bare JID to the room.
+func (r *Room) Affiliation(jid string) string {
+	if aff, ok := r.Affiliations[jid]; ok {
+		return aff
+	}
+	return None
+}
+
+// SetAffiliation sets the affiliation of a user bare JID to the room.
+func (r *Room) SetAffiliation(jid, affiliation string) {
+	if affiliation == None {
+		delete(r.Affiliations, jid)
+		return
+	}
+	if r.Affiliations == nil {
+		r.Affiliations = make(map[string]string)
+	}
+	r.Affiliations[jid] = affiliation
+}
+
+// AffiliatedJIDs returns all user bare JIDs holding a given room affiliation.
+func (r *Room) AffiliatedJIDs(affiliation string) []string {
+	var jids []string
+	for jid, aff := range r.Affiliations {
+		if aff == affiliation {
+			jids = append(jids, jid)
+		}
+	}
+	return jids
+}
+
+// FromBytes deserializes a Room entity from its binary representation.
+func (r *Room) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.JID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Config); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Subject); err != nil {
+		return err
+	}
+	return dec.Decode(&r.Affiliations)
+}
+
+// ToBytes converts a Room entity to its binary representation.
+func (r *Room) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.JID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Config); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Subject); err != nil {
+		return err
+	}
+	return enc.Encode(&r.Affiliations)
+}
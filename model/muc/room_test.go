@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoom_Serialization(t *testing.T) {
+	r := Room{
+		JID:          "lobby@muc.jackal.im",
+		Config:       RoomConfig{Name: "Lobby", Persistent: true, MaxOccupants: 50, HistoryLength: 20},
+		Subject:      "Welcome!",
+		Affiliations: map[string]string{"ortuman@jackal.im": Owner, "romeo@jackal.im": Member},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	var r2 Room
+	require.Nil(t, r2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&r, &r2))
+}
+
+func TestRoom_Service(t *testing.T) {
+	r := Room{JID: "lobby@muc.jackal.im"}
+	require.Equal(t, "muc.jackal.im", r.Service())
+}
+
+func TestRoom_Affiliations(t *testing.T) {
+	var r Room
+	require.Equal(t, None, r.Affiliation("ortuman@jackal.im"))
+
+	r.SetAffiliation("ortuman@jackal.im", Owner)
+	r.SetAffiliation("romeo@jackal.im", Member)
+	r.SetAffiliation("juliet@jackal.im", Member)
+	require.Equal(t, Owner, r.Affiliation("ortuman@jackal.im"))
+	require.Len(t, r.AffiliatedJIDs(Member), 2)
+
+	r.SetAffiliation("romeo@jackal.im", None)
+	require.Equal(t, None, r.Affiliation("romeo@jackal.im"))
+	require.Equal(t, []string{"juliet@jackal.im"}, r.AffiliatedJIDs(Member))
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offlinemodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// Message represents a user offline queued message.
+type Message struct {
+	// ID is the offline queue identifier assigned to the message, also used as its XEP-0013 node.
+	ID string
+
+	// ExpiresAt is the time after which message is no longer delivered. Zero value means message never expires.
+	ExpiresAt time.Time
+
+	Message *xmpp.Message
+}
+
+// IsExpired returns whether or not message lifetime is over at a given time.
+func (m *Message) IsExpired(at time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !m.ExpiresAt.After(at)
+}
+
+// Size returns message serialized size in bytes, as accounted by user offline quota.
+func (m *Message) Size() int {
+	return len(m.Message.String())
+}
+
+// FromBytes deserializes a Message entity from its binary representation.
+func (m *Message) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&m.ID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&m.ExpiresAt); err != nil {
+		return err
+	}
+	msg, err := xmpp.NewMessageFromBytes(buf)
+	if err != nil {
+		return err
+	}
+	m.Message = msg
+	return nil
+}
+
+// ToBytes converts a Message entity to its binary representation.
+func (m *Message) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&m.ID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&m.ExpiresAt); err != nil {
+		return err
+	}
+	return m.Message.ToBytes(buf)
+}
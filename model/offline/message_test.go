@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offlinemodel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Serialization(t *testing.T) {
+	from, _ := jid.NewWithString("romeo@jackal.im/garden", true)
+	to, _ := jid.NewWithString("juliet@jackal.im", true)
+
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Art thou not Romeo, and a Montague?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	m := Message{ID: "28482-98726-73623", ExpiresAt: time.Now().Add(time.Hour), Message: msg}
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, m.ToBytes(buf))
+
+	var m2 Message
+	require.Nil(t, m2.FromBytes(buf))
+
+	require.Equal(t, m.ID, m2.ID)
+	require.True(t, m.ExpiresAt.Equal(m2.ExpiresAt))
+	require.Equal(t, m.Message.String(), m2.Message.String())
+}
+
+func TestMessage_IsExpired(t *testing.T) {
+	now := time.Now()
+
+	m := Message{ID: "28482-98726-73623"}
+	require.False(t, m.IsExpired(now))
+
+	m.ExpiresAt = now.Add(time.Minute)
+	require.False(t, m.IsExpired(now))
+
+	m.ExpiresAt = now
+	require.True(t, m.IsExpired(now))
+}
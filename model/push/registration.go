@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pushmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Registration represents a user push notifications registration.
+type Registration struct {
+	Username string
+	Domain   string
+
+	// JID is the address of the app server pubsub service notifications are published to.
+	JID string
+
+	// Node is the app server pubsub node notifications are published to.
+	Node string
+
+	// Options contains the publish-options fields to be included along with every notification.
+	Options map[string]string
+}
+
+// FromBytes deserializes a Registration entity from its binary representation.
+func (r *Registration) FromBytes(buf *bytes.Buffer) error {
+	dec := gob.NewDecoder(buf)
+	if err := dec.Decode(&r.Username); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Domain); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.JID); err != nil {
+		return err
+	}
+	if err := dec.Decode(&r.Node); err != nil {
+		return err
+	}
+	return dec.Decode(&r.Options)
+}
+
+// ToBytes converts a Registration entity to its binary representation.
+func (r *Registration) ToBytes(buf *bytes.Buffer) error {
+	enc := gob.NewEncoder(buf)
+	if err := enc.Encode(&r.Username); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Domain); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.JID); err != nil {
+		return err
+	}
+	if err := enc.Encode(&r.Node); err != nil {
+		return err
+	}
+	return enc.Encode(&r.Options)
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pushmodel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistration_Serialization(t *testing.T) {
+	r := Registration{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		JID:      "push.jackal.im",
+		Node:     "yxs32uqsflafdk3iuqo",
+		Options:  map[string]string{"secret": "eruio234vzxc2kla-91"},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, r.ToBytes(buf))
+
+	var r2 Registration
+	require.Nil(t, r2.FromBytes(buf))
+	require.True(t, reflect.DeepEqual(&r, &r2))
+}
@@ -27,6 +27,7 @@ const (
 // Item represents a roster item storage entity.
 type Item struct {
 	Username     string
+	Domain       string
 	JID          string
 	Name         string
 	Subscription string
@@ -115,6 +116,9 @@ func (ri *Item) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&ri.Username); err != nil {
 		return err
 	}
+	if err := dec.Decode(&ri.Domain); err != nil {
+		return err
+	}
 	if err := dec.Decode(&ri.JID); err != nil {
 		return err
 	}
@@ -139,6 +143,9 @@ func (ri *Item) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&ri.Username); err != nil {
 		return err
 	}
+	if err := enc.Encode(&ri.Domain); err != nil {
+		return err
+	}
 	if err := enc.Encode(&ri.JID); err != nil {
 		return err
 	}
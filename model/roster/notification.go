@@ -15,6 +15,7 @@ import (
 // Notification represents a roster subscription pending notification.
 type Notification struct {
 	Contact  string
+	Domain   string
 	JID      string
 	Presence *xmpp.Presence
 }
@@ -25,6 +26,9 @@ func (rn *Notification) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&rn.Contact); err != nil {
 		return err
 	}
+	if err := dec.Decode(&rn.Domain); err != nil {
+		return err
+	}
 	if err := dec.Decode(&rn.JID); err != nil {
 		return err
 	}
@@ -42,6 +46,9 @@ func (rn *Notification) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&rn.Contact); err != nil {
 		return err
 	}
+	if err := enc.Encode(&rn.Domain); err != nil {
+		return err
+	}
 	if err := enc.Encode(&rn.JID); err != nil {
 		return err
 	}
@@ -8,17 +8,50 @@ package model
 import (
 	"bytes"
 	"encoding/gob"
+	"io"
 	"time"
 
 	"github.com/sxmpp/jackal/xmpp"
 )
 
+const (
+	// UserActive represents an enabled user account.
+	UserActive = "active"
+
+	// UserDisabled represents a temporarily suspended user account.
+	UserDisabled = "disabled"
+
+	// UserBanned represents a banned user account.
+	UserBanned = "banned"
+)
+
 // User represents a user storage entity.
 type User struct {
 	Username       string
+	Domain         string
 	Password       string
 	LastPresence   *xmpp.Presence
 	LastPresenceAt time.Time
+	Email          string
+	Status         string
+	StatusReason   string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+}
+
+// IsDisabled returns whether or not the user account has been suspended or it's already expired.
+func (u *User) IsDisabled() bool {
+	return u.IsSuspended() || u.IsExpired()
+}
+
+// IsSuspended returns whether or not the user account status is either disabled or banned.
+func (u *User) IsSuspended() bool {
+	return len(u.Status) > 0 && u.Status != UserActive
+}
+
+// IsExpired returns whether or not the user account expiration time has been reached.
+func (u *User) IsExpired() bool {
+	return !u.ExpiresAt.IsZero() && !time.Now().Before(u.ExpiresAt)
 }
 
 // FromBytes deserializes a User entity from it's gob binary representation.
@@ -27,6 +60,9 @@ func (u *User) FromBytes(buf *bytes.Buffer) error {
 	if err := dec.Decode(&u.Username); err != nil {
 		return err
 	}
+	if err := dec.Decode(&u.Domain); err != nil {
+		return err
+	}
 	if err := dec.Decode(&u.Password); err != nil {
 		return err
 	}
@@ -44,7 +80,23 @@ func (u *User) FromBytes(buf *bytes.Buffer) error {
 			return err
 		}
 	}
-	return nil
+	// account status fields are missing in previously stored users
+	if err := dec.Decode(&u.Email); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if err := dec.Decode(&u.Status); err != nil {
+		return err
+	}
+	if err := dec.Decode(&u.StatusReason); err != nil {
+		return err
+	}
+	if err := dec.Decode(&u.ExpiresAt); err != nil {
+		return err
+	}
+	return dec.Decode(&u.CreatedAt)
 }
 
 // ToBytes converts a User entity to it's gob binary representation.
@@ -53,6 +105,9 @@ func (u *User) ToBytes(buf *bytes.Buffer) error {
 	if err := enc.Encode(&u.Username); err != nil {
 		return err
 	}
+	if err := enc.Encode(&u.Domain); err != nil {
+		return err
+	}
 	if err := enc.Encode(&u.Password); err != nil {
 		return err
 	}
@@ -65,7 +120,24 @@ func (u *User) ToBytes(buf *bytes.Buffer) error {
 			return err
 		}
 		u.LastPresenceAt = time.Now()
-		return enc.Encode(&u.LastPresenceAt)
+		if err := enc.Encode(&u.LastPresenceAt); err != nil {
+			return err
+		}
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	if err := enc.Encode(&u.Email); err != nil {
+		return err
+	}
+	if err := enc.Encode(&u.Status); err != nil {
+		return err
+	}
+	if err := enc.Encode(&u.StatusReason); err != nil {
+		return err
+	}
+	if err := enc.Encode(&u.ExpiresAt); err != nil {
+		return err
 	}
-	return nil
+	return enc.Encode(&u.CreatedAt)
 }
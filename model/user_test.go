@@ -7,6 +7,7 @@ package model
 
 import (
 	"bytes"
+	"encoding/gob"
 	"testing"
 	"time"
 
@@ -34,3 +35,50 @@ func TestModelUser(t *testing.T) {
 	require.Equal(t, usr1.LastPresence.String(), usr2.LastPresence.String())
 	require.NotEqual(t, time.Time{}, usr2.LastPresenceAt)
 }
+
+func TestModelUserAccountStatus(t *testing.T) {
+	usr1 := User{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		Password:     "1234",
+		Email:        "sxmpp@jackal.im",
+		Status:       UserBanned,
+		StatusReason: "spam",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	buf := new(bytes.Buffer)
+	require.Nil(t, usr1.ToBytes(buf))
+	usr2 := User{}
+	require.Nil(t, usr2.FromBytes(buf))
+	require.Equal(t, usr1.Email, usr2.Email)
+	require.Equal(t, usr1.Status, usr2.Status)
+	require.Equal(t, usr1.StatusReason, usr2.StatusReason)
+	require.True(t, usr1.ExpiresAt.Equal(usr2.ExpiresAt))
+	require.False(t, usr2.CreatedAt.IsZero())
+	require.True(t, usr2.IsDisabled())
+	require.True(t, usr2.IsSuspended())
+	require.False(t, usr2.IsExpired())
+
+	// previously stored users
+	buf.Reset()
+	enc := gob.NewEncoder(buf)
+	require.Nil(t, enc.Encode("sxmpp"))
+	require.Nil(t, enc.Encode("jackal.im"))
+	require.Nil(t, enc.Encode("1234"))
+	require.Nil(t, enc.Encode(false))
+
+	usr3 := User{}
+	require.Nil(t, usr3.FromBytes(buf))
+	require.Equal(t, "sxmpp", usr3.Username)
+	require.Equal(t, "1234", usr3.Password)
+	require.False(t, usr3.IsDisabled())
+
+	usr3.ExpiresAt = time.Now().Add(-time.Second)
+	require.True(t, usr3.IsExpired())
+	require.False(t, usr3.IsSuspended())
+	require.True(t, usr3.IsDisabled())
+
+	usr3.ExpiresAt = time.Time{}
+	usr3.Status = UserActive
+	require.False(t, usr3.IsDisabled())
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	streamerror "github.com/sxmpp/jackal/errors"
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/module/roster"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// expiredReason is the status reason assigned to those accounts disabled due to expiration.
+const expiredReason = "account expired"
+
+// expirationInterval defines how often expired accounts are looked up.
+var expirationInterval = time.Minute
+
+// ErrAccountNotFound will be returned by those operations targeting a non existing account.
+var ErrAccountNotFound = errors.New("account: account not found")
+
+// Manager removes user accounts along with every piece of data associated to them, and takes care of
+// disabling them, either on demand or once their expiration time is reached.
+type Manager struct {
+	router  router.Router
+	roster  *roster.Roster
+	userRep repository.User
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// New returns an account manager instance. Router and roster module are optional; when present, account
+// streams are closed on disable and account contacts are notified of the removal.
+func New(router router.Router, roster *roster.Roster, userRep repository.User) *Manager {
+	return &Manager{
+		router:  router,
+		roster:  roster,
+		userRep: userRep,
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// Start starts disabling expired accounts in background.
+func (m *Manager) Start() {
+	m.startOnce.Do(func() { go m.loop() })
+}
+
+// Shutdown stops disabling expired accounts.
+func (m *Manager) Shutdown() error {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+		m.startOnce.Do(func() { close(m.doneCh) }) // never started
+		<-m.doneCh
+	})
+	return nil
+}
+
+// DeleteAccount cancels every account presence subscription and removes the account from storage.
+// Account streams are left untouched, see DisconnectSessions.
+func (m *Manager) DeleteAccount(ctx context.Context, username, domain string) error {
+	userJID, err := jid.New(username, domain, "", true)
+	if err != nil {
+		return err
+	}
+	if m.roster != nil {
+		if err := m.roster.RemoveAccount(ctx, userJID); err != nil {
+			return err
+		}
+	}
+	if err := m.userRep.DeleteUser(ctx, username, domain); err != nil {
+		return err
+	}
+	log.Infof("deleted account: %v", userJID)
+	return nil
+}
+
+// DisconnectSessions closes every local stream bound to an account.
+func (m *Manager) DisconnectSessions(ctx context.Context, username, domain string) {
+	if m.router == nil {
+		return
+	}
+	for _, stm := range m.router.LocalStreams(username, domain) {
+		stm.Disconnect(ctx, streamerror.ErrNotAuthorized)
+	}
+}
+
+// DisableAccount sets an account status either to disabled or banned, closing every local stream bound to it.
+// Subsequent authentication attempts will be refused with an 'account-disabled' failure.
+func (m *Manager) DisableAccount(ctx context.Context, username, domain, status, reason string) error {
+	if status != model.UserDisabled && status != model.UserBanned {
+		return fmt.Errorf("account: invalid status: %s", status)
+	}
+	usr, err := m.fetchUser(ctx, username, domain)
+	if err != nil {
+		return err
+	}
+	usr.Status = status
+	usr.StatusReason = reason
+	if err := m.userRep.UpsertUser(ctx, usr); err != nil {
+		return err
+	}
+	log.Infof("%s account: %s@%s (%s)", status, username, domain, reason)
+
+	m.DisconnectSessions(ctx, username, domain)
+	return nil
+}
+
+// EnableAccount reactivates a disabled or banned account. An already reached expiration time is cleared as well.
+func (m *Manager) EnableAccount(ctx context.Context, username, domain string) error {
+	usr, err := m.fetchUser(ctx, username, domain)
+	if err != nil {
+		return err
+	}
+	usr.Status = model.UserActive
+	usr.StatusReason = ""
+	if usr.IsExpired() {
+		usr.ExpiresAt = time.Time{}
+	}
+	if err := m.userRep.UpsertUser(ctx, usr); err != nil {
+		return err
+	}
+	log.Infof("enabled account: %s@%s", username, domain)
+	return nil
+}
+
+// SetAccountExpiration sets the time at which an account will be disabled. Zero time value means
+// the account never expires.
+func (m *Manager) SetAccountExpiration(ctx context.Context, username, domain string, expiresAt time.Time) error {
+	usr, err := m.fetchUser(ctx, username, domain)
+	if err != nil {
+		return err
+	}
+	usr.ExpiresAt = expiresAt
+	if usr.IsExpired() && !usr.IsSuspended() {
+		return m.expireAccount(ctx, usr)
+	}
+	return m.userRep.UpsertUser(ctx, usr)
+}
+
+func (m *Manager) fetchUser(ctx context.Context, username, domain string) (*model.User, error) {
+	usr, err := m.userRep.FetchUser(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if usr == nil {
+		return nil, ErrAccountNotFound
+	}
+	return usr, nil
+}
+
+func (m *Manager) loop() {
+	defer close(m.doneCh)
+
+	tc := time.NewTicker(expirationInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			if err := m.expireAccounts(context.Background()); err != nil {
+				log.Error(err)
+			}
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// expireAccounts disables every active account whose expiration time has been reached.
+func (m *Manager) expireAccounts(ctx context.Context) error {
+	users, err := m.userRep.FetchExpiredUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if err := m.expireAccount(ctx, &users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) expireAccount(ctx context.Context, usr *model.User) error {
+	usr.Status = model.UserDisabled
+	usr.StatusReason = expiredReason
+	if err := m.userRep.UpsertUser(ctx, usr); err != nil {
+		return err
+	}
+	log.Infof("expired account: %s@%s", usr.Username, usr.Domain)
+
+	m.DisconnectSessions(ctx, usr.Username, usr.Domain)
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package account
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/model"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/module/roster"
+	"github.com/sxmpp/jackal/module/xep0115"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_DeleteAccount(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	rosterRep := memorystorage.NewRoster()
+	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "noelia",
+		Domain:       "jackal.im",
+		JID:          "sxmpp@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "noelia", Domain: "jackal.im", Password: "1234"})
+
+	stm1 := bindStream(r, "sxmpp", "jackal.im")
+	stm1.SetPresence(xmpp.NewPresence(stm1.JID(), stm1.JID(), xmpp.AvailableType))
+	stm2 := bindStream(r, "noelia", "jackal.im")
+	stm2.SetPresence(xmpp.NewPresence(stm2.JID(), stm2.JID(), xmpp.AvailableType))
+	stm2.SetValue("roster:requested", true)
+
+	rst := roster.New(&roster.Config{}, xep0115.New(r, memorystorage.NewPresences(), "alloc-1234"), nil, r, s, rosterRep)
+	defer func() { _ = rst.Shutdown() }()
+
+	m := New(r, rst, s)
+	defer func() { _ = m.Shutdown() }()
+
+	require.Nil(t, m.DeleteAccount(context.Background(), "sxmpp", "jackal.im"))
+
+	// contact must have been notified before account removal took place
+	elem := stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnsubscribeType, elem.Type())
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnsubscribedType, elem.Type())
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	item := elem.Elements().ChildNamespace("query", "jabber:iq:roster").Elements().Child("item")
+	require.NotNil(t, item)
+	require.Equal(t, "sxmpp@jackal.im", item.Attributes().Get("jid"))
+	require.Equal(t, rostermodel.SubscriptionRemove, item.Attributes().Get("subscription"))
+
+	ri, _ := rosterRep.FetchRosterItem(context.Background(), "noelia", "jackal.im", "sxmpp@jackal.im")
+	require.Nil(t, ri)
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, usr)
+}
+
+func TestManager_DisableAccount(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	stm := bindStream(r, "sxmpp", "jackal.im")
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
+
+	m := New(r, nil, s)
+	defer func() { _ = m.Shutdown() }()
+
+	require.NotNil(t, m.DisableAccount(context.Background(), "sxmpp", "jackal.im", model.UserActive, ""))
+	require.Equal(t, ErrAccountNotFound, m.DisableAccount(context.Background(), "romeo", "jackal.im", model.UserBanned, "spam"))
+
+	require.Nil(t, m.DisableAccount(context.Background(), "sxmpp", "jackal.im", model.UserBanned, "spam"))
+	require.True(t, stm.IsDisconnected())
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, model.UserBanned, usr.Status)
+	require.Equal(t, "spam", usr.StatusReason)
+	require.True(t, usr.IsDisabled())
+
+	require.Nil(t, m.EnableAccount(context.Background(), "sxmpp", "jackal.im"))
+
+	usr, _ = s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, model.UserActive, usr.Status)
+	require.Equal(t, "", usr.StatusReason)
+	require.False(t, usr.IsDisabled())
+}
+
+func TestManager_ExpireAccounts(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	stm1 := bindStream(r, "sxmpp", "jackal.im")
+	stm2 := bindStream(r, "noelia", "jackal.im")
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234", ExpiresAt: time.Now().Add(-time.Minute)})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "noelia", Domain: "jackal.im", Password: "1234", ExpiresAt: time.Now().Add(time.Hour)})
+
+	m := New(r, nil, s)
+	defer func() { _ = m.Shutdown() }()
+
+	require.Nil(t, m.expireAccounts(context.Background()))
+	require.True(t, stm1.IsDisconnected())
+	require.False(t, stm2.IsDisconnected())
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, model.UserDisabled, usr.Status)
+	require.Equal(t, expiredReason, usr.StatusReason)
+
+	// already expired expiration time
+	require.Nil(t, m.SetAccountExpiration(context.Background(), "noelia", "jackal.im", time.Now().Add(-time.Second)))
+	require.True(t, stm2.IsDisconnected())
+
+	usr, _ = s.FetchUser(context.Background(), "noelia", "jackal.im")
+	require.Equal(t, model.UserDisabled, usr.Status)
+
+	// enabling an expired account clears its expiration time
+	require.Nil(t, m.EnableAccount(context.Background(), "noelia", "jackal.im"))
+
+	usr, _ = s.FetchUser(context.Background(), "noelia", "jackal.im")
+	require.True(t, usr.ExpiresAt.IsZero())
+	require.False(t, usr.IsDisabled())
+
+	// storage error
+	memorystorage.EnableMockedError()
+	require.Equal(t, memorystorage.ErrMocked, m.expireAccounts(context.Background()))
+	memorystorage.DisableMockedError()
+}
+
+func TestManager_ExpirationLoop(t *testing.T) {
+	interval := expirationInterval
+	expirationInterval = time.Millisecond * 10
+	defer func() { expirationInterval = interval }()
+
+	r, s := setupTest("jackal.im")
+
+	stm := bindStream(r, "sxmpp", "jackal.im")
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234", ExpiresAt: time.Now().Add(time.Millisecond * 50)})
+
+	m := New(r, nil, s)
+	m.Start()
+
+	time.Sleep(time.Millisecond * 200)
+	require.Nil(t, m.Shutdown())
+	require.True(t, stm.IsDisconnected())
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, model.UserDisabled, usr.Status)
+
+	// shutting down a non started manager
+	require.Nil(t, New(r, nil, s).Shutdown())
+}
+
+func bindStream(r router.Router, username, domain string) *stream.MockC2S {
+	j, _ := jid.New(username, domain, "balcony", true)
+	stm := stream.NewMockC2S(username, j)
+	stm.SetAuthenticated(true)
+	r.Bind(context.Background(), stm)
+	return stm
+}
+
+func setupTest(domain string) (router.Router, *memorystorage.User) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+	userRep := memorystorage.NewUser()
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(userRep, memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, userRep
+}
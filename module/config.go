@@ -13,6 +13,8 @@ import (
 	"github.com/sxmpp/jackal/module/xep0077"
 	"github.com/sxmpp/jackal/module/xep0092"
 	"github.com/sxmpp/jackal/module/xep0199"
+	"github.com/sxmpp/jackal/module/xep0313"
+	"github.com/sxmpp/jackal/module/xep0357"
 )
 
 // Config represents C2S modules configuration.
@@ -23,6 +25,12 @@ type Config struct {
 	Registration xep0077.Config
 	Version      xep0092.Config
 	Ping         xep0199.Config
+	MAM          xep0313.Config
+	Push         xep0357.Config
+
+	// Hosts contains per-host overrides keyed by domain, which are resolved when serving a domain,
+	// so that they also apply to virtual hosts registered in storage.
+	Hosts map[string]HostConfig
 }
 
 type configProxy struct {
@@ -32,6 +40,10 @@ type configProxy struct {
 	Registration xep0077.Config `yaml:"mod_registration"`
 	Version      xep0092.Config `yaml:"mod_version"`
 	Ping         xep0199.Config `yaml:"mod_ping"`
+	MAM          xep0313.Config `yaml:"mod_mam"`
+	Push         xep0357.Config `yaml:"mod_push"`
+
+	Hosts map[string]HostConfig `yaml:"hosts"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -40,17 +52,9 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&p); err != nil {
 		return err
 	}
-	// validate modules
-	enabled := make(map[string]struct{}, len(p.Enabled))
-	for _, mod := range p.Enabled {
-		switch mod {
-		case "roster", "last_activity", "private", "vcard", "registration", "pep", "version", "blocking_command",
-			"ping", "offline":
-			break
-		default:
-			return fmt.Errorf("module.Config: unrecognized module: %s", mod)
-		}
-		enabled[mod] = struct{}{}
+	enabled, err := enabledModules(p.Enabled)
+	if err != nil {
+		return err
 	}
 	cfg.Enabled = enabled
 	cfg.Roster = p.Roster
@@ -58,5 +62,59 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	cfg.Registration = p.Registration
 	cfg.Version = p.Version
 	cfg.Ping = p.Ping
+	cfg.MAM = p.MAM
+	cfg.Push = p.Push
+	cfg.Hosts = p.Hosts
 	return nil
 }
+
+// HostConfig represents a virtual host modules configuration.
+// Every nil field is inherited from global modules configuration.
+type HostConfig struct {
+	Enabled      map[string]struct{}
+	Offline      *offline.Config
+	Registration *xep0077.Config
+	Ping         *xep0199.Config
+}
+
+type hostConfigProxy struct {
+	Enabled      []string        `yaml:"enabled"`
+	Offline      *offline.Config `yaml:"mod_offline"`
+	Registration *xep0077.Config `yaml:"mod_registration"`
+	Ping         *xep0199.Config `yaml:"mod_ping"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *HostConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := hostConfigProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.Enabled != nil {
+		enabled, err := enabledModules(p.Enabled)
+		if err != nil {
+			return err
+		}
+		cfg.Enabled = enabled
+	}
+	cfg.Offline = p.Offline
+	cfg.Registration = p.Registration
+	cfg.Ping = p.Ping
+	return nil
+}
+
+func enabledModules(mods []string) (map[string]struct{}, error) {
+	// validate modules
+	enabled := make(map[string]struct{}, len(mods))
+	for _, mod := range mods {
+		switch mod {
+		case "roster", "last_activity", "private", "vcard", "registration", "pep", "version", "blocking_command",
+			"ping", "carbons", "offline", "mam", "push":
+			break
+		default:
+			return nil, fmt.Errorf("module.Config: unrecognized module: %s", mod)
+		}
+		enabled[mod] = struct{}{}
+	}
+	return enabled, nil
+}
@@ -23,4 +23,45 @@ func TestModuleConfig(t *testing.T) {
 	validMod := `enabled: [roster]`
 	err = yaml.Unmarshal([]byte(validMod), &cfg)
 	require.Nil(t, err)
+
+	badHostMod := `
+enabled: [roster]
+hosts:
+  example.org:
+    enabled: [bad_mod]
+`
+	err = yaml.Unmarshal([]byte(badHostMod), &cfg)
+	require.NotNil(t, err)
+
+	hostMod := `
+enabled: [roster]
+hosts:
+  example.org:
+    enabled: [roster, ping]
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(hostMod), &cfg)
+	require.Nil(t, err)
+	require.Len(t, cfg.Hosts, 1)
+	require.Len(t, cfg.Hosts["example.org"].Enabled, 2)
+}
+
+func TestModuleHostConfig(t *testing.T) {
+	badMod := `enabled: [bad_mod]`
+	cfg := &HostConfig{}
+	err := yaml.Unmarshal([]byte(badMod), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &HostConfig{}
+	err = yaml.Unmarshal([]byte(`mod_registration: {allow_registration: true}`), &cfg)
+	require.Nil(t, err)
+	require.Nil(t, cfg.Enabled)
+	require.NotNil(t, cfg.Registration)
+	require.True(t, cfg.Registration.AllowRegistration)
+	require.Nil(t, cfg.Offline)
+
+	cfg = &HostConfig{}
+	err = yaml.Unmarshal([]byte(`enabled: [roster, ping]`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(cfg.Enabled))
 }
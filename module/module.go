@@ -9,6 +9,7 @@ import (
 	"context"
 
 	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/account"
 	"github.com/sxmpp/jackal/module/offline"
 	"github.com/sxmpp/jackal/module/roster"
 	"github.com/sxmpp/jackal/module/xep0012"
@@ -21,6 +22,9 @@ import (
 	"github.com/sxmpp/jackal/module/xep0163"
 	"github.com/sxmpp/jackal/module/xep0191"
 	"github.com/sxmpp/jackal/module/xep0199"
+	"github.com/sxmpp/jackal/module/xep0280"
+	"github.com/sxmpp/jackal/module/xep0313"
+	"github.com/sxmpp/jackal/module/xep0357"
 	"github.com/sxmpp/jackal/router"
 	"github.com/sxmpp/jackal/storage/repository"
 	"github.com/sxmpp/jackal/xmpp"
@@ -56,99 +60,92 @@ type Modules struct {
 	Pep          *xep0163.Pep
 	BlockingCmd  *xep0191.BlockingCommand
 	Ping         *xep0199.Ping
+	Carbons      *xep0280.Carbons
+	MAM          *xep0313.MAM
+	Push         *xep0357.Push
 
-	router     router.Router
-	iqHandlers []IQHandler
-	all        []Module
+	// Accounts removes, disables and expires user accounts. Always available, regardless of enabled modules.
+	Accounts *account.Manager
+
+	presenceHub *xep0115.EntityCaps
+	router      router.Router
+	iqHandlers  []IQHandler
+	hosts       map[string]*Modules
+	all         []Module
+
+	// disco info registrations made by every module instance during its initialization
+	discoRegs     map[string]xep0030.Registrations
+	discoSnapshot xep0030.Registrations
 }
 
 // New returns a set of modules derived from a concrete configuration.
 func New(config *Config, router router.Router, reps repository.Container, allocationID string) *Modules {
-	var presenceHub = xep0115.New(router, reps.Presences(), allocationID)
-
-	m := &Modules{router: router}
-
-	// XEP-0030: Service Discovery (https://xmpp.org/extensions/xep-0030.html)
-	m.DiscoInfo = xep0030.New(router, reps.Roster())
-	m.iqHandlers = append(m.iqHandlers, m.DiscoInfo)
-	m.all = append(m.all, m.DiscoInfo)
-
-	// XEP-0012: Last Activity (https://xmpp.org/extensions/xep-0012.html)
-	if _, ok := config.Enabled["last_activity"]; ok {
-		m.LastActivity = xep0012.New(m.DiscoInfo, router, reps.User(), reps.Roster())
-		m.iqHandlers = append(m.iqHandlers, m.LastActivity)
-		m.all = append(m.all, m.LastActivity)
+	// instantiate every module enabled either globally or by any virtual host
+	enabled := make(map[string]struct{}, len(config.Enabled))
+	for mod := range config.Enabled {
+		enabled[mod] = struct{}{}
 	}
-
-	// XEP-0049: Private XML Storage (https://xmpp.org/extensions/xep-0049.html)
-	if _, ok := config.Enabled["private"]; ok {
-		m.Private = xep0049.New(router, reps.Private())
-		m.iqHandlers = append(m.iqHandlers, m.Private)
-		m.all = append(m.all, m.Private)
-	}
-
-	// XEP-0054: vcard-temp (https://xmpp.org/extensions/xep-0054.html)
-	if _, ok := config.Enabled["vcard"]; ok {
-		m.VCard = xep0054.New(m.DiscoInfo, router, reps.VCard())
-		m.iqHandlers = append(m.iqHandlers, m.VCard)
-		m.all = append(m.all, m.VCard)
+	for _, hostCfg := range config.Hosts {
+		for mod := range hostCfg.Enabled {
+			enabled[mod] = struct{}{}
+		}
 	}
+	instances := newInstances(enabled, config, router, reps, allocationID)
 
-	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
-	if _, ok := config.Enabled["registration"]; ok {
-		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User())
-		m.iqHandlers = append(m.iqHandlers, m.Register)
-		m.all = append(m.all, m.Register)
-	}
+	m := instances.with(config.Enabled)
+	m.hosts = make(map[string]*Modules, len(config.Hosts))
 
-	// XEP-0092: Software Version (https://xmpp.org/extensions/xep-0092.html)
-	if _, ok := config.Enabled["version"]; ok {
-		m.Version = xep0092.New(&config.Version, m.DiscoInfo, router)
-		m.iqHandlers = append(m.iqHandlers, m.Version)
-		m.all = append(m.all, m.Version)
-	}
+	// hide features of disabled modules from disco info
+	instances.hideDisabled("", config.Enabled)
 
-	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
-	if _, ok := config.Enabled["offline"]; ok {
-		m.Offline = offline.New(&config.Offline, m.DiscoInfo, router, reps.Offline())
-		m.all = append(m.all, m.Offline)
-	}
+	for domain, hostCfg := range config.Hosts {
+		hostEnabled := config.Enabled
+		if hostCfg.Enabled != nil {
+			hostEnabled = hostCfg.Enabled
+		}
+		h := instances.with(hostEnabled)
 
-	// XEP-0163: Personal Eventing Protocol (https://xmpp.org/extensions/xep-0163.html)
-	if _, ok := config.Enabled["pep"]; ok {
-		m.Pep = xep0163.New(m.DiscoInfo, presenceHub, router, reps.Roster(), reps.PubSub())
-		m.iqHandlers = append(m.iqHandlers, m.Pep)
-		m.all = append(m.all, m.Pep)
-	}
+		// replace shared instances whenever host overrides its configuration
+		if h.Register != nil && hostCfg.Registration != nil {
+			h.Register = xep0077.New(hostCfg.Registration, h.DiscoInfo, router, reps.User(), h.Accounts)
+			instances.all = append(instances.all, h.Register)
+		}
+		if h.Offline != nil && hostCfg.Offline != nil {
+			h.Offline = offline.New(hostCfg.Offline, h.DiscoInfo, h.Push, router, reps.Offline())
+			instances.all = append(instances.all, h.Offline)
+		}
+		if h.Ping != nil && hostCfg.Ping != nil {
+			h.Ping = xep0199.New(hostCfg.Ping, h.DiscoInfo, router)
+			instances.all = append(instances.all, h.Ping)
+		}
+		instances.hideDisabled(domain, hostEnabled)
 
-	// XEP-0191: Blocking Command (https://xmpp.org/extensions/xep-0191.html)
-	if _, ok := config.Enabled["blocking_command"]; ok {
-		m.BlockingCmd = xep0191.New(m.DiscoInfo, presenceHub, router, reps.Roster(), reps.BlockList())
-		m.iqHandlers = append(m.iqHandlers, m.BlockingCmd)
-		m.all = append(m.all, m.BlockingCmd)
+		h.buildIQHandlers()
+		m.hosts[domain] = h
 	}
-
-	// XEP-0199: XMPP Ping (https://xmpp.org/extensions/xep-0199.html)
-	if _, ok := config.Enabled["ping"]; ok {
-		m.Ping = xep0199.New(&config.Ping, m.DiscoInfo, router)
-		m.iqHandlers = append(m.iqHandlers, m.Ping)
-		m.all = append(m.all, m.Ping)
+	// a single reaper purges expired messages shared by every offline instance
+	if instances.Offline != nil {
+		reaper := offline.NewReaper(reps.Offline(), func(domain string) *offline.Offline { return m.For(domain).Offline })
+		reaper.Start()
+		instances.all = append(instances.all, reaper)
 	}
+	m.buildIQHandlers()
+	m.all = instances.all
+	return m
+}
 
-	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
-	if _, ok := config.Enabled["roster"]; ok {
-		m.iqHandlers = append(m.iqHandlers, presenceHub)
-
-		m.Roster = roster.New(&config.Roster, presenceHub, m.Pep, router, reps.User(), reps.Roster())
-		m.iqHandlers = append(m.iqHandlers, m.Roster)
-		m.all = append(m.all, m.Roster)
+// For returns the set of modules serving a given virtual host domain.
+func (m *Modules) For(domain string) *Modules {
+	if h, ok := m.hosts[domain]; ok {
+		return h
 	}
 	return m
 }
 
 // ProcessIQ process a module IQ returning 'service unavailable' in case it couldn't be properly handled.
+// IQ is dispatched according to its target domain modules configuration.
 func (m *Modules) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	for _, handler := range m.iqHandlers {
+	for _, handler := range m.For(iq.ToJID().Domain()).iqHandlers {
 		if !handler.MatchesIQ(iq) {
 			continue
 		}
@@ -186,3 +183,219 @@ func (m *Modules) shutdown() <-chan bool {
 	}()
 	return c
 }
+
+func newInstances(enabled map[string]struct{}, config *Config, router router.Router, reps repository.Container, allocationID string) *Modules {
+	m := &Modules{
+		router:      router,
+		presenceHub: xep0115.New(router, reps.Presences(), allocationID),
+	}
+
+	// XEP-0030: Service Discovery (https://xmpp.org/extensions/xep-0030.html)
+	m.DiscoInfo = xep0030.New(router, reps.Roster())
+	m.all = append(m.all, m.DiscoInfo)
+	m.discoRegs = make(map[string]xep0030.Registrations)
+	m.discoSnapshot = m.DiscoInfo.Registrations()
+
+	// XEP-0012: Last Activity (https://xmpp.org/extensions/xep-0012.html)
+	if _, ok := enabled["last_activity"]; ok {
+		m.LastActivity = xep0012.New(m.DiscoInfo, router, reps.User(), reps.Roster())
+		m.register("last_activity", m.LastActivity)
+	}
+
+	// XEP-0049: Private XML Storage (https://xmpp.org/extensions/xep-0049.html)
+	if _, ok := enabled["private"]; ok {
+		m.Private = xep0049.New(router, reps.Private())
+		m.register("private", m.Private)
+	}
+
+	// XEP-0054: vcard-temp (https://xmpp.org/extensions/xep-0054.html)
+	if _, ok := enabled["vcard"]; ok {
+		m.VCard = xep0054.New(m.DiscoInfo, router, reps.VCard())
+		m.register("vcard", m.VCard)
+	}
+
+	// XEP-0092: Software Version (https://xmpp.org/extensions/xep-0092.html)
+	if _, ok := enabled["version"]; ok {
+		m.Version = xep0092.New(&config.Version, m.DiscoInfo, router)
+		m.register("version", m.Version)
+	}
+
+	// XEP-0357: Push Notifications (https://xmpp.org/extensions/xep-0357.html)
+	// (instantiated before offline module, so that archived messages can be notified)
+	if _, ok := enabled["push"]; ok {
+		m.Push = xep0357.New(&config.Push, m.DiscoInfo, router, reps.Push())
+		m.register("push", m.Push)
+	}
+
+	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
+	if _, ok := enabled["offline"]; ok {
+		m.Offline = offline.New(&config.Offline, m.DiscoInfo, m.Push, router, reps.Offline())
+		m.register("offline", m.Offline)
+	}
+
+	// XEP-0163: Personal Eventing Protocol (https://xmpp.org/extensions/xep-0163.html)
+	if _, ok := enabled["pep"]; ok {
+		m.Pep = xep0163.New(m.DiscoInfo, m.presenceHub, router, reps.Roster(), reps.PubSub())
+		m.register("pep", m.Pep)
+	}
+
+	// XEP-0191: Blocking Command (https://xmpp.org/extensions/xep-0191.html)
+	if _, ok := enabled["blocking_command"]; ok {
+		m.BlockingCmd = xep0191.New(m.DiscoInfo, m.presenceHub, router, reps.Roster(), reps.BlockList())
+		m.register("blocking_command", m.BlockingCmd)
+	}
+
+	// XEP-0199: XMPP Ping (https://xmpp.org/extensions/xep-0199.html)
+	if _, ok := enabled["ping"]; ok {
+		m.Ping = xep0199.New(&config.Ping, m.DiscoInfo, router)
+		m.register("ping", m.Ping)
+	}
+
+	// XEP-0280: Message Carbons (https://xmpp.org/extensions/xep-0280.html)
+	if _, ok := enabled["carbons"]; ok {
+		m.Carbons = xep0280.New(m.DiscoInfo, router)
+		m.register("carbons", m.Carbons)
+	}
+
+	// XEP-0313: Message Archive Management (https://xmpp.org/extensions/xep-0313.html)
+	if _, ok := enabled["mam"]; ok {
+		m.MAM = xep0313.New(&config.MAM, m.DiscoInfo, router, reps.User(), reps.Roster(), reps.Archive())
+		m.register("mam", m.MAM)
+	}
+
+	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
+	if _, ok := enabled["roster"]; ok {
+		m.Roster = roster.New(&config.Roster, m.presenceHub, m.Pep, router, reps.User(), reps.Roster())
+		m.register("roster", m.Roster)
+	}
+	m.Accounts = account.New(router, m.Roster, reps.User())
+	m.Accounts.Start() // disable expired accounts
+	m.all = append(m.all, m.Accounts)
+
+	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
+	// (instantiated once roster is available, so that cancelled accounts can notify their contacts)
+	if _, ok := enabled["registration"]; ok {
+		m.Register = xep0077.New(&config.Registration, m.DiscoInfo, router, reps.User(), m.Accounts)
+		m.register("registration", m.Register)
+	}
+	return m
+}
+
+// register adds a module instance to m, keeping track of the disco info registrations made during its initialization.
+func (m *Modules) register(mod string, instance Module) {
+	regs := m.DiscoInfo.Registrations()
+	m.discoRegs[mod] = regs.Subtract(m.discoSnapshot)
+	m.discoSnapshot = regs
+	m.all = append(m.all, instance)
+}
+
+// hideDisabled hides from domain disco info every feature registered by a module not contained in enabled set.
+func (m *Modules) hideDisabled(domain string, enabled map[string]struct{}) {
+	var hidden xep0030.Registrations
+	for mod, regs := range m.discoRegs {
+		if _, ok := enabled[mod]; ok {
+			continue
+		}
+		hidden.ServerFeatures = append(hidden.ServerFeatures, regs.ServerFeatures...)
+		hidden.AccountFeatures = append(hidden.AccountFeatures, regs.AccountFeatures...)
+		hidden.AccountNodes = append(hidden.AccountNodes, regs.AccountNodes...)
+	}
+	m.DiscoInfo.Hide(domain, hidden)
+}
+
+// with returns a view of m including only the enabled subset of its modules.
+func (m *Modules) with(enabled map[string]struct{}) *Modules {
+	isEnabled := func(mod string) bool {
+		_, ok := enabled[mod]
+		return ok
+	}
+	v := &Modules{
+		DiscoInfo:   m.DiscoInfo,
+		Accounts:    m.Accounts,
+		presenceHub: m.presenceHub,
+		router:      m.router,
+	}
+	if isEnabled("last_activity") {
+		v.LastActivity = m.LastActivity
+	}
+	if isEnabled("private") {
+		v.Private = m.Private
+	}
+	if isEnabled("vcard") {
+		v.VCard = m.VCard
+	}
+	if isEnabled("registration") {
+		v.Register = m.Register
+	}
+	if isEnabled("version") {
+		v.Version = m.Version
+	}
+	if isEnabled("offline") {
+		v.Offline = m.Offline
+	}
+	if isEnabled("pep") {
+		v.Pep = m.Pep
+	}
+	if isEnabled("blocking_command") {
+		v.BlockingCmd = m.BlockingCmd
+	}
+	if isEnabled("ping") {
+		v.Ping = m.Ping
+	}
+	if isEnabled("carbons") {
+		v.Carbons = m.Carbons
+	}
+	if isEnabled("mam") {
+		v.MAM = m.MAM
+	}
+	if isEnabled("push") {
+		v.Push = m.Push
+	}
+	if isEnabled("roster") {
+		v.Roster = m.Roster
+	}
+	return v
+}
+
+func (m *Modules) buildIQHandlers() {
+	m.iqHandlers = []IQHandler{m.DiscoInfo}
+	if m.LastActivity != nil {
+		m.iqHandlers = append(m.iqHandlers, m.LastActivity)
+	}
+	if m.Private != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Private)
+	}
+	if m.VCard != nil {
+		m.iqHandlers = append(m.iqHandlers, m.VCard)
+	}
+	if m.Register != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Register)
+	}
+	if m.Version != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Version)
+	}
+	if m.Offline != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Offline)
+	}
+	if m.Pep != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Pep)
+	}
+	if m.BlockingCmd != nil {
+		m.iqHandlers = append(m.iqHandlers, m.BlockingCmd)
+	}
+	if m.Ping != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Ping)
+	}
+	if m.Carbons != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Carbons)
+	}
+	if m.MAM != nil {
+		m.iqHandlers = append(m.iqHandlers, m.MAM)
+	}
+	if m.Push != nil {
+		m.iqHandlers = append(m.iqHandlers, m.Push)
+	}
+	if m.Roster != nil {
+		m.iqHandlers = append(m.iqHandlers, m.presenceHub, m.Roster)
+	}
+}
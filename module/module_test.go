@@ -16,6 +16,7 @@ import (
 
 	"github.com/google/uuid"
 	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/module/xep0077"
 	"github.com/sxmpp/jackal/router"
 	"github.com/sxmpp/jackal/storage"
 	"github.com/sxmpp/jackal/stream"
@@ -40,7 +41,7 @@ func TestModules_New(t *testing.T) {
 	mods := setupModules(t)
 	defer func() { _ = mods.Shutdown(context.Background()) }()
 
-	require.Equal(t, 10, len(mods.all))
+	require.Equal(t, 15, len(mods.all))
 }
 
 func TestModules_ProcessIQ(t *testing.T) {
@@ -68,6 +69,119 @@ func TestModules_ProcessIQ(t *testing.T) {
 	require.Equal(t, xmpp.ErrorType, elem.Type())
 }
 
+func TestModules_ProcessIQPerHost(t *testing.T) {
+	var config Config
+	b, err := ioutil.ReadFile("../testdata/config_modules.yml")
+	require.Nil(t, err)
+	err = yaml.Unmarshal(b, &config)
+	require.Nil(t, err)
+
+	config.Hosts = map[string]HostConfig{
+		"jackal.im":   {Enabled: map[string]struct{}{"roster": {}}},
+		"example.org": {Registration: &xep0077.Config{AllowRegistration: true}},
+	}
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}})
+
+	rep, _ := storage.New(&storage.Config{Type: storage.Memory})
+	r, _ := router.New(hosts, c2srouter.New(rep.User(), rep.BlockList()), nil)
+
+	mods := New(&config, r, rep, "alloc-1234")
+	defer func() { _ = mods.Shutdown(context.Background()) }()
+
+	require.Nil(t, mods.For("jackal.im").Register)
+	require.NotNil(t, mods.For("jackal.im").Roster)
+	require.NotNil(t, mods.For("example.org").Register)
+	require.NotEqual(t, mods.Register, mods.For("example.org").Register)
+	require.Equal(t, mods.Register, mods.For("unknown.org").Register)
+
+	j0, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j0)
+	stm.SetPresence(xmpp.NewPresence(j0.ToBareJID(), j0, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	// registration is not enabled for jackal.im
+	iqID := uuid.New().String()
+	iq := xmpp.NewIQType(iqID, xmpp.GetType)
+	iq.SetFromJID(j0)
+	iq.SetToJID(j0.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("query", "jabber:iq:register"))
+	mods.ProcessIQ(context.Background(), iq)
+
+	elem := stm.ReceiveElement()
+	require.NotNil(t, elem)
+	require.Equal(t, iqID, elem.ID())
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("service-unavailable"))
+}
+
+func TestModules_DiscoFeaturesPerHost(t *testing.T) {
+	var config Config
+	b, err := ioutil.ReadFile("../testdata/config_modules.yml")
+	require.Nil(t, err)
+	err = yaml.Unmarshal(b, &config)
+	require.Nil(t, err)
+
+	config.Hosts = map[string]HostConfig{
+		"jackal.im":   {Enabled: map[string]struct{}{"roster": {}, "offline": {}}},
+		"example.org": {Enabled: map[string]struct{}{"roster": {}, "ping": {}, "pep": {}}},
+	}
+	hosts, _ := host.New([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}, {Name: "unknown.org"}})
+
+	rep, _ := storage.New(&storage.Config{Type: storage.Memory})
+	r, _ := router.New(hosts, c2srouter.New(rep.User(), rep.BlockList()), nil)
+
+	mods := New(&config, r, rep, "alloc-1234")
+	defer func() { _ = mods.Shutdown(context.Background()) }()
+
+	j0, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	stm := stream.NewMockC2S(uuid.New().String(), j0)
+	stm.SetPresence(xmpp.NewPresence(j0.ToBareJID(), j0, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	discoFeatures := func(to string, node string) []string {
+		toJID, _ := jid.NewWithString(to, true)
+		q := xmpp.NewElementNamespace("query", "http://jabber.org/protocol/disco#info")
+		if len(node) > 0 {
+			q.SetAttribute("node", node)
+		}
+		iq := xmpp.NewIQType(uuid.New().String(), xmpp.GetType)
+		iq.SetFromJID(j0)
+		iq.SetToJID(toJID)
+		iq.AppendElement(q)
+		mods.ProcessIQ(context.Background(), iq)
+
+		elem := stm.ReceiveElement()
+		require.NotNil(t, elem)
+		var features []string
+		if elem.Type() != xmpp.ResultType {
+			return features
+		}
+		for _, f := range elem.Elements().Child("query").Elements().Children("feature") {
+			features = append(features, f.Attributes().Get("var"))
+		}
+		return features
+	}
+	features := discoFeatures("jackal.im", "")
+	require.Contains(t, features, "msgoffline")
+	require.NotContains(t, features, "urn:xmpp:ping")
+	require.NotContains(t, features, "jabber:iq:version")
+
+	features = discoFeatures("sxmpp@jackal.im", "")
+	require.NotContains(t, features, "urn:xmpp:ping")
+	require.NotContains(t, features, "http://jabber.org/protocol/pubsub#publish")
+	require.Contains(t, discoFeatures("sxmpp@jackal.im", "http://jabber.org/protocol/offline"), "http://jabber.org/protocol/offline")
+
+	features = discoFeatures("example.org", "")
+	require.Contains(t, features, "urn:xmpp:ping")
+	require.NotContains(t, features, "msgoffline")
+
+	// domains lacking their own configuration stick to globally enabled modules
+	features = discoFeatures("unknown.org", "")
+	require.Contains(t, features, "urn:xmpp:ping")
+	require.Contains(t, features, "jabber:iq:version")
+	require.Contains(t, features, "msgoffline")
+}
+
 func TestModules_Shutdown(t *testing.T) {
 	mods := setupModules(t)
 
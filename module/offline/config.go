@@ -1,24 +1,105 @@
 package offline
 
-import "fmt"
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+const (
+	httpGatewayType  = "http"
+	spoolGatewayType = "spool"
+	execGatewayType  = "exec"
+)
 
 const (
-	httpGatewayType = "http"
+	defaultGatewayTimeout    = time.Second * 10
+	defaultGatewayMaxRetries = 5
+	defaultGatewayBackoff    = time.Second
+	defaultGatewayMaxBackoff = time.Minute
+	defaultGatewayQueueSize  = 1024
 )
 
 // Config represents Offline Storage module configuration.
 type Config struct {
 	QueueSize int
-	Gateway   gateway
+
+	// MaxSize defines the maximum number of bytes a user offline queue can hold. Zero value means no size limit.
+	MaxSize int
+
+	// TTL defines offline messages default lifetime. Zero value means messages never expire,
+	// unless an earlier expiration time is requested by sender.
+	TTL time.Duration
+
+	// BounceNotice is the text included into the error returned to sender whenever a message can't be stored or expires.
+	// Expired messages are silently discarded in case it's empty.
+	BounceNotice string
+
+	// Gateway defines an optional external service every archived message is forwarded to.
+	Gateway *GatewayConfig
+}
+
+// GatewayConfig represents offline gateway configuration.
+type GatewayConfig struct {
+	// Type is the gateway type: http, spool or exec.
+	Type string
+
+	// URL, Auth and Headers define http gateway request target and headers.
+	URL     string
+	Auth    string
+	Headers map[string]string
+
+	// Template is an optional JSON payload template. Forwarded messages are encoded as raw XML in case it's empty.
+	Template string
+
+	// Dir is the spool gateway directory.
+	Dir string
+
+	// Command and Args define the program run by exec gateway for every forwarded message.
+	Command string
+	Args    []string
+
+	// Timeout bounds every http request or command execution.
+	Timeout time.Duration
+
+	// MaxRetries, Backoff and MaxBackoff define how failed deliveries are retried.
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// QueueSize defines how many messages can be pending delivery.
+	QueueSize int
+
+	// DeadLetter is the path of the log file undeliverable messages are appended to.
+	// They're just logged as errors in case it's empty.
+	DeadLetter string
+
+	tmpl *template.Template
 }
 
 type configProxy struct {
-	QueueSize int `yaml:"queue_size"`
-	Gateway   *struct {
-		Type string `yaml:"type"`
-		Auth string `yaml:"auth"`
-		Pass string `yaml:"pass"`
-	} `yaml:"gateway"`
+	QueueSize    int                 `yaml:"queue_size"`
+	MaxSize      int                 `yaml:"max_size"`
+	TTL          int                 `yaml:"ttl"`
+	BounceNotice string              `yaml:"bounce_notice"`
+	Gateway      *gatewayConfigProxy `yaml:"gateway"`
+}
+
+type gatewayConfigProxy struct {
+	Type       string            `yaml:"type"`
+	Auth       string            `yaml:"auth"`
+	Pass       string            `yaml:"pass"`
+	Headers    map[string]string `yaml:"headers"`
+	Template   string            `yaml:"template"`
+	Dir        string            `yaml:"dir"`
+	Command    string            `yaml:"command"`
+	Args       []string          `yaml:"args"`
+	Timeout    int               `yaml:"timeout"`
+	MaxRetries *int              `yaml:"max_retries"`
+	Backoff    int               `yaml:"backoff"`
+	MaxBackoff int               `yaml:"max_backoff"`
+	QueueSize  int               `yaml:"queue_size"`
+	DeadLetter string            `yaml:"dead_letter"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -27,14 +108,94 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&p); err != nil {
 		return err
 	}
+	if p.MaxSize < 0 {
+		return fmt.Errorf("offline.Config: max size must be a positive value")
+	}
+	if p.TTL < 0 {
+		return fmt.Errorf("offline.Config: ttl must be a positive value")
+	}
 	cfg.QueueSize = p.QueueSize
+	cfg.MaxSize = p.MaxSize
+	cfg.TTL = time.Duration(p.TTL) * time.Second
+	cfg.BounceNotice = p.BounceNotice
 	if p.Gateway != nil {
-		switch p.Gateway.Type {
-		case httpGatewayType:
-			cfg.Gateway = newHTTPGateway(p.Gateway.Pass, p.Gateway.Auth)
-		default:
-			return fmt.Errorf("unrecognized offline gateway type: %s", p.Gateway.Type)
+		gwCfg, err := newGatewayConfig(p.Gateway)
+		if err != nil {
+			return err
+		}
+		cfg.Gateway = gwCfg
+	}
+	return nil
+}
+
+func newGatewayConfig(p *gatewayConfigProxy) (*GatewayConfig, error) {
+	switch p.Type {
+	case httpGatewayType:
+		if len(p.Pass) == 0 {
+			return nil, fmt.Errorf("offline.Config: http gateway pass URL must be specified")
+		}
+	case spoolGatewayType:
+		if len(p.Dir) == 0 {
+			return nil, fmt.Errorf("offline.Config: spool gateway directory must be specified")
+		}
+	case execGatewayType:
+		if len(p.Command) == 0 {
+			return nil, fmt.Errorf("offline.Config: exec gateway command must be specified")
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized offline gateway type: %s", p.Type)
+	}
+	if p.Timeout < 0 || p.Backoff < 0 || p.MaxBackoff < 0 || p.QueueSize < 0 || (p.MaxRetries != nil && *p.MaxRetries < 0) {
+		return nil, fmt.Errorf("offline.Config: gateway timeout, retries, backoff and queue size must be positive values")
+	}
+	cfg := &GatewayConfig{
+		Type:       p.Type,
+		URL:        p.Pass,
+		Auth:       p.Auth,
+		Headers:    p.Headers,
+		Template:   p.Template,
+		Dir:        p.Dir,
+		Command:    p.Command,
+		Args:       p.Args,
+		Timeout:    time.Duration(p.Timeout) * time.Second,
+		MaxRetries: defaultGatewayMaxRetries,
+		Backoff:    time.Duration(p.Backoff) * time.Second,
+		MaxBackoff: time.Duration(p.MaxBackoff) * time.Second,
+		QueueSize:  p.QueueSize,
+		DeadLetter: p.DeadLetter,
+	}
+	if p.MaxRetries != nil {
+		cfg.MaxRetries = *p.MaxRetries
+	}
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setDefaults fills unset gateway configuration values, parsing payload template.
+func (cfg *GatewayConfig) setDefaults() error {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultGatewayTimeout
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = defaultGatewayBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultGatewayMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = defaultGatewayQueueSize
+	}
+	if len(cfg.Template) > 0 && cfg.tmpl == nil {
+		tmpl, err := template.New("payload").Funcs(payloadFuncs).Parse(cfg.Template)
+		if err != nil {
+			return fmt.Errorf("offline.Config: invalid gateway template: %v", err)
 		}
+		cfg.tmpl = tmpl
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package offline
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -37,4 +38,74 @@ gateway:
 	cfg = &Config{}
 	err = yaml.Unmarshal([]byte(goodGatewayTypeCfg), &cfg)
 	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`max_size: -1`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`ttl: -1`), &cfg)
+	require.NotNil(t, err)
+
+	quotaCfg := `
+queue_size: 100
+max_size: 1048576
+ttl: 3600
+bounce_notice: Message expired
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(quotaCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 1048576, cfg.MaxSize)
+	require.Equal(t, time.Hour, cfg.TTL)
+	require.Equal(t, "Message expired", cfg.BounceNotice)
+
+	gatewayCfgs := []string{
+		"gateway:\n  type: http\n",
+		"gateway:\n  type: spool\n",
+		"gateway:\n  type: exec\n",
+		"gateway:\n  type: http\n  pass: http://127.0.0.1:6666\n  template: '{{.Foo'\n",
+		"gateway:\n  type: spool\n  dir: /tmp\n  max_retries: -1\n",
+	}
+	for _, gatewayCfg := range gatewayCfgs {
+		cfg = &Config{}
+		err = yaml.Unmarshal([]byte(gatewayCfg), &cfg)
+		require.NotNil(t, err)
+	}
+
+	httpGatewayCfg := `
+gateway:
+  type: http
+  pass: http://127.0.0.1:6666
+  headers:
+    X-Api-Key: a-secret-key
+  template: '{"body": {{json .Body}}}'
+  dead_letter: /var/log/jackal/dead_letter.log
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(httpGatewayCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "http://127.0.0.1:6666", cfg.Gateway.URL)
+	require.Equal(t, "a-secret-key", cfg.Gateway.Headers["X-Api-Key"])
+	require.NotNil(t, cfg.Gateway.tmpl)
+	require.Equal(t, defaultGatewayMaxRetries, cfg.Gateway.MaxRetries)
+	require.Equal(t, defaultGatewayBackoff, cfg.Gateway.Backoff)
+	require.Equal(t, defaultGatewayQueueSize, cfg.Gateway.QueueSize)
+
+	execGatewayCfg := `
+gateway:
+  type: exec
+  command: /usr/local/bin/notify
+  args: ["--offline"]
+  max_retries: 0
+  backoff: 2
+  max_backoff: 30
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(execGatewayCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, []string{"--offline"}, cfg.Gateway.Args)
+	require.Equal(t, 0, cfg.Gateway.MaxRetries)
+	require.Equal(t, 2*time.Second, cfg.Gateway.Backoff)
+	require.Equal(t, 30*time.Second, cfg.Gateway.MaxBackoff)
 }
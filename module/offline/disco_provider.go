@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// discoInfoProvider serves flexible offline message retrieval node of every local account (XEP-0013).
+type discoInfoProvider struct {
+	router     router.Router
+	offlineRep repository.Offline
+}
+
+func (p *discoInfoProvider) Identities(_ context.Context, _, _ *jid.JID, _ string) []xep0030.Identity {
+	return []xep0030.Identity{{Type: "message-list", Category: "automation"}}
+}
+
+func (p *discoInfoProvider) Features(_ context.Context, toJID, fromJID *jid.JID, _ string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if !isOwnAccount(toJID, fromJID) {
+		return nil, xmpp.ErrForbidden
+	}
+	return []xep0030.Feature{flexibleOfflineNamespace}, nil
+}
+
+func (p *discoInfoProvider) Form(ctx context.Context, toJID, fromJID *jid.JID, _ string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if !isOwnAccount(toJID, fromJID) {
+		return nil, xmpp.ErrForbidden
+	}
+	count, err := p.offlineRep.CountOfflineMessages(ctx, fromJID.Node(), fromJID.Domain())
+	if err != nil {
+		log.Error(err)
+		return nil, xmpp.ErrInternalServerError
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{flexibleOfflineNamespace}},
+			{Var: "number_of_messages", Values: []string{strconv.Itoa(count)}},
+		},
+	}, nil
+}
+
+// Items returns offline message headers, identifying each message by its node.
+func (p *discoInfoProvider) Items(ctx context.Context, toJID, fromJID *jid.JID, _ string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if !isOwnAccount(toJID, fromJID) {
+		return nil, xmpp.ErrForbidden
+	}
+	// requesting headers implies flexible offline message retrieval
+	if stm := p.router.LocalStream(fromJID.Node(), fromJID.Domain(), fromJID.Resource()); stm != nil {
+		setFlexibleRetrieval(stm)
+	}
+	messages, err := p.offlineRep.FetchOfflineMessages(ctx, fromJID.Node(), fromJID.Domain())
+	if err != nil {
+		log.Error(err)
+		return nil, xmpp.ErrInternalServerError
+	}
+	userJID := fromJID.ToBareJID().String()
+
+	var items []xep0030.Item
+	for _, msg := range pendingMessages(messages, time.Now()) {
+		items = append(items, xep0030.Item{
+			Jid:  userJID,
+			Node: msg.ID,
+			Name: msg.Message.From(),
+		})
+	}
+	return items, nil
+}
+
+func isOwnAccount(toJID, fromJID *jid.JID) bool {
+	return toJID.Node() == fromJID.Node() && toJID.Domain() == fromJID.Domain()
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// execGateway runs an external command for every forwarded message, writing it to the command standard input.
+// Message addressing is also available through JACKAL_MESSAGE_ID, JACKAL_FROM and JACKAL_TO environment variables.
+type execGateway struct {
+	command string
+	args    []string
+	timeout time.Duration
+	enc     *payloadEncoder
+}
+
+func newExecGateway(command string, args []string, timeout time.Duration, enc *payloadEncoder) gateway {
+	return &execGateway{
+		command: command,
+		args:    args,
+		timeout: timeout,
+		enc:     enc,
+	}
+}
+
+func (g *execGateway) Route(msg *xmpp.Message) error {
+	payload, err := g.enc.encode(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, g.command, g.args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"JACKAL_MESSAGE_ID="+msg.ID(),
+		"JACKAL_FROM="+msg.From(),
+		"JACKAL_TO="+msg.To(),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
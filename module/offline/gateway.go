@@ -2,17 +2,99 @@ package offline
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"text/template"
+	"time"
 
 	"github.com/sxmpp/jackal/xmpp"
 	"github.com/sony/gobreaker"
 )
 
+var errInvalidPayload = errors.New("offline gateway: rendered payload is not valid JSON")
+
 type gateway interface {
 	Route(msg *xmpp.Message) error
 }
 
+// newGateway returns the gateway associated to a configuration.
+func newGateway(cfg *GatewayConfig) gateway {
+	enc := &payloadEncoder{tmpl: cfg.tmpl}
+	switch cfg.Type {
+	case spoolGatewayType:
+		return newSpoolGateway(cfg.Dir, enc)
+	case execGatewayType:
+		return newExecGateway(cfg.Command, cfg.Args, cfg.Timeout, enc)
+	default:
+		return newHTTPGateway(cfg.URL, cfg.Auth, cfg.Headers, cfg.Timeout, enc)
+	}
+}
+
+// payloadFuncs contains those functions available to payload templates.
+var payloadFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+}
+
+// payloadMessage represents the forwarded message data available to payload templates.
+type payloadMessage struct {
+	ID     string
+	Type   string
+	From   string
+	To     string
+	Body   string
+	Thread string
+}
+
+// payloadEncoder encodes forwarded messages, either rendering a JSON template or as raw XML.
+type payloadEncoder struct {
+	tmpl *template.Template
+}
+
+func (e *payloadEncoder) encode(msg *xmpp.Message) ([]byte, error) {
+	if e.tmpl == nil {
+		return []byte(msg.String()), nil
+	}
+	var pm payloadMessage
+	pm.ID = msg.ID()
+	pm.Type = msg.Type()
+	pm.From = msg.From()
+	pm.To = msg.To()
+	if body := msg.Elements().Child("body"); body != nil {
+		pm.Body = body.Text()
+	}
+	if thread := msg.Elements().Child("thread"); thread != nil {
+		pm.Thread = thread.Text()
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := e.tmpl.Execute(buf, &pm); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errInvalidPayload
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *payloadEncoder) contentType() string {
+	if e.tmpl == nil {
+		return "application/xml"
+	}
+	return "application/json"
+}
+
+func (e *payloadEncoder) fileExt() string {
+	if e.tmpl == nil {
+		return ".xml"
+	}
+	return ".json"
+}
+
 type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -20,32 +102,45 @@ type httpClient interface {
 type httpGateway struct {
 	url       string
 	authToken string
-	reqBuf    *bytes.Buffer
+	headers   map[string]string
+	timeout   time.Duration
+	enc       *payloadEncoder
 	cb        *gobreaker.CircuitBreaker
 	client    httpClient
 }
 
-func newHTTPGateway(url string, authToken string) gateway {
+func newHTTPGateway(url, authToken string, headers map[string]string, timeout time.Duration, enc *payloadEncoder) gateway {
 	return &httpGateway{
 		url:       url,
 		authToken: authToken,
-		reqBuf:    bytes.NewBuffer(nil),
+		headers:   headers,
+		timeout:   timeout,
+		enc:       enc,
 		cb:        gobreaker.NewCircuitBreaker(gobreaker.Settings{}),
 		client:    &http.Client{},
 	}
 }
 
 func (g *httpGateway) Route(msg *xmpp.Message) error {
-	msg.ToXML(g.reqBuf, true)
-	defer g.reqBuf.Reset()
-
-	req, err := http.NewRequest(http.MethodPost, g.url, g.reqBuf)
+	payload, err := g.enc.encode(msg)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/xml")
-	req.Header.Set("Authorization", g.authToken)
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
 
+	req, err := http.NewRequest(http.MethodPost, g.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", g.enc.contentType())
+	if len(g.authToken) > 0 {
+		req.Header.Set("Authorization", g.authToken)
+	}
+	for k, v := range g.headers {
+		req.Header.Set(k, v)
+	}
 	_, err = g.cb.Execute(func() (i interface{}, e error) {
 		resp, err := g.client.Do(req)
 		if err != nil {
@@ -53,7 +148,7 @@ func (g *httpGateway) Route(msg *xmpp.Message) error {
 		}
 		defer func() { _ = resp.Body.Close() }()
 
-		if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 			return nil, fmt.Errorf("response status code: %d", resp.StatusCode)
 		}
 		return nil, nil
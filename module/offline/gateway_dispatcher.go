@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+var (
+	errGatewayQueueFull = errors.New("offline gateway: delivery queue is full")
+	errGatewayShutdown  = errors.New("offline gateway: shut down before delivery")
+)
+
+// gatewayDispatcher asynchronously forwards messages through a gateway, in order,
+// retrying failed deliveries with exponential backoff.
+// Messages that can't be delivered are appended to a dead-letter log.
+type gatewayDispatcher struct {
+	gw         gateway
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	deadLetter *deadLetterLog
+	queue      chan *xmpp.Message
+	stopOnce   sync.Once
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+}
+
+func newGatewayDispatcher(gw gateway, cfg *GatewayConfig) *gatewayDispatcher {
+	d := &gatewayDispatcher{
+		gw:         gw,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.Backoff,
+		maxBackoff: cfg.MaxBackoff,
+		deadLetter: &deadLetterLog{path: cfg.DeadLetter},
+		queue:      make(chan *xmpp.Message, cfg.QueueSize),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// Route enqueues a message for delivery.
+func (d *gatewayDispatcher) Route(msg *xmpp.Message) error {
+	select {
+	case <-d.stopCh:
+		d.deadLetter.write(msg, errGatewayShutdown)
+		return errGatewayShutdown
+	default:
+	}
+	select {
+	case d.queue <- msg:
+		return nil
+	default:
+		d.deadLetter.write(msg, errGatewayQueueFull)
+		return errGatewayQueueFull
+	}
+}
+
+// close stops delivering messages, moving those still pending into the dead-letter log.
+func (d *gatewayDispatcher) close() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+		<-d.doneCh
+
+		for {
+			select {
+			case msg := <-d.queue:
+				d.deadLetter.write(msg, errGatewayShutdown)
+			default:
+				return
+			}
+		}
+	})
+}
+
+func (d *gatewayDispatcher) loop() {
+	defer close(d.doneCh)
+	for {
+		select {
+		case msg := <-d.queue:
+			d.deliver(msg)
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+func (d *gatewayDispatcher) deliver(msg *xmpp.Message) {
+	for retry := 0; ; retry++ {
+		err := d.gw.Route(msg)
+		if err == nil {
+			return
+		}
+		if retry >= d.maxRetries {
+			log.Errorf("offline gateway: giving up on message %s after %d retries: %v", msg.ID(), retry, err)
+			d.deadLetter.write(msg, err)
+			return
+		}
+		log.Warnf("offline gateway: failed to deliver message %s: %v", msg.ID(), err)
+
+		select {
+		case <-time.After(d.backoffDelay(retry)):
+		case <-d.stopCh:
+			d.deadLetter.write(msg, errGatewayShutdown)
+			return
+		}
+	}
+}
+
+// backoffDelay returns the time to wait before a given retry, doubling it each time up to max backoff.
+func (d *gatewayDispatcher) backoffDelay(retry int) time.Duration {
+	delay := d.backoff
+	for i := 0; i < retry && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.maxBackoff {
+		delay = d.maxBackoff
+	}
+	return delay
+}
+
+// deadLetterLog appends undeliverable messages to a log file, one JSON entry per line.
+type deadLetterLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+type deadLetterEntry struct {
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error"`
+	Message string    `json:"message"`
+}
+
+func (l *deadLetterLog) write(msg *xmpp.Message, cause error) {
+	if len(l.path) == 0 {
+		log.Errorf("offline gateway: undeliverable message: %s (%v)", msg, cause)
+		return
+	}
+	b, err := json.Marshal(&deadLetterEntry{Time: time.Now().UTC(), Error: cause.Error(), Message: msg.String()})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Errorf("offline gateway: unable to open dead-letter log: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		log.Errorf("offline gateway: unable to write dead-letter log: %v", err)
+	}
+}
@@ -1,12 +1,19 @@
 package offline
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -24,20 +31,41 @@ func (c *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	return c.do(req)
 }
 
+type fakeGateway struct {
+	mu       sync.Mutex
+	failures int
+	routed   []*xmpp.Message
+	attempts int
+	routedCh chan struct{}
+}
+
+func (g *fakeGateway) Route(msg *xmpp.Message) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.attempts++
+	if g.failures > 0 {
+		g.failures--
+		return errors.New("foo error")
+	}
+	g.routed = append(g.routed, msg)
+	g.routedCh <- struct{}{}
+	return nil
+}
+
+const testPayloadTemplate = `{"from": {{json .From}}, "to": {{json .To}}, "body": {{json .Body}}, "thread": {{json .Thread}}}`
+
 func TestHttpGateway_Route(t *testing.T) {
-	g := newHTTPGateway("http://127.0.0.1:6666", "a-secret-key").(*httpGateway)
+	g := newHTTPGateway("http://127.0.0.1:6666", "a-secret-key", map[string]string{"X-Api-Key": "k1"}, time.Second, &payloadEncoder{}).(*httpGateway)
 	fakeClient := &fakeHTTPClient{}
 	g.client = fakeClient
 
-	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
-	body := xmpp.NewElementName("body")
-	body.SetText("This is an offline message!")
-	msg.AppendElement(body)
+	msg := testGatewayMessage()
 
 	var reqBody string
 	fakeClient.do = func(req *http.Request) (response *http.Response, e error) {
 		require.Equal(t, http.MethodPost, req.Method)
 		require.Equal(t, "a-secret-key", req.Header.Get("Authorization"))
+		require.Equal(t, "k1", req.Header.Get("X-Api-Key"))
 		require.Equal(t, "application/xml", req.Header.Get("Content-Type"))
 
 		b, _ := ioutil.ReadAll(req.Body)
@@ -59,3 +87,155 @@ func TestHttpGateway_Route(t *testing.T) {
 	}
 	require.NotNil(t, g.Route(msg))
 }
+
+func TestHttpGateway_RouteTemplate(t *testing.T) {
+	cfg := &GatewayConfig{Type: httpGatewayType, URL: "http://127.0.0.1:6666", Template: testPayloadTemplate}
+	require.Nil(t, cfg.setDefaults())
+
+	g := newGateway(cfg).(*httpGateway)
+	fakeClient := &fakeHTTPClient{}
+	g.client = fakeClient
+
+	var payload map[string]string
+	fakeClient.do = func(req *http.Request) (response *http.Response, e error) {
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.Equal(t, "", req.Header.Get("Authorization"))
+
+		b, _ := ioutil.ReadAll(req.Body)
+		require.Nil(t, json.Unmarshal(b, &payload))
+		return &http.Response{StatusCode: http.StatusAccepted, Body: &fakeReadCloser{}}, nil
+	}
+	require.Nil(t, g.Route(testGatewayMessage()))
+	require.Equal(t, "romeo@jackal.im/garden", payload["from"])
+	require.Equal(t, "juliet@jackal.im", payload["to"])
+	require.Equal(t, `Wherefore art thou "Romeo"?`, payload["body"])
+	require.Equal(t, "t1", payload["thread"])
+
+	// rendered payload must be valid JSON
+	cfg = &GatewayConfig{Type: httpGatewayType, URL: "http://127.0.0.1:6666", Template: `{"body": "{{.Body}}"}`}
+	require.Nil(t, cfg.setDefaults())
+
+	g = newGateway(cfg).(*httpGateway)
+	require.Equal(t, errInvalidPayload, g.Route(testGatewayMessage()))
+}
+
+func TestSpoolGateway_Route(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jackal-spool")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	spoolDir := filepath.Join(dir, "offline")
+
+	g := newSpoolGateway(spoolDir, &payloadEncoder{})
+
+	msg := testGatewayMessage()
+	require.Nil(t, g.Route(msg))
+	require.Nil(t, g.Route(msg))
+
+	files, _ := ioutil.ReadDir(spoolDir)
+	require.Len(t, files, 2)
+	require.True(t, strings.HasSuffix(files[0].Name(), ".xml"))
+
+	b, _ := ioutil.ReadFile(filepath.Join(spoolDir, files[0].Name()))
+	require.Equal(t, msg.String(), string(b))
+}
+
+func TestExecGateway_Route(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jackal-exec")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	out := filepath.Join(dir, "out")
+
+	g := newExecGateway("sh", []string{"-c", `cat > "$0" && echo "$JACKAL_TO" >> "$0"`, out}, time.Second, &payloadEncoder{})
+
+	msg := testGatewayMessage()
+	require.Nil(t, g.Route(msg))
+
+	b, _ := ioutil.ReadFile(out)
+	require.Equal(t, msg.String()+"juliet@jackal.im\n", string(b))
+
+	g = newExecGateway("sh", []string{"-c", "echo failed && exit 1"}, time.Second, &payloadEncoder{})
+	err := g.Route(msg)
+	require.NotNil(t, err)
+	require.True(t, strings.HasSuffix(err.Error(), "failed"))
+}
+
+func TestGatewayDispatcher_Retries(t *testing.T) {
+	gw := &fakeGateway{failures: 2, routedCh: make(chan struct{}, 1)}
+
+	d := newGatewayDispatcher(gw, &GatewayConfig{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond * 4, QueueSize: 10})
+	defer d.close()
+
+	msg := testGatewayMessage()
+	require.Nil(t, d.Route(msg))
+
+	select {
+	case <-gw.routedCh:
+		break
+	case <-time.After(time.Second):
+		require.Fail(t, "message not routed")
+	}
+	gw.mu.Lock()
+	require.Equal(t, 3, gw.attempts)
+	require.Equal(t, msg, gw.routed[0])
+	gw.mu.Unlock()
+
+	require.Equal(t, time.Millisecond, d.backoffDelay(0))
+	require.Equal(t, time.Millisecond*2, d.backoffDelay(1))
+	require.Equal(t, time.Millisecond*4, d.backoffDelay(2))
+	require.Equal(t, time.Millisecond*4, d.backoffDelay(10))
+}
+
+func TestGatewayDispatcher_DeadLetter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jackal-dead-letter")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	deadLetter := filepath.Join(dir, "dead_letter.log")
+
+	gw := &fakeGateway{failures: 2, routedCh: make(chan struct{}, 1)}
+
+	d := newGatewayDispatcher(gw, &GatewayConfig{MaxRetries: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, QueueSize: 10, DeadLetter: deadLetter})
+
+	msg := testGatewayMessage()
+	require.Nil(t, d.Route(msg))
+
+	// wait for retries to be exhausted
+	for i := 0; i < 100; i++ {
+		if b, _ := ioutil.ReadFile(deadLetter); len(b) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	d.close()
+
+	// closed dispatcher
+	require.Equal(t, errGatewayShutdown, d.Route(msg))
+
+	b, _ := ioutil.ReadFile(deadLetter)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+
+	var entry deadLetterEntry
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "foo error", entry.Error)
+	require.Equal(t, msg.String(), entry.Message)
+
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.Equal(t, errGatewayShutdown.Error(), entry.Error)
+}
+
+func testGatewayMessage() *xmpp.Message {
+	from, _ := jid.NewWithString("romeo@jackal.im/garden", true)
+	to, _ := jid.NewWithString("juliet@jackal.im", true)
+
+	msg := xmpp.NewMessageType(uuid.New().String(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xmpp.NewElementName("body")
+	body.SetText(`Wherefore art thou "Romeo"?`)
+	msg.AppendElement(body)
+	thread := xmpp.NewElementName("thread")
+	thread.SetText("t1")
+	msg.AppendElement(thread)
+	return msg
+}
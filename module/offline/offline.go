@@ -7,51 +7,104 @@ package offline
 
 import (
 	"context"
+	"time"
 
 	"github.com/sxmpp/jackal/log"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/module/xep0357"
 	"github.com/sxmpp/jackal/router"
 	"github.com/sxmpp/jackal/storage/repository"
 	"github.com/sxmpp/jackal/stream"
 	"github.com/sxmpp/jackal/util/runqueue"
 	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
 )
 
-const offlineNamespace = "msgoffline"
+const (
+	offlineNamespace         = "msgoffline"
+	flexibleOfflineNamespace = "http://jabber.org/protocol/offline"
+)
 
-const hintsNamespace = "urn:xmpp:hints"
+const (
+	hintsNamespace = "urn:xmpp:hints"
+	ampNamespace   = "http://jabber.org/protocol/amp"
+)
 
-const offlineDeliveredCtxKey = "offline:delivered"
+const (
+	offlineDeliveredCtxKey = "offline:delivered"
+	offlineFlexibleCtxKey  = "offline:flexible"
+)
 
 // Offline represents an offline server stream module.
 type Offline struct {
 	cfg        *Config
 	runQueue   *runqueue.RunQueue
 	router     router.Router
+	push       *xep0357.Push
 	offlineRep repository.Offline
+	gateway    *gatewayDispatcher
 }
 
 // New returns an offline server stream module.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, offlineRep repository.Offline) *Offline {
+// In case push is not nil, app servers will be notified of every archived message.
+func New(config *Config, disco *xep0030.DiscoInfo, push *xep0357.Push, router router.Router, offlineRep repository.Offline) *Offline {
 	r := &Offline{
 		cfg:        config,
-		runQueue:   runqueue.New("xep0030"),
+		runQueue:   runqueue.New("offline"),
 		router:     router,
+		push:       push,
 		offlineRep: offlineRep,
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(offlineNamespace)
+		disco.RegisterServerFeature(flexibleOfflineNamespace)
+		disco.RegisterAccountNodeProvider(flexibleOfflineNamespace, &discoInfoProvider{
+			router:     router,
+			offlineRep: offlineRep,
+		})
+	}
+	if config.Gateway != nil {
+		if err := config.Gateway.setDefaults(); err != nil {
+			log.Error(err)
+		} else {
+			r.gateway = newGatewayDispatcher(newGateway(config.Gateway), config.Gateway)
+		}
 	}
 	return r
 }
 
+// MatchesIQ returns whether or not an IQ should be processed by the offline module.
+func (x *Offline) MatchesIQ(iq *xmpp.IQ) bool {
+	return iq.Elements().ChildNamespace("offline", flexibleOfflineNamespace) != nil
+}
+
+// ProcessIQ processes a flexible offline message retrieval IQ taking according actions over the associated stream.
+func (x *Offline) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource())
+		if stm == nil {
+			return
+		}
+		x.processIQ(ctx, iq, stm)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
+	})
+}
+
 // ArchiveMessage archives a new offline messages into the storage.
 func (x *Offline) ArchiveMessage(ctx context.Context, message *xmpp.Message) {
-	x.runQueue.Run(func() { x.archiveMessage(ctx, message) })
+	_ = x.runQueue.TryRun(func() {
+		x.archiveMessage(ctx, message)
+	}, func() {
+		_ = x.router.Route(context.Background(), message.ResourceConstraintError())
+	})
 }
 
 // DeliverOfflineMessages delivers every archived offline messages to the peer
-// deleting them from storage.
+// deleting them from storage, unless flexible offline message retrieval was requested.
 func (x *Offline) DeliverOfflineMessages(ctx context.Context, stm stream.C2S) {
 	x.runQueue.Run(func() { x.deliverOfflineMessages(ctx, stm) })
 }
@@ -61,6 +114,11 @@ func (x *Offline) Shutdown() error {
 	c := make(chan struct{})
 	x.runQueue.Stop(func() { close(c) })
 	<-c
+
+	// no more messages will be archived at this point
+	if x.gateway != nil {
+		x.gateway.close()
+	}
 	return nil
 }
 
@@ -68,27 +126,51 @@ func (x *Offline) archiveMessage(ctx context.Context, message *xmpp.Message) {
 	if !isMessageArchivable(message) {
 		return
 	}
+	expiresAt := x.expirationTime(message)
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		if len(x.cfg.BounceNotice) > 0 {
+			x.bounce(ctx, message, xmpp.ErrRecipientUnavailable)
+		}
+		return
+	}
 	toJID := message.ToJID()
-	queueSize, err := x.offlineRep.CountOfflineMessages(ctx, toJID.Node())
+	queueSize, err := x.offlineRep.CountOfflineMessages(ctx, toJID.Node(), toJID.Domain())
 	if err != nil {
 		log.Error(err)
 		return
 	}
 	if queueSize >= x.cfg.QueueSize {
-		_ = x.router.Route(ctx, message.ServiceUnavailableError())
+		x.bounce(ctx, message, xmpp.ErrServiceUnavailable)
 		return
 	}
 	delayed, _ := xmpp.NewMessageFromElement(message, message.FromJID(), message.ToJID())
 	delayed.Delay(message.FromJID().Domain(), "Offline Storage")
-	if err := x.offlineRep.InsertOfflineMessage(ctx, delayed, toJID.Node()); err != nil {
+
+	offlineMsg := &offlinemodel.Message{ID: uuid.New(), ExpiresAt: expiresAt, Message: delayed}
+	if x.cfg.MaxSize > 0 {
+		size, err := x.offlineRep.FetchOfflineMessagesSize(ctx, toJID.Node(), toJID.Domain())
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if size+offlineMsg.Size() > x.cfg.MaxSize {
+			x.bounce(ctx, message, xmpp.ErrServiceUnavailable)
+			return
+		}
+	}
+	if err := x.offlineRep.InsertOfflineMessage(ctx, offlineMsg, toJID.Node(), toJID.Domain()); err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, message.InternalServerError())
 		return
 	}
 	log.Infof("archived offline message... id: %s", message.ID())
 
-	if x.cfg.Gateway != nil {
-		if err := x.cfg.Gateway.Route(message); err != nil {
+	if x.push != nil {
+		x.push.NotifyMessage(ctx, message, queueSize+1)
+	}
+
+	if x.gateway != nil {
+		if err := x.gateway.Route(message); err != nil {
 			log.Errorf("bad offline gateway: %v", err)
 		}
 	}
@@ -99,9 +181,12 @@ func (x *Offline) deliverOfflineMessages(ctx context.Context, stm stream.C2S) {
 	if delivered {
 		return // already delivered
 	}
+	if isFlexibleRetrieval(stm) {
+		return // messages will be explicitly requested by client
+	}
 	// deliver offline messages
 	userJID := stm.JID()
-	messages, err := x.offlineRep.FetchOfflineMessages(ctx, userJID.Node())
+	messages, err := x.offlineRep.FetchOfflineMessages(ctx, userJID.Node(), userJID.Domain())
 	if err != nil {
 		log.Error(err)
 		return
@@ -111,15 +196,225 @@ func (x *Offline) deliverOfflineMessages(ctx context.Context, stm stream.C2S) {
 	}
 	log.Infof("delivering offline messages: %s... count: %d", userJID, len(messages))
 
+	// only remove those messages that have been successfully routed
+	// (expired ones are left to be purged)
+	now := time.Now()
+
+	var ids []string
 	for i := 0; i < len(messages); i++ {
-		_ = x.router.Route(ctx, &messages[i])
+		if messages[i].IsExpired(now) {
+			continue
+		}
+		if err := x.router.Route(ctx, messages[i].Message); err != nil {
+			log.Errorf("failed to deliver offline message: %v", err)
+			continue
+		}
+		ids = append(ids, messages[i].ID)
 	}
-	if err := x.offlineRep.DeleteOfflineMessages(ctx, userJID.Node()); err != nil {
-		log.Error(err)
+	if len(ids) > 0 {
+		if err := x.offlineRep.DeleteOfflineMessagesByID(ctx, ids, userJID.Node(), userJID.Domain()); err != nil {
+			log.Error(err)
+		}
 	}
 	stm.SetValue(offlineDeliveredCtxKey, true)
 }
 
+func (x *Offline) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	toJID := iq.ToJID()
+	validTo := toJID.IsServer() || (toJID.Node() == stm.Username() && toJID.Domain() == stm.Domain())
+	if !validTo {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	setFlexibleRetrieval(stm)
+
+	offline := iq.Elements().ChildNamespace("offline", flexibleOfflineNamespace)
+	switch {
+	case offline.Elements().Child("fetch") != nil:
+		if !iq.IsGet() {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		x.viewMessages(ctx, iq, nil, stm)
+
+	case offline.Elements().Child("purge") != nil:
+		if !iq.IsSet() {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		x.purgeMessages(ctx, iq, stm)
+
+	default:
+		items := offline.Elements().Children("item")
+		if len(items) == 0 {
+			stm.SendElement(ctx, iq.BadRequestError())
+			return
+		}
+		var nodes []string
+		for _, item := range items {
+			action := item.Attributes().Get("action")
+			validAction := (action == "view" && iq.IsGet()) || (action == "remove" && iq.IsSet())
+
+			node := item.Attributes().Get("node")
+			if !validAction || len(node) == 0 {
+				stm.SendElement(ctx, iq.BadRequestError())
+				return
+			}
+			nodes = append(nodes, node)
+		}
+		if iq.IsGet() {
+			x.viewMessages(ctx, iq, nodes, stm)
+		} else {
+			x.removeMessages(ctx, iq, nodes, stm)
+		}
+	}
+}
+
+// viewMessages sends to the requesting resource those offline messages identified by nodes,
+// or the whole offline queue in case nodes is nil. Messages are kept in storage until removed.
+func (x *Offline) viewMessages(ctx context.Context, iq *xmpp.IQ, nodes []string, stm stream.C2S) {
+	messages, sErr := x.fetchMessages(ctx, nodes, stm)
+	if sErr != nil {
+		stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+		return
+	}
+	log.Infof("retrieving offline messages: %s... count: %d", stm.JID(), len(messages))
+
+	for i := range messages {
+		stm.SendElement(ctx, flexibleMessage(&messages[i], stm.JID()))
+	}
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func (x *Offline) removeMessages(ctx context.Context, iq *xmpp.IQ, nodes []string, stm stream.C2S) {
+	if _, sErr := x.fetchMessages(ctx, nodes, stm); sErr != nil {
+		stm.SendElement(ctx, xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+		return
+	}
+	if err := x.offlineRep.DeleteOfflineMessagesByID(ctx, nodes, stm.Username(), stm.Domain()); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("removed offline messages: %s... count: %d", stm.JID(), len(nodes))
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func (x *Offline) purgeMessages(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	if err := x.offlineRep.DeleteOfflineMessages(ctx, stm.Username(), stm.Domain()); err != nil {
+		log.Error(err)
+		stm.SendElement(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("purged offline messages: %s", stm.JID())
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+// fetchMessages returns those user offline messages identified by nodes, keeping the requested order.
+// In case nodes is nil the whole offline queue is returned.
+func (x *Offline) fetchMessages(ctx context.Context, nodes []string, stm stream.C2S) ([]offlinemodel.Message, *xmpp.StanzaError) {
+	messages, err := x.offlineRep.FetchOfflineMessages(ctx, stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		return nil, xmpp.ErrInternalServerError
+	}
+	messages = pendingMessages(messages, time.Now())
+	if nodes == nil {
+		return messages, nil
+	}
+	res := make([]offlinemodel.Message, 0, len(nodes))
+	for _, node := range nodes {
+		var found bool
+		for _, msg := range messages {
+			if msg.ID == node {
+				res = append(res, msg)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, xmpp.ErrItemNotFound
+		}
+	}
+	return res, nil
+}
+
+// expirationTime returns the time at which an offline message expires, honoring sender requested
+// expire-at rule (XEP-0079) as long as it's earlier than configured lifetime.
+func (x *Offline) expirationTime(message *xmpp.Message) time.Time {
+	var expiresAt time.Time
+	if x.cfg.TTL > 0 {
+		expiresAt = time.Now().Add(x.cfg.TTL)
+	}
+	amp := message.Elements().ChildNamespace("amp", ampNamespace)
+	if amp == nil {
+		return expiresAt
+	}
+	for _, rule := range amp.Elements().Children("rule") {
+		if rule.Attributes().Get("condition") != "expire-at" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, rule.Attributes().Get("value"))
+		if err != nil {
+			continue
+		}
+		if expiresAt.IsZero() || t.Before(expiresAt) {
+			expiresAt = t
+		}
+	}
+	return expiresAt
+}
+
+// bounce returns a message back to its sender, including configured bounce notice.
+func (x *Offline) bounce(ctx context.Context, message *xmpp.Message, stanzaErr *xmpp.StanzaError) {
+	if message.IsError() {
+		return // never bounce errors
+	}
+	var errElements []xmpp.XElement
+	if len(x.cfg.BounceNotice) > 0 {
+		text := xmpp.NewElementNamespace("text", "urn:ietf:params:xml:ns:xmpp-stanzas")
+		text.SetText(x.cfg.BounceNotice)
+		errElements = append(errElements, text)
+	}
+	_ = x.router.Route(ctx, xmpp.NewErrorStanzaFromStanza(message, stanzaErr, errElements))
+}
+
+// pendingMessages filters out those messages expired at a given time.
+func pendingMessages(messages []offlinemodel.Message, at time.Time) []offlinemodel.Message {
+	res := messages[:0]
+	for _, msg := range messages {
+		if !msg.IsExpired(at) {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+// flexibleMessage returns an offline message copy addressed to toJID, including its node identifier.
+func flexibleMessage(message *offlinemodel.Message, toJID *jid.JID) *xmpp.Message {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("node", message.ID)
+
+	offline := xmpp.NewElementNamespace("offline", flexibleOfflineNamespace)
+	offline.AppendElement(item)
+
+	msg, _ := xmpp.NewMessageFromElement(message.Message, message.Message.FromJID(), toJID)
+	msg.AppendElement(offline)
+	return msg
+}
+
+func isFlexibleRetrieval(stm stream.C2S) bool {
+	flexible, _ := stm.Value(offlineFlexibleCtxKey).(bool)
+	return flexible
+}
+
+// setFlexibleRetrieval prevents offline messages from being delivered on initial presence.
+func setFlexibleRetrieval(stm stream.C2S) {
+	stm.SetValue(offlineFlexibleCtxKey, true)
+}
+
 func isMessageArchivable(message *xmpp.Message) bool {
 	if message.Elements().ChildNamespace("no-store", hintsNamespace) != nil {
 		return false
@@ -14,6 +14,10 @@ import (
 	"github.com/sxmpp/jackal/router/host"
 
 	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/module/xep0357"
 	"github.com/sxmpp/jackal/router"
 	memorystorage "github.com/sxmpp/jackal/storage/memory"
 	"github.com/sxmpp/jackal/stream"
@@ -34,7 +38,7 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{QueueSize: 1}, nil, r, s)
+	x := New(&Config{QueueSize: 1}, nil, nil, r, s)
 	defer func() { _ = x.Shutdown() }()
 
 	msgID := uuid.New()
@@ -46,7 +50,7 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	// wait for insertion...
 	time.Sleep(time.Millisecond * 250)
 
-	msgs, err := s.FetchOfflineMessages(context.Background(), "juliet")
+	msgs, err := s.FetchOfflineMessages(context.Background(), "juliet", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(msgs))
 
@@ -66,7 +70,7 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 
 	r.Bind(context.Background(), stm2)
 
-	x2 := New(&Config{QueueSize: 1}, nil, r, s)
+	x2 := New(&Config{QueueSize: 1}, nil, nil, r, s)
 	defer func() { _ = x.Shutdown() }()
 
 	x2.DeliverOfflineMessages(context.Background(), stm2)
@@ -76,6 +80,297 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+func TestOffline_PushNotification(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("juliet", "jackal.im", "garden", true)
+	appServerJID, _ := jid.New("app", "jackal.im", "push", true)
+
+	stm := stream.NewMockC2S(uuid.New(), appServerJID)
+	stm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	pushRep := memorystorage.NewPush()
+	_ = pushRep.UpsertPushRegistration(context.Background(), &pushmodel.Registration{
+		Username: "juliet",
+		Domain:   "jackal.im",
+		JID:      appServerJID.String(),
+		Node:     "node1",
+	})
+	push := xep0357.New(&xep0357.Config{}, nil, r, pushRep)
+	defer func() { _ = push.Shutdown() }()
+
+	x := New(&Config{QueueSize: 10}, nil, push, r, s)
+	defer func() { _ = x.Shutdown() }()
+
+	msg := xmpp.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	x.ArchiveMessage(context.Background(), msg)
+
+	elem := stm.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, "juliet@jackal.im", elem.From())
+	require.NotNil(t, elem.Elements().ChildNamespace("pubsub", "http://jabber.org/protocol/pubsub"))
+}
+
+func TestOffline_Quota(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("juliet", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	msg := xmpp.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+
+	// room enough for a single message
+	x := New(&Config{QueueSize: 10, MaxSize: len(msg.String()) * 2, BounceNotice: "Mailbox is full"}, nil, nil, r, s)
+	defer func() { _ = x.Shutdown() }()
+
+	x.ArchiveMessage(context.Background(), msg)
+	x.ArchiveMessage(context.Background(), msg)
+
+	elem := stm.ReceiveElement()
+	require.True(t, elem.IsError())
+	require.Equal(t, xmpp.ErrServiceUnavailable.Error(), elem.Error().Elements().All()[0].Name())
+	require.Equal(t, "Mailbox is full", elem.Error().Elements().Child("text").Text())
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 1, cnt)
+}
+
+func TestOffline_Expiration(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("juliet", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{QueueSize: 10, TTL: time.Hour, BounceNotice: "Message expired"}, nil, nil, r, s)
+	defer func() { _ = x.Shutdown() }()
+
+	// default lifetime
+	msg := xmpp.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	x.ArchiveMessage(context.Background(), msg)
+
+	// sender requested expiration
+	expireAt := time.Now().Add(time.Minute).UTC()
+	msg2 := xmpp.NewMessageType(uuid.New(), "normal")
+	msg2.SetFromJID(j1)
+	msg2.SetToJID(j2)
+	msg2.AppendElement(testAMPExpireAt(expireAt))
+	x.ArchiveMessage(context.Background(), msg2)
+
+	// already expired
+	msg3 := xmpp.NewMessageType(uuid.New(), "normal")
+	msg3.SetFromJID(j1)
+	msg3.SetToJID(j2)
+	msg3.AppendElement(testAMPExpireAt(time.Now().Add(-time.Minute)))
+	x.ArchiveMessage(context.Background(), msg3)
+
+	elem := stm.ReceiveElement()
+	require.Equal(t, msg3.ID(), elem.ID())
+	require.Equal(t, xmpp.ErrRecipientUnavailable.Error(), elem.Error().Elements().All()[0].Name())
+	require.Equal(t, "Message expired", elem.Error().Elements().Child("text").Text())
+
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Len(t, msgs, 2)
+	require.True(t, msgs[0].ExpiresAt.After(time.Now().Add(time.Minute)))
+	require.Equal(t, expireAt.Unix(), msgs[1].ExpiresAt.Unix())
+
+	_ = s.DeleteOfflineMessages(context.Background(), "juliet", "jackal.im")
+
+	expired := &offlinemodel.Message{ID: "m1", ExpiresAt: time.Now().Add(-time.Minute), Message: msg}
+	_ = s.InsertOfflineMessage(context.Background(), expired, "juliet", "jackal.im")
+
+	// expired messages are never delivered, but purged and bounced back to sender
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	r.Bind(context.Background(), stm2)
+
+	x.DeliverOfflineMessages(context.Background(), stm2)
+	NewReaper(s, func(string) *Offline { return x }).purgeExpiredMessages(context.Background())
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, xmpp.ErrRecipientUnavailable.Error(), elem.Error().Elements().All()[0].Name())
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 0, cnt)
+}
+
+func TestOffline_FlexibleRetrieval(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	j1, _ := jid.New("juliet", "jackal.im", "garden", true)
+	j2, _ := jid.New("romeo", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		msg := xmpp.NewMessageType(uuid.New(), "normal")
+		msg.SetFromJID(j2)
+		msg.SetToJID(j1.ToBareJID())
+		_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: id, Message: msg}, "juliet", "jackal.im")
+	}
+
+	x := New(&Config{QueueSize: 10}, nil, nil, r, s)
+	defer func() { _ = x.Shutdown() }()
+
+	// forbidden target
+	iq := testOfflineIQ(j1, j2.ToBareJID(), xmpp.GetType, xmpp.NewElementName("fetch"))
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// view single message
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.GetType, testOfflineItem("view", "m2"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, j1.String(), elem.To())
+	item := elem.Elements().ChildNamespace("offline", flexibleOfflineNamespace).Elements().Child("item")
+	require.Equal(t, "m2", item.Attributes().Get("node"))
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.Equal(t, iq.ID(), elem.ID())
+
+	// unknown node
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.SetType, testOfflineItem("remove", "m4"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid action
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.GetType, testOfflineItem("remove", "m1"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// remove single message
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.SetType, testOfflineItem("remove", "m1"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 2, len(msgs))
+
+	// fetch all messages
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.GetType, xmpp.NewElementName("fetch"))
+	x.ProcessIQ(context.Background(), iq)
+	for _, node := range []string{"m2", "m3"} {
+		elem = stm.ReceiveElement()
+		item := elem.Elements().ChildNamespace("offline", flexibleOfflineNamespace).Elements().Child("item")
+		require.Equal(t, node, item.Attributes().Get("node"))
+	}
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// messages are not delivered on initial presence
+	x.DeliverOfflineMessages(context.Background(), stm)
+
+	msgs, _ = s.FetchOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 2, len(msgs))
+
+	// purge
+	iq = testOfflineIQ(j1, j1.ToBareJID(), xmpp.SetType, xmpp.NewElementName("purge"))
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	msgs, _ = s.FetchOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 0, len(msgs))
+}
+
+func TestOffline_DiscoInfoProvider(t *testing.T) {
+	r, s := setupTest("jackal.im")
+
+	j1, _ := jid.New("juliet", "jackal.im", "garden", true)
+	j2, _ := jid.New("romeo", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	msg := xmpp.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1.ToBareJID())
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m1", Message: msg}, "juliet", "jackal.im")
+
+	p := &discoInfoProvider{router: r, offlineRep: s}
+
+	_, sErr := p.Features(context.Background(), j1.ToBareJID(), j2, flexibleOfflineNamespace)
+	require.Equal(t, xmpp.ErrForbidden, sErr)
+
+	features, sErr := p.Features(context.Background(), j1.ToBareJID(), j1, flexibleOfflineNamespace)
+	require.Nil(t, sErr)
+	require.Equal(t, []xep0030.Feature{flexibleOfflineNamespace}, features)
+
+	form, sErr := p.Form(context.Background(), j1.ToBareJID(), j1, flexibleOfflineNamespace)
+	require.Nil(t, sErr)
+	require.Equal(t, "1", form.Fields.ValueForField("number_of_messages"))
+
+	require.False(t, isFlexibleRetrieval(stm))
+
+	items, sErr := p.Items(context.Background(), j1.ToBareJID(), j1, flexibleOfflineNamespace)
+	require.Nil(t, sErr)
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "m1", items[0].Node)
+	require.Equal(t, j2.String(), items[0].Name)
+
+	// requesting headers implies flexible retrieval
+	require.True(t, isFlexibleRetrieval(stm))
+}
+
+func testAMPExpireAt(at time.Time) xmpp.XElement {
+	rule := xmpp.NewElementName("rule")
+	rule.SetAttribute("condition", "expire-at")
+	rule.SetAttribute("action", "error")
+	rule.SetAttribute("value", at.UTC().Format(time.RFC3339))
+
+	amp := xmpp.NewElementNamespace("amp", ampNamespace)
+	amp.AppendElement(rule)
+	return amp
+}
+
+func testOfflineIQ(from, to *jid.JID, iqType string, child xmpp.XElement) *xmpp.IQ {
+	offline := xmpp.NewElementNamespace("offline", flexibleOfflineNamespace)
+	offline.AppendElement(child)
+
+	iq := xmpp.NewIQType(uuid.New(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(offline)
+	return iq
+}
+
+func testOfflineItem(action, node string) xmpp.XElement {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("action", action)
+	item.SetAttribute("node", node)
+	return item
+}
+
 func setupTest(domain string) (router.Router, *memorystorage.Offline) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// expirationInterval defines how often expired offline messages are purged.
+var expirationInterval = time.Minute
+
+// Reaper periodically purges expired offline messages from a repository shared by every offline module instance.
+// Each purged message is bounced back to its sender according to the configuration of the instance
+// serving recipient domain.
+type Reaper struct {
+	offlineRep repository.Offline
+	resolve    func(domain string) *Offline
+	startOnce  sync.Once
+	stopOnce   sync.Once
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+}
+
+// NewReaper returns an expired offline messages reaper. resolve returns the offline module instance serving
+// a given domain, or nil in case offline storage is not enabled for it.
+func NewReaper(offlineRep repository.Offline, resolve func(domain string) *Offline) *Reaper {
+	return &Reaper{
+		offlineRep: offlineRep,
+		resolve:    resolve,
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// Start starts purging expired offline messages in background.
+func (r *Reaper) Start() {
+	r.startOnce.Do(func() { go r.loop() })
+}
+
+// Shutdown stops purging expired offline messages.
+func (r *Reaper) Shutdown() error {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+		r.startOnce.Do(func() { close(r.doneCh) }) // never started
+		<-r.doneCh
+	})
+	return nil
+}
+
+func (r *Reaper) loop() {
+	defer close(r.doneCh)
+
+	tc := time.NewTicker(expirationInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			r.purgeExpiredMessages(context.Background())
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// purgeExpiredMessages removes every expired offline message, bouncing it back to its sender
+// in case a bounce notice was configured.
+func (r *Reaper) purgeExpiredMessages(ctx context.Context) {
+	messages, err := r.offlineRep.DeleteExpiredOfflineMessages(ctx, time.Now())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+	log.Infof("purged expired offline messages... count: %d", len(messages))
+
+	for i := range messages {
+		msg := messages[i].Message
+		x := r.resolve(msg.ToJID().Domain())
+		if x == nil || len(x.cfg.BounceNotice) == 0 {
+			continue
+		}
+		x.bounce(ctx, msg, xmpp.ErrRecipientUnavailable)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaper_PurgeExpiredMessages(t *testing.T) {
+	hosts, _ := host.New([]host.Config{
+		{Name: "jackal.im", Certificate: tls.Certificate{}},
+		{Name: "example.org", Certificate: tls.Certificate{}},
+	})
+	s := memorystorage.NewOffline()
+	r, _ := router.New(hosts, c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()), nil)
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{QueueSize: 10, BounceNotice: "Message expired"}, nil, nil, r, s)
+	defer func() { _ = x.Shutdown() }()
+
+	// offline storage is disabled for example.org
+	resolve := func(domain string) *Offline {
+		if domain == "jackal.im" {
+			return x
+		}
+		return nil
+	}
+	expiresAt := time.Now().Add(-time.Minute)
+	for _, to := range []string{"romeo@example.org", "juliet@jackal.im"} {
+		toJID, _ := jid.NewWithString(to, true)
+		msg := xmpp.NewMessageType(uuid.New(), "normal")
+		msg.SetFromJID(j1)
+		msg.SetToJID(toJID)
+		_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), ExpiresAt: expiresAt, Message: msg}, toJID.Node(), toJID.Domain())
+	}
+	rp := NewReaper(s, resolve)
+	defer func() { _ = rp.Shutdown() }()
+
+	rp.purgeExpiredMessages(context.Background())
+
+	// only messages addressed to jackal.im are bounced
+	elem := stm.ReceiveElement()
+	require.Equal(t, "juliet@jackal.im", elem.From())
+	require.Equal(t, "Message expired", elem.Error().Elements().Child("text").Text())
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "romeo", "example.org")
+	require.Equal(t, 0, cnt)
+	cnt, _ = s.CountOfflineMessages(context.Background(), "juliet", "jackal.im")
+	require.Equal(t, 0, cnt)
+}
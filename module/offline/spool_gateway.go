@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package offline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+// spoolGateway writes every forwarded message into a file within a spool directory,
+// to be consumed by some external process.
+// Files are written under a hidden temporary name and renamed once synced to disk, so that
+// consumers only see complete files, which sort by spooling time.
+type spoolGateway struct {
+	dir string
+	enc *payloadEncoder
+}
+
+func newSpoolGateway(dir string, enc *payloadEncoder) gateway {
+	return &spoolGateway{dir: dir, enc: enc}
+}
+
+func (g *spoolGateway) Route(msg *xmpp.Message) error {
+	payload, err := g.enc.encode(msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(g.dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(g.dir, ".spool-")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	if err := writeAndSync(f, payload); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	fileName := fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), uuid.New(), g.enc.fileExt())
+	if err := os.Rename(tmpName, filepath.Join(g.dir, fileName)); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func writeAndSync(f *os.File, b []byte) error {
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -11,7 +11,6 @@ import (
 	"strconv"
 
 	"github.com/sxmpp/jackal/log"
-	"github.com/sxmpp/jackal/model"
 	rostermodel "github.com/sxmpp/jackal/model/roster"
 	"github.com/sxmpp/jackal/module/xep0115"
 	"github.com/sxmpp/jackal/module/xep0163"
@@ -42,6 +41,15 @@ type Roster struct {
 	rosterRep  repository.Roster
 	pep        *xep0163.Pep
 	entityCaps *xep0115.EntityCaps
+	sessions   map[string]*presenceSession
+}
+
+// presenceSession keeps track of the presence state of a single user resource
+// as described in RFC 6121 §4.6.
+type presenceSession struct {
+	available bool                // initial presence has been broadcasted
+	directed  map[string]*jid.JID // entities that received directed available presence
+	excluded  map[string]struct{} // contacts excluded from presence broadcast
 }
 
 // New returns a roster server stream module.
@@ -54,6 +62,7 @@ func New(cfg *Config, entityCaps *xep0115.EntityCaps, pep *xep0163.Pep, router r
 		rosterRep:  rosterRep,
 		entityCaps: entityCaps,
 		pep:        pep,
+		sessions:   make(map[string]*presenceSession),
 	}
 	return r
 }
@@ -65,24 +74,59 @@ func (x *Roster) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a roster IQ taking according actions over the associated stream.
 func (x *Roster) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
-		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource())
 		if stm == nil {
 			return
 		}
 		if err := x.processRosterIQ(ctx, iq, stm); err != nil {
 			log.Error(err)
 		}
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
 // ProcessPresence process an incoming roster presence.
+//
+// Subscription and unavailable presences are never discarded, since losing them would leave
+// rosters and presence state inconsistent. Any other presence is bounced with a 'resource-constraint'
+// error on queue congestion.
 func (x *Roster) ProcessPresence(ctx context.Context, presence *xmpp.Presence) {
-	x.runQueue.Run(func() {
+	fn := func() {
 		if err := x.processPresence(ctx, presence); err != nil {
 			log.Error(err)
 		}
+	}
+	if isStatePresence(presence) {
+		x.runQueue.Run(fn)
+		return
+	}
+	_ = x.runQueue.TryRun(fn, func() {
+		log.Warnf("roster: presence from %s discarded due to queue congestion", presence.FromJID())
+		if !presence.IsError() {
+			_ = x.router.Route(context.Background(), presence.ResourceConstraintError())
+		}
+	})
+}
+
+// ReleaseDirectedPresences sends 'unavailable' presence to every entity that received
+// directed presence from a user resource, and stops tracking them.
+func (x *Roster) ReleaseDirectedPresences(ctx context.Context, userJID *jid.JID) {
+	x.runQueue.Run(func() {
+		x.releaseDirectedPresences(ctx, userJID)
+	})
+}
+
+// RemoveAccount cancels every presence subscription held by an account about to be deleted, notifying its contacts
+// and removing the account from local contacts rosters.
+func (x *Roster) RemoveAccount(ctx context.Context, userJID *jid.JID) error {
+	errCh := make(chan error, 1)
+	x.runQueue.Run(func() {
+		errCh <- x.removeAccount(ctx, userJID.ToBareJID())
 	})
+	return <-errCh
 }
 
 // Shutdown shuts down roster module.
@@ -115,7 +159,7 @@ func (x *Roster) sendRoster(ctx context.Context, iq *xmpp.IQ, query xmpp.XElemen
 
 	log.Infof("retrieving user roster... (%s)", userJID)
 
-	items, ver, err := x.rosterRep.FetchRosterItems(ctx, userJID.Node())
+	items, ver, err := x.rosterRep.FetchRosterItems(ctx, userJID.Node(), userJID.Domain())
 	if err != nil {
 		stm.SendElement(ctx, iq.InternalServerError())
 		return err
@@ -185,7 +229,7 @@ func (x *Roster) updateItem(ctx context.Context, ri *rostermodel.Item, stm strea
 
 	log.Infof("updating roster item - contact: %s (%s)", contactJID, userJID)
 
-	usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+	usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 	if err != nil {
 		return err
 	}
@@ -199,6 +243,7 @@ func (x *Roster) updateItem(ctx context.Context, ri *rostermodel.Item, stm strea
 	} else {
 		usrRi = &rostermodel.Item{
 			Username:     userJID.Node(),
+			Domain:       userJID.Domain(),
 			JID:          ri.JID,
 			Name:         ri.Name,
 			Subscription: rostermodel.SubscriptionNone,
@@ -217,7 +262,7 @@ func (x *Roster) removeItem(ctx context.Context, ri *rostermodel.Item, stm strea
 
 	log.Infof("removing roster item: %v (%s)", contactJID, userJID)
 
-	usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+	usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 	if err != nil {
 		return err
 	}
@@ -236,7 +281,7 @@ func (x *Roster) removeItem(ctx context.Context, ri *rostermodel.Item, stm strea
 		usrRi.Subscription = rostermodel.SubscriptionRemove
 		usrRi.Ask = false
 
-		_, err := x.deleteNotification(ctx, contactJID.Node(), userJID)
+		_, err := x.deleteNotification(ctx, contactJID, userJID)
 		if err != nil {
 			return err
 		}
@@ -248,7 +293,7 @@ func (x *Roster) removeItem(ctx context.Context, ri *rostermodel.Item, stm strea
 	}
 
 	if x.router.Hosts().IsLocalHost(contactJID.Domain()) {
-		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), userJID.String())
+		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -287,6 +332,56 @@ func (x *Roster) removeItem(ctx context.Context, ri *rostermodel.Item, stm strea
 	return nil
 }
 
+func (x *Roster) removeAccount(ctx context.Context, userJID *jid.JID) error {
+	log.Infof("removing account roster: %v", userJID)
+
+	ris, _, err := x.rosterRep.FetchRosterItems(ctx, userJID.Node(), userJID.Domain())
+	if err != nil {
+		return err
+	}
+	for _, ri := range ris {
+		contactJID := ri.ContactJID()
+
+		if ri.Subscription == rostermodel.SubscriptionFrom || ri.Subscription == rostermodel.SubscriptionBoth {
+			x.routePresencesFrom(ctx, userJID, contactJID, xmpp.UnavailableType)
+		}
+		switch ri.Subscription {
+		case rostermodel.SubscriptionTo:
+			_ = x.router.Route(ctx, xmpp.NewPresence(userJID, contactJID, xmpp.UnsubscribeType))
+		case rostermodel.SubscriptionFrom:
+			_ = x.router.Route(ctx, xmpp.NewPresence(userJID, contactJID, xmpp.UnsubscribedType))
+		case rostermodel.SubscriptionBoth:
+			_ = x.router.Route(ctx, xmpp.NewPresence(userJID, contactJID, xmpp.UnsubscribeType))
+			_ = x.router.Route(ctx, xmpp.NewPresence(userJID, contactJID, xmpp.UnsubscribedType))
+		default:
+			if ri.Ask {
+				_ = x.router.Route(ctx, xmpp.NewPresence(userJID, contactJID, xmpp.UnsubscribeType))
+			}
+		}
+		if !x.router.Hosts().IsLocalHost(contactJID.Domain()) {
+			continue
+		}
+		// remove account from contact roster
+		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
+		if err != nil {
+			return err
+		}
+		if cntRi != nil {
+			cntRi.Subscription = rostermodel.SubscriptionRemove
+			cntRi.Ask = false
+			if err := x.deleteItem(ctx, cntRi, contactJID); err != nil {
+				return err
+			}
+		}
+		if _, err := x.deleteNotification(ctx, contactJID, userJID); err != nil {
+			return err
+		}
+		// auto-unsubscribe from all contact virtual nodes
+		x.unsubscribeFromVirtualNodes(ctx, contactJID.String(), userJID)
+	}
+	return nil
+}
+
 func (x *Roster) processPresence(ctx context.Context, presence *xmpp.Presence) error {
 	switch presence.Type() {
 	case xmpp.SubscribeType:
@@ -312,7 +407,7 @@ func (x *Roster) processSubscribe(ctx context.Context, presence *xmpp.Presence)
 	log.Infof("processing 'subscribe' - contact: %s (%s)", contactJID, userJID)
 
 	if x.router.Hosts().IsLocalHost(userJID.Domain()) {
-		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -331,6 +426,7 @@ func (x *Roster) processSubscribe(ctx context.Context, presence *xmpp.Presence)
 			// create roster item if not previously created
 			usrRi = &rostermodel.Item{
 				Username:     userJID.Node(),
+				Domain:       userJID.Domain(),
 				JID:          contactJID.String(),
 				Subscription: rostermodel.SubscriptionNone,
 				Ask:          true,
@@ -346,7 +442,7 @@ func (x *Roster) processSubscribe(ctx context.Context, presence *xmpp.Presence)
 
 	if x.router.Hosts().IsLocalHost(contactJID.Domain()) {
 		// archive roster approval notification
-		if err := x.upsertNotification(ctx, contactJID.Node(), userJID, p); err != nil {
+		if err := x.upsertNotification(ctx, contactJID, userJID, p); err != nil {
 			return err
 		}
 	}
@@ -361,11 +457,11 @@ func (x *Roster) processSubscribed(ctx context.Context, presence *xmpp.Presence)
 	log.Infof("processing 'subscribed' - user: %s (%s)", userJID, contactJID)
 
 	if x.router.Hosts().IsLocalHost(contactJID.Domain()) {
-		_, err := x.deleteNotification(ctx, contactJID.Node(), userJID)
+		_, err := x.deleteNotification(ctx, contactJID, userJID)
 		if err != nil {
 			return err
 		}
-		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), userJID.String())
+		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -380,6 +476,7 @@ func (x *Roster) processSubscribed(ctx context.Context, presence *xmpp.Presence)
 			// create roster item if not previously created
 			cntRi = &rostermodel.Item{
 				Username:     contactJID.Node(),
+				Domain:       contactJID.Domain(),
 				JID:          userJID.String(),
 				Subscription: rostermodel.SubscriptionFrom,
 				Ask:          false,
@@ -396,7 +493,7 @@ func (x *Roster) processSubscribed(ctx context.Context, presence *xmpp.Presence)
 	p.AppendElements(presence.Elements().All())
 
 	if x.router.Hosts().IsLocalHost(userJID.Domain()) {
-		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -429,7 +526,7 @@ func (x *Roster) processUnsubscribe(ctx context.Context, presence *xmpp.Presence
 
 	var usrSub string
 	if x.router.Hosts().IsLocalHost(userJID.Domain()) {
-		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -452,7 +549,7 @@ func (x *Roster) processUnsubscribe(ctx context.Context, presence *xmpp.Presence
 	p.AppendElements(presence.Elements().All())
 
 	if x.router.Hosts().IsLocalHost(contactJID.Domain()) {
-		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), userJID.String())
+		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -486,7 +583,7 @@ func (x *Roster) processUnsubscribed(ctx context.Context, presence *xmpp.Presenc
 
 	var cntSub string
 	if x.router.Hosts().IsLocalHost(contactJID.Domain()) {
-		deleted, err := x.deleteNotification(ctx, contactJID.Node(), userJID)
+		deleted, err := x.deleteNotification(ctx, contactJID, userJID)
 		if err != nil {
 			return err
 		}
@@ -494,7 +591,7 @@ func (x *Roster) processUnsubscribed(ctx context.Context, presence *xmpp.Presenc
 		if deleted {
 			goto routePresence
 		}
-		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), userJID.String())
+		cntRi, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -520,7 +617,7 @@ routePresence:
 	p.AppendElements(presence.Elements().All())
 
 	if x.router.Hosts().IsLocalHost(userJID.Domain()) {
-		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contactJID.String())
+		usrRi, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -557,7 +654,7 @@ func (x *Roster) processProbePresence(ctx context.Context, presence *xmpp.Presen
 		_ = x.router.Route(ctx, presence)
 		return nil
 	}
-	ri, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), userJID.String())
+	ri, err := x.rosterRep.FetchRosterItem(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 	if err != nil {
 		return err
 	}
@@ -569,7 +666,7 @@ func (x *Roster) processProbePresence(ctx context.Context, presence *xmpp.Presen
 		return err
 	}
 	if len(availPresences) == 0 { // send last known presence
-		usr, err := x.userRep.FetchUser(ctx, contactJID.Node())
+		usr, err := x.userRep.FetchUser(ctx, contactJID.Node(), contactJID.Domain())
 		if err != nil {
 			return err
 		}
@@ -595,8 +692,12 @@ func (x *Roster) processAvailablePresence(ctx context.Context, presence *xmpp.Pr
 	userJID := fromJID.ToBareJID()
 	contactJID := presence.ToJID().ToBareJID()
 
-	replyOnBehalf := x.router.Hosts().IsLocalHost(userJID.Domain()) && userJID.MatchesWithOptions(contactJID, jid.MatchesBare)
+	isLocalUser := x.router.Hosts().IsLocalHost(userJID.Domain())
+	replyOnBehalf := isLocalUser && userJID.MatchesWithOptions(contactJID, jid.MatchesBare)
 
+	if isLocalUser && !replyOnBehalf {
+		return x.processDirectedPresence(ctx, presence)
+	}
 	// keep track of available presences
 	if presence.IsAvailable() {
 		log.Infof("processing 'available' - user: %s", fromJID)
@@ -621,15 +722,78 @@ func (x *Roster) processAvailablePresence(ctx context.Context, presence *xmpp.Pr
 		}
 	}
 	if replyOnBehalf {
-		return x.broadcastPresence(ctx, presence)
+		if err := x.broadcastPresence(ctx, presence); err != nil {
+			return err
+		}
+		if presence.IsUnavailable() {
+			x.releaseDirectedPresences(ctx, fromJID)
+		}
+		return nil
+	}
+	_ = x.router.Route(ctx, presence)
+	return nil
+}
+
+func (x *Roster) processDirectedPresence(ctx context.Context, presence *xmpp.Presence) error {
+	fromJID := presence.FromJID()
+	toJID := presence.ToJID()
+
+	ri, err := x.rosterRep.FetchRosterItem(ctx, fromJID.Node(), fromJID.Domain(), toJID.ToBareJID().String())
+	if err != nil {
+		return err
+	}
+	subscribed := ri != nil && (ri.Subscription == rostermodel.SubscriptionFrom || ri.Subscription == rostermodel.SubscriptionBoth)
+
+	sess := x.presenceSession(fromJID)
+	if sess.available && subscribed {
+		// contact already receives presence broadcast during this session
+		contact := toJID.ToBareJID().String()
+		if presence.IsAvailable() {
+			delete(sess.excluded, contact)
+		} else {
+			sess.excluded[contact] = struct{}{}
+		}
+	} else {
+		if presence.IsAvailable() {
+			sess.directed[toJID.String()] = toJID
+		} else {
+			delete(sess.directed, toJID.String())
+		}
+	}
+	if !sess.available && len(sess.directed) == 0 {
+		delete(x.sessions, fromJID.String())
 	}
 	_ = x.router.Route(ctx, presence)
 	return nil
 }
 
+func (x *Roster) releaseDirectedPresences(ctx context.Context, userJID *jid.JID) {
+	sess := x.sessions[userJID.String()]
+	if sess == nil {
+		return
+	}
+	delete(x.sessions, userJID.String())
+
+	for _, j := range sess.directed {
+		_ = x.router.Route(ctx, xmpp.NewPresence(userJID, j, xmpp.UnavailableType))
+	}
+}
+
+func (x *Roster) presenceSession(userJID *jid.JID) *presenceSession {
+	sess := x.sessions[userJID.String()]
+	if sess == nil {
+		sess = &presenceSession{
+			directed: make(map[string]*jid.JID),
+			excluded: make(map[string]struct{}),
+		}
+		x.sessions[userJID.String()] = sess
+	}
+	return sess
+}
+
 func (x *Roster) deliverRosterPresences(ctx context.Context, userJID *jid.JID) error {
 	// first, deliver pending approval notifications...
-	rns, err := x.rosterRep.FetchRosterNotifications(ctx, userJID.Node())
+	rns, err := x.rosterRep.FetchRosterNotifications(ctx, userJID.Node(), userJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -641,7 +805,7 @@ func (x *Roster) deliverRosterPresences(ctx context.Context, userJID *jid.JID) e
 	}
 
 	// deliver roster online presences
-	items, _, err := x.rosterRep.FetchRosterItems(ctx, userJID.Node())
+	items, _, err := x.rosterRep.FetchRosterItems(ctx, userJID.Node(), userJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -661,30 +825,37 @@ func (x *Roster) deliverRosterPresences(ctx context.Context, userJID *jid.JID) e
 
 func (x *Roster) broadcastPresence(ctx context.Context, presence *xmpp.Presence) error {
 	fromJID := presence.FromJID()
-	items, _, err := x.rosterRep.FetchRosterItems(ctx, fromJID.Node())
+	items, _, err := x.rosterRep.FetchRosterItems(ctx, fromJID.Node(), fromJID.Domain())
 	if err != nil {
 		return err
 	}
+	sess := x.presenceSession(fromJID)
+	sess.available = presence.IsAvailable()
+
+	// render presence just once
+	bp := xmpp.NewPresence(fromJID, fromJID.ToBareJID(), presence.Type())
+	bp.AppendElements(presence.Elements().All())
+	prepared := xmpp.NewPreparedStanza(bp)
+
 	for _, itm := range items {
 		switch itm.Subscription {
 		case rostermodel.SubscriptionFrom, rostermodel.SubscriptionBoth:
-			p := xmpp.NewPresence(fromJID, itm.ContactJID(), presence.Type())
-			p.AppendElements(presence.Elements().All())
-			_ = x.router.Route(ctx, p)
+			contactJID := itm.ContactJID()
+			if _, ok := sess.excluded[contactJID.String()]; ok {
+				continue
+			}
+			// contact will be notified through presence broadcast from now on
+			for k, j := range sess.directed {
+				if j.MatchesWithOptions(contactJID, jid.MatchesBare) {
+					delete(sess.directed, k)
+				}
+			}
+			_ = x.router.Route(ctx, prepared.Stanza(contactJID))
 		}
 	}
 
 	// update last received presence
-	if usr, err := x.userRep.FetchUser(ctx, fromJID.Node()); err != nil {
-		return err
-	} else if usr != nil {
-		return x.userRep.UpsertUser(ctx, &model.User{
-			Username:     usr.Username,
-			Password:     usr.Password,
-			LastPresence: presence,
-		})
-	}
-	return nil
+	return x.userRep.UpdateLastPresence(ctx, fromJID.Node(), fromJID.Domain(), presence)
 }
 
 func (x *Roster) upsertItem(ctx context.Context, ri *rostermodel.Item, pushTo *jid.JID) error {
@@ -697,7 +868,7 @@ func (x *Roster) upsertItem(ctx context.Context, ri *rostermodel.Item, pushTo *j
 }
 
 func (x *Roster) deleteItem(ctx context.Context, ri *rostermodel.Item, pushTo *jid.JID) error {
-	v, err := x.rosterRep.DeleteRosterItem(ctx, ri.Username, ri.JID)
+	v, err := x.rosterRep.DeleteRosterItem(ctx, ri.Username, ri.Domain, ri.JID)
 	if err != nil {
 		return err
 	}
@@ -712,7 +883,7 @@ func (x *Roster) pushItem(ctx context.Context, ri *rostermodel.Item, to *jid.JID
 	}
 	query.AppendElement(ri.Element())
 
-	streams := x.router.LocalStreams(to.Node())
+	streams := x.router.LocalStreams(to.Node(), to.Domain())
 	for _, stm := range streams {
 		requested, _ := stm.Value(rosterRequestedCtxKey).(bool)
 		if !requested {
@@ -726,23 +897,24 @@ func (x *Roster) pushItem(ctx context.Context, ri *rostermodel.Item, to *jid.JID
 	return nil
 }
 
-func (x *Roster) deleteNotification(ctx context.Context, contact string, userJID *jid.JID) (deleted bool, err error) {
-	rn, err := x.rosterRep.FetchRosterNotification(ctx, contact, userJID.String())
+func (x *Roster) deleteNotification(ctx context.Context, contactJID *jid.JID, userJID *jid.JID) (deleted bool, err error) {
+	rn, err := x.rosterRep.FetchRosterNotification(ctx, contactJID.Node(), contactJID.Domain(), userJID.String())
 	if err != nil {
 		return false, err
 	}
 	if rn == nil {
 		return false, nil
 	}
-	if err := x.rosterRep.DeleteRosterNotification(ctx, contact, userJID.String()); err != nil {
+	if err := x.rosterRep.DeleteRosterNotification(ctx, contactJID.Node(), contactJID.Domain(), userJID.String()); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (x *Roster) upsertNotification(ctx context.Context, contact string, userJID *jid.JID, presence *xmpp.Presence) error {
+func (x *Roster) upsertNotification(ctx context.Context, contactJID *jid.JID, userJID *jid.JID, presence *xmpp.Presence) error {
 	rn := &rostermodel.Notification{
-		Contact:  contact,
+		Contact:  contactJID.Node(),
+		Domain:   contactJID.Domain(),
 		JID:      userJID.String(),
 		Presence: presence,
 	}
@@ -750,7 +922,7 @@ func (x *Roster) upsertNotification(ctx context.Context, contact string, userJID
 }
 
 func (x *Roster) routePresencesFrom(ctx context.Context, from *jid.JID, to *jid.JID, presenceType string) {
-	streams := x.router.LocalStreams(from.Node())
+	streams := x.router.LocalStreams(from.Node(), from.Domain())
 	for _, stm := range streams {
 		p := xmpp.NewPresence(stm.JID(), to.ToBareJID(), presenceType)
 		if presence := stm.Presence(); presence != nil && presence.IsAvailable() {
@@ -788,3 +960,9 @@ func parseVer(ver string) int {
 	}
 	return 0
 }
+
+// isStatePresence tells whether a presence changes either subscription or availability state.
+func isStatePresence(presence *xmpp.Presence) bool {
+	return presence.IsSubscribe() || presence.IsSubscribed() || presence.IsUnsubscribe() ||
+		presence.IsUnsubscribed() || presence.IsUnavailable()
+}
@@ -20,6 +20,7 @@ import (
 	memorystorage "github.com/sxmpp/jackal/storage/memory"
 	"github.com/sxmpp/jackal/storage/repository"
 	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/util/runqueue"
 	"github.com/sxmpp/jackal/xmpp"
 	"github.com/sxmpp/jackal/xmpp/jid"
 	"github.com/pborman/uuid"
@@ -76,6 +77,7 @@ func TestRoster_FetchRoster(t *testing.T) {
 
 	ri1 := &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Name:         "My Juliet",
 		Subscription: rostermodel.SubscriptionNone,
@@ -86,6 +88,7 @@ func TestRoster_FetchRoster(t *testing.T) {
 
 	ri2 := &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "romeo@jackal.im",
 		Name:         "Rome",
 		Subscription: rostermodel.SubscriptionNone,
@@ -199,7 +202,7 @@ func TestRoster_Update(t *testing.T) {
 	require.Equal(t, xmpp.ResultType, elem.Type())
 	require.Equal(t, iqID, elem.ID())
 
-	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.NotNil(t, ri)
 	require.Equal(t, "sxmpp", ri.Username)
@@ -213,12 +216,14 @@ func TestRoster_RemoveItem(t *testing.T) {
 	// insert contact's roster item
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Name:         "My Juliet",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "sxmpp@jackal.im",
 		Name:         "My Romeo",
 		Subscription: rostermodel.SubscriptionBoth,
@@ -250,7 +255,62 @@ func TestRoster_RemoveItem(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, iqID, elem.ID())
 
-	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, ri)
+}
+
+func TestRoster_RemoveAccount(t *testing.T) {
+	rtr, userRep, presencesRep, rosterRep := setupTest("jackal.im")
+
+	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "noelia",
+		Domain:       "jackal.im",
+		JID:          "sxmpp@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	stm2.SetValue(rosterRequestedCtxKey, true)
+
+	rtr.Bind(context.Background(), stm1)
+	rtr.Bind(context.Background(), stm2)
+
+	r := New(&Config{}, xep0115.New(rtr, presencesRep, "alloc-1234"), nil, rtr, userRep, rosterRep)
+	defer func() { _ = r.Shutdown() }()
+
+	require.Nil(t, r.RemoveAccount(context.Background(), j1))
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, j1.String(), elem.From())
+
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnsubscribeType, elem.Type())
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnsubscribedType, elem.Type())
+
+	// contact roster push
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	item := elem.Elements().ChildNamespace("query", rosterNamespace).Elements().Child("item")
+	require.NotNil(t, item)
+	require.Equal(t, "sxmpp@jackal.im", item.Attributes().Get("jid"))
+	require.Equal(t, rostermodel.SubscriptionRemove, item.Attributes().Get("subscription"))
+
+	ri, err := rosterRep.FetchRosterItem(context.Background(), "noelia", "jackal.im", "sxmpp@jackal.im")
 	require.Nil(t, err)
 	require.Nil(t, ri)
 }
@@ -278,17 +338,20 @@ func TestRoster_OnlineJIDs(t *testing.T) {
 	// user entity
 	_ = userRep.UpsertUser(context.Background(), &model.User{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		LastPresence: xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.UnavailableType),
 	})
 
 	// roster items
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "sxmpp@jackal.im",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
@@ -296,6 +359,7 @@ func TestRoster_OnlineJIDs(t *testing.T) {
 	// pending notification
 	_ = rosterRep.UpsertRosterNotification(context.Background(), &rostermodel.Notification{
 		Contact:  "sxmpp",
+		Domain:   "jackal.im",
 		JID:      j3.ToBareJID().String(),
 		Presence: xmpp.NewPresence(j3.ToBareJID(), j1.ToBareJID(), xmpp.SubscribeType),
 	})
@@ -322,7 +386,7 @@ func TestRoster_OnlineJIDs(t *testing.T) {
 	require.Equal(t, xmpp.AvailableType, elem.Type())
 
 	// check if last presence was updated
-	usr, err := userRep.FetchUser(context.Background(), "sxmpp")
+	usr, err := userRep.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, err)
 	require.NotNil(t, usr)
 	require.NotNil(t, usr.LastPresence)
@@ -394,11 +458,13 @@ func TestRoster_Probe(t *testing.T) {
 
 	_ = userRep.UpsertUser(context.Background(), &model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: xmpp.NewPresence(j2.ToBareJID(), j2.ToBareJID(), xmpp.UnavailableType),
 	})
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "sxmpp@jackal.im",
 		Subscription: rostermodel.SubscriptionFrom,
 	})
@@ -410,6 +476,7 @@ func TestRoster_Probe(t *testing.T) {
 	p2 := xmpp.NewPresence(j2, j2.ToBareJID(), xmpp.AvailableType)
 	_ = userRep.UpsertUser(context.Background(), &model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: p2,
 	})
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j2, xmpp.ProbeType))
@@ -430,7 +497,7 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xmpp.SubscribeType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	rns, err := rosterRep.FetchRosterNotifications(context.Background(), "noelia")
+	rns, err := rosterRep.FetchRosterNotifications(context.Background(), "noelia", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rns))
 
@@ -441,11 +508,11 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j2.ToBareJID(), j1.ToBareJID(), xmpp.UnsubscribedType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	rns, err = rosterRep.FetchRosterNotifications(context.Background(), "noelia")
+	rns, err = rosterRep.FetchRosterNotifications(context.Background(), "noelia", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns))
 
-	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err := rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 
@@ -454,7 +521,7 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j2.ToBareJID(), j1.ToBareJID(), xmpp.SubscribedType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionTo, ri.Subscription)
 
@@ -463,7 +530,7 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xmpp.SubscribedType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	ri, err = rosterRep.FetchRosterItem(context.Background(), "noelia", "sxmpp@jackal.im")
+	ri, err = rosterRep.FetchRosterItem(context.Background(), "noelia", "jackal.im", "sxmpp@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionBoth, ri.Subscription)
 
@@ -471,7 +538,7 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xmpp.UnsubscribeType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionFrom, ri.Subscription)
 
@@ -479,15 +546,120 @@ func TestRoster_Subscription(t *testing.T) {
 	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xmpp.UnsubscribedType))
 	time.Sleep(time.Millisecond * 150) // wait until processed...
 
-	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "noelia@jackal.im")
+	ri, err = rosterRep.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 
-	ri, err = rosterRep.FetchRosterItem(context.Background(), "noelia", "sxmpp@jackal.im")
+	ri, err = rosterRep.FetchRosterItem(context.Background(), "noelia", "jackal.im", "sxmpp@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 }
 
+func TestRoster_Congestion(t *testing.T) {
+	rtr, userRep, presencesRep, rosterRep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	rtr.Bind(context.Background(), stm1)
+
+	r := New(&Config{}, xep0115.New(rtr, presencesRep, "alloc-1234"), nil, rtr, userRep, rosterRep)
+	defer func() { _ = r.Shutdown() }()
+
+	r.runQueue = runqueue.NewWithConfig("roster", runqueue.Config{Capacity: 1, Overflow: runqueue.Reject})
+
+	blockCh := make(chan struct{})
+	r.runQueue.Run(func() { <-blockCh })
+
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j2.ToBareJID(), xmpp.AvailableType))
+
+	// subscription presences are never discarded...
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xmpp.SubscribeType))
+
+	// ...while any other presence is bounced
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j2.ToBareJID(), xmpp.AvailableType))
+
+	elem := stm1.ReceiveElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("resource-constraint"))
+
+	close(blockCh)
+	time.Sleep(time.Millisecond * 150) // wait until processed...
+
+	rns, err := rosterRep.FetchRosterNotifications(context.Background(), "noelia", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rns))
+}
+
+func TestRoster_DirectedPresence(t *testing.T) {
+	rtr, userRep, presencesRep, rosterRep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j3, _ := jid.New("room", "jackal.im", "sxmpp", true)
+
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	stm3.SetPresence(xmpp.NewPresence(j3, j3, xmpp.AvailableType))
+	rtr.Bind(context.Background(), stm2)
+	rtr.Bind(context.Background(), stm3)
+
+	r := New(&Config{}, xep0115.New(rtr, presencesRep, "alloc-1234"), nil, rtr, userRep, rosterRep)
+	defer func() { _ = r.Shutdown() }()
+
+	_ = userRep.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im"})
+	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionFrom,
+	})
+	// directed presence to an entity not in roster
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j3, xmpp.AvailableType))
+	elem := stm3.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+
+	// initial presence broadcast
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+
+	// directed unavailable presence to a subscribed contact during presence session
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j2, xmpp.UnavailableType))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+
+	// contact should not receive presence broadcast anymore...
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType))
+
+	// unavailable presence broadcast
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.UnavailableType))
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, j1.String(), elem.From())
+
+	// release on stream disconnection
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j3, xmpp.AvailableType))
+	elem = stm3.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+
+	r.ReleaseDirectedPresences(context.Background(), j1)
+	elem = stm3.ReceiveElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+
+	// excluded contact didn't receive any further broadcast
+	r.ProcessPresence(context.Background(), xmpp.NewPresence(j1, j2, xmpp.UnavailableType))
+	elem = stm2.ReceiveElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+}
+
 func setupTest(domain string) (router.Router, repository.User, repository.Presences, repository.Roster) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 
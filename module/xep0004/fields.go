@@ -27,8 +27,8 @@ func (f Fields) ValueForFieldOfType(fieldName, typ string) string {
 func (f Fields) ValuesForFieldOfType(fieldName, typ string) []string {
 	var res []string
 	for _, field := range f {
-		if field.Var == fieldName && field.Type == typ && len(field.Values) > 0 {
-			res = append(res, field.Values[0])
+		if field.Var == fieldName && field.Type == typ {
+			res = append(res, field.Values...)
 		}
 	}
 	return res
@@ -16,3 +16,14 @@ func TestFields_ValueForField(t *testing.T) {
 	require.Equal(t, "foo", f.ValueForField("var1"))
 	require.Equal(t, "", f.ValueForField("var2"))
 }
+
+func TestFields_ValuesForField(t *testing.T) {
+	f := Fields{
+		{
+			Var:    "var1",
+			Values: []string{"foo", "bar"},
+		},
+	}
+	require.Equal(t, []string{"foo", "bar"}, f.ValuesForField("var1"))
+	require.Nil(t, f.ValuesForField("var2"))
+}
@@ -54,8 +54,11 @@ func (x *LastActivity) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a last activity IQ taking according actions over the associated stream.
 func (x *LastActivity) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -95,11 +98,11 @@ func (x *LastActivity) sendServerUptime(ctx context.Context, iq *xmpp.IQ) {
 }
 
 func (x *LastActivity) sendUserLastActivity(ctx context.Context, iq *xmpp.IQ, to *jid.JID) {
-	if len(x.router.LocalStreams(to.Node())) > 0 { // user is online
+	if len(x.router.LocalStreams(to.Node(), to.Domain())) > 0 { // user is online
 		x.sendReply(ctx, iq, 0, "")
 		return
 	}
-	usr, err := x.userRep.FetchUser(ctx, to.Node())
+	usr, err := x.userRep.FetchUser(ctx, to.Node(), to.Domain())
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
@@ -133,7 +136,7 @@ func (x *LastActivity) isSubscribedTo(ctx context.Context, contact *jid.JID, use
 	if contact.MatchesWithOptions(userJID, jid.MatchesBare) {
 		return true, nil
 	}
-	ri, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), contact.ToBareJID().String())
+	ri, err := x.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contact.ToBareJID().String())
 	if err != nil {
 		return false, err
 	}
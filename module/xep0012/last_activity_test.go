@@ -117,10 +117,12 @@ func TestXEP0012_GetOnlineUserLastActivity(t *testing.T) {
 
 	_ = userRep.UpsertUser(context.Background(), &model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: p,
 	})
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
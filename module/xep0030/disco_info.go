@@ -21,12 +21,30 @@ const (
 	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
 )
 
+// Registrations represents a set of server features, account features and account node providers
+// registered into disco info module.
+type Registrations struct {
+	ServerFeatures  []Feature
+	AccountFeatures []Feature
+	AccountNodes    []string
+}
+
+// Subtract returns the registrations contained in r but not in other.
+func (r Registrations) Subtract(other Registrations) Registrations {
+	return Registrations{
+		ServerFeatures:  subtract(r.ServerFeatures, other.ServerFeatures),
+		AccountFeatures: subtract(r.AccountFeatures, other.AccountFeatures),
+		AccountNodes:    subtract(r.AccountNodes, other.AccountNodes),
+	}
+}
+
 // DiscoInfo represents a disco info server stream module.
 type DiscoInfo struct {
 	mu          sync.RWMutex
 	router      router.Router
 	srvProvider *serverProvider
 	providers   map[string]InfoProvider
+	nodeProvs   map[string]InfoProvider
 	runQueue    *runqueue.RunQueue
 }
 
@@ -39,6 +57,7 @@ func New(router router.Router, rosterRep repository.Roster) *DiscoInfo {
 			rosterRep: rosterRep,
 		},
 		providers: make(map[string]InfoProvider),
+		nodeProvs: make(map[string]InfoProvider),
 		runQueue:  runqueue.New("xep0030"),
 	}
 	di.RegisterServerFeature(discoItemsNamespace)
@@ -92,6 +111,39 @@ func (x *DiscoInfo) UnregisterProvider(domain string) {
 	delete(x.providers, domain)
 }
 
+// RegisterAccountNodeProvider registers a new disco info provider associated to a node of every local account.
+func (x *DiscoInfo) RegisterAccountNodeProvider(node string, provider InfoProvider) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.nodeProvs[node] = provider
+}
+
+// UnregisterAccountNodeProvider unregisters a previously registered account node disco info provider.
+func (x *DiscoInfo) UnregisterAccountNodeProvider(node string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.nodeProvs, node)
+}
+
+// Registrations returns every server feature, account feature and account node provider currently registered.
+func (x *DiscoInfo) Registrations() Registrations {
+	var r Registrations
+	r.ServerFeatures, r.AccountFeatures = x.srvProvider.features()
+
+	x.mu.RLock()
+	for node := range x.nodeProvs {
+		r.AccountNodes = append(r.AccountNodes, node)
+	}
+	x.mu.RUnlock()
+	return r
+}
+
+// Hide hides a set of registrations from every disco info request addressed to a local domain.
+// Registrations hidden for an empty domain apply to every domain lacking its own hidden set.
+func (x *DiscoInfo) Hide(domain string, r Registrations) {
+	x.srvProvider.hide(domain, r)
+}
+
 // MatchesIQ returns whether or not an IQ should be
 // processed by the disco info module.
 func (x *DiscoInfo) MatchesIQ(iq *xmpp.IQ) bool {
@@ -104,8 +156,11 @@ func (x *DiscoInfo) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a disco info IQ taking according actions over the associated stream.
 func (x *DiscoInfo) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -121,9 +176,14 @@ func (x *DiscoInfo) processIQ(ctx context.Context, iq *xmpp.IQ) {
 	fromJID := iq.FromJID()
 	toJID := iq.ToJID()
 
+	q := iq.Elements().Child("query")
+	node := q.Attributes().Get("node")
+
 	var prov InfoProvider
 	if x.router.Hosts().IsLocalHost(toJID.Domain()) {
-		if p := x.providers[toJID.String()]; p != nil {
+		if p := x.accountNodeProvider(toJID, node); p != nil {
+			prov = p
+		} else if p := x.providers[toJID.String()]; p != nil {
 			prov = p
 		} else {
 			prov = x.srvProvider
@@ -135,8 +195,6 @@ func (x *DiscoInfo) processIQ(ctx context.Context, iq *xmpp.IQ) {
 			return
 		}
 	}
-	q := iq.Elements().Child("query")
-	node := q.Attributes().Get("node")
 	if q != nil {
 		switch q.Namespace() {
 		case discoInfoNamespace:
@@ -150,6 +208,15 @@ func (x *DiscoInfo) processIQ(ctx context.Context, iq *xmpp.IQ) {
 	_ = x.router.Route(ctx, iq.BadRequestError())
 }
 
+func (x *DiscoInfo) accountNodeProvider(toJID *jid.JID, node string) InfoProvider {
+	if len(node) == 0 || !toJID.IsBare() || x.srvProvider.isHiddenNode(toJID.Domain(), node) {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.nodeProvs[node]
+}
+
 func (x *DiscoInfo) sendDiscoInfo(ctx context.Context, prov InfoProvider, toJID, fromJID *jid.JID, node string, iq *xmpp.IQ) {
 	features, sErr := prov.Features(ctx, toJID, fromJID, node)
 	if sErr != nil {
@@ -213,3 +280,22 @@ func (x *DiscoInfo) sendDiscoItems(ctx context.Context, prov InfoProvider, toJID
 	result.AppendElement(query)
 	_ = x.router.Route(ctx, result)
 }
+
+func subtract(s, other []string) []string {
+	var res []string
+	for _, v := range s {
+		if !contains(other, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func contains(s []string, v string) bool {
+	for _, sv := range s {
+		if sv == v {
+			return true
+		}
+	}
+	return false
+}
@@ -196,6 +196,44 @@ func TestXEP0030_Provider(t *testing.T) {
 	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
 }
 
+func TestXEP0030_AccountNodeProvider(t *testing.T) {
+	r, rosterRep := setupTest("jackal.im")
+
+	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+
+	r.Bind(context.Background(), stm)
+
+	x := New(r, rosterRep)
+	defer func() { _ = x.Shutdown() }()
+
+	x.RegisterAccountNodeProvider("test_node", &testDiscoInfoProvider{})
+
+	q := xmpp.NewElementNamespace("query", discoItemsNamespace)
+	q.SetAttribute("node", "test_node")
+
+	iq1 := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq1.SetFromJID(j)
+	iq1.SetToJID(j.ToBareJID())
+	iq1.AppendElement(q)
+
+	x.ProcessIQ(context.Background(), iq1)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("query", discoItemsNamespace).Elements().Children("item")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "test.jackal.im", items[0].Attributes().Get("jid"))
+
+	x.UnregisterAccountNodeProvider("test_node")
+
+	x.ProcessIQ(context.Background(), iq1)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.Equal(t, 0, len(elem.Elements().ChildNamespace("query", discoItemsNamespace).Elements().Children("item")))
+}
+
 func setupTest(domain string) (router.Router, repository.Roster) {
 	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
 	rosterRep := memorystorage.NewRoster()
@@ -25,6 +25,7 @@ type serverProvider struct {
 	serverItems     []Item
 	serverFeatures  []Feature
 	accountFeatures []Feature
+	hidden          map[string]Registrations
 }
 
 func (sp *serverProvider) Identities(_ context.Context, toJID, _ *jid.JID, node string) []Identity {
@@ -44,11 +45,13 @@ func (sp *serverProvider) Items(ctx context.Context, toJID, fromJID *jid.JID, no
 	var items []Item
 	if toJID.IsServer() {
 		items = append(items, Item{Jid: fromJID.ToBareJID().String()})
+		sp.mu.RLock()
 		items = append(items, sp.serverItems...)
+		sp.mu.RUnlock()
 	} else {
 		// add account resources
 		if sp.isSubscribedTo(ctx, toJID, fromJID) {
-			streams := sp.router.LocalStreams(toJID.Node())
+			streams := sp.router.LocalStreams(toJID.Node(), toJID.Domain())
 			for _, stm := range streams {
 				items = append(items, Item{Jid: stm.JID().String()})
 			}
@@ -65,11 +68,12 @@ func (sp *serverProvider) Features(ctx context.Context, toJID, fromJID *jid.JID,
 	if node != "" {
 		return nil, nil
 	}
+	hidden := sp.hiddenRegistrations(toJID.Domain())
 	if toJID.IsServer() {
-		return sp.serverFeatures, nil
+		return subtract(sp.serverFeatures, hidden.ServerFeatures), nil
 	}
 	if sp.isSubscribedTo(ctx, toJID, fromJID) {
-		return sp.accountFeatures, nil
+		return subtract(sp.accountFeatures, hidden.AccountFeatures), nil
 	}
 	return nil, xmpp.ErrSubscriptionRequired
 }
@@ -144,11 +148,42 @@ func (sp *serverProvider) unregisterAccountFeature(feature Feature) {
 	}
 }
 
+func (sp *serverProvider) features() (server, account []Feature) {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	server = append(server, sp.serverFeatures...)
+	account = append(account, sp.accountFeatures...)
+	return
+}
+
+func (sp *serverProvider) hide(domain string, r Registrations) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.hidden == nil {
+		sp.hidden = make(map[string]Registrations)
+	}
+	sp.hidden[domain] = r
+}
+
+func (sp *serverProvider) isHiddenNode(domain, node string) bool {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return contains(sp.hiddenRegistrations(domain).AccountNodes, node)
+}
+
+// hiddenRegistrations must be called holding sp.mu lock.
+func (sp *serverProvider) hiddenRegistrations(domain string) Registrations {
+	if r, ok := sp.hidden[domain]; ok {
+		return r
+	}
+	return sp.hidden[""]
+}
+
 func (sp *serverProvider) isSubscribedTo(ctx context.Context, contact *jid.JID, userJID *jid.JID) bool {
 	if contact.MatchesWithOptions(userJID, jid.MatchesBare) {
 		return true
 	}
-	ri, err := sp.rosterRep.FetchRosterItem(ctx, userJID.Node(), contact.ToBareJID().String())
+	ri, err := sp.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contact.ToBareJID().String())
 	if err != nil {
 		log.Error(err)
 		return false
@@ -109,6 +109,7 @@ func TestServerProvider_Items(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -42,8 +42,11 @@ func (x *Private) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a private storage IQ taking according actions over the associated stream.
 func (x *Private) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -90,7 +93,7 @@ func (x *Private) getPrivate(ctx context.Context, iq *xmpp.IQ, q xmpp.XElement)
 	fromJID := iq.FromJID()
 	log.Infof("retrieving private element. ns: %s... (%s/%s)", privNS, fromJID.Node(), fromJID.Resource())
 
-	privElements, err := x.rep.FetchPrivateXML(ctx, privNS, fromJID.Node())
+	privElements, err := x.rep.FetchPrivateXML(ctx, privNS, fromJID.Node(), fromJID.Domain())
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
@@ -133,7 +136,7 @@ func (x *Private) setPrivate(ctx context.Context, iq *xmpp.IQ, q xmpp.XElement)
 	for ns, elements := range nsElements {
 		log.Infof("saving private element. ns: %s... (%s/%s)", ns, fromJID.Node(), fromJID.Resource())
 
-		if err := x.rep.UpsertPrivateXML(ctx, elements, ns, fromJID.Node()); err != nil {
+		if err := x.rep.UpsertPrivateXML(ctx, elements, ns, fromJID.Node(), fromJID.Domain()); err != nil {
 			log.Error(err)
 			_ = x.router.Route(ctx, iq.InternalServerError())
 			return
@@ -47,8 +47,11 @@ func (x *VCard) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a vCard IQ taking according actions over the associated stream.
 func (x *VCard) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -80,7 +83,7 @@ func (x *VCard) getVCard(ctx context.Context, vCard xmpp.XElement, iq *xmpp.IQ)
 		return
 	}
 	toJID := iq.ToJID()
-	resElem, err := x.rep.FetchVCard(ctx, toJID.Node())
+	resElem, err := x.rep.FetchVCard(ctx, toJID.Node(), toJID.Domain())
 	if err != nil {
 		log.Errorf("%v", err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
@@ -104,7 +107,7 @@ func (x *VCard) setVCard(ctx context.Context, vCard xmpp.XElement, iq *xmpp.IQ)
 	if toJID.IsServer() || (toJID.Node() == fromJID.Node()) {
 		log.Infof("saving vcard... (jid: %s)", toJID.String())
 
-		err := x.rep.UpsertVCard(ctx, vCard, toJID.Node())
+		err := x.rep.UpsertVCard(ctx, vCard, toJID.Node(), toJID.Domain())
 		if err != nil {
 			log.Error(err)
 			_ = x.router.Route(ctx, iq.InternalServerError())
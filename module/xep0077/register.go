@@ -10,6 +10,7 @@ import (
 
 	"github.com/sxmpp/jackal/log"
 	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/module/account"
 	"github.com/sxmpp/jackal/module/xep0030"
 	"github.com/sxmpp/jackal/router"
 	"github.com/sxmpp/jackal/storage/repository"
@@ -36,15 +37,21 @@ type Register struct {
 	router   router.Router
 	runQueue *runqueue.RunQueue
 	rep      repository.User
+	accounts *account.Manager
 }
 
 // New returns an in-band registration IQ handler.
-func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User) *Register {
+// When no account manager is provided, cancelled accounts are removed without notifying their contacts.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, accounts *account.Manager) *Register {
+	if accounts == nil {
+		accounts = account.New(router, nil, userRep)
+	}
 	r := &Register{
 		cfg:      config,
 		router:   router,
 		runQueue: runqueue.New("xep0077"),
 		rep:      userRep,
+		accounts: accounts,
 	}
 	if disco != nil {
 		disco.RegisterServerFeature(registerNamespace)
@@ -59,17 +66,23 @@ func (x *Register) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes an in-band registration IQ taking according actions over the associated stream.
 func (x *Register) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
-		if stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource()); stm != nil {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		if stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource()); stm != nil {
 			x.processIQ(ctx, iq, stm)
 		}
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
 // ProcessIQWithStream processes an in-band registration IQ taking according actions over a referenced stream.
 func (x *Register) ProcessIQWithStream(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq, stm)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -146,7 +159,7 @@ func (x *Register) registerNewUser(ctx context.Context, iq *xmpp.IQ, query xmpp.
 		stm.SendElement(ctx, iq.BadRequestError())
 		return
 	}
-	exists, err := x.rep.UserExists(ctx, userEl.Text())
+	exists, err := x.rep.UserExists(ctx, userEl.Text(), stm.Domain())
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -158,9 +171,13 @@ func (x *Register) registerNewUser(ctx context.Context, iq *xmpp.IQ, query xmpp.
 	}
 	user := model.User{
 		Username:     userEl.Text(),
+		Domain:       stm.Domain(),
 		Password:     passwordEl.Text(),
 		LastPresence: xmpp.NewPresence(stm.JID(), stm.JID(), xmpp.UnavailableType),
 	}
+	if emailEl := query.Elements().Child("email"); emailEl != nil {
+		user.Email = emailEl.Text() // optional
+	}
 	if err := x.rep.UpsertUser(ctx, &user); err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -179,12 +196,15 @@ func (x *Register) cancelRegistration(ctx context.Context, iq *xmpp.IQ, query xm
 		stm.SendElement(ctx, iq.BadRequestError())
 		return
 	}
-	if err := x.rep.DeleteUser(ctx, stm.Username()); err != nil {
+	if err := x.accounts.DeleteAccount(ctx, stm.Username(), stm.Domain()); err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
 		return
 	}
 	stm.SendElement(ctx, iq.ResultIQ())
+
+	// close every session of the removed account
+	x.accounts.DisconnectSessions(ctx, stm.Username(), stm.Domain())
 }
 
 func (x *Register) changePassword(ctx context.Context, password string, username string, iq *xmpp.IQ, stm stream.C2S) {
@@ -201,7 +221,7 @@ func (x *Register) changePassword(ctx context.Context, password string, username
 		stm.SendElement(ctx, iq.NotAuthorizedError())
 		return
 	}
-	user, err := x.rep.FetchUser(ctx, username)
+	user, err := x.rep.FetchUser(ctx, username, stm.Domain())
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -28,7 +28,7 @@ func TestXEP0077_Matching(t *testing.T) {
 
 	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	// test MatchesIQ
@@ -49,7 +49,7 @@ func TestXEP0077_InvalidToJID(t *testing.T) {
 	stm1 := stream.NewMockC2S(uuid.New(), j1)
 	r.Bind(context.Background(), stm1)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
@@ -74,7 +74,7 @@ func TestXEP0077_NotAuthenticatedErrors(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
@@ -91,7 +91,7 @@ func TestXEP0077_NotAuthenticatedErrors(t *testing.T) {
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
 	// allow registration...
-	x = New(&Config{AllowRegistration: true}, nil, r, s)
+	x = New(&Config{AllowRegistration: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	q := xmpp.NewElementNamespace("query", registerNamespace)
@@ -122,7 +122,7 @@ func TestXEP0077_AuthenticatedErrors(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
@@ -150,7 +150,7 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New(), j)
 	r.Bind(context.Background(), stm)
 
-	x := New(&Config{AllowRegistration: true}, nil, r, s)
+	x := New(&Config{AllowRegistration: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
@@ -177,7 +177,7 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	// already existing user...
-	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
 	username.SetText("sxmpp")
 	password.SetText("5678")
 	x.ProcessIQ(context.Background(), iq)
@@ -192,12 +192,19 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	memorystorage.DisableMockedError()
 
 	username.SetText("juliet")
+	email := xmpp.NewElementName("email")
+	email.SetText("juliet@capulet.lit")
+	q.AppendElement(email)
 	x.ProcessIQ(context.Background(), iq)
 	elem = stm.ReceiveElement()
 	require.Equal(t, xmpp.ResultType, elem.Type())
 
-	usr, _ := s.FetchUser(context.Background(), "sxmpp")
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.NotNil(t, usr)
+
+	usr, _ = s.FetchUser(context.Background(), "juliet", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, "juliet@capulet.lit", usr.Email)
 }
 
 func TestXEP0077_CancelRegistration(t *testing.T) {
@@ -211,10 +218,10 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
-	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
 	iq.SetFromJID(j)
@@ -228,7 +235,7 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowCancel: true}, nil, r, s)
+	x = New(&Config{AllowCancel: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	q.AppendElement(xmpp.NewElementName("remove2"))
@@ -249,8 +256,12 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 	elem = stm.ReceiveElement()
 	require.Equal(t, xmpp.ResultType, elem.Type())
 
-	usr, _ := s.FetchUser(context.Background(), "sxmpp")
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, usr)
+
+	// account session must be closed
+	_ = x.Shutdown()
+	require.True(t, stm.IsDisconnected())
 }
 
 func TestXEP0077_ChangePassword(t *testing.T) {
@@ -264,10 +275,10 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, nil, r, s)
+	x := New(&Config{}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
-	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
 
 	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
 	iq.SetFromJID(j)
@@ -286,7 +297,7 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 	elem := stm.ReceiveElement()
 	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowChange: true}, nil, r, s)
+	x = New(&Config{AllowChange: true}, nil, r, s, nil)
 	defer func() { _ = x.Shutdown() }()
 
 	x.ProcessIQ(context.Background(), iq)
@@ -312,7 +323,7 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 	elem = stm.ReceiveElement()
 	require.Equal(t, xmpp.ResultType, elem.Type())
 
-	usr, _ := s.FetchUser(context.Background(), "sxmpp")
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.NotNil(t, usr)
 	require.Equal(t, "5678", usr.Password)
 }
@@ -59,8 +59,11 @@ func (x *Version) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a version IQ taking according actions over the associated stream.
 func (x *Version) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -9,6 +9,7 @@ import (
 	"context"
 	"crypto/tls"
 	"testing"
+	"time"
 
 	"github.com/sxmpp/jackal/router/host"
 
@@ -77,6 +78,14 @@ func TestXEP0092(t *testing.T) {
 	elem = stm.ReceiveElement()
 	ver = elem.Elements().ChildNamespace("query", versionNamespace)
 	require.Equal(t, osString, ver.Elements().Child("os").Text())
+
+	// expired request
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	x.ProcessIQ(ctx, iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrResourceConstraint.Error(), elem.Error().Elements().All()[0].Name())
 }
 
 func setupTest() router.Router {
@@ -79,8 +79,11 @@ func (x *EntityCaps) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a roster IQ taking according actions over the associated stream.
 func (x *EntityCaps) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
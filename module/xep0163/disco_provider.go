@@ -117,7 +117,7 @@ func (p *discoInfoProvider) isSubscribedTo(ctx context.Context, contact *jid.JID
 	if contact.MatchesWithOptions(userJID, jid.MatchesBare) {
 		return true
 	}
-	ri, err := p.rosterRep.FetchRosterItem(ctx, userJID.Node(), contact.ToBareJID().String())
+	ri, err := p.rosterRep.FetchRosterItem(ctx, userJID.Node(), userJID.Domain(), contact.ToBareJID().String())
 	if err != nil {
 		log.Error(err)
 		return false
@@ -94,6 +94,9 @@ func New(disco *xep0030.DiscoInfo, presenceHub *xep0115.EntityCaps, router route
 
 // MatchesIQ returns whether or not an IQ should be processed by the PEP module.
 func (x *Pep) MatchesIQ(iq *xmpp.IQ) bool {
+	if !iq.IsGet() && !iq.IsSet() {
+		return false // replies to pubsub requests issued by the server
+	}
 	pubSub := iq.Elements().Child("pubsub")
 	if pubSub == nil {
 		return false
@@ -107,8 +110,11 @@ func (x *Pep) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a version IQ taking according actions over the associated stream
 func (x *Pep) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
 		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -482,7 +488,7 @@ func (x *Pep) sendConfigurationForm(ctx context.Context, cmdCtx *commandContext,
 	configureNode := xmpp.NewElementName("configure")
 	configureNode.SetAttribute("node", cmdCtx.nodeID)
 
-	rosterGroups, err := x.rosterRep.FetchRosterGroups(ctx, iq.ToJID().Node())
+	rosterGroups, err := x.rosterRep.FetchRosterGroups(ctx, iq.ToJID().Node(), iq.ToJID().Domain())
 	if err != nil {
 		log.Error(err)
 		_ = x.router.Route(ctx, iq.InternalServerError())
@@ -967,6 +973,10 @@ func (x *Pep) notify(
 	notificationType string,
 ) {
 	hostJID, _ := jid.NewWithString(host, true)
+
+	// render event message just once
+	prepared := xmpp.NewPreparedStanza(eventMessage(notificationElem, hostJID, hostJID, notificationType))
+
 	for _, toJID := range toJIDs {
 		if toJID.ToBareJID().String() != host {
 			// check JID access before notifying
@@ -999,15 +1009,13 @@ func (x *Pep) notify(
 				// notify to full jid
 				presence := onlinePresence.Presence
 
-				eventMsg := eventMessage(notificationElem, hostJID, presence.FromJID(), notificationType)
-				_ = x.router.Route(ctx, eventMsg)
+				_ = x.router.Route(ctx, prepared.Stanza(presence.FromJID()))
 			}
 			return
 		}
 	broadcastEventMsg:
 		// broadcast event message
-		eventMsg := eventMessage(notificationElem, hostJID, &toJID, notificationType)
-		_ = x.router.Route(ctx, eventMsg)
+		_ = x.router.Route(ctx, prepared.Stanza(&toJID))
 	}
 }
 
@@ -1151,7 +1159,7 @@ func (x *Pep) createNode(ctx context.Context, node *pubsubmodel.Node) error {
 	if err != nil {
 		return err
 	}
-	rosterItems, _, err := x.rosterRep.FetchRosterItems(ctx, j.Node())
+	rosterItems, _, err := x.rosterRep.FetchRosterItems(ctx, j.Node(), j.Domain())
 	if err != nil {
 		return err
 	}
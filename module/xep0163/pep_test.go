@@ -177,6 +177,7 @@ func TestXEP163_SetNodeConfiguration(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -273,6 +274,7 @@ func TestXEP163_DeleteNode(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -602,6 +604,7 @@ func TestXEP163_Subscribe(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -683,6 +686,7 @@ func TestXEP163_Unsubscribe(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -746,6 +750,7 @@ func TestXEP163_RetrieveItems(t *testing.T) {
 	}, "sxmpp@jackal.im", "princely_musings")
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
@@ -836,6 +841,7 @@ func TestXEP163_SubscribeToAll(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "sxmpp@jackal.im",
 		Subscription: "both",
 	})
@@ -881,6 +887,7 @@ func TestXEP163_FilteredNotifications(t *testing.T) {
 
 	_, _ = rosterRep.UpsertRosterItem(context.Background(), &rostermodel.Item{
 		Username:     "sxmpp",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
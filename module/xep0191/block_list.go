@@ -64,12 +64,15 @@ func (x *BlockingCommand) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a blocking command IQ taking according actions over the associated stream.
 func (x *BlockingCommand) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
-		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource())
 		if stm == nil {
 			return
 		}
 		x.processIQ(ctx, iq, stm)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -96,7 +99,7 @@ func (x *BlockingCommand) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream
 
 func (x *BlockingCommand) sendBlockList(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
 	fromJID := iq.FromJID()
-	blItems, err := x.blockListRep.FetchBlockListItems(ctx, fromJID.Node())
+	blItems, err := x.blockListRep.FetchBlockListItems(ctx, fromJID.Node(), fromJID.Domain())
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -127,7 +130,7 @@ func (x *BlockingCommand) block(ctx context.Context, iq *xmpp.IQ, block xmpp.XEl
 		stm.SendElement(ctx, iq.JidMalformedError())
 		return
 	}
-	blItems, ris, err := x.fetchBlockListAndRosterItems(ctx, stm.Username())
+	blItems, ris, err := x.fetchBlockListAndRosterItems(ctx, stm.Username(), stm.Domain())
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -140,6 +143,7 @@ func (x *BlockingCommand) block(ctx context.Context, iq *xmpp.IQ, block xmpp.XEl
 		}
 		err := x.blockListRep.InsertBlockListItem(ctx, &model.BlockListItem{
 			Username: username,
+			Domain:   stm.Domain(),
 			JID:      j.String(),
 		})
 		if err != nil {
@@ -164,7 +168,7 @@ func (x *BlockingCommand) unblock(ctx context.Context, iq *xmpp.IQ, unblock xmpp
 	}
 	username := stm.Username()
 
-	blItems, ris, err := x.fetchBlockListAndRosterItems(ctx, username)
+	blItems, ris, err := x.fetchBlockListAndRosterItems(ctx, username, stm.Domain())
 	if err != nil {
 		log.Error(err)
 		stm.SendElement(ctx, iq.InternalServerError())
@@ -177,6 +181,7 @@ func (x *BlockingCommand) unblock(ctx context.Context, iq *xmpp.IQ, unblock xmpp
 			}
 			if err := x.blockListRep.DeleteBlockListItem(ctx, &model.BlockListItem{
 				Username: username,
+				Domain:   stm.Domain(),
 				JID:      j.String(),
 			}); err != nil {
 				log.Error(err)
@@ -203,7 +208,7 @@ func (x *BlockingCommand) unblock(ctx context.Context, iq *xmpp.IQ, unblock xmpp
 }
 
 func (x *BlockingCommand) pushIQ(ctx context.Context, elem xmpp.XElement, stm stream.C2S) {
-	streams := x.router.LocalStreams(stm.Username())
+	streams := x.router.LocalStreams(stm.Username(), stm.Domain())
 	for _, stm := range streams {
 		requested, _ := stm.Value(xep191RequestedContextKey).(bool)
 		if !requested {
@@ -256,12 +261,12 @@ func (x *BlockingCommand) isSubscribedTo(jid *jid.JID, ris []rostermodel.Item) b
 	return false
 }
 
-func (x *BlockingCommand) fetchBlockListAndRosterItems(ctx context.Context, username string) ([]model.BlockListItem, []rostermodel.Item, error) {
-	blItems, err := x.blockListRep.FetchBlockListItems(ctx, username)
+func (x *BlockingCommand) fetchBlockListAndRosterItems(ctx context.Context, username, domain string) ([]model.BlockListItem, []rostermodel.Item, error) {
+	blItems, err := x.blockListRep.FetchBlockListItems(ctx, username, domain)
 	if err != nil {
 		return nil, nil, err
 	}
-	ris, _, err := x.rosterRep.FetchRosterItems(ctx, username)
+	ris, _, err := x.rosterRep.FetchRosterItems(ctx, username, domain)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -90,12 +90,15 @@ func (x *Ping) MatchesIQ(iq *xmpp.IQ) bool {
 
 // ProcessIQ processes a ping IQ taking according actions over the associated stream.
 func (x *Ping) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
-	x.runQueue.Run(func() {
-		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Resource())
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource())
 		if stm == nil {
 			return
 		}
 		x.processIQ(ctx, iq, stm)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
 	})
 }
 
@@ -0,0 +1,190 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	carbonsNamespace = "urn:xmpp:carbons:2"
+	forwardNamespace = "urn:xmpp:forward:0"
+	hintsNamespace   = "urn:xmpp:hints"
+)
+
+const (
+	carbonsEnabledCtxKey = "xep_280:enabled"
+)
+
+// Carbons represents a message carbons server stream module.
+type Carbons struct {
+	runQueue *runqueue.RunQueue
+	router   router.Router
+}
+
+// New returns a message carbons IQ handler module.
+func New(disco *xep0030.DiscoInfo, router router.Router) *Carbons {
+	x := &Carbons{
+		runQueue: runqueue.New("xep0280"),
+		router:   router,
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(carbonsNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the message carbons module.
+func (x *Carbons) MatchesIQ(iq *xmpp.IQ) bool {
+	e := iq.Elements()
+	return e.ChildNamespace("enable", carbonsNamespace) != nil || e.ChildNamespace("disable", carbonsNamespace) != nil
+}
+
+// ProcessIQ processes a message carbons IQ taking according actions over the associated stream.
+func (x *Carbons) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		stm := x.router.LocalStream(iq.FromJID().Node(), iq.FromJID().Domain(), iq.FromJID().Resource())
+		if stm == nil {
+			return
+		}
+		x.processIQ(ctx, iq, stm)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
+	})
+}
+
+// ProcessOutgoingMessage sends a 'sent' carbon copy of a message to every other
+// carbons enabled resource of the sending user.
+func (x *Carbons) ProcessOutgoingMessage(ctx context.Context, message *xmpp.Message) {
+	if !isMessageCopyable(message) {
+		return
+	}
+	fromJID := message.FromJID()
+	for _, stm := range x.router.LocalStreams(fromJID.Node(), fromJID.Domain()) {
+		if stm.Resource() == fromJID.Resource() || !isCarbonsEnabled(stm) {
+			continue
+		}
+		stm.SendElement(ctx, carbonCopy("sent", message, stm.JID()))
+	}
+}
+
+// ProcessIncomingMessage sends a 'received' carbon copy of a delivered message to every
+// carbons enabled resource of the recipient user that didn't receive it.
+func (x *Carbons) ProcessIncomingMessage(ctx context.Context, message *xmpp.Message) {
+	if !isMessageCopyable(message) {
+		return
+	}
+	toJID := message.ToJID()
+	streams := x.router.LocalStreams(toJID.Node(), toJID.Domain())
+
+	var recipient string
+	if toJID.IsFullWithUser() {
+		recipient = toJID.Resource()
+	} else if recipient = highestPriorityResource(streams); len(recipient) == 0 {
+		return // message was delivered to all available resources
+	}
+	for _, stm := range streams {
+		if stm.Resource() == recipient || !isCarbonsEnabled(stm) {
+			continue
+		}
+		stm.SendElement(ctx, carbonCopy("received", message, stm.JID()))
+	}
+}
+
+// Shutdown shuts down message carbons module.
+func (x *Carbons) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Carbons) processIQ(ctx context.Context, iq *xmpp.IQ, stm stream.C2S) {
+	toJID := iq.ToJID()
+	validTo := toJID.IsServer() || (toJID.Node() == stm.Username() && toJID.Domain() == stm.Domain())
+	if !validTo {
+		stm.SendElement(ctx, iq.ForbiddenError())
+		return
+	}
+	if !iq.IsSet() {
+		stm.SendElement(ctx, iq.BadRequestError())
+		return
+	}
+	enabled := iq.Elements().ChildNamespace("enable", carbonsNamespace) != nil
+	stm.SetValue(carbonsEnabledCtxKey, enabled)
+
+	log.Infof("message carbons enabled: %t... (%s/%s)", enabled, stm.Username(), stm.Resource())
+
+	stm.SendElement(ctx, iq.ResultIQ())
+}
+
+func carbonCopy(direction string, message *xmpp.Message, toJID *jid.JID) *xmpp.Message {
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(message)
+
+	carbon := xmpp.NewElementNamespace(direction, carbonsNamespace)
+	carbon.AppendElement(forwarded)
+
+	fromJID := toJID.ToBareJID()
+
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetID(uuid.New())
+	msgEl.SetType(message.Type())
+	msgEl.SetFrom(fromJID.String())
+	msgEl.SetTo(toJID.String())
+	msgEl.AppendElement(carbon)
+
+	msg, _ := xmpp.NewMessageFromElement(msgEl, fromJID, toJID)
+	return msg
+}
+
+// highestPriorityResource returns the resource a message addressed to the user bare JID is routed to,
+// or an empty string in case the message is broadcasted to all available resources.
+// (keep in sync with c2s router delivery rules)
+func highestPriorityResource(streams []stream.C2S) string {
+	var highestPriority int8
+	var resource string
+
+	for _, stm := range streams {
+		if p := stm.Presence(); p != nil && p.IsAvailable() && p.Priority() > highestPriority {
+			resource = stm.Resource()
+			highestPriority = p.Priority()
+		}
+	}
+	return resource
+}
+
+func isCarbonsEnabled(stm stream.C2S) bool {
+	enabled, _ := stm.Value(carbonsEnabledCtxKey).(bool)
+	if !enabled {
+		return false
+	}
+	p := stm.Presence()
+	return p != nil && p.IsAvailable()
+}
+
+func isMessageCopyable(message *xmpp.Message) bool {
+	// chat messages and normal messages containing a body are eligible for carbons (XEP-0280 section 6)
+	if !message.IsChat() && !(message.IsNormal() && message.IsMessageWithBody()) {
+		return false
+	}
+	e := message.Elements()
+	if e.ChildNamespace("private", carbonsNamespace) != nil || e.ChildNamespace("no-copy", hintsNamespace) != nil {
+		return false
+	}
+	// never copy carbon copies
+	return e.ChildNamespace("sent", carbonsNamespace) == nil && e.ChildNamespace("received", carbonsNamespace) == nil
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0280_Matching(t *testing.T) {
+	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+
+	x := New(nil, nil)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0280_EnableDisable(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	// forbidden target
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j2.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid type
+	iq.SetType(xmpp.GetType)
+	iq.SetToJID(j1.ToBareJID())
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// enable
+	iq.SetType(xmpp.SetType)
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.True(t, isCarbonsEnabled(stm))
+
+	// disable
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.False(t, isCarbonsEnabled(stm))
+}
+
+func TestXEP0280_OutgoingMessage(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("sxmpp", "jackal.im", "garden", true)
+	j3, _ := jid.New("sxmpp", "jackal.im", "hall", true)
+	j4, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm1.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	stm1.SetValue(carbonsEnabledCtxKey, true)
+	r.Bind(context.Background(), stm1)
+
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	stm2.SetValue(carbonsEnabledCtxKey, true)
+	r.Bind(context.Background(), stm2)
+
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	stm3.SetPresence(xmpp.NewPresence(j3, j3, xmpp.AvailableType))
+	r.Bind(context.Background(), stm3)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	msg := testChatMessage(j1, j4, "hi!")
+	x.ProcessOutgoingMessage(context.Background(), msg)
+
+	elem := stm2.ReceiveElement()
+	require.Equal(t, "sxmpp@jackal.im", elem.From())
+	require.Equal(t, j2.String(), elem.To())
+
+	sent := elem.Elements().ChildNamespace("sent", carbonsNamespace)
+	require.NotNil(t, sent)
+	forwarded := sent.Elements().ChildNamespace("forwarded", forwardNamespace)
+	require.NotNil(t, forwarded)
+	require.Equal(t, msg.ID(), forwarded.Elements().Child("message").ID())
+
+	// private messages
+	msg = testChatMessage(j1, j4, "shh...")
+	msg.AppendElement(xmpp.NewElementNamespace("private", carbonsNamespace))
+	x.ProcessOutgoingMessage(context.Background(), msg)
+
+	msg = testChatMessage(j1, j4, "shh...")
+	msg.AppendElement(xmpp.NewElementNamespace("no-copy", hintsNamespace))
+	x.ProcessOutgoingMessage(context.Background(), msg)
+
+	// only copyable message should be received
+	msg = testChatMessage(j1, j4, "bye!")
+	x.ProcessOutgoingMessage(context.Background(), msg)
+
+	elem = stm2.ReceiveElement()
+	sent = elem.Elements().ChildNamespace("sent", carbonsNamespace)
+	require.NotNil(t, sent)
+	require.Equal(t, msg.ID(), sent.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+}
+
+func TestXEP0280_IncomingMessage(t *testing.T) {
+	r := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("sxmpp", "jackal.im", "garden", true)
+	j3, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	p1 := xmpp.NewElementName("presence")
+	priority := xmpp.NewElementName("priority")
+	priority.SetText("10")
+	p1.AppendElement(priority)
+	presence1, _ := xmpp.NewPresenceFromElement(p1, j1, j1)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm1.SetPresence(presence1)
+	stm1.SetValue(carbonsEnabledCtxKey, true)
+	r.Bind(context.Background(), stm1)
+
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm2.SetPresence(xmpp.NewPresence(j2, j2, xmpp.AvailableType))
+	stm2.SetValue(carbonsEnabledCtxKey, true)
+	r.Bind(context.Background(), stm2)
+
+	x := New(nil, r)
+	defer func() { _ = x.Shutdown() }()
+
+	// addressed to a full JID
+	msg := testChatMessage(j3, j2, "hi!")
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	elem := stm1.ReceiveElement()
+	received := elem.Elements().ChildNamespace("received", carbonsNamespace)
+	require.NotNil(t, received)
+	require.Equal(t, msg.ID(), received.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+
+	// addressed to bare JID (delivered to highest priority resource)
+	msg = testChatMessage(j3, j1.ToBareJID(), "hi!")
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	elem = stm2.ReceiveElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("received", carbonsNamespace))
+
+	// non chat messages
+	msg = testChatMessage(j3, j2, "hi!")
+	msg.SetType(xmpp.GroupChatType)
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	// normal messages without body
+	msg = testChatMessage(j3, j2, "")
+	msg.SetType(xmpp.NormalType)
+	msg.ClearElements()
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	// only last message copy should be received
+	msg = testChatMessage(j3, j2, "bye!")
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	elem = stm1.ReceiveElement()
+	received = elem.Elements().ChildNamespace("received", carbonsNamespace)
+	require.NotNil(t, received)
+	require.Equal(t, msg.ID(), received.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+
+	// normal messages with body
+	msg = testChatMessage(j3, j2, "hello")
+	msg.SetType(xmpp.NormalType)
+	x.ProcessIncomingMessage(context.Background(), msg)
+
+	elem = stm1.ReceiveElement()
+	received = elem.Elements().ChildNamespace("received", carbonsNamespace)
+	require.NotNil(t, received)
+	require.Equal(t, msg.ID(), received.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+}
+
+func testChatMessage(from, to *jid.JID, body string) *xmpp.Message {
+	b := xmpp.NewElementName("body")
+	b.SetText(body)
+
+	msg := xmpp.NewElementName("message")
+	msg.SetID(uuid.New())
+	msg.SetType(xmpp.ChatType)
+	msg.AppendElement(b)
+
+	m, _ := xmpp.NewMessageFromElement(msg, from, to)
+	return m
+}
+
+func setupTest(domain string) router.Router {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"fmt"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+)
+
+const (
+	defaultMaxPageSize = 100
+)
+
+// Config represents Message Archive Management module (XEP-0313) configuration.
+type Config struct {
+	// DefaultMode is the archiving mode applied to those users that didn't set their own preferences.
+	DefaultMode string
+
+	// MaxPageSize limits the number of messages returned in response to a single query.
+	MaxPageSize int
+
+	// Retention defines how long archived messages are kept. Zero value means messages are never purged.
+	Retention time.Duration
+}
+
+type configProxy struct {
+	DefaultMode   string `yaml:"default_mode"`
+	MaxPageSize   int    `yaml:"max_page_size"`
+	RetentionDays int    `yaml:"retention_days"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	cfg.DefaultMode = p.DefaultMode
+	if len(cfg.DefaultMode) == 0 {
+		cfg.DefaultMode = archivemodel.Always
+	}
+	if !archivemodel.IsValidMode(cfg.DefaultMode) {
+		return fmt.Errorf("xep0313.Config: unrecognized default mode: %s", cfg.DefaultMode)
+	}
+	cfg.MaxPageSize = p.MaxPageSize
+	if cfg.MaxPageSize == 0 {
+		cfg.MaxPageSize = defaultMaxPageSize
+	}
+	if cfg.MaxPageSize < 0 {
+		return fmt.Errorf("xep0313.Config: max page size must be a positive value")
+	}
+	if p.RetentionDays < 0 {
+		return fmt.Errorf("xep0313.Config: retention days must be a positive value")
+	}
+	cfg.Retention = time.Duration(p.RetentionDays) * 24 * time.Hour
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"testing"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMAMConfig(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(`default_mode: sometimes`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`retention_days: -1`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`max_page_size: 20`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, archivemodel.Always, cfg.DefaultMode)
+	require.Equal(t, 20, cfg.MaxPageSize)
+	require.Equal(t, time.Duration(0), cfg.Retention)
+
+	goodCfg := `
+default_mode: roster
+retention_days: 30
+`
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(goodCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, archivemodel.Roster, cfg.DefaultMode)
+	require.Equal(t, defaultMaxPageSize, cfg.MaxPageSize)
+	require.Equal(t, 30*24*time.Hour, cfg.Retention)
+}
@@ -0,0 +1,584 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	mamNamespace      = "urn:xmpp:mam:2"
+	stanzaIDNamespace = "urn:xmpp:sid:0"
+	rsmNamespace      = "http://jabber.org/protocol/rsm"
+	forwardNamespace  = "urn:xmpp:forward:0"
+	delayNamespace    = "urn:xmpp:delay"
+	hintsNamespace    = "urn:xmpp:hints"
+)
+
+const stampLayout = "2006-01-02T15:04:05Z"
+
+var errInvalidQuery = errors.New("xep0313: invalid query")
+
+// retentionInterval defines how often expired archived messages are purged.
+var retentionInterval = time.Hour
+
+// MAM represents a message archive management server stream module.
+type MAM struct {
+	cfg        Config
+	router     router.Router
+	runQueue   *runqueue.RunQueue
+	userRep    repository.User
+	rosterRep  repository.Roster
+	archiveRep repository.Archive
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// New returns a message archive management IQ handler module.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, userRep repository.User, rosterRep repository.Roster, archiveRep repository.Archive) *MAM {
+	x := &MAM{
+		cfg:        *config,
+		router:     router,
+		runQueue:   runqueue.New("xep0313"),
+		userRep:    userRep,
+		rosterRep:  rosterRep,
+		archiveRep: archiveRep,
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	if len(x.cfg.DefaultMode) == 0 {
+		x.cfg.DefaultMode = archivemodel.Always
+	}
+	if x.cfg.MaxPageSize <= 0 {
+		x.cfg.MaxPageSize = defaultMaxPageSize
+	}
+	if disco != nil {
+		disco.RegisterServerFeature(stanzaIDNamespace)
+		disco.RegisterAccountFeature(mamNamespace)
+		disco.RegisterAccountFeature(stanzaIDNamespace)
+	}
+	if x.cfg.Retention > 0 {
+		go x.loop()
+	} else {
+		close(x.doneCh)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the message archive management module.
+func (x *MAM) MatchesIQ(iq *xmpp.IQ) bool {
+	return iq.Elements().ChildNamespace("query", mamNamespace) != nil || iq.Elements().ChildNamespace("prefs", mamNamespace) != nil
+}
+
+// ProcessIQ processes a message archive management IQ taking according actions over the associated stream.
+func (x *MAM) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
+	})
+}
+
+// ArchiveOutgoingMessage stores a message sent by a local user into its archive.
+func (x *MAM) ArchiveOutgoingMessage(ctx context.Context, message *xmpp.Message) {
+	x.runQueue.Run(func() {
+		fromJID := message.FromJID()
+		if _, err := x.archiveMessage(ctx, message, fromJID.Node(), fromJID.Domain(), message.ToJID()); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// ArchiveIncomingMessage stores a message addressed to a local user into its archive, handing over to deliver
+// the message to be delivered in its place, stamped with its archive identifier.
+//
+// Archiving takes place on the module queue, so that deliver might be called from a different goroutine.
+func (x *MAM) ArchiveIncomingMessage(ctx context.Context, message *xmpp.Message, deliver func(ctx context.Context, message *xmpp.Message)) {
+	if len(message.ToJID().Node()) == 0 {
+		deliver(ctx, message)
+		return
+	}
+	x.runQueue.Run(func() {
+		deliver(ctx, x.archiveIncomingMessage(ctx, message))
+	})
+}
+
+func (x *MAM) archiveIncomingMessage(ctx context.Context, message *xmpp.Message) *xmpp.Message {
+	toJID := message.ToJID()
+	stamped := stripStanzaIDs(message, toJID.ToBareJID().String())
+
+	id, err := x.archiveMessage(ctx, stamped, toJID.Node(), toJID.Domain(), message.FromJID())
+	if err != nil {
+		log.Error(err)
+		return stamped
+	}
+	if len(id) == 0 {
+		return stamped // not archived
+	}
+	stanzaID := xmpp.NewElementNamespace("stanza-id", stanzaIDNamespace)
+	stanzaID.SetAttribute("by", toJID.ToBareJID().String())
+	stanzaID.SetAttribute("id", id)
+	stamped.AppendElement(stanzaID)
+	return stamped
+}
+
+// Shutdown shuts down message archive management module.
+func (x *MAM) Shutdown() error {
+	x.stopOnce.Do(func() {
+		close(x.stopCh)
+		<-x.doneCh
+	})
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *MAM) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	fromJID := iq.FromJID()
+	toJID := iq.ToJID()
+	validTo := toJID.IsServer() || (toJID.Node() == fromJID.Node() && toJID.Domain() == fromJID.Domain())
+	if !validTo {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	if q := iq.Elements().ChildNamespace("query", mamNamespace); q != nil {
+		if iq.IsGet() {
+			x.sendQueryForm(ctx, iq)
+		} else if iq.IsSet() {
+			x.queryArchive(ctx, iq, q)
+		} else {
+			_ = x.router.Route(ctx, iq.BadRequestError())
+		}
+		return
+	}
+	prefs := iq.Elements().ChildNamespace("prefs", mamNamespace)
+	if iq.IsGet() {
+		x.sendPreferences(ctx, iq)
+	} else if iq.IsSet() {
+		x.setPreferences(ctx, iq, prefs)
+	} else {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+	}
+}
+
+func (x *MAM) sendQueryForm(ctx context.Context, iq *xmpp.IQ) {
+	form := xep0004.DataForm{
+		Type: xep0004.Form,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Type: xep0004.JidSingle},
+			{Var: "start", Type: xep0004.TextSingle},
+			{Var: "end", Type: xep0004.TextSingle},
+		},
+	}
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.AppendElement(form.Element())
+
+	res := iq.ResultIQ()
+	res.AppendElement(query)
+	_ = x.router.Route(ctx, res)
+}
+
+func (x *MAM) queryArchive(ctx context.Context, iq *xmpp.IQ, q xmpp.XElement) {
+	query, err := x.parseQuery(q)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	userJID := iq.FromJID().ToBareJID()
+	username, domain := userJID.Node(), userJID.Domain()
+
+	// make sure paging references exist
+	for _, id := range []string{query.After, query.Before} {
+		if len(id) == 0 {
+			continue
+		}
+		ok, err := x.archiveRep.ArchiveMessageExists(ctx, id, username, domain)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if !ok {
+			_ = x.router.Route(ctx, iq.ItemNotFoundError())
+			return
+		}
+	}
+	count, err := x.archiveRep.CountArchiveMessages(ctx, query, username, domain)
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	var messages []archivemodel.Message
+	complete := true
+
+	if maxSize := query.Max; maxSize > 0 {
+		query.Max = maxSize + 1 // fetch an extra message to find out whether or not the result set is complete
+		messages, err = x.archiveRep.FetchArchiveMessages(ctx, query, username, domain)
+		if err != nil {
+			log.Error(err)
+			_ = x.router.Route(ctx, iq.InternalServerError())
+			return
+		}
+		if len(messages) > maxSize {
+			complete = false
+			if query.IsBackward() {
+				messages = messages[1:]
+			} else {
+				messages = messages[:maxSize]
+			}
+		}
+	} else {
+		complete = count == 0
+	}
+	log.Infof("retrieving archived messages... (%s/%s) count: %d", username, iq.FromJID().Resource(), len(messages))
+
+	queryID := q.Attributes().Get("queryid")
+	for i := range messages {
+		_ = x.router.Route(ctx, resultMessage(&messages[i], queryID, userJID, iq.FromJID()))
+	}
+	fin := xmpp.NewElementNamespace("fin", mamNamespace)
+	if complete {
+		fin.SetAttribute("complete", "true")
+	}
+	set := xmpp.NewElementNamespace("set", rsmNamespace)
+	if len(messages) > 0 {
+		first := xmpp.NewElementName("first")
+		first.SetText(messages[0].ID)
+		last := xmpp.NewElementName("last")
+		last.SetText(messages[len(messages)-1].ID)
+		set.AppendElement(first)
+		set.AppendElement(last)
+	}
+	countEl := xmpp.NewElementName("count")
+	countEl.SetText(strconv.Itoa(count))
+	set.AppendElement(countEl)
+	fin.AppendElement(set)
+
+	res := iq.ResultIQ()
+	res.AppendElement(fin)
+	_ = x.router.Route(ctx, res)
+}
+
+func (x *MAM) parseQuery(q xmpp.XElement) (*archivemodel.Query, error) {
+	query := &archivemodel.Query{Max: x.cfg.MaxPageSize}
+
+	if formEl := q.Elements().ChildNamespace("x", xep0004.FormNamespace); formEl != nil {
+		form, err := xep0004.NewFormFromElement(formEl)
+		if err != nil {
+			return nil, err
+		}
+		if form.Type != xep0004.Submit {
+			return nil, errInvalidQuery
+		}
+		for _, field := range form.Fields {
+			if len(field.Values) == 0 {
+				continue
+			}
+			val := field.Values[0]
+			switch field.Var {
+			case xep0004.FormType:
+				if val != mamNamespace {
+					return nil, errInvalidQuery
+				}
+			case "with":
+				withJID, err := jid.NewWithString(val, false)
+				if err != nil {
+					return nil, err
+				}
+				query.With = withJID.String()
+			case "start":
+				query.Start, err = time.Parse(time.RFC3339, val)
+				if err != nil {
+					return nil, err
+				}
+			case "end":
+				query.End, err = time.Parse(time.RFC3339, val)
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return nil, errInvalidQuery
+			}
+		}
+	}
+	if set := q.Elements().ChildNamespace("set", rsmNamespace); set != nil {
+		if maxEl := set.Elements().Child("max"); maxEl != nil {
+			max, err := strconv.Atoi(maxEl.Text())
+			if err != nil || max < 0 {
+				return nil, errInvalidQuery
+			}
+			if max < query.Max {
+				query.Max = max
+			}
+		}
+		if after := set.Elements().Child("after"); after != nil {
+			query.After = after.Text()
+		}
+		if before := set.Elements().Child("before"); before != nil {
+			query.Before = before.Text()
+			query.Last = len(query.Before) == 0
+		}
+	}
+	return query, nil
+}
+
+func (x *MAM) sendPreferences(ctx context.Context, iq *xmpp.IQ) {
+	userJID := iq.FromJID()
+	prefs, err := x.fetchPreferences(ctx, userJID.Node(), userJID.Domain())
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(preferencesElement(prefs))
+	_ = x.router.Route(ctx, res)
+}
+
+func (x *MAM) setPreferences(ctx context.Context, iq *xmpp.IQ, prefsEl xmpp.XElement) {
+	prefs := &archivemodel.Preferences{Default: prefsEl.Attributes().Get("default")}
+	if !archivemodel.IsValidMode(prefs.Default) {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	var err error
+	if prefs.Always, err = preferencesJIDs(prefsEl.Elements().Child("always")); err != nil {
+		_ = x.router.Route(ctx, iq.JidMalformedError())
+		return
+	}
+	if prefs.Never, err = preferencesJIDs(prefsEl.Elements().Child("never")); err != nil {
+		_ = x.router.Route(ctx, iq.JidMalformedError())
+		return
+	}
+	userJID := iq.FromJID()
+	if err := x.archiveRep.UpsertArchivePreferences(ctx, prefs, userJID.Node(), userJID.Domain()); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("updated archiving preferences... (%s/%s) default: %s", userJID.Node(), userJID.Resource(), prefs.Default)
+
+	res := iq.ResultIQ()
+	res.AppendElement(preferencesElement(prefs))
+	_ = x.router.Route(ctx, res)
+}
+
+func (x *MAM) fetchPreferences(ctx context.Context, username, domain string) (*archivemodel.Preferences, error) {
+	prefs, err := x.archiveRep.FetchArchivePreferences(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		return &archivemodel.Preferences{Default: x.cfg.DefaultMode}, nil
+	}
+	return prefs, nil
+}
+
+// archiveMessage stores a message into a user archive, returning its archive identifier.
+// An empty identifier is returned in case the message was not archived.
+func (x *MAM) archiveMessage(ctx context.Context, message *xmpp.Message, username, domain string, withJID *jid.JID) (string, error) {
+	if !isMessageArchivable(message) {
+		return "", nil
+	}
+	ok, err := x.userRep.UserExists(ctx, username, domain)
+	if err != nil || !ok {
+		return "", err
+	}
+	ok, err = x.shouldArchive(ctx, username, domain, withJID)
+	if err != nil || !ok {
+		return "", err
+	}
+	archived := &archivemodel.Message{
+		ID:       uuid.New(),
+		Username: username,
+		Domain:   domain,
+		With:     withJID.String(),
+		Message:  message,
+		Stamp:    time.Now().UTC(),
+	}
+	if err := x.archiveRep.InsertArchiveMessage(ctx, archived); err != nil {
+		return "", err
+	}
+	log.Debugf("archived message... (%s@%s) id: %s", username, domain, archived.ID)
+	return archived.ID, nil
+}
+
+// shouldArchive tells whether or not messages exchanged with a given JID must be archived,
+// according to user archiving preferences.
+func (x *MAM) shouldArchive(ctx context.Context, username, domain string, withJID *jid.JID) (bool, error) {
+	prefs, err := x.fetchPreferences(ctx, username, domain)
+	if err != nil {
+		return false, err
+	}
+	bareWith := withJID.ToBareJID().String()
+	if containsJID(prefs.Never, bareWith) {
+		return false, nil
+	}
+	if containsJID(prefs.Always, bareWith) {
+		return true, nil
+	}
+	switch prefs.Default {
+	case archivemodel.Always:
+		return true, nil
+	case archivemodel.Roster:
+		ri, err := x.rosterRep.FetchRosterItem(ctx, username, domain, bareWith)
+		if err != nil {
+			return false, err
+		}
+		return ri != nil, nil
+	default:
+		return false, nil
+	}
+}
+
+// stripStanzaIDs returns a message copy, removing every stanza-id element claiming to be assigned by a given entity.
+func stripStanzaIDs(message *xmpp.Message, by string) *xmpp.Message {
+	msg, _ := xmpp.NewMessageFromElement(message, message.FromJID(), message.ToJID())
+
+	var spoofed bool
+	elements := msg.Elements().All()
+	for _, el := range elements {
+		if el.Name() == "stanza-id" && el.Namespace() == stanzaIDNamespace && el.Attributes().Get("by") == by {
+			spoofed = true
+			break
+		}
+	}
+	if !spoofed {
+		return msg
+	}
+	msg.ClearElements()
+	for _, el := range elements {
+		if el.Name() == "stanza-id" && el.Namespace() == stanzaIDNamespace && el.Attributes().Get("by") == by {
+			continue
+		}
+		msg.AppendElement(el)
+	}
+	return msg
+}
+
+func (x *MAM) loop() {
+	defer close(x.doneCh)
+
+	tc := time.NewTicker(retentionInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			x.purgeMessages(context.Background())
+		case <-x.stopCh:
+			return
+		}
+	}
+}
+
+// purgeMessages removes every archived message older than configured retention period.
+func (x *MAM) purgeMessages(ctx context.Context) {
+	if err := x.archiveRep.DeleteArchiveMessages(ctx, time.Now().Add(-x.cfg.Retention)); err != nil {
+		log.Error(err)
+	}
+}
+
+func resultMessage(archived *archivemodel.Message, queryID string, fromJID, toJID *jid.JID) *xmpp.Message {
+	delay := xmpp.NewElementNamespace("delay", delayNamespace)
+	delay.SetAttribute("stamp", archived.Stamp.UTC().Format(stampLayout))
+
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(delay)
+	forwarded.AppendElement(archived.Message)
+
+	result := xmpp.NewElementNamespace("result", mamNamespace)
+	if len(queryID) > 0 {
+		result.SetAttribute("queryid", queryID)
+	}
+	result.SetAttribute("id", archived.ID)
+	result.AppendElement(forwarded)
+
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetID(uuid.New())
+	msgEl.SetFrom(fromJID.String())
+	msgEl.SetTo(toJID.String())
+	msgEl.AppendElement(result)
+
+	msg, _ := xmpp.NewMessageFromElement(msgEl, fromJID, toJID)
+	return msg
+}
+
+func preferencesElement(prefs *archivemodel.Preferences) xmpp.XElement {
+	prefsEl := xmpp.NewElementNamespace("prefs", mamNamespace)
+	prefsEl.SetAttribute("default", prefs.Default)
+
+	always := xmpp.NewElementName("always")
+	for _, j := range prefs.Always {
+		jidEl := xmpp.NewElementName("jid")
+		jidEl.SetText(j)
+		always.AppendElement(jidEl)
+	}
+	never := xmpp.NewElementName("never")
+	for _, j := range prefs.Never {
+		jidEl := xmpp.NewElementName("jid")
+		jidEl.SetText(j)
+		never.AppendElement(jidEl)
+	}
+	prefsEl.AppendElement(always)
+	prefsEl.AppendElement(never)
+	return prefsEl
+}
+
+func preferencesJIDs(elem xmpp.XElement) ([]string, error) {
+	if elem == nil {
+		return nil, nil
+	}
+	var jids []string
+	for _, jidEl := range elem.Elements().Children("jid") {
+		j, err := jid.NewWithString(jidEl.Text(), false)
+		if err != nil {
+			return nil, err
+		}
+		jids = append(jids, j.ToBareJID().String())
+	}
+	return jids, nil
+}
+
+func containsJID(jids []string, j string) bool {
+	for _, s := range jids {
+		if s == j {
+			return true
+		}
+	}
+	return false
+}
+
+func isMessageArchivable(message *xmpp.Message) bool {
+	if message.Elements().ChildNamespace("no-store", hintsNamespace) != nil {
+		return false
+	}
+	if message.Elements().ChildNamespace("store", hintsNamespace) != nil {
+		return true
+	}
+	return (message.IsNormal() || message.IsChat()) && message.IsMessageWithBody()
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	"github.com/sxmpp/jackal/model"
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testRepos struct {
+	user    *memorystorage.User
+	roster  *memorystorage.Roster
+	archive *memorystorage.Archive
+}
+
+func TestXEP0313_Matching(t *testing.T) {
+	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+
+	x := New(&Config{}, nil, nil, nil, nil, nil)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.AppendElement(xmpp.NewElementNamespace("prefs", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0313_ArchiveIncomingMessage(t *testing.T) {
+	r, reps := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	x := New(&Config{}, nil, r, reps.user, reps.roster, reps.archive)
+	defer func() { _ = x.Shutdown() }()
+
+	// spoofed stanza-id must be removed
+	spoofed := xmpp.NewElementNamespace("stanza-id", stanzaIDNamespace)
+	spoofed.SetAttribute("by", "sxmpp@jackal.im")
+	spoofed.SetAttribute("id", "spoofed")
+
+	msg := testChatMessage(j2, j1, "hi!")
+	msg.AppendElement(spoofed)
+
+	stamped := tUtilArchiveIncomingMessage(x, msg)
+	stanzaIDs := stamped.Elements().ChildrenNamespace("stanza-id", stanzaIDNamespace)
+	require.Len(t, stanzaIDs, 1)
+	require.Equal(t, "sxmpp@jackal.im", stanzaIDs[0].Attributes().Get("by"))
+	require.NotEqual(t, "spoofed", stanzaIDs[0].Attributes().Get("id"))
+
+	ok, _ := reps.archive.ArchiveMessageExists(context.Background(), stanzaIDs[0].Attributes().Get("id"), "sxmpp", "jackal.im")
+	require.True(t, ok)
+
+	// no-store hint
+	msg = testChatMessage(j2, j1, "forget me")
+	msg.AppendElement(xmpp.NewElementNamespace("no-store", hintsNamespace))
+
+	stamped = tUtilArchiveIncomingMessage(x, msg)
+	require.Nil(t, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+
+	count, _ := reps.archive.CountArchiveMessages(context.Background(), &archivemodel.Query{}, "sxmpp", "jackal.im")
+	require.Equal(t, 1, count)
+
+	// unknown recipient
+	j3, _ := jid.New("juliet", "jackal.im", "balcony", true)
+	stamped = tUtilArchiveIncomingMessage(x, testChatMessage(j2, j3, "hi!"))
+	require.Nil(t, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+}
+
+func TestXEP0313_Preferences(t *testing.T) {
+	r, reps := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	j3, _ := jid.New("juliet", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{}, nil, r, reps.user, reps.roster, reps.archive)
+	defer func() { _ = x.Shutdown() }()
+
+	// default preferences
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("prefs", mamNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.Equal(t, archivemodel.Always, elem.Elements().ChildNamespace("prefs", mamNamespace).Attributes().Get("default"))
+
+	// invalid default mode
+	prefs := xmpp.NewElementNamespace("prefs", mamNamespace)
+	prefs.SetAttribute("default", "sometimes")
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(prefs)
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// roster mode, never archiving juliet
+	prefs = xmpp.NewElementNamespace("prefs", mamNamespace)
+	prefs.SetAttribute("default", archivemodel.Roster)
+	never := xmpp.NewElementName("never")
+	jidEl := xmpp.NewElementName("jid")
+	jidEl.SetText(j3.String())
+	never.AppendElement(jidEl)
+	prefs.AppendElement(never)
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(prefs)
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	stored, _ := reps.archive.FetchArchivePreferences(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, stored)
+	require.Equal(t, archivemodel.Roster, stored.Default)
+	require.Equal(t, []string{"juliet@jackal.im"}, stored.Never)
+
+	// romeo is not in roster
+	stamped := tUtilArchiveIncomingMessage(x, testChatMessage(j2, j1, "hi!"))
+	require.Nil(t, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+
+	_, _ = reps.roster.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		JID:          "romeo@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	_, _ = reps.roster.UpsertRosterItem(context.Background(), &rostermodel.Item{
+		Username:     "sxmpp",
+		Domain:       "jackal.im",
+		JID:          "juliet@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	stamped = tUtilArchiveIncomingMessage(x, testChatMessage(j2, j1, "hi!"))
+	require.NotNil(t, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+
+	stamped = tUtilArchiveIncomingMessage(x, testChatMessage(j3, j1, "hi!"))
+	require.Nil(t, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+}
+
+func TestXEP0313_QueryArchive(t *testing.T) {
+	r, reps := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	j3, _ := jid.New("juliet", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{MaxPageSize: 2}, nil, r, reps.user, reps.roster, reps.archive)
+	defer func() { _ = x.Shutdown() }()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		stamped := tUtilArchiveIncomingMessage(x, testChatMessage(j2, j1, "hi!"))
+		ids = append(ids, stamped.Elements().ChildNamespace("stanza-id", stanzaIDNamespace).Attributes().Get("id"))
+	}
+	x.ArchiveOutgoingMessage(context.Background(), testChatMessage(j1, j3, "hello"))
+
+	// first page (page size limited by configuration)
+	x.ProcessIQ(context.Background(), testQueryIQ(j1, "q1", "romeo@jackal.im", "", "10"))
+
+	for i := 0; i < 2; i++ {
+		elem := stm.ReceiveElement()
+		result := elem.Elements().ChildNamespace("result", mamNamespace)
+		require.NotNil(t, result)
+		require.Equal(t, "q1", result.Attributes().Get("queryid"))
+		require.Equal(t, ids[i], result.Attributes().Get("id"))
+		require.NotNil(t, result.Elements().ChildNamespace("forwarded", forwardNamespace))
+	}
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	fin := elem.Elements().ChildNamespace("fin", mamNamespace)
+	require.NotNil(t, fin)
+	require.Equal(t, "", fin.Attributes().Get("complete"))
+
+	set := fin.Elements().ChildNamespace("set", rsmNamespace)
+	require.Equal(t, ids[0], set.Elements().Child("first").Text())
+	require.Equal(t, ids[1], set.Elements().Child("last").Text())
+	require.Equal(t, "3", set.Elements().Child("count").Text())
+
+	// second page
+	x.ProcessIQ(context.Background(), testQueryIQ(j1, "q2", "romeo@jackal.im", ids[1], "2"))
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, ids[2], elem.Elements().ChildNamespace("result", mamNamespace).Attributes().Get("id"))
+
+	elem = stm.ReceiveElement()
+	fin = elem.Elements().ChildNamespace("fin", mamNamespace)
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+
+	// outgoing messages are archived as well
+	x.ProcessIQ(context.Background(), testQueryIQ(j1, "q3", "juliet@jackal.im", "", ""))
+
+	elem = stm.ReceiveElement()
+	result := elem.Elements().ChildNamespace("result", mamNamespace)
+	require.NotNil(t, result)
+	forwarded := result.Elements().ChildNamespace("forwarded", forwardNamespace)
+	require.Equal(t, "hello", forwarded.Elements().Child("message").Elements().Child("body").Text())
+
+	elem = stm.ReceiveElement()
+	require.Equal(t, "true", elem.Elements().ChildNamespace("fin", mamNamespace).Attributes().Get("complete"))
+
+	// unknown paging reference
+	x.ProcessIQ(context.Background(), testQueryIQ(j1, "q4", "", "unknown", ""))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// forbidden target
+	iq := testQueryIQ(j1, "q5", "", "", "")
+	iq.SetToJID(j2.ToBareJID())
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0313_QueryForm(t *testing.T) {
+	r, reps := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{}, nil, r, reps.user, reps.roster, reps.archive)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	formEl := elem.Elements().ChildNamespace("query", mamNamespace).Elements().ChildNamespace("x", xep0004.FormNamespace)
+	require.NotNil(t, formEl)
+
+	form, err := xep0004.NewFormFromElement(formEl)
+	require.Nil(t, err)
+	require.Equal(t, xep0004.Form, form.Type)
+	require.Equal(t, 4, len(form.Fields))
+}
+
+func TestXEP0313_PurgeMessages(t *testing.T) {
+	r, reps := setupTest("jackal.im")
+
+	x := New(&Config{}, nil, r, reps.user, reps.roster, reps.archive)
+	defer func() { _ = x.Shutdown() }()
+
+	x.cfg.Retention = time.Hour
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	_ = reps.archive.InsertArchiveMessage(context.Background(), &archivemodel.Message{
+		ID:       uuid.New(),
+		Username: "sxmpp",
+		Domain:   "jackal.im",
+		With:     j2.String(),
+		Message:  testChatMessage(j2, j1, "old"),
+		Stamp:    time.Now().Add(-2 * time.Hour),
+	})
+	_ = tUtilArchiveIncomingMessage(x, testChatMessage(j2, j1, "new"))
+
+	x.purgeMessages(context.Background())
+
+	count, _ := reps.archive.CountArchiveMessages(context.Background(), &archivemodel.Query{}, "sxmpp", "jackal.im")
+	require.Equal(t, 1, count)
+}
+
+func testChatMessage(from, to *jid.JID, body string) *xmpp.Message {
+	b := xmpp.NewElementName("body")
+	b.SetText(body)
+
+	msg := xmpp.NewElementName("message")
+	msg.SetID(uuid.New())
+	msg.SetType(xmpp.ChatType)
+	msg.AppendElement(b)
+
+	m, _ := xmpp.NewMessageFromElement(msg, from, to)
+	return m
+}
+
+func testQueryIQ(from *jid.JID, queryID, with, after, max string) *xmpp.IQ {
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.SetAttribute("queryid", queryID)
+
+	if len(with) > 0 {
+		form := xep0004.DataForm{
+			Type: xep0004.Submit,
+			Fields: xep0004.Fields{
+				{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+				{Var: "with", Values: []string{with}},
+			},
+		}
+		query.AppendElement(form.Element())
+	}
+	if len(after) > 0 || len(max) > 0 {
+		set := xmpp.NewElementNamespace("set", rsmNamespace)
+		if len(max) > 0 {
+			maxEl := xmpp.NewElementName("max")
+			maxEl.SetText(max)
+			set.AppendElement(maxEl)
+		}
+		if len(after) > 0 {
+			afterEl := xmpp.NewElementName("after")
+			afterEl.SetText(after)
+			set.AppendElement(afterEl)
+		}
+		query.AppendElement(set)
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(from.ToBareJID())
+	iq.AppendElement(query)
+	return iq
+}
+
+func setupTest(domain string) (router.Router, *testRepos) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	reps := &testRepos{
+		user:    memorystorage.NewUser(),
+		roster:  memorystorage.NewRoster(),
+		archive: memorystorage.NewArchive(),
+	}
+	_ = reps.user.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: domain, Password: "1234"})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(reps.user, memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, reps
+}
+
+func tUtilArchiveIncomingMessage(x *MAM, message *xmpp.Message) *xmpp.Message {
+	delivered := make(chan *xmpp.Message, 1)
+	x.ArchiveIncomingMessage(context.Background(), message, func(_ context.Context, msg *xmpp.Message) {
+		delivered <- msg
+	})
+	return <-delivered
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import "fmt"
+
+const (
+	// NonePayload means notifications only carry the pending message count.
+	NonePayload = "none"
+
+	// SenderPayload means notifications carry the last message sender.
+	SenderPayload = "sender"
+
+	// FullPayload means notifications carry both the last message sender and body.
+	FullPayload = "full"
+)
+
+// Config represents Push Notifications module (XEP-0357) configuration.
+type Config struct {
+	// Payload defines the redaction level applied to notifications published to app servers.
+	Payload string
+}
+
+type configProxy struct {
+	Payload string `yaml:"payload"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	cfg.Payload = p.Payload
+	if len(cfg.Payload) == 0 {
+		cfg.Payload = NonePayload
+	}
+	switch cfg.Payload {
+	case NonePayload, SenderPayload, FullPayload:
+		break
+	default:
+		return fmt.Errorf("xep0357.Config: unrecognized payload: %s", cfg.Payload)
+	}
+	return nil
+}
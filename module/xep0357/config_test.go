@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPushConfig(t *testing.T) {
+	cfg := &Config{}
+	err := yaml.Unmarshal([]byte(`payload: everything`), &cfg)
+	require.NotNil(t, err)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`payload: ""`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, NonePayload, cfg.Payload)
+
+	cfg = &Config{}
+	err = yaml.Unmarshal([]byte(`payload: full`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, FullPayload, cfg.Payload)
+}
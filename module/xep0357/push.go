@@ -0,0 +1,349 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/module/xep0030"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/util/runqueue"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	pushNamespace          = "urn:xmpp:push:0"
+	pushSummaryFormType    = "urn:xmpp:push:summary"
+	pubSubNamespace        = "http://jabber.org/protocol/pubsub"
+	publishOptionsFormType = "http://jabber.org/protocol/pubsub#publish-options"
+
+	// publishReplyTimeout is the time given to an app server to reply a published notification.
+	publishReplyTimeout = time.Minute
+)
+
+var errInvalidPublishOptions = errors.New("xep0357: invalid publish options")
+
+// Push represents a push notifications server stream module.
+type Push struct {
+	cfg      Config
+	router   router.Router
+	runQueue *runqueue.RunQueue
+	pushRep  repository.Push
+
+	// notifications published and not yet replied, keyed by IQ identifier.
+	pendingMu sync.Mutex
+	pending   map[string]pushmodel.Registration
+}
+
+// New returns a push notifications IQ handler module.
+func New(config *Config, disco *xep0030.DiscoInfo, router router.Router, pushRep repository.Push) *Push {
+	x := &Push{
+		cfg:      *config,
+		router:   router,
+		runQueue: runqueue.New("xep0357"),
+		pushRep:  pushRep,
+		pending:  make(map[string]pushmodel.Registration),
+	}
+	if len(x.cfg.Payload) == 0 {
+		x.cfg.Payload = NonePayload
+	}
+	if disco != nil {
+		disco.RegisterAccountFeature(pushNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be processed by the push notifications module.
+func (x *Push) MatchesIQ(iq *xmpp.IQ) bool {
+	if iq.IsResult() || iq.IsError() {
+		return x.isPublishReply(iq)
+	}
+	e := iq.Elements()
+	return e.ChildNamespace("enable", pushNamespace) != nil || e.ChildNamespace("disable", pushNamespace) != nil
+}
+
+// ProcessIQ processes a push notifications IQ taking according actions over the associated stream.
+func (x *Push) ProcessIQ(ctx context.Context, iq *xmpp.IQ) {
+	if iq.IsResult() || iq.IsError() {
+		x.runQueue.Run(func() {
+			x.processPublishReply(ctx, iq)
+		})
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	_ = x.runQueue.TryRunWithDeadline(deadline, func() {
+		x.processIQ(ctx, iq)
+	}, func() {
+		_ = x.router.Route(context.Background(), iq.ResourceConstraintError())
+	})
+}
+
+// NotifyMessage publishes a summary notification to every app server registered by the
+// message recipient, where count is the number of messages pending to be delivered.
+func (x *Push) NotifyMessage(ctx context.Context, message *xmpp.Message, count int) {
+	x.runQueue.Run(func() {
+		x.notifyMessage(ctx, message, count)
+	})
+}
+
+// NotifyDetach publishes a content-free notification to every app server registered by a user
+// once its session has been closed, unless another session remains bound to this node.
+// Messages sent to the user from then on are notified as they get stored offline.
+func (x *Push) NotifyDetach(ctx context.Context, userJID *jid.JID) {
+	x.runQueue.Run(func() {
+		x.notifyDetach(ctx, userJID)
+	})
+}
+
+// Shutdown shuts down push notifications module.
+func (x *Push) Shutdown() error {
+	c := make(chan struct{})
+	x.runQueue.Stop(func() { close(c) })
+	<-c
+	return nil
+}
+
+func (x *Push) processIQ(ctx context.Context, iq *xmpp.IQ) {
+	fromJID := iq.FromJID()
+	toJID := iq.ToJID()
+	validTo := toJID.IsServer() || (toJID.Node() == fromJID.Node() && toJID.Domain() == fromJID.Domain())
+	if !validTo {
+		_ = x.router.Route(ctx, iq.ForbiddenError())
+		return
+	}
+	if !iq.IsSet() {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	if enable := iq.Elements().ChildNamespace("enable", pushNamespace); enable != nil {
+		x.enable(ctx, iq, enable)
+	} else {
+		x.disable(ctx, iq, iq.Elements().ChildNamespace("disable", pushNamespace))
+	}
+}
+
+func (x *Push) enable(ctx context.Context, iq *xmpp.IQ, enable xmpp.XElement) {
+	appServerJID, err := jid.NewWithString(enable.Attributes().Get("jid"), false)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.JidMalformedError())
+		return
+	}
+	node := enable.Attributes().Get("node")
+	if len(node) == 0 {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	options, err := parsePublishOptions(enable)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.BadRequestError())
+		return
+	}
+	fromJID := iq.FromJID()
+	reg := &pushmodel.Registration{
+		Username: fromJID.Node(),
+		Domain:   fromJID.Domain(),
+		JID:      appServerJID.String(),
+		Node:     node,
+		Options:  options,
+	}
+	if err := x.pushRep.UpsertPushRegistration(ctx, reg); err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("push notifications enabled... (%s/%s) app server: %s", fromJID.Node(), fromJID.Resource(), reg.JID)
+
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *Push) disable(ctx context.Context, iq *xmpp.IQ, disable xmpp.XElement) {
+	appServerJID, err := jid.NewWithString(disable.Attributes().Get("jid"), false)
+	if err != nil {
+		_ = x.router.Route(ctx, iq.JidMalformedError())
+		return
+	}
+	fromJID := iq.FromJID()
+	err = x.pushRep.DeletePushRegistrations(ctx, fromJID.Node(), fromJID.Domain(), appServerJID.String(), disable.Attributes().Get("node"))
+	if err != nil {
+		log.Error(err)
+		_ = x.router.Route(ctx, iq.InternalServerError())
+		return
+	}
+	log.Infof("push notifications disabled... (%s/%s) app server: %s", fromJID.Node(), fromJID.Resource(), appServerJID)
+
+	_ = x.router.Route(ctx, iq.ResultIQ())
+}
+
+func (x *Push) notifyMessage(ctx context.Context, message *xmpp.Message, count int) {
+	fields := xep0004.Fields{
+		{Var: "message-count", Values: []string{strconv.Itoa(count)}},
+	}
+	if x.cfg.Payload == SenderPayload || x.cfg.Payload == FullPayload {
+		fields = append(fields, xep0004.Field{Var: "last-message-sender", Values: []string{message.FromJID().String()}})
+	}
+	if body := message.Elements().Child("body"); body != nil && x.cfg.Payload == FullPayload {
+		fields = append(fields, xep0004.Field{Var: "last-message-body", Values: []string{body.Text()}})
+	}
+	x.publish(ctx, message.ToJID().ToBareJID(), fields)
+}
+
+func (x *Push) notifyDetach(ctx context.Context, userJID *jid.JID) {
+	if len(x.router.LocalStreams(userJID.Node(), userJID.Domain())) > 0 {
+		return // user is still connected
+	}
+	x.publish(ctx, userJID.ToBareJID(), nil)
+}
+
+func (x *Push) publish(ctx context.Context, userJID *jid.JID, fields xep0004.Fields) {
+	regs, err := x.pushRep.FetchPushRegistrations(ctx, userJID.Node(), userJID.Domain())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for i := range regs {
+		appServerJID, err := jid.NewWithString(regs[i].JID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+		iq.SetFromJID(userJID)
+		iq.SetToJID(appServerJID)
+		iq.AppendElement(x.publishElement(&regs[i], fields))
+
+		x.trackPublish(iq.ID(), regs[i])
+		if err := x.router.Route(ctx, iq); err != nil {
+			x.untrackPublish(iq.ID())
+			log.Errorf("xep0357: failed to publish notification to %s: %v", appServerJID, err)
+			continue
+		}
+		log.Infof("published push notification... (%s) app server: %s", userJID, appServerJID)
+	}
+}
+
+// processPublishReply removes a registration whenever its app server rejects a published notification,
+// as it's no longer willing to deliver them. Temporary errors are ignored.
+func (x *Push) processPublishReply(ctx context.Context, iq *xmpp.IQ) {
+	reg, ok := x.untrackPublish(iq.ID())
+	if !ok || !iq.IsError() {
+		return
+	}
+	if errEl := iq.Error(); errEl != nil && errEl.Type() == "wait" {
+		log.Warnf("xep0357: app server %s temporarily failed to process notification", reg.JID)
+		return
+	}
+	if err := x.pushRep.DeletePushRegistrations(ctx, reg.Username, reg.Domain, reg.JID, reg.Node); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("push notifications disabled by app server error... (%s@%s) app server: %s", reg.Username, reg.Domain, reg.JID)
+}
+
+func (x *Push) isPublishReply(iq *xmpp.IQ) bool {
+	x.pendingMu.Lock()
+	reg, ok := x.pending[iq.ID()]
+	x.pendingMu.Unlock()
+
+	return ok && iq.FromJID().String() == reg.JID
+}
+
+func (x *Push) trackPublish(id string, reg pushmodel.Registration) {
+	x.pendingMu.Lock()
+	x.pending[id] = reg
+	x.pendingMu.Unlock()
+
+	time.AfterFunc(publishReplyTimeout, func() { x.untrackPublish(id) })
+}
+
+func (x *Push) untrackPublish(id string) (pushmodel.Registration, bool) {
+	x.pendingMu.Lock()
+	defer x.pendingMu.Unlock()
+
+	reg, ok := x.pending[id]
+	delete(x.pending, id)
+	return reg, ok
+}
+
+func (x *Push) publishElement(reg *pushmodel.Registration, fields xep0004.Fields) xmpp.XElement {
+	fields = append(xep0004.Fields{
+		{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{pushSummaryFormType}},
+	}, fields...)
+	summary := xep0004.DataForm{Type: xep0004.Submit, Fields: fields}
+
+	notification := xmpp.NewElementNamespace("notification", pushNamespace)
+	notification.AppendElement(summary.Element())
+
+	item := xmpp.NewElementName("item")
+	item.AppendElement(notification)
+
+	publish := xmpp.NewElementName("publish")
+	publish.SetAttribute("node", reg.Node)
+	publish.AppendElement(item)
+
+	pubSub := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	pubSub.AppendElement(publish)
+
+	if len(reg.Options) > 0 {
+		optFields := xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{publishOptionsFormType}},
+		}
+		vars := make([]string, 0, len(reg.Options))
+		for k := range reg.Options {
+			vars = append(vars, k)
+		}
+		sort.Strings(vars)
+		for _, k := range vars {
+			optFields = append(optFields, xep0004.Field{Var: k, Values: []string{reg.Options[k]}})
+		}
+		options := xep0004.DataForm{Type: xep0004.Submit, Fields: optFields}
+
+		publishOptions := xmpp.NewElementName("publish-options")
+		publishOptions.AppendElement(options.Element())
+		pubSub.AppendElement(publishOptions)
+	}
+	return pubSub
+}
+
+func parsePublishOptions(enable xmpp.XElement) (map[string]string, error) {
+	formEl := enable.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if formEl == nil {
+		return nil, nil
+	}
+	form, err := xep0004.NewFormFromElement(formEl)
+	if err != nil {
+		return nil, err
+	}
+	if form.Type != xep0004.Submit {
+		return nil, errInvalidPublishOptions
+	}
+	var formType string
+	options := make(map[string]string)
+	for _, field := range form.Fields {
+		if len(field.Values) == 0 {
+			continue
+		}
+		if field.Var == xep0004.FormType {
+			formType = field.Values[0]
+			continue
+		}
+		options[field.Var] = field.Values[0]
+	}
+	if formType != publishOptionsFormType {
+		return nil, errInvalidPublishOptions
+	}
+	return options, nil
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	c2srouter "github.com/sxmpp/jackal/c2s/router"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/router"
+	"github.com/sxmpp/jackal/router/host"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/stream"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0357_Matching(t *testing.T) {
+	j, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+
+	x := New(&Config{}, nil, nil, nil)
+	defer func() { _ = x.Shutdown() }()
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("enable", pushNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.ClearElements()
+	iq.AppendElement(xmpp.NewElementNamespace("disable", pushNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0357_EnableDisable(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	stm.SetPresence(xmpp.NewPresence(j1, j1, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	// forbidden target
+	iq := enableIQ(j1, j2.ToBareJID(), "push.jackal.im", "node1")
+
+	x.ProcessIQ(context.Background(), iq)
+	elem := stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// missing node
+	iq = enableIQ(j1, j1.ToBareJID(), "push.jackal.im", "")
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid publish options
+	iq = enableIQ(j1, j1.ToBareJID(), "push.jackal.im", "node1")
+	form := xep0004.DataForm{Type: xep0004.Form}
+	iq.Elements().ChildNamespace("enable", pushNamespace).(*xmpp.Element).AppendElement(form.Element())
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// enable
+	iq = enableIQ(j1, j1.ToBareJID(), "push.jackal.im", "node1")
+	form = xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormType, Type: xep0004.Hidden, Values: []string{publishOptionsFormType}},
+			{Var: "secret", Values: []string{"eruio234vzxc2kla-91"}},
+		},
+	}
+	iq.Elements().ChildNamespace("enable", pushNamespace).(*xmpp.Element).AppendElement(form.Element())
+
+	x.ProcessIQ(context.Background(), iq)
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	x.ProcessIQ(context.Background(), enableIQ(j1, j1.ToBareJID(), "push.jackal.im", "node2"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	regs, _ := rep.FetchPushRegistrations(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, regs, 2)
+	require.Equal(t, "node1", regs[0].Node)
+	require.Equal(t, map[string]string{"secret": "eruio234vzxc2kla-91"}, regs[0].Options)
+
+	// disable single node
+	x.ProcessIQ(context.Background(), disableIQ(j1, j1.ToBareJID(), "push.jackal.im", "node1"))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	regs, _ = rep.FetchPushRegistrations(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, regs, 1)
+	require.Equal(t, "node2", regs[0].Node)
+
+	// disable all app server nodes
+	x.ProcessIQ(context.Background(), disableIQ(j1, j1.ToBareJID(), "push.jackal.im", ""))
+	elem = stm.ReceiveElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	regs, _ = rep.FetchPushRegistrations(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, regs, 0)
+}
+
+func TestXEP0357_NotifyMessage(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	appServerJID, _ := jid.New("app", "jackal.im", "push", true)
+
+	stm := stream.NewMockC2S(uuid.New(), appServerJID)
+	stm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{Payload: SenderPayload}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	x.ProcessIQ(context.Background(), enableIQ(j1, j1.ToBareJID(), appServerJID.String(), "node1"))
+
+	b := xmpp.NewElementName("body")
+	b.SetText("hi!")
+	msg := xmpp.NewElementName("message")
+	msg.SetID(uuid.New())
+	msg.SetType(xmpp.ChatType)
+	msg.AppendElement(b)
+	message, _ := xmpp.NewMessageFromElement(msg, j2, j1.ToBareJID())
+
+	x.NotifyMessage(context.Background(), message, 3)
+
+	elem := stm.ReceiveElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xmpp.SetType, elem.Type())
+	require.Equal(t, "sxmpp@jackal.im", elem.From())
+
+	publish := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish")
+	require.NotNil(t, publish)
+	require.Equal(t, "node1", publish.Attributes().Get("node"))
+
+	notification := publish.Elements().Child("item").Elements().ChildNamespace("notification", pushNamespace)
+	require.NotNil(t, notification)
+
+	form, err := xep0004.NewFormFromElement(notification.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, pushSummaryFormType, form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden))
+	require.Equal(t, "3", form.Fields.ValueForField("message-count"))
+	require.Equal(t, j2.String(), form.Fields.ValueForField("last-message-sender"))
+	require.Equal(t, "", form.Fields.ValueForField("last-message-body"))
+}
+
+func TestXEP0357_NotifyDetach(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	appServerJID, _ := jid.New("app", "jackal.im", "push", true)
+
+	appStm := stream.NewMockC2S(uuid.New(), appServerJID)
+	appStm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+	r.Bind(context.Background(), appStm)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	r.Bind(context.Background(), stm)
+
+	x := New(&Config{}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	require.Nil(t, rep.UpsertPushRegistration(context.Background(), &pushmodel.Registration{
+		Username: "sxmpp", Domain: "jackal.im", JID: appServerJID.String(), Node: "node1",
+	}))
+
+	// user still connected
+	x.NotifyDetach(context.Background(), j1)
+
+	message, _ := xmpp.NewMessageFromElement(xmpp.NewMessageType(uuid.New(), xmpp.ChatType), j2, j1.ToBareJID())
+	x.NotifyMessage(context.Background(), message, 1)
+	tUtilSync(x)
+
+	r.Unbind(context.Background(), j1)
+	x.NotifyDetach(context.Background(), j1)
+
+	form := tUtilNotificationForm(t, appStm.ReceiveElement())
+	require.Equal(t, "1", form.Fields.ValueForField("message-count"))
+
+	form = tUtilNotificationForm(t, appStm.ReceiveElement())
+	require.Equal(t, pushSummaryFormType, form.Fields.ValueForFieldOfType(xep0004.FormType, xep0004.Hidden))
+	require.Len(t, form.Fields, 1)
+}
+
+func TestXEP0357_PublishErrorReply(t *testing.T) {
+	r, rep := setupTest("jackal.im")
+
+	j1, _ := jid.New("sxmpp", "jackal.im", "balcony", true)
+	j2, _ := jid.New("romeo", "jackal.im", "garden", true)
+	appServerJID, _ := jid.New("app", "jackal.im", "push", true)
+
+	appStm := stream.NewMockC2S(uuid.New(), appServerJID)
+	appStm.SetPresence(xmpp.NewPresence(appServerJID, appServerJID, xmpp.AvailableType))
+	r.Bind(context.Background(), appStm)
+
+	x := New(&Config{}, nil, r, rep)
+	defer func() { _ = x.Shutdown() }()
+
+	require.Nil(t, rep.UpsertPushRegistration(context.Background(), &pushmodel.Registration{
+		Username: "sxmpp", Domain: "jackal.im", JID: appServerJID.String(), Node: "node1",
+	}))
+	message, _ := xmpp.NewMessageFromElement(xmpp.NewMessageType(uuid.New(), xmpp.ChatType), j2, j1.ToBareJID())
+
+	// temporary errors are ignored
+	x.NotifyMessage(context.Background(), message, 1)
+	publishIQ := tUtilIQ(t, appStm.ReceiveElement())
+
+	reply := tUtilIQ(t, publishIQ.ResourceConstraintError())
+	require.True(t, x.MatchesIQ(reply))
+	x.ProcessIQ(context.Background(), reply)
+
+	tUtilSync(x)
+	require.False(t, x.MatchesIQ(reply))
+	regs, _ := rep.FetchPushRegistrations(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, regs, 1)
+
+	// replies not coming from app server are not matched
+	x.NotifyMessage(context.Background(), message, 2)
+	publishIQ = tUtilIQ(t, appStm.ReceiveElement())
+
+	reply = tUtilIQ(t, publishIQ.ItemNotFoundError())
+	reply.SetFromJID(j2)
+	require.False(t, x.MatchesIQ(reply))
+
+	// registration rejected by app server
+	reply.SetFromJID(appServerJID)
+	require.True(t, x.MatchesIQ(reply))
+	x.ProcessIQ(context.Background(), reply)
+
+	tUtilSync(x)
+	regs, _ = rep.FetchPushRegistrations(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, regs, 0)
+}
+
+func tUtilNotificationForm(t *testing.T, elem xmpp.XElement) *xep0004.DataForm {
+	require.Equal(t, "iq", elem.Name())
+	publish := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish")
+	notification := publish.Elements().Child("item").Elements().ChildNamespace("notification", pushNamespace)
+	form, err := xep0004.NewFormFromElement(notification.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	return form
+}
+
+func tUtilIQ(t *testing.T, elem xmpp.XElement) *xmpp.IQ {
+	fromJID, _ := jid.NewWithString(elem.From(), true)
+	toJID, _ := jid.NewWithString(elem.To(), true)
+	iq, err := xmpp.NewIQFromElement(elem, fromJID, toJID)
+	require.Nil(t, err)
+	return iq
+}
+
+// tUtilSync waits until every task previously enqueued into module run queue has been processed.
+func tUtilSync(x *Push) {
+	c := make(chan struct{})
+	x.runQueue.Run(func() { close(c) })
+	<-c
+}
+
+func enableIQ(from, to *jid.JID, appServer, node string) *xmpp.IQ {
+	enable := xmpp.NewElementNamespace("enable", pushNamespace)
+	enable.SetAttribute("jid", appServer)
+	if len(node) > 0 {
+		enable.SetAttribute("node", node)
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(enable)
+	return iq
+}
+
+func disableIQ(from, to *jid.JID, appServer, node string) *xmpp.IQ {
+	disable := xmpp.NewElementNamespace("disable", pushNamespace)
+	disable.SetAttribute("jid", appServer)
+	if len(node) > 0 {
+		disable.SetAttribute("node", node)
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(disable)
+	return iq
+}
+
+func setupTest(domain string) (router.Router, repository.Push) {
+	hosts, _ := host.New([]host.Config{{Name: domain, Certificate: tls.Certificate{}}})
+
+	r, _ := router.New(
+		hosts,
+		c2srouter.New(memorystorage.NewUser(), memorystorage.NewBlockList()),
+		nil,
+	)
+	return r, memorystorage.NewPush()
+}
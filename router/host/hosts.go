@@ -6,29 +6,61 @@
 package host
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"sort"
+	"sync"
 
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/storage/repository"
 	utiltls "github.com/sxmpp/jackal/util/tls"
 )
 
 const defaultDomain = "localhost"
 
+var (
+	// ErrHostNotFound will be returned by admin operations if referenced host is not registered.
+	ErrHostNotFound = errors.New("host: host not found")
+
+	// ErrStaticHost will be returned by admin operations when trying to modify a host defined in configuration file.
+	ErrStaticHost = errors.New("host: host is statically configured")
+)
+
+// UnregisterHandler is invoked whenever a host stops being served, either because it has been disabled or removed.
+type UnregisterHandler func(ctx context.Context, name string)
+
+type hostEntry struct {
+	certificate tls.Certificate
+	enabled     bool
+	static      bool
+}
+
+// Hosts represents the local virtual hosts registry.
+//
+// Hosts defined in configuration file are static and cannot be modified at runtime,
+// while the ones loaded from storage can be added, disabled or removed by means of admin operations.
 type Hosts struct {
-	defaultHostname string
-	hosts           map[string]tls.Certificate
+	mu                 sync.RWMutex
+	defaultHostname    string
+	hosts              map[string]*hostEntry
+	hostRep            repository.Host
+	unregisterHandlers []UnregisterHandler
 }
 
+// New returns a hosts registry initialized with a set of static hosts.
 func New(hostsConfig []Config) (*Hosts, error) {
 	h := &Hosts{
-		hosts: make(map[string]tls.Certificate),
+		hosts: make(map[string]*hostEntry),
 	}
 	if len(hostsConfig) > 0 {
 		for i, host := range hostsConfig {
 			if i == 0 {
 				h.defaultHostname = host.Name
 			}
-			h.hosts[host.Name] = host.Certificate
+			h.hosts[host.Name] = &hostEntry{certificate: host.Certificate, enabled: true, static: true}
 		}
 	} else {
 		cer, err := utiltls.LoadCertificate("", "", defaultDomain)
@@ -36,33 +68,221 @@ func New(hostsConfig []Config) (*Hosts, error) {
 			return nil, err
 		}
 		h.defaultHostname = defaultDomain
-		h.hosts[defaultDomain] = cer
+		h.hosts[defaultDomain] = &hostEntry{certificate: cer, enabled: true, static: true}
 	}
 	return h, nil
 }
 
+// Load registers every host contained in storage.
+// Subsequent admin operations will be persisted using the same repository.
+func (h *Hosts) Load(ctx context.Context, hostRep repository.Host) error {
+	hosts, err := hostRep.FetchHosts(ctx)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hostRep = hostRep
+	for _, host := range hosts {
+		if e := h.hosts[host.Name]; e != nil && e.static {
+			log.Warnf("host: ignoring stored host %s: statically configured", host.Name)
+			continue
+		}
+		cer, err := tls.X509KeyPair(host.Certificate, host.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("host: %s: %v", host.Name, err)
+		}
+		h.hosts[host.Name] = &hostEntry{certificate: cer, enabled: host.Enabled}
+	}
+	return nil
+}
+
+// OnUnregister registers a handler to be invoked whenever a host stops being served.
+func (h *Hosts) OnUnregister(handler UnregisterHandler) {
+	h.mu.Lock()
+	h.unregisterHandlers = append(h.unregisterHandlers, handler)
+	h.mu.Unlock()
+}
+
+// AddHost registers a new enabled host, or replaces certificate of a previously added one.
+func (h *Hosts) AddHost(ctx context.Context, name string, certPEM, keyPEM []byte) error {
+	if len(name) == 0 {
+		return errors.New("host: empty host name")
+	}
+	cer, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e := h.hosts[name]; e != nil && e.static {
+		return ErrStaticHost
+	}
+	if h.hostRep != nil {
+		host := &model.Host{Name: name, Certificate: certPEM, PrivateKey: keyPEM, Enabled: true}
+		if err := h.hostRep.UpsertHost(ctx, host); err != nil {
+			return err
+		}
+	}
+	h.hosts[name] = &hostEntry{certificate: cer, enabled: true}
+	return nil
+}
+
+// EnableHost enables a previously disabled host.
+func (h *Hosts) EnableHost(ctx context.Context, name string) error {
+	return h.setEnabled(ctx, name, true)
+}
+
+// DisableHost stops serving a host without removing it from storage.
+func (h *Hosts) DisableHost(ctx context.Context, name string) error {
+	return h.setEnabled(ctx, name, false)
+}
+
+// RemoveHost removes a host from both registry and storage.
+func (h *Hosts) RemoveHost(ctx context.Context, name string) error {
+	h.mu.Lock()
+	e := h.hosts[name]
+	switch {
+	case e == nil:
+		h.mu.Unlock()
+		return ErrHostNotFound
+	case e.static:
+		h.mu.Unlock()
+		return ErrStaticHost
+	}
+	if h.hostRep != nil {
+		if err := h.hostRep.DeleteHost(ctx, name); err != nil {
+			h.mu.Unlock()
+			return err
+		}
+	}
+	delete(h.hosts, name)
+	handlers := h.unregisterHandlers
+	h.mu.Unlock()
+
+	if e.enabled {
+		runUnregisterHandlers(ctx, handlers, name)
+	}
+	return nil
+}
+
+// DefaultHostName returns default host name.
 func (h *Hosts) DefaultHostName() string {
 	return h.defaultHostname
 }
 
+// IsLocalHost tells whether or not a domain is being served by this host.
 func (h *Hosts) IsLocalHost(domain string) bool {
-	_, ok := h.hosts[domain]
-	return ok
+	h.mu.RLock()
+	e := h.hosts[domain]
+	h.mu.RUnlock()
+	return e != nil && e.enabled
 }
 
+// IsDisabledHost tells whether or not a domain is registered but currently disabled.
+func (h *Hosts) IsDisabledHost(domain string) bool {
+	h.mu.RLock()
+	e := h.hosts[domain]
+	h.mu.RUnlock()
+	return e != nil && !e.enabled
+}
+
+// HostNames returns all served host names.
 func (h *Hosts) HostNames() []string {
+	h.mu.RLock()
 	var ret []string
-	for n := range h.hosts {
-		ret = append(ret, n)
+	for n, e := range h.hosts {
+		if e.enabled {
+			ret = append(ret, n)
+		}
 	}
+	h.mu.RUnlock()
+
 	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
 	return ret
 }
 
+// Certificates returns all served host certificates.
 func (h *Hosts) Certificates() []tls.Certificate {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	var certs []tls.Certificate
-	for _, cer := range h.hosts {
-		certs = append(certs, cer)
+	for _, e := range h.hosts {
+		if e.enabled {
+			certs = append(certs, e.certificate)
+		}
 	}
 	return certs
 }
+
+// Certificate returns the certificate associated to a served host.
+func (h *Hosts) Certificate(name string) (tls.Certificate, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	e := h.hosts[name]
+	if e == nil || !e.enabled {
+		return tls.Certificate{}, false
+	}
+	return e.certificate, true
+}
+
+// GetCertificate returns the certificate matching client requested server name,
+// falling back to default host one.
+// Its signature satisfies tls.Config GetCertificate field.
+func (h *Hosts) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cer, ok := h.Certificate(hello.ServerName); ok {
+		return &cer, nil
+	}
+	if cer, ok := h.Certificate(h.defaultHostname); ok {
+		return &cer, nil
+	}
+	return nil, fmt.Errorf("host: no certificate found for %s", hello.ServerName)
+}
+
+func (h *Hosts) setEnabled(ctx context.Context, name string, enabled bool) error {
+	h.mu.Lock()
+	e := h.hosts[name]
+	switch {
+	case e == nil:
+		h.mu.Unlock()
+		return ErrHostNotFound
+	case e.static:
+		h.mu.Unlock()
+		return ErrStaticHost
+	case e.enabled == enabled:
+		h.mu.Unlock()
+		return nil
+	}
+	if h.hostRep != nil {
+		host, err := h.hostRep.FetchHost(ctx, name)
+		if err != nil {
+			h.mu.Unlock()
+			return err
+		}
+		if host != nil {
+			host.Enabled = enabled
+			if err := h.hostRep.UpsertHost(ctx, host); err != nil {
+				h.mu.Unlock()
+				return err
+			}
+		}
+	}
+	e.enabled = enabled
+	handlers := h.unregisterHandlers
+	h.mu.Unlock()
+
+	if !enabled {
+		runUnregisterHandlers(ctx, handlers, name)
+	}
+	return nil
+}
+
+func runUnregisterHandlers(ctx context.Context, handlers []UnregisterHandler, name string) {
+	for _, handler := range handlers {
+		handler(ctx, name)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package host
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sxmpp/jackal/model"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHosts_Load(t *testing.T) {
+	certPEM, keyPEM := tUtilGenerateCertificate(t, "example.org")
+
+	rep := memorystorage.NewHost()
+	_ = rep.UpsertHost(context.Background(), &model.Host{Name: "example.org", Certificate: certPEM, PrivateKey: keyPEM, Enabled: true})
+	_ = rep.UpsertHost(context.Background(), &model.Host{Name: "example.net", Certificate: certPEM, PrivateKey: keyPEM, Enabled: false})
+
+	h, _ := New([]Config{{Name: "jackal.im"}})
+	require.Nil(t, h.Load(context.Background(), rep))
+
+	require.Equal(t, "jackal.im", h.DefaultHostName())
+	require.True(t, h.IsLocalHost("jackal.im"))
+	require.True(t, h.IsLocalHost("example.org"))
+	require.False(t, h.IsLocalHost("example.net"))
+	require.True(t, h.IsDisabledHost("example.net"))
+	require.Equal(t, []string{"example.org", "jackal.im"}, h.HostNames())
+}
+
+func TestHosts_AdminOperations(t *testing.T) {
+	certPEM, keyPEM := tUtilGenerateCertificate(t, "example.org")
+
+	rep := memorystorage.NewHost()
+
+	h, _ := New([]Config{{Name: "jackal.im"}})
+	require.Nil(t, h.Load(context.Background(), rep))
+
+	var unregistered []string
+	h.OnUnregister(func(_ context.Context, name string) {
+		unregistered = append(unregistered, name)
+	})
+
+	// static hosts can't be modified
+	require.Equal(t, ErrStaticHost, h.AddHost(context.Background(), "jackal.im", certPEM, keyPEM))
+	require.Equal(t, ErrStaticHost, h.RemoveHost(context.Background(), "jackal.im"))
+	require.Equal(t, ErrHostNotFound, h.DisableHost(context.Background(), "example.org"))
+
+	// add
+	require.NotNil(t, h.AddHost(context.Background(), "example.org", []byte("foo"), keyPEM))
+	require.Nil(t, h.AddHost(context.Background(), "example.org", certPEM, keyPEM))
+	require.True(t, h.IsLocalHost("example.org"))
+
+	stored, _ := rep.FetchHost(context.Background(), "example.org")
+	require.NotNil(t, stored)
+	require.True(t, stored.Enabled)
+
+	cer, err := h.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.Nil(t, err)
+	require.Equal(t, certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cer.Certificate[0]}))
+
+	// disable
+	require.Nil(t, h.DisableHost(context.Background(), "example.org"))
+	require.False(t, h.IsLocalHost("example.org"))
+	require.Equal(t, []string{"example.org"}, unregistered)
+
+	stored, _ = rep.FetchHost(context.Background(), "example.org")
+	require.False(t, stored.Enabled)
+
+	_, ok := h.Certificate("example.org")
+	require.False(t, ok)
+
+	// enable
+	require.Nil(t, h.EnableHost(context.Background(), "example.org"))
+	require.True(t, h.IsLocalHost("example.org"))
+
+	// remove
+	require.Nil(t, h.RemoveHost(context.Background(), "example.org"))
+	require.False(t, h.IsLocalHost("example.org"))
+	require.False(t, h.IsDisabledHost("example.org"))
+	require.Equal(t, []string{"example.org", "example.org"}, unregistered)
+
+	stored, _ = rep.FetchHost(context.Background(), "example.org")
+	require.Nil(t, stored)
+}
+
+func tUtilGenerateCertificate(t *testing.T, domain string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{domain}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{domain},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
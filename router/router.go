@@ -8,6 +8,8 @@ package router
 import (
 	"context"
 
+	streamerror "github.com/sxmpp/jackal/errors"
+	"github.com/sxmpp/jackal/log"
 	"github.com/sxmpp/jackal/router/host"
 	"github.com/sxmpp/jackal/stream"
 	"github.com/sxmpp/jackal/xmpp"
@@ -32,11 +34,17 @@ type Router interface {
 	// Unbind unbinds a previously bound c2s stream.
 	Unbind(ctx context.Context, j *jid.JID)
 
-	// LocalStream returns the stream associated to a given username and resource.
-	LocalStream(username, resource string) stream.C2S
+	// LocalStream returns the stream associated to a given username, domain and resource.
+	LocalStream(username, domain, resource string) stream.C2S
 
-	// LocalStreams returns all streams associated to a given username.
-	LocalStreams(username string) []stream.C2S
+	// LocalStreams returns all streams associated to a given username and domain.
+	LocalStreams(username, domain string) []stream.C2S
+
+	// SetComponentRouter sets the router in charge of delivering stanzas addressed to local components.
+	SetComponentRouter(compRouter ComponentRouter)
+
+	// IsComponentHost returns whether or not a domain is served by a local component.
+	IsComponentHost(domain string) bool
 }
 
 type C2SRouter interface {
@@ -48,13 +56,16 @@ type C2SRouter interface {
 	Bind(stm stream.C2S)
 
 	// Unbind unbinds a previously bound c2s stream.
-	Unbind(username, resource string)
+	Unbind(username, domain, resource string)
+
+	// Stream returns the stream associated to a given username, domain and resource.
+	Stream(username, domain, resource string) stream.C2S
 
-	// Stream returns the stream associated to a given username and resource.
-	Stream(username, resource string) stream.C2S
+	// Streams returns all streams associated to a given username and domain.
+	Streams(username, domain string) []stream.C2S
 
-	// Streams returns all streams associated to a given username.
-	Streams(username string) []stream.C2S
+	// DomainStreams returns all streams associated to a given domain.
+	DomainStreams(domain string) []stream.C2S
 }
 
 type S2SRouter interface {
@@ -63,10 +74,19 @@ type S2SRouter interface {
 	Route(ctx context.Context, stanza xmpp.Stanza, localDomain string) error
 }
 
+type ComponentRouter interface {
+	// Route routes a stanza to the component serving its destination domain.
+	Route(ctx context.Context, stanza xmpp.Stanza) error
+
+	// IsComponentHost returns whether or not a domain is served by a local component.
+	IsComponentHost(domain string) bool
+}
+
 type router struct {
 	hosts *host.Hosts
 	c2s   C2SRouter
 	s2s   S2SRouter
+	comps ComponentRouter
 }
 
 func New(hosts *host.Hosts, c2sRouter C2SRouter, s2sRouter S2SRouter) (Router, error) {
@@ -75,6 +95,9 @@ func New(hosts *host.Hosts, c2sRouter C2SRouter, s2sRouter S2SRouter) (Router, e
 		c2s:   c2sRouter,
 		s2s:   s2sRouter,
 	}
+	if hosts != nil {
+		hosts.OnUnregister(r.unregisterHost)
+	}
 	return r, nil
 }
 
@@ -95,24 +118,48 @@ func (r *router) Bind(ctx context.Context, stm stream.C2S) {
 }
 
 func (r *router) Unbind(ctx context.Context, j *jid.JID) {
-	r.c2s.Unbind(j.Node(), j.Resource())
+	r.c2s.Unbind(j.Node(), j.Domain(), j.Resource())
 }
 
-func (r *router) LocalStreams(username string) []stream.C2S {
-	return r.c2s.Streams(username)
+func (r *router) LocalStreams(username, domain string) []stream.C2S {
+	return r.c2s.Streams(username, domain)
 }
 
-func (r *router) LocalStream(username, resource string) stream.C2S {
-	return r.c2s.Stream(username, resource)
+func (r *router) LocalStream(username, domain, resource string) stream.C2S {
+	return r.c2s.Stream(username, domain, resource)
+}
+
+func (r *router) SetComponentRouter(compRouter ComponentRouter) {
+	r.comps = compRouter
+}
+
+func (r *router) IsComponentHost(domain string) bool {
+	return r.comps != nil && r.comps.IsComponentHost(domain)
 }
 
 func (r *router) route(ctx context.Context, stanza xmpp.Stanza, validateStanza bool) error {
 	toJID := stanza.ToJID()
+	if r.IsComponentHost(toJID.Domain()) {
+		return r.comps.Route(ctx, stanza)
+	}
 	if !r.hosts.IsLocalHost(toJID.Domain()) {
-		if r.s2s == nil {
+		if r.s2s == nil || r.hosts.IsDisabledHost(toJID.Domain()) {
 			return ErrFailedRemoteConnect
 		}
-		return r.s2s.Route(ctx, stanza, r.hosts.DefaultHostName())
+		// stanzas sent by a component are delivered over a stream originated from its own domain
+		localDomain := r.hosts.DefaultHostName()
+		if fromDomain := stanza.FromJID().Domain(); r.IsComponentHost(fromDomain) {
+			localDomain = fromDomain
+		}
+		return r.s2s.Route(ctx, stanza, localDomain)
 	}
 	return r.c2s.Route(ctx, stanza, validateStanza)
 }
+
+func (r *router) unregisterHost(ctx context.Context, domain string) {
+	stms := r.c2s.DomainStreams(domain)
+	for _, stm := range stms {
+		stm.Disconnect(ctx, streamerror.ErrHostGone)
+	}
+	log.Infof("host %s unregistered... %d stream(s) disconnected", domain, len(stms))
+}
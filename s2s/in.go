@@ -131,6 +131,9 @@ func (s *inStream) handleConnecting(ctx context.Context, elem xmpp.XElement) {
 	}
 	// assign domain pair
 	s.localDomain = s.router.Hosts().DefaultHostName()
+	if s.router.IsComponentHost(elem.To()) {
+		s.localDomain = elem.To()
+	}
 	s.remoteDomain = elem.From()
 
 	// open stream session
@@ -209,7 +212,7 @@ func (s *inStream) processStanza(ctx context.Context, stanza xmpp.Stanza) {
 func (s *inStream) processPresence(ctx context.Context, presence *xmpp.Presence) {
 	// process roster presence
 	if presence.ToJID().IsBare() {
-		if r := s.mods.Roster; r != nil {
+		if r := s.mods.For(presence.ToJID().Domain()).Roster; r != nil {
 			r.ProcessPresence(ctx, presence)
 			return
 		}
@@ -239,19 +242,30 @@ func (s *inStream) processIQ(ctx context.Context, iq *xmpp.IQ) {
 }
 
 func (s *inStream) processMessage(ctx context.Context, message *xmpp.Message) {
+	if mam := s.mods.For(message.ToJID().Domain()).MAM; mam != nil {
+		mam.ArchiveIncomingMessage(ctx, message, s.deliverMessage)
+		return
+	}
+	s.deliverMessage(ctx, message)
+}
+
+// deliverMessage routes an incoming message, once it has been archived.
+func (s *inStream) deliverMessage(ctx context.Context, message *xmpp.Message) {
 	msg := message
 
 sendMessage:
 	err := s.router.Route(ctx, msg)
 	switch err {
 	case nil:
-		break
+		if carbons := s.mods.For(msg.ToJID().Domain()).Carbons; carbons != nil {
+			carbons.ProcessIncomingMessage(ctx, msg)
+		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
 		msg, _ = xmpp.NewMessageFromElement(msg, msg.FromJID(), msg.ToJID().ToBareJID())
 		goto sendMessage
 	case router.ErrNotAuthenticated:
-		if off := s.mods.Offline; off != nil {
+		if off := s.mods.For(message.ToJID().Domain()).Offline; off != nil {
 			off.ArchiveMessage(ctx, message)
 			return
 		}
@@ -273,9 +287,9 @@ func (s *inStream) proceedStartTLS(ctx context.Context, elem xmpp.XElement) {
 	s.writeElement(ctx, xmpp.NewElementNamespace("proceed", tlsNamespace))
 
 	s.tr.StartTLS(&tls.Config{
-		ServerName:   s.localDomain,
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: s.router.Hosts().Certificates(),
+		ServerName:     s.localDomain,
+		ClientAuth:     tls.VerifyClientCertIfGiven,
+		GetCertificate: s.router.Hosts().GetCertificate,
 	}, false)
 	atomic.StoreUint32(&s.secured, 1)
 
@@ -327,14 +341,14 @@ func (s *inStream) failAuthentication(ctx context.Context, reason, text string)
 }
 
 func (s *inStream) authorizeDialbackKey(ctx context.Context, elem xmpp.XElement) {
-	if !s.router.Hosts().IsLocalHost(elem.To()) {
+	if !s.isLocalDomain(elem.To()) {
 		s.writeStanzaErrorResponse(ctx, elem, xmpp.ErrItemNotFound)
 		return
 	}
 	log.Infof("authorizing dialback key: %s...", elem.Text())
 
 	// verify stream
-	outStm := s.newOut(s.router.Hosts().DefaultHostName(), elem.From())
+	outStm := s.newOut(elem.To(), elem.From())
 
 	verifyCh := outStm.verify(ctx, s.sess.StreamID(), elem.To(), elem.From(), elem.Text())
 
@@ -362,7 +376,7 @@ func (s *inStream) authorizeDialbackKey(ctx context.Context, elem xmpp.XElement)
 }
 
 func (s *inStream) verifyDialbackKey(ctx context.Context, elem xmpp.XElement) {
-	if !s.router.Hosts().IsLocalHost(elem.To()) {
+	if !s.isLocalDomain(elem.To()) {
 		s.writeStanzaErrorResponse(ctx, elem, xmpp.ErrItemNotFound)
 		return
 	}
@@ -459,13 +473,19 @@ func (s *inStream) disconnectClosingSession(ctx context.Context, closeSession bo
 	s.runQueue.Stop(nil) // stop processing messages
 }
 
+// isLocalDomain returns whether or not a domain is served by this server, either as a host or a component.
+func (s *inStream) isLocalDomain(domain string) bool {
+	return s.router.Hosts().IsLocalHost(domain) || s.router.IsComponentHost(domain)
+}
+
 func (s *inStream) restartSession() {
 	j, _ := jid.New("", s.localDomain, "", true)
 	s.sess = session.New(s.id, &session.Config{
-		JID:           j,
-		MaxStanzaSize: s.cfg.maxStanzaSize,
-		RemoteDomain:  s.remoteDomain,
-		IsServer:      true,
+		JID:             j,
+		MaxStanzaSize:   s.cfg.maxStanzaSize,
+		RemoteDomain:    s.remoteDomain,
+		IsServer:        true,
+		IsComponentHost: s.router.IsComponentHost,
 	}, s.tr, s.router.Hosts())
 	s.setState(inConnecting)
 }
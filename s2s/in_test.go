@@ -217,6 +217,33 @@ func TestStream_DialbackVerify(t *testing.T) {
 	require.Equal(t, "valid", elem.Type())
 }
 
+func TestStream_DialbackVerifyComponentHost(t *testing.T) {
+	r, h := setupTestRouter(jackaDomain)
+	r.SetComponentRouter(&fakeComponentRouter{domain: "muc.jackal.im"})
+
+	op := NewOutProvider(&Config{KeepAlive: time.Second}, h)
+
+	stm, conn := tUtilInStreamInit(t, r, op, false)
+	_, _ = conn.inboundWriteString(`<?xml version="1.0"?>
+	<stream:stream xmlns:stream="http://etherx.jabber.org/streams"
+	version="1.0" xmlns="jabber:server" to="muc.jackal.im" from="localhost" xmlns:xml="http://www.w3.org/XML/1998/namespace">
+`)
+	elem := conn.outboundRead() // read stream opening...
+	require.Equal(t, "muc.jackal.im", elem.From())
+	_ = conn.outboundRead() // read stream features...
+	atomic.StoreUint32(&stm.secured, 1)
+	atomic.StoreUint32(&stm.authenticated, 1)
+
+	kg := &keyGen{secret: "s3cr3t"}
+	key := kg.generate("localhost", "muc.jackal.im", "abcde")
+
+	_, _ = conn.inboundWriteString(fmt.Sprintf(`<db:verify id="abcde" from="localhost" to="muc.jackal.im">%s</db:verify>`, key))
+	elem = conn.outboundRead()
+	require.Equal(t, "db:verify", elem.Name())
+	require.Equal(t, "muc.jackal.im", elem.From())
+	require.Equal(t, "valid", elem.Type())
+}
+
 func TestStream_DialbackAuthorize(t *testing.T) {
 	r, h := setupTestRouter(jackaDomain)
 
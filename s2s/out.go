@@ -70,8 +70,10 @@ func (s *outStream) ID() string {
 }
 
 func (s *outStream) SendElement(ctx context.Context, elem xmpp.XElement) {
-	s.runQueue.Run(func() {
+	_ = s.runQueue.TryRun(func() {
 		s.sendElement(ctx, elem)
+	}, func() {
+		log.Warnf("s2s out stream %s: element discarded due to stream congestion", s.ID())
 	})
 }
 
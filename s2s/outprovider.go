@@ -73,8 +73,11 @@ func (p *OutProvider) Shutdown(ctx context.Context) error {
 
 func (p *OutProvider) newOut(localDomain, remoteDomain string) *outStream {
 	tlsConfig := &tls.Config{
-		ServerName:   remoteDomain,
-		Certificates: p.hosts.Certificates(),
+		ServerName: remoteDomain,
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cer, _ := p.hosts.Certificate(localDomain)
+			return &cer, nil // an empty certificate means no client certificate at all
+		},
 	}
 	cfg := &outConfig{
 		keyGen:        &keyGen{secret: p.cfg.DialbackSecret},
@@ -28,17 +28,18 @@ func New(outProvider OutProvider) router.S2SRouter {
 
 func (r *s2sRouter) Route(ctx context.Context, stanza xmpp.Stanza, localDomain string) error {
 	remoteDomain := stanza.ToJID().Domain()
+	domainPair := localDomain + ":" + remoteDomain
 
 	r.mu.RLock()
-	rr := r.remotes[remoteDomain]
+	rr := r.remotes[domainPair]
 	r.mu.RUnlock()
 
 	if rr == nil {
 		r.mu.Lock()
-		rr = r.remotes[remoteDomain] // avoid double initialization
+		rr = r.remotes[domainPair] // avoid double initialization
 		if rr == nil {
 			rr = newRemoteRouter(localDomain, remoteDomain, r.outProvider)
-			r.remotes[remoteDomain] = rr
+			r.remotes[domainPair] = rr
 		}
 		r.mu.Unlock()
 	}
@@ -28,9 +28,13 @@ func (s *mockedOutS2S) SendElement(_ context.Context, _ xmpp.XElement) {
 
 type mockedOutProvider struct {
 	outStm *mockedOutS2S
+	pairs  []string
 }
 
-func (p *mockedOutProvider) GetOut(_, _ string) stream.S2SOut { return p.outStm }
+func (p *mockedOutProvider) GetOut(localDomain, remoteDomain string) stream.S2SOut {
+	p.pairs = append(p.pairs, localDomain+":"+remoteDomain)
+	return p.outStm
+}
 
 func TestS2SRouter_Route(t *testing.T) {
 	outStm := &mockedOutS2S{}
@@ -47,3 +51,21 @@ func TestS2SRouter_Route(t *testing.T) {
 
 	require.Equal(t, int32(2), atomic.LoadInt32(&outStm.sentTimes))
 }
+
+func TestS2SRouter_RoutePerLocalDomain(t *testing.T) {
+	outStm := &mockedOutS2S{}
+	p := &mockedOutProvider{outStm: outStm}
+
+	r := New(p)
+
+	j1, _ := jid.NewWithString("sxmpp@jackal.im", true)
+	j2, _ := jid.NewWithString("room@muc.jackal.im/sxmpp", true)
+	j3, _ := jid.NewWithString("noelia@jabber.org/yard", true)
+
+	_ = r.Route(context.Background(), xmpp.NewPresence(j1, j3, xmpp.AvailableType), "jackal.im")
+	_ = r.Route(context.Background(), xmpp.NewPresence(j2, j3, xmpp.AvailableType), "muc.jackal.im")
+	_ = r.Route(context.Background(), xmpp.NewPresence(j2, j3, xmpp.UnavailableType), "muc.jackal.im")
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&outStm.sentTimes))
+	require.Equal(t, []string{"jackal.im:jabber.org", "muc.jackal.im:jabber.org"}, p.pairs)
+}
@@ -202,6 +202,13 @@ func setupTestHosts(domain string) *host.Hosts {
 	return hosts
 }
 
+type fakeComponentRouter struct {
+	domain string
+}
+
+func (r *fakeComponentRouter) Route(_ context.Context, _ xmpp.Stanza) error { return nil }
+func (r *fakeComponentRouter) IsComponentHost(domain string) bool           { return domain == r.domain }
+
 type fakeS2SServer struct {
 	startCh    chan struct{}
 	shutdownCh chan struct{}
@@ -27,6 +27,7 @@ import (
 const (
 	jabberClientNamespace = "jabber:client"
 	jabberServerNamespace = "jabber:server"
+	componentNamespace    = "jabber:component:accept"
 	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
 	streamNamespace       = "http://etherx.jabber.org/streams"
 	dialbackNamespace     = "jabber:server:dialback"
@@ -36,6 +37,10 @@ type namespaceSettable interface {
 	SetNamespace(string)
 }
 
+type preparable interface {
+	Prepared() *xmpp.PreparedStanza
+}
+
 // Error represents a session error.
 type Error struct {
 	// Element returns the original incoming element that generated
@@ -65,6 +70,14 @@ type Config struct {
 	// IsInitiating defines whether or not this is an initiating
 	// entity session.
 	IsInitiating bool
+
+	// IsComponent defines whether or not this session is established
+	// by an external component (XEP-0114).
+	IsComponent bool
+
+	// IsComponentHost reports whether or not a domain is served by a local component,
+	// allowing the remote entity to address it on stream opening.
+	IsComponentHost func(domain string) bool
 }
 
 // Session represents an XMPP session between the two peers.
@@ -76,6 +89,8 @@ type Session struct {
 	remoteDomain string
 	isServer     bool
 	isInitiating bool
+	isComponent  bool
+	isCompHost   func(domain string) bool
 	opened       uint32
 	started      uint32
 
@@ -99,6 +114,8 @@ func New(id string, config *Config, tr transport.Transport, hosts *host.Hosts) *
 		remoteDomain: config.RemoteDomain,
 		isServer:     config.IsServer,
 		isInitiating: config.IsInitiating,
+		isComponent:  config.IsComponent,
+		isCompHost:   config.IsComponentHost,
 		sJID:         config.JID,
 	}
 	if !s.isInitiating {
@@ -161,7 +178,9 @@ func (s *Session) Open(ctx context.Context, featuresElem xmpp.XElement) error {
 		ops.SetAttribute("to", s.remoteDomain)
 		s.mu.RUnlock()
 	}
-	ops.SetAttribute("version", "1.0")
+	if !s.isComponent {
+		ops.SetAttribute("version", "1.0")
+	}
 	if err := ops.ToXML(buf, includeClosing); err != nil {
 		return err
 	}
@@ -204,12 +223,16 @@ func (s *Session) Close(ctx context.Context) error {
 
 // Send writes an XML element to the underlying session transport.
 func (s *Session) Send(ctx context.Context, elem xmpp.XElement) error {
+	log.Debugf("SEND(%s): %v", s.id, elem)
+
+	// write pre-rendered stanza
+	if p, ok := elem.(preparable); ok && p.Prepared() != nil {
+		return s.sendPrepared(ctx, p.Prepared(), elem.To())
+	}
 	// clear namespace if sending a stanza
 	if e, ok := elem.(namespaceSettable); elem.IsStanza() && ok {
 		e.SetNamespace("")
 	}
-	log.Debugf("SEND(%s): %v", s.id, elem)
-
 	s.setWriteDeadline(ctx)
 
 	if err := elem.ToXML(s.tr, true); err != nil {
@@ -218,6 +241,15 @@ func (s *Session) Send(ctx context.Context, elem xmpp.XElement) error {
 	return s.tr.Flush()
 }
 
+func (s *Session) sendPrepared(ctx context.Context, p *xmpp.PreparedStanza, to string) error {
+	s.setWriteDeadline(ctx)
+
+	if err := s.tr.WritePrepared(p, to); err != nil {
+		return err
+	}
+	return s.tr.Flush()
+}
+
 // Receive returns next incoming session element.
 func (s *Session) Receive() (xmpp.XElement, *Error) {
 	elem, err := s.pr.ParseElement()
@@ -297,7 +329,18 @@ func (s *Session) extractAddresses(elem xmpp.XElement) (*jid.JID, *jid.JID, *Err
 	var err error
 
 	from := elem.From()
-	if !s.isServer {
+	if s.isComponent {
+		// stanzas sent by a component are always stamped with its domain
+		if len(from) == 0 {
+			fromJID = s.jid()
+		} else {
+			j, err := jid.NewWithString(from, false)
+			if err != nil || j.Domain() != s.remoteDomain {
+				return nil, nil, &Error{UnderlyingErr: streamerror.ErrInvalidFrom}
+			}
+			fromJID = j
+		}
+	} else if !s.isServer {
 		// do not validate 'from' address until full user JID has been set
 		if s.jid().IsFullWithUser() {
 			if len(from) > 0 && !s.isValidFrom(from) {
@@ -352,8 +395,12 @@ func (s *Session) validateStreamElement(elem xmpp.XElement) *Error {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
 	}
+	if s.isComponent {
+		// component domain is validated on handshake
+		return nil
+	}
 	to := elem.To()
-	if len(to) > 0 && !s.hosts.IsLocalHost(to) {
+	if len(to) > 0 && !s.hosts.IsLocalHost(to) && !(s.isCompHost != nil && s.isCompHost(to)) {
 		return &Error{UnderlyingErr: streamerror.ErrHostUnknown}
 	}
 	if elem.Version() != "1.0" {
@@ -371,6 +418,9 @@ func (s *Session) validateNamespace(elem xmpp.XElement) *Error {
 }
 
 func (s *Session) namespace() string {
+	if s.isComponent {
+		return componentNamespace
+	}
 	if s.isServer {
 		return jabberServerNamespace
 	}
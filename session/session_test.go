@@ -36,13 +36,16 @@ func newFakeTransport(typ transport.Type) *fakeTransport {
 	return &fakeTransport{typ: typ, rdBuf: new(bytes.Buffer), wrBuf: new(bytes.Buffer)}
 }
 
-func (t *fakeTransport) Read(p []byte) (n int, err error)                             { return t.rdBuf.Read(p) }
-func (t *fakeTransport) Write(p []byte) (n int, err error)                            { return t.wrBuf.Write(p) }
-func (t *fakeTransport) Close() error                                                 { return nil }
-func (t *fakeTransport) Type() transport.Type                                         { return t.typ }
-func (t *fakeTransport) Flush() error                                                 { return nil }
-func (t *fakeTransport) SetWriteDeadline(_ time.Time) error                           { return nil }
-func (t *fakeTransport) WriteString(s string) (n int, err error)                      { return t.wrBuf.WriteString(s) }
+func (t *fakeTransport) Read(p []byte) (n int, err error)        { return t.rdBuf.Read(p) }
+func (t *fakeTransport) Write(p []byte) (n int, err error)       { return t.wrBuf.Write(p) }
+func (t *fakeTransport) Close() error                            { return nil }
+func (t *fakeTransport) Type() transport.Type                    { return t.typ }
+func (t *fakeTransport) Flush() error                            { return nil }
+func (t *fakeTransport) SetWriteDeadline(_ time.Time) error      { return nil }
+func (t *fakeTransport) WriteString(s string) (n int, err error) { return t.wrBuf.WriteString(s) }
+func (t *fakeTransport) WritePrepared(p *xmpp.PreparedStanza, to string) error {
+	return p.WriteTo(t.wrBuf, to)
+}
 func (t *fakeTransport) StartTLS(_ *tls.Config, _ bool)                               {}
 func (t *fakeTransport) EnableCompression(compress.Level)                             {}
 func (t *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte { return nil }
@@ -119,6 +122,48 @@ func TestSession_Send(t *testing.T) {
 	require.Equal(t, elem.String(), tr.wrBuf.String())
 }
 
+func TestSession_SendPrepared(t *testing.T) {
+	hosts := setupTest("jackal.im")
+
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/res", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+	tr := newFakeTransport(transport.Socket)
+	sess := New(uuid.New(), &Config{JID: j1}, tr, hosts)
+
+	_ = sess.Open(context.Background(), nil)
+	tr.wrBuf.Reset()
+
+	p := xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType)
+	p.SetNamespace("jabber:client")
+	status := xmpp.NewElementName("status")
+	status.SetText("Ready")
+	p.AppendElement(status)
+
+	prepared := xmpp.NewPreparedStanza(p)
+
+	_ = sess.Send(context.Background(), prepared.Stanza(j2))
+	require.Equal(t, `<presence from="sxmpp@jackal.im/res" to="noelia@jackal.im/garden"><status>Ready</status></presence>`, tr.wrBuf.String())
+
+	// mutating a prepared stanza discards its prepared representation
+	tr.wrBuf.Reset()
+	s := prepared.Stanza(j2).(*xmpp.Presence)
+	s.SetID("p1")
+	s.AppendElement(xmpp.NewElementName("priority").SetText("5"))
+	_ = sess.Send(context.Background(), s)
+	require.Equal(t, `<presence from="sxmpp@jackal.im/res" to="noelia@jackal.im/garden" xmlns="jabber:client" id="p1"><status>Ready</status><priority>5</priority></presence>`, tr.wrBuf.String())
+
+	tr.wrBuf.Reset()
+	s = prepared.Stanza(j2).(*xmpp.Presence)
+	s.RemoveElements("status")
+	_ = sess.Send(context.Background(), s)
+	require.Equal(t, `<presence from="sxmpp@jackal.im/res" to="noelia@jackal.im/garden" xmlns="jabber:client"/>`, tr.wrBuf.String())
+
+	// original stanza remains untouched
+	tr.wrBuf.Reset()
+	_ = sess.Send(context.Background(), prepared.Stanza(j2))
+	require.Equal(t, `<presence from="sxmpp@jackal.im/res" to="noelia@jackal.im/garden"><status>Ready</status></presence>`, tr.wrBuf.String())
+}
+
 func TestSession_Receive(t *testing.T) {
 	hosts := setupTest("jackal.im")
 
@@ -197,7 +242,7 @@ func TestSession_IsValidNamespace(t *testing.T) {
 func TestSession_IsValidFrom(t *testing.T) {
 	hosts := setupTest("jackal.im")
 
-	j1, _ := jid.NewWithString("jackal.im", true)                  // server domain
+	j1, _ := jid.NewWithString("jackal.im", true)                // server domain
 	j2, _ := jid.NewWithString("sxmpp@jackal.im/resource", true) // full jid with user
 
 	tr := newFakeTransport(transport.Socket)
@@ -250,6 +295,16 @@ func TestSession_ValidateStream(t *testing.T) {
 
 	elem2.SetTo("jackal.im")
 	require.Nil(t, sess.validateStreamElement(elem2))
+
+	// component domains
+	elem2.SetTo("muc.jackal.im")
+	err = sess.validateStreamElement(elem2)
+	require.NotNil(t, err)
+	require.Equal(t, streamerror.ErrHostUnknown, err.UnderlyingErr)
+
+	isCompHost := func(domain string) bool { return domain == "muc.jackal.im" }
+	sess = New(uuid.New(), &Config{JID: j, IsComponentHost: isCompHost}, newFakeTransport(transport.Socket), hosts)
+	require.Nil(t, sess.validateStreamElement(elem2))
 }
 
 func TestSession_ExtractAddresses(t *testing.T) {
@@ -292,6 +347,48 @@ func TestSession_ExtractAddresses(t *testing.T) {
 	require.Equal(t, xmpp.ErrJidMalformed, err.UnderlyingErr)
 }
 
+func TestSession_Component(t *testing.T) {
+	hosts := setupTest("jackal.im")
+
+	j, _ := jid.NewWithString("gateway.jackal.im", true)
+
+	tr := newFakeTransport(transport.Socket)
+	sess := New(uuid.New(), &Config{JID: j, RemoteDomain: "gateway.jackal.im", IsComponent: true}, tr, hosts)
+
+	_ = sess.Open(context.Background(), nil)
+	pr := xmpp.NewParser(tr.wrBuf, xmpp.SocketStream, 0)
+	_, _ = pr.ParseElement() // read xml header
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "jabber:component:accept", elem.Namespace())
+	require.Equal(t, "gateway.jackal.im", elem.From())
+	require.Equal(t, sess.StreamID(), elem.ID())
+	require.Equal(t, "", elem.Version())
+
+	// component stream element carries neither version nor a local 'to' domain
+	open := xmpp.NewElementNamespace("stream:stream", componentNamespace)
+	open.SetAttribute("xmlns:stream", streamNamespace)
+	open.SetTo("gateway.jackal.im")
+	require.Nil(t, sess.validateStreamElement(open))
+
+	iq := xmpp.NewElementNamespace("iq", componentNamespace)
+	iq.SetTo("sxmpp@jackal.im/res")
+
+	from, _, sErr := sess.extractAddresses(iq)
+	require.Nil(t, sErr)
+	require.Equal(t, "gateway.jackal.im", from.String())
+
+	iq.SetFrom("user@gateway.jackal.im")
+	from, _, sErr = sess.extractAddresses(iq)
+	require.Nil(t, sErr)
+	require.Equal(t, "user@gateway.jackal.im", from.String())
+
+	iq.SetFrom("user@example.org")
+	_, _, sErr = sess.extractAddresses(iq)
+	require.NotNil(t, sErr)
+	require.Equal(t, streamerror.ErrInvalidFrom, sErr.UnderlyingErr)
+}
+
 func TestSession_BuildStanza(t *testing.T) {
 	hosts := setupTest("jackal.im")
 
@@ -1,21 +0,0 @@
-/*
- * Copyright (c) 2018 Miguel Ángel Ortuño.
- * See the LICENSE file for more information.
- */
-
-DROP TABLE IF EXISTS pubsub_items;
-DROP TABLE IF EXISTS pubsub_subscriptions;
-DROP TABLE IF EXISTS pubsub_affiliations;
-DROP TABLE IF EXISTS pubsub_node_options;
-DROP TABLE IF EXISTS pubsub_nodes;
-DROP TABLE IF EXISTS offline_messages;
-DROP TABLE IF EXISTS vcards;
-DROP TABLE IF EXISTS private_storage;
-DROP TABLE IF EXISTS blocklist_items;
-DROP TABLE IF EXISTS roster_versions;
-DROP TABLE IF EXISTS roster_groups;
-DROP TABLE IF EXISTS roster_items;
-DROP TABLE IF EXISTS roster_notifications;
-DROP TABLE IF EXISTS capabilities;
-DROP TABLE IF EXISTS presences;
-DROP TABLE IF EXISTS users;
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+)
+
+// archive messages are stored under their own key, prefixed by owner and ordered by stamp,
+// so that archiving a message doesn't require rewriting its whole archive.
+const archiveMessagesPrefix = "archiveMessage:"
+
+type badgerDBArchive struct {
+	*badgerDBStorage
+}
+
+func newArchive(db *badger.DB) *badgerDBArchive {
+	return &badgerDBArchive{badgerDBStorage: newStorage(db)}
+}
+
+// InsertArchiveMessage appends a new message into its owner archive.
+func (b *badgerDBArchive) InsertArchiveMessage(_ context.Context, message *archivemodel.Message) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(message, archiveMessageKey(message), tx)
+	})
+}
+
+// FetchArchiveMessages retrieves in chronological order those archived messages satisfying a query.
+func (b *badgerDBArchive) FetchArchiveMessages(_ context.Context, query *archivemodel.Query, username, domain string) ([]archivemodel.Message, error) {
+	messages, err := b.fetchArchiveMessages(username, domain)
+	if err != nil {
+		return nil, err
+	}
+	return query.Filter(messages), nil
+}
+
+// CountArchiveMessages returns the number of archived messages satisfying a query filter, paging parameters aside.
+func (b *badgerDBArchive) CountArchiveMessages(_ context.Context, query *archivemodel.Query, username, domain string) (int, error) {
+	messages, err := b.fetchArchiveMessages(username, domain)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for i := range messages {
+		if query.Matches(&messages[i]) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ArchiveMessageExists returns whether or not a message identifier is contained in a user archive.
+func (b *badgerDBArchive) ArchiveMessageExists(_ context.Context, id, username, domain string) (bool, error) {
+	var exists bool
+	err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKey(archiveMessagesKeyPrefix(username, domain), tx, func(k []byte) error {
+			if _, msgID := parseArchiveMessageKey(k); msgID == id {
+				exists = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// DeleteArchiveMessages removes from every archive those messages archived before a given time.
+func (b *badgerDBArchive) DeleteArchiveMessages(_ context.Context, before time.Time) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var keys [][]byte
+		if err := b.forEachKey(archiveMessagesPrefix, tx, func(k []byte) error {
+			if stamp, _ := parseArchiveMessageKey(k); stamp.Before(before) {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := tx.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpsertArchivePreferences inserts new user archiving preferences into storage,
+// or updates them in case they've been previously inserted.
+func (b *badgerDBArchive) UpsertArchivePreferences(_ context.Context, prefs *archivemodel.Preferences, username, domain string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(prefs, archivePreferencesKey(username, domain), tx)
+	})
+}
+
+// FetchArchivePreferences retrieves from storage user archiving preferences.
+func (b *badgerDBArchive) FetchArchivePreferences(_ context.Context, username, domain string) (*archivemodel.Preferences, error) {
+	var prefs archivemodel.Preferences
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&prefs, archivePreferencesKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &prefs, nil
+}
+
+func (b *badgerDBArchive) fetchArchiveMessages(username, domain string) ([]archivemodel.Message, error) {
+	var messages []archivemodel.Message
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKeyAndValue(archiveMessagesKeyPrefix(username, domain), tx, func(_, v []byte) error {
+			var msg archivemodel.Message
+			if err := msg.FromBytes(bytes.NewBuffer(v)); err != nil {
+				return err
+			}
+			messages = append(messages, msg)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// archiveMessagesKeyPrefix returns the key prefix shared by every message of a user archive.
+func archiveMessagesKeyPrefix(username, domain string) string {
+	return archiveMessagesPrefix + userKey(username, domain) + ":"
+}
+
+func archiveMessageKey(message *archivemodel.Message) string {
+	// fixed width hexadecimal stamp makes key order chronological
+	return fmt.Sprintf("%s%016x:%s", archiveMessagesKeyPrefix(message.Username, message.Domain), message.Stamp.UnixNano(), message.ID)
+}
+
+// parseArchiveMessageKey returns both stamp and identifier of an archived message key.
+func parseArchiveMessageKey(k []byte) (stamp time.Time, id string) {
+	parts := strings.SplitN(string(k[len(archiveMessagesPrefix):]), ":", 3)
+	if len(parts) != 3 {
+		return time.Time{}, ""
+	}
+	nanos, _ := strconv.ParseInt(parts[1], 16, 64)
+	return time.Unix(0, nanos), parts[2]
+}
+
+func archivePreferencesKey(username, domain string) string {
+	return "archivePreferences:" + userKey(username, domain)
+}
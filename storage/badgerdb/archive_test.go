@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_ArchiveMessages(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newArchive(h.db)
+
+	now := time.Now()
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("1", "romeo@jackal.im/garden", now.Add(-48*time.Hour))))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("2", "nurse@jackal.im/home", now)))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("3", "romeo@jackal.im/orchard", now.Add(time.Second))))
+
+	messages, err := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im"}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "1", messages[0].ID)
+	require.Equal(t, "3", messages[1].ID)
+
+	cnt, err := s.CountArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 3, cnt)
+
+	ok, err := s.ArchiveMessageExists(context.Background(), "2", "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = s.ArchiveMessageExists(context.Background(), "2", "juliet", "jackal.net")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// messages are kept in chronological order regardless of insertion order
+	early := tArchiveMessage("0", "romeo@jackal.im/garden", now.Add(-72*time.Hour))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), early))
+
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Len(t, messages, 4)
+	require.Equal(t, "0", messages[0].ID)
+
+	require.Nil(t, s.DeleteArchiveMessages(context.Background(), now.Add(-24*time.Hour)))
+
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Len(t, messages, 2)
+	require.Equal(t, "2", messages[0].ID)
+}
+
+func TestBadgerDB_ArchivePreferences(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newArchive(h.db)
+
+	prefs, err := s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, prefs)
+
+	require.Nil(t, s.UpsertArchivePreferences(context.Background(), &archivemodel.Preferences{
+		Default: archivemodel.Always,
+		Never:   []string{"tybalt@jackal.im"},
+	}, "juliet", "jackal.im"))
+
+	prefs, err = s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, archivemodel.Always, prefs.Default)
+	require.Equal(t, []string{"tybalt@jackal.im"}, prefs.Never)
+}
+
+func tArchiveMessage(id, with string, stamp time.Time) *archivemodel.Message {
+	from, _ := jid.NewWithString(with, true)
+	to, _ := jid.NewWithString("juliet@jackal.im/balcony", true)
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Wherefore art thou Romeo?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	return &archivemodel.Message{
+		ID:       id,
+		Username: "juliet",
+		Domain:   "jackal.im",
+		With:     with,
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+const valueLogGCDiscardRatio = 0.5
+
+type badgerDBContainer struct {
+	user      *badgerDBUser
+	roster    *badgerDBRoster
+	presences *badgerDBPresences
+	vCard     *badgerDBVCard
+	priv      *badgerDBPrivate
+	blockList *badgerDBBlockList
+	pubSub    *badgerDBPubSub
+	offline   *badgerDBOffline
+	archive   *badgerDBArchive
+	push      *badgerDBPush
+	muc       *badgerDBMuc
+	host      *badgerDBHost
+
+	db     *badger.DB
+	doneCh chan chan bool
+}
+
+// New initializes BadgerDB storage and returns associated container.
+func New(cfg *Config) (repository.Container, error) {
+	if err := os.MkdirAll(cfg.DataDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	opts := badger.DefaultOptions(cfg.DataDir).WithLogger(&badgerLogger{})
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	c := &badgerDBContainer{db: db, doneCh: make(chan chan bool, 1)}
+	go c.loop()
+
+	c.user = newUser(db)
+	c.roster = newRoster(db)
+	c.presences = newPresences(db)
+	c.vCard = newVCard(db)
+	c.priv = newPrivate(db)
+	c.blockList = newBlockList(db)
+	c.pubSub = newPubSub(db)
+	c.offline = newOffline(db)
+	c.archive = newArchive(db)
+	c.push = newPush(db)
+	c.muc = newMuc(db)
+	c.host = newHost(db)
+
+	return c, nil
+}
+
+func (c *badgerDBContainer) User() repository.User           { return c.user }
+func (c *badgerDBContainer) Roster() repository.Roster       { return c.roster }
+func (c *badgerDBContainer) Presences() repository.Presences { return c.presences }
+func (c *badgerDBContainer) VCard() repository.VCard         { return c.vCard }
+func (c *badgerDBContainer) Private() repository.Private     { return c.priv }
+func (c *badgerDBContainer) BlockList() repository.BlockList { return c.blockList }
+func (c *badgerDBContainer) PubSub() repository.PubSub       { return c.pubSub }
+func (c *badgerDBContainer) Offline() repository.Offline     { return c.offline }
+func (c *badgerDBContainer) Archive() repository.Archive     { return c.archive }
+func (c *badgerDBContainer) Push() repository.Push           { return c.push }
+func (c *badgerDBContainer) Muc() repository.Muc             { return c.muc }
+func (c *badgerDBContainer) Host() repository.Host           { return c.host }
+
+func (c *badgerDBContainer) Close(ctx context.Context) error {
+	ch := make(chan bool)
+	c.doneCh <- ch
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *badgerDBContainer) IsClusterCompatible() bool { return false }
+
+func (c *badgerDBContainer) loop() {
+	tc := time.NewTicker(time.Minute)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-tc.C:
+			// reclaim value log space
+			for c.db.RunValueLogGC(valueLogGCDiscardRatio) == nil {
+			}
+		case ch := <-c.doneCh:
+			if err := c.db.Close(); err != nil {
+				log.Error(err)
+			}
+			close(ch)
+			return
+		}
+	}
+}
+
+// badgerLogger forwards BadgerDB log output to jackal logger.
+type badgerLogger struct{}
+
+func (l *badgerLogger) Errorf(format string, args ...interface{})   { log.Errorf(format, args...) }
+func (l *badgerLogger) Warningf(format string, args ...interface{}) { log.Warnf(format, args...) }
+func (l *badgerLogger) Infof(format string, args ...interface{})    { log.Debugf(format, args...) }
+func (l *badgerLogger) Debugf(format string, args ...interface{})   { log.Debugf(format, args...) }
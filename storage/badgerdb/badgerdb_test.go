@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+type badgerDBTestHelper struct {
+	db      *badger.DB
+	dataDir string
+}
+
+func TestBadgerDB_Container(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "jackal_badgerdb")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	c, err := New(&Config{DataDir: dataDir})
+	require.Nil(t, err)
+	require.False(t, c.IsClusterCompatible())
+
+	require.Nil(t, c.User().UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	require.Nil(t, c.Close(context.Background()))
+
+	// data must persist across restarts
+	c, err = New(&Config{DataDir: dataDir})
+	require.Nil(t, err)
+	defer func() { _ = c.Close(context.Background()) }()
+
+	usr, err := c.User().FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+}
+
+func tUtilBadgerDBSetup(t *testing.T) *badgerDBTestHelper {
+	dataDir, err := ioutil.TempDir("", "jackal_badgerdb")
+	require.Nil(t, err)
+
+	opts := badger.DefaultOptions(dataDir).WithLogger(&badgerLogger{})
+	db, err := badger.Open(opts)
+	require.Nil(t, err)
+	return &badgerDBTestHelper{db: db, dataDir: dataDir}
+}
+
+func tUtilBadgerDBTeardown(h *badgerDBTestHelper) {
+	_ = h.db.Close()
+	_ = os.RemoveAll(h.dataDir)
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/model"
+)
+
+type badgerDBBlockList struct {
+	*badgerDBStorage
+}
+
+func newBlockList(db *badger.DB) *badgerDBBlockList {
+	return &badgerDBBlockList{badgerDBStorage: newStorage(db)}
+}
+
+// InsertBlockListItem inserts a block list item entity into storage if not previously inserted.
+func (b *badgerDBBlockList) InsertBlockListItem(_ context.Context, item *model.BlockListItem) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var items []model.BlockListItem
+		if _, err := b.fetchEntities(&items, blockListItemsKey(item.Username, item.Domain), tx); err != nil {
+			return err
+		}
+		for _, itm := range items {
+			if itm.JID == item.JID {
+				return nil // already inserted
+			}
+		}
+		items = append(items, *item)
+		return b.upsertEntities(&items, blockListItemsKey(item.Username, item.Domain), tx)
+	})
+}
+
+// DeleteBlockListItem deletes a block list item entity from storage.
+func (b *badgerDBBlockList) DeleteBlockListItem(_ context.Context, item *model.BlockListItem) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var items []model.BlockListItem
+		if _, err := b.fetchEntities(&items, blockListItemsKey(item.Username, item.Domain), tx); err != nil {
+			return err
+		}
+		for i, itm := range items {
+			if itm.JID == item.JID {
+				items = append(items[:i], items[i+1:]...)
+				return b.upsertEntities(&items, blockListItemsKey(item.Username, item.Domain), tx)
+			}
+		}
+		return nil // not present
+	})
+}
+
+// FetchBlockListItems retrieves from storage all block list item entities associated to a given user.
+func (b *badgerDBBlockList) FetchBlockListItems(_ context.Context, username, domain string) ([]model.BlockListItem, error) {
+	var items []model.BlockListItem
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&items, blockListItemsKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func blockListItemsKey(username, domain string) string {
+	return "blockListItems:" + userKey(username, domain)
+}
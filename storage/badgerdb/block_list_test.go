@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_InsertOrUpdateBlockListItems(t *testing.T) {
+	items := []model.BlockListItem{
+		{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"},
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newBlockList(h.db)
+
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"}))
+
+	sItems, _ := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, items, sItems)
+}
+
+func TestBadgerDB_DeleteBlockListItems(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newBlockList(h.db)
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"}))
+
+	require.Nil(t, s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+
+	sItems, _ := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, []model.BlockListItem{
+		{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"},
+	}, sItems)
+}
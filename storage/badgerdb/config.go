@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+// DefaultDataDir defines the default BadgerDB data directory.
+const DefaultDataDir = "./data"
+
+// Config represents BadgerDB storage configuration.
+type Config struct {
+	DataDir string `yaml:"data_dir"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawConfig Config
+
+	parsed := rawConfig{DataDir: DefaultDataDir}
+
+	if err := unmarshal(&parsed); err != nil {
+		return err
+	}
+	*c = Config(parsed)
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+type badgerDBHost struct {
+	*badgerDBStorage
+}
+
+func newHost(db *badger.DB) *badgerDBHost {
+	return &badgerDBHost{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertHost inserts a new host entity into storage, or updates it in case it's been previously inserted.
+func (b *badgerDBHost) UpsertHost(_ context.Context, host *model.Host) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(host, hostKey(host.Name), tx)
+	})
+}
+
+// DeleteHost deletes a host entity from storage.
+func (b *badgerDBHost) DeleteHost(_ context.Context, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.deleteKey(hostKey(name), tx)
+	})
+}
+
+// FetchHost retrieves from storage a host entity.
+func (b *badgerDBHost) FetchHost(_ context.Context, name string) (*model.Host, error) {
+	var host model.Host
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&host, hostKey(name), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &host, nil
+}
+
+// FetchHosts retrieves from storage all host entities.
+func (b *badgerDBHost) FetchHosts(_ context.Context) ([]model.Host, error) {
+	var hosts []model.Host
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKeyAndValue(hostsPrefix, tx, func(_, v []byte) error {
+			var host model.Host
+			if err := serializer.Deserialize(v, &host); err != nil {
+				return err
+			}
+			hosts = append(hosts, host)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+const hostsPrefix = "hosts:"
+
+func hostKey(name string) string {
+	return hostsPrefix + name
+}
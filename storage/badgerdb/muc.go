@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+type badgerDBMuc struct {
+	*badgerDBStorage
+}
+
+func newMuc(db *badger.DB) *badgerDBMuc {
+	return &badgerDBMuc{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertMucRoom inserts a new room into storage, or updates it in case it's been previously inserted.
+func (b *badgerDBMuc) UpsertMucRoom(_ context.Context, room *mucmodel.Room) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(room, mucRoomKey(room.JID), tx)
+	})
+}
+
+// DeleteMucRoom deletes a room from storage, along with all its nick reservations.
+func (b *badgerDBMuc) DeleteMucRoom(_ context.Context, roomJID string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		if err := b.deleteKey(mucRoomKey(roomJID), tx); err != nil {
+			return err
+		}
+		return b.deleteKey(mucNickReservationsKey(roomJID), tx)
+	})
+}
+
+// FetchMucRoom retrieves from storage a room entity.
+func (b *badgerDBMuc) FetchMucRoom(_ context.Context, roomJID string) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&room, mucRoomKey(roomJID), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &room, nil
+}
+
+// FetchMucRooms retrieves from storage all rooms hosted by a multi-user chat service.
+func (b *badgerDBMuc) FetchMucRooms(_ context.Context, service string) ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKeyAndValue(mucRoomsPrefix, tx, func(k, v []byte) error {
+			if !strings.HasSuffix(string(k), "@"+service) {
+				return nil
+			}
+			var room mucmodel.Room
+			if err := serializer.Deserialize(v, &room); err != nil {
+				return err
+			}
+			rooms = append(rooms, room)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// UpsertMucNickReservation inserts a new nick reservation into storage,
+// or updates it in case user already reserved a nick within the room.
+func (b *badgerDBMuc) UpsertMucNickReservation(_ context.Context, reservation *mucmodel.NickReservation) error {
+	k := mucNickReservationsKey(reservation.RoomJID)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var reservations []mucmodel.NickReservation
+		if _, err := b.fetchEntities(&reservations, k, tx); err != nil {
+			return err
+		}
+		var updated bool
+		for i, r := range reservations {
+			if r.JID == reservation.JID {
+				reservations[i] = *reservation
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			reservations = append(reservations, *reservation)
+		}
+		return b.upsertEntities(&reservations, k, tx)
+	})
+}
+
+// DeleteMucNickReservation deletes a user nick reservation from storage.
+func (b *badgerDBMuc) DeleteMucNickReservation(_ context.Context, roomJID, jid string) error {
+	k := mucNickReservationsKey(roomJID)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var reservations, keep []mucmodel.NickReservation
+		if _, err := b.fetchEntities(&reservations, k, tx); err != nil {
+			return err
+		}
+		for _, r := range reservations {
+			if r.JID != jid {
+				keep = append(keep, r)
+			}
+		}
+		if len(keep) == 0 {
+			return b.deleteKey(k, tx)
+		}
+		return b.upsertEntities(&keep, k, tx)
+	})
+}
+
+// FetchMucNickReservations retrieves from storage all nick reservations associated to a room.
+func (b *badgerDBMuc) FetchMucNickReservations(_ context.Context, roomJID string) ([]mucmodel.NickReservation, error) {
+	var reservations []mucmodel.NickReservation
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&reservations, mucNickReservationsKey(roomJID), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+const mucRoomsPrefix = "mucRooms:"
+
+func mucRoomKey(roomJID string) string {
+	return mucRoomsPrefix + roomJID
+}
+
+func mucNickReservationsKey(roomJID string) string {
+	return "mucNickReservations:" + roomJID
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_MucRooms(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newMuc(h.db)
+
+	r1 := &mucmodel.Room{
+		JID:          "lobby@muc.jackal.im",
+		Config:       mucmodel.RoomConfig{Name: "Lobby", Persistent: true},
+		Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.Owner},
+	}
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r1))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), &mucmodel.Room{JID: "garden@muc.jackal.im"}))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), &mucmodel.Room{JID: "garden@conference.jackal.im"}))
+
+	r1.Subject = "Welcome!"
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r1))
+
+	room, err := s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, r1, room)
+
+	room, err = s.FetchMucRoom(context.Background(), "hall@muc.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	rooms, err := s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	require.Nil(t, s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im"))
+
+	room, _ = s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, room)
+	reservations, _ := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 0)
+}
+
+func TestBadgerDB_MucNickReservations(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newMuc(h.db)
+
+	reservations, err := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, reservations, 0)
+
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "romeo@jackal.im", Nick: "romeo"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "sxmpp"}))
+
+	reservations, err = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, reservations, 2)
+	require.Equal(t, "sxmpp", reservations[0].Nick)
+
+	require.Nil(t, s.DeleteMucNickReservation(context.Background(), "lobby@muc.jackal.im", "ortuman@jackal.im"))
+	reservations, _ = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 1)
+	require.Equal(t, "romeo", reservations[0].Nick)
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+type badgerDBOffline struct {
+	*badgerDBStorage
+}
+
+func newOffline(db *badger.DB) *badgerDBOffline {
+	return &badgerDBOffline{badgerDBStorage: newStorage(db)}
+}
+
+// InsertOfflineMessage inserts a new message element into user's offline queue.
+func (b *badgerDBOffline) InsertOfflineMessage(_ context.Context, message *offlinemodel.Message, username, domain string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		messages, err := b.fetchOfflineMessages(username, domain, tx)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, *message)
+		return b.upsertEntities(&messages, offlineMessageKey(username, domain), tx)
+	})
+}
+
+// CountOfflineMessages returns current length of user's offline queue.
+func (b *badgerDBOffline) CountOfflineMessages(ctx context.Context, username, domain string) (int, error) {
+	messages, err := b.FetchOfflineMessages(ctx, username, domain)
+	if err != nil {
+		return 0, err
+	}
+	return len(messages), nil
+}
+
+// FetchOfflineMessagesSize returns the total size in bytes of user's offline queue messages.
+func (b *badgerDBOffline) FetchOfflineMessagesSize(ctx context.Context, username, domain string) (int, error) {
+	messages, err := b.FetchOfflineMessages(ctx, username, domain)
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	for i := range messages {
+		size += messages[i].Size()
+	}
+	return size, nil
+}
+
+// FetchOfflineMessages retrieves from storage current user offline queue.
+func (b *badgerDBOffline) FetchOfflineMessages(_ context.Context, username, domain string) ([]offlinemodel.Message, error) {
+	var messages []offlinemodel.Message
+	if err := b.inTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		messages, fnErr = b.fetchOfflineMessages(username, domain, tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteOfflineMessagesByID removes from user's offline queue those messages identified by ids.
+func (b *badgerDBOffline) DeleteOfflineMessagesByID(_ context.Context, ids []string, username, domain string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		messages, err := b.fetchOfflineMessages(username, domain, tx)
+		if err != nil {
+			return err
+		}
+		var res []offlinemodel.Message
+		for _, msg := range messages {
+			if !containsID(ids, msg.ID) {
+				res = append(res, msg)
+			}
+		}
+		if len(res) == 0 {
+			return b.deleteKey(offlineMessageKey(username, domain), tx)
+		}
+		return b.upsertEntities(&res, offlineMessageKey(username, domain), tx)
+	})
+}
+
+// DeleteOfflineMessages clears a user offline queue.
+func (b *badgerDBOffline) DeleteOfflineMessages(_ context.Context, username, domain string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.deleteKey(offlineMessageKey(username, domain), tx)
+	})
+}
+
+// DeleteExpiredOfflineMessages removes from every offline queue those messages expired at a given time,
+// returning them.
+func (b *badgerDBOffline) DeleteExpiredOfflineMessages(_ context.Context, at time.Time) ([]offlinemodel.Message, error) {
+	var expired []offlinemodel.Message
+	if err := b.inTransaction(func(tx *badger.Txn) error {
+		updated := make(map[string][]offlinemodel.Message)
+		if err := b.forEachKeyAndValue(offlineMessagesPrefix, tx, func(k, v []byte) error {
+			var messages, keep []offlinemodel.Message
+			if err := serializer.DeserializeSlice(v, &messages); err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				if msg.IsExpired(at) {
+					expired = append(expired, msg)
+				} else {
+					keep = append(keep, msg)
+				}
+			}
+			if len(keep) != len(messages) {
+				updated[string(k)] = keep
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for k, messages := range updated {
+			if len(messages) == 0 {
+				if err := b.deleteKey(k, tx); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := b.upsertEntities(&messages, k, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// fetchOfflineMessages returns user's offline queue.
+func (b *badgerDBOffline) fetchOfflineMessages(username, domain string, tx *badger.Txn) ([]offlinemodel.Message, error) {
+	var messages []offlinemodel.Message
+	if _, err := b.fetchEntities(&messages, offlineMessageKey(username, domain), tx); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func containsID(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+const offlineMessagesPrefix = "offlineQueue:"
+
+func offlineMessageKey(username, domain string) string {
+	return offlineMessagesPrefix + userKey(username, domain)
+}
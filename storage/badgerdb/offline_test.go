@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_InsertOfflineMessage(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+
+	require.Nil(t, s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im"))
+}
+
+func TestBadgerDB_CountOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, cnt)
+}
+
+func TestBadgerDB_FetchOfflineMessagesSize(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	size, _ := s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 2*len(m.String()), size)
+}
+
+func TestBadgerDB_FetchOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, len(elems))
+}
+
+func TestBadgerDB_DeleteOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	require.Nil(t, s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im"))
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 0, len(elems))
+}
+
+func TestBadgerDB_DeleteOfflineMessagesByID(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "1", Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "2", Message: m}, "sxmpp", "jackal.im")
+
+	require.Nil(t, s.DeleteOfflineMessagesByID(context.Background(), []string{"1"}, "sxmpp", "jackal.im"))
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, len(elems))
+	require.Equal(t, "2", elems[0].ID)
+
+	require.Nil(t, s.DeleteOfflineMessagesByID(context.Background(), []string{"2"}, "sxmpp", "jackal.im"))
+
+	elems, _ = s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 0, len(elems))
+}
+
+func TestBadgerDB_DeleteExpiredOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	now := time.Now()
+
+	s := newOffline(h.db)
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m1", ExpiresAt: now.Add(-time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m2", ExpiresAt: now.Add(time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m3", ExpiresAt: now.Add(-time.Minute), Message: m}, "noelia", "jackal.im")
+
+	expired, err := s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Nil(t, err)
+	require.Len(t, expired, 2)
+
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, msgs, 1)
+	require.Equal(t, "m2", msgs[0].ID)
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "noelia", "jackal.im")
+	require.Equal(t, 0, cnt)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	capsmodel "github.com/sxmpp/jackal/model/capabilities"
+	"github.com/sxmpp/jackal/model/serializer"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+const presencesPrefix = "presences:"
+
+type badgerDBPresences struct {
+	*badgerDBStorage
+}
+
+func newPresences(db *badger.DB) *badgerDBPresences {
+	return &badgerDBPresences{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertPresence inserts or updates a presence and links it to certain allocation.
+func (b *badgerDBPresences) UpsertPresence(_ context.Context, presence *xmpp.Presence, jid *jid.JID, _ string) (inserted bool, err error) {
+	err = b.inTransaction(func(tx *badger.Txn) error {
+		k := presenceKey(jid)
+		ok, fnErr := b.keyExists(k, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		inserted = !ok
+		return b.upsertEntity(presence, k, tx)
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+// FetchPresence retrieves from storage a concrete registered presence.
+func (b *badgerDBPresences) FetchPresence(_ context.Context, jid *jid.JID) (*capsmodel.PresenceCaps, error) {
+	var pCaps *capsmodel.PresenceCaps
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		v, fnErr := b.getVal(presenceKey(jid), tx)
+		if fnErr != nil || v == nil {
+			return fnErr
+		}
+		pCaps, fnErr = b.deserializePresence(v, tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return pCaps, nil
+}
+
+// FetchPresencesMatchingJID retrives all storage presences matching a certain JID
+func (b *badgerDBPresences) FetchPresencesMatchingJID(ctx context.Context, j *jid.JID) ([]capsmodel.PresenceCaps, error) {
+	if j.IsFullWithUser() {
+		pCaps, err := b.FetchPresence(ctx, j)
+		if err != nil {
+			return nil, err
+		}
+		if pCaps == nil {
+			return nil, nil
+		}
+		return []capsmodel.PresenceCaps{*pCaps}, nil
+	}
+	usePrefix := j.IsBare()
+	useSuffix := j.IsFullWithServer()
+
+	var res []capsmodel.PresenceCaps
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKeyAndValue(presencesPrefix, tx, func(k, v []byte) error {
+			kJID, err := jid.NewWithString(string(k[len(presencesPrefix):]), true)
+			if err != nil {
+				return nil // skip malformed key
+			}
+			if usePrefix {
+				if !j.MatchesWithOptions(kJID, jid.MatchesBare) {
+					return nil
+				}
+			} else if useSuffix {
+				if !j.MatchesWithOptions(kJID, jid.MatchesDomain|jid.MatchesResource) {
+					return nil
+				}
+			} else if !j.MatchesWithOptions(kJID, jid.MatchesDomain) {
+				return nil
+			}
+			pCaps, err := b.deserializePresence(v, tx)
+			if err != nil {
+				return err
+			}
+			res = append(res, *pCaps)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeletePresence removes from storage a concrete registered presence.
+func (b *badgerDBPresences) DeletePresence(_ context.Context, jid *jid.JID) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.deleteKey(presenceKey(jid), tx)
+	})
+}
+
+// DeleteAllocationPresences removes from storage all presences associated to a given allocation.
+// Since BadgerDB storage is not cluster compatible all presences belong to the same allocation.
+func (b *badgerDBPresences) DeleteAllocationPresences(ctx context.Context, _ string) error {
+	return b.ClearPresences(ctx)
+}
+
+// ClearPresences wipes out all storage presences.
+func (b *badgerDBPresences) ClearPresences(_ context.Context) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.deletePrefix(presencesPrefix, tx)
+	})
+}
+
+// UpsertCapabilities inserts capabilities associated to a node+ver pair, or updates them if previously inserted..
+func (b *badgerDBPresences) UpsertCapabilities(_ context.Context, caps *capsmodel.Capabilities) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(caps, capabilitiesKey(caps.Node, caps.Ver), tx)
+	})
+}
+
+// FetchCapabilities fetches capabilities associated to a give node and ver.
+func (b *badgerDBPresences) FetchCapabilities(_ context.Context, node, ver string) (*capsmodel.Capabilities, error) {
+	var caps capsmodel.Capabilities
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&caps, capabilitiesKey(node, ver), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &caps, nil
+}
+
+func (b *badgerDBPresences) deserializePresence(v []byte, tx *badger.Txn) (*capsmodel.PresenceCaps, error) {
+	var pCaps capsmodel.PresenceCaps
+	var presence xmpp.Presence
+
+	if err := serializer.Deserialize(v, &presence); err != nil {
+		return nil, err
+	}
+	pCaps.Presence = &presence
+	if c := presence.Capabilities(); c != nil {
+		var caps capsmodel.Capabilities
+		ok, err := b.fetchEntity(&caps, capabilitiesKey(c.Node, c.Ver), tx)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pCaps.Caps = &caps
+		}
+	}
+	return &pCaps, nil
+}
+
+func presenceKey(jid *jid.JID) string {
+	return presencesPrefix + jid.String()
+}
+
+func capabilitiesKey(node, ver string) string {
+	return "capabilities:" + node + ":" + ver
+}
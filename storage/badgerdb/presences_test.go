@@ -0,0 +1,97 @@
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	capsmodel "github.com/sxmpp/jackal/model/capabilities"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_FetchPresencesMatchingJID(t *testing.T) {
+	const allocID = "1234"
+
+	j1, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+	j2, _ := jid.NewWithString("sxmpp@jackal.im/yard", true)
+	j3, _ := jid.NewWithString("noelia@jackal.im/yard", true)
+	j4, _ := jid.NewWithString("boss@jabber.org/balcony", true)
+
+	p1 := xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType)
+	p2 := xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType)
+	p3 := xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType)
+	p4 := xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newPresences(h.db)
+	ok, err := s.UpsertPresence(context.Background(), p1, j1, allocID)
+	require.True(t, ok)
+	require.Nil(t, err)
+
+	ok, err = s.UpsertPresence(context.Background(), p2, j2, allocID)
+	require.True(t, ok)
+	require.Nil(t, err)
+
+	ok, err = s.UpsertPresence(context.Background(), p3, j3, allocID)
+	require.True(t, ok)
+	require.Nil(t, err)
+
+	ok, err = s.UpsertPresence(context.Background(), p4, j4, allocID)
+	require.True(t, ok)
+	require.Nil(t, err)
+
+	// updating presence
+	ok, err = s.UpsertPresence(context.Background(), p1, j1, allocID)
+	require.False(t, ok)
+	require.Nil(t, err)
+
+	mJID, _ := jid.NewWithString("jackal.im", true)
+	presences, _ := s.FetchPresencesMatchingJID(context.Background(), mJID)
+	require.Len(t, presences, 3)
+
+	mJID, _ = jid.NewWithString("jackal.im/yard", true)
+	presences, _ = s.FetchPresencesMatchingJID(context.Background(), mJID)
+	require.Len(t, presences, 2)
+
+	mJID, _ = jid.NewWithString("jabber.org", true)
+	presences, _ = s.FetchPresencesMatchingJID(context.Background(), mJID)
+	require.Len(t, presences, 1)
+
+	_ = s.DeletePresence(context.Background(), j2)
+	mJID, _ = jid.NewWithString("jackal.im/yard", true)
+	presences, _ = s.FetchPresencesMatchingJID(context.Background(), mJID)
+	require.Len(t, presences, 1)
+
+	_ = s.ClearPresences(context.Background())
+	mJID, _ = jid.NewWithString("jackal.im", true)
+	presences, _ = s.FetchPresencesMatchingJID(context.Background(), mJID)
+	require.Len(t, presences, 0)
+}
+
+func TestBadgerDB_InsertCapabilities(t *testing.T) {
+	caps := capsmodel.Capabilities{Node: "n1", Ver: "1234A", Features: []string{"ns"}}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newPresences(h.db)
+	err := s.UpsertCapabilities(context.Background(), &caps)
+	require.Nil(t, err)
+}
+
+func TestBadgerDB_FetchCapabilities(t *testing.T) {
+	caps := capsmodel.Capabilities{Node: "n1", Ver: "1234A", Features: []string{"ns"}}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newPresences(h.db)
+	_ = s.UpsertCapabilities(context.Background(), &caps)
+
+	cs, _ := s.FetchCapabilities(context.Background(), "n1", "1234B")
+	require.Nil(t, cs)
+
+	cs, _ = s.FetchCapabilities(context.Background(), "n1", "1234A")
+	require.NotNil(t, cs)
+}
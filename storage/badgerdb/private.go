@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type badgerDBPrivate struct {
+	*badgerDBStorage
+}
+
+func newPrivate(db *badger.DB) *badgerDBPrivate {
+	return &badgerDBPrivate{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertPrivateXML inserts a new private element into storage, or updates it in case it's been previously inserted.
+func (b *badgerDBPrivate) UpsertPrivateXML(_ context.Context, privateXML []xmpp.XElement, namespace string, username, domain string) error {
+	var priv []xmpp.Element
+
+	// convert to concrete type
+	for _, el := range privateXML {
+		priv = append(priv, *xmpp.NewElementFromElement(el))
+	}
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntities(&priv, privateStorageKey(username, domain, namespace), tx)
+	})
+}
+
+// FetchPrivateXML retrieves from storage a private element.
+func (b *badgerDBPrivate) FetchPrivateXML(_ context.Context, namespace string, username, domain string) ([]xmpp.XElement, error) {
+	var priv []xmpp.Element
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&priv, privateStorageKey(username, domain, namespace), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	var ret []xmpp.XElement
+	for i := range priv {
+		ret = append(ret, &priv[i])
+	}
+	return ret, nil
+}
+
+// FetchPrivateXMLNamespaces retrieves from storage the namespaces of every private element stored by a user.
+func (b *badgerDBPrivate) FetchPrivateXMLNamespaces(_ context.Context, username, domain string) ([]string, error) {
+	var namespaces []string
+	prefix := privateStorageKey(username, domain, "")
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKey(prefix, tx, func(k []byte) error {
+			namespaces = append(namespaces, strings.TrimPrefix(string(k), prefix))
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+func privateStorageKey(username, domain, namespace string) string {
+	return "privateElements:" + userKey(username, domain) + ":" + namespace
+}
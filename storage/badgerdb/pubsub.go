@@ -0,0 +1,333 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+const pubSubNodesPrefix = "pubSubNodes:"
+
+type badgerDBPubSub struct {
+	*badgerDBStorage
+}
+
+func newPubSub(db *badger.DB) *badgerDBPubSub {
+	return &badgerDBPubSub{badgerDBStorage: newStorage(db)}
+}
+
+// FetchHosts returns all host identifiers.
+func (b *badgerDBPubSub) FetchHosts(_ context.Context) ([]string, error) {
+	var hosts []string
+	hostSet := make(map[string]struct{})
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachNode(pubSubNodesPrefix, tx, func(node *pubsubmodel.Node) error {
+			if _, ok := hostSet[node.Host]; ok {
+				return nil
+			}
+			hostSet[node.Host] = struct{}{}
+			hosts = append(hosts, node.Host)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// UpsertNode inserts a new pubsub node entity into storage, or updates it if previously inserted.
+func (b *badgerDBPubSub) UpsertNode(_ context.Context, node *pubsubmodel.Node) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(node, pubSubNodeKey(node.Host, node.Name), tx)
+	})
+}
+
+// FetchNode retrieves from storage a pubsub node entity.
+func (b *badgerDBPubSub) FetchNode(_ context.Context, host, name string) (*pubsubmodel.Node, error) {
+	var node pubsubmodel.Node
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&node, pubSubNodeKey(host, name), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &node, nil
+}
+
+// FetchNodes retrieves from storage all node entities associated with a host.
+func (b *badgerDBPubSub) FetchNodes(_ context.Context, host string) ([]pubsubmodel.Node, error) {
+	var nodes []pubsubmodel.Node
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachNode(pubSubNodesPrefix+host+":", tx, func(node *pubsubmodel.Node) error {
+			if node.Host == host {
+				nodes = append(nodes, *node)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// FetchSubscribedNodes retrieves from storage all nodes to which a given jid is subscribed.
+func (b *badgerDBPubSub) FetchSubscribedNodes(_ context.Context, jid string) ([]pubsubmodel.Node, error) {
+	var nodes []pubsubmodel.Node
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachNode(pubSubNodesPrefix, tx, func(node *pubsubmodel.Node) error {
+			var subs []pubsubmodel.Subscription
+			if _, err := b.fetchEntities(&subs, pubSubSubscriptionsKey(node.Host, node.Name), tx); err != nil {
+				return err
+			}
+			for _, sub := range subs {
+				if sub.JID == jid && sub.Subscription == pubsubmodel.Subscribed {
+					nodes = append(nodes, *node)
+					break
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// DeleteNode deletes a pubsub node from storage.
+func (b *badgerDBPubSub) DeleteNode(_ context.Context, host, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		for _, k := range []string{
+			pubSubNodeKey(host, name),
+			pubSubItemsKey(host, name),
+			pubSubAffiliationsKey(host, name),
+			pubSubSubscriptionsKey(host, name),
+		} {
+			if err := b.deleteKey(k, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpsertNodeItem inserts a new pubsub node item entity into storage, or updates it if previously inserted.
+func (b *badgerDBPubSub) UpsertNodeItem(_ context.Context, item *pubsubmodel.Item, host, name string, maxNodeItems int) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var items []pubsubmodel.Item
+		if _, err := b.fetchEntities(&items, pubSubItemsKey(host, name), tx); err != nil {
+			return err
+		}
+		var updated bool
+		for i, itm := range items {
+			if itm.ID == item.ID {
+				items[i] = *item
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			items = append(items, *item)
+		}
+		if len(items) > maxNodeItems {
+			items = items[len(items)-maxNodeItems:] // remove oldest elements
+		}
+		return b.upsertEntities(&items, pubSubItemsKey(host, name), tx)
+	})
+}
+
+// FetchNodeItems retrieves all items associated to a node.
+func (b *badgerDBPubSub) FetchNodeItems(_ context.Context, host, name string) ([]pubsubmodel.Item, error) {
+	var items []pubsubmodel.Item
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&items, pubSubItemsKey(host, name), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FetchNodeItemsWithIDs retrieves all items matching any of the passed identifiers.
+func (b *badgerDBPubSub) FetchNodeItemsWithIDs(ctx context.Context, host, name string, identifiers []string) ([]pubsubmodel.Item, error) {
+	items, err := b.FetchNodeItems(ctx, host, name)
+	if err != nil {
+		return nil, err
+	}
+	identifiersSet := make(map[string]struct{}, len(identifiers))
+	for _, id := range identifiers {
+		identifiersSet[id] = struct{}{}
+	}
+	var filteredItems []pubsubmodel.Item
+	for _, itm := range items {
+		if _, ok := identifiersSet[itm.ID]; ok {
+			filteredItems = append(filteredItems, itm)
+		}
+	}
+	return filteredItems, nil
+}
+
+// FetchNodeLastItem retrieves last published node item.
+func (b *badgerDBPubSub) FetchNodeLastItem(ctx context.Context, host, name string) (*pubsubmodel.Item, error) {
+	items, err := b.FetchNodeItems(ctx, host, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return &items[len(items)-1], nil
+}
+
+// UpsertNodeAffiliation inserts a new pubsub node affiliation into storage, or updates it if previously inserted.
+func (b *badgerDBPubSub) UpsertNodeAffiliation(_ context.Context, affiliation *pubsubmodel.Affiliation, host, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var affiliations []pubsubmodel.Affiliation
+		if _, err := b.fetchEntities(&affiliations, pubSubAffiliationsKey(host, name), tx); err != nil {
+			return err
+		}
+		var updated bool
+		for i, aff := range affiliations {
+			if aff.JID == affiliation.JID {
+				affiliations[i] = *affiliation
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			affiliations = append(affiliations, *affiliation)
+		}
+		return b.upsertEntities(&affiliations, pubSubAffiliationsKey(host, name), tx)
+	})
+}
+
+// FetchNodeAffiliation retrieves a concrete node affiliation from storage.
+func (b *badgerDBPubSub) FetchNodeAffiliation(ctx context.Context, host, name, jid string) (*pubsubmodel.Affiliation, error) {
+	affiliations, err := b.FetchNodeAffiliations(ctx, host, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, aff := range affiliations {
+		if aff.JID == jid {
+			return &aff, nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchNodeAffiliations retrieves all affiliations associated to a node.
+func (b *badgerDBPubSub) FetchNodeAffiliations(_ context.Context, host, name string) ([]pubsubmodel.Affiliation, error) {
+	var affiliations []pubsubmodel.Affiliation
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&affiliations, pubSubAffiliationsKey(host, name), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return affiliations, nil
+}
+
+// DeleteNodeAffiliation deletes a pubsub node affiliation from storage.
+func (b *badgerDBPubSub) DeleteNodeAffiliation(_ context.Context, jid, host, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var affiliations []pubsubmodel.Affiliation
+		if _, err := b.fetchEntities(&affiliations, pubSubAffiliationsKey(host, name), tx); err != nil {
+			return err
+		}
+		for i, aff := range affiliations {
+			if aff.JID == jid {
+				affiliations = append(affiliations[:i], affiliations[i+1:]...)
+				return b.upsertEntities(&affiliations, pubSubAffiliationsKey(host, name), tx)
+			}
+		}
+		return nil
+	})
+}
+
+// UpsertNodeSubscription inserts a new pubsub node subscription into storage, or updates it if previously inserted.
+func (b *badgerDBPubSub) UpsertNodeSubscription(_ context.Context, subscription *pubsubmodel.Subscription, host, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var subscriptions []pubsubmodel.Subscription
+		if _, err := b.fetchEntities(&subscriptions, pubSubSubscriptionsKey(host, name), tx); err != nil {
+			return err
+		}
+		var updated bool
+		for i, sub := range subscriptions {
+			if sub.JID == subscription.JID {
+				subscriptions[i] = *subscription
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			subscriptions = append(subscriptions, *subscription)
+		}
+		return b.upsertEntities(&subscriptions, pubSubSubscriptionsKey(host, name), tx)
+	})
+}
+
+// FetchNodeSubscriptions retrieves all subscriptions associated to a node.
+func (b *badgerDBPubSub) FetchNodeSubscriptions(_ context.Context, host, name string) ([]pubsubmodel.Subscription, error) {
+	var subscriptions []pubsubmodel.Subscription
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&subscriptions, pubSubSubscriptionsKey(host, name), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteNodeSubscription deletes a pubsub node subscription from storage.
+func (b *badgerDBPubSub) DeleteNodeSubscription(_ context.Context, jid, host, name string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var subscriptions []pubsubmodel.Subscription
+		if _, err := b.fetchEntities(&subscriptions, pubSubSubscriptionsKey(host, name), tx); err != nil {
+			return err
+		}
+		for i, sub := range subscriptions {
+			if sub.JID == jid {
+				subscriptions = append(subscriptions[:i], subscriptions[i+1:]...)
+				return b.upsertEntities(&subscriptions, pubSubSubscriptionsKey(host, name), tx)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *badgerDBPubSub) forEachNode(prefix string, tx *badger.Txn, f func(node *pubsubmodel.Node) error) error {
+	return b.forEachKeyAndValue(prefix, tx, func(_, v []byte) error {
+		var node pubsubmodel.Node
+		if err := serializer.Deserialize(v, &node); err != nil {
+			return err
+		}
+		return f(&node)
+	})
+}
+
+func pubSubNodeKey(host, name string) string {
+	return pubSubNodesPrefix + host + ":" + name
+}
+
+func pubSubItemsKey(host, name string) string {
+	return "pubSubItems:" + host + ":" + name
+}
+
+func pubSubAffiliationsKey(host, name string) string {
+	return "pubSubAffiliations:" + host + ":" + name
+}
+
+func pubSubSubscriptionsKey(host, name string) string {
+	return "pubSubSubscriptions:" + host + ":" + name
+}
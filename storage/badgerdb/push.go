@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+)
+
+type badgerDBPush struct {
+	*badgerDBStorage
+}
+
+func newPush(db *badger.DB) *badgerDBPush {
+	return &badgerDBPush{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertPushRegistration inserts a new push registration into storage,
+// or updates it in case it's been previously inserted.
+func (b *badgerDBPush) UpsertPushRegistration(_ context.Context, reg *pushmodel.Registration) error {
+	k := pushRegistrationsKey(reg.Username, reg.Domain)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var regs []pushmodel.Registration
+		if _, err := b.fetchEntities(&regs, k, tx); err != nil {
+			return err
+		}
+		var updated bool
+		for i, r := range regs {
+			if r.JID == reg.JID && r.Node == reg.Node {
+				regs[i] = *reg
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			regs = append(regs, *reg)
+		}
+		return b.upsertEntities(&regs, k, tx)
+	})
+}
+
+// DeletePushRegistrations removes those user push registrations associated to an app server JID.
+// In case node is empty every registration associated to the app server will be removed.
+func (b *badgerDBPush) DeletePushRegistrations(_ context.Context, username, domain, jid, node string) error {
+	k := pushRegistrationsKey(username, domain)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var regs, keep []pushmodel.Registration
+		if _, err := b.fetchEntities(&regs, k, tx); err != nil {
+			return err
+		}
+		for _, r := range regs {
+			if r.JID == jid && (len(node) == 0 || r.Node == node) {
+				continue
+			}
+			keep = append(keep, r)
+		}
+		if len(keep) == 0 {
+			return b.deleteKey(k, tx)
+		}
+		return b.upsertEntities(&keep, k, tx)
+	})
+}
+
+// FetchPushRegistrations retrieves from storage all user push registrations.
+func (b *badgerDBPush) FetchPushRegistrations(_ context.Context, username, domain string) ([]pushmodel.Registration, error) {
+	var regs []pushmodel.Registration
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		_, fnErr := b.fetchEntities(&regs, pushRegistrationsKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+func pushRegistrationsKey(username, domain string) string {
+	return "pushRegistrations:" + userKey(username, domain)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_PushRegistrations(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newPush(h.db)
+
+	regs, err := s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, regs, 0)
+
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"}))
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n2"}))
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		JID:      "push.jackal.im",
+		Node:     "n1",
+		Options:  map[string]string{"secret": "1234"},
+	}))
+
+	regs, err = s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, regs, 2)
+	require.Equal(t, "1234", regs[0].Options["secret"])
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "jackal.im", "push.jackal.im", "n1"))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Len(t, regs, 1)
+	require.Equal(t, "n2", regs[0].Node)
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "jackal.im", "push.jackal.im", ""))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Len(t, regs, 0)
+}
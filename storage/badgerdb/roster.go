@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+)
+
+type badgerDBRoster struct {
+	*badgerDBStorage
+}
+
+func newRoster(db *badger.DB) *badgerDBRoster {
+	return &badgerDBRoster{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertRosterItem inserts a new roster item entity into storage, or updates it if previously inserted.
+func (b *badgerDBRoster) UpsertRosterItem(_ context.Context, ri *rostermodel.Item) (rostermodel.Version, error) {
+	var rv rostermodel.Version
+	user := userKey(ri.Username, ri.Domain)
+	if err := b.inTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		rv, fnErr = b.fetchRosterVersion(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		rv.Ver++
+		if err := b.upsertEntity(&rv, rosterVersionKey(user), tx); err != nil {
+			return err
+		}
+		ris, fnErr := b.fetchRosterItems(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		item := *ri
+		item.Ver = rv.Ver
+		for i, r := range ris {
+			if r.JID == ri.JID {
+				ris[i] = item
+				goto done
+			}
+		}
+		ris = append(ris, item)
+	done:
+		return b.upsertEntities(&ris, rosterItemsKey(user), tx)
+	}); err != nil {
+		return rostermodel.Version{}, err
+	}
+	return rv, nil
+}
+
+// DeleteRosterItem deletes a roster item entity from storage.
+func (b *badgerDBRoster) DeleteRosterItem(_ context.Context, username, domain, contact string) (rostermodel.Version, error) {
+	var rv rostermodel.Version
+	user := userKey(username, domain)
+	if err := b.inTransaction(func(tx *badger.Txn) error {
+		ris, fnErr := b.fetchRosterItems(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		for i, ri := range ris {
+			if ri.JID == contact {
+				ris = append(ris[:i], ris[i+1:]...)
+				if err := b.upsertEntities(&ris, rosterItemsKey(user), tx); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		rv, fnErr = b.fetchRosterVersion(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		rv.Ver++
+		rv.DeletionVer = rv.Ver
+		return b.upsertEntity(&rv, rosterVersionKey(user), tx)
+	}); err != nil {
+		return rostermodel.Version{}, err
+	}
+	return rv, nil
+}
+
+// FetchRosterItems retrieves from storage all roster item entities associated to a given user.
+func (b *badgerDBRoster) FetchRosterItems(_ context.Context, username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	var ris []rostermodel.Item
+	var rv rostermodel.Version
+
+	user := userKey(username, domain)
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ris, fnErr = b.fetchRosterItems(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		rv, fnErr = b.fetchRosterVersion(user, tx)
+		return fnErr
+	}); err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	return ris, rv, nil
+}
+
+// FetchRosterItemsInGroups retrieves from storage all roster item entities associated to a given user and a set of groups.
+func (b *badgerDBRoster) FetchRosterItemsInGroups(ctx context.Context, username, domain string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	ris, rv, err := b.FetchRosterItems(ctx, username, domain)
+	if err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	groupSet := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		groupSet[group] = struct{}{}
+	}
+	var res []rostermodel.Item
+	for _, ri := range ris {
+		for _, riGroup := range ri.Groups {
+			if _, ok := groupSet[riGroup]; ok {
+				res = append(res, ri)
+				break
+			}
+		}
+	}
+	return res, rv, nil
+}
+
+// FetchRosterItem retrieves from storage a roster item entity.
+func (b *badgerDBRoster) FetchRosterItem(_ context.Context, username, domain, contact string) (*rostermodel.Item, error) {
+	var ret *rostermodel.Item
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		ris, fnErr := b.fetchRosterItems(userKey(username, domain), tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		for i := range ris {
+			if ris[i].JID == contact {
+				ret = &ris[i]
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// UpsertRosterNotification inserts a new roster notification entity into storage, or updates it if previously inserted.
+func (b *badgerDBRoster) UpsertRosterNotification(_ context.Context, rn *rostermodel.Notification) error {
+	contact := userKey(rn.Contact, rn.Domain)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		rns, fnErr := b.fetchRosterNotifications(contact, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		for i, r := range rns {
+			if r.JID == rn.JID {
+				rns[i] = *rn
+				goto done
+			}
+		}
+		rns = append(rns, *rn)
+	done:
+		return b.upsertEntities(&rns, rosterNotificationsKey(contact), tx)
+	})
+}
+
+// DeleteRosterNotification deletes a roster notification entity from storage.
+func (b *badgerDBRoster) DeleteRosterNotification(_ context.Context, contact, domain, jid string) error {
+	user := userKey(contact, domain)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		rns, fnErr := b.fetchRosterNotifications(user, tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		for i, rn := range rns {
+			if rn.JID == jid {
+				rns = append(rns[:i], rns[i+1:]...)
+				return b.upsertEntities(&rns, rosterNotificationsKey(user), tx)
+			}
+		}
+		return nil
+	})
+}
+
+// FetchRosterNotification retrieves from storage a roster notification entity.
+func (b *badgerDBRoster) FetchRosterNotification(_ context.Context, contact, domain, jid string) (*rostermodel.Notification, error) {
+	var ret *rostermodel.Notification
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		rns, fnErr := b.fetchRosterNotifications(userKey(contact, domain), tx)
+		if fnErr != nil {
+			return fnErr
+		}
+		for i := range rns {
+			if rns[i].JID == jid {
+				ret = &rns[i]
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FetchRosterNotifications retrieves from storage all roster notifications associated to a given user.
+func (b *badgerDBRoster) FetchRosterNotifications(_ context.Context, contact, domain string) ([]rostermodel.Notification, error) {
+	var rns []rostermodel.Notification
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		rns, fnErr = b.fetchRosterNotifications(userKey(contact, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	return rns, nil
+}
+
+// FetchRosterGroups retrieves all groups associated to a user roster.
+func (b *badgerDBRoster) FetchRosterGroups(ctx context.Context, username, domain string) ([]string, error) {
+	ris, _, err := b.FetchRosterItems(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	groupSet := make(map[string]struct{})
+	for _, ri := range ris {
+		for _, group := range ri.Groups {
+			if _, ok := groupSet[group]; ok {
+				continue
+			}
+			groupSet[group] = struct{}{}
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (b *badgerDBRoster) fetchRosterItems(user string, tx *badger.Txn) ([]rostermodel.Item, error) {
+	var ris []rostermodel.Item
+	if _, err := b.fetchEntities(&ris, rosterItemsKey(user), tx); err != nil {
+		return nil, err
+	}
+	return ris, nil
+}
+
+func (b *badgerDBRoster) fetchRosterNotifications(contact string, tx *badger.Txn) ([]rostermodel.Notification, error) {
+	var rns []rostermodel.Notification
+	if _, err := b.fetchEntities(&rns, rosterNotificationsKey(contact), tx); err != nil {
+		return nil, err
+	}
+	return rns, nil
+}
+
+func (b *badgerDBRoster) fetchRosterVersion(user string, tx *badger.Txn) (rostermodel.Version, error) {
+	var rv rostermodel.Version
+	if _, err := b.fetchEntity(&rv, rosterVersionKey(user), tx); err != nil {
+		return rostermodel.Version{}, err
+	}
+	return rv, nil
+}
+
+func rosterItemsKey(user string) string {
+	return "rosterItems:" + user
+}
+
+func rosterVersionKey(user string) string {
+	return "rosterVersions:" + user
+}
+
+func rosterNotificationsKey(contact string) string {
+	return "rosterNotifications:" + contact
+}
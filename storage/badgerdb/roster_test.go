@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_InsertRosterItem(t *testing.T) {
+	g := []string{"general", "friends"}
+	ri := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact",
+		Name:         "a name",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          1,
+		Groups:       g,
+	}
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_, err := s.UpsertRosterItem(context.Background(), &ri)
+	require.Nil(t, err)
+	ri.Subscription = "to"
+	_, err = s.UpsertRosterItem(context.Background(), &ri)
+	require.Nil(t, err)
+}
+
+func TestBadgerDB_FetchRosterItem(t *testing.T) {
+	g := []string{"general", "friends"}
+	ri := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact",
+		Name:         "a name",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          1,
+		Groups:       g,
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_, _ = s.UpsertRosterItem(context.Background(), &ri)
+
+	ri3, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact2")
+	require.Nil(t, ri3)
+
+	ri4, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact")
+	require.NotNil(t, ri4)
+	require.Equal(t, "user", ri4.Username)
+	require.Equal(t, "contact", ri4.JID)
+}
+
+func TestBadgerDB_FetchRosterItems(t *testing.T) {
+	ri := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact@jackal.im",
+		Name:         "a name",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          1,
+		Groups:       []string{"general", "friends"},
+	}
+	ri2 := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact2@jackal.im",
+		Name:         "a name 2",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          2,
+		Groups:       []string{"general", "buddies"},
+	}
+	ri3 := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact3@jackal.im",
+		Name:         "a name 3",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          2,
+		Groups:       []string{"family", "friends"},
+	}
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_, _ = s.UpsertRosterItem(context.Background(), &ri)
+	_, _ = s.UpsertRosterItem(context.Background(), &ri2)
+	_, _ = s.UpsertRosterItem(context.Background(), &ri3)
+
+	ris, _, _ := s.FetchRosterItems(context.Background(), "user", "jackal.im")
+	require.Equal(t, 3, len(ris))
+	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", "jackal.im", []string{"friends"})
+	require.Equal(t, 2, len(ris))
+	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", "jackal.im", []string{"buddies"})
+	require.Equal(t, 1, len(ris))
+
+	gr, _ := s.FetchRosterGroups(context.Background(), "user", "jackal.im")
+	require.Len(t, gr, 4)
+
+	require.Contains(t, gr, "general")
+	require.Contains(t, gr, "friends")
+	require.Contains(t, gr, "family")
+	require.Contains(t, gr, "buddies")
+}
+
+func TestBadgerDB_DeleteRosterItem(t *testing.T) {
+	g := []string{"general", "friends"}
+	ri := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact",
+		Name:         "a name",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          1,
+		Groups:       g,
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_, _ = s.UpsertRosterItem(context.Background(), &ri)
+
+	gr, _ := s.FetchRosterGroups(context.Background(), "user", "jackal.im")
+	require.Len(t, gr, 2)
+
+	require.Contains(t, gr, "general")
+	require.Contains(t, gr, "friends")
+
+	_, err := s.DeleteRosterItem(context.Background(), "user", "jackal.im", "contact")
+	require.Nil(t, err)
+	_, err = s.DeleteRosterItem(context.Background(), "user2", "jackal.im", "contact")
+	require.Nil(t, err) // delete not existing roster item...
+
+	ri2, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact")
+	require.Nil(t, ri2)
+
+	gr, _ = s.FetchRosterGroups(context.Background(), "user", "jackal.im")
+	require.Len(t, gr, 0)
+}
+
+func TestBadgerDB_InsertRosterNotification(t *testing.T) {
+	rn := rostermodel.Notification{
+		Contact:  "sxmpp",
+		Domain:   "jackal.im",
+		JID:      "romeo@jackal.im",
+		Presence: &xmpp.Presence{},
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	require.Nil(t, s.UpsertRosterNotification(context.Background(), &rn))
+}
+
+func TestBadgerDB_FetchRosterNotifications(t *testing.T) {
+	rn1 := rostermodel.Notification{
+		Contact:  "romeo",
+		Domain:   "jackal.im",
+		JID:      "sxmpp@jackal.im",
+		Presence: &xmpp.Presence{},
+	}
+	rn2 := rostermodel.Notification{
+		Contact:  "romeo",
+		Domain:   "jackal.im",
+		JID:      "sxmpp2@jackal.im",
+		Presence: &xmpp.Presence{},
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_ = s.UpsertRosterNotification(context.Background(), &rn1)
+	_ = s.UpsertRosterNotification(context.Background(), &rn2)
+
+	from, _ := jid.NewWithString("sxmpp2@jackal.im", true)
+	to, _ := jid.NewWithString("romeo@jackal.im", true)
+	rn2.Presence = xmpp.NewPresence(from, to, xmpp.SubscribeType)
+	_ = s.UpsertRosterNotification(context.Background(), &rn2)
+
+	rns, err := s.FetchRosterNotifications(context.Background(), "romeo", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rns))
+	require.Equal(t, "sxmpp@jackal.im", rns[0].JID)
+	require.Equal(t, "sxmpp2@jackal.im", rns[1].JID)
+}
+
+func TestBadgerDB_DeleteRosterNotification(t *testing.T) {
+	rn1 := rostermodel.Notification{
+		Contact:  "sxmpp",
+		Domain:   "jackal.im",
+		JID:      "romeo@jackal.im",
+		Presence: &xmpp.Presence{},
+	}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newRoster(h.db)
+	_ = s.UpsertRosterNotification(context.Background(), &rn1)
+
+	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp", "jackal.im", "romeo@jackal.im"))
+
+	rns, err := s.FetchRosterNotifications(context.Background(), "romeo", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(rns))
+
+	// delete not existing roster notification...
+	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp2", "jackal.im", "romeo@jackal.im"))
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+// badgerDBStorage represents a BadgerDB storage sub system.
+type badgerDBStorage struct {
+	db *badger.DB
+}
+
+func newStorage(db *badger.DB) *badgerDBStorage {
+	return &badgerDBStorage{db: db}
+}
+
+// inTransaction runs f within a read-write transaction, retrying it in case of conflict.
+func (b *badgerDBStorage) inTransaction(f func(tx *badger.Txn) error) error {
+	for {
+		err := b.db.Update(f)
+		if err == badger.ErrConflict {
+			continue
+		}
+		return err
+	}
+}
+
+// inReadTransaction runs f within a read-only transaction.
+func (b *badgerDBStorage) inReadTransaction(f func(tx *badger.Txn) error) error {
+	return b.db.View(f)
+}
+
+func (b *badgerDBStorage) upsertEntity(entity serializer.Serializer, key string, tx *badger.Txn) error {
+	bts, err := serializer.Serialize(entity)
+	if err != nil {
+		return err
+	}
+	return tx.Set([]byte(key), bts)
+}
+
+func (b *badgerDBStorage) upsertEntities(entities interface{}, key string, tx *badger.Txn) error {
+	bts, err := serializer.SerializeSlice(entities)
+	if err != nil {
+		return err
+	}
+	return tx.Set([]byte(key), bts)
+}
+
+func (b *badgerDBStorage) fetchEntity(entity serializer.Deserializer, key string, tx *badger.Txn) (bool, error) {
+	bts, err := b.getVal(key, tx)
+	if err != nil {
+		return false, err
+	}
+	if bts == nil {
+		return false, nil
+	}
+	if err := serializer.Deserialize(bts, entity); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *badgerDBStorage) fetchEntities(entities interface{}, key string, tx *badger.Txn) (bool, error) {
+	bts, err := b.getVal(key, tx)
+	if err != nil {
+		return false, err
+	}
+	if bts == nil {
+		return false, nil
+	}
+	if err := serializer.DeserializeSlice(bts, entities); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *badgerDBStorage) deleteKey(key string, tx *badger.Txn) error {
+	return tx.Delete([]byte(key))
+}
+
+func (b *badgerDBStorage) deletePrefix(prefix string, tx *badger.Txn) error {
+	var keys [][]byte
+	if err := b.forEachKey(prefix, tx, func(k []byte) error {
+		keys = append(keys, k)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := tx.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *badgerDBStorage) keyExists(key string, tx *badger.Txn) (bool, error) {
+	_, err := tx.Get([]byte(key))
+	switch err {
+	case nil:
+		return true, nil
+	case badger.ErrKeyNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (b *badgerDBStorage) getVal(key string, tx *badger.Txn) ([]byte, error) {
+	item, err := tx.Get([]byte(key))
+	switch err {
+	case nil:
+		return item.ValueCopy(nil)
+	case badger.ErrKeyNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *badgerDBStorage) forEachKey(prefix string, tx *badger.Txn, f func(k []byte) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	it := tx.NewIterator(opts)
+	defer it.Close()
+
+	p := []byte(prefix)
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		if err := f(it.Item().KeyCopy(nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *badgerDBStorage) forEachKeyAndValue(prefix string, tx *badger.Txn, f func(k, v []byte) error) error {
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	p := []byte(prefix)
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := f(item.KeyCopy(nil), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userKey returns the key prefix used to store entities associated to a username and domain pair.
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/model"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/model/serializer"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type badgerDBUser struct {
+	*badgerDBStorage
+}
+
+func newUser(db *badger.DB) *badgerDBUser {
+	return &badgerDBUser{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertUser inserts a new user entity into storage, or updates it in case it's been previously inserted.
+func (b *badgerDBUser) UpsertUser(_ context.Context, user *model.User) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(user, usersKey(user.Username, user.Domain), tx)
+	})
+}
+
+// UpdateLastPresence updates last received presence of a previously inserted user.
+func (b *badgerDBUser) UpdateLastPresence(_ context.Context, username, domain string, presence *xmpp.Presence) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		var user model.User
+		ok, err := b.fetchEntity(&user, usersKey(username, domain), tx)
+		if err != nil || !ok {
+			return err
+		}
+		user.LastPresence = presence
+		return b.upsertEntity(&user, usersKey(username, domain), tx)
+	})
+}
+
+// DeleteUser deletes a user entity from storage, along with every entity associated to it.
+func (b *badgerDBUser) DeleteUser(_ context.Context, username, domain string) error {
+	user := userKey(username, domain)
+	return b.inTransaction(func(tx *badger.Txn) error {
+		// account owned entities
+		for _, k := range []string{
+			rosterItemsKey(user),
+			rosterVersionKey(user),
+			rosterNotificationsKey(user),
+			presencesPrefix + user,
+			vCardKey(username, domain),
+			blockListItemsKey(username, domain),
+			offlineMessageKey(username, domain),
+			archivePreferencesKey(username, domain),
+			pushRegistrationsKey(username, domain),
+		} {
+			if err := b.deleteKey(k, tx); err != nil {
+				return err
+			}
+		}
+		for _, prefix := range []string{
+			presencesPrefix + user + "/",
+			privateStorageKey(username, domain, ""),
+			archiveMessagesKeyPrefix(username, domain),
+			pubSubNodesPrefix + user + ":",
+			pubSubItemsKey(user, ""),
+			pubSubAffiliationsKey(user, ""),
+			pubSubSubscriptionsKey(user, ""),
+		} {
+			if err := b.deletePrefix(prefix, tx); err != nil {
+				return err
+			}
+		}
+		// references held by other accounts
+		if err := b.deleteRosterReferences(user, tx); err != nil {
+			return err
+		}
+		if err := b.deletePubSubReferences(user, tx); err != nil {
+			return err
+		}
+		if err := b.deleteMucReferences(user, tx); err != nil {
+			return err
+		}
+		return b.deleteKey(usersKey(username, domain), tx)
+	})
+}
+
+// FetchUser retrieves from storage a user entity.
+func (b *badgerDBUser) FetchUser(_ context.Context, username, domain string) (*model.User, error) {
+	var usr model.User
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&usr, usersKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &usr, nil
+}
+
+// UserExists returns whether or not a user exists within storage.
+func (b *badgerDBUser) UserExists(_ context.Context, username, domain string) (bool, error) {
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.keyExists(usersKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func (b *badgerDBUser) deleteRosterReferences(jid string, tx *badger.Txn) error {
+	updates := make(map[string]interface{})
+	if err := b.forEachKeyAndValue(rosterItemsKey(""), tx, func(k, v []byte) error {
+		var ris, keep []rostermodel.Item
+		if err := serializer.DeserializeSlice(v, &ris); err != nil {
+			return err
+		}
+		for _, ri := range ris {
+			if ri.JID != jid {
+				keep = append(keep, ri)
+			}
+		}
+		if len(keep) != len(ris) {
+			updates[string(k)] = &keep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, ris := range updates {
+		if err := b.upsertEntities(ris, k, tx); err != nil {
+			return err
+		}
+		// bump roster version
+		var rv rostermodel.Version
+		vk := rosterVersionKey(strings.TrimPrefix(k, rosterItemsKey("")))
+		if _, err := b.fetchEntity(&rv, vk, tx); err != nil {
+			return err
+		}
+		rv.Ver++
+		rv.DeletionVer = rv.Ver
+		if err := b.upsertEntity(&rv, vk, tx); err != nil {
+			return err
+		}
+	}
+	updates = make(map[string]interface{})
+	if err := b.forEachKeyAndValue(rosterNotificationsKey(""), tx, func(k, v []byte) error {
+		var rns, keep []rostermodel.Notification
+		if err := serializer.DeserializeSlice(v, &rns); err != nil {
+			return err
+		}
+		for _, rn := range rns {
+			if rn.JID != jid {
+				keep = append(keep, rn)
+			}
+		}
+		if len(keep) != len(rns) {
+			updates[string(k)] = &keep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, rns := range updates {
+		if err := b.upsertEntities(rns, k, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *badgerDBUser) deletePubSubReferences(jid string, tx *badger.Txn) error {
+	updates := make(map[string]interface{})
+	if err := b.forEachKeyAndValue("pubSubAffiliations:", tx, func(k, v []byte) error {
+		var affiliations, keep []pubsubmodel.Affiliation
+		if err := serializer.DeserializeSlice(v, &affiliations); err != nil {
+			return err
+		}
+		for _, aff := range affiliations {
+			if aff.JID != jid {
+				keep = append(keep, aff)
+			}
+		}
+		if len(keep) != len(affiliations) {
+			updates[string(k)] = &keep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := b.forEachKeyAndValue("pubSubSubscriptions:", tx, func(k, v []byte) error {
+		var subscriptions, keep []pubsubmodel.Subscription
+		if err := serializer.DeserializeSlice(v, &subscriptions); err != nil {
+			return err
+		}
+		for _, sub := range subscriptions {
+			if sub.JID != jid {
+				keep = append(keep, sub)
+			}
+		}
+		if len(keep) != len(subscriptions) {
+			updates[string(k)] = &keep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, entities := range updates {
+		if err := b.upsertEntities(entities, k, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *badgerDBUser) deleteMucReferences(jid string, tx *badger.Txn) error {
+	rooms := make(map[string]*mucmodel.Room)
+	if err := b.forEachKeyAndValue(mucRoomsPrefix, tx, func(k, v []byte) error {
+		var room mucmodel.Room
+		if err := serializer.Deserialize(v, &room); err != nil {
+			return err
+		}
+		if room.Affiliation(jid) != mucmodel.None {
+			room.SetAffiliation(jid, mucmodel.None)
+			rooms[string(k)] = &room
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	reservations := make(map[string]*[]mucmodel.NickReservation)
+	if err := b.forEachKeyAndValue("mucNickReservations:", tx, func(k, v []byte) error {
+		var nickReservations, keep []mucmodel.NickReservation
+		if err := serializer.DeserializeSlice(v, &nickReservations); err != nil {
+			return err
+		}
+		for _, r := range nickReservations {
+			if r.JID != jid {
+				keep = append(keep, r)
+			}
+		}
+		if len(keep) != len(nickReservations) {
+			reservations[string(k)] = &keep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for k, room := range rooms {
+		if err := b.upsertEntity(room, k, tx); err != nil {
+			return err
+		}
+	}
+	for k, keep := range reservations {
+		if len(*keep) == 0 {
+			if err := b.deleteKey(k, tx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := b.upsertEntities(keep, k, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchUsernames retrieves from storage the names of every user registered within a domain.
+func (b *badgerDBUser) FetchUsernames(_ context.Context, domain string) ([]string, error) {
+	var usernames []string
+	suffix := userKey("", domain)
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKey("users:", tx, func(k []byte) error {
+			if key := string(k); strings.HasSuffix(key, suffix) {
+				usernames = append(usernames, strings.TrimSuffix(strings.TrimPrefix(key, "users:"), suffix))
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return usernames, nil
+}
+
+// FetchExpiredUsers retrieves from storage every active user whose account expiration time has been reached.
+func (b *badgerDBUser) FetchExpiredUsers(_ context.Context) ([]model.User, error) {
+	var users []model.User
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		return b.forEachKeyAndValue("users:", tx, func(_, v []byte) error {
+			var user model.User
+			if err := serializer.Deserialize(v, &user); err != nil {
+				return err
+			}
+			if user.IsExpired() && !user.IsSuspended() {
+				users = append(users, user)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func usersKey(username, domain string) string {
+	return "users:" + userKey(username, domain)
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sxmpp/jackal/model"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_InsertUser(t *testing.T) {
+	u := model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	err := s.UpsertUser(context.Background(), &u)
+	require.Nil(t, err)
+}
+
+func TestBadgerDB_UserExists(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	ok, err := s.UserExists(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func TestBadgerDB_FetchUser(t *testing.T) {
+	u := model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	_ = s.UpsertUser(context.Background(), &u)
+
+	usr, _ := s.FetchUser(context.Background(), "romeo", "jackal.im")
+	require.Nil(t, usr)
+
+	usr, _ = s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, usr)
+}
+
+func TestBadgerDB_UpdateLastPresence(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	p := xmpp.NewPresence(j, j.ToBareJID(), xmpp.UnavailableType)
+
+	u := model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234", Status: model.UserDisabled}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	_ = s.UpsertUser(context.Background(), &u)
+
+	require.Nil(t, s.UpdateLastPresence(context.Background(), "sxmpp", "jackal.im", p))
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, p.String(), usr.LastPresence.String())
+	require.Equal(t, model.UserDisabled, usr.Status)
+
+	// never creates a user
+	require.Nil(t, s.UpdateLastPresence(context.Background(), "romeo", "jackal.im", p))
+	ok, _ := s.UserExists(context.Background(), "romeo", "jackal.im")
+	require.False(t, ok)
+}
+
+func TestBadgerDB_DeleteUser(t *testing.T) {
+	u := model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	_ = s.UpsertUser(context.Background(), &u)
+
+	require.Nil(t, s.DeleteUser(context.Background(), "sxmpp", "jackal.im"))
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, usr)
+}
+
+func TestBadgerDB_UsersAcrossDomains(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "example.org", Password: "5678"})
+
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+
+	usr, _ = s.FetchUser(context.Background(), "sxmpp", "example.org")
+	require.NotNil(t, usr)
+	require.Equal(t, "5678", usr.Password)
+
+	require.Nil(t, s.DeleteUser(context.Background(), "sxmpp", "jackal.im"))
+
+	ok, _ := s.UserExists(context.Background(), "sxmpp", "jackal.im")
+	require.False(t, ok)
+	ok, _ = s.UserExists(context.Background(), "sxmpp", "example.org")
+	require.True(t, ok)
+}
+
+func TestBadgerDB_DeleteUserCascade(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "jackal_badgerdb")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	c, err := New(&Config{DataDir: dataDir})
+	require.Nil(t, err)
+	defer func() { _ = c.Close(context.Background()) }()
+
+	ctx := context.Background()
+	require.Nil(t, c.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	require.Nil(t, c.User().UpsertUser(ctx, &model.User{Username: "noelia", Domain: "jackal.im", Password: "5678"}))
+
+	_, err = c.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im", Subscription: "both", Groups: []string{"friends"}})
+	require.Nil(t, err)
+	_, err = c.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "noelia", Domain: "jackal.im", JID: "sxmpp@jackal.im", Subscription: "both"})
+	require.Nil(t, err)
+
+	fromJID, _ := jid.NewWithString("sxmpp@jackal.im", true)
+	toJID, _ := jid.NewWithString("romeo@jackal.im", true)
+	require.Nil(t, c.Roster().UpsertRosterNotification(ctx, &rostermodel.Notification{
+		Contact:  "romeo",
+		Domain:   "jackal.im",
+		JID:      "sxmpp@jackal.im",
+		Presence: xmpp.NewPresence(fromJID, toJID, xmpp.SubscribeType),
+	}))
+	require.Nil(t, c.VCard().UpsertVCard(ctx, xmpp.NewElementNamespace("vCard", "vcard-temp"), "sxmpp", "jackal.im"))
+	require.Nil(t, c.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+	require.Nil(t, c.Offline().InsertOfflineMessage(ctx, &offlinemodel.Message{ID: uuid.New(), Message: xmpp.NewMessageType(uuid.New(), xmpp.ChatType)}, "sxmpp", "jackal.im"))
+
+	require.Nil(t, c.PubSub().UpsertNode(ctx, &pubsubmodel.Node{Host: "sxmpp@jackal.im", Name: "princely_musings"}))
+	require.Nil(t, c.PubSub().UpsertNode(ctx, &pubsubmodel.Node{Host: "noelia@jackal.im", Name: "princely_musings"}))
+	require.Nil(t, c.PubSub().UpsertNodeSubscription(ctx, &pubsubmodel.Subscription{SubID: "1234", JID: "sxmpp@jackal.im", Subscription: "subscribed"}, "noelia@jackal.im", "princely_musings"))
+
+	room := &mucmodel.Room{JID: "lobby@conference.jackal.im"}
+	room.SetAffiliation("sxmpp@jackal.im", mucmodel.Member)
+	room.SetAffiliation("noelia@jackal.im", mucmodel.Owner)
+	require.Nil(t, c.Muc().UpsertMucRoom(ctx, room))
+	require.Nil(t, c.Muc().UpsertMucNickReservation(ctx, &mucmodel.NickReservation{RoomJID: "lobby@conference.jackal.im", JID: "sxmpp@jackal.im", Nick: "sxmpp"}))
+	require.Nil(t, c.Muc().UpsertMucNickReservation(ctx, &mucmodel.NickReservation{RoomJID: "lobby@conference.jackal.im", JID: "noelia@jackal.im", Nick: "noelia"}))
+
+	require.Nil(t, c.User().DeleteUser(ctx, "sxmpp", "jackal.im"))
+
+	usr, err := c.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, usr)
+
+	ris, _, err := c.Roster().FetchRosterItems(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, ris, 0)
+
+	// contact roster must no longer list the deleted account
+	ris, ver, err := c.Roster().FetchRosterItems(ctx, "noelia", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, ris, 0)
+	require.Equal(t, ver.Ver, ver.DeletionVer)
+
+	rns, err := c.Roster().FetchRosterNotifications(ctx, "romeo", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, rns, 0)
+
+	vCard, err := c.VCard().FetchVCard(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, vCard)
+
+	bl, err := c.BlockList().FetchBlockListItems(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, bl, 0)
+
+	cnt, err := c.Offline().CountOfflineMessages(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 0, cnt)
+
+	nodes, err := c.PubSub().FetchNodes(ctx, "sxmpp@jackal.im")
+	require.Nil(t, err)
+	require.Len(t, nodes, 0)
+
+	subs, err := c.PubSub().FetchNodeSubscriptions(ctx, "noelia@jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Len(t, subs, 0)
+
+	room, err = c.Muc().FetchMucRoom(ctx, "lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"noelia@jackal.im": mucmodel.Owner}, room.Affiliations)
+
+	reservations, err := c.Muc().FetchMucNickReservations(ctx, "lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, reservations, 1)
+	require.Equal(t, "noelia@jackal.im", reservations[0].JID)
+}
+
+func TestBadgerDB_FetchUsernames(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "noelia", Domain: "jackal.im", Password: "1234"})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "romeo", Domain: "example.org", Password: "1234"})
+
+	usernames, err := s.FetchUsernames(context.Background(), "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, []string{"noelia", "sxmpp"}, usernames)
+}
+
+func TestBadgerDB_FetchExpiredUsers(t *testing.T) {
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newUser(h.db)
+	expiresAt := time.Now().Add(-time.Minute)
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234", ExpiresAt: expiresAt})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "noelia", Domain: "jackal.im", Password: "1234", ExpiresAt: time.Now().Add(time.Hour)})
+	_ = s.UpsertUser(context.Background(), &model.User{Username: "romeo", Domain: "example.org", Password: "1234", Status: model.UserDisabled, ExpiresAt: expiresAt})
+
+	users, err := s.FetchExpiredUsers(context.Background())
+	require.Nil(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "sxmpp", users[0].Username)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type badgerDBVCard struct {
+	*badgerDBStorage
+}
+
+func newVCard(db *badger.DB) *badgerDBVCard {
+	return &badgerDBVCard{badgerDBStorage: newStorage(db)}
+}
+
+// UpsertVCard inserts a new vCard element into storage, or updates it in case it's been previously inserted.
+func (b *badgerDBVCard) UpsertVCard(_ context.Context, vCard xmpp.XElement, username, domain string) error {
+	return b.inTransaction(func(tx *badger.Txn) error {
+		return b.upsertEntity(vCard, vCardKey(username, domain), tx)
+	})
+}
+
+// FetchVCard retrieves from storage a vCard element associated to a given user.
+func (b *badgerDBVCard) FetchVCard(_ context.Context, username, domain string) (xmpp.XElement, error) {
+	var vCard xmpp.Element
+	var ok bool
+	if err := b.inReadTransaction(func(tx *badger.Txn) error {
+		var fnErr error
+		ok, fnErr = b.fetchEntity(&vCard, vCardKey(username, domain), tx)
+		return fnErr
+	}); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &vCard, nil
+}
+
+func vCardKey(username, domain string) string {
+	return "vCards:" + userKey(username, domain)
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_InsertVCard(t *testing.T) {
+	vCard := xmpp.NewElementName("vCard")
+	fn := xmpp.NewElementName("FN")
+	fn.SetText("Miguel Ángel")
+	vCard.AppendElement(fn)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newVCard(h.db)
+	require.Nil(t, s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im"))
+}
+
+func TestBadgerDB_FetchVCard(t *testing.T) {
+	vCard := xmpp.NewElementName("vCard")
+	fn := xmpp.NewElementName("FN")
+	fn.SetText("Miguel Ángel")
+	vCard.AppendElement(fn)
+
+	h := tUtilBadgerDBSetup(t)
+	defer tUtilBadgerDBTeardown(h)
+
+	s := newVCard(h.db)
+	_ = s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im")
+
+	elem, _ := s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, elem)
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+type cachedBlockList struct {
+	repository.BlockList
+	c     *Container
+	cache *lruCache
+}
+
+func (b *cachedBlockList) InsertBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	if err := b.BlockList.InsertBlockListItem(ctx, item); err != nil {
+		return err
+	}
+	b.c.invalidate(BlockListRepository, userKey(item.Username, item.Domain))
+	return nil
+}
+
+func (b *cachedBlockList) DeleteBlockListItem(ctx context.Context, item *model.BlockListItem) error {
+	if err := b.BlockList.DeleteBlockListItem(ctx, item); err != nil {
+		return err
+	}
+	b.c.invalidate(BlockListRepository, userKey(item.Username, item.Domain))
+	return nil
+}
+
+func (b *cachedBlockList) FetchBlockListItems(ctx context.Context, username, domain string) ([]model.BlockListItem, error) {
+	key := userKey(username, domain)
+	if v, ok := b.cache.get(key); ok {
+		return copyBlockListItems(v.([]model.BlockListItem)), nil
+	}
+	items, err := b.BlockList.FetchBlockListItems(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	b.cache.set(key, copyBlockListItems(items))
+	return items, nil
+}
+
+func copyBlockListItems(items []model.BlockListItem) []model.BlockListItem {
+	if items == nil {
+		return nil
+	}
+	cp := make([]model.BlockListItem, len(items))
+	copy(cp, items)
+	return cp
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+const (
+	// UserRepository identifies user repository cache.
+	UserRepository = "user"
+
+	// BlockListRepository identifies block list repository cache.
+	BlockListRepository = "block_list"
+
+	// RosterRepository identifies roster repository cache.
+	RosterRepository = "roster"
+
+	// VCardRepository identifies vCard repository cache.
+	VCardRepository = "vcard"
+
+	// CapabilitiesRepository identifies capabilities cache.
+	CapabilitiesRepository = "capabilities"
+)
+
+// Container is a repository container decorator that caches hot read paths.
+// Writes issued through the container invalidate affected entries.
+//
+// Caches are local to the process: writes issued by any other process sharing the same storage
+// (such as another cluster node) are not noticed, and affected entries remain stale until their TTL expires.
+type Container struct {
+	repository.Container
+
+	user      *cachedUser
+	blockList *cachedBlockList
+	roster    *cachedRoster
+	vCard     *cachedVCard
+	presences *cachedPresences
+
+	caches map[string]*lruCache
+}
+
+// New wraps a repository container caching those repositories present in configuration.
+func New(c repository.Container, cfg *Config) *Container {
+	cc := &Container{
+		Container: c,
+		caches:    make(map[string]*lruCache),
+	}
+	if cfg.User != nil {
+		cc.user = &cachedUser{User: c.User(), c: cc, cache: cc.newCache(UserRepository, cfg.User)}
+	}
+	if cfg.BlockList != nil {
+		cc.blockList = &cachedBlockList{BlockList: c.BlockList(), c: cc, cache: cc.newCache(BlockListRepository, cfg.BlockList)}
+	}
+	if cfg.Roster != nil {
+		cc.roster = &cachedRoster{Roster: c.Roster(), c: cc, cache: cc.newCache(RosterRepository, cfg.Roster)}
+	}
+	if cfg.VCard != nil {
+		cc.vCard = &cachedVCard{VCard: c.VCard(), c: cc, cache: cc.newCache(VCardRepository, cfg.VCard)}
+	}
+	if cfg.Capabilities != nil {
+		cc.presences = &cachedPresences{Presences: c.Presences(), c: cc, cache: cc.newCache(CapabilitiesRepository, cfg.Capabilities)}
+	}
+	return cc
+}
+
+// User returns cached user repository, if configured.
+func (c *Container) User() repository.User {
+	if c.user != nil {
+		return c.user
+	}
+	return c.Container.User()
+}
+
+// BlockList returns cached block list repository, if configured.
+func (c *Container) BlockList() repository.BlockList {
+	if c.blockList != nil {
+		return c.blockList
+	}
+	return c.Container.BlockList()
+}
+
+// Roster returns cached roster repository, if configured.
+func (c *Container) Roster() repository.Roster {
+	if c.roster != nil {
+		return c.roster
+	}
+	return c.Container.Roster()
+}
+
+// VCard returns cached vCard repository, if configured.
+func (c *Container) VCard() repository.VCard {
+	if c.vCard != nil {
+		return c.vCard
+	}
+	return c.Container.VCard()
+}
+
+// Presences returns presences repository, caching capabilities if configured.
+func (c *Container) Presences() repository.Presences {
+	if c.presences != nil {
+		return c.presences
+	}
+	return c.Container.Presences()
+}
+
+func (c *Container) newCache(repository string, cfg *CacheConfig) *lruCache {
+	cache := newLRUCache(cfg.Size, cfg.TTL)
+	c.caches[repository] = cache
+	return cache
+}
+
+// invalidate removes a cached entry.
+func (c *Container) invalidate(repository, key string) {
+	if cache := c.caches[repository]; cache != nil {
+		cache.del(key)
+	}
+}
+
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
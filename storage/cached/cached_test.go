@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sxmpp/jackal/model"
+	capsmodel "github.com/sxmpp/jackal/model/capabilities"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedContainer_User(t *testing.T) {
+	c, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	require.Nil(t, cc.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+
+	usr, err := cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, "1234", usr.Password)
+
+	// bypass cache
+	require.Nil(t, c.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "4321"}))
+
+	usr, _ = cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, "1234", usr.Password)
+
+	// write through cached container
+	require.Nil(t, cc.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "5678"}))
+
+	usr, _ = cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, "5678", usr.Password)
+
+	j, _ := jid.New("sxmpp", "jackal.im", "", true)
+	p := xmpp.NewPresence(j, j, xmpp.UnavailableType)
+	require.Nil(t, cc.User().UpdateLastPresence(ctx, "sxmpp", "jackal.im", p))
+
+	usr, _ = cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.NotNil(t, usr.LastPresence)
+
+	// not found users are cached too
+	ok, err := cc.User().UserExists(ctx, "romeo", "jackal.im")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.Nil(t, c.User().UpsertUser(ctx, &model.User{Username: "romeo", Domain: "jackal.im", Password: "1234"}))
+
+	ok, _ = cc.User().UserExists(ctx, "romeo", "jackal.im")
+	require.False(t, ok)
+
+	require.Nil(t, cc.User().DeleteUser(ctx, "sxmpp", "jackal.im"))
+	ok, _ = cc.User().UserExists(ctx, "sxmpp", "jackal.im")
+	require.False(t, ok)
+}
+
+func TestCachedContainer_DeleteUserInvalidatesContactRosters(t *testing.T) {
+	_, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	require.Nil(t, cc.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	_, err := cc.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "romeo", Domain: "jackal.im", JID: "sxmpp@jackal.im", Subscription: "both"})
+	require.Nil(t, err)
+
+	items, _, _ := cc.Roster().FetchRosterItems(ctx, "romeo", "jackal.im")
+	require.Len(t, items, 1)
+
+	require.Nil(t, cc.User().DeleteUser(ctx, "sxmpp", "jackal.im"))
+
+	items, _, _ = cc.Roster().FetchRosterItems(ctx, "romeo", "jackal.im")
+	require.Len(t, items, 0)
+}
+
+func TestCachedContainer_BlockList(t *testing.T) {
+	c, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	items, err := cc.BlockList().FetchBlockListItems(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, items, 0)
+
+	require.Nil(t, c.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+
+	items, _ = cc.BlockList().FetchBlockListItems(ctx, "sxmpp", "jackal.im")
+	require.Len(t, items, 0)
+
+	require.Nil(t, cc.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"}))
+
+	items, _ = cc.BlockList().FetchBlockListItems(ctx, "sxmpp", "jackal.im")
+	require.Len(t, items, 2)
+}
+
+func TestCachedContainer_Roster(t *testing.T) {
+	c, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	_, err := cc.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im", Subscription: "both"})
+	require.Nil(t, err)
+
+	items, ver, err := cc.Roster().FetchRosterItems(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+
+	_, _ = c.Roster().UpsertRosterItem(ctx, &rostermodel.Item{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im", Subscription: "both"})
+
+	items, ver2, _ := cc.Roster().FetchRosterItems(ctx, "sxmpp", "jackal.im")
+	require.Len(t, items, 1)
+	require.Equal(t, ver, ver2)
+
+	ri, err := cc.Roster().FetchRosterItem(ctx, "sxmpp", "jackal.im", "romeo@jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, ri)
+
+	_, err = cc.Roster().DeleteRosterItem(ctx, "sxmpp", "jackal.im", "romeo@jackal.im")
+	require.Nil(t, err)
+
+	ri, _ = cc.Roster().FetchRosterItem(ctx, "sxmpp", "jackal.im", "romeo@jackal.im")
+	require.Nil(t, ri)
+	ri, _ = cc.Roster().FetchRosterItem(ctx, "sxmpp", "jackal.im", "juliet@jackal.im")
+	require.NotNil(t, ri)
+}
+
+func TestCachedContainer_VCardAndCapabilities(t *testing.T) {
+	c, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	vCard, err := cc.VCard().FetchVCard(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, vCard)
+
+	require.Nil(t, c.VCard().UpsertVCard(ctx, xmpp.NewElementNamespace("vCard", "vcard-temp"), "sxmpp", "jackal.im"))
+
+	vCard, _ = cc.VCard().FetchVCard(ctx, "sxmpp", "jackal.im")
+	require.Nil(t, vCard)
+
+	require.Nil(t, cc.VCard().UpsertVCard(ctx, xmpp.NewElementNamespace("vCard", "vcard-temp"), "sxmpp", "jackal.im"))
+
+	vCard, _ = cc.VCard().FetchVCard(ctx, "sxmpp", "jackal.im")
+	require.NotNil(t, vCard)
+
+	caps := &capsmodel.Capabilities{Node: "http://jackal.im", Ver: "v1", Features: []string{"urn:xmpp:ping"}}
+	require.Nil(t, cc.Presences().UpsertCapabilities(ctx, caps))
+
+	fetched, err := cc.Presences().FetchCapabilities(ctx, "http://jackal.im", "v1")
+	require.Nil(t, err)
+	require.Equal(t, caps, fetched)
+
+	// returned entities can be safely modified
+	fetched.Features[0] = "urn:xmpp:time"
+	fetched, _ = cc.Presences().FetchCapabilities(ctx, "http://jackal.im", "v1")
+	require.Equal(t, "urn:xmpp:ping", fetched.Features[0])
+}
+
+func TestCachedContainer_Invalidation(t *testing.T) {
+	c, cc := tUtilCachedSetup(t)
+
+	ctx := context.Background()
+	require.Nil(t, cc.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	_, _ = cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+
+	require.Nil(t, cc.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "4321"}))
+	usr, _ := cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, "4321", usr.Password)
+
+	// writes bypassing the container are not noticed until entry expires
+	require.Nil(t, c.User().UpsertUser(ctx, &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "0000"}))
+	usr, _ = cc.User().FetchUser(ctx, "sxmpp", "jackal.im")
+	require.Equal(t, "4321", usr.Password)
+}
+
+func TestCachedContainer_NotConfigured(t *testing.T) {
+	c, err := memorystorage.New()
+	require.Nil(t, err)
+
+	cc := New(c, &Config{})
+	require.Equal(t, c.User(), cc.User())
+	require.Equal(t, c.Roster(), cc.Roster())
+	require.Equal(t, c.Presences(), cc.Presences())
+}
+
+func tUtilCachedSetup(t *testing.T) (repository.Container, *Container) {
+	c, err := memorystorage.New()
+	require.Nil(t, err)
+
+	cacheCfg := &CacheConfig{Size: 16, TTL: time.Minute}
+	return c, New(c, &Config{
+		User:         cacheCfg,
+		BlockList:    cacheCfg,
+		Roster:       cacheCfg,
+		VCard:        cacheCfg,
+		Capabilities: cacheCfg,
+	})
+}
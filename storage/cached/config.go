@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import "time"
+
+const (
+	defaultCacheSize = 4096
+	defaultCacheTTL  = time.Duration(5) * time.Minute
+)
+
+// Config represents repositories caching configuration.
+// Only those repositories with an associated configuration will be cached.
+type Config struct {
+	User         *CacheConfig `yaml:"user"`
+	BlockList    *CacheConfig `yaml:"block_list"`
+	Roster       *CacheConfig `yaml:"roster"`
+	VCard        *CacheConfig `yaml:"vcard"`
+	Capabilities *CacheConfig `yaml:"capabilities"`
+}
+
+// CacheConfig represents a repository cache configuration.
+type CacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+type cacheConfigProxy struct {
+	Size int `yaml:"size"`
+	TTL  int `yaml:"ttl"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *CacheConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := cacheConfigProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Size = p.Size
+	if c.Size <= 0 {
+		c.Size = defaultCacheSize
+	}
+	c.TTL = time.Duration(p.TTL) * time.Second
+	if c.TTL <= 0 {
+		c.TTL = defaultCacheTTL
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	val       interface{}
+	expiresAt time.Time
+}
+
+// lruCache is a bounded cache evicting least recently used entries first.
+// Entries expire after ttl, when set.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+	nowFn func() time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		nowFn: time.Now,
+	}
+}
+
+// get returns a cached value and whether or not it was found.
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := elem.Value.(*lruEntry)
+	if c.ttl > 0 && c.nowFn().After(ent.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return ent.val, true
+}
+
+// set caches a value, evicting least recently used entry when the cache is full.
+func (c *lruCache) set(key string, val interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = c.nowFn().Add(c.ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		ent := elem.Value.(*lruEntry)
+		ent.val = val
+		ent.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, val: val, expiresAt: expiresAt})
+	if c.size > 0 && c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// del removes a cached value.
+func (c *lruCache) del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// keys returns the keys of every cached value satisfying match.
+func (c *lruCache) keys(match func(val interface{}) bool) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []string
+	for key, elem := range c.items {
+		if match(elem.Value.(*lruEntry).val) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *lruCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
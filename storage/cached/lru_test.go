@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache_Eviction(t *testing.T) {
+	c := newLRUCache(2, 0)
+
+	c.set("a", 1)
+	c.set("b", 2)
+
+	// 'a' becomes the most recently used entry
+	v, ok := c.get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	c.set("c", 3)
+	require.Equal(t, 2, c.len())
+
+	_, ok = c.get("b")
+	require.False(t, ok)
+	_, ok = c.get("a")
+	require.True(t, ok)
+	_, ok = c.get("c")
+	require.True(t, ok)
+
+	c.del("a")
+	_, ok = c.get("a")
+	require.False(t, ok)
+	require.Equal(t, 1, c.len())
+}
+
+func TestLRUCache_Expiration(t *testing.T) {
+	now := time.Now()
+
+	c := newLRUCache(10, time.Minute)
+	c.nowFn = func() time.Time { return now }
+
+	c.set("a", 1)
+	_, ok := c.get("a")
+	require.True(t, ok)
+
+	now = now.Add(time.Minute + time.Second)
+	_, ok = c.get("a")
+	require.False(t, ok)
+	require.Equal(t, 0, c.len())
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+
+	capsmodel "github.com/sxmpp/jackal/model/capabilities"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+type cachedPresences struct {
+	repository.Presences
+	c     *Container
+	cache *lruCache
+}
+
+func (p *cachedPresences) UpsertCapabilities(ctx context.Context, caps *capsmodel.Capabilities) error {
+	if err := p.Presences.UpsertCapabilities(ctx, caps); err != nil {
+		return err
+	}
+	p.c.invalidate(CapabilitiesRepository, capabilitiesKey(caps.Node, caps.Ver))
+	return nil
+}
+
+func (p *cachedPresences) FetchCapabilities(ctx context.Context, node, ver string) (*capsmodel.Capabilities, error) {
+	key := capabilitiesKey(node, ver)
+	if v, ok := p.cache.get(key); ok {
+		return copyCapabilities(v.(*capsmodel.Capabilities)), nil
+	}
+	caps, err := p.Presences.FetchCapabilities(ctx, node, ver)
+	if err != nil {
+		return nil, err
+	}
+	p.cache.set(key, copyCapabilities(caps))
+	return caps, nil
+}
+
+func capabilitiesKey(node, ver string) string {
+	return node + "#" + ver
+}
+
+func copyCapabilities(caps *capsmodel.Capabilities) *capsmodel.Capabilities {
+	if caps == nil {
+		return nil
+	}
+	cp := *caps
+	if caps.Features != nil {
+		cp.Features = make([]string, len(caps.Features))
+		copy(cp.Features, caps.Features)
+	}
+	return &cp
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+type rosterEntry struct {
+	items []rostermodel.Item
+	ver   rostermodel.Version
+}
+
+// contains returns whether or not roster entry holds an item for a given jid.
+func (e *rosterEntry) contains(jid string) bool {
+	for _, ri := range e.items {
+		if ri.JID == jid {
+			return true
+		}
+	}
+	return false
+}
+
+type cachedRoster struct {
+	repository.Roster
+	c     *Container
+	cache *lruCache
+}
+
+func (r *cachedRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item) (rostermodel.Version, error) {
+	ver, err := r.Roster.UpsertRosterItem(ctx, ri)
+	if err != nil {
+		return rostermodel.Version{}, err
+	}
+	r.c.invalidate(RosterRepository, userKey(ri.Username, ri.Domain))
+	return ver, nil
+}
+
+func (r *cachedRoster) DeleteRosterItem(ctx context.Context, username, domain, jid string) (rostermodel.Version, error) {
+	ver, err := r.Roster.DeleteRosterItem(ctx, username, domain, jid)
+	if err != nil {
+		return rostermodel.Version{}, err
+	}
+	r.c.invalidate(RosterRepository, userKey(username, domain))
+	return ver, nil
+}
+
+func (r *cachedRoster) FetchRosterItems(ctx context.Context, username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	ent, err := r.fetchRosterEntry(ctx, username, domain)
+	if err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	return copyRosterItems(ent.items), ent.ver, nil
+}
+
+func (r *cachedRoster) FetchRosterItem(ctx context.Context, username, domain, jid string) (*rostermodel.Item, error) {
+	ent, err := r.fetchRosterEntry(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, ri := range ent.items {
+		if ri.JID == jid {
+			return copyRosterItem(&ri), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *cachedRoster) fetchRosterEntry(ctx context.Context, username, domain string) (*rosterEntry, error) {
+	key := userKey(username, domain)
+	if v, ok := r.cache.get(key); ok {
+		return v.(*rosterEntry), nil
+	}
+	items, ver, err := r.Roster.FetchRosterItems(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	ent := &rosterEntry{items: copyRosterItems(items), ver: ver}
+	r.cache.set(key, ent)
+	return ent, nil
+}
+
+func copyRosterItems(items []rostermodel.Item) []rostermodel.Item {
+	if items == nil {
+		return nil
+	}
+	cp := make([]rostermodel.Item, len(items))
+	for i := range items {
+		cp[i] = *copyRosterItem(&items[i])
+	}
+	return cp
+}
+
+func copyRosterItem(ri *rostermodel.Item) *rostermodel.Item {
+	cp := *ri
+	if ri.Groups != nil {
+		cp.Groups = make([]string, len(ri.Groups))
+		copy(cp.Groups, ri.Groups)
+	}
+	return &cp
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type cachedUser struct {
+	repository.User
+	c     *Container
+	cache *lruCache
+}
+
+func (u *cachedUser) UpsertUser(ctx context.Context, user *model.User) error {
+	if err := u.User.UpsertUser(ctx, user); err != nil {
+		return err
+	}
+	u.c.invalidate(UserRepository, userKey(user.Username, user.Domain))
+	return nil
+}
+
+func (u *cachedUser) UpdateLastPresence(ctx context.Context, username, domain string, presence *xmpp.Presence) error {
+	if err := u.User.UpdateLastPresence(ctx, username, domain, presence); err != nil {
+		return err
+	}
+	u.c.invalidate(UserRepository, userKey(username, domain))
+	return nil
+}
+
+func (u *cachedUser) DeleteUser(ctx context.Context, username, domain string) error {
+	if err := u.User.DeleteUser(ctx, username, domain); err != nil {
+		return err
+	}
+	// user associated entities are gone as well
+	key := userKey(username, domain)
+	u.c.invalidate(UserRepository, key)
+	u.c.invalidate(BlockListRepository, key)
+	u.c.invalidate(RosterRepository, key)
+	u.c.invalidate(VCardRepository, key)
+
+	// ...and so are the references held by contact rosters
+	if cache := u.c.caches[RosterRepository]; cache != nil {
+		for _, k := range cache.keys(func(val interface{}) bool { return val.(*rosterEntry).contains(key) }) {
+			u.c.invalidate(RosterRepository, k)
+		}
+	}
+	return nil
+}
+
+func (u *cachedUser) FetchUser(ctx context.Context, username, domain string) (*model.User, error) {
+	key := userKey(username, domain)
+	if v, ok := u.cache.get(key); ok {
+		return copyUser(v.(*model.User)), nil
+	}
+	usr, err := u.User.FetchUser(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	u.cache.set(key, copyUser(usr)) // not found users are cached as well
+	return usr, nil
+}
+
+func (u *cachedUser) UserExists(ctx context.Context, username, domain string) (bool, error) {
+	if v, ok := u.cache.get(userKey(username, domain)); ok {
+		return v.(*model.User) != nil, nil
+	}
+	usr, err := u.FetchUser(ctx, username, domain)
+	if err != nil {
+		return false, err
+	}
+	return usr != nil, nil
+}
+
+func copyUser(usr *model.User) *model.User {
+	if usr == nil {
+		return nil
+	}
+	cp := *usr
+	return &cp
+}
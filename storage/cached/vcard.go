@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package cached
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type cachedVCard struct {
+	repository.VCard
+	c     *Container
+	cache *lruCache
+}
+
+func (v *cachedVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username, domain string) error {
+	if err := v.VCard.UpsertVCard(ctx, vCard, username, domain); err != nil {
+		return err
+	}
+	v.c.invalidate(VCardRepository, userKey(username, domain))
+	return nil
+}
+
+func (v *cachedVCard) FetchVCard(ctx context.Context, username, domain string) (xmpp.XElement, error) {
+	key := userKey(username, domain)
+	if val, ok := v.cache.get(key); ok {
+		vCard, _ := val.(xmpp.XElement) // nil when not found
+		return copyVCard(vCard), nil
+	}
+	vCard, err := v.VCard.FetchVCard(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	v.cache.set(key, copyVCard(vCard))
+	return vCard, nil
+}
+
+func copyVCard(vCard xmpp.XElement) xmpp.XElement {
+	if vCard == nil {
+		return nil
+	}
+	return xmpp.NewElementFromElement(vCard)
+}
@@ -9,8 +9,12 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/sxmpp/jackal/storage/badgerdb"
+	"github.com/sxmpp/jackal/storage/cached"
+	"github.com/sxmpp/jackal/storage/encrypted"
 	"github.com/sxmpp/jackal/storage/mysql"
 	"github.com/sxmpp/jackal/storage/pgsql"
+	"github.com/sxmpp/jackal/storage/sqlite"
 )
 
 // Type represents a storage manager type.
@@ -25,12 +29,20 @@ const (
 
 	// Memory represents a in-memstorage storage type.
 	Memory
+
+	// BadgerDB represents a BadgerDB storage type.
+	BadgerDB
+
+	// SQLite represents a SQLite storage type.
+	SQLite
 )
 
 var typeStringMap = map[Type]string{
 	MySQL:      "MySQL",
 	PostgreSQL: "PostgreSQL",
 	Memory:     "Memory",
+	BadgerDB:   "BadgerDB",
+	SQLite:     "SQLite",
 }
 
 func (t Type) String() string { return typeStringMap[t] }
@@ -40,12 +52,20 @@ type Config struct {
 	Type       Type
 	MySQL      *mysql.Config
 	PostgreSQL *pgsql.Config
+	BadgerDB   *badgerdb.Config
+	SQLite     *sqlite.Config
+	Cache      *cached.Config
+	Encryption *encrypted.Config
 }
 
 type storageProxyType struct {
-	Type       string        `yaml:"type"`
-	MySQL      *mysql.Config `yaml:"mysql"`
-	PostgreSQL *pgsql.Config `yaml:"pgsql"`
+	Type       string            `yaml:"type"`
+	MySQL      *mysql.Config     `yaml:"mysql"`
+	PostgreSQL *pgsql.Config     `yaml:"pgsql"`
+	BadgerDB   *badgerdb.Config  `yaml:"badgerdb"`
+	SQLite     *sqlite.Config    `yaml:"sqlite"`
+	Cache      *cached.Config    `yaml:"cache"`
+	Encryption *encrypted.Config `yaml:"encryption"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -74,12 +94,28 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	case "memory":
 		c.Type = Memory
 
+	case "badgerdb":
+		if p.BadgerDB == nil {
+			return errors.New("storage.Config: couldn't read BadgerDB configuration")
+		}
+		c.Type = BadgerDB
+		c.BadgerDB = p.BadgerDB
+
+	case "sqlite":
+		if p.SQLite == nil {
+			return errors.New("storage.Config: couldn't read SQLite configuration")
+		}
+		c.Type = SQLite
+		c.SQLite = p.SQLite
+
 	case "":
 		return errors.New("storage.Config: unspecified storage type")
 
 	default:
 		return fmt.Errorf("storage.Config: unrecognized storage type: %s", p.Type)
 	}
+	c.Cache = p.Cache
+	c.Encryption = p.Encryption
 
 	return nil
 }
@@ -7,6 +7,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sxmpp/jackal/storage/mysql"
 	"github.com/stretchr/testify/require"
@@ -31,6 +32,8 @@ func TestStorageConfig(t *testing.T) {
     password: password
     database: jackaldb
     pool_size: 16
+    auto_migrate: true
+    legacy_domain: jackal.im
 `
 
 	err = yaml.Unmarshal([]byte(mySQLCfg), &cfg)
@@ -40,6 +43,8 @@ func TestStorageConfig(t *testing.T) {
 	require.Equal(t, "password", cfg.MySQL.Password)
 	require.Equal(t, "jackaldb", cfg.MySQL.Database)
 	require.Equal(t, 16, cfg.MySQL.PoolSize)
+	require.True(t, cfg.MySQL.AutoMigrate)
+	require.Equal(t, "jackal.im", cfg.MySQL.LegacyDomain)
 
 	mySQLCfg2 := `
   type: mysql
@@ -54,6 +59,7 @@ func TestStorageConfig(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, MySQL, cfg.Type)
 	require.Equal(t, mysql.DefaultPoolSize, cfg.MySQL.PoolSize)
+	require.False(t, cfg.MySQL.AutoMigrate)
 
 	invalidMySQLCfg := `
   type: mysql
@@ -61,6 +67,73 @@ func TestStorageConfig(t *testing.T) {
 	err = yaml.Unmarshal([]byte(invalidMySQLCfg), &cfg)
 	require.NotNil(t, err)
 
+	badgerCfg := `
+  type: badgerdb
+  badgerdb:
+    data_dir: /var/lib/jackal
+`
+	err = yaml.Unmarshal([]byte(badgerCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, BadgerDB, cfg.Type)
+	require.Equal(t, "/var/lib/jackal", cfg.BadgerDB.DataDir)
+
+	invalidBadgerCfg := `
+  type: badgerdb
+`
+	err = yaml.Unmarshal([]byte(invalidBadgerCfg), &cfg)
+	require.NotNil(t, err)
+
+	sqliteCfg := `
+  type: sqlite
+  sqlite:
+    path: /var/lib/jackal/jackal.db
+`
+	err = yaml.Unmarshal([]byte(sqliteCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, SQLite, cfg.Type)
+	require.Equal(t, "/var/lib/jackal/jackal.db", cfg.SQLite.Path)
+
+	invalidSQLiteCfg := `
+  type: sqlite
+`
+	err = yaml.Unmarshal([]byte(invalidSQLiteCfg), &cfg)
+	require.NotNil(t, err)
+
+	cacheCfg := `
+  type: memory
+  cache:
+    user:
+      size: 1024
+      ttl: 60
+    block_list: {}
+`
+	cfg = Config{}
+	err = yaml.Unmarshal([]byte(cacheCfg), &cfg)
+	require.Nil(t, err)
+	require.NotNil(t, cfg.Cache)
+	require.Equal(t, 1024, cfg.Cache.User.Size)
+	require.Equal(t, time.Minute, cfg.Cache.User.TTL)
+	require.NotNil(t, cfg.Cache.BlockList)
+	require.Nil(t, cfg.Cache.Roster)
+
+	encryptionCfg := `
+  type: memory
+  encryption:
+    keys:
+      - id: k2
+        file: /etc/jackal/kek2
+      - id: k1
+        env: JACKAL_KEK_1
+`
+	cfg = Config{}
+	err = yaml.Unmarshal([]byte(encryptionCfg), &cfg)
+	require.Nil(t, err)
+	require.NotNil(t, cfg.Encryption)
+	require.Len(t, cfg.Encryption.Keys, 2)
+	require.Equal(t, "k2", cfg.Encryption.Keys[0].ID)
+	require.Equal(t, "/etc/jackal/kek2", cfg.Encryption.Keys[0].File)
+	require.Equal(t, "JACKAL_KEK_1", cfg.Encryption.Keys[1].Env)
+
 	invalidCfg := `
   type: invalid
 `
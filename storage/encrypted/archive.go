@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"context"
+	"errors"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// encryptedArchive seals archived message stanzas. Archive queries only filter by peer and timestamp,
+// both of which are kept in plain text as message metadata.
+type encryptedArchive struct {
+	repository.Archive
+	kr *keyring
+}
+
+func (a *encryptedArchive) InsertArchiveMessage(ctx context.Context, message *archivemodel.Message) error {
+	env, err := a.kr.seal([]byte(message.Message.String()), archiveAAD(message.Username, message.Domain))
+	if err != nil {
+		return err
+	}
+	envMsg, err := envelopeMessage(message.Message, env)
+	if err != nil {
+		return err
+	}
+	sealed := *message
+	sealed.Message = envMsg
+	return a.Archive.InsertArchiveMessage(ctx, &sealed)
+}
+
+func (a *encryptedArchive) FetchArchiveMessages(ctx context.Context, query *archivemodel.Query, username, domain string) ([]archivemodel.Message, error) {
+	messages, err := a.Archive.FetchArchiveMessages(ctx, query, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		env := messages[i].Message.Elements().ChildNamespace(envelopeElementName, envelopeNamespace)
+		if env == nil {
+			continue // archived before enabling encryption
+		}
+		msg, err := a.openMessage(env, username, domain)
+		if err != nil {
+			return nil, err
+		}
+		messages[i].Message = msg
+	}
+	return messages, nil
+}
+
+func (a *encryptedArchive) openMessage(env xmpp.XElement, username, domain string) (*xmpp.Message, error) {
+	b, err := a.kr.open(env, archiveAAD(username, domain))
+	if err != nil {
+		return nil, err
+	}
+	elems, err := parseElements(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) != 1 {
+		return nil, errors.New("encrypted: unexpected archive message content")
+	}
+	fromJID, _ := jid.NewWithString(elems[0].From(), true)
+	toJID, _ := jid.NewWithString(elems[0].To(), true)
+	return xmpp.NewMessageFromElement(elems[0], fromJID, toJID)
+}
+
+func archiveAAD(username, domain string) string {
+	return "archive:" + userKey(username, domain)
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Config represents encryption at rest configuration.
+type Config struct {
+	// Keys contains every known key-encryption key. First one is used to encrypt new data,
+	// while the rest of them are only kept to decrypt data that has not been rotated yet.
+	Keys []KeyConfig `yaml:"keys"`
+}
+
+// KeyConfig represents a key-encryption key configuration.
+// Key material must be a base64 encoded 32 byte value, read either from a file or an environment variable.
+type KeyConfig struct {
+	ID   string `yaml:"id"`
+	File string `yaml:"file"`
+	Env  string `yaml:"env"`
+}
+
+func (kc *KeyConfig) load() ([]byte, error) {
+	var encoded string
+	switch {
+	case len(kc.File) > 0 && len(kc.Env) > 0:
+		return nil, fmt.Errorf("encrypted: key %s: file and env are mutually exclusive", kc.ID)
+	case len(kc.File) > 0:
+		b, err := ioutil.ReadFile(kc.File)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(b)
+	case len(kc.Env) > 0:
+		encoded = os.Getenv(kc.Env)
+		if len(encoded) == 0 {
+			return nil, fmt.Errorf("encrypted: key %s: environment variable %s not set", kc.ID, kc.Env)
+		}
+	default:
+		return nil, fmt.Errorf("encrypted: key %s: either file or env must be specified", kc.ID)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted: key %s: %v", kc.ID, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("encrypted: key %s: invalid key length %d (expected %d)", kc.ID, len(key), keySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+// Container is a repository container decorator that encrypts sensitive XML before handing it to the
+// underlying storage. Offline messages, archived messages, private XML, vCards and pubsub item payloads
+// are replaced by envelope elements, so that any backend can persist them unchanged. Virtual host private keys are
+// stored as serialized envelopes as well.
+//
+// Data stored prior to enabling encryption is returned as is, and gets encrypted by Rotate.
+type Container struct {
+	repository.Container
+
+	kr *keyring
+
+	vCard   *encryptedVCard
+	priv    *encryptedPrivate
+	offline *encryptedOffline
+	pubSub  *encryptedPubSub
+	host    *encryptedHost
+	archive *encryptedArchive
+}
+
+// New wraps a repository container encrypting its sensitive data.
+func New(c repository.Container, cfg *Config) (*Container, error) {
+	kr, err := newKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newContainer(c, kr), nil
+}
+
+func newContainer(c repository.Container, kr *keyring) *Container {
+	return &Container{
+		Container: c,
+		kr:        kr,
+		vCard:     &encryptedVCard{VCard: c.VCard(), kr: kr},
+		priv:      &encryptedPrivate{Private: c.Private(), kr: kr},
+		offline:   &encryptedOffline{Offline: c.Offline(), kr: kr},
+		pubSub:    &encryptedPubSub{PubSub: c.PubSub(), kr: kr},
+		host:      &encryptedHost{Host: c.Host(), kr: kr},
+		archive:   &encryptedArchive{Archive: c.Archive(), kr: kr},
+	}
+}
+
+// VCard returns encrypted vCard repository.
+func (c *Container) VCard() repository.VCard { return c.vCard }
+
+// Private returns encrypted private XML repository.
+func (c *Container) Private() repository.Private { return c.priv }
+
+// Offline returns encrypted offline messages repository.
+func (c *Container) Offline() repository.Offline { return c.offline }
+
+// PubSub returns pubsub repository, encrypting item payloads.
+func (c *Container) PubSub() repository.PubSub { return c.pubSub }
+
+// Host returns host repository, encrypting host private keys.
+func (c *Container) Host() repository.Host { return c.host }
+
+// Archive returns encrypted message archive repository.
+func (c *Container) Archive() repository.Archive { return c.archive }
+
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
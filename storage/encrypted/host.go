@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+type encryptedHost struct {
+	repository.Host
+	kr *keyring
+
+	// serializes writes against key rotation.
+	mu sync.Mutex
+}
+
+func (h *encryptedHost) UpsertHost(ctx context.Context, host *model.Host) error {
+	sealed, err := h.sealHost(host)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.Host.UpsertHost(ctx, sealed)
+}
+
+func (h *encryptedHost) FetchHost(ctx context.Context, name string) (*model.Host, error) {
+	host, err := h.Host.FetchHost(ctx, name)
+	if err != nil || host == nil {
+		return nil, err
+	}
+	if err := h.openHost(host); err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+func (h *encryptedHost) FetchHosts(ctx context.Context) ([]model.Host, error) {
+	hosts, err := h.Host.FetchHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		if err := h.openHost(&hosts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return hosts, nil
+}
+
+// sealHost returns a copy of host whose private key has been replaced by its serialized envelope.
+func (h *encryptedHost) sealHost(host *model.Host) (*model.Host, error) {
+	env, err := h.kr.seal(host.PrivateKey, hostAAD(host.Name))
+	if err != nil {
+		return nil, err
+	}
+	sealed := *host
+	sealed.PrivateKey = []byte(env.String())
+	return &sealed, nil
+}
+
+func (h *encryptedHost) openHost(host *model.Host) error {
+	if !isSealedPrivateKey(host.PrivateKey) {
+		return nil // not encrypted yet
+	}
+	elems, err := parseElements(host.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if len(elems) != 1 {
+		return errors.New("encrypted: unexpected host private key content")
+	}
+	b, err := h.kr.open(elems[0], hostAAD(host.Name))
+	if err != nil {
+		return err
+	}
+	host.PrivateKey = b
+	return nil
+}
+
+// isSealedPrivateKey tells whether a stored private key is an envelope rather than a PEM encoded key.
+func isSealedPrivateKey(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("<"))
+}
+
+func hostAAD(name string) string {
+	return "host:" + name
+}
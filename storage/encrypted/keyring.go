@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+const (
+	envelopeNamespace = "urn:jackal:storage:encrypted"
+
+	envelopeElementName = "encrypted"
+
+	keySize = 32 // AES-256
+)
+
+var (
+	errNoKeys = errors.New("encrypted: no key-encryption keys configured")
+
+	errMalformedEnvelope = errors.New("encrypted: malformed envelope")
+)
+
+// keyring holds the key-encryption keys used to seal and open data envelopes.
+//
+// Every envelope is encrypted with its own random data key, which is in turn encrypted (wrapped) with the active
+// key-encryption key. Rotating keys thus only requires re-wrapping data keys.
+type keyring struct {
+	activeID string
+	keks     map[string]cipher.AEAD
+}
+
+// newKeyring loads configured key-encryption keys.
+func newKeyring(cfg *Config) (*keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errNoKeys
+	}
+	kr := &keyring{
+		activeID: cfg.Keys[0].ID,
+		keks:     make(map[string]cipher.AEAD),
+	}
+	for i := range cfg.Keys {
+		kc := &cfg.Keys[i]
+		if len(kc.ID) == 0 {
+			return nil, errors.New("encrypted: key id is required")
+		}
+		if _, ok := kr.keks[kc.ID]; ok {
+			return nil, fmt.Errorf("encrypted: duplicated key id: %s", kc.ID)
+		}
+		key, err := kc.load()
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		kr.keks[kc.ID] = aead
+	}
+	return kr, nil
+}
+
+// seal encrypts plaintext into a new envelope element. aad binds the envelope to its storage location,
+// so that it can't be moved across entities.
+func (kr *keyring) seal(plaintext []byte, aad string) (*xmpp.Element, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := sealWithAEAD(aead, plaintext, []byte(aad))
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := sealWithAEAD(kr.keks[kr.activeID], dataKey, []byte(kr.activeID))
+	if err != nil {
+		return nil, err
+	}
+	return newEnvelope(kr.activeID, wrappedKey, ciphertext), nil
+}
+
+// open decrypts an envelope element.
+func (kr *keyring) open(envelope xmpp.XElement, aad string) ([]byte, error) {
+	kid, wrappedKey, ciphertext, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := kr.unwrap(kid, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return openWithAEAD(aead, ciphertext, []byte(aad))
+}
+
+// rewrap re-encrypts envelope data key with the active key-encryption key.
+// Returned boolean value is false when envelope was already wrapped with the active key.
+func (kr *keyring) rewrap(envelope xmpp.XElement) (*xmpp.Element, bool, error) {
+	kid, wrappedKey, ciphertext, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, false, err
+	}
+	if kid == kr.activeID {
+		return nil, false, nil
+	}
+	dataKey, err := kr.unwrap(kid, wrappedKey)
+	if err != nil {
+		return nil, false, err
+	}
+	wrappedKey, err = sealWithAEAD(kr.keks[kr.activeID], dataKey, []byte(kr.activeID))
+	if err != nil {
+		return nil, false, err
+	}
+	return newEnvelope(kr.activeID, wrappedKey, ciphertext), true, nil
+}
+
+func (kr *keyring) unwrap(kid string, wrappedKey []byte) ([]byte, error) {
+	kek := kr.keks[kid]
+	if kek == nil {
+		return nil, fmt.Errorf("encrypted: unknown key id: %s", kid)
+	}
+	return openWithAEAD(kek, wrappedKey, []byte(kid))
+}
+
+func isEnvelope(elem xmpp.XElement) bool {
+	return elem != nil && elem.Name() == envelopeElementName && elem.Namespace() == envelopeNamespace
+}
+
+func newEnvelope(kid string, wrappedKey, ciphertext []byte) *xmpp.Element {
+	env := xmpp.NewElementNamespace(envelopeElementName, envelopeNamespace)
+	env.SetAttribute("kid", kid)
+	env.SetAttribute("key", base64.StdEncoding.EncodeToString(wrappedKey))
+	env.SetText(base64.StdEncoding.EncodeToString(ciphertext))
+	return env
+}
+
+func parseEnvelope(envelope xmpp.XElement) (kid string, wrappedKey, ciphertext []byte, err error) {
+	if !isEnvelope(envelope) {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	kid = envelope.Attributes().Get("kid")
+	wrappedKey, err = base64.StdEncoding.DecodeString(envelope.Attributes().Get("key"))
+	if err != nil || len(kid) == 0 {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	ciphertext, err = base64.StdEncoding.DecodeString(envelope.Text())
+	if err != nil {
+		return "", nil, nil, errMalformedEnvelope
+	}
+	return kid, wrappedKey, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWithAEAD encrypts plaintext prepending a random nonce to the returned ciphertext.
+func sealWithAEAD(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func openWithAEAD(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errMalformedEnvelope
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], aad)
+}
+
+// parseElements parses a raw XML sequence of elements.
+func parseElements(b []byte) ([]xmpp.XElement, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(b)+7))
+	buf.WriteString("<r>")
+	buf.Write(b)
+	buf.WriteString("</r>")
+
+	rootEl, err := xmpp.NewParser(buf, xmpp.DefaultMode, 0).ParseElement()
+	if err != nil {
+		return nil, err
+	}
+	return rootEl.Elements().All(), nil
+}
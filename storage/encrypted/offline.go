@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sxmpp/jackal/log"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+type encryptedOffline struct {
+	repository.Offline
+	kr *keyring
+
+	// serializes writes against key rotation, which needs to replace the message queue tail.
+	mu sync.Mutex
+}
+
+func (o *encryptedOffline) InsertOfflineMessage(ctx context.Context, message *offlinemodel.Message, username, domain string) error {
+	env, err := o.sealMessage(message.Message, username, domain)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.Offline.InsertOfflineMessage(ctx, &offlinemodel.Message{ID: message.ID, ExpiresAt: message.ExpiresAt, Message: env}, username, domain)
+}
+
+func (o *encryptedOffline) FetchOfflineMessages(ctx context.Context, username, domain string) ([]offlinemodel.Message, error) {
+	messages, err := o.Offline.FetchOfflineMessages(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		env := messages[i].Message.Elements().ChildNamespace(envelopeElementName, envelopeNamespace)
+		if env == nil {
+			continue // not encrypted yet
+		}
+		msg, err := o.openMessage(env, username, domain)
+		if err != nil {
+			return nil, err
+		}
+		messages[i].Message = msg
+	}
+	return messages, nil
+}
+
+func (o *encryptedOffline) DeleteOfflineMessagesByID(ctx context.Context, ids []string, username, domain string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.Offline.DeleteOfflineMessagesByID(ctx, ids, username, domain)
+}
+
+func (o *encryptedOffline) DeleteOfflineMessages(ctx context.Context, username, domain string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.Offline.DeleteOfflineMessages(ctx, username, domain)
+}
+
+func (o *encryptedOffline) DeleteExpiredOfflineMessages(ctx context.Context, at time.Time) ([]offlinemodel.Message, error) {
+	o.mu.Lock()
+	messages, err := o.Offline.DeleteExpiredOfflineMessages(ctx, at)
+	o.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	res := messages[:0]
+	for _, msg := range messages {
+		env := msg.Message.Elements().ChildNamespace(envelopeElementName, envelopeNamespace)
+		if env == nil {
+			res = append(res, msg)
+			continue
+		}
+		// offline queues are keyed by message recipient
+		toJID := msg.Message.ToJID()
+		opened, err := o.openMessage(env, toJID.Node(), toJID.Domain())
+		if err != nil {
+			log.Warnf("encrypted: unable to open expired offline message %s: %v", msg.ID, err)
+			continue
+		}
+		msg.Message = opened
+		res = append(res, msg)
+	}
+	return res, nil
+}
+
+// sealMessage returns an envelope message, only preserving original message addressing.
+func (o *encryptedOffline) sealMessage(message *xmpp.Message, username, domain string) (*xmpp.Message, error) {
+	env, err := o.kr.seal([]byte(message.String()), offlineAAD(username, domain))
+	if err != nil {
+		return nil, err
+	}
+	return envelopeMessage(message, env)
+}
+
+func (o *encryptedOffline) openMessage(env xmpp.XElement, username, domain string) (*xmpp.Message, error) {
+	b, err := o.kr.open(env, offlineAAD(username, domain))
+	if err != nil {
+		return nil, err
+	}
+	elems, err := parseElements(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) != 1 {
+		return nil, errors.New("encrypted: unexpected offline message content")
+	}
+	fromJID, _ := jid.NewWithString(elems[0].From(), true)
+	toJID, _ := jid.NewWithString(elems[0].To(), true)
+	return xmpp.NewMessageFromElement(elems[0], fromJID, toJID)
+}
+
+func envelopeMessage(message *xmpp.Message, env xmpp.XElement) (*xmpp.Message, error) {
+	msgEl := xmpp.NewElementName("message")
+	msgEl.AppendElement(env)
+	return xmpp.NewMessageFromElement(msgEl, message.FromJID(), message.ToJID())
+}
+
+func offlineAAD(username, domain string) string {
+	return "offline:" + userKey(username, domain)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type encryptedPrivate struct {
+	repository.Private
+	kr *keyring
+
+	// serializes writes against key rotation.
+	mu sync.Mutex
+}
+
+func (p *encryptedPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username, domain string) error {
+	env, err := p.sealPrivateXML(privateXML, namespace, username, domain)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Private.UpsertPrivateXML(ctx, []xmpp.XElement{env}, namespace, username, domain)
+}
+
+func (p *encryptedPrivate) FetchPrivateXML(ctx context.Context, namespace string, username, domain string) ([]xmpp.XElement, error) {
+	privateXML, err := p.Private.FetchPrivateXML(ctx, namespace, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(privateXML) != 1 || !isEnvelope(privateXML[0]) {
+		return privateXML, nil // not encrypted yet
+	}
+	b, err := p.kr.open(privateXML[0], privateAAD(namespace, username, domain))
+	if err != nil {
+		return nil, err
+	}
+	return parseElements(b)
+}
+
+func (p *encryptedPrivate) sealPrivateXML(privateXML []xmpp.XElement, namespace string, username, domain string) (*xmpp.Element, error) {
+	buf := bytes.NewBuffer(nil)
+	for _, elem := range privateXML {
+		if err := elem.ToXML(buf, true); err != nil {
+			return nil, err
+		}
+	}
+	return p.kr.seal(buf.Bytes(), privateAAD(namespace, username, domain))
+}
+
+func privateAAD(namespace, username, domain string) string {
+	return "private:" + userKey(username, domain) + ":" + namespace
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+type encryptedPubSub struct {
+	repository.PubSub
+	kr *keyring
+
+	// serializes writes against key rotation.
+	mu sync.Mutex
+}
+
+func (p *encryptedPubSub) DeleteNode(ctx context.Context, host, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.PubSub.DeleteNode(ctx, host, name)
+}
+
+func (p *encryptedPubSub) UpsertNodeItem(ctx context.Context, item *pubsubmodel.Item, host, name string, maxNodeItems int) error {
+	if item.Payload != nil {
+		env, err := p.kr.seal([]byte(item.Payload.String()), pubSubItemAAD(host, name, item.ID))
+		if err != nil {
+			return err
+		}
+		item = &pubsubmodel.Item{ID: item.ID, Publisher: item.Publisher, Payload: env}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.PubSub.UpsertNodeItem(ctx, item, host, name, maxNodeItems)
+}
+
+func (p *encryptedPubSub) FetchNodeItems(ctx context.Context, host, name string) ([]pubsubmodel.Item, error) {
+	items, err := p.PubSub.FetchNodeItems(ctx, host, name)
+	if err != nil {
+		return nil, err
+	}
+	return p.openItems(items, host, name)
+}
+
+func (p *encryptedPubSub) FetchNodeItemsWithIDs(ctx context.Context, host, name string, identifiers []string) ([]pubsubmodel.Item, error) {
+	items, err := p.PubSub.FetchNodeItemsWithIDs(ctx, host, name, identifiers)
+	if err != nil {
+		return nil, err
+	}
+	return p.openItems(items, host, name)
+}
+
+func (p *encryptedPubSub) FetchNodeLastItem(ctx context.Context, host, name string) (*pubsubmodel.Item, error) {
+	item, err := p.PubSub.FetchNodeLastItem(ctx, host, name)
+	if err != nil || item == nil {
+		return item, err
+	}
+	if err := p.openItem(item, host, name); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (p *encryptedPubSub) openItems(items []pubsubmodel.Item, host, name string) ([]pubsubmodel.Item, error) {
+	for i := range items {
+		if err := p.openItem(&items[i], host, name); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (p *encryptedPubSub) openItem(item *pubsubmodel.Item, host, name string) error {
+	if !isEnvelope(item.Payload) {
+		return nil // not encrypted yet
+	}
+	b, err := p.kr.open(item.Payload, pubSubItemAAD(host, name, item.ID))
+	if err != nil {
+		return err
+	}
+	elems, err := parseElements(b)
+	if err != nil {
+		return err
+	}
+	if len(elems) != 1 {
+		return errors.New("encrypted: unexpected pubsub item content")
+	}
+	item.Payload = elems[0]
+	return nil
+}
+
+func pubSubItemAAD(host, name, id string) string {
+	return "pubsub:" + host + ":" + name + ":" + id
+}
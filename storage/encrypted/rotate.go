@@ -0,0 +1,288 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/model"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// Rotate walks over every stored entity belonging to the given domains, along with every pubsub item
+// and virtual host private key, re-wrapping those envelopes not sealed with the active key-encryption key and encrypting those
+// stored in plain text. It returns the number of updated entities.
+//
+// Archived messages are never rewritten, so retired keys must be kept in configuration until
+// archive retention has expired every message sealed with them. Otherwise, once completed, retired keys
+// can be safely removed from configuration.
+func (c *Container) Rotate(ctx context.Context, domains []string) (int, error) {
+	var count int
+	for _, domain := range domains {
+		usernames, err := c.Container.User().FetchUsernames(ctx, domain)
+		if err != nil {
+			return count, err
+		}
+		for _, username := range usernames {
+			n, err := c.rotateUser(ctx, username, domain)
+			count += n
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	n, err := c.rotatePubSubItems(ctx)
+	count += n
+	if err != nil {
+		return count, err
+	}
+	n, err = c.rotateHosts(ctx)
+	count += n
+	if err != nil {
+		return count, err
+	}
+	log.Infof("encrypted: key rotation completed... %d entities updated", count)
+	return count, nil
+}
+
+func (c *Container) rotateUser(ctx context.Context, username, domain string) (int, error) {
+	var count int
+
+	n, err := c.rotateVCard(ctx, username, domain)
+	count += n
+	if err != nil {
+		return count, err
+	}
+	n, err = c.rotatePrivateXML(ctx, username, domain)
+	count += n
+	if err != nil {
+		return count, err
+	}
+	n, err = c.rotateOfflineMessages(ctx, username, domain)
+	return count + n, err
+}
+
+func (c *Container) rotateVCard(ctx context.Context, username, domain string) (int, error) {
+	c.vCard.mu.Lock()
+	defer c.vCard.mu.Unlock()
+
+	vCard, err := c.Container.VCard().FetchVCard(ctx, username, domain)
+	if err != nil || vCard == nil {
+		return 0, err
+	}
+	env, ok, err := c.rotateElement(vCard, vCardAAD(username, domain))
+	if err != nil || !ok {
+		return 0, err
+	}
+	if err := c.Container.VCard().UpsertVCard(ctx, env, username, domain); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (c *Container) rotatePrivateXML(ctx context.Context, username, domain string) (int, error) {
+	c.priv.mu.Lock()
+	defer c.priv.mu.Unlock()
+
+	namespaces, err := c.Container.Private().FetchPrivateXMLNamespaces(ctx, username, domain)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, ns := range namespaces {
+		privateXML, err := c.Container.Private().FetchPrivateXML(ctx, ns, username, domain)
+		if err != nil {
+			return count, err
+		}
+		var env *xmpp.Element
+		if len(privateXML) == 1 && isEnvelope(privateXML[0]) {
+			var ok bool
+			env, ok, err = c.kr.rewrap(privateXML[0])
+			if err != nil {
+				return count, err
+			}
+			if !ok {
+				continue
+			}
+		} else {
+			env, err = c.priv.sealPrivateXML(privateXML, ns, username, domain)
+			if err != nil {
+				return count, err
+			}
+		}
+		if err := c.Container.Private().UpsertPrivateXML(ctx, []xmpp.XElement{env}, ns, username, domain); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (c *Container) rotateOfflineMessages(ctx context.Context, username, domain string) (int, error) {
+	c.offline.mu.Lock()
+	defer c.offline.mu.Unlock()
+
+	messages, err := c.Container.Offline().FetchOfflineMessages(ctx, username, domain)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	first := -1
+	rotated := make([]*offlinemodel.Message, len(messages))
+	for i := range messages {
+		rotated[i] = &messages[i]
+		msg := messages[i].Message
+
+		var rotatedMsg *xmpp.Message
+		if env := msg.Elements().ChildNamespace(envelopeElementName, envelopeNamespace); env != nil {
+			newEnv, ok, err := c.kr.rewrap(env)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				continue
+			}
+			rotatedMsg, err = envelopeMessage(msg, newEnv)
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			rotatedMsg, err = c.offline.sealMessage(msg, username, domain)
+			if err != nil {
+				return 0, err
+			}
+		}
+		rotated[i] = &offlinemodel.Message{ID: messages[i].ID, ExpiresAt: messages[i].ExpiresAt, Message: rotatedMsg}
+		if first == -1 {
+			first = i
+		}
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	// replace queue tail one message at a time, so that its order is preserved and
+	// no more than a single message is at stake in case of failure.
+	for _, msg := range rotated[first:] {
+		if err := c.Container.Offline().DeleteOfflineMessagesByID(ctx, []string{msg.ID}, username, domain); err != nil {
+			return 0, err
+		}
+		if err := c.Container.Offline().InsertOfflineMessage(ctx, msg, username, domain); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (c *Container) rotatePubSubItems(ctx context.Context) (int, error) {
+	hosts, err := c.Container.PubSub().FetchHosts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, host := range hosts {
+		nodes, err := c.Container.PubSub().FetchNodes(ctx, host)
+		if err != nil {
+			return count, err
+		}
+		for _, node := range nodes {
+			n, err := c.rotateNodeItems(ctx, host, &node)
+			count += n
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+func (c *Container) rotateNodeItems(ctx context.Context, host string, node *pubsubmodel.Node) (int, error) {
+	c.pubSub.mu.Lock()
+	defer c.pubSub.mu.Unlock()
+
+	items, err := c.Container.PubSub().FetchNodeItems(ctx, host, node.Name)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, item := range items {
+		if item.Payload == nil {
+			continue
+		}
+		env, ok, err := c.rotateElement(item.Payload, pubSubItemAAD(host, node.Name, item.ID))
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			continue
+		}
+		rotatedItem := &pubsubmodel.Item{ID: item.ID, Publisher: item.Publisher, Payload: env}
+		if err := c.Container.PubSub().UpsertNodeItem(ctx, rotatedItem, host, node.Name, int(node.Options.MaxItems)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// rotateElement returns the active key envelope associated to a stored element, whether it was already
+// encrypted or not. Returned boolean value is false when no update is required.
+func (c *Container) rotateElement(elem xmpp.XElement, aad string) (*xmpp.Element, bool, error) {
+	if isEnvelope(elem) {
+		return c.kr.rewrap(elem)
+	}
+	env, err := c.kr.seal([]byte(elem.String()), aad)
+	if err != nil {
+		return nil, false, err
+	}
+	return env, true, nil
+}
+
+func (c *Container) rotateHosts(ctx context.Context) (int, error) {
+	c.host.mu.Lock()
+	defer c.host.mu.Unlock()
+
+	hosts, err := c.Container.Host().FetchHosts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for i := range hosts {
+		host := &hosts[i]
+
+		var rotated *model.Host
+		if isSealedPrivateKey(host.PrivateKey) {
+			elems, err := parseElements(host.PrivateKey)
+			if err != nil {
+				return count, err
+			}
+			if len(elems) != 1 {
+				return count, errMalformedEnvelope
+			}
+			env, ok, err := c.kr.rewrap(elems[0])
+			if err != nil {
+				return count, err
+			}
+			if !ok {
+				continue
+			}
+			rotated = &model.Host{Name: host.Name, Certificate: host.Certificate, PrivateKey: []byte(env.String()), Enabled: host.Enabled}
+		} else {
+			rotated, err = c.host.sealHost(host)
+			if err != nil {
+				return count, err
+			}
+		}
+		if err := c.Container.Host().UpsertHost(ctx, rotated); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+type encryptedVCard struct {
+	repository.VCard
+	kr *keyring
+
+	// serializes writes against key rotation.
+	mu sync.Mutex
+}
+
+func (v *encryptedVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username, domain string) error {
+	env, err := v.kr.seal([]byte(vCard.String()), vCardAAD(username, domain))
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.VCard.UpsertVCard(ctx, env, username, domain)
+}
+
+func (v *encryptedVCard) FetchVCard(ctx context.Context, username, domain string) (xmpp.XElement, error) {
+	vCard, err := v.VCard.FetchVCard(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if !isEnvelope(vCard) {
+		return vCard, nil // not encrypted yet
+	}
+	b, err := v.kr.open(vCard, vCardAAD(username, domain))
+	if err != nil {
+		return nil, err
+	}
+	elems, err := parseElements(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) != 1 {
+		return nil, errors.New("encrypted: unexpected vCard content")
+	}
+	return elems[0], nil
+}
+
+func vCardAAD(username, domain string) string {
+	return "vcard:" + userKey(username, domain)
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"strings"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+// accountDeleter is implemented by every in-memory repository holding entities associated to a user account.
+type accountDeleter interface {
+	deleteAccount(username, domain string) error
+}
+
+func (m *Roster) deleteAccount(username, domain string) error {
+	user := userKey(username, domain)
+	return m.inWriteLock(func() error {
+		delete(m.b, rosterItemsKey(user))
+		delete(m.b, rosterVersionKey(user))
+		delete(m.b, rosterNotificationsKey(user))
+		delete(m.b, rosterGroupsKey(user))
+
+		for k := range m.b {
+			switch {
+			case strings.HasPrefix(k, rosterItemsKey("")):
+				if err := m.deleteRosterItemsWithJID(strings.TrimPrefix(k, rosterItemsKey("")), user); err != nil {
+					return err
+				}
+			case strings.HasPrefix(k, rosterNotificationsKey("")):
+				if err := m.deleteRosterNotificationsWithJID(strings.TrimPrefix(k, rosterNotificationsKey("")), user); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Roster) deleteRosterItemsWithJID(user, jid string) error {
+	ris, err := m.fetchRosterItems(user)
+	if err != nil {
+		return err
+	}
+	var keep []rostermodel.Item
+	for _, ri := range ris {
+		if ri.JID != jid {
+			keep = append(keep, ri)
+		}
+	}
+	if len(keep) == len(ris) {
+		return nil
+	}
+	if err := m.upsertRosterItems(keep, user); err != nil {
+		return err
+	}
+	if err := m.upsertRosterGroups(user, keep); err != nil {
+		return err
+	}
+	rv, err := m.fetchRosterVersion(user)
+	if err != nil {
+		return err
+	}
+	rv.Ver++
+	rv.DeletionVer = rv.Ver
+	return m.upsertRosterVersion(rv, user)
+}
+
+func (m *Roster) deleteRosterNotificationsWithJID(contact, jid string) error {
+	rns, err := m.fetchRosterNotifications(contact)
+	if err != nil {
+		return err
+	}
+	var keep []rostermodel.Notification
+	for _, rn := range rns {
+		if rn.JID != jid {
+			keep = append(keep, rn)
+		}
+	}
+	if len(keep) == len(rns) {
+		return nil
+	}
+	return m.upsertRosterNotifications(keep, contact)
+}
+
+func (m *Presences) deleteAccount(username, domain string) error {
+	prefix := "presences:" + userKey(username, domain)
+	return m.inWriteLock(func() error {
+		for k := range m.b {
+			if k == prefix || strings.HasPrefix(k, prefix+"/") {
+				delete(m.b, k)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *VCard) deleteAccount(username, domain string) error {
+	return m.deleteKey(vCardKey(username, domain))
+}
+
+func (m *Private) deleteAccount(username, domain string) error {
+	prefix := privateStorageKey(username, domain, "")
+	return m.inWriteLock(func() error {
+		for k := range m.b {
+			if strings.HasPrefix(k, prefix) {
+				delete(m.b, k)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *BlockList) deleteAccount(username, domain string) error {
+	return m.deleteKey(blockListItemKey(username, domain))
+}
+
+func (m *Offline) deleteAccount(username, domain string) error {
+	return m.deleteKey(offlineMessageKey(username, domain))
+}
+
+func (m *Archive) deleteAccount(username, domain string) error {
+	return m.inWriteLock(func() error {
+		delete(m.b, archiveMessagesKey(username, domain))
+		delete(m.b, archivePreferencesKey(username, domain))
+		return nil
+	})
+}
+
+func (m *Push) deleteAccount(username, domain string) error {
+	return m.deleteKey(pushRegistrationsKey(username, domain))
+}
+
+func (m *PubSub) deleteAccount(username, domain string) error {
+	host := userKey(username, domain)
+	return m.inWriteLock(func() error {
+		// delete owned nodes
+		var nodes []pubsubmodel.Node
+		if b := m.b[pubSubHostNodesKey(host)]; b != nil {
+			if err := serializer.DeserializeSlice(b, &nodes); err != nil {
+				return err
+			}
+		}
+		for _, n := range nodes {
+			delete(m.b, pubSubNodesKey(host, n.Name))
+			delete(m.b, pubSubItemsKey(host, n.Name))
+			delete(m.b, pubSubAffiliationsKey(host, n.Name))
+			delete(m.b, pubSubSubscriptionsKey(host, n.Name))
+		}
+		delete(m.b, pubSubHostNodesKey(host))
+
+		// delete affiliations and subscriptions to foreign nodes
+		for k, b := range m.b {
+			switch {
+			case strings.HasPrefix(k, "pubSubAffiliations:"):
+				var affiliations, keep []pubsubmodel.Affiliation
+				if err := serializer.DeserializeSlice(b, &affiliations); err != nil {
+					return err
+				}
+				for _, aff := range affiliations {
+					if aff.JID != host {
+						keep = append(keep, aff)
+					}
+				}
+				if len(keep) == len(affiliations) {
+					continue
+				}
+				b, err := serializer.SerializeSlice(&keep)
+				if err != nil {
+					return err
+				}
+				m.b[k] = b
+
+			case strings.HasPrefix(k, "pubSubSubscriptions:"):
+				var subscriptions, keep []pubsubmodel.Subscription
+				if err := serializer.DeserializeSlice(b, &subscriptions); err != nil {
+					return err
+				}
+				for _, sub := range subscriptions {
+					if sub.JID != host {
+						keep = append(keep, sub)
+					}
+				}
+				if len(keep) == len(subscriptions) {
+					continue
+				}
+				b, err := serializer.SerializeSlice(&keep)
+				if err != nil {
+					return err
+				}
+				m.b[k] = b
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Muc) deleteAccount(username, domain string) error {
+	userJID := userKey(username, domain)
+	return m.inWriteLock(func() error {
+		for k, b := range m.b {
+			switch {
+			case strings.HasPrefix(k, mucRoomsPrefix):
+				var room mucmodel.Room
+				if err := serializer.Deserialize(b, &room); err != nil {
+					return err
+				}
+				if room.Affiliation(userJID) == mucmodel.None {
+					continue
+				}
+				room.SetAffiliation(userJID, mucmodel.None)
+				b, err := serializer.Serialize(&room)
+				if err != nil {
+					return err
+				}
+				m.b[k] = b
+
+			case strings.HasPrefix(k, "mucNickReservations:"):
+				var reservations, keep []mucmodel.NickReservation
+				if err := serializer.DeserializeSlice(b, &reservations); err != nil {
+					return err
+				}
+				for _, r := range reservations {
+					if r.JID != userJID {
+						keep = append(keep, r)
+					}
+				}
+				if len(keep) == len(reservations) {
+					continue
+				}
+				b, err := serializer.SerializeSlice(&keep)
+				if err != nil {
+					return err
+				}
+				m.b[k] = b
+			}
+		}
+		return nil
+	})
+}
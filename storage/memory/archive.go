@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+// Archive represents an in-memory message archive storage.
+type Archive struct {
+	*memoryStorage
+}
+
+// NewArchive returns an instance of Archive in-memory storage.
+func NewArchive() *Archive {
+	return &Archive{memoryStorage: newStorage()}
+}
+
+// InsertArchiveMessage appends a new message into its owner archive.
+func (m *Archive) InsertArchiveMessage(_ context.Context, message *archivemodel.Message) error {
+	return m.updateInWriteLock(archiveMessagesKey(message.Username, message.Domain), func(b []byte) ([]byte, error) {
+		var messages []archivemodel.Message
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, *message)
+		return serializer.SerializeSlice(&messages)
+	})
+}
+
+// FetchArchiveMessages retrieves in chronological order those archived messages satisfying a query.
+func (m *Archive) FetchArchiveMessages(_ context.Context, query *archivemodel.Query, username, domain string) ([]archivemodel.Message, error) {
+	var messages []archivemodel.Message
+	if _, err := m.getEntities(archiveMessagesKey(username, domain), &messages); err != nil {
+		return nil, err
+	}
+	return query.Filter(messages), nil
+}
+
+// CountArchiveMessages returns the number of archived messages satisfying a query filter, paging parameters aside.
+func (m *Archive) CountArchiveMessages(_ context.Context, query *archivemodel.Query, username, domain string) (int, error) {
+	var messages []archivemodel.Message
+	if _, err := m.getEntities(archiveMessagesKey(username, domain), &messages); err != nil {
+		return 0, err
+	}
+	var count int
+	for i := range messages {
+		if query.Matches(&messages[i]) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ArchiveMessageExists returns whether or not a message identifier is contained in a user archive.
+func (m *Archive) ArchiveMessageExists(_ context.Context, id, username, domain string) (bool, error) {
+	var messages []archivemodel.Message
+	if _, err := m.getEntities(archiveMessagesKey(username, domain), &messages); err != nil {
+		return false, err
+	}
+	for _, msg := range messages {
+		if msg.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteArchiveMessages removes from every archive those messages archived before a given time.
+func (m *Archive) DeleteArchiveMessages(_ context.Context, before time.Time) error {
+	return m.inWriteLock(func() error {
+		for k, b := range m.b {
+			if !strings.HasPrefix(k, archiveMessagesPrefix) {
+				continue
+			}
+			var messages, keep []archivemodel.Message
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				if !msg.Stamp.Before(before) {
+					keep = append(keep, msg)
+				}
+			}
+			if len(keep) == len(messages) {
+				continue
+			}
+			if len(keep) == 0 {
+				delete(m.b, k)
+				continue
+			}
+			b, err := serializer.SerializeSlice(&keep)
+			if err != nil {
+				return err
+			}
+			m.b[k] = b
+		}
+		return nil
+	})
+}
+
+// UpsertArchivePreferences inserts new user archiving preferences into storage,
+// or updates them in case they've been previously inserted.
+func (m *Archive) UpsertArchivePreferences(_ context.Context, prefs *archivemodel.Preferences, username, domain string) error {
+	return m.saveEntity(archivePreferencesKey(username, domain), prefs)
+}
+
+// FetchArchivePreferences retrieves from storage user archiving preferences.
+func (m *Archive) FetchArchivePreferences(_ context.Context, username, domain string) (*archivemodel.Preferences, error) {
+	var prefs archivemodel.Preferences
+	ok, err := m.getEntity(archivePreferencesKey(username, domain), &prefs)
+	switch err {
+	case nil:
+		if ok {
+			return &prefs, nil
+		}
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+const archiveMessagesPrefix = "archiveMessages:"
+
+func archiveMessagesKey(username, domain string) string {
+	return archiveMessagesPrefix + userKey(username, domain)
+}
+
+func archivePreferencesKey(username, domain string) string {
+	return "archivePreferences:" + userKey(username, domain)
+}
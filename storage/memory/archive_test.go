@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_InsertArchiveMessage(t *testing.T) {
+	s := NewArchive()
+	msg := testArchiveMessage("1", "romeo@jackal.im/garden", time.Now())
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.InsertArchiveMessage(context.Background(), msg))
+	DisableMockedError()
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), msg))
+
+	ok, err := s.ArchiveMessageExists(context.Background(), "1", "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, _ = s.ArchiveMessageExists(context.Background(), "2", "juliet", "jackal.im")
+	require.False(t, ok)
+}
+
+func TestMemoryStorage_FetchArchiveMessages(t *testing.T) {
+	s := NewArchive()
+	now := time.Now()
+	_ = s.InsertArchiveMessage(context.Background(), testArchiveMessage("1", "romeo@jackal.im/garden", now))
+	_ = s.InsertArchiveMessage(context.Background(), testArchiveMessage("2", "nurse@jackal.im/home", now.Add(time.Second)))
+	_ = s.InsertArchiveMessage(context.Background(), testArchiveMessage("3", "romeo@jackal.im/orchard", now.Add(2*time.Second)))
+
+	EnableMockedError()
+	_, err := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	messages, err := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im"}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "1", messages[0].ID)
+	require.Equal(t, "3", messages[1].ID)
+	require.NotNil(t, messages[1].Message)
+
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{Last: true, Max: 1}, "juliet", "jackal.im")
+	require.Len(t, messages, 1)
+	require.Equal(t, "3", messages[0].ID)
+
+	cnt, err := s.CountArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im", Max: 1}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, cnt)
+}
+
+func TestMemoryStorage_DeleteArchiveMessages(t *testing.T) {
+	s := NewArchive()
+	now := time.Now()
+	_ = s.InsertArchiveMessage(context.Background(), testArchiveMessage("1", "romeo@jackal.im/garden", now.Add(-48*time.Hour)))
+	_ = s.InsertArchiveMessage(context.Background(), testArchiveMessage("2", "romeo@jackal.im/garden", now))
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteArchiveMessages(context.Background(), now.Add(-24*time.Hour)))
+	DisableMockedError()
+	require.Nil(t, s.DeleteArchiveMessages(context.Background(), now.Add(-24*time.Hour)))
+
+	messages, _ := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Len(t, messages, 1)
+	require.Equal(t, "2", messages[0].ID)
+}
+
+func TestMemoryStorage_ArchivePreferences(t *testing.T) {
+	s := NewArchive()
+	prefs := &archivemodel.Preferences{Default: archivemodel.Roster, Never: []string{"tybalt@jackal.im"}}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertArchivePreferences(context.Background(), prefs, "juliet", "jackal.im"))
+	DisableMockedError()
+
+	p, err := s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, p)
+
+	require.Nil(t, s.UpsertArchivePreferences(context.Background(), prefs, "juliet", "jackal.im"))
+
+	p, err = s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, archivemodel.Roster, p.Default)
+	require.Equal(t, []string{"tybalt@jackal.im"}, p.Never)
+}
+
+func testArchiveMessage(id, with string, stamp time.Time) *archivemodel.Message {
+	from, _ := jid.NewWithString(with, true)
+	to, _ := jid.NewWithString("juliet@jackal.im/balcony", true)
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Wherefore art thou Romeo?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	return &archivemodel.Message{
+		ID:       id,
+		Username: "juliet",
+		Domain:   "jackal.im",
+		With:     with,
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
@@ -24,7 +24,7 @@ func NewBlockList() *BlockList {
 
 // InsertBlockListItem inserts a block list item entity into storage if not previously inserted.
 func (m *BlockList) InsertBlockListItem(_ context.Context, item *model.BlockListItem) error {
-	return m.updateInWriteLock(blockListItemKey(item.Username), func(b []byte) ([]byte, error) {
+	return m.updateInWriteLock(blockListItemKey(item.Username, item.Domain), func(b []byte) ([]byte, error) {
 		var items []model.BlockListItem
 		if len(b) > 0 {
 			if err := serializer.DeserializeSlice(b, &items); err != nil {
@@ -48,7 +48,7 @@ func (m *BlockList) InsertBlockListItem(_ context.Context, item *model.BlockList
 
 // DeleteBlockListItem deletes a block list item entity from storage.
 func (m *BlockList) DeleteBlockListItem(_ context.Context, item *model.BlockListItem) error {
-	return m.updateInWriteLock(blockListItemKey(item.Username), func(b []byte) ([]byte, error) {
+	return m.updateInWriteLock(blockListItemKey(item.Username, item.Domain), func(b []byte) ([]byte, error) {
 		var items []model.BlockListItem
 		if len(b) > 0 {
 			if err := serializer.DeserializeSlice(b, &items); err != nil {
@@ -71,15 +71,15 @@ func (m *BlockList) DeleteBlockListItem(_ context.Context, item *model.BlockList
 }
 
 // FetchBlockListItems retrieves from storage all block list item entities associated to a given user.
-func (m *BlockList) FetchBlockListItems(_ context.Context, username string) ([]model.BlockListItem, error) {
+func (m *BlockList) FetchBlockListItems(_ context.Context, username, domain string) ([]model.BlockListItem, error) {
 	var items []model.BlockListItem
-	_, err := m.getEntities(blockListItemKey(username), &items)
+	_, err := m.getEntities(blockListItemKey(username, domain), &items)
 	if err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
-func blockListItemKey(username string) string {
-	return "blockListItems:" + username
+func blockListItemKey(username, domain string) string {
+	return "blockListItems:" + userKey(username, domain)
 }
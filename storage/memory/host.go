@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+const hostsKey = "hosts"
+
+// Host represents an in-memory host storage.
+type Host struct {
+	*memoryStorage
+}
+
+// NewHost returns an instance of Host in-memory storage.
+func NewHost() *Host {
+	return &Host{memoryStorage: newStorage()}
+}
+
+// UpsertHost inserts a new host entity into storage, or updates it in case it's been previously inserted.
+func (m *Host) UpsertHost(_ context.Context, host *model.Host) error {
+	return m.updateInWriteLock(hostsKey, func(b []byte) ([]byte, error) {
+		var hosts []model.Host
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &hosts); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, h := range hosts {
+			if h.Name == host.Name {
+				hosts[i] = *host
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			hosts = append(hosts, *host)
+		}
+		return serializer.SerializeSlice(&hosts)
+	})
+}
+
+// DeleteHost deletes a host entity from storage.
+func (m *Host) DeleteHost(_ context.Context, name string) error {
+	return m.updateInWriteLock(hostsKey, func(b []byte) ([]byte, error) {
+		var hosts []model.Host
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &hosts); err != nil {
+				return nil, err
+			}
+		}
+		for i, h := range hosts {
+			if h.Name == name {
+				hosts = append(hosts[:i], hosts[i+1:]...)
+				return serializer.SerializeSlice(&hosts)
+			}
+		}
+		return b, nil // not present
+	})
+}
+
+// FetchHost retrieves from storage a host entity.
+func (m *Host) FetchHost(ctx context.Context, name string) (*model.Host, error) {
+	hosts, err := m.FetchHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		if h.Name == name {
+			return &h, nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchHosts retrieves from storage all host entities.
+func (m *Host) FetchHosts(_ context.Context) ([]model.Host, error) {
+	var hosts []model.Host
+	_, err := m.getEntities(hostsKey, &hosts)
+	if err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
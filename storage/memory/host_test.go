@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_UpsertHost(t *testing.T) {
+	h := model.Host{Name: "jackal.im", Enabled: true}
+	s := NewHost()
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertHost(context.Background(), &h))
+	DisableMockedError()
+	require.Nil(t, s.UpsertHost(context.Background(), &h))
+
+	h.Enabled = false
+	require.Nil(t, s.UpsertHost(context.Background(), &h))
+
+	hosts, err := s.FetchHosts(context.Background())
+	require.Nil(t, err)
+	require.Len(t, hosts, 1)
+	require.False(t, hosts[0].Enabled)
+}
+
+func TestMemoryStorage_FetchHost(t *testing.T) {
+	s := NewHost()
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "jackal.im", Enabled: true})
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "example.org", Enabled: true})
+
+	EnableMockedError()
+	_, err := s.FetchHost(context.Background(), "jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	h, err := s.FetchHost(context.Background(), "example.org")
+	require.Nil(t, err)
+	require.NotNil(t, h)
+	require.Equal(t, "example.org", h.Name)
+
+	h, err = s.FetchHost(context.Background(), "example.net")
+	require.Nil(t, err)
+	require.Nil(t, h)
+}
+
+func TestMemoryStorage_DeleteHost(t *testing.T) {
+	s := NewHost()
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "jackal.im", Enabled: true})
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteHost(context.Background(), "jackal.im"))
+	DisableMockedError()
+	require.Nil(t, s.DeleteHost(context.Background(), "jackal.im"))
+
+	hosts, err := s.FetchHosts(context.Background())
+	require.Nil(t, err)
+	require.Len(t, hosts, 0)
+}
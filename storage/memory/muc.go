@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+// Muc represents an in-memory multi-user chat storage.
+type Muc struct {
+	*memoryStorage
+}
+
+// NewMuc returns an instance of Muc in-memory storage.
+func NewMuc() *Muc {
+	return &Muc{memoryStorage: newStorage()}
+}
+
+// UpsertMucRoom inserts a new room into storage, or updates it in case it's been previously inserted.
+func (m *Muc) UpsertMucRoom(_ context.Context, room *mucmodel.Room) error {
+	return m.saveEntity(mucRoomKey(room.JID), room)
+}
+
+// DeleteMucRoom deletes a room from storage, along with all its nick reservations.
+func (m *Muc) DeleteMucRoom(_ context.Context, roomJID string) error {
+	return m.inWriteLock(func() error {
+		delete(m.b, mucRoomKey(roomJID))
+		delete(m.b, mucNickReservationsKey(roomJID))
+		return nil
+	})
+}
+
+// FetchMucRoom retrieves from storage a room entity.
+func (m *Muc) FetchMucRoom(_ context.Context, roomJID string) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	ok, err := m.getEntity(mucRoomKey(roomJID), &room)
+	switch err {
+	case nil:
+		if !ok {
+			return nil, nil
+		}
+		return &room, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchMucRooms retrieves from storage all rooms hosted by a multi-user chat service.
+func (m *Muc) FetchMucRooms(_ context.Context, service string) ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if err := m.inReadLock(func() error {
+		for k, b := range m.b {
+			if !strings.HasPrefix(k, mucRoomsPrefix) || !strings.HasSuffix(k, "@"+service) {
+				continue
+			}
+			var room mucmodel.Room
+			if err := serializer.Deserialize(b, &room); err != nil {
+				return err
+			}
+			rooms = append(rooms, room)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].JID < rooms[j].JID })
+	return rooms, nil
+}
+
+// UpsertMucNickReservation inserts a new nick reservation into storage,
+// or updates it in case user already reserved a nick within the room.
+func (m *Muc) UpsertMucNickReservation(_ context.Context, reservation *mucmodel.NickReservation) error {
+	return m.updateInWriteLock(mucNickReservationsKey(reservation.RoomJID), func(b []byte) ([]byte, error) {
+		var reservations []mucmodel.NickReservation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &reservations); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, r := range reservations {
+			if r.JID == reservation.JID {
+				reservations[i] = *reservation
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			reservations = append(reservations, *reservation)
+		}
+		return serializer.SerializeSlice(&reservations)
+	})
+}
+
+// DeleteMucNickReservation deletes a user nick reservation from storage.
+func (m *Muc) DeleteMucNickReservation(_ context.Context, roomJID, jid string) error {
+	return m.updateInWriteLock(mucNickReservationsKey(roomJID), func(b []byte) ([]byte, error) {
+		var reservations []mucmodel.NickReservation
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &reservations); err != nil {
+				return nil, err
+			}
+		}
+		for i, r := range reservations {
+			if r.JID == jid {
+				reservations = append(reservations[:i], reservations[i+1:]...)
+				return serializer.SerializeSlice(&reservations)
+			}
+		}
+		return b, nil // not present
+	})
+}
+
+// FetchMucNickReservations retrieves from storage all nick reservations associated to a room.
+func (m *Muc) FetchMucNickReservations(_ context.Context, roomJID string) ([]mucmodel.NickReservation, error) {
+	var reservations []mucmodel.NickReservation
+	if _, err := m.getEntities(mucNickReservationsKey(roomJID), &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+const mucRoomsPrefix = "mucRooms:"
+
+func mucRoomKey(roomJID string) string {
+	return mucRoomsPrefix + roomJID
+}
+
+func mucNickReservationsKey(roomJID string) string {
+	return "mucNickReservations:" + roomJID
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_MucRooms(t *testing.T) {
+	s := NewMuc()
+	r1 := &mucmodel.Room{JID: "lobby@muc.jackal.im", Config: mucmodel.RoomConfig{Name: "Lobby", Persistent: true}, Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.Owner}}
+	r2 := &mucmodel.Room{JID: "garden@muc.jackal.im", Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.Owner}}
+	r3 := &mucmodel.Room{JID: "garden@conference.jackal.im"}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertMucRoom(context.Background(), r1))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r1))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r2))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r3))
+
+	r1.Subject = "Welcome!"
+	require.Nil(t, s.UpsertMucRoom(context.Background(), r1))
+
+	room, err := s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, r1, room)
+
+	room, err = s.FetchMucRoom(context.Background(), "hall@muc.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	rooms, err := s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "garden@muc.jackal.im", rooms[0].JID)
+	require.Equal(t, "lobby@muc.jackal.im", rooms[1].JID)
+
+	EnableMockedError()
+	_, err = s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Equal(t, ErrMocked, err)
+	require.Equal(t, ErrMocked, s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im"))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	require.Nil(t, s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im"))
+
+	room, _ = s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, room)
+	reservations, _ := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 0)
+}
+
+func TestMemoryStorage_MucNickReservations(t *testing.T) {
+	s := NewMuc()
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	DisableMockedError()
+
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "romeo@jackal.im", Nick: "romeo"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "sxmpp"}))
+
+	reservations, err := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, reservations, 2)
+	require.Equal(t, "sxmpp", reservations[0].Nick)
+
+	require.Nil(t, s.DeleteMucNickReservation(context.Background(), "lobby@muc.jackal.im", "ortuman@jackal.im"))
+	reservations, _ = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 1)
+	require.Equal(t, "romeo", reservations[0].Nick)
+
+	EnableMockedError()
+	_, err = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+}
@@ -7,9 +7,11 @@ package memorystorage
 
 import (
 	"context"
+	"strings"
+	"time"
 
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 	"github.com/sxmpp/jackal/model/serializer"
-	"github.com/sxmpp/jackal/xmpp"
 )
 
 // Offline represents an in-memory offline storage.
@@ -23,9 +25,9 @@ func NewOffline() *Offline {
 }
 
 // InsertOfflineMessage inserts a new message element into user's offline queue.
-func (m *Offline) InsertOfflineMessage(_ context.Context, message *xmpp.Message, username string) error {
-	return m.updateInWriteLock(offlineMessageKey(username), func(b []byte) ([]byte, error) {
-		var messages []xmpp.Message
+func (m *Offline) InsertOfflineMessage(_ context.Context, message *offlinemodel.Message, username, domain string) error {
+	return m.updateInWriteLock(offlineMessageKey(username, domain), func(b []byte) ([]byte, error) {
+		var messages []offlinemodel.Message
 		if len(b) > 0 {
 			if err := serializer.DeserializeSlice(b, &messages); err != nil {
 				return nil, err
@@ -42,19 +44,33 @@ func (m *Offline) InsertOfflineMessage(_ context.Context, message *xmpp.Message,
 }
 
 // CountOfflineMessages returns current length of user's offline queue.
-func (m *Offline) CountOfflineMessages(_ context.Context, username string) (int, error) {
-	var messages []xmpp.Message
-	_, err := m.getEntities(offlineMessageKey(username), &messages)
+func (m *Offline) CountOfflineMessages(_ context.Context, username, domain string) (int, error) {
+	var messages []offlinemodel.Message
+	_, err := m.getEntities(offlineMessageKey(username, domain), &messages)
 	if err != nil {
 		return 0, err
 	}
 	return len(messages), nil
 }
 
+// FetchOfflineMessagesSize returns the total size in bytes of user's offline queue messages.
+func (m *Offline) FetchOfflineMessagesSize(_ context.Context, username, domain string) (int, error) {
+	var messages []offlinemodel.Message
+	_, err := m.getEntities(offlineMessageKey(username, domain), &messages)
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	for i := range messages {
+		size += messages[i].Size()
+	}
+	return size, nil
+}
+
 // FetchOfflineMessages retrieves from storage current user offline queue.
-func (m *Offline) FetchOfflineMessages(_ context.Context, username string) ([]xmpp.Message, error) {
-	var messages []xmpp.Message
-	_, err := m.getEntities(offlineMessageKey(username), &messages)
+func (m *Offline) FetchOfflineMessages(_ context.Context, username, domain string) ([]offlinemodel.Message, error) {
+	var messages []offlinemodel.Message
+	_, err := m.getEntities(offlineMessageKey(username, domain), &messages)
 	switch err {
 	case nil:
 		return messages, nil
@@ -63,11 +79,89 @@ func (m *Offline) FetchOfflineMessages(_ context.Context, username string) ([]xm
 	}
 }
 
+// DeleteOfflineMessagesByID removes from user's offline queue those messages identified by ids.
+func (m *Offline) DeleteOfflineMessagesByID(_ context.Context, ids []string, username, domain string) error {
+	return m.updateInWriteLock(offlineMessageKey(username, domain), func(b []byte) ([]byte, error) {
+		var messages []offlinemodel.Message
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return nil, err
+			}
+		}
+		messages = filterOfflineMessages(messages, ids)
+
+		b, err := serializer.SerializeSlice(&messages)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	})
+}
+
 // DeleteOfflineMessages clears a user offline queue.
-func (m *Offline) DeleteOfflineMessages(_ context.Context, username string) error {
-	return m.deleteKey(offlineMessageKey(username))
+func (m *Offline) DeleteOfflineMessages(_ context.Context, username, domain string) error {
+	return m.deleteKey(offlineMessageKey(username, domain))
 }
 
-func offlineMessageKey(username string) string {
-	return "offlineMessages:" + username
+// DeleteExpiredOfflineMessages removes from every offline queue those messages expired at a given time,
+// returning them.
+func (m *Offline) DeleteExpiredOfflineMessages(_ context.Context, at time.Time) ([]offlinemodel.Message, error) {
+	var expired []offlinemodel.Message
+	if err := m.inWriteLock(func() error {
+		for k, b := range m.b {
+			if !strings.HasPrefix(k, offlineMessagesPrefix) {
+				continue
+			}
+			var messages, keep []offlinemodel.Message
+			if err := serializer.DeserializeSlice(b, &messages); err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				if msg.IsExpired(at) {
+					expired = append(expired, msg)
+				} else {
+					keep = append(keep, msg)
+				}
+			}
+			if len(keep) == len(messages) {
+				continue
+			}
+			if len(keep) == 0 {
+				delete(m.b, k)
+				continue
+			}
+			b, err := serializer.SerializeSlice(&keep)
+			if err != nil {
+				return err
+			}
+			m.b[k] = b
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func filterOfflineMessages(messages []offlinemodel.Message, ids []string) []offlinemodel.Message {
+	res := messages[:0]
+	for _, msg := range messages {
+		var found bool
+		for _, id := range ids {
+			if msg.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+const offlineMessagesPrefix = "offlineMessages:"
+
+func offlineMessageKey(username, domain string) string {
+	return offlineMessagesPrefix + userKey(username, domain)
 }
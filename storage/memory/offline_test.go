@@ -8,7 +8,9 @@ package memorystorage
 import (
 	"context"
 	"testing"
+	"time"
 
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 	"github.com/sxmpp/jackal/xmpp"
 	"github.com/sxmpp/jackal/xmpp/jid"
 	"github.com/pborman/uuid"
@@ -24,10 +26,10 @@ func TestMemoryStorage_InsertOfflineMessage(t *testing.T) {
 
 	s := NewOffline()
 	EnableMockedError()
-	require.Equal(t, ErrMocked, s.InsertOfflineMessage(context.Background(), m, "sxmpp"))
+	require.Equal(t, ErrMocked, s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im"))
 	DisableMockedError()
 
-	require.Nil(t, s.InsertOfflineMessage(context.Background(), m, "sxmpp"))
+	require.Nil(t, s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im"))
 }
 
 func TestMemoryStorage_CountOfflineMessages(t *testing.T) {
@@ -38,17 +40,40 @@ func TestMemoryStorage_CountOfflineMessages(t *testing.T) {
 	m, _ := xmpp.NewMessageFromElement(message, j, j)
 
 	s := NewOffline()
-	_ = s.InsertOfflineMessage(context.Background(), m, "sxmpp")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
 
 	EnableMockedError()
-	_, err := s.CountOfflineMessages(context.Background(), "sxmpp")
+	_, err := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp")
+	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, 1, cnt)
 }
 
+func TestMemoryStorage_FetchOfflineMessagesSize(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	s := NewOffline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	EnableMockedError()
+	_, err := s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	size, _ := s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 2*len(m.String()), size)
+
+	size, _ = s.FetchOfflineMessagesSize(context.Background(), "noelia", "jackal.im")
+	require.Equal(t, 0, size)
+}
+
 func TestMemoryStorage_FetchOfflineMessages(t *testing.T) {
 	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
 	message := xmpp.NewElementName("message")
@@ -57,13 +82,13 @@ func TestMemoryStorage_FetchOfflineMessages(t *testing.T) {
 	m, _ := xmpp.NewMessageFromElement(message, j, j)
 
 	s := NewOffline()
-	_ = s.InsertOfflineMessage(context.Background(), m, "sxmpp")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
 
 	EnableMockedError()
-	_, err := s.FetchOfflineMessages(context.Background(), "sxmpp")
+	_, err := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
-	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp")
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, 1, len(elems))
 }
 
@@ -75,13 +100,68 @@ func TestMemoryStorage_DeleteOfflineMessages(t *testing.T) {
 	m, _ := xmpp.NewMessageFromElement(message, j, j)
 
 	s := NewOffline()
-	_ = s.InsertOfflineMessage(context.Background(), m, "sxmpp")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
 
 	EnableMockedError()
-	require.Equal(t, ErrMocked, s.DeleteOfflineMessages(context.Background(), "sxmpp"))
+	require.Equal(t, ErrMocked, s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im"))
 	DisableMockedError()
-	require.Nil(t, s.DeleteOfflineMessages(context.Background(), "sxmpp"))
+	require.Nil(t, s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im"))
 
-	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp")
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, 0, len(elems))
 }
+
+func TestMemoryStorage_DeleteOfflineMessagesByID(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	s := NewOffline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "1", Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "2", Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "3", Message: m}, "sxmpp", "jackal.im")
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeleteOfflineMessagesByID(context.Background(), []string{"1"}, "sxmpp", "jackal.im"))
+	DisableMockedError()
+	require.Nil(t, s.DeleteOfflineMessagesByID(context.Background(), []string{"1", "3"}, "sxmpp", "jackal.im"))
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, len(elems))
+	require.Equal(t, "2", elems[0].ID)
+}
+
+func TestMemoryStorage_DeleteExpiredOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	now := time.Now()
+
+	s := NewOffline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m1", ExpiresAt: now.Add(-time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m2", ExpiresAt: now.Add(time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m3", Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m4", ExpiresAt: now.Add(-time.Minute), Message: m}, "noelia", "jackal.im")
+
+	EnableMockedError()
+	_, err := s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	expired, err := s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Nil(t, err)
+	require.Len(t, expired, 2)
+
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, msgs, 2)
+	require.Equal(t, "m2", msgs[0].ID)
+	require.Equal(t, "m3", msgs[1].ID)
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "noelia", "jackal.im")
+	require.Equal(t, 0, cnt)
+}
@@ -7,6 +7,8 @@ package memorystorage
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/sxmpp/jackal/xmpp"
 )
@@ -22,30 +24,48 @@ func NewPrivate() *Private {
 }
 
 // UpsertPrivateXML inserts a new private element into storage, or updates it in case it's been previously inserted.
-func (m *Private) UpsertPrivateXML(_ context.Context, privateXML []xmpp.XElement, namespace string, username string) error {
+func (m *Private) UpsertPrivateXML(_ context.Context, privateXML []xmpp.XElement, namespace string, username, domain string) error {
 	var priv []xmpp.Element
 
 	// convert to concrete type
 	for _, el := range privateXML {
 		priv = append(priv, *xmpp.NewElementFromElement(el))
 	}
-	return m.saveEntities(privateStorageKey(username, namespace), &priv)
+	return m.saveEntities(privateStorageKey(username, domain, namespace), &priv)
 }
 
 // FetchPrivateXML retrieves from storage a private element.
-func (m *Private) FetchPrivateXML(_ context.Context, namespace string, username string) ([]xmpp.XElement, error) {
+func (m *Private) FetchPrivateXML(_ context.Context, namespace string, username, domain string) ([]xmpp.XElement, error) {
 	var priv []xmpp.Element
-	_, err := m.getEntities(privateStorageKey(username, namespace), &priv)
+	_, err := m.getEntities(privateStorageKey(username, domain, namespace), &priv)
 	if err != nil {
 		return nil, err
 	}
 	var ret []xmpp.XElement
-	for _, e := range priv {
-		ret = append(ret, &e)
+	for i := range priv {
+		ret = append(ret, &priv[i])
 	}
 	return ret, nil
 }
 
-func privateStorageKey(username, namespace string) string {
-	return "privateElements:" + username + ":" + namespace
+// FetchPrivateXMLNamespaces retrieves from storage the namespaces of every private element stored by a user.
+func (m *Private) FetchPrivateXMLNamespaces(_ context.Context, username, domain string) ([]string, error) {
+	var namespaces []string
+	prefix := privateStorageKey(username, domain, "")
+	if err := m.inReadLock(func() error {
+		for k := range m.b {
+			if strings.HasPrefix(k, prefix) {
+				namespaces = append(namespaces, strings.TrimPrefix(k, prefix))
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func privateStorageKey(username, domain, namespace string) string {
+	return "privateElements:" + userKey(username, domain) + ":" + namespace
 }
@@ -18,11 +18,11 @@ func TestMemoryStorage_InsertPrivateXML(t *testing.T) {
 
 	s := NewPrivate()
 	EnableMockedError()
-	err := s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp")
+	err := s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	err = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp")
+	err = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, err)
 }
 
@@ -30,13 +30,29 @@ func TestMemoryStorage_FetchPrivateXML(t *testing.T) {
 	private := xmpp.NewElementNamespace("exodus", "exodus:ns")
 
 	s := NewPrivate()
-	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp")
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
 
 	EnableMockedError()
-	_, err := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	_, err := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	elems, _ := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, _ := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Equal(t, 1, len(elems))
 }
+
+func TestMemoryStorage_FetchPrivateXMLNamespaces(t *testing.T) {
+	s := NewPrivate()
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "sxmpp", "jackal.im")
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("storage", "storage:bookmarks")}, "storage:bookmarks", "sxmpp", "jackal.im")
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "noelia", "jackal.im")
+
+	EnableMockedError()
+	_, err := s.FetchPrivateXMLNamespaces(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	namespaces, err := s.FetchPrivateXMLNamespaces(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, []string{"exodus:ns", "storage:bookmarks"}, namespaces)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/sxmpp/jackal/model/serializer"
+)
+
+// Push represents an in-memory push registrations storage.
+type Push struct {
+	*memoryStorage
+}
+
+// NewPush returns an instance of Push in-memory storage.
+func NewPush() *Push {
+	return &Push{memoryStorage: newStorage()}
+}
+
+// UpsertPushRegistration inserts a new push registration into storage,
+// or updates it in case it's been previously inserted.
+func (m *Push) UpsertPushRegistration(_ context.Context, reg *pushmodel.Registration) error {
+	return m.updateInWriteLock(pushRegistrationsKey(reg.Username, reg.Domain), func(b []byte) ([]byte, error) {
+		var regs []pushmodel.Registration
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &regs); err != nil {
+				return nil, err
+			}
+		}
+		var updated bool
+		for i, r := range regs {
+			if r.JID == reg.JID && r.Node == reg.Node {
+				regs[i] = *reg
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			regs = append(regs, *reg)
+		}
+		return serializer.SerializeSlice(&regs)
+	})
+}
+
+// DeletePushRegistrations removes those user push registrations associated to an app server JID.
+// In case node is empty every registration associated to the app server will be removed.
+func (m *Push) DeletePushRegistrations(_ context.Context, username, domain, jid, node string) error {
+	return m.updateInWriteLock(pushRegistrationsKey(username, domain), func(b []byte) ([]byte, error) {
+		var regs, keep []pushmodel.Registration
+		if len(b) > 0 {
+			if err := serializer.DeserializeSlice(b, &regs); err != nil {
+				return nil, err
+			}
+		}
+		for _, r := range regs {
+			if r.JID == jid && (len(node) == 0 || r.Node == node) {
+				continue
+			}
+			keep = append(keep, r)
+		}
+		return serializer.SerializeSlice(&keep)
+	})
+}
+
+// FetchPushRegistrations retrieves from storage all user push registrations.
+func (m *Push) FetchPushRegistrations(_ context.Context, username, domain string) ([]pushmodel.Registration, error) {
+	var regs []pushmodel.Registration
+	if _, err := m.getEntities(pushRegistrationsKey(username, domain), &regs); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+func pushRegistrationsKey(username, domain string) string {
+	return "pushRegistrations:" + userKey(username, domain)
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memorystorage
+
+import (
+	"context"
+	"testing"
+
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorage_UpsertPushRegistration(t *testing.T) {
+	s := NewPush()
+	reg := &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"}
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.UpsertPushRegistration(context.Background(), reg))
+	DisableMockedError()
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg))
+
+	reg2 := &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1", Options: map[string]string{"secret": "1234"}}
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg2))
+
+	regs, err := s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, regs, 1)
+	require.Equal(t, "1234", regs[0].Options["secret"])
+}
+
+func TestMemoryStorage_DeletePushRegistrations(t *testing.T) {
+	s := NewPush()
+	_ = s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"})
+	_ = s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n2"})
+	_ = s.UpsertPushRegistration(context.Background(), &pushmodel.Registration{Username: "ortuman", Domain: "jackal.im", JID: "push.example.org", Node: "n1"})
+
+	EnableMockedError()
+	require.Equal(t, ErrMocked, s.DeletePushRegistrations(context.Background(), "ortuman", "jackal.im", "push.jackal.im", "n1"))
+	_, err := s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Equal(t, ErrMocked, err)
+	DisableMockedError()
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "jackal.im", "push.jackal.im", "n1"))
+	regs, _ := s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Len(t, regs, 2)
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "ortuman", "jackal.im", "push.jackal.im", ""))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "ortuman", "jackal.im")
+	require.Len(t, regs, 1)
+	require.Equal(t, "push.example.org", regs[0].JID)
+}
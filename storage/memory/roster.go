@@ -27,8 +27,9 @@ func NewRoster() *Roster {
 // UpsertRosterItem inserts a new roster item entity into storage, or updates it if previously inserted.
 func (m *Roster) UpsertRosterItem(_ context.Context, ri *rostermodel.Item) (rostermodel.Version, error) {
 	var rv rostermodel.Version
+	user := userKey(ri.Username, ri.Domain)
 	err := m.inWriteLock(func() error {
-		ris, fnErr := m.fetchRosterItems(ri.Username)
+		ris, fnErr := m.fetchRosterItems(user)
 		if fnErr != nil {
 			return fnErr
 		}
@@ -45,26 +46,27 @@ func (m *Roster) UpsertRosterItem(_ context.Context, ri *rostermodel.Item) (rost
 		}
 
 	done:
-		if fnErr := m.upsertRosterGroups(ri.Username, ris); fnErr != nil {
+		if fnErr := m.upsertRosterGroups(user, ris); fnErr != nil {
 			return fnErr
 		}
-		rv, fnErr = m.fetchRosterVersion(ri.Username)
+		rv, fnErr = m.fetchRosterVersion(user)
 		if fnErr != nil {
 			return fnErr
 		}
 		rv.Ver++
-		if err := m.upsertRosterVersion(rv, ri.Username); err != nil {
+		if err := m.upsertRosterVersion(rv, user); err != nil {
 			return err
 		}
 		ris[len(ris)-1].Ver = rv.Ver
-		return m.upsertRosterItems(ris, ri.Username)
+		return m.upsertRosterItems(ris, user)
 	})
 	return rv, err
 }
 
 // DeleteRosterItem deletes a roster item entity from storage.
-func (m *Roster) DeleteRosterItem(_ context.Context, user, contact string) (rostermodel.Version, error) {
+func (m *Roster) DeleteRosterItem(_ context.Context, username, domain, contact string) (rostermodel.Version, error) {
 	var rv rostermodel.Version
+	user := userKey(username, domain)
 	if err := m.inWriteLock(func() error {
 		ris, fnErr := m.fetchRosterItems(user)
 		if fnErr != nil {
@@ -97,10 +99,11 @@ func (m *Roster) DeleteRosterItem(_ context.Context, user, contact string) (rost
 }
 
 // FetchRosterItems retrieves from storage all roster item entities associated to a given user.
-func (m *Roster) FetchRosterItems(_ context.Context, user string) ([]rostermodel.Item, rostermodel.Version, error) {
+func (m *Roster) FetchRosterItems(_ context.Context, username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
 	var ris []rostermodel.Item
 	var rv rostermodel.Version
 
+	user := userKey(username, domain)
 	if err := m.inReadLock(func() error {
 		var fnErr error
 		ris, fnErr = m.fetchRosterItems(user)
@@ -116,16 +119,17 @@ func (m *Roster) FetchRosterItems(_ context.Context, user string) ([]rostermodel
 }
 
 // FetchRosterItemsInGroups retrieves from storage all roster item entities associated to a given user and a set of groups.
-func (m *Roster) FetchRosterItemsInGroups(_ context.Context, username string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+func (m *Roster) FetchRosterItemsInGroups(_ context.Context, username, domain string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
 	var ris []rostermodel.Item
 	var rv rostermodel.Version
 
+	user := userKey(username, domain)
 	groupSet := make(map[string]struct{}, len(groups))
 	for _, group := range groups {
 		groupSet[group] = struct{}{}
 	}
 	if err := m.inReadLock(func() error {
-		fnRis, fnErr := m.fetchRosterItems(username)
+		fnRis, fnErr := m.fetchRosterItems(user)
 		if fnErr != nil {
 			return fnErr
 		}
@@ -137,7 +141,7 @@ func (m *Roster) FetchRosterItemsInGroups(_ context.Context, username string, gr
 				}
 			}
 		}
-		rv, fnErr = m.fetchRosterVersion(username)
+		rv, fnErr = m.fetchRosterVersion(user)
 		return fnErr
 	}); err != nil {
 		return nil, rostermodel.Version{}, err
@@ -146,10 +150,10 @@ func (m *Roster) FetchRosterItemsInGroups(_ context.Context, username string, gr
 }
 
 // FetchRosterItem retrieves from storage a roster item entity.
-func (m *Roster) FetchRosterItem(_ context.Context, user, contact string) (*rostermodel.Item, error) {
+func (m *Roster) FetchRosterItem(_ context.Context, username, domain, contact string) (*rostermodel.Item, error) {
 	var ret *rostermodel.Item
 	err := m.inReadLock(func() error {
-		ris, fnErr := m.fetchRosterItems(user)
+		ris, fnErr := m.fetchRosterItems(userKey(username, domain))
 		if fnErr != nil {
 			return fnErr
 		}
@@ -166,8 +170,9 @@ func (m *Roster) FetchRosterItem(_ context.Context, user, contact string) (*rost
 
 // UpsertRosterNotification inserts a new roster notification entity into storage, or updates it if previously inserted.
 func (m *Roster) UpsertRosterNotification(_ context.Context, rn *rostermodel.Notification) error {
+	contact := userKey(rn.Contact, rn.Domain)
 	return m.inWriteLock(func() error {
-		rns, fnErr := m.fetchRosterNotifications(rn.Contact)
+		rns, fnErr := m.fetchRosterNotifications(contact)
 		if fnErr != nil {
 			return fnErr
 		}
@@ -183,12 +188,13 @@ func (m *Roster) UpsertRosterNotification(_ context.Context, rn *rostermodel.Not
 			rns = []rostermodel.Notification{*rn}
 		}
 	done:
-		return m.upsertRosterNotifications(rns, rn.Contact)
+		return m.upsertRosterNotifications(rns, contact)
 	})
 }
 
 // DeleteRosterNotification deletes a roster notification entity from storage.
-func (m *Roster) DeleteRosterNotification(_ context.Context, contact, jid string) error {
+func (m *Roster) DeleteRosterNotification(_ context.Context, username, domain, jid string) error {
+	contact := userKey(username, domain)
 	return m.inWriteLock(func() error {
 		rns, fnErr := m.fetchRosterNotifications(contact)
 		if fnErr != nil {
@@ -205,10 +211,10 @@ func (m *Roster) DeleteRosterNotification(_ context.Context, contact, jid string
 }
 
 // FetchRosterNotification retrieves from storage a roster notification entity.
-func (m *Roster) FetchRosterNotification(_ context.Context, contact string, jid string) (*rostermodel.Notification, error) {
+func (m *Roster) FetchRosterNotification(_ context.Context, contact, domain, jid string) (*rostermodel.Notification, error) {
 	var ret *rostermodel.Notification
 	err := m.inReadLock(func() error {
-		rns, fnErr := m.fetchRosterNotifications(contact)
+		rns, fnErr := m.fetchRosterNotifications(userKey(contact, domain))
 		if fnErr != nil {
 			return fnErr
 		}
@@ -224,11 +230,11 @@ func (m *Roster) FetchRosterNotification(_ context.Context, contact string, jid
 }
 
 // FetchRosterNotifications retrieves from storage all roster notifications associated to a given user.
-func (m *Roster) FetchRosterNotifications(_ context.Context, contact string) ([]rostermodel.Notification, error) {
+func (m *Roster) FetchRosterNotifications(_ context.Context, contact, domain string) ([]rostermodel.Notification, error) {
 	var rns []rostermodel.Notification
 	if err := m.inReadLock(func() error {
 		var fnErr error
-		rns, fnErr = m.fetchRosterNotifications(contact)
+		rns, fnErr = m.fetchRosterNotifications(userKey(contact, domain))
 		return fnErr
 	}); err != nil {
 		return nil, err
@@ -237,11 +243,11 @@ func (m *Roster) FetchRosterNotifications(_ context.Context, contact string) ([]
 }
 
 // FetchRosterGroups retrieves all groups associated to a user roster.
-func (m *Roster) FetchRosterGroups(_ context.Context, username string) ([]string, error) {
+func (m *Roster) FetchRosterGroups(_ context.Context, username, domain string) ([]string, error) {
 	var groups []string
 	if err := m.inReadLock(func() error {
 		var fnErr error
-		groups, fnErr = m.fetchRosterGroups(username)
+		groups, fnErr = m.fetchRosterGroups(userKey(username, domain))
 		return fnErr
 	}); err != nil {
 		return nil, err
@@ -19,6 +19,7 @@ func TestMemoryStorage_InsertRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
 	ri := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact",
 		Name:         "a name",
 		Subscription: "both",
@@ -43,6 +44,7 @@ func TestMemoryStorage_FetchRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
 	ri := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact",
 		Name:         "a name",
 		Subscription: "both",
@@ -54,14 +56,14 @@ func TestMemoryStorage_FetchRosterItem(t *testing.T) {
 	_, _ = s.UpsertRosterItem(context.Background(), &ri)
 
 	EnableMockedError()
-	_, err := s.FetchRosterItem(context.Background(), "user", "contact")
+	_, err := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	ri3, _ := s.FetchRosterItem(context.Background(), "user", "contact2")
+	ri3, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact2")
 	require.Nil(t, ri3)
 
-	ri4, _ := s.FetchRosterItem(context.Background(), "user", "contact")
+	ri4, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.NotNil(t, ri4)
 	require.Equal(t, "user", ri4.Username)
 	require.Equal(t, "contact", ri4.JID)
@@ -70,6 +72,7 @@ func TestMemoryStorage_FetchRosterItem(t *testing.T) {
 func TestMemoryStorage_FetchRosterItems(t *testing.T) {
 	ri := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact@jackal.im",
 		Name:         "a name",
 		Subscription: "both",
@@ -79,6 +82,7 @@ func TestMemoryStorage_FetchRosterItems(t *testing.T) {
 	}
 	ri2 := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact2@jackal.im",
 		Name:         "a name 2",
 		Subscription: "both",
@@ -88,6 +92,7 @@ func TestMemoryStorage_FetchRosterItems(t *testing.T) {
 	}
 	ri3 := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact3@jackal.im",
 		Name:         "a name 3",
 		Subscription: "both",
@@ -102,18 +107,18 @@ func TestMemoryStorage_FetchRosterItems(t *testing.T) {
 	_, _ = s.UpsertRosterItem(context.Background(), &ri3)
 
 	EnableMockedError()
-	_, _, err := s.FetchRosterItems(context.Background(), "user")
+	_, _, err := s.FetchRosterItems(context.Background(), "user", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	ris, _, _ := s.FetchRosterItems(context.Background(), "user")
+	ris, _, _ := s.FetchRosterItems(context.Background(), "user", "jackal.im")
 	require.Equal(t, 3, len(ris))
-	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", []string{"friends"})
+	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", "jackal.im", []string{"friends"})
 	require.Equal(t, 2, len(ris))
-	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", []string{"buddies"})
+	ris, _, _ = s.FetchRosterItemsInGroups(context.Background(), "user", "jackal.im", []string{"buddies"})
 	require.Equal(t, 1, len(ris))
 
-	gr, _ := s.FetchRosterGroups(context.Background(), "user")
+	gr, _ := s.FetchRosterGroups(context.Background(), "user", "jackal.im")
 	require.Len(t, gr, 4)
 
 	require.Contains(t, gr, "general")
@@ -126,6 +131,7 @@ func TestMemoryStorage_DeleteRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
 	ri := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact",
 		Name:         "a name",
 		Subscription: "both",
@@ -136,32 +142,33 @@ func TestMemoryStorage_DeleteRosterItem(t *testing.T) {
 	s := NewRoster()
 	_, _ = s.UpsertRosterItem(context.Background(), &ri)
 
-	gr, _ := s.FetchRosterGroups(context.Background(), "user")
+	gr, _ := s.FetchRosterGroups(context.Background(), "user", "jackal.im")
 	require.Len(t, gr, 2)
 
 	require.Contains(t, gr, "general")
 	require.Contains(t, gr, "friends")
 
 	EnableMockedError()
-	_, err := s.DeleteRosterItem(context.Background(), "user", "contact")
+	_, err := s.DeleteRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	_, err = s.DeleteRosterItem(context.Background(), "user", "contact")
+	_, err = s.DeleteRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, err)
-	_, err = s.DeleteRosterItem(context.Background(), "user2", "contact")
+	_, err = s.DeleteRosterItem(context.Background(), "user2", "jackal.im", "contact")
 	require.Nil(t, err) // delete not existing roster item...
 
-	ri2, _ := s.FetchRosterItem(context.Background(), "user", "contact")
+	ri2, _ := s.FetchRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, ri2)
 
-	gr, _ = s.FetchRosterGroups(context.Background(), "user")
+	gr, _ = s.FetchRosterGroups(context.Background(), "user", "jackal.im")
 	require.Len(t, gr, 0)
 }
 
 func TestMemoryStorage_InsertRosterNotification(t *testing.T) {
 	rn := rostermodel.Notification{
 		Contact:  "sxmpp",
+		Domain:   "jackal.im",
 		JID:      "romeo@jackal.im",
 		Presence: &xmpp.Presence{},
 	}
@@ -175,11 +182,13 @@ func TestMemoryStorage_InsertRosterNotification(t *testing.T) {
 func TestMemoryStorage_FetchRosterNotifications(t *testing.T) {
 	rn1 := rostermodel.Notification{
 		Contact:  "romeo",
+		Domain:   "jackal.im",
 		JID:      "sxmpp@jackal.im",
 		Presence: &xmpp.Presence{},
 	}
 	rn2 := rostermodel.Notification{
 		Contact:  "romeo",
+		Domain:   "jackal.im",
 		JID:      "sxmpp2@jackal.im",
 		Presence: &xmpp.Presence{},
 	}
@@ -193,11 +202,11 @@ func TestMemoryStorage_FetchRosterNotifications(t *testing.T) {
 	_ = s.UpsertRosterNotification(context.Background(), &rn2)
 
 	EnableMockedError()
-	_, err := s.FetchRosterNotifications(context.Background(), "romeo")
+	_, err := s.FetchRosterNotifications(context.Background(), "romeo", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	rns, err := s.FetchRosterNotifications(context.Background(), "romeo")
+	rns, err := s.FetchRosterNotifications(context.Background(), "romeo", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(rns))
 	require.Equal(t, "sxmpp@jackal.im", rns[0].JID)
@@ -207,6 +216,7 @@ func TestMemoryStorage_FetchRosterNotifications(t *testing.T) {
 func TestMemoryStorage_DeleteRosterNotification(t *testing.T) {
 	rn1 := rostermodel.Notification{
 		Contact:  "sxmpp",
+		Domain:   "jackal.im",
 		JID:      "romeo@jackal.im",
 		Presence: &xmpp.Presence{},
 	}
@@ -214,15 +224,15 @@ func TestMemoryStorage_DeleteRosterNotification(t *testing.T) {
 	_ = s.UpsertRosterNotification(context.Background(), &rn1)
 
 	EnableMockedError()
-	require.Equal(t, ErrMocked, s.DeleteRosterNotification(context.Background(), "sxmpp", "romeo@jackal.im"))
+	require.Equal(t, ErrMocked, s.DeleteRosterNotification(context.Background(), "sxmpp", "jackal.im", "romeo@jackal.im"))
 	DisableMockedError()
 
-	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp", "romeo@jackal.im"))
+	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp", "jackal.im", "romeo@jackal.im"))
 
-	rns, err := s.FetchRosterNotifications(context.Background(), "romeo")
+	rns, err := s.FetchRosterNotifications(context.Background(), "romeo", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns))
 
 	// delete not existing roster notification...
-	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp2", "romeo@jackal.im"))
+	require.Nil(t, s.DeleteRosterNotification(context.Background(), "sxmpp2", "jackal.im", "romeo@jackal.im"))
 }
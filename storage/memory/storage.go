@@ -157,6 +157,11 @@ func (m *memoryStorage) keyExists(k string) (bool, error) {
 	return b != nil, nil
 }
 
+// userKey returns the key prefix used to store entities associated to a username and domain pair.
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
+
 func checkMockedError() error {
 	mockErrMu.Lock()
 	defer mockErrMu.Unlock()
@@ -7,13 +7,18 @@ package memorystorage
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/model/serializer"
+	"github.com/sxmpp/jackal/xmpp"
 )
 
 // User represents an in-memory user storage.
 type User struct {
 	*memoryStorage
+	accountReps []accountDeleter
 }
 
 // NewUser returns an instance of User in-memory storage.
@@ -23,18 +28,46 @@ func NewUser() *User {
 
 // UpsertUser inserts a new user entity into storage, or updates it in case it's been previously inserted.
 func (m *User) UpsertUser(_ context.Context, user *model.User) error {
-	return m.saveEntity(userKey(user.Username), user)
+	return m.saveEntity(usersKey(user.Username, user.Domain), user)
 }
 
-// DeleteUser deletes a user entity from storage.
-func (m *User) DeleteUser(_ context.Context, username string) error {
-	return m.deleteKey(userKey(username))
+// UpdateLastPresence updates last received presence of a previously inserted user.
+func (m *User) UpdateLastPresence(_ context.Context, username, domain string, presence *xmpp.Presence) error {
+	k := usersKey(username, domain)
+	return m.inWriteLock(func() error {
+		b, ok := m.b[k]
+		if !ok {
+			return nil
+		}
+		var user model.User
+		if err := serializer.Deserialize(b, &user); err != nil {
+			return err
+		}
+		user.LastPresence = presence
+		b, err := serializer.Serialize(&user)
+		if err != nil {
+			return err
+		}
+		m.b[k] = b
+		return nil
+	})
+}
+
+// DeleteUser deletes a user entity from storage, along with every associated entity
+// held by the container repositories.
+func (m *User) DeleteUser(_ context.Context, username, domain string) error {
+	for _, rep := range m.accountReps {
+		if err := rep.deleteAccount(username, domain); err != nil {
+			return err
+		}
+	}
+	return m.deleteKey(usersKey(username, domain))
 }
 
 // FetchUser retrieves from storage a user entity.
-func (m *User) FetchUser(_ context.Context, username string) (*model.User, error) {
+func (m *User) FetchUser(_ context.Context, username, domain string) (*model.User, error) {
 	var user model.User
-	ok, err := m.getEntity(userKey(username), &user)
+	ok, err := m.getEntity(usersKey(username, domain), &user)
 	switch err {
 	case nil:
 		if ok {
@@ -47,10 +80,54 @@ func (m *User) FetchUser(_ context.Context, username string) (*model.User, error
 }
 
 // UserExists returns whether or not a user exists within storage.
-func (m *User) UserExists(_ context.Context, username string) (bool, error) {
-	return m.keyExists(userKey(username))
+func (m *User) UserExists(_ context.Context, username, domain string) (bool, error) {
+	return m.keyExists(usersKey(username, domain))
+}
+
+// FetchUsernames retrieves from storage the names of every user registered within a domain.
+func (m *User) FetchUsernames(_ context.Context, domain string) ([]string, error) {
+	var usernames []string
+	suffix := userKey("", domain)
+	if err := m.inReadLock(func() error {
+		for k := range m.b {
+			if strings.HasPrefix(k, "users:") && strings.HasSuffix(k, suffix) {
+				usernames = append(usernames, strings.TrimSuffix(strings.TrimPrefix(k, "users:"), suffix))
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(usernames)
+	return usernames, nil
+}
+
+// FetchExpiredUsers retrieves from storage every active user whose account expiration time has been reached.
+func (m *User) FetchExpiredUsers(_ context.Context) ([]model.User, error) {
+	var entries [][]byte
+	if err := m.inReadLock(func() error {
+		for k, b := range m.b {
+			if strings.HasPrefix(k, "users:") {
+				entries = append(entries, b)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var users []model.User
+	for _, b := range entries {
+		var user model.User
+		if err := serializer.Deserialize(b, &user); err != nil {
+			return nil, err
+		}
+		if user.IsExpired() && !user.IsSuspended() {
+			users = append(users, user)
+		}
+	}
+	return users, nil
 }
 
-func userKey(username string) string {
-	return "users:" + username
+func usersKey(username, domain string) string {
+	return "users:" + userKey(username, domain)
 }
@@ -22,14 +22,14 @@ func NewVCard() *VCard {
 }
 
 // UpsertVCard inserts a new vCard element into storage, or updates it in case it's been previously inserted.
-func (m *VCard) UpsertVCard(_ context.Context, vCard xmpp.XElement, username string) error {
-	return m.saveEntity(vCardKey(username), vCard)
+func (m *VCard) UpsertVCard(_ context.Context, vCard xmpp.XElement, username, domain string) error {
+	return m.saveEntity(vCardKey(username, domain), vCard)
 }
 
 // FetchVCard retrieves from storage a vCard element associated to a given user.
-func (m *VCard) FetchVCard(_ context.Context, username string) (xmpp.XElement, error) {
+func (m *VCard) FetchVCard(_ context.Context, username, domain string) (xmpp.XElement, error) {
 	var vCard xmpp.Element
-	ok, err := m.getEntity(vCardKey(username), &vCard)
+	ok, err := m.getEntity(vCardKey(username, domain), &vCard)
 	switch err {
 	case nil:
 		if ok {
@@ -41,6 +41,6 @@ func (m *VCard) FetchVCard(_ context.Context, username string) (xmpp.XElement, e
 	}
 }
 
-func vCardKey(username string) string {
-	return "vCards:" + username
+func vCardKey(username, domain string) string {
+	return "vCards:" + userKey(username, domain)
 }
@@ -21,9 +21,9 @@ func TestMemoryStorage_InsertVCard(t *testing.T) {
 
 	s := NewVCard()
 	EnableMockedError()
-	require.Equal(t, ErrMocked, s.UpsertVCard(context.Background(), vCard, "sxmpp"))
+	require.Equal(t, ErrMocked, s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im"))
 	DisableMockedError()
-	require.Nil(t, s.UpsertVCard(context.Background(), vCard, "sxmpp"))
+	require.Nil(t, s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im"))
 }
 
 func TestMemoryStorage_FetchVCard(t *testing.T) {
@@ -33,13 +33,13 @@ func TestMemoryStorage_FetchVCard(t *testing.T) {
 	vCard.AppendElement(fn)
 
 	s := NewVCard()
-	_ = s.UpsertVCard(context.Background(), vCard, "sxmpp")
+	_ = s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im")
 
 	EnableMockedError()
-	_, err := s.FetchVCard(context.Background(), "sxmpp")
+	_, err := s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, ErrMocked, err)
 	DisableMockedError()
 
-	elem, _ := s.FetchVCard(context.Background(), "sxmpp")
+	elem, _ := s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
 	require.NotNil(t, elem)
 }
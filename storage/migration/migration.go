@@ -0,0 +1,362 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sxmpp/jackal/log"
+)
+
+// versionTable is the table used to keep track of applied migrations.
+const versionTable = "schema_migrations"
+
+// ErrNoAppliedMigrations will be returned by Down when there's nothing to revert.
+var ErrNoAppliedMigrations = errors.New("migration: no applied migrations")
+
+// Migration represents a numbered schema change.
+type Migration struct {
+	// Version identifies the migration. Versions are applied in ascending order.
+	Version int
+
+	// Description is a human readable summary of the change.
+	Description string
+
+	// Baseline, if set, is a query that only succeeds when the schema changes introduced by the migration
+	// are already in place, so that schemas applied by hand prior to versioning can be recognized.
+	Baseline string
+
+	// Check, if set, runs before any Up statement, so that a migration bound to fail doesn't leave
+	// a partially applied schema behind on databases committing DDL statements implicitly (MySQL).
+	Check func(ctx context.Context, db *sql.DB) error
+
+	// Up contains the statements that apply the migration.
+	Up []string
+
+	// UpGuards, if set, contains for each Up statement a query that only succeeds when the statement
+	// has already been applied, in which case it's skipped. An empty guard means the statement is always run.
+	// Guards make a migration partially applied by a database committing DDL statements implicitly
+	// (MySQL) safe to retry.
+	UpGuards []string
+
+	// Exec, if set, runs right after Up statements within the same transaction.
+	Exec func(ctx context.Context, tx *sql.Tx) error
+
+	// Down contains the statements that revert the migration.
+	Down []string
+}
+
+// Status represents a migration status.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// AppliedMismatchError will be returned by Verify and Up when an applied migration doesn't match
+// the known migration with the same version, which means released migrations have been renumbered.
+type AppliedMismatchError struct {
+	Version            int
+	AppliedDescription string
+	KnownDescription   string
+}
+
+// Error satisfies error interface.
+func (e *AppliedMismatchError) Error() string {
+	return fmt.Sprintf("migration: applied migration %d (%s) doesn't match known migration %d (%s)",
+		e.Version, e.AppliedDescription, e.Version, e.KnownDescription)
+}
+
+// VersionMismatchError will be returned by Verify when database schema version differs from the expected one.
+type VersionMismatchError struct {
+	Current  int
+	Expected int
+}
+
+// Error satisfies error interface.
+func (e *VersionMismatchError) Error() string {
+	if e.Current < e.Expected {
+		return fmt.Sprintf("migration: database schema version %d is older than expected version %d (run 'jackal migrate up' or enable auto_migrate)", e.Current, e.Expected)
+	}
+	return fmt.Sprintf("migration: database schema version %d is newer than expected version %d", e.Current, e.Expected)
+}
+
+// Migrator applies and reverts migrations against a database.
+type Migrator struct {
+	db         *sql.DB
+	sb         sq.StatementBuilderType
+	migrations []Migration
+}
+
+// New returns a migrator instance for a given migration set.
+func New(db *sql.DB, placeholder sq.PlaceholderFormat, migrations []Migration) *Migrator {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+
+	return &Migrator{
+		db:         db,
+		sb:         sq.StatementBuilder.PlaceholderFormat(placeholder),
+		migrations: ms,
+	}
+}
+
+// Latest returns the latest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns current database schema version.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.createVersionTable(ctx); err != nil {
+		return 0, err
+	}
+	var version int
+	err := m.sb.Select("COALESCE(MAX(version), 0)").
+		From(versionTable).
+		RunWith(m.db).QueryRowContext(ctx).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Verify checks that database schema is at the latest known version.
+func (m *Migrator) Verify(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyApplied(ctx); err != nil {
+		return err
+	}
+	if latest := m.Latest(); version != latest {
+		return &VersionMismatchError{Current: version, Expected: latest}
+	}
+	return nil
+}
+
+// Up applies every pending migration, returning the number of applied ones.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verifyApplied(ctx); err != nil {
+		return 0, err
+	}
+	var count int
+
+	// an unversioned schema may have been created by hand from any former release,
+	// so recognize every leading migration whose changes are already in place.
+	baselining := version == 0
+	for _, mig := range m.migrations {
+		if mig.Version <= version {
+			continue
+		}
+		if baselining && len(mig.Baseline) > 0 && m.isPresent(ctx, mig.Baseline) {
+			log.Infof("migration: schema already present... marking migration %d as applied", mig.Version)
+			if err := m.setApplied(ctx, m.db, mig); err != nil {
+				return count, err
+			}
+		} else {
+			baselining = false
+
+			log.Infof("migration: applying migration %d (%s)", mig.Version, mig.Description)
+			if err := m.apply(ctx, mig); err != nil {
+				return count, fmt.Errorf("migration: %d: %v", mig.Version, err)
+			}
+		}
+		version = mig.Version
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the latest applied migration, returning its version.
+func (m *Migrator) Down(ctx context.Context) (int, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, ErrNoAppliedMigrations
+	}
+	for _, mig := range m.migrations {
+		if mig.Version != version {
+			continue
+		}
+		log.Infof("migration: reverting migration %d (%s)", mig.Version, mig.Description)
+		if err := m.revert(ctx, mig); err != nil {
+			return 0, fmt.Errorf("migration: %d: %v", mig.Version, err)
+		}
+		return mig.Version, nil
+	}
+	return 0, fmt.Errorf("migration: unknown applied migration %d", version)
+}
+
+// Status returns the status of every known migration.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Migration: mig}
+		var am appliedMigration
+		am, st.Applied = applied[mig.Version]
+		st.AppliedAt = am.appliedAt
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+// Close closes underlying database handler.
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+type appliedMigration struct {
+	description string
+	appliedAt   time.Time
+}
+
+func (m *Migrator) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	if err := m.createVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.sb.Select("version", "description", "applied_at").
+		From(versionTable).
+		RunWith(m.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var am appliedMigration
+		if err := rows.Scan(&version, &am.description, &am.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = am
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// verifyApplied checks that every applied migration matches the known migration with the same version.
+func (m *Migrator) verifyApplied(ctx context.Context) error {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		am, ok := applied[mig.Version]
+		if !ok || am.description == mig.Description {
+			continue
+		}
+		return &AppliedMismatchError{Version: mig.Version, AppliedDescription: am.description, KnownDescription: mig.Description}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	if mig.Check != nil {
+		if err := mig.Check(ctx, m.db); err != nil {
+			return err
+		}
+	}
+	// guards are evaluated beforehand, since a failing query aborts the whole transaction on some databases
+	skip := make([]bool, len(mig.Up))
+	for i := range mig.Up {
+		if i < len(mig.UpGuards) && len(mig.UpGuards[i]) > 0 && m.isPresent(ctx, mig.UpGuards[i]) {
+			log.Infof("migration: statement %d of migration %d already applied... skipping", i+1, mig.Version)
+			skip[i] = true
+		}
+	}
+	return m.inTransaction(ctx, func(tx *sql.Tx) error {
+		for i, stmt := range mig.Up {
+			if skip[i] {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		if mig.Exec != nil {
+			if err := mig.Exec(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return m.setApplied(ctx, tx, mig)
+	})
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	return m.inTransaction(ctx, func(tx *sql.Tx) error {
+		for _, stmt := range mig.Down {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := m.sb.Delete(versionTable).
+			Where(sq.Eq{"version": mig.Version}).
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (m *Migrator) setApplied(ctx context.Context, runner sq.BaseRunner, mig Migration) error {
+	_, err := m.sb.Insert(versionTable).
+		Columns("version", "description", "applied_at").
+		Values(mig.Version, mig.Description, time.Now().UTC()).
+		RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+func (m *Migrator) createVersionTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+    version     INT NOT NULL PRIMARY KEY,
+    description VARCHAR(256) NOT NULL,
+    applied_at  TIMESTAMP NOT NULL
+)`)
+	return err
+}
+
+func (m *Migrator) isPresent(ctx context.Context, query string) bool {
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return false
+	}
+	_ = rows.Close()
+	return true
+}
+
+func (m *Migrator) inTransaction(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, txErr := m.db.BeginTx(ctx, nil)
+	if txErr != nil {
+		return txErr
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
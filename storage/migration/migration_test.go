@@ -0,0 +1,267 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/mattn/go-sqlite3" // SQL driver
+	"github.com/stretchr/testify/require"
+)
+
+var tMigrations = []Migration{
+	{
+		Version:     2,
+		Description: "add vcards",
+		Up:          []string{"CREATE TABLE vcards (username TEXT NOT NULL, vcard TEXT NOT NULL)"},
+		Down:        []string{"DROP TABLE vcards"},
+	},
+	{
+		Version:     1,
+		Description: "initial schema",
+		Baseline:    "SELECT username FROM users WHERE 1 = 0",
+		Up:          []string{"CREATE TABLE users (username TEXT NOT NULL)"},
+		Down:        []string{"DROP TABLE users"},
+	},
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	m := New(db, sq.Question, tMigrations)
+	require.Equal(t, 2, m.Latest())
+
+	err := m.Verify(context.Background())
+	require.NotNil(t, err)
+	require.Equal(t, &VersionMismatchError{Current: 0, Expected: 2}, err)
+
+	n, err := m.Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	require.Nil(t, m.Verify(context.Background()))
+
+	// nothing left to apply
+	n, err = m.Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+
+	_, err = db.Exec("INSERT INTO vcards (username, vcard) VALUES ('sxmpp', '<vCard/>')")
+	require.Nil(t, err)
+
+	version, err := m.Down(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 2, version)
+
+	version, err = m.Version(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 1, version)
+
+	_, err = db.Exec("SELECT 1 FROM vcards")
+	require.NotNil(t, err)
+
+	_, err = m.Down(context.Background())
+	require.Nil(t, err)
+
+	_, err = m.Down(context.Background())
+	require.Equal(t, ErrNoAppliedMigrations, err)
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	m := New(db, sq.Question, tMigrations[1:])
+	_, err := m.Up(context.Background())
+	require.Nil(t, err)
+
+	m = New(db, sq.Question, tMigrations)
+	require.NotNil(t, m.Verify(context.Background()))
+
+	sts, err := m.Status(context.Background())
+	require.Nil(t, err)
+	require.Len(t, sts, 2)
+
+	require.Equal(t, 1, sts[0].Version)
+	require.True(t, sts[0].Applied)
+	require.False(t, sts[0].AppliedAt.IsZero())
+
+	require.Equal(t, 2, sts[1].Version)
+	require.False(t, sts[1].Applied)
+}
+
+func TestMigrator_Baseline(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	// schema applied by hand
+	_, err := db.Exec("CREATE TABLE users (username TEXT NOT NULL)")
+	require.Nil(t, err)
+
+	m := New(db, sq.Question, tMigrations)
+	n, err := m.Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	require.Nil(t, m.Verify(context.Background()))
+}
+
+func TestMigrator_BaselineFingerprint(t *testing.T) {
+	ms := []Migration{
+		tMigrations[1],
+		{
+			Version:     2,
+			Description: "add domain",
+			Baseline:    "SELECT domain FROM users WHERE 1 = 0",
+			Up:          []string{"ALTER TABLE users ADD COLUMN domain TEXT NOT NULL DEFAULT ''"},
+			Exec: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "UPDATE users SET domain = ?", "jackal.im")
+				return err
+			},
+		},
+		tMigrations[0],
+	}
+	ms[2].Version = 3
+
+	// former release schema
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("CREATE TABLE users (username TEXT NOT NULL)")
+	require.Nil(t, err)
+	_, err = db.Exec("INSERT INTO users (username) VALUES ('ortuman')")
+	require.Nil(t, err)
+
+	n, err := New(db, sq.Question, ms).Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+
+	var domain string
+	require.Nil(t, db.QueryRow("SELECT domain FROM users WHERE username = 'ortuman'").Scan(&domain))
+	require.Equal(t, "jackal.im", domain)
+
+	_, err = db.Exec("SELECT 1 FROM vcards")
+	require.Nil(t, err)
+
+	// schema already keyed by domain
+	db2 := tUtilMigrationSetup(t)
+	defer func() { _ = db2.Close() }()
+
+	_, err = db2.Exec("CREATE TABLE users (username TEXT NOT NULL, domain TEXT NOT NULL)")
+	require.Nil(t, err)
+
+	n, err = New(db2, sq.Question, ms).Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+}
+
+func TestMigrator_FailedRerun(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	var legacyDomain string
+	ms := []Migration{
+		tMigrations[1],
+		{
+			Version:     2,
+			Description: "add domain",
+			Check: func(ctx context.Context, db *sql.DB) error {
+				if len(legacyDomain) == 0 {
+					return errors.New("legacy domain not set")
+				}
+				return nil
+			},
+			Up: []string{
+				"ALTER TABLE users ADD COLUMN domain TEXT NOT NULL DEFAULT ''",
+				"CREATE TABLE vcards (username TEXT NOT NULL, domain TEXT NOT NULL)",
+			},
+			UpGuards: []string{
+				"SELECT domain FROM users WHERE 1 = 0",
+				"SELECT domain FROM vcards WHERE 1 = 0",
+			},
+			Exec: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "UPDATE users SET domain = ?", legacyDomain)
+				return err
+			},
+		},
+	}
+	m := New(db, sq.Question, ms)
+
+	_, err := New(db, sq.Question, ms[:1]).Up(context.Background())
+	require.Nil(t, err)
+	_, err = db.Exec("INSERT INTO users (username) VALUES ('ortuman')")
+	require.Nil(t, err)
+
+	// failed check leaves schema untouched
+	_, err = m.Up(context.Background())
+	require.NotNil(t, err)
+
+	_, err = db.Exec("SELECT domain FROM users")
+	require.NotNil(t, err)
+
+	// statement committed implicitly before a failure (as MySQL does with DDL statements)
+	_, err = db.Exec("ALTER TABLE users ADD COLUMN domain TEXT NOT NULL DEFAULT ''")
+	require.Nil(t, err)
+
+	// rerun skips already applied statements
+	legacyDomain = "jackal.im"
+
+	n, err := m.Up(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Nil(t, m.Verify(context.Background()))
+
+	var domain string
+	require.Nil(t, db.QueryRow("SELECT domain FROM users WHERE username = 'ortuman'").Scan(&domain))
+	require.Equal(t, "jackal.im", domain)
+
+	_, err = db.Exec("SELECT domain FROM vcards")
+	require.Nil(t, err)
+}
+
+func TestMigrator_AppliedMismatch(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := New(db, sq.Question, tMigrations).Up(context.Background())
+	require.Nil(t, err)
+
+	// released migration renumbered
+	ms := []Migration{
+		tMigrations[1],
+		{Version: 2, Description: "add domain", Up: []string{"ALTER TABLE users ADD COLUMN domain TEXT"}},
+		tMigrations[0],
+	}
+	ms[2].Version = 3
+
+	m := New(db, sq.Question, ms)
+	expectedErr := &AppliedMismatchError{Version: 2, AppliedDescription: "add vcards", KnownDescription: "add domain"}
+
+	require.Equal(t, expectedErr, m.Verify(context.Background()))
+	_, err = m.Up(context.Background())
+	require.Equal(t, expectedErr, err)
+}
+
+func TestMigrator_NewerSchema(t *testing.T) {
+	db := tUtilMigrationSetup(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := New(db, sq.Question, tMigrations).Up(context.Background())
+	require.Nil(t, err)
+
+	err = New(db, sq.Question, tMigrations[1:]).Verify(context.Background())
+	require.Equal(t, &VersionMismatchError{Current: 2, Expected: 1}, err)
+}
+
+func tUtilMigrationSetup(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	db.SetMaxOpenConns(1) // share a unique in-memory database
+	return db
+}
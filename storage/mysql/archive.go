@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+type mySQLArchive struct {
+	*mySQLStorage
+}
+
+func newArchive(db *sql.DB) *mySQLArchive {
+	return &mySQLArchive{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLArchive) InsertArchiveMessage(ctx context.Context, message *archivemodel.Message) error {
+	q := sq.Insert("archive_messages").
+		Columns("id", "username", "domain", "peer", "bare_peer", "data", "stamp", "created_at").
+		Values(message.ID, message.Username, message.Domain, message.With, message.BareWith(), message.Message.String(), message.Stamp.UTC(), nowExpr)
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLArchive) FetchArchiveMessages(ctx context.Context, query *archivemodel.Query, username, domain string) ([]archivemodel.Message, error) {
+	filter := archiveFilter(query, username, domain)
+	if len(query.After) > 0 {
+		filter = append(filter, sq.Expr("serial > (SELECT serial FROM archive_messages WHERE id = ? AND username = ? AND domain = ?)", query.After, username, domain))
+	}
+	if len(query.Before) > 0 {
+		filter = append(filter, sq.Expr("serial < (SELECT serial FROM archive_messages WHERE id = ? AND username = ? AND domain = ?)", query.Before, username, domain))
+	}
+	q := sq.Select("id", "peer", "data", "stamp").
+		From("archive_messages").
+		Where(filter)
+	if query.IsBackward() {
+		q = q.OrderBy("serial DESC")
+	} else {
+		q = q.OrderBy("serial")
+	}
+	if query.Max > 0 {
+		q = q.Limit(uint64(query.Max))
+	}
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []archivemodel.Message
+	for rows.Next() {
+		msg, err := scanArchiveMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msg.Username = username
+		msg.Domain = domain
+		messages = append(messages, *msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if query.IsBackward() {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, nil
+}
+
+func (s *mySQLArchive) CountArchiveMessages(ctx context.Context, query *archivemodel.Query, username, domain string) (int, error) {
+	q := sq.Select("COUNT(*)").
+		From("archive_messages").
+		Where(archiveFilter(query, username, domain))
+
+	var count int
+	if err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *mySQLArchive) ArchiveMessageExists(ctx context.Context, id, username, domain string) (bool, error) {
+	q := sq.Select("COUNT(*)").
+		From("archive_messages").
+		Where(sq.And{sq.Eq{"id": id}, sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var count int
+	if err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *mySQLArchive) DeleteArchiveMessages(ctx context.Context, before time.Time) error {
+	q := sq.Delete("archive_messages").Where(sq.Lt{"stamp": before.UTC()})
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLArchive) UpsertArchivePreferences(ctx context.Context, prefs *archivemodel.Preferences, username, domain string) error {
+	alwaysBytes, err := json.Marshal(prefs.Always)
+	if err != nil {
+		return err
+	}
+	neverBytes, err := json.Marshal(prefs.Never)
+	if err != nil {
+		return err
+	}
+	alwaysJSON, neverJSON := string(alwaysBytes), string(neverBytes)
+	q := sq.Insert("archive_preferences").
+		Columns("username", "domain", "default_mode", "always_jids", "never_jids", "updated_at", "created_at").
+		Values(username, domain, prefs.Default, alwaysJSON, neverJSON, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE default_mode = ?, always_jids = ?, never_jids = ?, updated_at = NOW()", prefs.Default, alwaysJSON, neverJSON)
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLArchive) FetchArchivePreferences(ctx context.Context, username, domain string) (*archivemodel.Preferences, error) {
+	q := sq.Select("default_mode", "always_jids", "never_jids").
+		From("archive_preferences").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var prefs archivemodel.Preferences
+	var alwaysJSON, neverJSON string
+
+	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&prefs.Default, &alwaysJSON, &neverJSON)
+	switch err {
+	case nil:
+		if err := json.NewDecoder(strings.NewReader(alwaysJSON)).Decode(&prefs.Always); err != nil {
+			return nil, err
+		}
+		if err := json.NewDecoder(strings.NewReader(neverJSON)).Decode(&prefs.Never); err != nil {
+			return nil, err
+		}
+		return &prefs, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func archiveFilter(query *archivemodel.Query, username, domain string) sq.And {
+	filter := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
+	if len(query.With) > 0 {
+		if strings.Contains(query.With, "/") {
+			filter = append(filter, sq.Eq{"peer": query.With})
+		} else {
+			filter = append(filter, sq.Eq{"bare_peer": query.With})
+		}
+	}
+	if !query.Start.IsZero() {
+		filter = append(filter, sq.GtOrEq{"stamp": query.Start.UTC()})
+	}
+	if !query.End.IsZero() {
+		filter = append(filter, sq.LtOrEq{"stamp": query.End.UTC()})
+	}
+	return filter
+}
+
+func scanArchiveMessage(scanner rowScanner) (*archivemodel.Message, error) {
+	var msg archivemodel.Message
+	var data string
+
+	if err := scanner.Scan(&msg.ID, &msg.With, &data, &msg.Stamp); err != nil {
+		return nil, err
+	}
+	parser := xmpp.NewParser(strings.NewReader(data), xmpp.DefaultMode, 0)
+	el, err := parser.ParseElement()
+	if err != nil {
+		return nil, err
+	}
+	fromJID, _ := jid.NewWithString(el.From(), true)
+	toJID, _ := jid.NewWithString(el.To(), true)
+
+	msg.Message, err = xmpp.NewMessageFromElement(el, fromJID, toJID)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
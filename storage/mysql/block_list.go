@@ -26,23 +26,23 @@ func newBlockList(db *sql.DB) *mySQLBlockList {
 func (s *mySQLBlockList) InsertBlockListItem(ctx context.Context, item *model.BlockListItem) error {
 	_, err := sq.Insert("blocklist_items").
 		Options("IGNORE").
-		Columns("username", "jid", "created_at").
-		Values(item.Username, item.JID, nowExpr).
+		Columns("username", "domain", "jid", "created_at").
+		Values(item.Username, item.Domain, item.JID, nowExpr).
 		RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
 func (s *mySQLBlockList) DeleteBlockListItem(ctx context.Context, item *model.BlockListItem) error {
 	_, err := sq.Delete("blocklist_items").
-		Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"jid": item.JID}}).
+		Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"domain": item.Domain}, sq.Eq{"jid": item.JID}}).
 		RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *mySQLBlockList) FetchBlockListItems(ctx context.Context, username string) ([]model.BlockListItem, error) {
-	q := sq.Select("username", "jid").
+func (s *mySQLBlockList) FetchBlockListItems(ctx context.Context, username, domain string) ([]model.BlockListItem, error) {
+	q := sq.Select("username", "domain", "jid").
 		From("blocklist_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -57,7 +57,7 @@ func scanBlockListItemEntities(scanner rowsScanner) ([]model.BlockListItem, erro
 	var ret []model.BlockListItem
 	for scanner.Next() {
 		var it model.BlockListItem
-		if err := scanner.Scan(&it.Username, &it.JID); err != nil {
+		if err := scanner.Scan(&it.Username, &it.Domain, &it.JID); err != nil {
 			return nil, err
 		}
 		ret = append(ret, it)
@@ -19,35 +19,35 @@ func TestMySQLStorageInsertBlockListItems(t *testing.T) {
 	mock.ExpectExec("INSERT IGNORE INTO blocklist_items (.+)").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newBlockListMock()
 	mock.ExpectExec("INSERT IGNORE INTO blocklist_items (.+)").WillReturnError(errMySQLStorage)
 
-	err = s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err = s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLFetchBlockListItems(t *testing.T) {
-	var blockListColumns = []string{"username", "jid"}
+	var blockListColumns = []string{"username", "domain", "jid"}
 	s, mock := newBlockListMock()
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("sxmpp", "noelia@jackal.im"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("sxmpp", "jackal.im", "noelia@jackal.im"))
 
-	_, err := s.FetchBlockListItems(context.Background(), "sxmpp")
+	_, err := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newBlockListMock()
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchBlockListItems(context.Background(), "sxmpp")
+	_, err = s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -55,15 +55,15 @@ func TestMySQLFetchBlockListItems(t *testing.T) {
 func TestMySQLStorageDeleteBlockListItems(t *testing.T) {
 	s, mock := newBlockListMock()
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	s, mock = newBlockListMock()
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("sxmpp", "noelia@jackal.im").
+		WithArgs("sxmpp", "jackal.im", "noelia@jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
@@ -71,7 +71,7 @@ func TestMySQLStorageDeleteBlockListItems(t *testing.T) {
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
 		WillReturnError(errMySQLStorage)
 
-	err = s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err = s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -10,11 +10,13 @@ const DefaultPoolSize = 16
 
 // Config represents MySQL storage configuration.
 type Config struct {
-	Host     string `yaml:"host"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Database string `yaml:"database"`
-	PoolSize int    `yaml:"pool_size"`
+	Host         string `yaml:"host"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	Database     string `yaml:"database"`
+	PoolSize     int    `yaml:"pool_size"`
+	AutoMigrate  bool   `yaml:"auto_migrate"`
+	LegacyDomain string `yaml:"legacy_domain"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface
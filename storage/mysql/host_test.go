@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLStorageUpsertHost(t *testing.T) {
+	h := &model.Host{Name: "jackal.im", Certificate: []byte("cert"), PrivateKey: []byte("key"), Enabled: true}
+
+	s, mock := newHostMock()
+	mock.ExpectExec("INSERT INTO hosts (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("jackal.im", "cert", "key", true, "cert", "key", true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertHost(context.Background(), h)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newHostMock()
+	mock.ExpectExec("INSERT INTO hosts (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("jackal.im", "cert", "key", true, "cert", "key", true).
+		WillReturnError(errMySQLStorage)
+
+	err = s.UpsertHost(context.Background(), h)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageDeleteHost(t *testing.T) {
+	s, mock := newHostMock()
+	mock.ExpectExec("DELETE FROM hosts (.+)").
+		WithArgs("jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteHost(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newHostMock()
+	mock.ExpectExec("DELETE FROM hosts (.+)").
+		WithArgs("jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	err = s.DeleteHost(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchHost(t *testing.T) {
+	var hostColumns = []string{"name", "certificate", "private_key", "enabled"}
+
+	s, mock := newHostMock()
+	mock.ExpectQuery("SELECT (.+) FROM hosts (.+)").
+		WithArgs("jackal.im").
+		WillReturnRows(sqlmock.NewRows(hostColumns).AddRow("jackal.im", "cert", "key", true))
+
+	h, err := s.FetchHost(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, h)
+	require.Equal(t, []byte("cert"), h.Certificate)
+	require.True(t, h.Enabled)
+
+	s, mock = newHostMock()
+	mock.ExpectQuery("SELECT (.+) FROM hosts (.+)").
+		WithArgs("jackal.im").
+		WillReturnRows(sqlmock.NewRows(hostColumns))
+
+	h, err = s.FetchHost(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, h)
+
+	s, mock = newHostMock()
+	mock.ExpectQuery("SELECT (.+) FROM hosts (.+)").
+		WithArgs("jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchHost(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchHosts(t *testing.T) {
+	var hostColumns = []string{"name", "certificate", "private_key", "enabled"}
+
+	s, mock := newHostMock()
+	mock.ExpectQuery("SELECT (.+) FROM hosts (.+)").
+		WillReturnRows(sqlmock.NewRows(hostColumns).
+			AddRow("jackal.im", "cert", "key", true).
+			AddRow("example.org", "cert", "key", false))
+
+	hosts, err := s.FetchHosts(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, hosts, 2)
+
+	s, mock = newHostMock()
+	mock.ExpectQuery("SELECT (.+) FROM hosts (.+)").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchHosts(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func newHostMock() (*mySQLHost, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLHost{
+		mySQLStorage: s,
+	}, sqlMock
+}
@@ -0,0 +1,550 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sxmpp/jackal/storage/migration"
+)
+
+// domainKeyedTables contains every table keyed by domain in migration 2.
+var domainKeyedTables = []string{
+	"users",
+	"roster_notifications",
+	"roster_items",
+	"roster_groups",
+	"roster_versions",
+	"blocklist_items",
+	"private_storage",
+	"vcards",
+	"offline_messages",
+}
+
+// migrations returns every MySQL schema migration, ordered by version.
+// New schema changes must be appended as a new migration, never by modifying an already released one.
+//
+// legacyDomain is assigned to every row stored prior to keying the schema by domain.
+func migrations(legacyDomain string) []migration.Migration {
+	return []migration.Migration{
+		{
+			Version:     1,
+			Description: "initial schema",
+			Baseline:    "SELECT username FROM users WHERE 1 = 0",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS users (
+    username         VARCHAR(256) PRIMARY KEY,
+    password         TEXT NOT NULL,
+    last_presence    TEXT NOT NULL,
+    last_presence_at DATETIME NOT NULL,
+    updated_at       DATETIME NOT NULL,
+    created_at       DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS presences (
+    username      VARCHAR(256) NOT NULL,
+    domain        VARCHAR(256) NOT NULL,
+    resource      VARCHAR(256) NOT NULL,
+    presence      TEXT NOT NULL,
+    node          VARCHAR(256) NOT NULL,
+    ver           VARCHAR(256) NOT NULL,
+    allocation_id VARCHAR(256) NOT NULL,
+    updated_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL,
+
+    PRIMARY KEY (username, domain, resource),
+
+    INDEX i_presences_username_domain(username, domain),
+    INDEX i_presences_domain_resource(domain, resource),
+    INDEX i_presences_allocation_id(allocation_id)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS capabilities (
+    node       VARCHAR(256) NOT NULL,
+    ver        VARCHAR(256) NOT NULL,
+    features   TEXT,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    PRIMARY KEY (node, ver)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact    VARCHAR(256) NOT NULL,
+    jid        VARCHAR(512) NOT NULL,
+    elements   TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    PRIMARY KEY (contact, jid),
+
+    INDEX i_roster_notifications_jid (jid)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS roster_items (
+    username     VARCHAR(256) NOT NULL,
+    jid          VARCHAR(512) NOT NULL,
+    name         TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    ` + "`groups`" + `     TEXT NOT NULL,
+    ask          BOOL NOT NULL,
+    ver          INT NOT NULL DEFAULT 0,
+    updated_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL,
+
+    PRIMARY KEY (username, jid),
+
+    INDEX i_roster_items_username(username),
+    INDEX i_roster_items_jid     (jid)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS roster_groups (
+    username     VARCHAR(256) NOT NULL,
+    jid          VARCHAR(512) NOT NULL,
+    ` + "`group`" + `      TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL,
+
+    INDEX i_roster_groups_username_jid (username, jid)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS roster_versions (
+    username          VARCHAR(256) NOT NULL,
+    ver               INT NOT NULL DEFAULT 0,
+    last_deletion_ver INT NOT NULL DEFAULT 0,
+    updated_at        DATETIME NOT NULL,
+    created_at        DATETIME NOT NULL,
+    PRIMARY KEY (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS blocklist_items (
+    username   VARCHAR(256) NOT NULL,
+    jid        VARCHAR(512) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(username, jid),
+
+    INDEX i_blocklist_items_username (username)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS private_storage (
+    username   VARCHAR(256) NOT NULL,
+    namespace  VARCHAR(512) NOT NULL,
+    data       MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, namespace),
+
+    INDEX i_private_storage_username (username)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS vcards (
+    username   VARCHAR(256) PRIMARY KEY,
+    vcard      MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS offline_messages (
+    username   VARCHAR(256) NOT NULL,
+    data       MEDIUMTEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_offline_messages_username (username)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    host       TEXT NOT NULL,
+    name       TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_pubsub_nodes_host (host(256)),
+    UNIQUE INDEX i_pubsub_nodes_host_name (host(256), name(512))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS pubsub_node_options (
+    node_id BIGINT NOT NULL,
+    name    TEXT NOT NULL,
+    value   TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_pubsub_node_options_node_id (node_id)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    node_id     BIGINT NOT NULL,
+    jid         TEXT NOT NULL,
+    affiliation TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_pubsub_affiliations_jid (jid(512)),
+    UNIQUE INDEX i_pubsub_affiliations_node_id_jid (node_id, jid(512))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    node_id      BIGINT NOT NULL,
+    subid        TEXT NOT NULL,
+    jid          TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL,
+
+    INDEX i_pubsub_subscriptions_jid (jid(512)),
+    UNIQUE INDEX i_pubsub_subscriptions_node_id_jid (node_id, jid(512))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS pubsub_items (
+    node_id    BIGINT NOT NULL,
+    item_id    TEXT NOT NULL,
+    payload    TEXT NOT NULL,
+    publisher  TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_pubsub_items_item_id (item_id(36)),
+    INDEX i_pubsub_items_node_id_created_at (node_id, created_at),
+    UNIQUE INDEX i_pubsub_items_node_id_item_id (node_id, item_id(36))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS pubsub_items`,
+				`DROP TABLE IF EXISTS pubsub_subscriptions`,
+				`DROP TABLE IF EXISTS pubsub_affiliations`,
+				`DROP TABLE IF EXISTS pubsub_node_options`,
+				`DROP TABLE IF EXISTS pubsub_nodes`,
+				`DROP TABLE IF EXISTS offline_messages`,
+				`DROP TABLE IF EXISTS vcards`,
+				`DROP TABLE IF EXISTS private_storage`,
+				`DROP TABLE IF EXISTS blocklist_items`,
+				`DROP TABLE IF EXISTS roster_versions`,
+				`DROP TABLE IF EXISTS roster_groups`,
+				`DROP TABLE IF EXISTS roster_items`,
+				`DROP TABLE IF EXISTS roster_notifications`,
+				`DROP TABLE IF EXISTS capabilities`,
+				`DROP TABLE IF EXISTS presences`,
+				`DROP TABLE IF EXISTS users`,
+			},
+		},
+		{
+			Version:     2,
+			Description: "domain keyed user data",
+			Baseline:    "SELECT domain FROM users WHERE 1 = 0",
+			Check:       checkLegacyDomain(legacyDomain),
+			// each table is altered by a single statement, which MySQL applies atomically,
+			// while existing rows are assigned an empty domain until legacy domain is set.
+			Up: []string{
+				`ALTER TABLE users ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE roster_notifications ADD COLUMN domain VARCHAR(256) NOT NULL AFTER contact,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (contact, domain, jid(256))`,
+				`ALTER TABLE roster_items ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain, jid(256)),
+    DROP INDEX i_roster_items_username,
+    ADD INDEX i_roster_items_username_domain (username, domain)`,
+				`ALTER TABLE roster_groups ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP INDEX i_roster_groups_username_jid,
+    ADD INDEX i_roster_groups_username_domain_jid (username, domain, jid(256))`,
+				`ALTER TABLE roster_versions ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE blocklist_items ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain, jid(256)),
+    DROP INDEX i_blocklist_items_username,
+    ADD INDEX i_blocklist_items_username_domain (username, domain)`,
+				`ALTER TABLE private_storage ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain, namespace(256)),
+    DROP INDEX i_private_storage_username,
+    ADD INDEX i_private_storage_username_domain (username, domain)`,
+				`ALTER TABLE vcards ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE offline_messages ADD COLUMN domain VARCHAR(256) NOT NULL AFTER username,
+    DROP INDEX i_offline_messages_username,
+    ADD INDEX i_offline_messages_username_domain (username, domain)`,
+			},
+			UpGuards: columnGuards("domain", domainKeyedTables...),
+			Exec:     assignLegacyDomain(legacyDomain),
+			Down: []string{
+				`ALTER TABLE offline_messages
+    DROP INDEX i_offline_messages_username_domain,
+    DROP COLUMN domain,
+    ADD INDEX i_offline_messages_username (username)`,
+				`ALTER TABLE vcards
+    DROP PRIMARY KEY,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+				`ALTER TABLE private_storage
+    DROP PRIMARY KEY,
+    DROP INDEX i_private_storage_username_domain,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, namespace),
+    ADD INDEX i_private_storage_username (username)`,
+				`ALTER TABLE blocklist_items
+    DROP PRIMARY KEY,
+    DROP INDEX i_blocklist_items_username_domain,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, jid),
+    ADD INDEX i_blocklist_items_username (username)`,
+				`ALTER TABLE roster_versions
+    DROP PRIMARY KEY,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+				`ALTER TABLE roster_groups
+    DROP INDEX i_roster_groups_username_domain_jid,
+    DROP COLUMN domain,
+    ADD INDEX i_roster_groups_username_jid (username, jid)`,
+				`ALTER TABLE roster_items
+    DROP PRIMARY KEY,
+    DROP INDEX i_roster_items_username_domain,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, jid),
+    ADD INDEX i_roster_items_username (username)`,
+				`ALTER TABLE roster_notifications
+    DROP PRIMARY KEY,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (contact, jid)`,
+				`ALTER TABLE users
+    DROP PRIMARY KEY,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+			},
+		},
+		{
+			Version:     3,
+			Description: "virtual hosts",
+			Baseline:    "SELECT name FROM hosts WHERE 1 = 0",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS hosts (
+    name        VARCHAR(256) PRIMARY KEY,
+    certificate TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    enabled     BOOL NOT NULL,
+    updated_at  DATETIME NOT NULL,
+    created_at  DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS hosts`,
+			},
+		},
+		{
+			Version:     4,
+			Description: "user account status",
+			Up: []string{
+				`ALTER TABLE users
+    ADD COLUMN email         VARCHAR(256) NOT NULL DEFAULT '',
+    ADD COLUMN status        VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason VARCHAR(1024) NOT NULL DEFAULT '',
+    ADD COLUMN expires_at    DATETIME NULL,
+    ADD INDEX i_users_status_expires_at (status, expires_at)`,
+			},
+			UpGuards: columnGuards("status", "users"),
+			Down: []string{
+				`ALTER TABLE users
+    DROP INDEX i_users_status_expires_at,
+    DROP COLUMN expires_at,
+    DROP COLUMN status_reason,
+    DROP COLUMN status,
+    DROP COLUMN email`,
+			},
+		},
+		{
+			Version:     5,
+			Description: "message archive",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS archive_messages (
+    serial     BIGINT AUTO_INCREMENT PRIMARY KEY,
+    id         VARCHAR(64) NOT NULL,
+    username   VARCHAR(256) NOT NULL,
+    domain     VARCHAR(256) NOT NULL,
+    peer       TEXT NOT NULL,
+    bare_peer  VARCHAR(512) NOT NULL,
+    data       MEDIUMTEXT NOT NULL,
+    stamp      DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    INDEX i_archive_messages_username_domain_serial (username, domain, serial),
+    INDEX i_archive_messages_username_domain_stamp (username, domain, stamp),
+    INDEX i_archive_messages_id (id),
+    INDEX i_archive_messages_stamp (stamp)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS archive_preferences (
+    username     VARCHAR(256) NOT NULL,
+    domain       VARCHAR(256) NOT NULL,
+    default_mode VARCHAR(16) NOT NULL,
+    always_jids  TEXT NOT NULL,
+    never_jids   TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL,
+
+    PRIMARY KEY (username, domain)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS archive_preferences`,
+				`DROP TABLE IF EXISTS archive_messages`,
+			},
+		},
+		{
+			Version:     6,
+			Description: "push notifications registrations",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS push_registrations (
+    username   VARCHAR(256) NOT NULL,
+    domain     VARCHAR(256) NOT NULL,
+    jid        VARCHAR(512) NOT NULL,
+    node       VARCHAR(256) NOT NULL,
+    options    TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    PRIMARY KEY (username, domain, jid(256), node)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS push_registrations`,
+			},
+		},
+		{
+			Version:     7,
+			Description: "offline message identifiers",
+			Up: []string{
+				`ALTER TABLE offline_messages
+    ADD COLUMN id VARCHAR(64) NOT NULL DEFAULT '' FIRST`,
+				`UPDATE offline_messages SET id = UUID() WHERE id = ''`,
+				`ALTER TABLE offline_messages
+    ADD INDEX i_offline_messages_username_domain_id (username, domain, id)`,
+			},
+			UpGuards: []string{
+				"SELECT id FROM offline_messages WHERE 1 = 0",
+				"", // only assigns missing identifiers
+				"SELECT id FROM offline_messages FORCE INDEX (i_offline_messages_username_domain_id) WHERE 1 = 0",
+			},
+			Down: []string{
+				`ALTER TABLE offline_messages
+    DROP INDEX i_offline_messages_username_domain_id,
+    DROP COLUMN id`,
+			},
+		},
+		{
+			Version:     8,
+			Description: "offline message expiration and size",
+			Up: []string{
+				`ALTER TABLE offline_messages
+    ADD COLUMN size       INT NOT NULL DEFAULT 0 AFTER data,
+    ADD COLUMN expires_at DATETIME NULL AFTER size,
+    ADD INDEX i_offline_messages_expires_at (expires_at)`,
+				`UPDATE offline_messages SET size = LENGTH(data)`,
+			},
+			UpGuards: []string{
+				"SELECT size FROM offline_messages WHERE 1 = 0",
+				"", // recomputing sizes is harmless
+			},
+			Down: []string{
+				`ALTER TABLE offline_messages
+    DROP INDEX i_offline_messages_expires_at,
+    DROP COLUMN expires_at,
+    DROP COLUMN size`,
+			},
+		},
+		{
+			Version:     9,
+			Description: "multi-user chat rooms and nick reservations",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS muc_rooms (
+    jid          VARCHAR(512) NOT NULL,
+    service      VARCHAR(256) NOT NULL,
+    config       TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    affiliations TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL,
+    created_at   DATETIME NOT NULL,
+
+    PRIMARY KEY (jid),
+    INDEX i_muc_rooms_service (service)
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+				`CREATE TABLE IF NOT EXISTS muc_nick_reservations (
+    room_jid   VARCHAR(512) NOT NULL,
+    jid        VARCHAR(512) NOT NULL,
+    nick       VARCHAR(256) NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    PRIMARY KEY (room_jid(256), jid(256))
+
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS muc_nick_reservations`,
+				`DROP TABLE IF EXISTS muc_rooms`,
+			},
+		},
+	}
+}
+
+// checkLegacyDomain returns a migration check refusing to key the schema by domain whenever there are rows
+// stored and no legacy domain has been set, before any table has been altered.
+func checkLegacyDomain(legacyDomain string) func(ctx context.Context, db *sql.DB) error {
+	return func(ctx context.Context, db *sql.DB) error {
+		if len(legacyDomain) > 0 {
+			return nil
+		}
+		for _, table := range domainKeyedTables {
+			var count int
+			err := sq.Select("COUNT(*)").
+				From(table).
+				RunWith(db).QueryRowContext(ctx).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("mysql: legacy_domain must be set in order to upgrade existing %s rows", table)
+			}
+		}
+		return nil
+	}
+}
+
+// assignLegacyDomain returns a migration step assigning legacyDomain to every row stored prior to keying the schema by domain.
+func assignLegacyDomain(legacyDomain string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		if len(legacyDomain) == 0 {
+			return nil // no rows stored, as checked beforehand
+		}
+		for _, table := range domainKeyedTables {
+			_, err := sq.Update(table).
+				Set("domain", legacyDomain).
+				Where(sq.Eq{"domain": ""}).
+				RunWith(tx).ExecContext(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// columnGuards returns a migration guard for each table, only succeeding once column has been added to it.
+func columnGuards(column string, tables ...string) []string {
+	guards := make([]string, len(tables))
+	for i, table := range tables {
+		guards[i] = fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 0", column, table)
+	}
+	return guards
+}
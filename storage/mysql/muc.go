@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+)
+
+type mySQLMuc struct {
+	*mySQLStorage
+}
+
+func newMuc(db *sql.DB) *mySQLMuc {
+	return &mySQLMuc{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLMuc) UpsertMucRoom(ctx context.Context, room *mucmodel.Room) error {
+	configJSON, affiliationsJSON, err := marshalMucRoom(room)
+	if err != nil {
+		return err
+	}
+	q := sq.Insert("muc_rooms").
+		Columns("jid", "service", "config", "subject", "affiliations", "updated_at", "created_at").
+		Values(room.JID, room.Service(), configJSON, room.Subject, affiliationsJSON, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE config = ?, subject = ?, affiliations = ?, updated_at = NOW()", configJSON, room.Subject, affiliationsJSON)
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMuc) DeleteMucRoom(ctx context.Context, roomJID string) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+		if _, err := sq.Delete("muc_nick_reservations").Where(sq.Eq{"room_jid": roomJID}).RunWith(tx).ExecContext(ctx); err != nil {
+			return err
+		}
+		_, err := sq.Delete("muc_rooms").Where(sq.Eq{"jid": roomJID}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (s *mySQLMuc) FetchMucRoom(ctx context.Context, roomJID string) (*mucmodel.Room, error) {
+	q := sq.Select("jid", "config", "subject", "affiliations").
+		From("muc_rooms").
+		Where(sq.Eq{"jid": roomJID})
+
+	room, err := scanMucRoom(q.RunWith(s.db).QueryRowContext(ctx))
+	switch err {
+	case nil:
+		return room, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (s *mySQLMuc) FetchMucRooms(ctx context.Context, service string) ([]mucmodel.Room, error) {
+	q := sq.Select("jid", "config", "subject", "affiliations").
+		From("muc_rooms").
+		Where(sq.Eq{"service": service}).
+		OrderBy("jid")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rooms []mucmodel.Room
+	for rows.Next() {
+		room, err := scanMucRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (s *mySQLMuc) UpsertMucNickReservation(ctx context.Context, reservation *mucmodel.NickReservation) error {
+	q := sq.Insert("muc_nick_reservations").
+		Columns("room_jid", "jid", "nick", "updated_at", "created_at").
+		Values(reservation.RoomJID, reservation.JID, reservation.Nick, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE nick = ?, updated_at = NOW()", reservation.Nick)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMuc) DeleteMucNickReservation(ctx context.Context, roomJID, jid string) error {
+	_, err := sq.Delete("muc_nick_reservations").
+		Where(sq.And{sq.Eq{"room_jid": roomJID}, sq.Eq{"jid": jid}}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLMuc) FetchMucNickReservations(ctx context.Context, roomJID string) ([]mucmodel.NickReservation, error) {
+	q := sq.Select("room_jid", "jid", "nick").
+		From("muc_nick_reservations").
+		Where(sq.Eq{"room_jid": roomJID}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var reservations []mucmodel.NickReservation
+	for rows.Next() {
+		var r mucmodel.NickReservation
+		if err := rows.Scan(&r.RoomJID, &r.JID, &r.Nick); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// deleteMucAffiliations strips a user from every room affiliation list.
+func deleteMucAffiliations(ctx context.Context, tx *sql.Tx, userJID string) error {
+	rows, err := sq.Select("jid", "config", "subject", "affiliations").
+		From("muc_rooms").
+		Where(sq.Expr("affiliations LIKE ?", "%"+userJID+"%")).
+		Suffix("FOR UPDATE").
+		RunWith(tx).QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	var rooms []*mucmodel.Room
+	for rows.Next() {
+		room, err := scanMucRoom(rows)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if room.Affiliation(userJID) != mucmodel.None {
+			rooms = append(rooms, room)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+	_ = rows.Close()
+
+	for _, room := range rooms {
+		room.SetAffiliation(userJID, mucmodel.None)
+		_, affiliationsJSON, err := marshalMucRoom(room)
+		if err != nil {
+			return err
+		}
+		_, err = sq.Update("muc_rooms").
+			Set("affiliations", affiliationsJSON).
+			Where(sq.Eq{"jid": room.JID}).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalMucRoom(room *mucmodel.Room) (configJSON, affiliationsJSON string, err error) {
+	configBytes, err := json.Marshal(&room.Config)
+	if err != nil {
+		return "", "", err
+	}
+	affiliationsBytes, err := json.Marshal(room.Affiliations)
+	if err != nil {
+		return "", "", err
+	}
+	return string(configBytes), string(affiliationsBytes), nil
+}
+
+func scanMucRoom(scanner rowScanner) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	var configJSON, affiliationsJSON string
+	if err := scanner.Scan(&room.JID, &configJSON, &room.Subject, &affiliationsJSON); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(configJSON), &room.Config); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(affiliationsJSON), &room.Affiliations); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	_ "github.com/go-sql-driver/mysql" // SQL driver
 	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/storage/migration"
 	"github.com/sxmpp/jackal/storage/repository"
 )
 
@@ -25,6 +27,10 @@ type mySQLContainer struct {
 	blockList *mySQLBlockList
 	pubSub    *mySQLPubSub
 	offline   *mySQLOffline
+	archive   *mySQLArchive
+	push      *mySQLPush
+	muc       *mySQLMuc
+	host      *mySQLHost
 
 	h      *sql.DB
 	doneCh chan chan bool
@@ -34,22 +40,28 @@ type mySQLContainer struct {
 func New(cfg *Config) (repository.Container, error) {
 	var err error
 	c := &mySQLContainer{doneCh: make(chan chan bool, 1)}
-	host := cfg.Host
-	usr := cfg.User
-	pass := cfg.Password
-	db := cfg.Database
-	poolSize := cfg.PoolSize
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", usr, pass, host, db)
-	c.h, err = sql.Open("mysql", dsn)
+
+	c.h, err = sql.Open("mysql", dataSourceName(cfg))
 	if err != nil {
 		return nil, err
 	}
-	c.h.SetMaxOpenConns(poolSize) // set max opened connection count
+	c.h.SetMaxOpenConns(cfg.PoolSize) // set max opened connection count
 
 	if err := c.h.Ping(); err != nil {
 		return nil, err
 	}
+	// verify schema version before serving
+	m := migration.New(c.h, sq.Question, migrations(cfg.LegacyDomain))
+	if cfg.AutoMigrate {
+		if _, err := m.Up(context.Background()); err != nil {
+			_ = c.h.Close()
+			return nil, err
+		}
+	}
+	if err := m.Verify(context.Background()); err != nil {
+		_ = c.h.Close()
+		return nil, err
+	}
 	go c.loop()
 
 	c.user = newUser(c.h)
@@ -60,10 +72,27 @@ func New(cfg *Config) (repository.Container, error) {
 	c.blockList = newBlockList(c.h)
 	c.pubSub = newPubSub(c.h)
 	c.offline = newOffline(c.h)
+	c.archive = newArchive(c.h)
+	c.push = newPush(c.h)
+	c.muc = newMuc(c.h)
+	c.host = newHost(c.h)
 
 	return c, nil
 }
 
+// NewMigrator returns a schema migrator operating over the configured MySQL database.
+func NewMigrator(cfg *Config) (*migration.Migrator, error) {
+	h, err := sql.Open("mysql", dataSourceName(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Ping(); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return migration.New(h, sq.Question, migrations(cfg.LegacyDomain)), nil
+}
+
 func (c *mySQLContainer) User() repository.User           { return c.user }
 func (c *mySQLContainer) Roster() repository.Roster       { return c.roster }
 func (c *mySQLContainer) Presences() repository.Presences { return c.presences }
@@ -72,6 +101,10 @@ func (c *mySQLContainer) Private() repository.Private     { return c.priv }
 func (c *mySQLContainer) BlockList() repository.BlockList { return c.blockList }
 func (c *mySQLContainer) PubSub() repository.PubSub       { return c.pubSub }
 func (c *mySQLContainer) Offline() repository.Offline     { return c.offline }
+func (c *mySQLContainer) Archive() repository.Archive     { return c.archive }
+func (c *mySQLContainer) Push() repository.Push           { return c.push }
+func (c *mySQLContainer) Muc() repository.Muc             { return c.muc }
+func (c *mySQLContainer) Host() repository.Host           { return c.host }
 
 func (c *mySQLContainer) Close(ctx context.Context) error {
 	ch := make(chan bool)
@@ -105,3 +138,7 @@ func (c *mySQLContainer) loop() {
 		}
 	}
 }
+
+func dataSourceName(cfg *Config) string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", cfg.User, cfg.Password, cfg.Host, cfg.Database)
+}
@@ -8,13 +8,18 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 	"github.com/sxmpp/jackal/util/pool"
 	"github.com/sxmpp/jackal/xmpp"
 	"github.com/sxmpp/jackal/xmpp/jid"
 )
 
+var errInvalidOfflineMessage = errors.New("mysql: invalid offline message")
+
 type mySQLOffline struct {
 	*mySQLStorage
 	pool *pool.BufferPool
@@ -27,19 +32,24 @@ func newOffline(db *sql.DB) *mySQLOffline {
 	}
 }
 
-func (s *mySQLOffline) InsertOfflineMessage(ctx context.Context, message *xmpp.Message, username string) error {
+func (s *mySQLOffline) InsertOfflineMessage(ctx context.Context, message *offlinemodel.Message, username, domain string) error {
+	var expiresAt interface{}
+	if !message.ExpiresAt.IsZero() {
+		expiresAt = message.ExpiresAt.UTC()
+	}
+	data := message.Message.String()
+
 	q := sq.Insert("offline_messages").
-		Columns("username", "data", "created_at").
-		Values(username, message.String(), nowExpr)
+		Columns("id", "username", "domain", "data", "size", "expires_at", "created_at").
+		Values(message.ID, username, domain, data, len(data), expiresAt, nowExpr)
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *mySQLOffline) CountOfflineMessages(ctx context.Context, username string) (int, error) {
+func (s *mySQLOffline) CountOfflineMessages(ctx context.Context, username, domain string) (int, error) {
 	q := sq.Select("COUNT(*)").
 		From("offline_messages").
-		Where(sq.Eq{"username": username}).
-		OrderBy("created_at")
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var count int
 	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&count)
@@ -51,10 +61,25 @@ func (s *mySQLOffline) CountOfflineMessages(ctx context.Context, username string
 	}
 }
 
-func (s *mySQLOffline) FetchOfflineMessages(ctx context.Context, username string) ([]xmpp.Message, error) {
-	q := sq.Select("data").
+func (s *mySQLOffline) FetchOfflineMessagesSize(ctx context.Context, username, domain string) (int, error) {
+	q := sq.Select("COALESCE(SUM(size), 0)").
 		From("offline_messages").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var size int
+	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&size)
+	switch err {
+	case nil:
+		return size, nil
+	default:
+		return 0, err
+	}
+}
+
+func (s *mySQLOffline) FetchOfflineMessages(ctx context.Context, username, domain string) ([]offlinemodel.Message, error) {
+	q := sq.Select("id", "data", "expires_at").
+		From("offline_messages").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -63,17 +88,72 @@ func (s *mySQLOffline) FetchOfflineMessages(ctx context.Context, username string
 	}
 	defer func() { _ = rows.Close() }()
 
+	return s.scanOfflineMessages(rows)
+}
+
+func (s *mySQLOffline) DeleteOfflineMessagesByID(ctx context.Context, ids []string, username, domain string) error {
+	q := sq.Delete("offline_messages").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"id": ids}})
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLOffline) DeleteOfflineMessages(ctx context.Context, username, domain string) error {
+	q := sq.Delete("offline_messages").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLOffline) DeleteExpiredOfflineMessages(ctx context.Context, at time.Time) ([]offlinemodel.Message, error) {
+	var messages []offlinemodel.Message
+	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
+		rows, err := sq.Select("id", "data", "expires_at").
+			From("offline_messages").
+			Where(sq.LtOrEq{"expires_at": at.UTC()}).
+			OrderBy("created_at").
+			Suffix("FOR UPDATE").
+			RunWith(tx).QueryContext(ctx)
+		if err != nil {
+			return err
+		}
+		messages, err = s.scanOfflineMessages(rows)
+		_ = rows.Close()
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]string, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		_, err = sq.Delete("offline_messages").Where(sq.Eq{"id": ids}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *mySQLOffline) scanOfflineMessages(rows *sql.Rows) ([]offlinemodel.Message, error) {
 	buf := s.pool.Get()
 	defer s.pool.Put(buf)
 
+	var ids []string
+	var expiresAts []sql.NullTime
+
 	buf.WriteString("<r>")
 	for rows.Next() {
-		var msg string
-		if err := rows.Scan(&msg); err != nil {
+		var id, msg string
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&id, &msg, &expiresAt); err != nil {
 			return nil, err
 		}
+		ids = append(ids, id)
+		expiresAts = append(expiresAts, expiresAt)
 		buf.WriteString(msg)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	buf.WriteString("</r>")
 
 	parser := xmpp.NewParser(buf, xmpp.DefaultMode, 0)
@@ -83,7 +163,10 @@ func (s *mySQLOffline) FetchOfflineMessages(ctx context.Context, username string
 	}
 	elements := rootEl.Elements().All()
 
-	messages := make([]xmpp.Message, len(elements))
+	if len(elements) != len(ids) {
+		return nil, errInvalidOfflineMessage
+	}
+	messages := make([]offlinemodel.Message, len(elements))
 	for i, el := range elements {
 		fromJID, _ := jid.NewWithString(el.From(), true)
 		toJID, _ := jid.NewWithString(el.To(), true)
@@ -92,13 +175,10 @@ func (s *mySQLOffline) FetchOfflineMessages(ctx context.Context, username string
 		if err != nil {
 			return nil, err
 		}
-		messages[i] = *msg
+		messages[i] = offlinemodel.Message{ID: ids[i], Message: msg}
+		if expiresAts[i].Valid {
+			messages[i].ExpiresAt = expiresAts[i].Time
+		}
 	}
 	return messages, nil
 }
-
-func (s *mySQLOffline) DeleteOfflineMessages(ctx context.Context, username string) error {
-	q := sq.Delete("offline_messages").Where(sq.Eq{"username": username})
-	_, err := q.RunWith(s.db).ExecContext(ctx)
-	return err
-}
@@ -26,7 +26,7 @@ func newPrivate(db *sql.DB) *mySQLPrivate {
 	}
 }
 
-func (s *mySQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username string) error {
+func (s *mySQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace, username, domain string) error {
 	buf := s.pool.Get()
 	defer s.pool.Put(buf)
 	for _, elem := range privateXML {
@@ -37,18 +37,18 @@ func (s *mySQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.X
 	rawXML := buf.String()
 
 	q := sq.Insert("private_storage").
-		Columns("username", "namespace", "data", "updated_at", "created_at").
-		Values(username, namespace, rawXML, nowExpr, nowExpr).
+		Columns("username", "domain", "namespace", "data", "updated_at", "created_at").
+		Values(username, domain, namespace, rawXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE data = ?, updated_at = NOW()", rawXML)
 
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *mySQLPrivate) FetchPrivateXML(ctx context.Context, namespace string, username string) ([]xmpp.XElement, error) {
+func (s *mySQLPrivate) FetchPrivateXML(ctx context.Context, namespace, username, domain string) ([]xmpp.XElement, error) {
 	q := sq.Select("data").
 		From("private_storage").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"namespace": namespace}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"namespace": namespace}})
 
 	var privateXML string
 	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&privateXML)
@@ -73,3 +73,25 @@ func (s *mySQLPrivate) FetchPrivateXML(ctx context.Context, namespace string, us
 		return nil, err
 	}
 }
+
+func (s *mySQLPrivate) FetchPrivateXMLNamespaces(ctx context.Context, username, domain string) ([]string, error) {
+	rows, err := sq.Select("namespace").
+		From("private_storage").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("namespace").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, rows.Err()
+}
@@ -21,19 +21,19 @@ func TestMySQLStorageInsertPrivateXML(t *testing.T) {
 
 	s, mock := newPrivateMock()
 	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", "exodus:ns", rawXML, rawXML).
+		WithArgs("sxmpp", "jackal.im", "exodus:ns", rawXML, rawXML).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp")
+	err := s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newPrivateMock()
 	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", "exodus:ns", rawXML, rawXML).
+		WithArgs("sxmpp", "jackal.im", "exodus:ns", rawXML, rawXML).
 		WillReturnError(errMySQLStorage)
 
-	err = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp")
+	err = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -43,50 +43,50 @@ func TestMySQLStorageFetchPrivateXML(t *testing.T) {
 
 	s, mock := newPrivateMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("sxmpp", "exodus:ns").
+		WithArgs("sxmpp", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/></exodus>"))
 
-	elems, err := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, err := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(elems))
 
 	s, mock = newPrivateMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("sxmpp", "exodus:ns").
+		WithArgs("sxmpp", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/>"))
 
-	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = newPrivateMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("sxmpp", "exodus:ns").
+		WithArgs("sxmpp", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow(""))
 
-	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = newPrivateMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("sxmpp", "exodus:ns").
+		WithArgs("sxmpp", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns))
 
-	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = newPrivateMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("sxmpp", "exodus:ns").
+		WithArgs("sxmpp", "jackal.im", "exodus:ns").
 		WillReturnError(errMySQLStorage)
 
-	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp")
+	elems, err = s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 	require.Equal(t, 0, len(elems))
@@ -99,3 +99,24 @@ func newPrivateMock() (*mySQLPrivate, sqlmock.Sqlmock) {
 		pool:         pool.NewBufferPool(),
 	}, sqlMock
 }
+
+func TestMySQLStorageFetchPrivateXMLNamespaces(t *testing.T) {
+	s, mock := newPrivateMock()
+	mock.ExpectQuery("SELECT namespace FROM private_storage (.+)").
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"namespace"}).AddRow("exodus:ns").AddRow("storage:bookmarks"))
+
+	namespaces, err := s.FetchPrivateXMLNamespaces(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, []string{"exodus:ns", "storage:bookmarks"}, namespaces)
+
+	s, mock = newPrivateMock()
+	mock.ExpectQuery("SELECT namespace FROM private_storage (.+)").
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.FetchPrivateXMLNamespaces(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
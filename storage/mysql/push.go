@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+)
+
+type mySQLPush struct {
+	*mySQLStorage
+}
+
+func newPush(db *sql.DB) *mySQLPush {
+	return &mySQLPush{
+		mySQLStorage: newStorage(db),
+	}
+}
+
+func (s *mySQLPush) UpsertPushRegistration(ctx context.Context, reg *pushmodel.Registration) error {
+	optionsBytes, err := json.Marshal(reg.Options)
+	if err != nil {
+		return err
+	}
+	optionsJSON := string(optionsBytes)
+	q := sq.Insert("push_registrations").
+		Columns("username", "domain", "jid", "node", "options", "updated_at", "created_at").
+		Values(reg.Username, reg.Domain, reg.JID, reg.Node, optionsJSON, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE options = ?, updated_at = NOW()", optionsJSON)
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLPush) DeletePushRegistrations(ctx context.Context, username, domain, jid, node string) error {
+	filter := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}
+	if len(node) > 0 {
+		filter = append(filter, sq.Eq{"node": node})
+	}
+	_, err := sq.Delete("push_registrations").Where(filter).RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *mySQLPush) FetchPushRegistrations(ctx context.Context, username, domain string) ([]pushmodel.Registration, error) {
+	q := sq.Select("jid", "node", "options").
+		From("push_registrations").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var regs []pushmodel.Registration
+	for rows.Next() {
+		reg := pushmodel.Registration{Username: username, Domain: domain}
+		var optionsJSON string
+		if err := rows.Scan(&reg.JID, &reg.Node, &optionsJSON); err != nil {
+			return nil, err
+		}
+		if err := json.NewDecoder(strings.NewReader(optionsJSON)).Decode(&reg.Options); err != nil {
+			return nil, err
+		}
+		regs = append(regs, reg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLStorageUpsertPushRegistration(t *testing.T) {
+	reg := testPushRegistration()
+
+	s, mock := newPushMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("juliet", "jackal.im", "push.jackal.im", "yxs32uqsflafdk3iuqo", `{"secret":"eruio234vzxc2kla-91"}`, `{"secret":"eruio234vzxc2kla-91"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertPushRegistration(context.Background(), reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newPushMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON DUPLICATE KEY UPDATE (.+)").
+		WithArgs("juliet", "jackal.im", "push.jackal.im", "yxs32uqsflafdk3iuqo", `{"secret":"eruio234vzxc2kla-91"}`, `{"secret":"eruio234vzxc2kla-91"}`).
+		WillReturnError(errMySQLStorage)
+
+	err = s.UpsertPushRegistration(context.Background(), reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageDeletePushRegistrations(t *testing.T) {
+	s, mock := newPushMock()
+	mock.ExpectExec("DELETE FROM push_registrations WHERE \\(username = \\? AND domain = \\? AND jid = \\? AND node = \\?\\)").
+		WithArgs("juliet", "jackal.im", "push.jackal.im", "yxs32uqsflafdk3iuqo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeletePushRegistrations(context.Background(), "juliet", "jackal.im", "push.jackal.im", "yxs32uqsflafdk3iuqo")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	// all app server nodes
+	s, mock = newPushMock()
+	mock.ExpectExec("DELETE FROM push_registrations WHERE \\(username = \\? AND domain = \\? AND jid = \\?\\)").
+		WithArgs("juliet", "jackal.im", "push.jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	err = s.DeletePushRegistrations(context.Background(), "juliet", "jackal.im", "push.jackal.im", "")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchPushRegistrations(t *testing.T) {
+	columns := []string{"jid", "node", "options"}
+
+	s, mock := newPushMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations WHERE \\(username = \\? AND domain = \\?\\) ORDER BY created_at").
+		WithArgs("juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("push.jackal.im", "yxs32uqsflafdk3iuqo", `{"secret":"eruio234vzxc2kla-91"}`))
+
+	regs, err := s.FetchPushRegistrations(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, regs, 1)
+	require.Equal(t, *testPushRegistration(), regs[0])
+
+	s, mock = newPushMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnError(errMySQLStorage)
+
+	regs, err = s.FetchPushRegistrations(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+	require.Nil(t, regs)
+}
+
+func testPushRegistration() *pushmodel.Registration {
+	return &pushmodel.Registration{
+		Username: "juliet",
+		Domain:   "jackal.im",
+		JID:      "push.jackal.im",
+		Node:     "yxs32uqsflafdk3iuqo",
+		Options:  map[string]string{"secret": "eruio234vzxc2kla-91"},
+	}
+}
+
+func newPushMock() (*mySQLPush, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &mySQLPush{
+		mySQLStorage: s,
+	}, sqlMock
+}
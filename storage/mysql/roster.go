@@ -32,8 +32,8 @@ func (s *mySQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 
 	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username", "created_at", "updated_at").
-			Values(ri.Username, nowExpr, nowExpr).
+			Columns("username", "domain", "created_at", "updated_at").
+			Values(ri.Username, ri.Domain, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, updated_at = NOW()")
 		if _, err := q.RunWith(tx).ExecContext(ctx); err != nil {
 			return err
@@ -43,10 +43,10 @@ func (s *mySQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 			return err
 		}
 
-		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ?)", ri.Username)
+		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ? AND domain = ?)", ri.Username, ri.Domain)
 		q = sq.Insert("roster_items").
-			Columns("username", "jid", "name", "subscription", "`groups`", "ask", "ver", "created_at", "updated_at").
-			Values(ri.Username, ri.JID, ri.Name, ri.Subscription, groupsBytes, ri.Ask, verExpr, nowExpr, nowExpr).
+			Columns("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver", "created_at", "updated_at").
+			Values(ri.Username, ri.Domain, ri.JID, ri.Name, ri.Subscription, groupsBytes, ri.Ask, verExpr, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE name = ?, subscription = ?, `groups` = ?, ask = ?, ver = ver + 1, updated_at = NOW()", ri.Name, ri.Subscription, groupsBytes, ri.Ask)
 		_, err = q.RunWith(tx).ExecContext(ctx)
 		if err != nil {
@@ -54,7 +54,7 @@ func (s *mySQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 		}
 		// delete previous groups
 		_, err = sq.Delete("roster_groups").
-			Where(sq.And{sq.Eq{"username": ri.Username}, sq.Eq{"jid": ri.JID}}).
+			Where(sq.And{sq.Eq{"username": ri.Username}, sq.Eq{"domain": ri.Domain}, sq.Eq{"jid": ri.JID}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
@@ -62,15 +62,15 @@ func (s *mySQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 		// insert groups
 		for _, group := range ri.Groups {
 			q = sq.Insert("roster_groups").
-				Columns("username", "jid", "`group`", "created_at", "updated_at").
-				Values(ri.Username, ri.JID, group, nowExpr, nowExpr)
+				Columns("username", "domain", "jid", "`group`", "created_at", "updated_at").
+				Values(ri.Username, ri.Domain, ri.JID, group, nowExpr, nowExpr)
 			_, err := q.RunWith(tx).ExecContext(ctx)
 			if err != nil {
 				return err
 			}
 		}
 		// fetch new roster version
-		ver, err = fetchRosterVer(ctx, ri.Username, tx)
+		ver, err = fetchRosterVer(ctx, ri.Username, ri.Domain, tx)
 		return err
 	})
 	if err != nil {
@@ -79,13 +79,13 @@ func (s *mySQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 	return ver, nil
 }
 
-func (s *mySQLRoster) DeleteRosterItem(ctx context.Context, username, jid string) (rostermodel.Version, error) {
+func (s *mySQLRoster) DeleteRosterItem(ctx context.Context, username, domain, jid string) (rostermodel.Version, error) {
 	var ver rostermodel.Version
 
 	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username", "created_at", "updated_at").
-			Values(username, nowExpr, nowExpr).
+			Columns("username", "domain", "created_at", "updated_at").
+			Values(username, domain, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, last_deletion_ver = ver, updated_at = NOW()")
 
 		if _, err := q.RunWith(tx).ExecContext(ctx); err != nil {
@@ -93,21 +93,21 @@ func (s *mySQLRoster) DeleteRosterItem(ctx context.Context, username, jid string
 		}
 		// delete groups
 		_, err := sq.Delete("roster_groups").
-			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
 		}
 		// delete items
 		_, err = sq.Delete("roster_items").
-			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
 		}
 
 		// fetch new roster version
-		ver, err = fetchRosterVer(ctx, username, tx)
+		ver, err = fetchRosterVer(ctx, username, domain, tx)
 		return err
 	})
 	if err != nil {
@@ -116,10 +116,10 @@ func (s *mySQLRoster) DeleteRosterItem(ctx context.Context, username, jid string
 	return ver, nil
 }
 
-func (s *mySQLRoster) FetchRosterItems(ctx context.Context, username string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+func (s *mySQLRoster) FetchRosterItems(ctx context.Context, username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver").
 		From("roster_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at DESC")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -132,18 +132,18 @@ func (s *mySQLRoster) FetchRosterItems(ctx context.Context, username string) ([]
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := fetchRosterVer(ctx, username, s.db)
+	ver, err := fetchRosterVer(ctx, username, domain, s.db)
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
 	return items, ver, nil
 }
 
-func (s *mySQLRoster) FetchRosterItemsInGroups(ctx context.Context, username string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("ris.username", "ris.jid", "ris.name", "ris.subscription", "ris.`groups`", "ris.ask", "ris.ver").
+func (s *mySQLRoster) FetchRosterItemsInGroups(ctx context.Context, username, domain string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("ris.username", "ris.domain", "ris.jid", "ris.name", "ris.subscription", "ris.`groups`", "ris.ask", "ris.ver").
 		From("roster_items ris").
-		LeftJoin("roster_groups g ON ris.username = g.username").
-		Where(sq.And{sq.Eq{"ris.username": username}, sq.Eq{"g.group": groups}}).
+		LeftJoin("roster_groups g ON ris.username = g.username AND ris.domain = g.domain").
+		Where(sq.And{sq.Eq{"ris.username": username}, sq.Eq{"ris.domain": domain}, sq.Eq{"g.group": groups}}).
 		OrderBy("ris.created_at DESC")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -156,17 +156,17 @@ func (s *mySQLRoster) FetchRosterItemsInGroups(ctx context.Context, username str
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := fetchRosterVer(ctx, username, s.db)
+	ver, err := fetchRosterVer(ctx, username, domain, s.db)
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
 	return items, ver, nil
 }
 
-func (s *mySQLRoster) FetchRosterItem(ctx context.Context, username, jid string) (*rostermodel.Item, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+func (s *mySQLRoster) FetchRosterItem(ctx context.Context, username, domain, jid string) (*rostermodel.Item, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver").
 		From("roster_items").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var ri rostermodel.Item
 	err := scanRosterItemEntity(&ri, q.RunWith(s.db).QueryRowContext(ctx))
@@ -183,17 +183,17 @@ func (s *mySQLRoster) FetchRosterItem(ctx context.Context, username, jid string)
 func (s *mySQLRoster) UpsertRosterNotification(ctx context.Context, rn *rostermodel.Notification) error {
 	presenceXML := rn.Presence.String()
 	q := sq.Insert("roster_notifications").
-		Columns("contact", "jid", "elements", "updated_at", "created_at").
-		Values(rn.Contact, rn.JID, presenceXML, nowExpr, nowExpr).
+		Columns("contact", "domain", "jid", "elements", "updated_at", "created_at").
+		Values(rn.Contact, rn.Domain, rn.JID, presenceXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE elements = ?, updated_at = NOW()", presenceXML)
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *mySQLRoster) FetchRosterNotifications(ctx context.Context, contact string) ([]rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *mySQLRoster) FetchRosterNotifications(ctx context.Context, contact, domain string) ([]rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.Eq{"contact": contact}).
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -213,10 +213,10 @@ func (s *mySQLRoster) FetchRosterNotifications(ctx context.Context, contact stri
 	return ret, nil
 }
 
-func (s *mySQLRoster) FetchRosterNotification(ctx context.Context, contact string, jid string) (*rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *mySQLRoster) FetchRosterNotification(ctx context.Context, contact, domain, jid string) (*rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var rn rostermodel.Notification
 	err := scanRosterNotificationEntity(&rn, q.RunWith(s.db).QueryRowContext(ctx))
@@ -230,16 +230,16 @@ func (s *mySQLRoster) FetchRosterNotification(ctx context.Context, contact strin
 	}
 }
 
-func (s *mySQLRoster) DeleteRosterNotification(ctx context.Context, contact, jid string) error {
-	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+func (s *mySQLRoster) DeleteRosterNotification(ctx context.Context, contact, domain, jid string) error {
+	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *mySQLRoster) FetchRosterGroups(ctx context.Context, username string) ([]string, error) {
+func (s *mySQLRoster) FetchRosterGroups(ctx context.Context, username, domain string) ([]string, error) {
 	q := sq.Select("`group`").
 		From("roster_groups").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		GroupBy("`group`")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -261,7 +261,7 @@ func (s *mySQLRoster) FetchRosterGroups(ctx context.Context, username string) ([
 
 func scanRosterNotificationEntity(rn *rostermodel.Notification, scanner rowScanner) error {
 	var presenceXML string
-	if err := scanner.Scan(&rn.Contact, &rn.JID, &presenceXML); err != nil {
+	if err := scanner.Scan(&rn.Contact, &rn.Domain, &rn.JID, &presenceXML); err != nil {
 		return err
 	}
 	parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
@@ -277,7 +277,7 @@ func scanRosterNotificationEntity(rn *rostermodel.Notification, scanner rowScann
 
 func scanRosterItemEntity(ri *rostermodel.Item, scanner rowScanner) error {
 	var groupsBytes string
-	if err := scanner.Scan(&ri.Username, &ri.JID, &ri.Name, &ri.Subscription, &groupsBytes, &ri.Ask, &ri.Ver); err != nil {
+	if err := scanner.Scan(&ri.Username, &ri.Domain, &ri.JID, &ri.Name, &ri.Subscription, &groupsBytes, &ri.Ask, &ri.Ver); err != nil {
 		return err
 	}
 	if len(groupsBytes) > 0 {
@@ -300,10 +300,10 @@ func scanRosterItemEntities(scanner rowsScanner) ([]rostermodel.Item, error) {
 	return ret, nil
 }
 
-func fetchRosterVer(ctx context.Context, username string, runner sq.BaseRunner) (rostermodel.Version, error) {
+func fetchRosterVer(ctx context.Context, username, domain string, runner sq.BaseRunner) (rostermodel.Version, error) {
 	q := sq.Select("IFNULL(MAX(ver), 0)", "IFNULL(MAX(last_deletion_ver), 0)").
 		From("roster_versions").
-		Where(sq.Eq{"username": username})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var ver rostermodel.Version
 	row := q.RunWith(runner).QueryRowContext(ctx)
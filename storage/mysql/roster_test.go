@@ -21,6 +21,7 @@ func TestMySQLStorageInsertRosterItem(t *testing.T) {
 	groups := []string{"Buddies", "Family"}
 	ri := rostermodel.Item{
 		Username:     "user",
+		Domain:       "jackal.im",
 		JID:          "contact@jid",
 		Name:         "a name",
 		Subscription: "both",
@@ -32,12 +33,14 @@ func TestMySQLStorageInsertRosterItem(t *testing.T) {
 	groupsBytes, _ := json.Marshal(groups)
 	args := []driver.Value{
 		ri.Username,
+		ri.Domain,
 		ri.JID,
 		ri.Name,
 		ri.Subscription,
 		groupsBytes,
 		ri.Ask,
 		ri.Username,
+		ri.Domain,
 		ri.Name,
 		ri.Subscription,
 		groupsBytes,
@@ -48,7 +51,7 @@ func TestMySQLStorageInsertRosterItem(t *testing.T) {
 	mock.ExpectBegin()
 
 	mock.ExpectExec("INSERT INTO roster_versions (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("user").
+		WithArgs("user", "jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectExec("INSERT INTO roster_items (.+) ON DUPLICATE KEY UPDATE (.+)").
@@ -56,19 +59,19 @@ func TestMySQLStorageInsertRosterItem(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectExec("DELETE FROM roster_groups (.+)").
-		WithArgs("user", "contact@jid").
+		WithArgs("user", "jackal.im", "contact@jid").
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	mock.ExpectExec("INSERT INTO roster_groups (.+)").
-		WithArgs("user", "contact@jid", "Buddies").
+		WithArgs("user", "jackal.im", "contact@jid", "Buddies").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectExec("INSERT INTO roster_groups (.+)").
-		WithArgs("user", "contact@jid", "Family").
+		WithArgs("user", "jackal.im", "contact@jid", "Family").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("user").
+		WithArgs("user", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
 
 	mock.ExpectCommit()
@@ -82,102 +85,102 @@ func TestMySQLStorageDeleteRosterItem(t *testing.T) {
 	s, mock := newRosterMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO roster_versions (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("user").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM roster_groups (.+)").
-		WithArgs("user", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM roster_items (.+)").
-		WithArgs("user", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("user").
+		WithArgs("user", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
 	mock.ExpectCommit()
 
-	_, err := s.DeleteRosterItem(context.Background(), "user", "contact")
+	_, err := s.DeleteRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO roster_versions (.+)").
-		WithArgs("user").WillReturnError(errMySQLStorage)
+		WithArgs("user", "jackal.im").WillReturnError(errMySQLStorage)
 	mock.ExpectRollback()
 
-	_, err = s.DeleteRosterItem(context.Background(), "user", "contact")
+	_, err = s.DeleteRosterItem(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLStorageFetchRosterItems(t *testing.T) {
-	var riColumns = []string{"user", "contact", "name", "subscription", "`groups`", "ask", "ver"}
+	var riColumns = []string{"user", "domain", "contact", "name", "subscription", "`groups`", "ask", "ver"}
 
 	s, mock := newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("sxmpp", "romeo", "Romeo", "both", "", false, 0))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("sxmpp", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(0, 0))
 
-	rosterItems, _, err := s.FetchRosterItems(context.Background(), "sxmpp")
+	rosterItems, _, err := s.FetchRosterItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rosterItems))
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, _, err = s.FetchRosterItems(context.Background(), "sxmpp")
+	_, _, err = s.FetchRosterItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp", "romeo").
-		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("sxmpp", "romeo", "Romeo", "both", "", false, 0))
+		WithArgs("sxmpp", "jackal.im", "romeo").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("sxmpp", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
 
-	_, err = s.FetchRosterItem(context.Background(), "sxmpp", "romeo")
+	_, err = s.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp", "romeo").
+		WithArgs("sxmpp", "jackal.im", "romeo").
 		WillReturnRows(sqlmock.NewRows(riColumns))
 
-	ri, _ := s.FetchRosterItem(context.Background(), "sxmpp", "romeo")
+	ri, _ := s.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, ri)
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp", "romeo").
+		WithArgs("sxmpp", "jackal.im", "romeo").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchRosterItem(context.Background(), "sxmpp", "romeo")
+	_, err = s.FetchRosterItem(context.Background(), "sxmpp", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, _, err = s.FetchRosterItems(context.Background(), "sxmpp")
+	_, _, err = s.FetchRosterItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
-	var riColumns2 = []string{"ris.user", "ris.contact", "ris.name", "ris.subscription", "ris.`groups`", "ris.ask", "ris.ver"}
+	var riColumns2 = []string{"ris.user", "ris.domain", "ris.contact", "ris.name", "ris.subscription", "ris.`groups`", "ris.ask", "ris.ver"}
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items ris LEFT JOIN roster_groups g ON ris.username = g.username (.+)").
-		WithArgs("sxmpp", "Family").
-		WillReturnRows(sqlmock.NewRows(riColumns2).AddRow("sxmpp", "romeo", "Romeo", "both", `["Family"]`, false, 0))
+		WithArgs("sxmpp", "jackal.im", "Family").
+		WillReturnRows(sqlmock.NewRows(riColumns2).AddRow("sxmpp", "jackal.im", "romeo", "Romeo", "both", `["Family"]`, false, 0))
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(0, 0))
 
-	_, _, err = s.FetchRosterItemsInGroups(context.Background(), "sxmpp", []string{"Family"})
+	_, _, err = s.FetchRosterItemsInGroups(context.Background(), "sxmpp", "jackal.im", []string{"Family"})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 }
@@ -185,6 +188,7 @@ func TestMySQLStorageFetchRosterItems(t *testing.T) {
 func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 	rn := rostermodel.Notification{
 		Contact:  "sxmpp",
+		Domain:   "jackal.im",
 		JID:      "romeo",
 		Presence: &xmpp.Presence{},
 	}
@@ -192,6 +196,7 @@ func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 
 	args := []driver.Value{
 		rn.Contact,
+		rn.Domain,
 		rn.JID,
 		presenceXML,
 		presenceXML,
@@ -218,72 +223,72 @@ func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 func TestMySQLStorageDeleteRosterNotification(t *testing.T) {
 	s, mock := newRosterMock()
 	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
-		WithArgs("user", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteRosterNotification(context.Background(), "user", "contact")
+	err := s.DeleteRosterNotification(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
-		WithArgs("user", "contact").WillReturnError(errMySQLStorage)
+		WithArgs("user", "jackal.im", "contact").WillReturnError(errMySQLStorage)
 
-	err = s.DeleteRosterNotification(context.Background(), "user", "contact")
+	err = s.DeleteRosterNotification(context.Background(), "user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLStorageFetchRosterNotifications(t *testing.T) {
-	var rnColumns = []string{"user", "contact", "elements"}
+	var rnColumns = []string{"user", "domain", "contact", "elements"}
 
 	s, mock := newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "contact", "<priority>8</priority>"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8</priority>"))
 
-	rosterNotifications, err := s.FetchRosterNotifications(context.Background(), "sxmpp")
+	rosterNotifications, err := s.FetchRosterNotifications(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rosterNotifications))
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(rnColumns))
 
-	rosterNotifications, err = s.FetchRosterNotifications(context.Background(), "sxmpp")
+	rosterNotifications, err = s.FetchRosterNotifications(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rosterNotifications))
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchRosterNotifications(context.Background(), "sxmpp")
+	_, err = s.FetchRosterNotifications(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
 	s, mock = newRosterMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "contact", "<priority>8"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8"))
 
-	_, err = s.FetchRosterNotifications(context.Background(), "sxmpp")
+	_, err = s.FetchRosterNotifications(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 }
 
 func TestMySQLStorageFetchRosterGroups(t *testing.T) {
 	s, mock := newRosterMock()
-	mock.ExpectQuery("SELECT `group` FROM roster_groups WHERE username = (.+) GROUP BY (.+)").
-		WithArgs("sxmpp").
+	mock.ExpectQuery("SELECT `group` FROM roster_groups WHERE \\(username = (.+) AND domain = (.+)\\) GROUP BY (.+)").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"group"}).
 			AddRow("Contacts").
 			AddRow("News"))
 
-	groups, err := s.FetchRosterGroups(context.Background(), "sxmpp")
+	groups, err := s.FetchRosterGroups(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
@@ -292,11 +297,11 @@ func TestMySQLStorageFetchRosterGroups(t *testing.T) {
 	require.Equal(t, "News", groups[1])
 
 	s, mock = newRosterMock()
-	mock.ExpectQuery("SELECT `group` FROM roster_groups WHERE username = (.+) GROUP BY (.+)").
-		WithArgs("sxmpp").
+	mock.ExpectQuery("SELECT `group` FROM roster_groups WHERE \\(username = (.+) AND domain = (.+)\\) GROUP BY (.+)").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	groups, err = s.FetchRosterGroups(context.Background(), "sxmpp")
+	groups, err = s.FetchRosterGroups(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 
 	require.Nil(t, groups)
@@ -23,11 +23,11 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 	to, _ := jid.NewWithString("sxmpp@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	user := model.User{Username: "sxmpp", Password: "1234", LastPresence: p}
+	user := model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234", LastPresence: p}
 
 	s, mock := newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", "1234", p.String(), "1234", p.String()).
+		WithArgs("sxmpp", "jackal.im", "1234", "", model.UserActive, "", nil, p.String(), "1234", "", model.UserActive, "", nil, p.String()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := s.UpsertUser(context.Background(), &user)
@@ -36,7 +36,7 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 
 	s, mock = newUserMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", "1234", p.String(), "1234", p.String()).
+		WithArgs("sxmpp", "jackal.im", "1234", "", model.UserActive, "", nil, p.String(), "1234", "", model.UserActive, "", nil, p.String()).
 		WillReturnError(errMocked)
 
 	err = s.UpsertUser(context.Background(), &user)
@@ -44,34 +44,67 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 	require.Equal(t, errMocked, err)
 }
 
+func TestMySQLStorageUpdateLastPresence(t *testing.T) {
+	from, _ := jid.NewWithString("sxmpp@jackal.im/Psi+", true)
+	to, _ := jid.NewWithString("sxmpp@jackal.im", true)
+	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
+
+	s, mock := newUserMock()
+	mock.ExpectExec("UPDATE users SET last_presence = \\?, last_presence_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE (.+)").
+		WithArgs(p.String(), "sxmpp", "jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.UpdateLastPresence(context.Background(), "sxmpp", "jackal.im", p)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newUserMock()
+	mock.ExpectExec("UPDATE users SET (.+)").
+		WithArgs(p.String(), "sxmpp", "jackal.im").
+		WillReturnError(errMocked)
+
+	err = s.UpdateLastPresence(context.Background(), "sxmpp", "jackal.im", p)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMocked, err)
+}
+
 func TestMySQLStorageDeleteUser(t *testing.T) {
 	s, mock := newUserMock()
 	mock.ExpectBegin()
-	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM roster_items (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM roster_versions (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM private_storage (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM vcards (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"offline_messages", "archive_messages", "archive_preferences", "push_registrations", "presences", "roster_groups", "roster_items", "roster_versions", "blocklist_items", "private_storage", "vcards", "roster_notifications"} {
+		mock.ExpectExec("DELETE FROM "+table+" (.+)").
+			WithArgs("sxmpp", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
+		WithArgs("sxmpp@jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE roster_versions (.+)").
+		WithArgs("sxmpp@jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"roster_groups", "roster_items", "pubsub_node_options", "pubsub_items", "pubsub_affiliations", "pubsub_subscriptions", "pubsub_nodes", "pubsub_affiliations", "pubsub_subscriptions", "muc_nick_reservations"} {
+		mock.ExpectExec("DELETE FROM " + table + " (.+)").
+			WithArgs("sxmpp@jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
 	mock.ExpectExec("DELETE FROM users (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("sxmpp", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+) FOR UPDATE").
+		WithArgs("%sxmpp@jackal.im%").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "config", "subject", "affiliations"}).
+			AddRow("room@conference.jackal.im", "{}", "", `{"sxmpp@jackal.im":"member","noelia@jackal.im":"owner"}`).
+			AddRow("other@conference.jackal.im", "{}", "", `{"nosxmpp@jackal.im":"member"}`))
+	mock.ExpectExec("UPDATE muc_rooms SET affiliations = (.+)").
+		WithArgs(`{"noelia@jackal.im":"owner"}`, "room@conference.jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	err := s.DeleteUser(context.Background(), "sxmpp")
+	err := s.DeleteUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newUserMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("sxmpp").WillReturnError(errMocked)
+		WithArgs("sxmpp", "jackal.im").WillReturnError(errMocked)
 	mock.ExpectRollback()
 
-	err = s.DeleteUser(context.Background(), "sxmpp")
+	err = s.DeleteUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMocked, err)
 }
@@ -81,29 +114,32 @@ func TestMySQLStorageFetchUser(t *testing.T) {
 	to, _ := jid.NewWithString("sxmpp@jackal.im", true)
 	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
 
-	var userColumns = []string{"username", "password", "last_presence", "last_presence_at"}
-
 	s, mock := newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(userColumns))
 
-	usr, _ := s.FetchUser(context.Background(), "sxmpp")
+	usr, _ := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, usr)
 
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("sxmpp", "1234", p.String(), time.Now()))
-	_, err := s.FetchUser(context.Background(), "sxmpp")
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("sxmpp", "jackal.im", "1234", p.String(), time.Now(), "sxmpp@jackal.im", model.UserBanned, "spam", nil, time.Now()))
+	usr, err := s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
+	require.Equal(t, "sxmpp@jackal.im", usr.Email)
+	require.Equal(t, model.UserBanned, usr.Status)
+	require.Equal(t, "spam", usr.StatusReason)
+	require.True(t, usr.ExpiresAt.IsZero())
+	require.True(t, usr.IsDisabled())
 
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("sxmpp").WillReturnError(errMocked)
-	_, err = s.FetchUser(context.Background(), "sxmpp")
+		WithArgs("sxmpp", "jackal.im").WillReturnError(errMocked)
+	_, err = s.FetchUser(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMocked, err)
 }
@@ -113,19 +149,19 @@ func TestMySQLStorageUserExists(t *testing.T) {
 
 	s, mock := newUserMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countCols).AddRow(1))
 
-	ok, err := s.UserExists(context.Background(), "sxmpp")
+	ok, err := s.UserExists(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.True(t, ok)
 
 	s, mock = newUserMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("romeo").
+		WithArgs("romeo", "jackal.im").
 		WillReturnError(errMocked)
-	_, err = s.UserExists(context.Background(), "romeo")
+	_, err = s.UserExists(context.Background(), "romeo", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMocked, err)
 }
@@ -137,3 +173,50 @@ func newUserMock() (*mySQLUser, sqlmock.Sqlmock) {
 		pool:         pool.NewBufferPool(),
 	}, sqlMock
 }
+
+func TestMySQLStorageFetchUsernames(t *testing.T) {
+	s, mock := newUserMock()
+	mock.ExpectQuery("SELECT username FROM users (.+)").
+		WithArgs("jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("noelia").AddRow("sxmpp"))
+
+	usernames, err := s.FetchUsernames(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, []string{"noelia", "sxmpp"}, usernames)
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT username FROM users (.+)").
+		WithArgs("jackal.im").
+		WillReturnError(errMocked)
+
+	_, err = s.FetchUsernames(context.Background(), "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMocked, err)
+}
+
+func TestMySQLStorageFetchExpiredUsers(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Hour)
+
+	s, mock := newUserMock()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE (.+)expires_at <= NOW()").
+		WithArgs(model.UserActive).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("sxmpp", "jackal.im", "1234", "", time.Now(), "", model.UserActive, "", expiresAt, time.Now()))
+
+	users, err := s.FetchExpiredUsers(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "sxmpp", users[0].Username)
+	require.True(t, expiresAt.Equal(users[0].ExpiresAt))
+	require.True(t, users[0].IsExpired())
+
+	s, mock = newUserMock()
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE (.+)expires_at <= NOW()").
+		WithArgs(model.UserActive).
+		WillReturnError(errMocked)
+
+	_, err = s.FetchExpiredUsers(context.Background())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMocked, err)
+}
@@ -25,11 +25,11 @@ func newVCard(db *sql.DB) *mySQLVCard {
 }
 
 // UpsertVCard inserts a new vCard element into storage, or updates it in case it's been previously inserted.
-func (s *mySQLVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username string) error {
+func (s *mySQLVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username, domain string) error {
 	rawXML := vCard.String()
 	q := sq.Insert("vcards").
-		Columns("username", "vcard", "updated_at", "created_at").
-		Values(username, rawXML, nowExpr, nowExpr).
+		Columns("username", "domain", "vcard", "updated_at", "created_at").
+		Values(username, domain, rawXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE vcard = ?, updated_at = NOW()", rawXML)
 
 	_, err := q.RunWith(s.db).ExecContext(ctx)
@@ -37,10 +37,10 @@ func (s *mySQLVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, usern
 }
 
 // FetchVCard retrieves from storage a vCard element associated to a given user.
-func (s *mySQLVCard) FetchVCard(ctx context.Context, username string) (xmpp.XElement, error) {
+func (s *mySQLVCard) FetchVCard(ctx context.Context, username, domain string) (xmpp.XElement, error) {
 	var vCard string
 
-	q := sq.Select("vcard").From("vcards").Where(sq.Eq{"username": username})
+	q := sq.Select("vcard").From("vcards").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&vCard)
 	switch err {
@@ -20,20 +20,20 @@ func TestMySQLStorageInsertVCard(t *testing.T) {
 
 	s, mock := newVCardMock()
 	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", rawXML, rawXML).
+		WithArgs("sxmpp", "jackal.im", rawXML, rawXML).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.UpsertVCard(context.Background(), vCard, "sxmpp")
+	err := s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.NotNil(t, vCard)
 
 	s, mock = newVCardMock()
 	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("sxmpp", rawXML, rawXML).
+		WithArgs("sxmpp", "jackal.im", rawXML, rawXML).
 		WillReturnError(errMySQLStorage)
 
-	err = s.UpsertVCard(context.Background(), vCard, "sxmpp")
+	err = s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -43,30 +43,30 @@ func TestMySQLStorageFetchVCard(t *testing.T) {
 
 	s, mock := newVCardMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(vCardColumns).AddRow("<vCard><FN>Miguel Ángel</FN></vCard>"))
 
-	vCard, err := s.FetchVCard(context.Background(), "sxmpp")
+	vCard, err := s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.NotNil(t, vCard)
 
 	s, mock = newVCardMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(vCardColumns))
 
-	vCard, err = s.FetchVCard(context.Background(), "sxmpp")
+	vCard, err = s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Nil(t, vCard)
 
 	s, mock = newVCardMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	vCard, _ = s.FetchVCard(context.Background(), "sxmpp")
+	vCard, _ = s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, vCard)
 }
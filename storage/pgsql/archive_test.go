@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertArchiveMessage(t *testing.T) {
+	m := testArchiveMessage()
+
+	s, mock := newArchiveMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("1234", "juliet", "jackal.im", "romeo@jackal.im/garden", "romeo@jackal.im", m.Message.String(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertArchiveMessage(context.Background(), m)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("1234", "juliet", "jackal.im", "romeo@jackal.im/garden", "romeo@jackal.im", m.Message.String(), sqlmock.AnyArg()).
+		WillReturnError(errGeneric)
+
+	err = s.InsertArchiveMessage(context.Background(), m)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchArchiveMessages(t *testing.T) {
+	m := testArchiveMessage()
+	columns := []string{"id", "peer", "data", "stamp"}
+
+	s, mock := newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE \\(username = \\? AND domain = \\? AND bare_peer = \\? AND serial > (.+)\\) ORDER BY serial LIMIT 2").
+		WithArgs("juliet", "jackal.im", "romeo@jackal.im", "1233", "juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("1234", "romeo@jackal.im/garden", m.Message.String(), m.Stamp))
+
+	messages, err := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im", After: "1233", Max: 2}, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, "1234", messages[0].ID)
+	require.Equal(t, "juliet", messages[0].Username)
+	require.Equal(t, "romeo@jackal.im/garden", messages[0].With)
+	require.Equal(t, m.Message.String(), messages[0].Message.String())
+
+	// last page
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE \\(username = \\? AND domain = \\?\\) ORDER BY serial DESC LIMIT 2").
+		WithArgs("juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("1235", "romeo@jackal.im/garden", m.Message.String(), m.Stamp).
+			AddRow("1234", "romeo@jackal.im/garden", m.Message.String(), m.Stamp))
+
+	messages, err = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{Last: true, Max: 2}, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "1234", messages[0].ID)
+	require.Equal(t, "1235", messages[1].ID)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnError(errGeneric)
+
+	_, err = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestCountArchiveMessages(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+
+	s, mock := newArchiveMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages WHERE \\(username = \\? AND domain = \\? AND peer = \\? AND stamp >= \\?\\)").
+		WithArgs("juliet", "jackal.im", "romeo@jackal.im/garden", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	cnt, err := s.CountArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im/garden", Start: start}, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 3, cnt)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnError(errGeneric)
+
+	_, err = s.CountArchiveMessages(context.Background(), &archivemodel.Query{}, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestArchiveMessageExists(t *testing.T) {
+	s, mock := newArchiveMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("1234", "juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	ok, err := s.ArchiveMessageExists(context.Background(), "1234", "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+func TestDeleteArchiveMessages(t *testing.T) {
+	s, mock := newArchiveMock()
+	mock.ExpectExec("DELETE FROM archive_messages WHERE stamp < \\?").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	err := s.DeleteArchiveMessages(context.Background(), time.Now())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectExec("DELETE FROM archive_messages (.+)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnError(errGeneric)
+
+	err = s.DeleteArchiveMessages(context.Background(), time.Now())
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestArchivePreferences(t *testing.T) {
+	prefs := &archivemodel.Preferences{Default: archivemodel.Roster, Always: []string{"romeo@jackal.im"}}
+
+	s, mock := newArchiveMock()
+	mock.ExpectExec("INSERT INTO archive_preferences (.+) ON CONFLICT \\(username, domain\\) DO UPDATE (.+)").
+		WithArgs("juliet", "jackal.im", "roster", `["romeo@jackal.im"]`, "null", "roster", `["romeo@jackal.im"]`, "null").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertArchivePreferences(context.Background(), prefs, "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_preferences (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"default_mode", "always_jids", "never_jids"}))
+
+	p, err := s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, p)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_preferences (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"default_mode", "always_jids", "never_jids"}).AddRow("roster", `["romeo@jackal.im"]`, "null"))
+
+	p, err = s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, prefs, p)
+
+	s, mock = newArchiveMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_preferences (.+)").
+		WithArgs("juliet", "jackal.im").
+		WillReturnError(errGeneric)
+
+	_, err = s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func testArchiveMessage() *archivemodel.Message {
+	from, _ := jid.NewWithString("romeo@jackal.im/garden", true)
+	to, _ := jid.NewWithString("juliet@jackal.im/balcony", true)
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Wherefore art thou Romeo?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	return &archivemodel.Message{
+		ID:       "1234",
+		Username: "juliet",
+		Domain:   "jackal.im",
+		With:     "romeo@jackal.im/garden",
+		Message:  msg,
+		Stamp:    time.Now(),
+	}
+}
+
+func newArchiveMock() (*pgSQLArchive, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &pgSQLArchive{
+		pgSQLStorage: s,
+	}, sqlMock
+}
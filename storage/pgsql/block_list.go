@@ -25,8 +25,8 @@ func newBlockList(db *sql.DB) *pgSQLBlockList {
 
 func (s *pgSQLBlockList) InsertBlockListItem(ctx context.Context, item *model.BlockListItem) error {
 	q := sq.Insert("blocklist_items").
-		Columns("username", "jid").
-		Values(item.Username, item.JID).
+		Columns("username", "domain", "jid").
+		Values(item.Username, item.Domain, item.JID).
 		RunWith(s.db)
 	_, err := q.ExecContext(ctx)
 	return err
@@ -34,16 +34,16 @@ func (s *pgSQLBlockList) InsertBlockListItem(ctx context.Context, item *model.Bl
 
 func (s *pgSQLBlockList) DeleteBlockListItem(ctx context.Context, item *model.BlockListItem) error {
 	q := sq.Delete("blocklist_items").
-		Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"jid": item.JID}}).
+		Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"domain": item.Domain}, sq.Eq{"jid": item.JID}}).
 		RunWith(s.db)
 	_, err := q.ExecContext(ctx)
 	return err
 }
 
-func (s *pgSQLBlockList) FetchBlockListItems(ctx context.Context, username string) ([]model.BlockListItem, error) {
-	q := sq.Select("username", "jid").
+func (s *pgSQLBlockList) FetchBlockListItems(ctx context.Context, username, domain string) ([]model.BlockListItem, error) {
+	q := sq.Select("username", "domain", "jid").
 		From("blocklist_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -60,7 +60,7 @@ func scanBlockListItemEntities(scanner rowsScanner) ([]model.BlockListItem, erro
 
 	for scanner.Next() {
 		var it model.BlockListItem
-		if err := scanner.Scan(&it.Username, &it.JID); err != nil {
+		if err := scanner.Scan(&it.Username, &it.Domain, &it.JID); err != nil {
 			return nil, err
 		}
 		ret = append(ret, it)
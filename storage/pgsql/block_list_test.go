@@ -21,7 +21,7 @@ func TestInsertValidBlockListItem(t *testing.T) {
 	mock.ExpectExec("INSERT INTO blocklist_items (.+)").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
@@ -38,24 +38,24 @@ func TestInsertDoubleBlockListItem(t *testing.T) {
 	mock.ExpectExec("INSERT INTO blocklist_items (.+)").
 		WillReturnError(errGeneric)
 
-	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, err)
 
-	err = s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err = s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Equal(t, errGeneric, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
 
 // Test fetching block list items
 func TestFetchBlockListItems(t *testing.T) {
-	var blockListColumns = []string{"username", "jid"}
+	var blockListColumns = []string{"username", "domain", "jid"}
 	s, mock := newBlockListMock()
 
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("sxmpp", "noelia@jackal.im"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("sxmpp", "jackal.im", "noelia@jackal.im"))
 
-	_, err := s.FetchBlockListItems(context.Background(), "sxmpp")
+	_, err := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
@@ -65,10 +65,10 @@ func TestFetchBlockListItemsError(t *testing.T) {
 	s, mock := newBlockListMock()
 
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errGeneric)
 
-	_, err := s.FetchBlockListItems(context.Background(), "sxmpp")
+	_, err := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
 	require.Equal(t, errGeneric, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
@@ -78,10 +78,10 @@ func TestDeleteBlockListItems(t *testing.T) {
 	s, mock := newBlockListMock()
 
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("sxmpp", "noelia@jackal.im").
+		WithArgs("sxmpp", "jackal.im", "noelia@jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Nil(t, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
@@ -91,10 +91,10 @@ func TestDeleteBlockListItemsError(t *testing.T) {
 	s, mock := newBlockListMock()
 
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("sxmpp", "noelia@jackal.im").
+		WithArgs("sxmpp", "jackal.im", "noelia@jackal.im").
 		WillReturnError(errGeneric)
 
-	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", JID: "noelia@jackal.im"})
+	err := s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "noelia@jackal.im"})
 	require.Equal(t, errGeneric, err)
 	require.Nil(t, mock.ExpectationsWereMet())
 }
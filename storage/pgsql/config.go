@@ -6,12 +6,14 @@ const defaultSSLMode = "disable"
 
 // Config represents PostgreSQL storage configuration.
 type Config struct {
-	Host     string `yaml:"host"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Database string `yaml:"database"`
-	PoolSize int    `yaml:"pool_size"`
-	SSLMode  string `yaml:"ssl_mode"`
+	Host         string `yaml:"host"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	Database     string `yaml:"database"`
+	PoolSize     int    `yaml:"pool_size"`
+	SSLMode      string `yaml:"ssl_mode"`
+	AutoMigrate  bool   `yaml:"auto_migrate"`
+	LegacyDomain string `yaml:"legacy_domain"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+// The following constructors expose PostgreSQL repositories operating over an already opened database handler,
+// so that storages sharing PostgreSQL dialect can reuse them.
+
+// NewUser returns a PostgreSQL dialect user repository.
+func NewUser(db *sql.DB) repository.User { return newUser(db) }
+
+// NewRoster returns a PostgreSQL dialect roster repository.
+func NewRoster(db *sql.DB) repository.Roster { return newRoster(db) }
+
+// NewPresences returns a PostgreSQL dialect presences repository.
+func NewPresences(db *sql.DB) repository.Presences { return newPresences(db) }
+
+// NewVCard returns a PostgreSQL dialect vCard repository.
+func NewVCard(db *sql.DB) repository.VCard { return newVCard(db) }
+
+// NewPrivate returns a PostgreSQL dialect private storage repository.
+func NewPrivate(db *sql.DB) repository.Private { return newPrivate(db) }
+
+// NewBlockList returns a PostgreSQL dialect block list repository.
+func NewBlockList(db *sql.DB) repository.BlockList { return newBlockList(db) }
+
+// NewPubSub returns a PostgreSQL dialect pubsub repository.
+func NewPubSub(db *sql.DB) repository.PubSub { return newPubSub(db) }
+
+// NewOffline returns a PostgreSQL dialect offline repository.
+func NewOffline(db *sql.DB) repository.Offline { return newOffline(db) }
+
+// NewArchive returns a PostgreSQL dialect message archive repository.
+func NewArchive(db *sql.DB) repository.Archive { return newArchive(db) }
+
+// NewPush returns a PostgreSQL dialect push registrations repository.
+func NewPush(db *sql.DB) repository.Push { return newPush(db) }
+
+// NewMuc returns a PostgreSQL dialect multi-user chat repository.
+func NewMuc(db *sql.DB) repository.Muc { return newMuc(db) }
+
+// NewHost returns a PostgreSQL dialect host repository.
+func NewHost(db *sql.DB) repository.Host { return newHost(db) }
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sxmpp/jackal/model"
+)
+
+type pgSQLHost struct {
+	*pgSQLStorage
+}
+
+func newHost(db *sql.DB) *pgSQLHost {
+	return &pgSQLHost{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+// UpsertHost inserts a new host entity into storage, or updates it in case it's been previously inserted.
+func (s *pgSQLHost) UpsertHost(ctx context.Context, host *model.Host) error {
+	cert := string(host.Certificate)
+	key := string(host.PrivateKey)
+	q := sq.Insert("hosts").
+		Columns("name", "certificate", "private_key", "enabled").
+		Values(host.Name, cert, key, host.Enabled).
+		Suffix("ON CONFLICT (name) DO UPDATE SET certificate = $2, private_key = $3, enabled = $4")
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// DeleteHost deletes a host entity from storage.
+func (s *pgSQLHost) DeleteHost(ctx context.Context, name string) error {
+	_, err := sq.Delete("hosts").
+		Where(sq.Eq{"name": name}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// FetchHost retrieves from storage a host entity.
+func (s *pgSQLHost) FetchHost(ctx context.Context, name string) (*model.Host, error) {
+	q := sq.Select("name", "certificate", "private_key", "enabled").
+		From("hosts").
+		Where(sq.Eq{"name": name})
+
+	var h model.Host
+	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&h.Name, &h.Certificate, &h.PrivateKey, &h.Enabled)
+	switch err {
+	case nil:
+		return &h, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchHosts retrieves from storage all host entities.
+func (s *pgSQLHost) FetchHosts(ctx context.Context) ([]model.Host, error) {
+	q := sq.Select("name", "certificate", "private_key", "enabled").
+		From("hosts").
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var hosts []model.Host
+	for rows.Next() {
+		var h model.Host
+		if err := rows.Scan(&h.Name, &h.Certificate, &h.PrivateKey, &h.Enabled); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
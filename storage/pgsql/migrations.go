@@ -0,0 +1,515 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sxmpp/jackal/storage/migration"
+)
+
+// domainKeyedTables contains every table keyed by domain in migration 2.
+var domainKeyedTables = []string{
+	"users",
+	"roster_notifications",
+	"roster_items",
+	"roster_groups",
+	"roster_versions",
+	"blocklist_items",
+	"private_storage",
+	"vcards",
+	"offline_messages",
+}
+
+// migrations returns every PostgreSQL schema migration, ordered by version.
+// New schema changes must be appended as a new migration, never by modifying an already released one.
+//
+// legacyDomain is assigned to every row stored prior to keying the schema by domain.
+func migrations(legacyDomain string) []migration.Migration {
+	return []migration.Migration{
+		{
+			Version:     1,
+			Description: "initial schema",
+			Baseline:    "SELECT username FROM users WHERE 1 = 0",
+			Up: []string{
+				`CREATE OR REPLACE FUNCTION enable_updated_at(_tbl regclass) RETURNS VOID AS $$
+BEGIN
+    EXECUTE format('CREATE TRIGGER set_updated_at BEFORE UPDATE ON %s
+                    FOR EACH ROW EXECUTE PROCEDURE set_updated_at()', _tbl);
+END;
+$$ LANGUAGE plpgsql`,
+				`CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    IF (
+        NEW IS DISTINCT FROM OLD AND
+        NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at
+    ) THEN
+        NEW.updated_at := current_timestamp;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`,
+				`CREATE TABLE IF NOT EXISTS users (
+    username            VARCHAR(1023) PRIMARY KEY,
+    password            TEXT NOT NULL,
+    last_presence       TEXT NOT NULL,
+    last_presence_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`SELECT enable_updated_at('users')`,
+				`CREATE TABLE IF NOT EXISTS presences (
+    username      VARCHAR(1023) NOT NULL,
+    domain        VARCHAR(1023) NOT NULL,
+    resource      VARCHAR(1023) NOT NULL,
+    presence      TEXT NOT NULL,
+    node          VARCHAR(1023) NOT NULL,
+    ver           VARCHAR(1023) NOT NULL,
+    allocation_id VARCHAR(1023) NOT NULL,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, domain, resource)
+)`,
+				`SELECT enable_updated_at('presences')`,
+				`CREATE INDEX IF NOT EXISTS i_presences_username_domain ON presences(username, domain)`,
+				`CREATE INDEX IF NOT EXISTS i_presences_domain_resource ON presences(domain, resource)`,
+				`CREATE INDEX IF NOT EXISTS i_presences_allocation_id ON presences(allocation_id)`,
+				`CREATE TABLE IF NOT EXISTS capabilities (
+    node       VARCHAR(1023) NOT NULL,
+    ver        VARCHAR(1023) NOT NULL,
+    features   TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (node, ver)
+)`,
+				`SELECT enable_updated_at('capabilities')`,
+				`CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact     VARCHAR(1023) NOT NULL,
+    jid         TEXT NOT NULL,
+    elements    TEXT NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (contact, jid)
+)`,
+				`SELECT enable_updated_at('roster_notifications')`,
+				`CREATE TABLE IF NOT EXISTS roster_items (
+    username        VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    subscription    TEXT NOT NULL,
+    groups          TEXT NOT NULL,
+    ask BOOL        NOT NULL,
+    ver             INT NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, jid)
+)`,
+				`SELECT enable_updated_at('roster_items')`,
+				`CREATE TABLE IF NOT EXISTS roster_groups (
+    username     VARCHAR(1023) NOT NULL,
+    jid          TEXT NOT NULL,
+    "group"      TEXT NOT NULL,
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, jid)
+)`,
+				`SELECT enable_updated_at('roster_groups')`,
+				`CREATE TABLE IF NOT EXISTS roster_versions (
+    username            VARCHAR(1023) NOT NULL,
+    ver                 INT NOT NULL DEFAULT 0,
+    last_deletion_ver   INT NOT NULL DEFAULT 0,
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username)
+)`,
+				`SELECT enable_updated_at('roster_versions')`,
+				`CREATE TABLE IF NOT EXISTS blocklist_items (
+    username        VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(username, jid)
+)`,
+				`CREATE TABLE IF NOT EXISTS private_storage (
+    username        VARCHAR(1023) NOT NULL,
+    namespace       VARCHAR(512) NOT NULL,
+    data            TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, namespace)
+)`,
+				`SELECT enable_updated_at('private_storage')`,
+				`CREATE TABLE IF NOT EXISTS vcards (
+    username        VARCHAR(1023) PRIMARY KEY,
+    vcard           TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`SELECT enable_updated_at('vcards')`,
+				`CREATE TABLE IF NOT EXISTS offline_messages (
+    username        VARCHAR(1023) NOT NULL,
+    data            TEXT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_offline_messages_username ON offline_messages(username)`,
+				`CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    id              BIGSERIAL,
+    host            TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (id)
+)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_nodes_host_name ON pubsub_nodes(host, name)`,
+				`SELECT enable_updated_at('pubsub_nodes')`,
+				`CREATE TABLE IF NOT EXISTS pubsub_node_options (
+    node_id         BIGINT NOT NULL,
+    name            TEXT NOT NULL,
+    value           TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_pubsub_node_options_node_id ON pubsub_node_options(node_id)`,
+				`SELECT enable_updated_at('pubsub_node_options')`,
+				`CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    node_id          BIGINT NOT NULL,
+    jid              TEXT NOT NULL,
+    affiliation      TEXT NOT NULL,
+    updated_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_pubsub_affiliations_jid ON pubsub_affiliations(jid)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_affiliations_node_id_jid ON pubsub_affiliations(node_id, jid)`,
+				`SELECT enable_updated_at('pubsub_affiliations')`,
+				`CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    node_id          BIGINT NOT NULL,
+    subid            TEXT NOT NULL,
+    jid              TEXT NOT NULL,
+    subscription     TEXT NOT NULL,
+    updated_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_pubsub_subscriptions_jid ON pubsub_subscriptions(jid)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_subscriptions_node_id_jid ON pubsub_subscriptions(node_id, jid)`,
+				`SELECT enable_updated_at('pubsub_subscriptions')`,
+				`CREATE TABLE IF NOT EXISTS pubsub_items (
+    node_id          BIGINT NOT NULL,
+    item_id          TEXT NOT NULL,
+    payload          TEXT NOT NULL,
+    publisher        TEXT NOT NULL,
+    updated_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_pubsub_items_item_id ON pubsub_items(item_id)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_items_node_id_item_id ON pubsub_items(node_id, item_id)`,
+				`SELECT enable_updated_at('pubsub_items')`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS pubsub_items`,
+				`DROP TABLE IF EXISTS pubsub_subscriptions`,
+				`DROP TABLE IF EXISTS pubsub_affiliations`,
+				`DROP TABLE IF EXISTS pubsub_node_options`,
+				`DROP TABLE IF EXISTS pubsub_nodes`,
+				`DROP TABLE IF EXISTS offline_messages`,
+				`DROP TABLE IF EXISTS vcards`,
+				`DROP TABLE IF EXISTS private_storage`,
+				`DROP TABLE IF EXISTS blocklist_items`,
+				`DROP TABLE IF EXISTS roster_versions`,
+				`DROP TABLE IF EXISTS roster_groups`,
+				`DROP TABLE IF EXISTS roster_items`,
+				`DROP TABLE IF EXISTS roster_notifications`,
+				`DROP TABLE IF EXISTS capabilities`,
+				`DROP TABLE IF EXISTS presences`,
+				`DROP TABLE IF EXISTS users`,
+				`DROP FUNCTION IF EXISTS set_updated_at()`,
+				`DROP FUNCTION IF EXISTS enable_updated_at(regclass)`,
+			},
+		},
+		{
+			Version:     2,
+			Description: "domain keyed user data",
+			Baseline:    "SELECT domain FROM users WHERE 1 = 0",
+			Up: []string{
+				`ALTER TABLE users ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE users ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE users DROP CONSTRAINT users_pkey`,
+				`ALTER TABLE users ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE roster_notifications ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE roster_notifications ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE roster_notifications DROP CONSTRAINT roster_notifications_pkey`,
+				`ALTER TABLE roster_notifications ADD PRIMARY KEY (contact, domain, jid)`,
+				`ALTER TABLE roster_items ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE roster_items ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE roster_items DROP CONSTRAINT roster_items_pkey`,
+				`ALTER TABLE roster_items ADD PRIMARY KEY (username, domain, jid)`,
+				`ALTER TABLE roster_groups ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE roster_groups ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE roster_groups DROP CONSTRAINT roster_groups_pkey`,
+				`ALTER TABLE roster_groups ADD PRIMARY KEY (username, domain, jid, "group")`,
+				`ALTER TABLE roster_versions ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE roster_versions ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE roster_versions DROP CONSTRAINT roster_versions_pkey`,
+				`ALTER TABLE roster_versions ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE blocklist_items ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE blocklist_items ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE blocklist_items DROP CONSTRAINT blocklist_items_pkey`,
+				`ALTER TABLE blocklist_items ADD PRIMARY KEY (username, domain, jid)`,
+				`ALTER TABLE private_storage ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE private_storage ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE private_storage DROP CONSTRAINT private_storage_pkey`,
+				`ALTER TABLE private_storage ADD PRIMARY KEY (username, domain, namespace)`,
+				`ALTER TABLE vcards ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE vcards ALTER COLUMN domain DROP DEFAULT`,
+				`ALTER TABLE vcards DROP CONSTRAINT vcards_pkey`,
+				`ALTER TABLE vcards ADD PRIMARY KEY (username, domain)`,
+				`ALTER TABLE offline_messages ADD COLUMN domain VARCHAR(1023) NOT NULL DEFAULT ''`,
+				`ALTER TABLE offline_messages ALTER COLUMN domain DROP DEFAULT`,
+				`DROP INDEX IF EXISTS i_offline_messages_username`,
+				`CREATE INDEX IF NOT EXISTS i_offline_messages_username_domain ON offline_messages(username, domain)`,
+			},
+			Exec: assignLegacyDomain(legacyDomain),
+			Down: []string{
+				`DROP INDEX IF EXISTS i_offline_messages_username_domain`,
+				`ALTER TABLE offline_messages DROP COLUMN domain`,
+				`CREATE INDEX IF NOT EXISTS i_offline_messages_username ON offline_messages(username)`,
+				`ALTER TABLE vcards
+    DROP CONSTRAINT vcards_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+				`ALTER TABLE private_storage
+    DROP CONSTRAINT private_storage_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, namespace)`,
+				`ALTER TABLE blocklist_items
+    DROP CONSTRAINT blocklist_items_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, jid)`,
+				`ALTER TABLE roster_versions
+    DROP CONSTRAINT roster_versions_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+				`ALTER TABLE roster_groups
+    DROP CONSTRAINT roster_groups_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, jid)`,
+				`ALTER TABLE roster_items
+    DROP CONSTRAINT roster_items_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username, jid)`,
+				`ALTER TABLE roster_notifications
+    DROP CONSTRAINT roster_notifications_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (contact, jid)`,
+				`ALTER TABLE users
+    DROP CONSTRAINT users_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (username)`,
+			},
+		},
+		{
+			Version:     3,
+			Description: "virtual hosts",
+			Baseline:    "SELECT name FROM hosts WHERE 1 = 0",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS hosts (
+    name             VARCHAR(1023) PRIMARY KEY,
+    certificate      TEXT NOT NULL,
+    private_key      TEXT NOT NULL,
+    enabled          BOOLEAN NOT NULL,
+    updated_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`SELECT enable_updated_at('hosts')`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS hosts`,
+			},
+		},
+		{
+			Version:     4,
+			Description: "user account status",
+			Up: []string{
+				`ALTER TABLE users
+    ADD COLUMN email         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN status        VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN expires_at    TIMESTAMP WITH TIME ZONE`,
+				`CREATE INDEX IF NOT EXISTS i_users_status_expires_at ON users(status, expires_at)`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS i_users_status_expires_at`,
+				`ALTER TABLE users
+    DROP COLUMN expires_at,
+    DROP COLUMN status_reason,
+    DROP COLUMN status,
+    DROP COLUMN email`,
+			},
+		},
+		{
+			Version:     5,
+			Description: "message archive",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS archive_messages (
+    serial          BIGSERIAL PRIMARY KEY,
+    id              VARCHAR(64) NOT NULL,
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    peer            TEXT NOT NULL,
+    bare_peer       TEXT NOT NULL,
+    data            TEXT NOT NULL,
+    stamp           TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`,
+				`CREATE INDEX IF NOT EXISTS i_archive_messages_username_domain_serial ON archive_messages(username, domain, serial)`,
+				`CREATE INDEX IF NOT EXISTS i_archive_messages_username_domain_stamp ON archive_messages(username, domain, stamp)`,
+				`CREATE INDEX IF NOT EXISTS i_archive_messages_id ON archive_messages(id)`,
+				`CREATE INDEX IF NOT EXISTS i_archive_messages_stamp ON archive_messages(stamp)`,
+				`CREATE TABLE IF NOT EXISTS archive_preferences (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    default_mode    VARCHAR(16) NOT NULL,
+    always_jids     TEXT NOT NULL,
+    never_jids      TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, domain)
+)`,
+				`SELECT enable_updated_at('archive_preferences')`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS archive_preferences`,
+				`DROP TABLE IF EXISTS archive_messages`,
+			},
+		},
+		{
+			Version:     6,
+			Description: "push notifications registrations",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS push_registrations (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    node            VARCHAR(1023) NOT NULL,
+    options         TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (username, domain, jid, node)
+)`,
+				`SELECT enable_updated_at('push_registrations')`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS push_registrations`,
+			},
+		},
+		{
+			Version:     7,
+			Description: "offline message identifiers",
+			Up: []string{
+				`ALTER TABLE offline_messages
+    ADD COLUMN id VARCHAR(64) NOT NULL DEFAULT ''`,
+				`UPDATE offline_messages SET id = md5(random()::text || clock_timestamp()::text) WHERE id = ''`,
+				`CREATE INDEX IF NOT EXISTS i_offline_messages_username_domain_id ON offline_messages(username, domain, id)`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS i_offline_messages_username_domain_id`,
+				`ALTER TABLE offline_messages
+    DROP COLUMN id`,
+			},
+		},
+		{
+			Version:     8,
+			Description: "offline message expiration and size",
+			Up: []string{
+				`ALTER TABLE offline_messages
+    ADD COLUMN size          INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN expires_at    TIMESTAMP WITH TIME ZONE`,
+				`UPDATE offline_messages SET size = OCTET_LENGTH(data)`,
+				`CREATE INDEX IF NOT EXISTS i_offline_messages_expires_at ON offline_messages(expires_at)`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS i_offline_messages_expires_at`,
+				`ALTER TABLE offline_messages
+    DROP COLUMN expires_at,
+    DROP COLUMN size`,
+			},
+		},
+		{
+			Version:     9,
+			Description: "multi-user chat rooms and nick reservations",
+			Up: []string{
+				`CREATE TABLE IF NOT EXISTS muc_rooms (
+    jid             TEXT NOT NULL,
+    service         VARCHAR(1023) NOT NULL,
+    config          TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    affiliations    TEXT NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (jid)
+)`,
+				`CREATE INDEX IF NOT EXISTS i_muc_rooms_service ON muc_rooms(service)`,
+				`SELECT enable_updated_at('muc_rooms')`,
+				`CREATE TABLE IF NOT EXISTS muc_nick_reservations (
+    room_jid        TEXT NOT NULL,
+    jid             TEXT NOT NULL,
+    nick            VARCHAR(1023) NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (room_jid, jid)
+)`,
+				`SELECT enable_updated_at('muc_nick_reservations')`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS muc_nick_reservations`,
+				`DROP TABLE IF EXISTS muc_rooms`,
+			},
+		},
+	}
+}
+
+// assignLegacyDomain returns a migration step assigning legacyDomain to every row stored prior to keying the schema by domain.
+func assignLegacyDomain(legacyDomain string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		for _, table := range domainKeyedTables {
+			if len(legacyDomain) > 0 {
+				_, err := sq.Update(table).
+					Set("domain", legacyDomain).
+					PlaceholderFormat(sq.Dollar).
+					RunWith(tx).ExecContext(ctx)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			var count int
+			err := sq.Select("COUNT(*)").
+				From(table).
+				RunWith(tx).QueryRowContext(ctx).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("pgsql: legacy_domain must be set in order to upgrade existing %s rows", table)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Released(t *testing.T) {
+	// released migrations must never be renumbered nor modified
+	released := []string{
+		"initial schema",
+		"domain keyed user data",
+		"virtual hosts",
+		"user account status",
+		"message archive",
+		"push notifications registrations",
+		"offline message identifiers",
+		"offline message expiration and size",
+		"multi-user chat rooms and nick reservations",
+	}
+	ms := migrations("jackal.im")
+	require.True(t, len(ms) >= len(released))
+
+	for i, desc := range released {
+		require.Equal(t, i+1, ms[i].Version)
+		require.Equal(t, desc, ms[i].Description)
+	}
+	for _, mig := range ms {
+		require.True(t, len(mig.UpGuards) == 0 || len(mig.UpGuards) == len(mig.Up))
+	}
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+)
+
+type pgSQLMuc struct {
+	*pgSQLStorage
+}
+
+func newMuc(db *sql.DB) *pgSQLMuc {
+	return &pgSQLMuc{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+// UpsertMucRoom inserts a new room into storage, or updates it in case it's been previously inserted.
+func (s *pgSQLMuc) UpsertMucRoom(ctx context.Context, room *mucmodel.Room) error {
+	configJSON, affiliationsJSON, err := marshalMucRoom(room)
+	if err != nil {
+		return err
+	}
+	q := sq.Insert("muc_rooms").
+		Columns("jid", "service", "config", "subject", "affiliations").
+		Values(room.JID, room.Service(), configJSON, room.Subject, affiliationsJSON).
+		Suffix("ON CONFLICT (jid) DO UPDATE SET config = ?, subject = ?, affiliations = ?", configJSON, room.Subject, affiliationsJSON)
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// DeleteMucRoom deletes a room from storage, along with all its nick reservations.
+func (s *pgSQLMuc) DeleteMucRoom(ctx context.Context, roomJID string) error {
+	return s.inTransaction(ctx, func(tx *sql.Tx) error {
+		if _, err := sq.Delete("muc_nick_reservations").Where(sq.Eq{"room_jid": roomJID}).RunWith(tx).ExecContext(ctx); err != nil {
+			return err
+		}
+		_, err := sq.Delete("muc_rooms").Where(sq.Eq{"jid": roomJID}).RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+// FetchMucRoom retrieves from storage a room entity.
+func (s *pgSQLMuc) FetchMucRoom(ctx context.Context, roomJID string) (*mucmodel.Room, error) {
+	q := sq.Select("jid", "config", "subject", "affiliations").
+		From("muc_rooms").
+		Where(sq.Eq{"jid": roomJID})
+
+	room, err := scanMucRoom(q.RunWith(s.db).QueryRowContext(ctx))
+	switch err {
+	case nil:
+		return room, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchMucRooms retrieves from storage all rooms hosted by a multi-user chat service.
+func (s *pgSQLMuc) FetchMucRooms(ctx context.Context, service string) ([]mucmodel.Room, error) {
+	q := sq.Select("jid", "config", "subject", "affiliations").
+		From("muc_rooms").
+		Where(sq.Eq{"service": service}).
+		OrderBy("jid")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rooms []mucmodel.Room
+	for rows.Next() {
+		room, err := scanMucRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// UpsertMucNickReservation inserts a new nick reservation into storage,
+// or updates it in case user already reserved a nick within the room.
+func (s *pgSQLMuc) UpsertMucNickReservation(ctx context.Context, reservation *mucmodel.NickReservation) error {
+	q := sq.Insert("muc_nick_reservations").
+		Columns("room_jid", "jid", "nick").
+		Values(reservation.RoomJID, reservation.JID, reservation.Nick).
+		Suffix("ON CONFLICT (room_jid, jid) DO UPDATE SET nick = ?", reservation.Nick)
+
+	_, err := q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// DeleteMucNickReservation deletes a user nick reservation from storage.
+func (s *pgSQLMuc) DeleteMucNickReservation(ctx context.Context, roomJID, jid string) error {
+	_, err := sq.Delete("muc_nick_reservations").
+		Where(sq.And{sq.Eq{"room_jid": roomJID}, sq.Eq{"jid": jid}}).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// FetchMucNickReservations retrieves from storage all nick reservations associated to a room.
+func (s *pgSQLMuc) FetchMucNickReservations(ctx context.Context, roomJID string) ([]mucmodel.NickReservation, error) {
+	q := sq.Select("room_jid", "jid", "nick").
+		From("muc_nick_reservations").
+		Where(sq.Eq{"room_jid": roomJID}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var reservations []mucmodel.NickReservation
+	for rows.Next() {
+		var r mucmodel.NickReservation
+		if err := rows.Scan(&r.RoomJID, &r.JID, &r.Nick); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// deleteMucAffiliations strips a user from every room affiliation list.
+//
+// Rooms are not locked, since SQLite shares this implementation, so every affiliation list
+// is replaced only if it wasn't concurrently modified, being reloaded otherwise.
+func deleteMucAffiliations(ctx context.Context, tx *sql.Tx, userJID string) error {
+	rows, err := sq.Select("jid", "affiliations").
+		From("muc_rooms").
+		Where(sq.Expr("affiliations LIKE ?", "%"+userJID+"%")).
+		RunWith(tx).QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	candidates := make(map[string]string)
+	for rows.Next() {
+		var roomJID, affiliationsJSON string
+		if err := rows.Scan(&roomJID, &affiliationsJSON); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		candidates[roomJID] = affiliationsJSON
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+	_ = rows.Close()
+
+	for roomJID, affiliationsJSON := range candidates {
+		if err := deleteMucAffiliation(ctx, tx, roomJID, affiliationsJSON, userJID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteMucAffiliation(ctx context.Context, tx *sql.Tx, roomJID, affiliationsJSON, userJID string) error {
+	for {
+		var affiliations map[string]string
+		if err := json.Unmarshal([]byte(affiliationsJSON), &affiliations); err != nil {
+			return err
+		}
+		if _, ok := affiliations[userJID]; !ok {
+			return nil
+		}
+		delete(affiliations, userJID)
+		b, err := json.Marshal(affiliations)
+		if err != nil {
+			return err
+		}
+		res, err := sq.Update("muc_rooms").
+			Set("affiliations", string(b)).
+			Where(sq.And{sq.Eq{"jid": roomJID}, sq.Eq{"affiliations": affiliationsJSON}}).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+		// room has been concurrently modified
+		err = sq.Select("affiliations").
+			From("muc_rooms").
+			Where(sq.Eq{"jid": roomJID}).
+			RunWith(tx).QueryRowContext(ctx).Scan(&affiliationsJSON)
+		switch err {
+		case nil:
+			continue
+		case sql.ErrNoRows:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+func marshalMucRoom(room *mucmodel.Room) (configJSON, affiliationsJSON string, err error) {
+	configBytes, err := json.Marshal(&room.Config)
+	if err != nil {
+		return "", "", err
+	}
+	affiliationsBytes, err := json.Marshal(room.Affiliations)
+	if err != nil {
+		return "", "", err
+	}
+	return string(configBytes), string(affiliationsBytes), nil
+}
+
+func scanMucRoom(scanner rowScanner) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	var configJSON, affiliationsJSON string
+	if err := scanner.Scan(&room.JID, &configJSON, &room.Subject, &affiliationsJSON); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(configJSON), &room.Config); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(affiliationsJSON), &room.Affiliations); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
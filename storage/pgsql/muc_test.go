@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertMucRoom(t *testing.T) {
+	room := testMucRoom()
+	configJSON := testMucRoomConfigJSON(room)
+
+	s, mock := newMucMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON CONFLICT (.+) DO UPDATE SET (.+)").
+		WithArgs("lobby@muc.jackal.im", "muc.jackal.im", configJSON, "Welcome!", `{"ortuman@jackal.im":"owner"}`, configJSON, "Welcome!", `{"ortuman@jackal.im":"owner"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertMucRoom(context.Background(), room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON CONFLICT (.+) DO UPDATE SET (.+)").
+		WillReturnError(errGeneric)
+
+	err = s.UpsertMucRoom(context.Background(), room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestDeleteMucRoom(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_nick_reservations WHERE room_jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM muc_rooms WHERE jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newMucMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_nick_reservations WHERE room_jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnError(errGeneric)
+	mock.ExpectRollback()
+
+	err = s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchMucRoom(t *testing.T) {
+	room := testMucRoom()
+	columns := []string{"jid", "config", "subject", "affiliations"}
+
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("lobby@muc.jackal.im", testMucRoomConfigJSON(room), "Welcome!", `{"ortuman@jackal.im":"owner"}`))
+
+	r, err := s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, room, r)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	r, err = s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, r)
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE jid = \\?").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnError(errGeneric)
+
+	r, err = s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+	require.Nil(t, r)
+}
+
+func TestFetchMucRooms(t *testing.T) {
+	room := testMucRoom()
+	columns := []string{"jid", "config", "subject", "affiliations"}
+
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms WHERE service = \\? ORDER BY jid").
+		WithArgs("muc.jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("lobby@muc.jackal.im", testMucRoomConfigJSON(room), "Welcome!", `{"ortuman@jackal.im":"owner"}`))
+
+	rooms, err := s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, rooms, 1)
+	require.Equal(t, *room, rooms[0])
+
+	s, mock = newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("muc.jackal.im").
+		WillReturnError(errGeneric)
+
+	rooms, err = s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+	require.Nil(t, rooms)
+}
+
+func TestUpsertMucNickReservation(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectExec("INSERT INTO muc_nick_reservations (.+) ON CONFLICT (.+) DO UPDATE SET (.+)").
+		WithArgs("lobby@muc.jackal.im", "ortuman@jackal.im", "ortuman", "ortuman").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+}
+
+func TestDeleteMucNickReservation(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectExec("DELETE FROM muc_nick_reservations WHERE \\(room_jid = \\? AND jid = \\?\\)").
+		WithArgs("lobby@muc.jackal.im", "ortuman@jackal.im").
+		WillReturnError(errGeneric)
+
+	err := s.DeleteMucNickReservation(context.Background(), "lobby@muc.jackal.im", "ortuman@jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchMucNickReservations(t *testing.T) {
+	s, mock := newMucMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_nick_reservations WHERE room_jid = \\? ORDER BY created_at").
+		WithArgs("lobby@muc.jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"room_jid", "jid", "nick"}).AddRow("lobby@muc.jackal.im", "ortuman@jackal.im", "ortuman"))
+
+	reservations, err := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, []mucmodel.NickReservation{{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}}, reservations)
+}
+
+func testMucRoom() *mucmodel.Room {
+	return &mucmodel.Room{
+		JID:          "lobby@muc.jackal.im",
+		Config:       mucmodel.RoomConfig{Name: "Lobby", Persistent: true, HistoryLength: 20},
+		Subject:      "Welcome!",
+		Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.Owner},
+	}
+}
+
+func testMucRoomConfigJSON(room *mucmodel.Room) string {
+	b, _ := json.Marshal(&room.Config)
+	return string(b)
+}
+
+func newMucMock() (*pgSQLMuc, sqlmock.Sqlmock) {
+	s, sqlMock := newStorageMock()
+	return &pgSQLMuc{
+		pgSQLStorage: s,
+	}, sqlMock
+}
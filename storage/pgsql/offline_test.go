@@ -8,8 +8,10 @@ package pgsql
 import (
 	"context"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 	"github.com/sxmpp/jackal/util/pool"
 	"github.com/sxmpp/jackal/xmpp"
 	"github.com/sxmpp/jackal/xmpp/jid"
@@ -24,22 +26,23 @@ func TestInsertOfflineMessages(t *testing.T) {
 	message.AppendElement(xmpp.NewElementName("body"))
 	m, _ := xmpp.NewMessageFromElement(message, j, j)
 	messageXML := m.String()
+	msg := &offlinemodel.Message{ID: "m1", Message: m}
 
 	s, mock := newOfflineMock()
 	mock.ExpectExec("INSERT INTO offline_messages (.+)").
-		WithArgs("sxmpp", messageXML).
+		WithArgs("m1", "sxmpp", "jackal.im", messageXML, len(messageXML), nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.InsertOfflineMessage(context.Background(), m, "sxmpp")
+	err := s.InsertOfflineMessage(context.Background(), msg, "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newOfflineMock()
 	mock.ExpectExec("INSERT INTO offline_messages (.+)").
-		WithArgs("sxmpp", messageXML).
+		WithArgs("m1", "sxmpp", "jackal.im", messageXML, len(messageXML), nil).
 		WillReturnError(errGeneric)
 
-	err = s.InsertOfflineMessage(context.Background(), m, "sxmpp")
+	err = s.InsertOfflineMessage(context.Background(), msg, "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 }
@@ -49,68 +52,108 @@ func TestCountOfflineMessages(t *testing.T) {
 
 	s, mock := newOfflineMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
 
-	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp")
+	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 1, cnt)
 
 	s, mock = newOfflineMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countColums))
 
-	cnt, _ = s.CountOfflineMessages(context.Background(), "sxmpp")
+	cnt, _ = s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 0, cnt)
 
 	s, mock = newOfflineMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errGeneric)
 
-	_, err := s.CountOfflineMessages(context.Background(), "sxmpp")
+	_, err := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestFetchOfflineMessagesSize(t *testing.T) {
+	s, mock := newOfflineMock()
+	mock.ExpectQuery("SELECT COALESCE(.+) FROM offline_messages (.+)").
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(1024))
+
+	size, err := s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1024, size)
+
+	s, mock = newOfflineMock()
+	mock.ExpectQuery("SELECT COALESCE(.+) FROM offline_messages (.+)").
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnError(errGeneric)
+
+	_, err = s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errGeneric, err)
 }
 
 func TestFetchOfflineMessages(t *testing.T) {
-	var offlineMessagesColumns = []string{"data"}
+	var offlineMessagesColumns = []string{"id", "data", "expires_at"}
 
 	s, mock := newOfflineMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("<message id='abc'><body>Hi!</body></message>"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("m1", "<message id='abc'><body>Hi!</body></message>", nil))
 
-	msgs, _ := s.FetchOfflineMessages(context.Background(), "sxmpp")
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 1, len(msgs))
+	require.Equal(t, "m1", msgs[0].ID)
 
 	s, mock = newOfflineMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns))
 
-	msgs, _ = s.FetchOfflineMessages(context.Background(), "sxmpp")
+	msgs, _ = s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 0, len(msgs))
 
 	s, mock = newOfflineMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
-		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("<message id='abc'><body>Hi!"))
+		WithArgs("sxmpp", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("m1", "<message id='abc'><body>Hi!", nil))
 
-	_, err := s.FetchOfflineMessages(context.Background(), "sxmpp")
+	_, err := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 
 	s, mock = newOfflineMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("sxmpp").
+		WithArgs("sxmpp", "jackal.im").
 		WillReturnError(errGeneric)
 
-	_, err = s.FetchOfflineMessages(context.Background(), "sxmpp")
+	_, err = s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestDeleteOfflineMessagesByID(t *testing.T) {
+	s, mock := newOfflineMock()
+	mock.ExpectExec("DELETE FROM offline_messages (.+)").
+		WithArgs("sxmpp", "jackal.im", "m1", "m2").WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := s.DeleteOfflineMessagesByID(context.Background(), []string{"m1", "m2"}, "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = newOfflineMock()
+	mock.ExpectExec("DELETE FROM offline_messages (.+)").
+		WithArgs("sxmpp", "jackal.im", "m1").WillReturnError(errGeneric)
+
+	err = s.DeleteOfflineMessagesByID(context.Background(), []string{"m1"}, "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errGeneric, err)
 }
@@ -118,17 +161,60 @@ func TestFetchOfflineMessages(t *testing.T) {
 func TestDeleteOfflineMessages(t *testing.T) {
 	s, mock := newOfflineMock()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("sxmpp").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("sxmpp", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteOfflineMessages(context.Background(), "sxmpp")
+	err := s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = newOfflineMock()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("sxmpp").WillReturnError(errGeneric)
+		WithArgs("sxmpp", "jackal.im").WillReturnError(errGeneric)
+
+	err = s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errGeneric, err)
+}
+
+func TestDeleteExpiredOfflineMessages(t *testing.T) {
+	now := time.Now()
+
+	s, mock := newOfflineMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM offline_messages WHERE expires_at <= (.+)").
+		WithArgs(now.UTC()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data", "expires_at"}).AddRow("m1", "<message id='abc'><body>Hi!</body></message>", now))
+	mock.ExpectExec("DELETE FROM offline_messages (.+)").
+		WithArgs("m1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	msgs, err := s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "m1", msgs[0].ID)
+	require.True(t, now.Equal(msgs[0].ExpiresAt))
+
+	s, mock = newOfflineMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM offline_messages WHERE expires_at <= (.+)").
+		WithArgs(now.UTC()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data", "expires_at"}))
+	mock.ExpectCommit()
+
+	msgs, err = s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Len(t, msgs, 0)
+
+	s, mock = newOfflineMock()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM offline_messages WHERE expires_at <= (.+)").
+		WithArgs(now.UTC()).
+		WillReturnError(errGeneric)
+	mock.ExpectRollback()
 
-	err = s.DeleteOfflineMessages(context.Background(), "sxmpp")
+	_, err = s.DeleteExpiredOfflineMessages(context.Background(), now)
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errGeneric, err)
 }
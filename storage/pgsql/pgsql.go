@@ -14,6 +14,7 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/storage/migration"
 	"github.com/sxmpp/jackal/storage/repository"
 )
 
@@ -32,6 +33,10 @@ type pgSQLContainer struct {
 	blockList *pgSQLBlockList
 	pubSub    *pgSQLPubSub
 	offline   *pgSQLOffline
+	archive   *pgSQLArchive
+	push      *pgSQLPush
+	muc       *pgSQLMuc
+	host      *pgSQLHost
 
 	h          *sql.DB
 	cancelPing context.CancelFunc
@@ -46,9 +51,7 @@ func New(cfg *Config) (repository.Container, error) {
 
 	sq.StatementBuilder = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s", cfg.User, cfg.Password, cfg.Host, cfg.Database, cfg.SSLMode)
-
-	c.h, err = sql.Open("postgres", dsn)
+	c.h, err = sql.Open("postgres", dataSourceName(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +60,18 @@ func New(cfg *Config) (repository.Container, error) {
 	if err := c.ping(context.Background()); err != nil {
 		return nil, err
 	}
+	// verify schema version before serving
+	m := migration.New(c.h, sq.Dollar, migrations(cfg.LegacyDomain))
+	if cfg.AutoMigrate {
+		if _, err := m.Up(context.Background()); err != nil {
+			_ = c.h.Close()
+			return nil, err
+		}
+	}
+	if err := m.Verify(context.Background()); err != nil {
+		_ = c.h.Close()
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancelPing = cancel
 	go c.loop(ctx)
@@ -69,10 +84,27 @@ func New(cfg *Config) (repository.Container, error) {
 	c.blockList = newBlockList(c.h)
 	c.pubSub = newPubSub(c.h)
 	c.offline = newOffline(c.h)
+	c.archive = newArchive(c.h)
+	c.push = newPush(c.h)
+	c.muc = newMuc(c.h)
+	c.host = newHost(c.h)
 
 	return c, nil
 }
 
+// NewMigrator returns a schema migrator operating over the configured PostgreSQL database.
+func NewMigrator(cfg *Config) (*migration.Migrator, error) {
+	h, err := sql.Open("postgres", dataSourceName(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Ping(); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return migration.New(h, sq.Dollar, migrations(cfg.LegacyDomain)), nil
+}
+
 func (c *pgSQLContainer) User() repository.User           { return c.user }
 func (c *pgSQLContainer) Roster() repository.Roster       { return c.roster }
 func (c *pgSQLContainer) Presences() repository.Presences { return c.presences }
@@ -81,6 +113,10 @@ func (c *pgSQLContainer) Private() repository.Private     { return c.priv }
 func (c *pgSQLContainer) BlockList() repository.BlockList { return c.blockList }
 func (c *pgSQLContainer) PubSub() repository.PubSub       { return c.pubSub }
 func (c *pgSQLContainer) Offline() repository.Offline     { return c.offline }
+func (c *pgSQLContainer) Archive() repository.Archive     { return c.archive }
+func (c *pgSQLContainer) Push() repository.Push           { return c.push }
+func (c *pgSQLContainer) Muc() repository.Muc             { return c.muc }
+func (c *pgSQLContainer) Host() repository.Host           { return c.host }
 
 func (c *pgSQLContainer) Close(ctx context.Context) error {
 	ch := make(chan bool)
@@ -126,3 +162,7 @@ func (c *pgSQLContainer) ping(ctx context.Context) error {
 
 	return c.h.PingContext(pingCtx)
 }
+
+func dataSourceName(cfg *Config) string {
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s", cfg.User, cfg.Password, cfg.Host, cfg.Database, cfg.SSLMode)
+}
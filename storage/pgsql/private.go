@@ -28,7 +28,7 @@ func newPrivate(db *sql.DB) *pgSQLPrivate {
 
 // UpsertPrivateXML inserts a new private element into storage,
 // or updates it in case it's been previously inserted.
-func (s *pgSQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username string) error {
+func (s *pgSQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace, username, domain string) error {
 	buf := s.pool.Get()
 	defer s.pool.Put(buf)
 
@@ -41,19 +41,19 @@ func (s *pgSQLPrivate) UpsertPrivateXML(ctx context.Context, privateXML []xmpp.X
 	rawXML := buf.String()
 
 	q := sq.Insert("private_storage").
-		Columns("username", "namespace", "data").
-		Values(username, namespace, rawXML).
-		Suffix("ON CONFLICT (username, namespace) DO UPDATE SET data = $4", rawXML)
+		Columns("username", "domain", "namespace", "data").
+		Values(username, domain, namespace, rawXML).
+		Suffix("ON CONFLICT (username, domain, namespace) DO UPDATE SET data = $5", rawXML)
 
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
 // FetchPrivateXML retrieves from storage a private element.
-func (s *pgSQLPrivate) FetchPrivateXML(ctx context.Context, namespace string, username string) ([]xmpp.XElement, error) {
+func (s *pgSQLPrivate) FetchPrivateXML(ctx context.Context, namespace, username, domain string) ([]xmpp.XElement, error) {
 	q := sq.Select("data").
 		From("private_storage").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"namespace": namespace}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"namespace": namespace}})
 
 	var privateXML string
 	err := q.RunWith(s.db).QueryRowContext(ctx).Scan(&privateXML)
@@ -78,3 +78,26 @@ func (s *pgSQLPrivate) FetchPrivateXML(ctx context.Context, namespace string, us
 		return nil, err
 	}
 }
+
+// FetchPrivateXMLNamespaces retrieves from storage the namespaces of every private element stored by a user.
+func (s *pgSQLPrivate) FetchPrivateXMLNamespaces(ctx context.Context, username, domain string) ([]string, error) {
+	rows, err := sq.Select("namespace").
+		From("private_storage").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("namespace").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var namespaces []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, rows.Err()
+}
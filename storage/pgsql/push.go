@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	pushmodel "github.com/sxmpp/jackal/model/push"
+)
+
+type pgSQLPush struct {
+	*pgSQLStorage
+}
+
+func newPush(db *sql.DB) *pgSQLPush {
+	return &pgSQLPush{
+		pgSQLStorage: newStorage(db),
+	}
+}
+
+// UpsertPushRegistration inserts a new push registration into storage,
+// or updates it in case it's been previously inserted.
+func (s *pgSQLPush) UpsertPushRegistration(ctx context.Context, reg *pushmodel.Registration) error {
+	optionsBytes, err := json.Marshal(reg.Options)
+	if err != nil {
+		return err
+	}
+	optionsJSON := string(optionsBytes)
+	q := sq.Insert("push_registrations").
+		Columns("username", "domain", "jid", "node", "options").
+		Values(reg.Username, reg.Domain, reg.JID, reg.Node, optionsJSON).
+		Suffix("ON CONFLICT (username, domain, jid, node) DO UPDATE SET options = ?", optionsJSON)
+
+	_, err = q.RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// DeletePushRegistrations removes those user push registrations associated to an app server JID.
+// In case node is empty every registration associated to the app server will be removed.
+func (s *pgSQLPush) DeletePushRegistrations(ctx context.Context, username, domain, jid, node string) error {
+	filter := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}
+	if len(node) > 0 {
+		filter = append(filter, sq.Eq{"node": node})
+	}
+	_, err := sq.Delete("push_registrations").Where(filter).RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// FetchPushRegistrations retrieves from storage all user push registrations.
+func (s *pgSQLPush) FetchPushRegistrations(ctx context.Context, username, domain string) ([]pushmodel.Registration, error) {
+	q := sq.Select("jid", "node", "options").
+		From("push_registrations").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var regs []pushmodel.Registration
+	for rows.Next() {
+		reg := pushmodel.Registration{Username: username, Domain: domain}
+		var optionsJSON string
+		if err := rows.Scan(&reg.JID, &reg.Node, &optionsJSON); err != nil {
+			return nil, err
+		}
+		if err := json.NewDecoder(strings.NewReader(optionsJSON)).Decode(&reg.Options); err != nil {
+			return nil, err
+		}
+		regs = append(regs, reg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
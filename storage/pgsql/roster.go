@@ -34,9 +34,9 @@ func (s *pgSQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 
 	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username").
-			Values(ri.Username).
-			Suffix("ON CONFLICT (username) DO UPDATE SET ver = roster_versions.ver + 1")
+			Columns("username", "domain").
+			Values(ri.Username, ri.Domain).
+			Suffix("ON CONFLICT (username, domain) DO UPDATE SET ver = roster_versions.ver + 1")
 
 		if _, err := q.RunWith(tx).ExecContext(ctx); err != nil {
 			return err
@@ -46,18 +46,18 @@ func (s *pgSQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 			return err
 		}
 
-		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ?)", ri.Username)
+		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ? AND domain = ?)", ri.Username, ri.Domain)
 		q = sq.Insert("roster_items").
-			Columns("username", "jid", "name", "subscription", "groups", "ask", "ver").
-			Values(ri.Username, ri.JID, ri.Name, ri.Subscription, groupsBytes, ri.Ask, verExpr).
-			Suffix("ON CONFLICT (username, jid) DO UPDATE SET name = $3, subscription = $4, groups = $5, ask = $6, ver = roster_items.ver + 1")
+			Columns("username", "domain", "jid", "name", "subscription", "groups", "ask", "ver").
+			Values(ri.Username, ri.Domain, ri.JID, ri.Name, ri.Subscription, groupsBytes, ri.Ask, verExpr).
+			Suffix("ON CONFLICT (username, domain, jid) DO UPDATE SET name = $4, subscription = $5, groups = $6, ask = $7, ver = roster_items.ver + 1")
 		_, err = q.RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
 		}
 		// delete previous groups
 		_, err = sq.Delete("roster_groups").
-			Where(sq.And{sq.Eq{"username": ri.Username}, sq.Eq{"jid": ri.JID}}).
+			Where(sq.And{sq.Eq{"username": ri.Username}, sq.Eq{"domain": ri.Domain}, sq.Eq{"jid": ri.JID}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
@@ -65,15 +65,15 @@ func (s *pgSQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 		// insert groups
 		for _, group := range ri.Groups {
 			q = sq.Insert("roster_groups").
-				Columns("username", "jid", `"group"`, "created_at", "updated_at").
-				Values(ri.Username, ri.JID, group, nowExpr, nowExpr)
+				Columns("username", "domain", "jid", `"group"`, "created_at", "updated_at").
+				Values(ri.Username, ri.Domain, ri.JID, group, nowExpr, nowExpr)
 			_, err := q.RunWith(tx).ExecContext(ctx)
 			if err != nil {
 				return err
 			}
 		}
 		// fetch new roster version
-		ver, err = fetchRosterVer(ctx, ri.Username, tx)
+		ver, err = fetchRosterVer(ctx, ri.Username, ri.Domain, tx)
 		return err
 	})
 	if err != nil {
@@ -82,35 +82,35 @@ func (s *pgSQLRoster) UpsertRosterItem(ctx context.Context, ri *rostermodel.Item
 	return ver, nil
 }
 
-func (s *pgSQLRoster) DeleteRosterItem(ctx context.Context, username, jid string) (rostermodel.Version, error) {
+func (s *pgSQLRoster) DeleteRosterItem(ctx context.Context, username, domain, jid string) (rostermodel.Version, error) {
 	var ver rostermodel.Version
 
 	err := s.inTransaction(ctx, func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username").
-			Values(username).
-			Suffix("ON CONFLICT (username) DO UPDATE SET ver = roster_versions.ver + 1, last_deletion_ver = roster_versions.ver")
+			Columns("username", "domain").
+			Values(username, domain).
+			Suffix("ON CONFLICT (username, domain) DO UPDATE SET ver = roster_versions.ver + 1, last_deletion_ver = roster_versions.ver")
 
 		if _, err := q.RunWith(tx).ExecContext(ctx); err != nil {
 			return err
 		}
 		// delete groups
 		_, err := sq.Delete("roster_groups").
-			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
 		}
 		// delete items
 		_, err = sq.Delete("roster_items").
-			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}).
 			RunWith(tx).ExecContext(ctx)
 		if err != nil {
 			return err
 		}
 
 		// fetch new roster version
-		ver, err = fetchRosterVer(ctx, username, tx)
+		ver, err = fetchRosterVer(ctx, username, domain, tx)
 		return err
 	})
 	if err != nil {
@@ -119,10 +119,10 @@ func (s *pgSQLRoster) DeleteRosterItem(ctx context.Context, username, jid string
 	return ver, nil
 }
 
-func (s *pgSQLRoster) FetchRosterItems(ctx context.Context, username string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "groups", "ask", "ver").
+func (s *pgSQLRoster) FetchRosterItems(ctx context.Context, username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "groups", "ask", "ver").
 		From("roster_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at DESC")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -135,18 +135,18 @@ func (s *pgSQLRoster) FetchRosterItems(ctx context.Context, username string) ([]
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := fetchRosterVer(ctx, username, s.db)
+	ver, err := fetchRosterVer(ctx, username, domain, s.db)
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
 	return items, ver, nil
 }
 
-func (s *pgSQLRoster) FetchRosterItemsInGroups(ctx context.Context, username string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("ris.username", "ris.jid", "ris.name", "ris.subscription", "ris.groups", "ris.ask", "ris.ver").
+func (s *pgSQLRoster) FetchRosterItemsInGroups(ctx context.Context, username, domain string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("ris.username", "ris.domain", "ris.jid", "ris.name", "ris.subscription", "ris.groups", "ris.ask", "ris.ver").
 		From("roster_items ris").
-		LeftJoin("roster_groups g ON ris.username = g.username").
-		Where(sq.And{sq.Eq{"ris.username": username}, sq.Eq{"g.group": groups}}).
+		LeftJoin("roster_groups g ON ris.username = g.username AND ris.domain = g.domain").
+		Where(sq.And{sq.Eq{"ris.username": username}, sq.Eq{"ris.domain": domain}, sq.Eq{"g.group": groups}}).
 		OrderBy("ris.created_at DESC")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -159,17 +159,17 @@ func (s *pgSQLRoster) FetchRosterItemsInGroups(ctx context.Context, username str
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := fetchRosterVer(ctx, username, s.db)
+	ver, err := fetchRosterVer(ctx, username, domain, s.db)
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
 	return items, ver, nil
 }
 
-func (s *pgSQLRoster) FetchRosterItem(ctx context.Context, username, jid string) (*rostermodel.Item, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "groups", "ask", "ver").
+func (s *pgSQLRoster) FetchRosterItem(ctx context.Context, username, domain, jid string) (*rostermodel.Item, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "groups", "ask", "ver").
 		From("roster_items").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var ri rostermodel.Item
 	err := scanRosterItemEntity(&ri, q.RunWith(s.db).QueryRowContext(ctx))
@@ -187,19 +187,19 @@ func (s *pgSQLRoster) UpsertRosterNotification(ctx context.Context, rn *rostermo
 	presenceXML := rn.Presence.String()
 
 	q := sq.Insert("roster_notifications").
-		Columns("contact", "jid", "elements").
-		Values(rn.Contact, rn.JID, presenceXML).
-		Suffix("ON CONFLICT (contact, jid) DO UPDATE SET elements = $4", presenceXML)
+		Columns("contact", "domain", "jid", "elements").
+		Values(rn.Contact, rn.Domain, rn.JID, presenceXML).
+		Suffix("ON CONFLICT (contact, domain, jid) DO UPDATE SET elements = $5", presenceXML)
 
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 
 	return err
 }
 
-func (s *pgSQLRoster) FetchRosterNotifications(ctx context.Context, contact string) ([]rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *pgSQLRoster) FetchRosterNotifications(ctx context.Context, contact, domain string) ([]rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.Eq{"contact": contact}).
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -219,10 +219,10 @@ func (s *pgSQLRoster) FetchRosterNotifications(ctx context.Context, contact stri
 	return ret, nil
 }
 
-func (s *pgSQLRoster) FetchRosterNotification(ctx context.Context, contact string, jid string) (*rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *pgSQLRoster) FetchRosterNotification(ctx context.Context, contact, domain, jid string) (*rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var rn rostermodel.Notification
 	err := scanRosterNotificationEntity(&rn, q.RunWith(s.db).QueryRowContext(ctx))
@@ -236,16 +236,16 @@ func (s *pgSQLRoster) FetchRosterNotification(ctx context.Context, contact strin
 	}
 }
 
-func (s *pgSQLRoster) DeleteRosterNotification(ctx context.Context, contact, jid string) error {
-	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+func (s *pgSQLRoster) DeleteRosterNotification(ctx context.Context, contact, domain, jid string) error {
+	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
-func (s *pgSQLRoster) FetchRosterGroups(ctx context.Context, username string) ([]string, error) {
+func (s *pgSQLRoster) FetchRosterGroups(ctx context.Context, username, domain string) ([]string, error) {
 	q := sq.Select("`group`").
 		From("roster_groups").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		GroupBy("`group`")
 
 	rows, err := q.RunWith(s.db).QueryContext(ctx)
@@ -267,7 +267,7 @@ func (s *pgSQLRoster) FetchRosterGroups(ctx context.Context, username string) ([
 
 func scanRosterNotificationEntity(rn *rostermodel.Notification, scanner rowScanner) error {
 	var presenceXML string
-	if err := scanner.Scan(&rn.Contact, &rn.JID, &presenceXML); err != nil {
+	if err := scanner.Scan(&rn.Contact, &rn.Domain, &rn.JID, &presenceXML); err != nil {
 		return err
 	}
 	parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
@@ -283,7 +283,7 @@ func scanRosterNotificationEntity(rn *rostermodel.Notification, scanner rowScann
 
 func scanRosterItemEntity(ri *rostermodel.Item, scanner rowScanner) error {
 	var groupsBytes string
-	if err := scanner.Scan(&ri.Username, &ri.JID, &ri.Name, &ri.Subscription, &groupsBytes, &ri.Ask, &ri.Ver); err != nil {
+	if err := scanner.Scan(&ri.Username, &ri.Domain, &ri.JID, &ri.Name, &ri.Subscription, &groupsBytes, &ri.Ask, &ri.Ver); err != nil {
 		return err
 	}
 	if len(groupsBytes) > 0 {
@@ -306,10 +306,10 @@ func scanRosterItemEntities(scanner rowsScanner) ([]rostermodel.Item, error) {
 	return ret, nil
 }
 
-func fetchRosterVer(ctx context.Context, username string, runner sq.BaseRunner) (rostermodel.Version, error) {
+func fetchRosterVer(ctx context.Context, username, domain string, runner sq.BaseRunner) (rostermodel.Version, error) {
 	q := sq.Select("COALESCE(MAX(ver), 0)", "COALESCE(MAX(last_deletion_ver), 0)").
 		From("roster_versions").
-		Where(sq.Eq{"username": username})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var ver rostermodel.Version
 	row := q.RunWith(runner).QueryRowContext(ctx)
@@ -43,46 +43,55 @@ func (u *pgSQLUser) UpsertUser(ctx context.Context, usr *model.User) error {
 		u.pool.Put(buf)
 	}
 
+	status := usr.Status
+	if len(status) == 0 {
+		status = model.UserActive
+	}
+	var expiresAt interface{}
+	if !usr.ExpiresAt.IsZero() {
+		expiresAt = usr.ExpiresAt
+	}
 	q := sq.Insert("users")
 
 	if len(presenceXML) > 0 {
-		q = q.Columns("username", "password", "last_presence", "last_presence_at").
-			Values(usr.Username, usr.Password, presenceXML, nowExpr).
-			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2, last_presence = $3, last_presence_at = NOW()")
+		q = q.Columns("username", "domain", "password", "email", "status", "status_reason", "expires_at", "last_presence", "last_presence_at").
+			Values(usr.Username, usr.Domain, usr.Password, usr.Email, status, usr.StatusReason, expiresAt, presenceXML, nowExpr).
+			Suffix("ON CONFLICT (username, domain) DO UPDATE SET password = $3, email = $4, status = $5, status_reason = $6, expires_at = $7, last_presence = $8, last_presence_at = NOW()")
 	} else {
-		q = q.Columns("username", "password").
-			Values(usr.Username, usr.Password).
-			Suffix("ON CONFLICT (username) DO UPDATE SET password = $2")
+		q = q.Columns("username", "domain", "password", "email", "status", "status_reason", "expires_at").
+			Values(usr.Username, usr.Domain, usr.Password, usr.Email, status, usr.StatusReason, expiresAt).
+			Suffix("ON CONFLICT (username, domain) DO UPDATE SET password = $3, email = $4, status = $5, status_reason = $6, expires_at = $7")
+	}
+	_, err := q.RunWith(u.db).ExecContext(ctx)
+	return err
+}
+
+// UpdateLastPresence updates last received presence of a previously inserted user.
+func (u *pgSQLUser) UpdateLastPresence(ctx context.Context, username, domain string, presence *xmpp.Presence) error {
+	buf := u.pool.Get()
+	defer u.pool.Put(buf)
+	if err := presence.ToXML(buf, true); err != nil {
+		return err
 	}
+	q := sq.Update("users").
+		Set("last_presence", buf.String()).
+		Set("last_presence_at", nowExpr).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
 	_, err := q.RunWith(u.db).ExecContext(ctx)
 	return err
 }
 
 // FetchUser retrieves from storage a user entity.
-func (u *pgSQLUser) FetchUser(ctx context.Context, username string) (*model.User, error) {
-	q := sq.Select("username", "password", "last_presence", "last_presence_at").
+func (u *pgSQLUser) FetchUser(ctx context.Context, username, domain string) (*model.User, error) {
+	q := sq.Select(userColumns...).
 		From("users").
-		Where(sq.Eq{"username": username})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
-	var presenceXML string
-	var presenceAt time.Time
-	var usr model.User
-
-	err := q.RunWith(u.db).QueryRowContext(ctx).Scan(&usr.Username, &usr.Password, &presenceXML, &presenceAt)
+	usr, err := scanUser(q.RunWith(u.db).QueryRowContext(ctx))
 	switch err {
 	case nil:
-		if len(presenceXML) > 0 {
-			parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
-			lastPresence, err := parser.ParseElement()
-			if err != nil {
-				return nil, err
-			}
-			fromJID, _ := jid.NewWithString(lastPresence.From(), true)
-			toJID, _ := jid.NewWithString(lastPresence.To(), true)
-			usr.LastPresence, _ = xmpp.NewPresenceFromElement(lastPresence, fromJID, toJID)
-			usr.LastPresenceAt = presenceAt
-		}
-		return &usr, nil
+		return usr, nil
 	case sql.ErrNoRows:
 		return nil, nil
 	default:
@@ -91,42 +100,72 @@ func (u *pgSQLUser) FetchUser(ctx context.Context, username string) (*model.User
 }
 
 // DeleteUser deletes a user entity from storage.
-func (u *pgSQLUser) DeleteUser(ctx context.Context, username string) error {
+func (u *pgSQLUser) DeleteUser(ctx context.Context, username, domain string) error {
+	userJID := username + "@" + domain
+	owned := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
+	ownedNodes := sq.Expr("node_id IN (SELECT id FROM pubsub_nodes WHERE host = ?)", userJID)
+
+	stmts := []sq.Sqlizer{
+		// account owned entities
+		sq.Delete("offline_messages").Where(owned),
+		sq.Delete("archive_messages").Where(owned),
+		sq.Delete("archive_preferences").Where(owned),
+		sq.Delete("push_registrations").Where(owned),
+		sq.Delete("presences").Where(owned),
+		sq.Delete("roster_groups").Where(owned),
+		sq.Delete("roster_items").Where(owned),
+		sq.Delete("roster_versions").Where(owned),
+		sq.Delete("blocklist_items").Where(owned),
+		sq.Delete("private_storage").Where(owned),
+		sq.Delete("vcards").Where(owned),
+		sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": username}, sq.Eq{"domain": domain}}),
+
+		// pending subscription requests sent by the account
+		sq.Delete("roster_notifications").Where(sq.Eq{"jid": userJID}),
+
+		// remove account from other users rosters
+		sq.Update("roster_versions").
+			Set("last_deletion_ver", sq.Expr("ver + 1")).
+			Set("ver", sq.Expr("ver + 1")).
+			Where(sq.Expr("(username, domain) IN (SELECT username, domain FROM roster_items WHERE jid = ?)", userJID)),
+		sq.Delete("roster_groups").Where(sq.Eq{"jid": userJID}),
+		sq.Delete("roster_items").Where(sq.Eq{"jid": userJID}),
+
+		// owned PEP nodes
+		sq.Delete("pubsub_node_options").Where(ownedNodes),
+		sq.Delete("pubsub_items").Where(ownedNodes),
+		sq.Delete("pubsub_affiliations").Where(ownedNodes),
+		sq.Delete("pubsub_subscriptions").Where(ownedNodes),
+		sq.Delete("pubsub_nodes").Where(sq.Eq{"host": userJID}),
+
+		// affiliations and subscriptions to foreign nodes
+		sq.Delete("pubsub_affiliations").Where(sq.Eq{"jid": userJID}),
+		sq.Delete("pubsub_subscriptions").Where(sq.Eq{"jid": userJID}),
+
+		// room nick reservations
+		sq.Delete("muc_nick_reservations").Where(sq.Eq{"jid": userJID}),
+
+		sq.Delete("users").Where(owned),
+	}
 	return u.inTransaction(ctx, func(tx *sql.Tx) error {
-		var err error
-		_, err = sq.Delete("offline_messages").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
-		}
-		_, err = sq.Delete("roster_items").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
-		}
-		_, err = sq.Delete("roster_versions").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
-		}
-		_, err = sq.Delete("private_storage").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
-		}
-		_, err = sq.Delete("vcards").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
-		}
-		_, err = sq.Delete("users").Where(sq.Eq{"username": username}).RunWith(tx).ExecContext(ctx)
-		if err != nil {
-			return err
+		for _, stmt := range stmts {
+			query, args, err := stmt.ToSql()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
 		}
-		return nil
+		return deleteMucAffiliations(ctx, tx, userJID)
 	})
 }
 
 // UserExists returns whether or not a user exists within storage.
-func (u *pgSQLUser) UserExists(ctx context.Context, username string) (bool, error) {
+func (u *pgSQLUser) UserExists(ctx context.Context, username, domain string) (bool, error) {
 	var count int
 
-	q := sq.Select("COUNT(*)").From("users").Where(sq.Eq{"username": username})
+	q := sq.Select("COUNT(*)").From("users").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 	err := q.RunWith(u.db).QueryRowContext(ctx).Scan(&count)
 	switch err {
 	case nil:
@@ -135,3 +174,76 @@ func (u *pgSQLUser) UserExists(ctx context.Context, username string) (bool, erro
 		return false, err
 	}
 }
+
+// FetchUsernames retrieves from storage the names of every user registered within a domain.
+func (u *pgSQLUser) FetchUsernames(ctx context.Context, domain string) ([]string, error) {
+	rows, err := sq.Select("username").
+		From("users").
+		Where(sq.Eq{"domain": domain}).
+		OrderBy("username").
+		RunWith(u.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// FetchExpiredUsers retrieves from storage every active user whose account expiration time has been reached.
+func (u *pgSQLUser) FetchExpiredUsers(ctx context.Context) ([]model.User, error) {
+	rows, err := sq.Select(userColumns...).
+		From("users").
+		Where(sq.And{sq.Eq{"status": model.UserActive}, sq.Expr("expires_at <= NOW()")}).
+		RunWith(u.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []model.User
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *usr)
+	}
+	return users, rows.Err()
+}
+
+var userColumns = []string{"username", "domain", "password", "last_presence", "last_presence_at", "email", "status", "status_reason", "expires_at", "created_at"}
+
+func scanUser(scanner rowScanner) (*model.User, error) {
+	var presenceXML string
+	var presenceAt time.Time
+	var expiresAt sql.NullTime
+	var usr model.User
+
+	if err := scanner.Scan(&usr.Username, &usr.Domain, &usr.Password, &presenceXML, &presenceAt, &usr.Email, &usr.Status, &usr.StatusReason, &expiresAt, &usr.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(presenceXML) > 0 {
+		parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
+		lastPresence, err := parser.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		fromJID, _ := jid.NewWithString(lastPresence.From(), true)
+		toJID, _ := jid.NewWithString(lastPresence.To(), true)
+		usr.LastPresence, _ = xmpp.NewPresenceFromElement(lastPresence, fromJID, toJID)
+		usr.LastPresenceAt = presenceAt
+	}
+	if expiresAt.Valid {
+		usr.ExpiresAt = expiresAt.Time
+	}
+	return &usr, nil
+}
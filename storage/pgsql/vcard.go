@@ -25,21 +25,21 @@ func newVCard(db *sql.DB) *pgSQLVCard {
 }
 
 // UpsertVCard inserts a new vCard element into storage, or updates it in case it's been previously inserted.
-func (s *pgSQLVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username string) error {
+func (s *pgSQLVCard) UpsertVCard(ctx context.Context, vCard xmpp.XElement, username, domain string) error {
 	rawXML := vCard.String()
 
 	q := sq.Insert("vcards").
-		Columns("username", "vcard").
-		Values(username, rawXML).
-		Suffix("ON CONFLICT (username) DO UPDATE SET vcard = $3", rawXML)
+		Columns("username", "domain", "vcard").
+		Values(username, domain, rawXML).
+		Suffix("ON CONFLICT (username, domain) DO UPDATE SET vcard = $4", rawXML)
 
 	_, err := q.RunWith(s.db).ExecContext(ctx)
 	return err
 }
 
 // FetchVCard retrieves from storage a vCard element associated to a given user.
-func (s *pgSQLVCard) FetchVCard(ctx context.Context, username string) (xmpp.XElement, error) {
-	q := sq.Select("vcard").From("vcards").Where(sq.Eq{"username": username})
+func (s *pgSQLVCard) FetchVCard(ctx context.Context, username, domain string) (xmpp.XElement, error) {
+	q := sq.Select("vcard").From("vcards").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var vCard string
 
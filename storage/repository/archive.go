@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+)
+
+// Archive defines storage operations for user message archives
+type Archive interface {
+	// InsertArchiveMessage appends a new message into its owner archive.
+	InsertArchiveMessage(ctx context.Context, message *archivemodel.Message) error
+
+	// FetchArchiveMessages retrieves in chronological order those archived messages satisfying a query.
+	FetchArchiveMessages(ctx context.Context, query *archivemodel.Query, username, domain string) ([]archivemodel.Message, error)
+
+	// CountArchiveMessages returns the number of archived messages satisfying a query filter, paging parameters aside.
+	CountArchiveMessages(ctx context.Context, query *archivemodel.Query, username, domain string) (int, error)
+
+	// ArchiveMessageExists returns whether or not a message identifier is contained in a user archive.
+	ArchiveMessageExists(ctx context.Context, id, username, domain string) (bool, error)
+
+	// DeleteArchiveMessages removes from every archive those messages archived before a given time.
+	DeleteArchiveMessages(ctx context.Context, before time.Time) error
+
+	// UpsertArchivePreferences inserts new user archiving preferences into storage,
+	// or updates them in case they've been previously inserted.
+	UpsertArchivePreferences(ctx context.Context, prefs *archivemodel.Preferences, username, domain string) error
+
+	// FetchArchivePreferences retrieves from storage user archiving preferences.
+	FetchArchivePreferences(ctx context.Context, username, domain string) (*archivemodel.Preferences, error)
+}
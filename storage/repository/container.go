@@ -33,6 +33,18 @@ type Container interface {
 	// Offline method returns repository.Offline concrete implementation.
 	Offline() Offline
 
+	// Archive method returns repository.Archive concrete implementation.
+	Archive() Archive
+
+	// Push method returns repository.Push concrete implementation.
+	Push() Push
+
+	// Muc method returns repository.Muc concrete implementation.
+	Muc() Muc
+
+	// Host method returns repository.Host concrete implementation.
+	Host() Host
+
 	// Close closes underlying storage resources, commonly shared across repositories.
 	Close(ctx context.Context) error
 
@@ -0,0 +1,27 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	"github.com/sxmpp/jackal/model"
+)
+
+// Host defines storage operations for virtual hosts.
+type Host interface {
+	// UpsertHost inserts a new host entity into storage, or updates it in case it's been previously inserted.
+	UpsertHost(ctx context.Context, host *model.Host) error
+
+	// DeleteHost deletes a host entity from storage.
+	DeleteHost(ctx context.Context, name string) error
+
+	// FetchHost retrieves from storage a host entity.
+	FetchHost(ctx context.Context, name string) (*model.Host, error)
+
+	// FetchHosts retrieves from storage all host entities.
+	FetchHosts(ctx context.Context) ([]model.Host, error)
+}
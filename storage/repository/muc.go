@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+)
+
+// MucRoom defines storage operations for persistent multi-user chat rooms.
+type MucRoom interface {
+	// UpsertMucRoom inserts a new room into storage, or updates it in case it's been previously inserted.
+	UpsertMucRoom(ctx context.Context, room *mucmodel.Room) error
+
+	// DeleteMucRoom deletes a room from storage, along with all its nick reservations.
+	DeleteMucRoom(ctx context.Context, roomJID string) error
+
+	// FetchMucRoom retrieves from storage a room entity.
+	FetchMucRoom(ctx context.Context, roomJID string) (*mucmodel.Room, error)
+
+	// FetchMucRooms retrieves from storage all rooms hosted by a multi-user chat service.
+	FetchMucRooms(ctx context.Context, service string) ([]mucmodel.Room, error)
+}
+
+// MucNickReservation defines storage operations for multi-user chat room nick reservations.
+type MucNickReservation interface {
+	// UpsertMucNickReservation inserts a new nick reservation into storage,
+	// or updates it in case user already reserved a nick within the room.
+	UpsertMucNickReservation(ctx context.Context, reservation *mucmodel.NickReservation) error
+
+	// DeleteMucNickReservation deletes a user nick reservation from storage.
+	DeleteMucNickReservation(ctx context.Context, roomJID, jid string) error
+
+	// FetchMucNickReservations retrieves from storage all nick reservations associated to a room.
+	FetchMucNickReservations(ctx context.Context, roomJID string) ([]mucmodel.NickReservation, error)
+}
+
+// Muc brings together multi-user chat storage operations.
+type Muc interface {
+	MucRoom
+	MucNickReservation
+}
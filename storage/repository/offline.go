@@ -7,21 +7,32 @@ package repository
 
 import (
 	"context"
+	"time"
 
-	"github.com/sxmpp/jackal/xmpp"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
 )
 
 // Offline defines storage operations for offline messages
 type Offline interface {
 	// InsertOfflineMessage inserts a new message element into user's offline queue.
-	InsertOfflineMessage(ctx context.Context, message *xmpp.Message, username string) error
+	InsertOfflineMessage(ctx context.Context, message *offlinemodel.Message, username, domain string) error
 
 	// CountOfflineMessages returns current length of user's offline queue.
-	CountOfflineMessages(ctx context.Context, username string) (int, error)
+	CountOfflineMessages(ctx context.Context, username, domain string) (int, error)
+
+	// FetchOfflineMessagesSize returns the total size in bytes of user's offline queue messages.
+	FetchOfflineMessagesSize(ctx context.Context, username, domain string) (int, error)
 
 	// FetchOfflineMessages retrieves from storage current user offline queue.
-	FetchOfflineMessages(ctx context.Context, username string) ([]xmpp.Message, error)
+	FetchOfflineMessages(ctx context.Context, username, domain string) ([]offlinemodel.Message, error)
+
+	// DeleteOfflineMessagesByID removes from user's offline queue those messages identified by ids.
+	DeleteOfflineMessagesByID(ctx context.Context, ids []string, username, domain string) error
 
 	// DeleteOfflineMessages clears a user offline queue.
-	DeleteOfflineMessages(ctx context.Context, username string) error
+	DeleteOfflineMessages(ctx context.Context, username, domain string) error
+
+	// DeleteExpiredOfflineMessages removes from every offline queue those messages expired at a given time,
+	// returning them.
+	DeleteExpiredOfflineMessages(ctx context.Context, at time.Time) ([]offlinemodel.Message, error)
 }
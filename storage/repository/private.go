@@ -14,8 +14,11 @@ import (
 // Private defines operations for private storage.
 type Private interface {
 	// FetchPrivateXML retrieves from storage a private element.
-	FetchPrivateXML(ctx context.Context, namespace string, username string) ([]xmpp.XElement, error)
+	FetchPrivateXML(ctx context.Context, namespace string, username, domain string) ([]xmpp.XElement, error)
 
 	// UpsertPrivateXML inserts a new private element into storage, or updates it if previously inserted.
-	UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username string) error
+	UpsertPrivateXML(ctx context.Context, privateXML []xmpp.XElement, namespace string, username, domain string) error
+
+	// FetchPrivateXMLNamespaces retrieves from storage the namespaces of every private element stored by a user.
+	FetchPrivateXMLNamespaces(ctx context.Context, username, domain string) ([]string, error)
 }
@@ -0,0 +1,26 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package repository
+
+import (
+	"context"
+
+	pushmodel "github.com/sxmpp/jackal/model/push"
+)
+
+// Push defines storage operations for user push notifications registrations
+type Push interface {
+	// UpsertPushRegistration inserts a new push registration into storage,
+	// or updates it in case it's been previously inserted.
+	UpsertPushRegistration(ctx context.Context, reg *pushmodel.Registration) error
+
+	// DeletePushRegistrations removes those user push registrations associated to an app server JID.
+	// In case node is empty every registration associated to the app server will be removed.
+	DeletePushRegistrations(ctx context.Context, username, domain, jid, node string) error
+
+	// FetchPushRegistrations retrieves from storage all user push registrations.
+	FetchPushRegistrations(ctx context.Context, username, domain string) ([]pushmodel.Registration, error)
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 
 	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/xmpp"
 )
 
 // User defines user repository operations
@@ -16,12 +17,21 @@ type User interface {
 	// UpsertUser inserts a new user entity into storage, or updates it if previously inserted.
 	UpsertUser(ctx context.Context, user *model.User) error
 
-	// DeleteUser deletes a user entity from storage.
-	DeleteUser(ctx context.Context, username string) error
+	// UpdateLastPresence updates last received presence of a previously inserted user.
+	UpdateLastPresence(ctx context.Context, username, domain string, presence *xmpp.Presence) error
+
+	// DeleteUser deletes a user entity from storage, along with every entity associated to it.
+	DeleteUser(ctx context.Context, username, domain string) error
 
 	// FetchUser retrieves a user entity from storage.
-	FetchUser(ctx context.Context, username string) (*model.User, error)
+	FetchUser(ctx context.Context, username, domain string) (*model.User, error)
 
 	// UserExists tells whether or not a user exists within storage.
-	UserExists(ctx context.Context, username string) (bool, error)
+	UserExists(ctx context.Context, username, domain string) (bool, error)
+
+	// FetchUsernames retrieves from storage the names of every user registered within a domain.
+	FetchUsernames(ctx context.Context, domain string) ([]string, error)
+
+	// FetchExpiredUsers retrieves from storage every active user whose account expiration time has been reached.
+	FetchExpiredUsers(ctx context.Context) ([]model.User, error)
 }
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	archivemodel "github.com/sxmpp/jackal/model/archive"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_ArchiveMessages(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Archive()
+
+	now := time.Now()
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("1", "romeo@jackal.im/garden", now.Add(-48*time.Hour))))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("2", "nurse@jackal.im/home", now)))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("3", "romeo@jackal.im/orchard", now.Add(time.Second))))
+	require.Nil(t, s.InsertArchiveMessage(context.Background(), tArchiveMessage("4", "romeo@jackal.im/garden", now.Add(2*time.Second))))
+
+	messages, err := s.FetchArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im"}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, messages, 3)
+	require.Equal(t, "1", messages[0].ID)
+	require.Equal(t, "romeo@jackal.im/garden", messages[0].With)
+	require.Equal(t, "Wherefore art thou Romeo?", messages[0].Message.Elements().Child("body").Text())
+
+	// time interval
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{Start: now.Add(-time.Hour), End: now.Add(time.Second)}, "juliet", "jackal.im")
+	require.Len(t, messages, 2)
+	require.Equal(t, "2", messages[0].ID)
+	require.Equal(t, "3", messages[1].ID)
+
+	// paging
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{After: "1", Max: 2}, "juliet", "jackal.im")
+	require.Len(t, messages, 2)
+	require.Equal(t, "2", messages[0].ID)
+	require.Equal(t, "3", messages[1].ID)
+
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{Before: "4", Max: 2}, "juliet", "jackal.im")
+	require.Len(t, messages, 2)
+	require.Equal(t, "2", messages[0].ID)
+	require.Equal(t, "3", messages[1].ID)
+
+	messages, _ = s.FetchArchiveMessages(context.Background(), &archivemodel.Query{After: "5"}, "juliet", "jackal.im")
+	require.Len(t, messages, 0)
+
+	cnt, err := s.CountArchiveMessages(context.Background(), &archivemodel.Query{With: "romeo@jackal.im/garden"}, "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, cnt)
+
+	// retention
+	require.Nil(t, s.DeleteArchiveMessages(context.Background(), now.Add(-24*time.Hour)))
+
+	ok, err := s.ArchiveMessageExists(context.Background(), "1", "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, _ = s.ArchiveMessageExists(context.Background(), "2", "juliet", "jackal.im")
+	require.True(t, ok)
+}
+
+func TestSQLite_ArchivePreferences(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Archive()
+
+	prefs, err := s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, prefs)
+
+	require.Nil(t, s.UpsertArchivePreferences(context.Background(), &archivemodel.Preferences{Default: archivemodel.Always}, "juliet", "jackal.im"))
+	require.Nil(t, s.UpsertArchivePreferences(context.Background(), &archivemodel.Preferences{
+		Default: archivemodel.Roster,
+		Always:  []string{"romeo@jackal.im"},
+		Never:   []string{"tybalt@jackal.im"},
+	}, "juliet", "jackal.im"))
+
+	prefs, err = s.FetchArchivePreferences(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, archivemodel.Roster, prefs.Default)
+	require.Equal(t, []string{"romeo@jackal.im"}, prefs.Always)
+	require.Equal(t, []string{"tybalt@jackal.im"}, prefs.Never)
+}
+
+func tArchiveMessage(id, with string, stamp time.Time) *archivemodel.Message {
+	from, _ := jid.NewWithString(with, true)
+	to, _ := jid.NewWithString("juliet@jackal.im/balcony", true)
+	msgEl := xmpp.NewElementName("message")
+	msgEl.SetType(xmpp.ChatType)
+	body := xmpp.NewElementName("body")
+	body.SetText("Wherefore art thou Romeo?")
+	msgEl.AppendElement(body)
+	msg, _ := xmpp.NewMessageFromElement(msgEl, from, to)
+
+	return &archivemodel.Message{
+		ID:       id,
+		Username: "juliet",
+		Domain:   "jackal.im",
+		With:     with,
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_InsertOrUpdateBlockListItems(t *testing.T) {
+	items := []model.BlockListItem{
+		{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"},
+	}
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.BlockList()
+
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"}))
+
+	sItems, _ := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, items, sItems)
+}
+
+func TestSQLite_DeleteBlockListItems(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.BlockList()
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+	require.Nil(t, s.InsertBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"}))
+
+	require.Nil(t, s.DeleteBlockListItem(context.Background(), &model.BlockListItem{Username: "sxmpp", Domain: "jackal.im", JID: "romeo@jackal.im"}))
+
+	sItems, _ := s.FetchBlockListItems(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, []model.BlockListItem{
+		{Username: "sxmpp", Domain: "jackal.im", JID: "user@jackal.im"},
+		{Username: "sxmpp", Domain: "jackal.im", JID: "juliet@jackal.im"},
+	}, sItems)
+}
@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+// DefaultPath defines the default SQLite database file path.
+const DefaultPath = "./jackal.db"
+
+// Config represents SQLite storage configuration.
+type Config struct {
+	Path string `yaml:"path"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawConfig Config
+
+	parsed := rawConfig{Path: DefaultPath}
+
+	if err := unmarshal(&parsed); err != nil {
+		return err
+	}
+	*c = Config(parsed)
+
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_UpsertHost(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	hst := model.Host{Name: "jackal.im", Enabled: true}
+	s := h.Host()
+	require.Nil(t, s.UpsertHost(context.Background(), &hst))
+
+	hst.Enabled = false
+	require.Nil(t, s.UpsertHost(context.Background(), &hst))
+
+	hosts, err := s.FetchHosts(context.Background())
+	require.Nil(t, err)
+	require.Len(t, hosts, 1)
+	require.False(t, hosts[0].Enabled)
+}
+
+func TestSQLite_FetchHost(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Host()
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "jackal.im", Enabled: true})
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "example.org", Enabled: true})
+
+	hst, err := s.FetchHost(context.Background(), "example.org")
+	require.Nil(t, err)
+	require.NotNil(t, hst)
+	require.Equal(t, "example.org", hst.Name)
+
+	hst, err = s.FetchHost(context.Background(), "example.net")
+	require.Nil(t, err)
+	require.Nil(t, hst)
+}
+
+func TestSQLite_DeleteHost(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Host()
+	_ = s.UpsertHost(context.Background(), &model.Host{Name: "jackal.im", Enabled: true})
+
+	require.Nil(t, s.DeleteHost(context.Background(), "jackal.im"))
+
+	hosts, err := s.FetchHosts(context.Background())
+	require.Nil(t, err)
+	require.Len(t, hosts, 0)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	mucmodel "github.com/sxmpp/jackal/model/muc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_MucRooms(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Muc()
+
+	room := &mucmodel.Room{
+		JID:          "lobby@muc.jackal.im",
+		Config:       mucmodel.RoomConfig{Name: "Lobby", Persistent: true, MaxOccupants: 50},
+		Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.Owner},
+	}
+	require.Nil(t, s.UpsertMucRoom(context.Background(), room))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), &mucmodel.Room{JID: "garden@muc.jackal.im", Affiliations: map[string]string{}}))
+	require.Nil(t, s.UpsertMucRoom(context.Background(), &mucmodel.Room{JID: "garden@conference.jackal.im", Affiliations: map[string]string{}}))
+
+	room.Subject = "Welcome!"
+	room.Affiliations["romeo@jackal.im"] = mucmodel.Member
+	require.Nil(t, s.UpsertMucRoom(context.Background(), room))
+
+	r, err := s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, room, r)
+
+	r, err = s.FetchMucRoom(context.Background(), "hall@muc.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, r)
+
+	rooms, err := s.FetchMucRooms(context.Background(), "muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, rooms, 2)
+	require.Equal(t, "garden@muc.jackal.im", rooms[0].JID)
+
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "ortuman"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "romeo@jackal.im", Nick: "romeo"}))
+	require.Nil(t, s.UpsertMucNickReservation(context.Background(), &mucmodel.NickReservation{RoomJID: "lobby@muc.jackal.im", JID: "ortuman@jackal.im", Nick: "sxmpp"}))
+
+	reservations, err := s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, err)
+	require.Len(t, reservations, 2)
+	require.Equal(t, "sxmpp", reservations[0].Nick)
+
+	require.Nil(t, s.DeleteMucNickReservation(context.Background(), "lobby@muc.jackal.im", "romeo@jackal.im"))
+	reservations, _ = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 1)
+
+	require.Nil(t, s.DeleteMucRoom(context.Background(), "lobby@muc.jackal.im"))
+	r, _ = s.FetchMucRoom(context.Background(), "lobby@muc.jackal.im")
+	require.Nil(t, r)
+	reservations, _ = s.FetchMucNickReservations(context.Background(), "lobby@muc.jackal.im")
+	require.Len(t, reservations, 0)
+}
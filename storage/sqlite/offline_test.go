@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_InsertOfflineMessage(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Offline()
+
+	require.Nil(t, s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im"))
+}
+
+func TestSQLite_CountOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Offline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	cnt, _ := s.CountOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, cnt)
+}
+
+func TestSQLite_FetchOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Offline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 1, len(elems))
+}
+
+func TestSQLite_FetchOfflineMessagesSize(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Offline()
+
+	size, err := s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 0, size)
+
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	size, err = s.FetchOfflineMessagesSize(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2*len(m.String()), size)
+}
+
+func TestSQLite_DeleteExpiredOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	now := time.Now()
+
+	s := h.Offline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m1", ExpiresAt: now.Add(-time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m2", ExpiresAt: now.Add(time.Minute), Message: m}, "sxmpp", "jackal.im")
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: "m3", Message: m}, "sxmpp", "jackal.im")
+
+	expired, err := s.DeleteExpiredOfflineMessages(context.Background(), now)
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	require.Equal(t, "m1", expired[0].ID)
+	require.Equal(t, m.String(), expired[0].Message.String())
+
+	msgs, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Len(t, msgs, 2)
+	require.Equal(t, "m2", msgs[0].ID)
+	require.Equal(t, now.Add(time.Minute).Unix(), msgs[0].ExpiresAt.Unix())
+	require.True(t, msgs[1].ExpiresAt.IsZero())
+}
+
+func TestSQLite_DeleteOfflineMessages(t *testing.T) {
+	j, _ := jid.NewWithString("sxmpp@jackal.im/balcony", false)
+	message := xmpp.NewElementName("message")
+	message.SetID(uuid.New())
+	message.AppendElement(xmpp.NewElementName("body"))
+	m, _ := xmpp.NewMessageFromElement(message, j, j)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Offline()
+	_ = s.InsertOfflineMessage(context.Background(), &offlinemodel.Message{ID: uuid.New(), Message: m}, "sxmpp", "jackal.im")
+
+	require.Nil(t, s.DeleteOfflineMessages(context.Background(), "sxmpp", "jackal.im"))
+
+	elems, _ := s.FetchOfflineMessages(context.Background(), "sxmpp", "jackal.im")
+	require.Equal(t, 0, len(elems))
+}
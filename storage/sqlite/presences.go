@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	capsmodel "github.com/sxmpp/jackal/model/capabilities"
+	"github.com/sxmpp/jackal/storage/pgsql"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/util/pool"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// sqlitePresences reuses PostgreSQL presences repository, overriding those queries
+// not supported by SQLite.
+type sqlitePresences struct {
+	repository.Presences
+	db   *sql.DB
+	pool *pool.BufferPool
+}
+
+func newPresences(db *sql.DB) *sqlitePresences {
+	return &sqlitePresences{
+		Presences: pgsql.NewPresences(db),
+		db:        db,
+		pool:      pool.NewBufferPool(),
+	}
+}
+
+func (s *sqlitePresences) UpsertPresence(ctx context.Context, presence *xmpp.Presence, jid *jid.JID, allocationID string) (loaded bool, err error) {
+	buf := s.pool.Get()
+	defer s.pool.Put(buf)
+	if err := presence.ToXML(buf, true); err != nil {
+		return false, err
+	}
+	var node, ver string
+	if caps := presence.Capabilities(); caps != nil {
+		node = caps.Node
+		ver = caps.Ver
+	}
+	rawXML := buf.String()
+
+	// RETURNING clause is not available, so existence is checked within the same transaction
+	var inserted bool
+	err = inTransaction(ctx, s.db, func(tx *sql.Tx) error {
+		var count int
+		err := sq.Select("COUNT(*)").
+			From("presences").
+			Where(sq.And{sq.Eq{"username": jid.Node()}, sq.Eq{"domain": jid.Domain()}, sq.Eq{"resource": jid.Resource()}}).
+			RunWith(tx).QueryRowContext(ctx).Scan(&count)
+		if err != nil {
+			return err
+		}
+		inserted = count == 0
+
+		_, err = sq.Insert("presences").
+			Columns("username", "domain", "resource", "presence", "node", "ver", "allocation_id").
+			Values(jid.Node(), jid.Domain(), jid.Resource(), rawXML, node, ver, allocationID).
+			Suffix("ON CONFLICT (username, domain, resource) DO UPDATE SET presence = $4, node = $5, ver = $6, allocation_id = $7").
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+func (s *sqlitePresences) FetchPresence(ctx context.Context, jid *jid.JID) (*capsmodel.PresenceCaps, error) {
+	var rawXML, node, ver, featuresJSON string
+
+	q := sq.Select("presence", "p.node", "p.ver", "COALESCE(c.features, '')").
+		From("presences AS p").
+		LeftJoin("capabilities AS c ON p.node = c.node AND p.ver = c.ver").
+		Where(sq.And{
+			sq.Eq{"username": jid.Node()},
+			sq.Eq{"domain": jid.Domain()},
+			sq.Eq{"resource": jid.Resource()},
+		}).
+		RunWith(s.db)
+
+	err := q.ScanContext(ctx, &rawXML, &node, &ver, &featuresJSON)
+	switch err {
+	case nil:
+		return scanPresenceAndCapabilities(rawXML, node, ver, featuresJSON)
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (s *sqlitePresences) FetchPresencesMatchingJID(ctx context.Context, jid *jid.JID) ([]capsmodel.PresenceCaps, error) {
+	var preds sq.And
+	if len(jid.Node()) > 0 {
+		preds = append(preds, sq.Eq{"username": jid.Node()})
+	}
+	if len(jid.Domain()) > 0 {
+		preds = append(preds, sq.Eq{"domain": jid.Domain()})
+	}
+	if len(jid.Resource()) > 0 {
+		preds = append(preds, sq.Eq{"resource": jid.Resource()})
+	}
+	q := sq.Select("presence", "p.node", "p.ver", "COALESCE(c.features, '')").
+		From("presences AS p").
+		LeftJoin("capabilities AS c ON p.node = c.node AND p.ver = c.ver").
+		Where(preds).
+		RunWith(s.db)
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var res []capsmodel.PresenceCaps
+	for rows.Next() {
+		var rawXML, node, ver, featuresJSON string
+
+		if err := rows.Scan(&rawXML, &node, &ver, &featuresJSON); err != nil {
+			return nil, err
+		}
+		presenceCaps, err := scanPresenceAndCapabilities(rawXML, node, ver, featuresJSON)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *presenceCaps)
+	}
+	return res, nil
+}
+
+func scanPresenceAndCapabilities(rawXML, node, ver, featuresJSON string) (*capsmodel.PresenceCaps, error) {
+	parser := xmpp.NewParser(strings.NewReader(rawXML), xmpp.DefaultMode, 0)
+	elem, err := parser.ParseElement()
+	if err != nil {
+		return nil, err
+	}
+	fromJID, _ := jid.NewWithString(elem.From(), true)
+	toJID, _ := jid.NewWithString(elem.To(), true)
+
+	presence, err := xmpp.NewPresenceFromElement(elem, fromJID, toJID)
+	if err != nil {
+		return nil, err
+	}
+	var res capsmodel.PresenceCaps
+
+	res.Presence = presence
+	if len(featuresJSON) > 0 {
+		res.Caps = &capsmodel.Capabilities{
+			Node: node,
+			Ver:  ver,
+		}
+		if err := json.NewDecoder(strings.NewReader(featuresJSON)).Decode(&res.Caps.Features); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_InsertPrivateXML(t *testing.T) {
+	private := xmpp.NewElementNamespace("exodus", "exodus:ns")
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Private()
+
+	err := s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
+	require.Nil(t, err)
+}
+
+func TestSQLite_FetchPrivateXML(t *testing.T) {
+	private := xmpp.NewElementNamespace("exodus", "exodus:ns")
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Private()
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{private}, "exodus:ns", "sxmpp", "jackal.im")
+
+	elems, _ := s.FetchPrivateXML(context.Background(), "exodus:ns", "sxmpp", "jackal.im")
+	require.Equal(t, 1, len(elems))
+}
+
+func TestSQLite_FetchPrivateXMLNamespaces(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Private()
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "sxmpp", "jackal.im")
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("storage", "storage:bookmarks")}, "storage:bookmarks", "sxmpp", "jackal.im")
+	_ = s.UpsertPrivateXML(context.Background(), []xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "noelia", "jackal.im")
+
+	namespaces, err := s.FetchPrivateXMLNamespaces(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, []string{"exodus:ns", "storage:bookmarks"}, namespaces)
+}
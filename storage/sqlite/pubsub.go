@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	"github.com/sxmpp/jackal/storage/pgsql"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// sqlitePubSub reuses PostgreSQL pubsub repository, overriding those queries
+// not supported by SQLite.
+type sqlitePubSub struct {
+	repository.PubSub
+	db *sql.DB
+}
+
+func newPubSub(db *sql.DB) *sqlitePubSub {
+	return &sqlitePubSub{
+		PubSub: pgsql.NewPubSub(db),
+		db:     db,
+	}
+}
+
+func (s *sqlitePubSub) FetchNodes(ctx context.Context, host string) ([]pubsubmodel.Node, error) {
+	rows, err := sq.Select("host", "name").
+		From("pubsub_nodes").
+		Where(sq.Eq{"host": host}).
+		OrderBy("id").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetchNodes(ctx, rows)
+}
+
+func (s *sqlitePubSub) FetchSubscribedNodes(ctx context.Context, jid string) ([]pubsubmodel.Node, error) {
+	rows, err := sq.Select("host", "name").
+		From("pubsub_nodes").
+		Where(sq.Expr("id IN (SELECT DISTINCT(node_id) FROM pubsub_subscriptions WHERE jid = ? AND subscription = ?)", jid, pubsubmodel.Subscribed)).
+		OrderBy("id").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetchNodes(ctx, rows)
+}
+
+func (s *sqlitePubSub) UpsertNodeItem(ctx context.Context, item *pubsubmodel.Item, host, name string, maxNodeItems int) error {
+	return inTransaction(ctx, s.db, func(tx *sql.Tx) error {
+		// fetch node identifier
+		var nodeIdentifier int64
+
+		err := sq.Select("id").
+			From("pubsub_nodes").
+			Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+			RunWith(tx).QueryRowContext(ctx).Scan(&nodeIdentifier)
+		switch err {
+		case nil:
+			break
+		case sql.ErrNoRows:
+			return nil
+		default:
+			return err
+		}
+
+		// upsert new item
+		rawPayload := item.Payload.String()
+
+		_, err = sq.Insert("pubsub_items").
+			Columns("node_id", "item_id", "payload", "publisher").
+			Values(nodeIdentifier, item.ID, rawPayload, item.Publisher).
+			Suffix("ON CONFLICT (node_id, item_id) DO UPDATE SET payload = $5, publisher = $6", rawPayload, item.Publisher).
+			RunWith(tx).ExecContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		// check if maximum item count was reached and delete oldest one (OFFSET requires a LIMIT clause in SQLite)
+		_, err = sq.Delete("pubsub_items").
+			Where("node_id = $1 AND item_id IN (SELECT item_id FROM pubsub_items WHERE node_id = $1 ORDER BY created_at DESC LIMIT -1 OFFSET $2)", nodeIdentifier, maxNodeItems).
+			RunWith(tx).ExecContext(ctx)
+		return err
+	})
+}
+
+func (s *sqlitePubSub) FetchNodeItemsWithIDs(ctx context.Context, host, name string, identifiers []string) ([]pubsubmodel.Item, error) {
+	rows, err := sq.Select("item_id", "publisher", "payload").
+		From("pubsub_items").
+		Where(sq.And{
+			sq.Expr("node_id = (SELECT id FROM pubsub_nodes WHERE host = ? AND name = ?)", host, name),
+			sq.Eq{"item_id": identifiers},
+		}).
+		OrderBy("created_at").
+		RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []pubsubmodel.Item
+	for rows.Next() {
+		var payload string
+		var item pubsubmodel.Item
+		if err := rows.Scan(&item.ID, &item.Publisher, &payload); err != nil {
+			return nil, err
+		}
+		parser := xmpp.NewParser(strings.NewReader(payload), xmpp.DefaultMode, 0)
+		item.Payload, err = parser.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *sqlitePubSub) DeleteNodeAffiliation(ctx context.Context, jid, host, name string) error {
+	_, err := sq.Delete("pubsub_affiliations").
+		Where("jid = ? AND node_id = (SELECT id FROM pubsub_nodes WHERE host = ? AND name = ?)", jid, host, name).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+func (s *sqlitePubSub) DeleteNodeSubscription(ctx context.Context, jid, host, name string) error {
+	_, err := sq.Delete("pubsub_subscriptions").
+		Where("jid = ? AND node_id = (SELECT id FROM pubsub_nodes WHERE host = ? AND name = ?)", jid, host, name).
+		RunWith(s.db).ExecContext(ctx)
+	return err
+}
+
+// fetchNodes reads every node key from rows before fetching its options, given that
+// the single database connection can't be shared by two queries at the same time.
+func (s *sqlitePubSub) fetchNodes(ctx context.Context, rows *sql.Rows) ([]pubsubmodel.Node, error) {
+	var keys []pubsubmodel.Node
+	for rows.Next() {
+		var key pubsubmodel.Node
+		if err := rows.Scan(&key.Host, &key.Name); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	var nodes []pubsubmodel.Node
+	for _, key := range keys {
+		node, err := s.FetchNode(ctx, key.Host, key.Name)
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, *node)
+		}
+	}
+	return nodes, nil
+}
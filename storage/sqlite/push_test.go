@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	pushmodel "github.com/sxmpp/jackal/model/push"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_PushRegistrations(t *testing.T) {
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.Push()
+
+	reg1 := &pushmodel.Registration{Username: "juliet", Domain: "jackal.im", JID: "push.jackal.im", Node: "node1", Options: map[string]string{"secret": "s1"}}
+	reg2 := &pushmodel.Registration{Username: "juliet", Domain: "jackal.im", JID: "push.jackal.im", Node: "node2"}
+
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg1))
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg2))
+
+	reg1.Options = map[string]string{"secret": "s2"}
+	require.Nil(t, s.UpsertPushRegistration(context.Background(), reg1))
+
+	regs, err := s.FetchPushRegistrations(context.Background(), "juliet", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, regs, 2)
+	require.Equal(t, "s2", regs[0].Options["secret"])
+	require.Equal(t, "node2", regs[1].Node)
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "juliet", "jackal.im", "push.jackal.im", "node1"))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "juliet", "jackal.im")
+	require.Len(t, regs, 1)
+
+	require.Nil(t, s.DeletePushRegistrations(context.Background(), "juliet", "jackal.im", "push.jackal.im", ""))
+	regs, _ = s.FetchPushRegistrations(context.Background(), "juliet", "jackal.im")
+	require.Len(t, regs, 0)
+}
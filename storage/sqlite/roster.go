@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/storage/pgsql"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+// sqliteRoster reuses PostgreSQL roster repository, overriding those queries
+// not supported by SQLite.
+type sqliteRoster struct {
+	repository.Roster
+	db *sql.DB
+}
+
+func newRoster(db *sql.DB) *sqliteRoster {
+	return &sqliteRoster{
+		Roster: pgsql.NewRoster(db),
+		db:     db,
+	}
+}
+
+func (s *sqliteRoster) FetchRosterItemsInGroups(ctx context.Context, username, domain string, groups []string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "groups", "ask", "ver").
+		From("roster_items").
+		Where(sq.And{
+			sq.Eq{"username": username},
+			sq.Eq{"domain": domain},
+			sq.Expr(`jid IN (SELECT jid FROM roster_groups WHERE username = ? AND domain = ? AND "group" IN (`+sq.Placeholders(len(groups))+`))`,
+				append([]interface{}{username, domain}, stringsToInterfaces(groups)...)...),
+		}).
+		OrderBy("created_at DESC")
+
+	rows, err := q.RunWith(s.db).QueryContext(ctx)
+	if err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	var items []rostermodel.Item
+	for rows.Next() {
+		var ri rostermodel.Item
+		var groupsJSON string
+		if err := rows.Scan(&ri.Username, &ri.Domain, &ri.JID, &ri.Name, &ri.Subscription, &groupsJSON, &ri.Ask, &ri.Ver); err != nil {
+			_ = rows.Close()
+			return nil, rostermodel.Version{}, err
+		}
+		if len(groupsJSON) > 0 {
+			if err := json.NewDecoder(strings.NewReader(groupsJSON)).Decode(&ri.Groups); err != nil {
+				_ = rows.Close()
+				return nil, rostermodel.Version{}, err
+			}
+		}
+		items = append(items, ri)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	var ver rostermodel.Version
+	err = sq.Select("COALESCE(MAX(ver), 0)", "COALESCE(MAX(last_deletion_ver), 0)").
+		From("roster_versions").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		RunWith(s.db).QueryRowContext(ctx).Scan(&ver.Ver, &ver.DeletionVer)
+	if err != nil {
+		return nil, rostermodel.Version{}, err
+	}
+	return items, ver, nil
+}
+
+func stringsToInterfaces(ss []string) []interface{} {
+	ret := make([]interface{}, len(ss))
+	for i, s := range ss {
+		ret[i] = s
+	}
+	return ret
+}
@@ -0,0 +1,432 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+// schema contains SQLite database schema, mirroring PostgreSQL migrated one (storage/pgsql/migrations.go).
+// It's automatically applied every time storage is initialized.
+const schema = `
+-- users
+
+CREATE TABLE IF NOT EXISTS users (
+    username            VARCHAR(1023) NOT NULL,
+    domain              VARCHAR(1023) NOT NULL,
+    password            TEXT NOT NULL,
+    last_presence       TEXT NOT NULL DEFAULT '',
+    last_presence_at    DATETIME NOT NULL DEFAULT (NOW()),
+    email               TEXT NOT NULL DEFAULT '',
+    status              VARCHAR(16) NOT NULL DEFAULT 'active',
+    status_reason       TEXT NOT NULL DEFAULT '',
+    expires_at          DATETIME,
+    updated_at          DATETIME NOT NULL DEFAULT (NOW()),
+    created_at          DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain)
+);
+CREATE TRIGGER IF NOT EXISTS t_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- presences
+
+CREATE TABLE IF NOT EXISTS presences (
+    username      VARCHAR(1023) NOT NULL,
+    domain        VARCHAR(1023) NOT NULL,
+    resource      VARCHAR(1023) NOT NULL,
+    presence      TEXT NOT NULL,
+    node          VARCHAR(1023) NOT NULL,
+    ver           VARCHAR(1023) NOT NULL,
+    allocation_id VARCHAR(1023) NOT NULL,
+    updated_at    DATETIME NOT NULL DEFAULT (NOW()),
+    created_at    DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, resource)
+);
+CREATE INDEX IF NOT EXISTS i_presences_username_domain ON presences(username, domain);
+CREATE INDEX IF NOT EXISTS i_presences_domain_resource ON presences(domain, resource);
+CREATE INDEX IF NOT EXISTS i_presences_allocation_id ON presences(allocation_id);
+CREATE TRIGGER IF NOT EXISTS t_presences_updated_at AFTER UPDATE ON presences
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE presences SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- capabilities
+
+CREATE TABLE IF NOT EXISTS capabilities (
+    node       VARCHAR(1023) NOT NULL,
+    ver        VARCHAR(1023) NOT NULL,
+    features   TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT (NOW()),
+    created_at DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (node, ver)
+);
+CREATE TRIGGER IF NOT EXISTS t_capabilities_updated_at AFTER UPDATE ON capabilities
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE capabilities SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- roster_notifications
+
+CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact     VARCHAR(1023) NOT NULL,
+    domain      VARCHAR(1023) NOT NULL,
+    jid         TEXT NOT NULL,
+    elements    TEXT NOT NULL,
+    updated_at  DATETIME NOT NULL DEFAULT (NOW()),
+    created_at  DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (contact, domain, jid)
+);
+CREATE TRIGGER IF NOT EXISTS t_roster_notifications_updated_at AFTER UPDATE ON roster_notifications
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE roster_notifications SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- roster_items
+
+CREATE TABLE IF NOT EXISTS roster_items (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    subscription    TEXT NOT NULL,
+    groups          TEXT NOT NULL,
+    ask             BOOLEAN NOT NULL,
+    ver             INT NOT NULL DEFAULT 0,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, jid)
+);
+CREATE TRIGGER IF NOT EXISTS t_roster_items_updated_at AFTER UPDATE ON roster_items
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE roster_items SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- roster_groups
+
+CREATE TABLE IF NOT EXISTS roster_groups (
+    username     VARCHAR(1023) NOT NULL,
+    domain       VARCHAR(1023) NOT NULL,
+    jid          TEXT NOT NULL,
+    "group"      TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (NOW()),
+    created_at   DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, jid, "group")
+);
+CREATE TRIGGER IF NOT EXISTS t_roster_groups_updated_at AFTER UPDATE ON roster_groups
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE roster_groups SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- roster_versions
+
+CREATE TABLE IF NOT EXISTS roster_versions (
+    username            VARCHAR(1023) NOT NULL,
+    domain              VARCHAR(1023) NOT NULL,
+    ver                 INT NOT NULL DEFAULT 0,
+    last_deletion_ver   INT NOT NULL DEFAULT 0,
+    updated_at          DATETIME NOT NULL DEFAULT (NOW()),
+    created_at          DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain)
+);
+CREATE TRIGGER IF NOT EXISTS t_roster_versions_updated_at AFTER UPDATE ON roster_versions
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE roster_versions SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- blocklist_items
+
+CREATE TABLE IF NOT EXISTS blocklist_items (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, jid)
+);
+
+-- private_storage
+
+CREATE TABLE IF NOT EXISTS private_storage (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    namespace       VARCHAR(512) NOT NULL,
+    data            TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, namespace)
+);
+CREATE TRIGGER IF NOT EXISTS t_private_storage_updated_at AFTER UPDATE ON private_storage
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE private_storage SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- vcards
+
+CREATE TABLE IF NOT EXISTS vcards (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    vcard           TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain)
+);
+CREATE TRIGGER IF NOT EXISTS t_vcards_updated_at AFTER UPDATE ON vcards
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE vcards SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- offline_messages
+
+CREATE TABLE IF NOT EXISTS offline_messages (
+    id              VARCHAR(64) NOT NULL DEFAULT '',
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    data            TEXT NOT NULL,
+    size            INTEGER NOT NULL DEFAULT 0,
+    expires_at      DATETIME,
+    created_at      DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_offline_messages_username_domain ON offline_messages(username, domain);
+
+-- archive_messages
+
+CREATE TABLE IF NOT EXISTS archive_messages (
+    serial          INTEGER PRIMARY KEY AUTOINCREMENT,
+    id              VARCHAR(64) NOT NULL,
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    peer            TEXT NOT NULL,
+    bare_peer       TEXT NOT NULL,
+    data            TEXT NOT NULL,
+    stamp           DATETIME NOT NULL,
+    created_at      DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_archive_messages_username_domain_serial ON archive_messages(username, domain, serial);
+CREATE INDEX IF NOT EXISTS i_archive_messages_username_domain_stamp ON archive_messages(username, domain, stamp);
+CREATE INDEX IF NOT EXISTS i_archive_messages_id ON archive_messages(id);
+CREATE INDEX IF NOT EXISTS i_archive_messages_stamp ON archive_messages(stamp);
+
+-- archive_preferences
+
+CREATE TABLE IF NOT EXISTS archive_preferences (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    default_mode    VARCHAR(16) NOT NULL,
+    always_jids     TEXT NOT NULL,
+    never_jids      TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain)
+);
+CREATE TRIGGER IF NOT EXISTS t_archive_preferences_updated_at AFTER UPDATE ON archive_preferences
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE archive_preferences SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- push_registrations
+
+CREATE TABLE IF NOT EXISTS push_registrations (
+    username        VARCHAR(1023) NOT NULL,
+    domain          VARCHAR(1023) NOT NULL,
+    jid             TEXT NOT NULL,
+    node            VARCHAR(1023) NOT NULL,
+    options         TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain, jid, node)
+);
+CREATE TRIGGER IF NOT EXISTS t_push_registrations_updated_at AFTER UPDATE ON push_registrations
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE push_registrations SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- muc_rooms
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    jid             TEXT NOT NULL,
+    service         VARCHAR(1023) NOT NULL,
+    config          TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    affiliations    TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (jid)
+);
+CREATE INDEX IF NOT EXISTS i_muc_rooms_service ON muc_rooms(service);
+CREATE TRIGGER IF NOT EXISTS t_muc_rooms_updated_at AFTER UPDATE ON muc_rooms
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE muc_rooms SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- muc_nick_reservations
+
+CREATE TABLE IF NOT EXISTS muc_nick_reservations (
+    room_jid        TEXT NOT NULL,
+    jid             TEXT NOT NULL,
+    nick            VARCHAR(1023) NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (room_jid, jid)
+);
+CREATE TRIGGER IF NOT EXISTS t_muc_nick_reservations_updated_at AFTER UPDATE ON muc_nick_reservations
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE muc_nick_reservations SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- pubsub_nodes
+
+CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    host            TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_nodes_host_name ON pubsub_nodes(host, name);
+CREATE TRIGGER IF NOT EXISTS t_pubsub_nodes_updated_at AFTER UPDATE ON pubsub_nodes
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pubsub_nodes SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- pubsub_node_options
+
+CREATE TABLE IF NOT EXISTS pubsub_node_options (
+    node_id         BIGINT NOT NULL,
+    name            TEXT NOT NULL,
+    value           TEXT NOT NULL,
+    updated_at      DATETIME NOT NULL DEFAULT (NOW()),
+    created_at      DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_pubsub_node_options_node_id ON pubsub_node_options(node_id);
+CREATE TRIGGER IF NOT EXISTS t_pubsub_node_options_updated_at AFTER UPDATE ON pubsub_node_options
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pubsub_node_options SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- pubsub_affiliations
+
+CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    node_id          BIGINT NOT NULL,
+    jid              TEXT NOT NULL,
+    affiliation      TEXT NOT NULL,
+    updated_at       DATETIME NOT NULL DEFAULT (NOW()),
+    created_at       DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_pubsub_affiliations_jid ON pubsub_affiliations(jid);
+CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_affiliations_node_id_jid ON pubsub_affiliations(node_id, jid);
+CREATE TRIGGER IF NOT EXISTS t_pubsub_affiliations_updated_at AFTER UPDATE ON pubsub_affiliations
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pubsub_affiliations SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- pubsub_subscriptions
+
+CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    node_id          BIGINT NOT NULL,
+    subid            TEXT NOT NULL,
+    jid              TEXT NOT NULL,
+    subscription     TEXT NOT NULL,
+    updated_at       DATETIME NOT NULL DEFAULT (NOW()),
+    created_at       DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_pubsub_subscriptions_jid ON pubsub_subscriptions(jid);
+CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_subscriptions_node_id_jid ON pubsub_subscriptions(node_id, jid);
+CREATE TRIGGER IF NOT EXISTS t_pubsub_subscriptions_updated_at AFTER UPDATE ON pubsub_subscriptions
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pubsub_subscriptions SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- pubsub_items
+
+CREATE TABLE IF NOT EXISTS pubsub_items (
+    node_id          BIGINT NOT NULL,
+    item_id          TEXT NOT NULL,
+    payload          TEXT NOT NULL,
+    publisher        TEXT NOT NULL,
+    updated_at       DATETIME NOT NULL DEFAULT (NOW()),
+    created_at       DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE INDEX IF NOT EXISTS i_pubsub_items_item_id ON pubsub_items(item_id);
+CREATE UNIQUE INDEX IF NOT EXISTS i_pubsub_items_node_id_item_id ON pubsub_items(node_id, item_id);
+CREATE TRIGGER IF NOT EXISTS t_pubsub_items_updated_at AFTER UPDATE ON pubsub_items
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE pubsub_items SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+
+-- hosts
+
+CREATE TABLE IF NOT EXISTS hosts (
+    name             VARCHAR(1023) PRIMARY KEY,
+    certificate      TEXT NOT NULL,
+    private_key      TEXT NOT NULL,
+    enabled          BOOLEAN NOT NULL,
+    updated_at       DATETIME NOT NULL DEFAULT (NOW()),
+    created_at       DATETIME NOT NULL DEFAULT (NOW())
+);
+CREATE TRIGGER IF NOT EXISTS t_hosts_updated_at AFTER UPDATE ON hosts
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE hosts SET updated_at = NOW() WHERE rowid = NEW.rowid;
+END;
+`
+
+// schemaColumns contains those columns added after the initial schema release. Since SQLite lacks
+// an 'ADD COLUMN IF NOT EXISTS' statement, they're added to previously created tables on storage initialization.
+var schemaColumns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"users", "email", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "status", "VARCHAR(16) NOT NULL DEFAULT 'active'"},
+	{"users", "status_reason", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "expires_at", "DATETIME"},
+	{"offline_messages", "id", "VARCHAR(64) NOT NULL DEFAULT ''"},
+	{"offline_messages", "size", "INTEGER NOT NULL DEFAULT 0"},
+	{"offline_messages", "expires_at", "DATETIME"},
+}
+
+// schemaIndexes contains those indexes referencing columns within schemaColumns.
+const schemaIndexes = `
+CREATE INDEX IF NOT EXISTS i_users_status_expires_at ON users(status, expires_at);
+CREATE INDEX IF NOT EXISTS i_offline_messages_username_domain_id ON offline_messages(username, domain, id);
+CREATE INDEX IF NOT EXISTS i_offline_messages_expires_at ON offline_messages(expires_at);
+`
+
+// schemaBackfills populates schemaColumns values of those rows inserted before they were added.
+const schemaBackfills = `
+UPDATE offline_messages SET id = lower(hex(randomblob(16))) WHERE id = '';
+UPDATE offline_messages SET size = length(CAST(data AS BLOB)) WHERE size = 0;
+`
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/sxmpp/jackal/storage/pgsql"
+	"github.com/sxmpp/jackal/storage/repository"
+)
+
+const driverName = "sqlite3_jackal"
+
+// timeLayout is a fixed width layout, so that stored timestamps sort lexicographically.
+const timeLayout = "2006-01-02 15:04:05.000000000"
+
+var registerDriverOnce sync.Once
+
+type sqliteContainer struct {
+	user      repository.User
+	roster    *sqliteRoster
+	presences *sqlitePresences
+	vCard     repository.VCard
+	priv      repository.Private
+	blockList repository.BlockList
+	pubSub    *sqlitePubSub
+	offline   repository.Offline
+	archive   repository.Archive
+	push      repository.Push
+	muc       repository.Muc
+	host      repository.Host
+
+	h *sql.DB
+}
+
+// New initializes SQLite storage and returns associated container.
+func New(cfg *Config) (repository.Container, error) {
+	registerDriverOnce.Do(registerDriver)
+
+	// SQLite storage shares PostgreSQL dialect
+	sq.StatementBuilder = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_foreign_keys=1", cfg.Path)
+	h, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, so every statement is funneled through a unique
+	// connection in order to serialize writes and avoid 'database is locked' errors.
+	h.SetMaxOpenConns(1)
+
+	if _, err := h.Exec(schema); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	if err := upgradeSchema(h); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	c := &sqliteContainer{h: h}
+
+	c.user = pgsql.NewUser(h)
+	c.roster = newRoster(h)
+	c.presences = newPresences(h)
+	c.vCard = pgsql.NewVCard(h)
+	c.priv = pgsql.NewPrivate(h)
+	c.blockList = pgsql.NewBlockList(h)
+	c.pubSub = newPubSub(h)
+	c.offline = pgsql.NewOffline(h)
+	c.archive = pgsql.NewArchive(h)
+	c.push = pgsql.NewPush(h)
+	c.muc = pgsql.NewMuc(h)
+	c.host = pgsql.NewHost(h)
+
+	return c, nil
+}
+
+func (c *sqliteContainer) User() repository.User           { return c.user }
+func (c *sqliteContainer) Roster() repository.Roster       { return c.roster }
+func (c *sqliteContainer) Presences() repository.Presences { return c.presences }
+func (c *sqliteContainer) VCard() repository.VCard         { return c.vCard }
+func (c *sqliteContainer) Private() repository.Private     { return c.priv }
+func (c *sqliteContainer) BlockList() repository.BlockList { return c.blockList }
+func (c *sqliteContainer) PubSub() repository.PubSub       { return c.pubSub }
+func (c *sqliteContainer) Offline() repository.Offline     { return c.offline }
+func (c *sqliteContainer) Archive() repository.Archive     { return c.archive }
+func (c *sqliteContainer) Push() repository.Push           { return c.push }
+func (c *sqliteContainer) Muc() repository.Muc             { return c.muc }
+func (c *sqliteContainer) Host() repository.Host           { return c.host }
+
+func (c *sqliteContainer) Close(_ context.Context) error { return c.h.Close() }
+
+func (c *sqliteContainer) IsClusterCompatible() bool { return false }
+
+// upgradeSchema adds to existing tables every column introduced after they were created.
+func upgradeSchema(h *sql.DB) error {
+	for _, sc := range schemaColumns {
+		var count int
+		if err := h.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", sc.table, sc.column).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := h.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", sc.table, sc.column, sc.definition)); err != nil {
+			return err
+		}
+	}
+	if _, err := h.Exec(schemaIndexes); err != nil {
+		return err
+	}
+	_, err := h.Exec(schemaBackfills)
+	return err
+}
+
+func registerDriver() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// provide PostgreSQL NOW() function
+			return conn.RegisterFunc("now", now, false)
+		},
+	})
+}
+
+func now() string {
+	return time.Now().UTC().Format(timeLayout)
+}
+
+func inTransaction(ctx context.Context, db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, txErr := db.BeginTx(ctx, nil)
+	if txErr != nil {
+		return txErr
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_Container(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "jackal_sqlite")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	dbPath := filepath.Join(dataDir, "jackal.db")
+
+	c, err := New(&Config{Path: dbPath})
+	require.Nil(t, err)
+	require.False(t, c.IsClusterCompatible())
+
+	require.Nil(t, c.User().UpsertUser(context.Background(), &model.User{Username: "sxmpp", Domain: "jackal.im", Password: "1234"}))
+	require.Nil(t, c.Close(context.Background()))
+
+	// schema creation must be idempotent and data must persist across restarts
+	c, err = New(&Config{Path: dbPath})
+	require.Nil(t, err)
+	defer func() { _ = c.Close(context.Background()) }()
+
+	usr, err := c.User().FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+}
+
+func TestSQLite_SchemaUpgrade(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "jackal_sqlite")
+	require.Nil(t, err)
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	dbPath := filepath.Join(dataDir, "jackal.db")
+
+	// users table as created by a previous release
+	registerDriverOnce.Do(registerDriver)
+	h, err := sql.Open(driverName, dbPath)
+	require.Nil(t, err)
+	_, err = h.Exec(`CREATE TABLE users (
+    username            VARCHAR(1023) NOT NULL,
+    domain              VARCHAR(1023) NOT NULL,
+    password            TEXT NOT NULL,
+    last_presence       TEXT NOT NULL DEFAULT '',
+    last_presence_at    DATETIME NOT NULL DEFAULT (NOW()),
+    updated_at          DATETIME NOT NULL DEFAULT (NOW()),
+    created_at          DATETIME NOT NULL DEFAULT (NOW()),
+
+    PRIMARY KEY (username, domain)
+);
+INSERT INTO users (username, domain, password) VALUES ('sxmpp', 'jackal.im', '1234');`)
+	require.Nil(t, err)
+	require.Nil(t, h.Close())
+
+	c, err := New(&Config{Path: dbPath})
+	require.Nil(t, err)
+	defer func() { _ = c.Close(context.Background()) }()
+
+	usr, err := c.User().FetchUser(context.Background(), "sxmpp", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+	require.Equal(t, model.UserActive, usr.Status)
+	require.False(t, usr.IsDisabled())
+}
+
+func tUtilSQLiteSetup(t *testing.T) repository.Container {
+	c, err := New(&Config{Path: ":memory:"})
+	require.Nil(t, err)
+	return c
+}
+
+func tUtilSQLiteTeardown(c repository.Container) {
+	_ = c.Close(context.Background())
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite_InsertVCard(t *testing.T) {
+	vCard := xmpp.NewElementName("vCard")
+	fn := xmpp.NewElementName("FN")
+	fn.SetText("Miguel Ángel")
+	vCard.AppendElement(fn)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.VCard()
+	require.Nil(t, s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im"))
+}
+
+func TestSQLite_FetchVCard(t *testing.T) {
+	vCard := xmpp.NewElementName("vCard")
+	fn := xmpp.NewElementName("FN")
+	fn.SetText("Miguel Ángel")
+	vCard.AppendElement(fn)
+
+	h := tUtilSQLiteSetup(t)
+	defer tUtilSQLiteTeardown(h)
+
+	s := h.VCard()
+	_ = s.UpsertVCard(context.Background(), vCard, "sxmpp", "jackal.im")
+
+	elem, _ := s.FetchVCard(context.Background(), "sxmpp", "jackal.im")
+	require.NotNil(t, elem)
+}
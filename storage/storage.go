@@ -8,14 +8,40 @@ package storage
 import (
 	"fmt"
 
+	"github.com/sxmpp/jackal/storage/badgerdb"
+	"github.com/sxmpp/jackal/storage/cached"
+	"github.com/sxmpp/jackal/storage/encrypted"
 	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/storage/migration"
 	"github.com/sxmpp/jackal/storage/mysql"
 	"github.com/sxmpp/jackal/storage/pgsql"
 	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/storage/sqlite"
 )
 
 // New initializes configured storage type and returns associated container.
+// In case caching is configured, returned container will be a *cached.Container instance, unless encryption
+// is also configured. In such case returned container will be an *encrypted.Container instance wrapping
+// the cached one, so that cached entries are kept encrypted as well.
 func New(config *Config) (repository.Container, error) {
+	c, err := newContainer(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Cache != nil {
+		c = cached.New(c, config.Cache)
+	}
+	if config.Encryption != nil {
+		ec, err := encrypted.New(c, config.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		return ec, nil
+	}
+	return c, nil
+}
+
+func newContainer(config *Config) (repository.Container, error) {
 	switch config.Type {
 	case MySQL:
 		return mysql.New(config.MySQL)
@@ -23,7 +49,23 @@ func New(config *Config) (repository.Container, error) {
 		return pgsql.New(config.PostgreSQL)
 	case Memory:
 		return memorystorage.New()
+	case BadgerDB:
+		return badgerdb.New(config.BadgerDB)
+	case SQLite:
+		return sqlite.New(config.SQLite)
 	default:
 		return nil, fmt.Errorf("storage: unrecognized storage type: %d", config.Type)
 	}
 }
+
+// NewMigrator returns a schema migrator for configured storage type.
+func NewMigrator(config *Config) (*migration.Migrator, error) {
+	switch config.Type {
+	case MySQL:
+		return mysql.NewMigrator(config.MySQL)
+	case PostgreSQL:
+		return pgsql.NewMigrator(config.PostgreSQL)
+	default:
+		return nil, fmt.Errorf("storage: %s storage type doesn't support schema migrations", config.Type)
+	}
+}
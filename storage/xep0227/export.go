@@ -0,0 +1,265 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0227
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+)
+
+// Export writes a XEP-0227 document containing every user account registered under the given domains.
+func Export(ctx context.Context, w io.Writer, rep repository.Container, domains ...string) error {
+	if err := writeStartServerData(w); err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		usernames, err := rep.User().FetchUsernames(ctx, domain)
+		if err != nil {
+			return err
+		}
+		if err := writeHost(ctx, w, rep, domain, usernames); err != nil {
+			return err
+		}
+	}
+	return writeEndServerData(w)
+}
+
+// ExportUser writes a XEP-0227 document containing a single user account.
+func ExportUser(ctx context.Context, w io.Writer, rep repository.Container, username, domain string) error {
+	exists, err := rep.User().UserExists(ctx, username, domain)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("xep0227: user %s@%s not found", username, domain)
+	}
+	if err := writeStartServerData(w); err != nil {
+		return err
+	}
+	if err := writeHost(ctx, w, rep, domain, []string{username}); err != nil {
+		return err
+	}
+	return writeEndServerData(w)
+}
+
+func writeStartServerData(w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header+`<`+serverDataElementName+` xmlns="`+pieNamespace+`">`)
+	return err
+}
+
+func writeEndServerData(w io.Writer) error {
+	_, err := io.WriteString(w, `</`+serverDataElementName+`>`+"\n")
+	return err
+}
+
+func writeHost(ctx context.Context, w io.Writer, rep repository.Container, domain string, usernames []string) error {
+	if _, err := io.WriteString(w, `<`+hostElementName+` jid="`+escapeAttr(domain)+`">`); err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		if err := writeUser(ctx, w, rep, username, domain); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, `</`+hostElementName+`>`)
+	return err
+}
+
+func writeUser(ctx context.Context, w io.Writer, rep repository.Container, username, domain string) error {
+	usr, err := rep.User().FetchUser(ctx, username, domain)
+	if err != nil {
+		return err
+	}
+	if usr == nil {
+		return nil // deleted while exporting
+	}
+	elems, err := userElements(ctx, rep, username, domain)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `<`+userElementName+` name="`+escapeAttr(usr.Username)+`" password="`+escapeAttr(usr.Password)+`">`); err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		if err := elem.ToXML(w, true); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, `</`+userElementName+`>`)
+	return err
+}
+
+func userElements(ctx context.Context, rep repository.Container, username, domain string) ([]xmpp.XElement, error) {
+	var elems []xmpp.XElement
+
+	// roster
+	ris, _, err := rep.Roster().FetchRosterItems(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(ris) > 0 {
+		query := xmpp.NewElementNamespace("query", rosterNamespace)
+		for _, ri := range ris {
+			query.AppendElement(ri.Element())
+		}
+		elems = append(elems, query)
+	}
+	// pending subscription requests
+	rns, err := rep.Roster().FetchRosterNotifications(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, rn := range rns {
+		if rn.Presence != nil {
+			elems = append(elems, rn.Presence)
+		}
+	}
+	// vCard
+	vCard, err := rep.VCard().FetchVCard(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if vCard != nil {
+		elems = append(elems, vCard)
+	}
+	// private XML
+	namespaces, err := rep.Private().FetchPrivateXMLNamespaces(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(namespaces) > 0 {
+		query := xmpp.NewElementNamespace("query", privateNamespace)
+		for _, ns := range namespaces {
+			privElems, err := rep.Private().FetchPrivateXML(ctx, ns, username, domain)
+			if err != nil {
+				return nil, err
+			}
+			query.AppendElements(privElems)
+		}
+		elems = append(elems, query)
+	}
+	// block list
+	blItems, err := rep.BlockList().FetchBlockListItems(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(blItems) > 0 {
+		blockList := xmpp.NewElementNamespace("blocklist", blockListNamespace)
+		for _, blItem := range blItems {
+			blockList.AppendElement(xmpp.NewElementName("item").SetAttribute("jid", blItem.JID))
+		}
+		elems = append(elems, blockList)
+	}
+	// offline messages
+	messages, err := rep.Offline().FetchOfflineMessages(ctx, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) > 0 {
+		offline := xmpp.NewElementName(offlineMessagesElementName)
+		for i := range messages {
+			offline.AppendElement(messages[i].Message)
+		}
+		elems = append(elems, offline)
+	}
+	// PEP nodes
+	pepElems, err := pepElements(ctx, rep, username, domain)
+	if err != nil {
+		return nil, err
+	}
+	return append(elems, pepElems...), nil
+}
+
+func pepElements(ctx context.Context, rep repository.Container, username, domain string) ([]xmpp.XElement, error) {
+	userJID := username + "@" + domain
+	nodes, err := rep.PubSub().FetchNodes(ctx, userJID)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	owner := xmpp.NewElementNamespace("pubsub", pubSubOwnerNamespace)
+	pubSub := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+
+	for _, n := range nodes {
+		// configuration
+		owner.AppendElement(xmpp.NewElementName("configure").
+			SetAttribute("node", n.Name).
+			AppendElement(optionsSubmitForm(&n.Options).Element()))
+
+		// affiliations
+		affiliations, err := rep.PubSub().FetchNodeAffiliations(ctx, userJID, n.Name)
+		if err != nil {
+			return nil, err
+		}
+		affiliationsEl := xmpp.NewElementName("affiliations").SetAttribute("node", n.Name)
+		for _, aff := range affiliations {
+			affiliationsEl.AppendElement(xmpp.NewElementName("affiliation").
+				SetAttribute("jid", aff.JID).
+				SetAttribute("affiliation", aff.Affiliation))
+		}
+		owner.AppendElement(affiliationsEl)
+
+		// subscriptions
+		subscriptions, err := rep.PubSub().FetchNodeSubscriptions(ctx, userJID, n.Name)
+		if err != nil {
+			return nil, err
+		}
+		subscriptionsEl := xmpp.NewElementName("subscriptions").SetAttribute("node", n.Name)
+		for _, sub := range subscriptions {
+			subscriptionsEl.AppendElement(xmpp.NewElementName("subscription").
+				SetAttribute("jid", sub.JID).
+				SetAttribute("subscription", sub.Subscription).
+				SetAttribute("subid", sub.SubID))
+		}
+		owner.AppendElement(subscriptionsEl)
+
+		// items
+		items, err := rep.PubSub().FetchNodeItems(ctx, userJID, n.Name)
+		if err != nil {
+			return nil, err
+		}
+		itemsEl := xmpp.NewElementName("items").SetAttribute("node", n.Name)
+		for _, itm := range items {
+			itemEl := xmpp.NewElementName("item").
+				SetAttribute("id", itm.ID).
+				SetAttribute("publisher", itm.Publisher)
+			if itm.Payload != nil {
+				itemEl.AppendElement(itm.Payload)
+			}
+			itemsEl.AppendElement(itemEl)
+		}
+		pubSub.AppendElement(itemsEl)
+	}
+	return []xmpp.XElement{owner, pubSub}, nil
+}
+
+func optionsSubmitForm(opts *pubsubmodel.Options) *xep0004.DataForm {
+	submit := &xep0004.DataForm{Type: xep0004.Submit}
+	for _, field := range opts.Form(nil).Fields {
+		if field.Var == xep0004.FormType {
+			submit.Fields = append(submit.Fields, field)
+			continue
+		}
+		submit.Fields = append(submit.Fields, xep0004.Field{Var: field.Var, Values: field.Values})
+	}
+	return submit
+}
+
+func escapeAttr(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
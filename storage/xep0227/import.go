@@ -0,0 +1,319 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0227
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/sxmpp/jackal/log"
+	"github.com/sxmpp/jackal/model"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	"github.com/sxmpp/jackal/module/xep0004"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+// ImportOptions defines XEP-0227 import behavior.
+type ImportOptions struct {
+	// SkipPasswordless makes import skip new accounts lacking a password, instead of failing.
+	SkipPasswordless bool
+}
+
+// Import reads a XEP-0227 document and stores every contained user account, returning the number
+// of imported accounts. Already existing accounts get their credentials replaced and the rest of their data merged.
+// Accounts not carrying a password keep their current credentials, while new ones are refused unless
+// SkipPasswordless option is set, in which case they're not imported at all.
+func Import(ctx context.Context, r io.Reader, rep repository.Container, opts ImportOptions) (int, error) {
+	serverData, err := parseServerData(r)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, host := range serverData.Elements().Children(hostElementName) {
+		domain := host.Attributes().Get("jid")
+		if len(domain) == 0 {
+			return count, fmt.Errorf("xep0227: host 'jid' attribute is required")
+		}
+		for _, user := range host.Elements().Children(userElementName) {
+			imported, err := importUser(ctx, rep, user, domain, &opts)
+			if err != nil {
+				return count, err
+			}
+			if imported {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func parseServerData(r io.Reader) (xmpp.XElement, error) {
+	p := xmpp.NewParser(r, xmpp.DefaultMode, 0)
+	for {
+		elem, err := p.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			continue // skip XML declaration
+		}
+		if elem.Name() != serverDataElementName || elem.Namespace() != pieNamespace {
+			return nil, fmt.Errorf("xep0227: unexpected root element: %s", elem.Name())
+		}
+		return elem, nil
+	}
+}
+
+func importUser(ctx context.Context, rep repository.Container, user xmpp.XElement, domain string, opts *ImportOptions) (bool, error) {
+	username := user.Attributes().Get("name")
+	if len(username) == 0 {
+		return false, fmt.Errorf("xep0227: user 'name' attribute is required")
+	}
+	userJID, err := jid.New(username, domain, "", false)
+	if err != nil {
+		return false, err
+	}
+	// preserve already existing account status
+	usr, err := rep.User().FetchUser(ctx, userJID.Node(), userJID.Domain())
+	if err != nil {
+		return false, err
+	}
+	// password is optional (eg. SCRAM only exports)... keep current one, if any
+	password := user.Attributes().Get("password")
+	if usr == nil {
+		if len(password) == 0 {
+			if opts.SkipPasswordless {
+				log.Warnf("xep0227: skipping user %s with no password", userJID)
+				return false, nil
+			}
+			return false, fmt.Errorf("xep0227: user %s has no password", userJID)
+		}
+		usr = &model.User{Username: userJID.Node(), Domain: userJID.Domain()}
+	}
+	if len(password) > 0 {
+		usr.Password = password
+	}
+	if err := rep.User().UpsertUser(ctx, usr); err != nil {
+		return false, err
+	}
+	username, domain = userJID.Node(), userJID.Domain()
+
+	for _, elem := range user.Elements().All() {
+		switch {
+		case elem.Name() == "query" && elem.Namespace() == rosterNamespace:
+			err = importRoster(ctx, rep, elem, username, domain)
+		case elem.Name() == "presence":
+			err = importRosterNotification(ctx, rep, elem, username, domain)
+		case elem.Name() == "vCard" && elem.Namespace() == vCardNamespace:
+			err = rep.VCard().UpsertVCard(ctx, elem, username, domain)
+		case elem.Name() == "query" && elem.Namespace() == privateNamespace:
+			err = importPrivateXML(ctx, rep, elem, username, domain)
+		case elem.Name() == "blocklist" && elem.Namespace() == blockListNamespace:
+			err = importBlockList(ctx, rep, elem, username, domain)
+		case elem.Name() == offlineMessagesElementName:
+			err = importOfflineMessages(ctx, rep, elem, username, domain)
+		case elem.Name() == "pubsub" && elem.Namespace() == pubSubOwnerNamespace:
+			err = importPEPNodes(ctx, rep, elem, userJID.String())
+		case elem.Name() == "pubsub" && elem.Namespace() == pubSubNamespace:
+			err = importPEPItems(ctx, rep, elem, userJID.String())
+		default:
+			log.Warnf("xep0227: ignoring unsupported element %s (%s) for user %s", elem.Name(), elem.Namespace(), userJID)
+		}
+		if err != nil {
+			return false, fmt.Errorf("xep0227: %s: %v", userJID, err)
+		}
+	}
+	return true, nil
+}
+
+func importRoster(ctx context.Context, rep repository.Container, query xmpp.XElement, username, domain string) error {
+	for _, itm := range query.Elements().Children("item") {
+		ri, err := rostermodel.NewItem(itm)
+		if err != nil {
+			return err
+		}
+		if len(ri.Subscription) == 0 {
+			ri.Subscription = rostermodel.SubscriptionNone
+		}
+		ri.Username = username
+		ri.Domain = domain
+		if _, err := rep.Roster().UpsertRosterItem(ctx, ri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importRosterNotification(ctx context.Context, rep repository.Container, elem xmpp.XElement, username, domain string) error {
+	if elem.Type() != xmpp.SubscribeType {
+		return nil
+	}
+	fromJID, err := jid.NewWithString(elem.From(), false)
+	if err != nil {
+		return err
+	}
+	toJID, err := jid.New(username, domain, "", true)
+	if err != nil {
+		return err
+	}
+	presence, err := xmpp.NewPresenceFromElement(elem, fromJID, toJID)
+	if err != nil {
+		return err
+	}
+	return rep.Roster().UpsertRosterNotification(ctx, &rostermodel.Notification{
+		Contact:  username,
+		Domain:   domain,
+		JID:      fromJID.ToBareJID().String(),
+		Presence: presence,
+	})
+}
+
+func importPrivateXML(ctx context.Context, rep repository.Container, query xmpp.XElement, username, domain string) error {
+	var namespaces []string
+	elems := make(map[string][]xmpp.XElement)
+	for _, elem := range query.Elements().All() {
+		ns := elem.Namespace()
+		if len(ns) == 0 {
+			continue
+		}
+		if _, ok := elems[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+		elems[ns] = append(elems[ns], elem)
+	}
+	for _, ns := range namespaces {
+		if err := rep.Private().UpsertPrivateXML(ctx, elems[ns], ns, username, domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importBlockList(ctx context.Context, rep repository.Container, blockList xmpp.XElement, username, domain string) error {
+	for _, itm := range blockList.Elements().Children("item") {
+		j, err := jid.NewWithString(itm.Attributes().Get("jid"), false)
+		if err != nil {
+			return err
+		}
+		err = rep.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{
+			Username: username,
+			Domain:   domain,
+			JID:      j.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importOfflineMessages(ctx context.Context, rep repository.Container, offline xmpp.XElement, username, domain string) error {
+	for _, elem := range offline.Elements().Children("message") {
+		fromJID, err := jid.NewWithString(elem.From(), false)
+		if err != nil {
+			return err
+		}
+		toJID, err := jid.NewWithString(elem.To(), false)
+		if err != nil {
+			return err
+		}
+		message, err := xmpp.NewMessageFromElement(elem, fromJID, toJID)
+		if err != nil {
+			return err
+		}
+		if err := rep.Offline().InsertOfflineMessage(ctx, &offlinemodel.Message{ID: uuid.New(), Message: message}, username, domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importPEPNodes(ctx context.Context, rep repository.Container, pubSub xmpp.XElement, host string) error {
+	for _, configure := range pubSub.Elements().Children("configure") {
+		nodeID := configure.Attributes().Get("node")
+		x := configure.Elements().ChildNamespace("x", xep0004.FormNamespace)
+		if len(nodeID) == 0 || x == nil {
+			return fmt.Errorf("malformed node configuration")
+		}
+		form, err := xep0004.NewFormFromElement(x)
+		if err != nil {
+			return err
+		}
+		opts, err := pubsubmodel.NewOptionsFromSubmitForm(form)
+		if err != nil {
+			return err
+		}
+		err = rep.PubSub().UpsertNode(ctx, &pubsubmodel.Node{
+			Host:    host,
+			Name:    nodeID,
+			Options: *opts,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, affiliations := range pubSub.Elements().Children("affiliations") {
+		nodeID := affiliations.Attributes().Get("node")
+		for _, aff := range affiliations.Elements().Children("affiliation") {
+			err := rep.PubSub().UpsertNodeAffiliation(ctx, &pubsubmodel.Affiliation{
+				JID:         aff.Attributes().Get("jid"),
+				Affiliation: aff.Attributes().Get("affiliation"),
+			}, host, nodeID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, subscriptions := range pubSub.Elements().Children("subscriptions") {
+		nodeID := subscriptions.Attributes().Get("node")
+		for _, sub := range subscriptions.Elements().Children("subscription") {
+			err := rep.PubSub().UpsertNodeSubscription(ctx, &pubsubmodel.Subscription{
+				SubID:        sub.Attributes().Get("subid"),
+				JID:          sub.Attributes().Get("jid"),
+				Subscription: sub.Attributes().Get("subscription"),
+			}, host, nodeID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func importPEPItems(ctx context.Context, rep repository.Container, pubSub xmpp.XElement, host string) error {
+	for _, items := range pubSub.Elements().Children("items") {
+		nodeID := items.Attributes().Get("node")
+		node, err := rep.PubSub().FetchNode(ctx, host, nodeID)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			log.Warnf("xep0227: ignoring items for unconfigured node %s (%s)", nodeID, host)
+			continue
+		}
+		for _, itm := range items.Elements().Children("item") {
+			var payload xmpp.XElement
+			if all := itm.Elements().All(); len(all) > 0 {
+				payload = all[0]
+			}
+			err := rep.PubSub().UpsertNodeItem(ctx, &pubsubmodel.Item{
+				ID:        itm.Attributes().Get("id"),
+				Publisher: itm.Attributes().Get("publisher"),
+				Payload:   payload,
+			}, host, nodeID, int(node.Options.MaxItems))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+// Package xep0227 implements XEP-0227 (Portable Import/Export Format for XMPP-IM Servers) on top of
+// repository.Container, so that user data can be moved across storage backends and servers.
+package xep0227
+
+const (
+	pieNamespace = "urn:xmpp:pie:0"
+
+	rosterNamespace = "jabber:iq:roster"
+
+	vCardNamespace = "vcard-temp"
+
+	privateNamespace = "jabber:iq:private"
+
+	blockListNamespace = "urn:xmpp:blocking"
+
+	pubSubNamespace = "http://jabber.org/protocol/pubsub"
+
+	pubSubOwnerNamespace = "http://jabber.org/protocol/pubsub#owner"
+)
+
+const (
+	serverDataElementName = "server-data"
+
+	hostElementName = "host"
+
+	userElementName = "user"
+
+	offlineMessagesElementName = "offline-messages"
+)
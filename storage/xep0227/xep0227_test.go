@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0227
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sxmpp/jackal/model"
+	offlinemodel "github.com/sxmpp/jackal/model/offline"
+	pubsubmodel "github.com/sxmpp/jackal/model/pubsub"
+	rostermodel "github.com/sxmpp/jackal/model/roster"
+	memorystorage "github.com/sxmpp/jackal/storage/memory"
+	"github.com/sxmpp/jackal/storage/repository"
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0227_ExportImport(t *testing.T) {
+	src := tUtilPopulatedStorage(t)
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, Export(context.Background(), buf, src, "jackal.im"))
+
+	dst, _ := memorystorage.New()
+	count, err := Import(context.Background(), buf, dst, ImportOptions{})
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	ctx := context.Background()
+
+	// user
+	usr, err := dst.User().FetchUser(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+	require.Equal(t, "s3cr&t", usr.Password)
+
+	ok, _ := dst.User().UserExists(ctx, "noelia", "jackal.im")
+	require.True(t, ok)
+
+	// roster
+	ris, _, err := dst.Roster().FetchRosterItems(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, ris, 1)
+	require.Equal(t, "noelia@jackal.im", ris[0].JID)
+	require.Equal(t, rostermodel.SubscriptionBoth, ris[0].Subscription)
+	require.Equal(t, []string{"friends"}, ris[0].Groups)
+
+	rns, err := dst.Roster().FetchRosterNotifications(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, rns, 1)
+	require.Equal(t, "romeo@example.org", rns[0].JID)
+
+	// vCard
+	vCard, err := dst.VCard().FetchVCard(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, vCard)
+	require.Equal(t, "ortuman", vCard.Elements().Child("FN").Text())
+
+	// private XML
+	priv, err := dst.Private().FetchPrivateXML(ctx, "storage:bookmarks", "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, priv, 1)
+
+	// block list
+	blItems, err := dst.BlockList().FetchBlockListItems(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, blItems, 1)
+	require.Equal(t, "hamlet@denmark.lit", blItems[0].JID)
+
+	// offline messages
+	messages, err := dst.Offline().FetchOfflineMessages(ctx, "ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, "hi!", messages[0].Message.Elements().Child("body").Text())
+
+	// PEP
+	node, err := dst.PubSub().FetchNode(ctx, "ortuman@jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.NotNil(t, node)
+	require.Equal(t, tNodeOptions, node.Options)
+
+	items, err := dst.PubSub().FetchNodeItems(ctx, "ortuman@jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, "i1", items[0].ID)
+	require.Equal(t, "entry", items[0].Payload.Name())
+
+	affiliations, err := dst.PubSub().FetchNodeAffiliations(ctx, "ortuman@jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Len(t, affiliations, 1)
+
+	subscriptions, err := dst.PubSub().FetchNodeSubscriptions(ctx, "ortuman@jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Len(t, subscriptions, 1)
+	require.Equal(t, "noelia@jackal.im", subscriptions[0].JID)
+}
+
+func TestXEP0227_ExportUser(t *testing.T) {
+	src := tUtilPopulatedStorage(t)
+
+	buf := bytes.NewBuffer(nil)
+	require.NotNil(t, ExportUser(context.Background(), buf, src, "romeo", "jackal.im"))
+
+	buf.Reset()
+	require.Nil(t, ExportUser(context.Background(), buf, src, "noelia", "jackal.im"))
+
+	dst, _ := memorystorage.New()
+	count, err := Import(context.Background(), buf, dst, ImportOptions{})
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	ok, _ := dst.User().UserExists(context.Background(), "ortuman", "jackal.im")
+	require.False(t, ok)
+}
+
+func TestXEP0227_ImportInvalid(t *testing.T) {
+	dst, _ := memorystorage.New()
+
+	_, err := Import(context.Background(), strings.NewReader(`<foo/>`), dst, ImportOptions{})
+	require.NotNil(t, err)
+
+	_, err = Import(context.Background(), strings.NewReader(`<server-data xmlns="urn:xmpp:pie:0"><host><user name="ortuman"/></host></server-data>`), dst, ImportOptions{})
+	require.NotNil(t, err)
+}
+
+func TestXEP0227_ImportPasswordless(t *testing.T) {
+	dst, _ := memorystorage.New()
+	_ = dst.User().UpsertUser(context.Background(), &model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	doc := `<server-data xmlns="urn:xmpp:pie:0"><host jid="jackal.im"><user name="ortuman"/><user name="noelia"/></host></server-data>`
+
+	// new accounts must carry a password
+	count, err := Import(context.Background(), strings.NewReader(doc), dst, ImportOptions{})
+	require.NotNil(t, err)
+	require.Equal(t, 1, count)
+
+	ok, _ := dst.User().UserExists(context.Background(), "noelia", "jackal.im")
+	require.False(t, ok)
+
+	count, err = Import(context.Background(), strings.NewReader(doc), dst, ImportOptions{SkipPasswordless: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	ok, _ = dst.User().UserExists(context.Background(), "noelia", "jackal.im")
+	require.False(t, ok)
+
+	// existing account password is preserved
+	usr, _ := dst.User().FetchUser(context.Background(), "ortuman", "jackal.im")
+	require.Equal(t, "1234", usr.Password)
+}
+
+// node options must be valid in order to be exported as a submit form
+var tNodeOptions = pubsubmodel.Options{
+	DeliverNotifications:  true,
+	DeliverPayloads:       true,
+	PersistItems:          true,
+	AccessModel:           pubsubmodel.Presence,
+	MaxItems:              10,
+	RosterGroupsAllowed:   []string{"friends", "family"},
+	SendLastPublishedItem: pubsubmodel.OnSubAndPresence,
+	NotificationType:      xmpp.HeadlineType,
+}
+
+func tUtilPopulatedStorage(t *testing.T) repository.Container {
+	rep, _ := memorystorage.New()
+	ctx := context.Background()
+
+	require.Nil(t, rep.User().UpsertUser(ctx, &model.User{Username: "ortuman", Domain: "jackal.im", Password: "s3cr&t"}))
+	require.Nil(t, rep.User().UpsertUser(ctx, &model.User{Username: "noelia", Domain: "jackal.im", Password: "1234"}))
+
+	_, err := rep.Roster().UpsertRosterItem(ctx, &rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+		Groups:       []string{"friends"},
+	})
+	require.Nil(t, err)
+
+	romeo, _ := jid.NewWithString("romeo@example.org", true)
+	ortuman, _ := jid.NewWithString("ortuman@jackal.im", true)
+	require.Nil(t, rep.Roster().UpsertRosterNotification(ctx, &rostermodel.Notification{
+		Contact:  "ortuman",
+		Domain:   "jackal.im",
+		JID:      "romeo@example.org",
+		Presence: xmpp.NewPresence(romeo, ortuman, xmpp.SubscribeType),
+	}))
+
+	vCard := xmpp.NewElementNamespace("vCard", "vcard-temp")
+	vCard.AppendElement(xmpp.NewElementName("FN").SetText("ortuman"))
+	require.Nil(t, rep.VCard().UpsertVCard(ctx, vCard, "ortuman", "jackal.im"))
+
+	bookmarks := xmpp.NewElementNamespace("storage", "storage:bookmarks")
+	require.Nil(t, rep.Private().UpsertPrivateXML(ctx, []xmpp.XElement{bookmarks}, "storage:bookmarks", "ortuman", "jackal.im"))
+
+	require.Nil(t, rep.BlockList().InsertBlockListItem(ctx, &model.BlockListItem{Username: "ortuman", Domain: "jackal.im", JID: "hamlet@denmark.lit"}))
+
+	msg := xmpp.NewMessageType("m1", xmpp.ChatType)
+	msg.SetFromJID(romeo)
+	msg.SetToJID(ortuman)
+	msg.AppendElement(xmpp.NewElementName("body").SetText("hi!"))
+	require.Nil(t, rep.Offline().InsertOfflineMessage(ctx, &offlinemodel.Message{ID: "m1", Message: msg}, "ortuman", "jackal.im"))
+
+	require.Nil(t, rep.PubSub().UpsertNode(ctx, &pubsubmodel.Node{Host: "ortuman@jackal.im", Name: "princely_musings", Options: tNodeOptions}))
+	require.Nil(t, rep.PubSub().UpsertNodeAffiliation(ctx, &pubsubmodel.Affiliation{JID: "ortuman@jackal.im", Affiliation: pubsubmodel.Owner}, "ortuman@jackal.im", "princely_musings"))
+	require.Nil(t, rep.PubSub().UpsertNodeSubscription(ctx, &pubsubmodel.Subscription{SubID: "s1", JID: "noelia@jackal.im", Subscription: pubsubmodel.Subscribed}, "ortuman@jackal.im", "princely_musings"))
+	require.Nil(t, rep.PubSub().UpsertNodeItem(ctx, &pubsubmodel.Item{
+		ID:        "i1",
+		Publisher: "ortuman@jackal.im",
+		Payload:   xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom"),
+	}, "ortuman@jackal.im", "princely_musings", 10))
+	return rep
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/sxmpp/jackal/transport/compress"
+	"github.com/sxmpp/jackal/xmpp"
 )
 
 const socketBuffSize = 4096
@@ -59,6 +60,11 @@ func (s *socketTransport) WriteString(str string) (int, error) {
 	return int(n), err
 }
 
+// WritePrepared writes a pre-rendered stanza addressed to 'to' to the transport.
+func (s *socketTransport) WritePrepared(p *xmpp.PreparedStanza, to string) error {
+	return p.WriteTo(s.bw, to)
+}
+
 // Flush writes any buffered data to the underlying io.Writer.
 func (s *socketTransport) Flush() error {
 	return s.bw.Flush()
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/sxmpp/jackal/transport/compress"
+	"github.com/sxmpp/jackal/xmpp"
 )
 
 // Type represents a stream transport type (socket).
@@ -49,6 +50,9 @@ type Transport interface {
 	// WriteString writes a raw string to the transport.
 	WriteString(s string) (n int, err error)
 
+	// WritePrepared writes a pre-rendered stanza addressed to 'to' to the transport.
+	WritePrepared(p *xmpp.PreparedStanza, to string) error
+
 	// Flush writes any buffered data to the underlying io.Writer.
 	Flush() error
 
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package runqueue
+
+import (
+	"sync"
+	"time"
+)
+
+// boundedQueue represents a multi-producer, single-consumer queue that limits
+// the number of pending operations pushed by means of TryRun.
+type boundedQueue struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	items []interface{}
+}
+
+func newBoundedQueue(capacity int) *boundedQueue {
+	return &boundedQueue{slots: make(chan struct{}, capacity)}
+}
+
+func (q *boundedQueue) Push(x interface{}) {
+	q.mu.Lock()
+	q.items = append(q.items, x)
+	q.mu.Unlock()
+}
+
+func (q *boundedQueue) Pop() interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	x := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return x
+}
+
+// acquire reserves a queue slot according to the overflow policy.
+// In case 'dropped' is not nil, the oldest bounded operation has been discarded to make room for the new one.
+// When blocking, caller never waits past deadline, if any.
+func (q *boundedQueue) acquire(policy OverflowPolicy, blockTimeout time.Duration, deadline time.Time) (dropped *funcMessage, err error) {
+	select {
+	case q.slots <- struct{}{}:
+		return nil, nil
+	default:
+		break
+	}
+	switch policy {
+	case Reject:
+		return nil, ErrQueueFull
+
+	case DropOldest:
+		if msg := q.dropOldest(); msg != nil {
+			return msg, nil // reuse discarded operation slot
+		}
+	}
+	if !deadline.IsZero() {
+		if d := time.Until(deadline); d < blockTimeout {
+			blockTimeout = d
+		}
+	}
+	if blockTimeout <= 0 {
+		return nil, ErrQueueFull
+	}
+	tm := time.NewTimer(blockTimeout)
+	defer tm.Stop()
+
+	select {
+	case q.slots <- struct{}{}:
+		return nil, nil
+	case <-tm.C:
+		return nil, ErrQueueFull
+	}
+}
+
+func (q *boundedQueue) release() {
+	<-q.slots
+}
+
+func (q *boundedQueue) dropOldest() *funcMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, x := range q.items {
+		if msg, ok := x.(*funcMessage); ok && msg.bounded {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return msg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package runqueue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultBlockTimeout = time.Duration(5) * time.Second
+
+// OverflowPolicy represents the behavior of a bounded queue once its capacity has been exceeded.
+type OverflowPolicy int
+
+const (
+	// Block represents 'block' overflow policy.
+	// Caller waits until there's room enough in the queue, block timeout or operation deadline expires.
+	//
+	// Since producers are usually running on another queue (a stream pushing into a module queue),
+	// a congested queue stalls them as well, so block timeout should be kept well below stream
+	// read timeouts.
+	Block OverflowPolicy = iota
+
+	// DropOldest represents 'drop_oldest' overflow policy.
+	// Oldest pending operation is discarded in favor of the new one.
+	DropOldest
+
+	// Reject represents 'reject' overflow policy.
+	// New operation is discarded and ErrQueueFull is returned to the caller.
+	Reject
+)
+
+// String returns OverflowPolicy string representation.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop_oldest"
+	case Reject:
+		return "reject"
+	}
+	return ""
+}
+
+// Config represents a run queue configuration.
+type Config struct {
+	// Capacity defines the maximum number of pending operations. A zero value means unbounded.
+	Capacity int
+
+	// Overflow defines what to do when capacity has been exceeded.
+	Overflow OverflowPolicy
+
+	// BlockTimeout defines the maximum time a caller can be blocked when using 'block' overflow policy.
+	BlockTimeout time.Duration
+}
+
+type configProxy struct {
+	Capacity     int    `yaml:"capacity"`
+	Overflow     string `yaml:"overflow"`
+	BlockTimeout int    `yaml:"block_timeout"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.Capacity < 0 {
+		return fmt.Errorf("runqueue.Config: invalid capacity: %d", p.Capacity)
+	}
+	cfg.Capacity = p.Capacity
+
+	switch p.Overflow {
+	case "", "block":
+		cfg.Overflow = Block
+	case "drop_oldest":
+		cfg.Overflow = DropOldest
+	case "reject":
+		cfg.Overflow = Reject
+	default:
+		return fmt.Errorf("runqueue.Config: unrecognized overflow policy: %s", p.Overflow)
+	}
+	cfg.BlockTimeout = time.Duration(p.BlockTimeout) * time.Second
+	if cfg.BlockTimeout == 0 {
+		cfg.BlockTimeout = defaultBlockTimeout
+	}
+	return nil
+}
+
+var (
+	defaultCfgMu sync.RWMutex
+	defaultCfg   Config
+)
+
+// DefaultConfig returns the configuration used by queues created by means of New.
+func DefaultConfig() Config {
+	defaultCfgMu.RLock()
+	defer defaultCfgMu.RUnlock()
+	return defaultCfg
+}
+
+// SetDefaultConfig sets the configuration used by queues created afterwards by means of New.
+func SetDefaultConfig(cfg Config) {
+	if cfg.BlockTimeout == 0 {
+		cfg.BlockTimeout = defaultBlockTimeout
+	}
+	defaultCfgMu.Lock()
+	defaultCfg = cfg
+	defaultCfgMu.Unlock()
+}
@@ -6,8 +6,10 @@
 package runqueue
 
 import (
+	"errors"
 	"runtime"
 	"sync/atomic"
+	"time"
 
 	"github.com/sxmpp/jackal/log"
 	"github.com/sxmpp/jackal/util/runqueue/mpsc"
@@ -18,34 +20,121 @@ const (
 	running
 )
 
-// RunQueue represents a lock-free operation queue.
+// ErrQueueFull will be returned by TryRun when queue capacity has been exceeded.
+var ErrQueueFull = errors.New("runqueue: queue is full")
+
+type queue interface {
+	Push(x interface{})
+	Pop() interface{}
+}
+
+// RunQueue represents an operation queue.
+//
+// Unless capacity has been configured, queue is lock-free and unbounded.
 type RunQueue struct {
 	name         string
-	queue        *mpsc.Queue
+	cfg          Config
+	queue        queue
+	bq           *boundedQueue
 	messageCount int32
 	state        int32
 	stopped      int32
+	stats        stats
+}
+
+type funcMessage struct {
+	fn         func()
+	discardFn  func()
+	enqueuedAt time.Time
+	deadline   time.Time
+	bounded    bool
 }
 
-type funcMessage struct{ fn func() }
 type stopMessage struct{ stopCb func() }
 
-// New returns an initialized lock-free operation queue.
+func (msg *funcMessage) discard() {
+	if msg.discardFn != nil {
+		msg.discardFn()
+	}
+}
+
+// New returns an initialized operation queue using default configuration.
 func New(name string) *RunQueue {
-	return &RunQueue{
-		name:  name,
-		queue: mpsc.New(),
+	return NewWithConfig(name, DefaultConfig())
+}
+
+// NewWithConfig returns an initialized operation queue using a custom configuration.
+func NewWithConfig(name string, cfg Config) *RunQueue {
+	rq := &RunQueue{
+		name: name,
+		cfg:  cfg,
+	}
+	if cfg.Capacity > 0 {
+		rq.bq = newBoundedQueue(cfg.Capacity)
+		rq.queue = rq.bq
+	} else {
+		rq.queue = mpsc.New()
 	}
+	register(rq)
+	return rq
+}
+
+// Name returns queue name.
+func (m *RunQueue) Name() string {
+	return m.name
 }
 
 // Run pushes a new operation function into the queue.
+//
+// Pushed function is always accepted regardless of queue capacity, so that it should be reserved
+// to operations that can't be lost (such as stream lifecycle or subscription state changes),
+// while any other operation should be pushed by means of TryRun.
 func (m *RunQueue) Run(fn func()) {
 	if atomic.LoadInt32(&m.stopped) == 1 {
 		return
 	}
-	m.queue.Push(&funcMessage{fn: fn})
-	atomic.AddInt32(&m.messageCount, 1)
-	m.schedule()
+	m.push(&funcMessage{fn: fn, enqueuedAt: time.Now()})
+}
+
+// TryRun pushes a new operation function into the queue applying configured overflow policy
+// in case queue capacity has been exceeded.
+//
+// If set, discardFn will be executed whenever the operation is discarded, either because it has been rejected
+// or dropped from the queue.
+func (m *RunQueue) TryRun(fn func(), discardFn func()) error {
+	return m.TryRunWithDeadline(time.Time{}, fn, discardFn)
+}
+
+// TryRunWithDeadline behaves as TryRun, but operation function will also be discarded if it can't be
+// started before deadline. A zero deadline value means no deadline at all.
+//
+// Under 'block' overflow policy, caller is never blocked past deadline.
+func (m *RunQueue) TryRunWithDeadline(deadline time.Time, fn func(), discardFn func()) error {
+	if atomic.LoadInt32(&m.stopped) == 1 {
+		return nil
+	}
+	msg := &funcMessage{
+		fn:         fn,
+		discardFn:  discardFn,
+		enqueuedAt: time.Now(),
+		deadline:   deadline,
+		bounded:    true,
+	}
+	if m.bq != nil {
+		dropped, err := m.bq.acquire(m.cfg.Overflow, m.cfg.BlockTimeout, deadline)
+		if err != nil {
+			atomic.AddUint64(&m.stats.rejected, 1)
+			msg.discard()
+			return err
+		}
+		if dropped != nil {
+			atomic.AddUint64(&m.stats.dropped, 1)
+			atomic.AddInt32(&m.messageCount, -1)
+			dropped.discard()
+		}
+	}
+	m.push(msg)
+	return nil
 }
 
 // Stop signals the queue to stop running.
@@ -54,13 +143,42 @@ func (m *RunQueue) Run(fn func()) {
 // previously scheduled.
 func (m *RunQueue) Stop(stopCb func()) {
 	if atomic.CompareAndSwapInt32(&m.stopped, 0, 1) {
+		unregister(m)
 		if atomic.LoadInt32(&m.messageCount) > 0 {
 			m.queue.Push(&stopMessage{stopCb: stopCb})
 			return
 		}
 	}
-	stopCb()
-	return
+	if stopCb != nil {
+		stopCb()
+	}
+}
+
+// Stats returns a snapshot of current queue statistics.
+func (m *RunQueue) Stats() Stats {
+	depth := atomic.LoadInt32(&m.messageCount)
+	if depth < 0 {
+		depth = 0
+	}
+	st := Stats{
+		Depth:      int(depth),
+		Capacity:   m.cfg.Capacity,
+		Processed:  atomic.LoadUint64(&m.stats.processed),
+		Dropped:    atomic.LoadUint64(&m.stats.dropped),
+		Rejected:   atomic.LoadUint64(&m.stats.rejected),
+		Expired:    atomic.LoadUint64(&m.stats.expired),
+		MaxLatency: time.Duration(atomic.LoadInt64(&m.stats.maxLatency)),
+	}
+	if st.Processed > 0 {
+		st.AvgLatency = time.Duration(uint64(atomic.LoadInt64(&m.stats.totalLatency)) / st.Processed)
+	}
+	return st
+}
+
+func (m *RunQueue) push(msg *funcMessage) {
+	m.queue.Push(msg)
+	atomic.AddInt32(&m.messageCount, 1)
+	m.schedule()
 }
 
 func (m *RunQueue) schedule() {
@@ -97,7 +215,10 @@ func (m *RunQueue) run() {
 	for {
 		switch msg := m.queue.Pop().(type) {
 		case *funcMessage:
-			msg.fn()
+			if msg.bounded && m.bq != nil {
+				m.bq.release()
+			}
+			m.runMessage(msg)
 			atomic.AddInt32(&m.messageCount, -1)
 		case *stopMessage:
 			if cb := msg.stopCb; cb != nil {
@@ -110,6 +231,18 @@ func (m *RunQueue) run() {
 	}
 }
 
+func (m *RunQueue) runMessage(msg *funcMessage) {
+	now := time.Now()
+	if !msg.deadline.IsZero() && now.After(msg.deadline) {
+		atomic.AddUint64(&m.stats.expired, 1)
+		msg.discard()
+		return
+	}
+	m.stats.observeLatency(now.Sub(msg.enqueuedAt))
+
+	msg.fn()
+}
+
 func (m *RunQueue) logStackTrace(err interface{}) {
 	stackSlice := make([]byte, 4096)
 	s := runtime.Stack(stackSlice, false)
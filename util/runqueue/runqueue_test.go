@@ -7,6 +7,7 @@ package runqueue
 
 import (
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -53,3 +54,173 @@ func TestRunQueueStop(t *testing.T) {
 		break
 	}
 }
+
+func TestRunQueueReject(t *testing.T) {
+	rq := NewWithConfig("test", Config{Capacity: 2, Overflow: Reject})
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+
+	require.Nil(t, rq.TryRun(func() {}, nil))
+	require.Nil(t, rq.TryRun(func() {}, nil))
+	var discarded int32
+	require.Equal(t, ErrQueueFull, rq.TryRun(func() {}, func() { atomic.StoreInt32(&discarded, 1) }))
+	require.Equal(t, int32(1), atomic.LoadInt32(&discarded))
+
+	// unbounded operations are always accepted
+	var executed int32
+	rq.Run(func() { atomic.StoreInt32(&executed, 1) })
+
+	close(blockCh)
+	stopQueue(rq)
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&executed))
+
+	st := rq.Stats()
+	require.Equal(t, uint64(1), st.Rejected)
+	require.Equal(t, uint64(4), st.Processed)
+}
+
+func TestRunQueueDropOldest(t *testing.T) {
+	rq := NewWithConfig("test", Config{Capacity: 2, Overflow: DropOldest})
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+
+	var mu sync.Mutex
+	var executed []int
+	var discarded int32
+	for i := 0; i < 4; i++ {
+		n := i
+		require.Nil(t, rq.TryRun(func() {
+			mu.Lock()
+			executed = append(executed, n)
+			mu.Unlock()
+		}, func() {
+			atomic.AddInt32(&discarded, 1)
+		}))
+	}
+	close(blockCh)
+	stopQueue(rq)
+
+	require.Equal(t, []int{2, 3}, executed)
+	require.Equal(t, int32(2), atomic.LoadInt32(&discarded))
+	require.Equal(t, uint64(2), rq.Stats().Dropped)
+}
+
+func TestRunQueueBlock(t *testing.T) {
+	rq := NewWithConfig("test", Config{Capacity: 1, Overflow: Block, BlockTimeout: time.Millisecond * 100})
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+
+	require.Nil(t, rq.TryRun(func() {}, nil))
+
+	// block timeout expiration
+	require.Equal(t, ErrQueueFull, rq.TryRun(func() {}, nil))
+
+	time.AfterFunc(time.Millisecond*50, func() { close(blockCh) })
+
+	var executed int32
+	require.Nil(t, rq.TryRun(func() { atomic.StoreInt32(&executed, 1) }, nil))
+
+	stopQueue(rq)
+	require.Equal(t, int32(1), atomic.LoadInt32(&executed))
+}
+
+func TestRunQueueBlockDeadline(t *testing.T) {
+	rq := NewWithConfig("test", Config{Capacity: 1, Overflow: Block, BlockTimeout: time.Second * 5})
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+
+	require.Nil(t, rq.TryRun(func() {}, nil))
+
+	// caller is never blocked past operation deadline
+	var discarded int32
+	start := time.Now()
+	require.Equal(t, ErrQueueFull, rq.TryRunWithDeadline(time.Now().Add(time.Millisecond*50), func() {}, func() {
+		atomic.StoreInt32(&discarded, 1)
+	}))
+	require.True(t, time.Since(start) < time.Second)
+	require.Equal(t, int32(1), atomic.LoadInt32(&discarded))
+
+	close(blockCh)
+	stopQueue(rq)
+}
+
+func TestRunQueueDeadline(t *testing.T) {
+	rq := New("test")
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+
+	var executed, expired int32
+	fn := func() { atomic.AddInt32(&executed, 1) }
+	discardFn := func() { atomic.AddInt32(&expired, 1) }
+
+	require.Nil(t, rq.TryRunWithDeadline(time.Now().Add(time.Millisecond*10), fn, discardFn))
+	require.Nil(t, rq.TryRunWithDeadline(time.Now().Add(time.Hour), fn, discardFn))
+
+	time.Sleep(time.Millisecond * 50)
+	close(blockCh)
+	stopQueue(rq)
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&executed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&expired))
+	require.Equal(t, uint64(1), rq.Stats().Expired)
+}
+
+func TestRunQueueStats(t *testing.T) {
+	rq := New("test-stats")
+
+	blockCh := make(chan struct{})
+	rq.Run(func() { <-blockCh })
+	rq.Run(func() {})
+
+	time.Sleep(time.Millisecond * 20)
+
+	st := rq.Stats()
+	require.Equal(t, 2, st.Depth)
+
+	_, ok := AllStats()["test-stats"]
+	require.True(t, ok)
+
+	close(blockCh)
+	stopQueue(rq)
+
+	st = rq.Stats()
+	require.Equal(t, 0, st.Depth)
+	require.Equal(t, uint64(2), st.Processed)
+	require.True(t, st.MaxLatency >= time.Millisecond*20)
+
+	_, ok = AllStats()["test-stats"]
+	require.False(t, ok)
+}
+
+func TestRunQueueStatsSharedName(t *testing.T) {
+	rq1 := NewWithConfig("test-shared-stats", Config{Capacity: 10})
+	rq2 := NewWithConfig("test-shared-stats", Config{Capacity: 20})
+	defer stopQueue(rq1)
+	defer stopQueue(rq2)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	rq1.Run(wg.Done)
+	rq2.Run(wg.Done)
+	rq2.Run(wg.Done)
+	wg.Wait()
+
+	time.Sleep(time.Millisecond * 20) // wait until processed operations are accounted
+
+	st, ok := AllStats()["test-shared-stats"]
+	require.True(t, ok)
+	require.Equal(t, uint64(3), st.Processed)
+	require.Equal(t, 30, st.Capacity)
+}
+
+func stopQueue(rq *RunQueue) {
+	c := make(chan struct{})
+	rq.Stop(func() { close(c) })
+	<-c
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package runqueue
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats represents a snapshot of run queue statistics.
+type Stats struct {
+	// Depth is the number of pending operations.
+	Depth int `json:"depth"`
+
+	// Capacity is the configured queue capacity. A zero value means unbounded.
+	Capacity int `json:"capacity"`
+
+	// Processed is the number of executed operations.
+	Processed uint64 `json:"processed"`
+
+	// Dropped is the number of operations discarded by 'drop_oldest' overflow policy.
+	Dropped uint64 `json:"dropped"`
+
+	// Rejected is the number of operations that couldn't be pushed into the queue.
+	Rejected uint64 `json:"rejected"`
+
+	// Expired is the number of operations discarded because of their deadline.
+	Expired uint64 `json:"expired"`
+
+	// AvgLatency is the average time an operation waits in the queue before being executed.
+	AvgLatency time.Duration `json:"avg_latency"`
+
+	// MaxLatency is the maximum time an operation has waited in the queue before being executed.
+	MaxLatency time.Duration `json:"max_latency"`
+}
+
+type stats struct {
+	processed    uint64
+	dropped      uint64
+	rejected     uint64
+	expired      uint64
+	totalLatency int64
+	maxLatency   int64
+}
+
+func (s *stats) observeLatency(d time.Duration) {
+	atomic.AddUint64(&s.processed, 1)
+	atomic.AddInt64(&s.totalLatency, int64(d))
+	for {
+		max := atomic.LoadInt64(&s.maxLatency)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&s.maxLatency, max, int64(d)) {
+			return
+		}
+	}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[*RunQueue]struct{})
+)
+
+func init() {
+	expvar.Publish("runqueues", expvar.Func(func() interface{} { return AllStats() }))
+}
+
+// AllStats returns statistics of all running queues indexed by queue name.
+// Statistics of queues sharing the same name are aggregated.
+func AllStats() map[string]Stats {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	ret := make(map[string]Stats, len(registry))
+	totalLatency := make(map[string]int64, len(registry))
+	unbounded := make(map[string]bool, len(registry))
+	for rq := range registry {
+		rqSt := rq.Stats()
+		st := ret[rq.name]
+		st.Depth += rqSt.Depth
+		st.Capacity += rqSt.Capacity
+		st.Processed += rqSt.Processed
+		st.Dropped += rqSt.Dropped
+		st.Rejected += rqSt.Rejected
+		st.Expired += rqSt.Expired
+		if rqSt.MaxLatency > st.MaxLatency {
+			st.MaxLatency = rqSt.MaxLatency
+		}
+		ret[rq.name] = st
+
+		totalLatency[rq.name] += atomic.LoadInt64(&rq.stats.totalLatency)
+		if rqSt.Capacity == 0 {
+			unbounded[rq.name] = true
+		}
+	}
+	for name, st := range ret {
+		if unbounded[name] {
+			st.Capacity = 0
+		}
+		if st.Processed > 0 {
+			st.AvgLatency = time.Duration(uint64(totalLatency[name]) / st.Processed)
+		}
+		ret[name] = st
+	}
+	return ret
+}
+
+func register(rq *RunQueue) {
+	registryMu.Lock()
+	registry[rq] = struct{}{}
+	registryMu.Unlock()
+}
+
+func unregister(rq *RunQueue) {
+	registryMu.Lock()
+	delete(registry, rq)
+	registryMu.Unlock()
+}
@@ -31,6 +31,9 @@ type Element struct {
 	text     string
 	attrs    attributeSet
 	elements elementSet
+
+	// prepared representation this element was derived from, discarded by any mutation.
+	prepared *PreparedStanza
 }
 
 // NewElementName creates a mutable XML XElement instance with a given name.
@@ -318,6 +321,11 @@ func (s *stanzaElement) SetFromJID(j *jid.JID) {
 	s.SetFrom(j.String())
 }
 
+// Prepared returns the prepared representation this stanza was derived from, if any.
+func (s *stanzaElement) Prepared() *PreparedStanza {
+	return s.prepared
+}
+
 // FromBytes deserializes a stanza element from it's gob binary representation.
 func (s *stanzaElement) FromBytes(buf *bytes.Buffer) error {
 	if err := s.Element.FromBytes(buf); err != nil {
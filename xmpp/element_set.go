@@ -88,7 +88,8 @@ func (es *elementSet) append(nodes ...XElement) {
 }
 
 func (es *elementSet) remove(name string) {
-	filtered := (*es)[:0]
+	// filter into a new slice, since backing array might be shared with a prepared stanza
+	var filtered elementSet
 	for _, node := range *es {
 		if node.Name() != name {
 			filtered = append(filtered, node)
@@ -98,7 +99,7 @@ func (es *elementSet) remove(name string) {
 }
 
 func (es *elementSet) removeNamespace(name string, namespace string) {
-	filtered := (*es)[:0]
+	var filtered elementSet
 	for _, elem := range *es {
 		if elem.Name() != name || elem.Attributes().Get("xmlns") != namespace {
 			filtered = append(filtered, elem)
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xmpp
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/sxmpp/jackal/xmpp/jid"
+)
+
+// PreparedStanza represents a stanza whose XML representation has been rendered beforehand,
+// so that it can be delivered to many recipients encoding it only once.
+//
+// Rendered bytes don't include 'to' and 'xmlns' attributes. The former is written
+// on each delivery, while the latter is always cleared by the session before sending a stanza.
+type PreparedStanza struct {
+	stanza Stanza
+	head   []byte
+	tail   []byte
+}
+
+// NewPreparedStanza renders a stanza and returns its prepared representation.
+func NewPreparedStanza(stanza Stanza) *PreparedStanza {
+	var head, tail bytes.Buffer
+
+	head.WriteString("<")
+	head.WriteString(stanza.Name())
+
+	// attributes following 'to' are rendered into tail
+	w := &head
+	for _, attr := range stanza.Attributes().(attributeSet) {
+		if attr.Label == "to" {
+			head.WriteString(` to="`)
+			tail.WriteString(`"`)
+			w = &tail
+			continue
+		}
+		if len(attr.Value) == 0 || attr.Label == "xmlns" {
+			continue
+		}
+		w.WriteString(" ")
+		w.WriteString(attr.Label)
+		w.WriteString(`="`)
+		w.WriteString(attr.Value)
+		w.WriteString(`"`)
+	}
+	if w == &head {
+		head.WriteString(` to="`)
+		tail.WriteString(`"`)
+	}
+	elements := stanza.Elements()
+	text := stanza.Text()
+	if elements.Count() > 0 || len(text) > 0 {
+		tail.WriteString(">")
+		if len(text) > 0 {
+			_ = escapeText(&tail, []byte(text), false)
+		}
+		for _, elem := range elements.All() {
+			_ = elem.ToXML(&tail, true)
+		}
+		tail.WriteString("</")
+		tail.WriteString(stanza.Name())
+		tail.WriteString(">")
+	} else {
+		tail.WriteString("/>")
+	}
+	return &PreparedStanza{
+		stanza: stanza,
+		head:   head.Bytes(),
+		tail:   tail.Bytes(),
+	}
+}
+
+// WriteTo writes the prepared stanza XML representation addressed to 'to'.
+func (p *PreparedStanza) WriteTo(w io.Writer, to string) error {
+	if _, err := w.Write(p.head); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, to); err != nil {
+		return err
+	}
+	_, err := w.Write(p.tail)
+	return err
+}
+
+// Stanza returns a stanza addressed to toJID that shares both the prepared XML representation
+// and the sub elements of the original stanza.
+// Mutating returned stanza discards its prepared representation, while its sub elements
+// should be considered read-only.
+func (p *PreparedStanza) Stanza(toJID *jid.JID) Stanza {
+	switch stanza := p.stanza.(type) {
+	case *Presence:
+		cp := *stanza
+		cp.stanzaElement = p.addressedStanzaElement(&stanza.stanzaElement, toJID)
+		return &cp
+	case *Message:
+		return &Message{stanzaElement: p.addressedStanzaElement(&stanza.stanzaElement, toJID)}
+	case *IQ:
+		return &IQ{stanzaElement: p.addressedStanzaElement(&stanza.stanzaElement, toJID)}
+	case *stanzaElement:
+		se := p.addressedStanzaElement(stanza, toJID)
+		return &se
+	}
+	// unknown stanza implementation
+	e := &stanzaElement{}
+	e.copyFrom(p.stanza)
+	e.fromJID = p.stanza.FromJID()
+	e.SetToJID(toJID)
+	return e
+}
+
+func (p *PreparedStanza) addressedStanzaElement(s *stanzaElement, toJID *jid.JID) stanzaElement {
+	se := *s
+	se.attrs = make(attributeSet, len(s.attrs))
+	copy(se.attrs, s.attrs)
+	se.elements = s.elements[:len(s.elements):len(s.elements)] // force reallocation on append
+	se.SetToJID(toJID)
+	se.prepared = p
+	return se
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2020 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xmpp_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/sxmpp/jackal/xmpp"
+	"github.com/sxmpp/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreparedStanza_WriteTo(t *testing.T) {
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im", true)
+
+	p := testPresence(j1, j2)
+	prepared := xmpp.NewPreparedStanza(p)
+
+	buf := new(bytes.Buffer)
+	require.Nil(t, prepared.WriteTo(buf, j2.String()))
+	require.Equal(t, p.String(), buf.String())
+
+	// empty stanza
+	p2 := xmpp.NewPresence(j1, j2, xmpp.UnavailableType)
+	buf.Reset()
+	require.Nil(t, xmpp.NewPreparedStanza(p2).WriteTo(buf, j2.String()))
+	require.Equal(t, p2.String(), buf.String())
+}
+
+func TestPreparedStanza_Stanza(t *testing.T) {
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im", true)
+	j3, _ := jid.NewWithString("romeo@jackal.im", true)
+
+	p := testPresence(j1, j2)
+	prepared := xmpp.NewPreparedStanza(p)
+
+	s := prepared.Stanza(j3)
+	p3, ok := s.(*xmpp.Presence)
+	require.True(t, ok)
+	require.Equal(t, j3.String(), p3.ToJID().String())
+	require.Equal(t, j3.String(), p3.To())
+	require.Equal(t, int8(5), p3.Priority())
+	require.Equal(t, prepared, p3.Prepared())
+
+	// original stanza remains untouched
+	require.Equal(t, j2.String(), p.To())
+	require.Nil(t, p.Prepared())
+
+	// modifying sender discards prepared representation
+	p3.SetFromJID(j2)
+	require.Nil(t, p3.Prepared())
+	require.Equal(t, j1.String(), p.From())
+
+	// ...as any other mutation does
+	p4 := prepared.Stanza(j3).(*xmpp.Presence)
+	p4.SetID("p1")
+	require.Nil(t, p4.Prepared())
+
+	p4 = prepared.Stanza(j3).(*xmpp.Presence)
+	p4.ClearElements()
+	require.Nil(t, p4.Prepared())
+	require.True(t, p.Elements().Count() > 0)
+
+	p4 = prepared.Stanza(j3).(*xmpp.Presence)
+	p4.SetTo(j2.String())
+	require.Equal(t, prepared, p4.Prepared())
+
+	msg := xmpp.NewMessageType("abc", xmpp.HeadlineType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	_, ok = xmpp.NewPreparedStanza(msg).Stanza(j3).(*xmpp.Message)
+	require.True(t, ok)
+}
+
+func BenchmarkPreparedStanza_FanOut(b *testing.B) {
+	const recipients = 500
+
+	j1, _ := jid.NewWithString("sxmpp@jackal.im/balcony", true)
+	var toJIDs []*jid.JID
+	for i := 0; i < recipients; i++ {
+		j, _ := jid.New("user"+strconv.Itoa(i), "jackal.im", "", true)
+		toJIDs = append(toJIDs, j)
+	}
+	p := testPresence(j1, j1.ToBareJID())
+
+	b.Run("ToXML", func(b *testing.B) {
+		buf := new(bytes.Buffer)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, toJID := range toJIDs {
+				buf.Reset()
+				cp := xmpp.NewPresence(j1, toJID, xmpp.AvailableType)
+				cp.AppendElements(p.Elements().All())
+				_ = cp.ToXML(buf, true)
+			}
+		}
+	})
+	b.Run("Prepared", func(b *testing.B) {
+		buf := new(bytes.Buffer)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			prepared := xmpp.NewPreparedStanza(p)
+			for _, toJID := range toJIDs {
+				buf.Reset()
+				_ = prepared.WriteTo(buf, toJID.String())
+			}
+		}
+	})
+}
+
+func testPresence(from, to *jid.JID) *xmpp.Presence {
+	p := xmpp.NewPresence(from, to, xmpp.AvailableType)
+	show := xmpp.NewElementName("show")
+	show.SetText("away")
+	status := xmpp.NewElementName("status")
+	status.SetText("Away & busy")
+	priority := xmpp.NewElementName("priority")
+	priority.SetText("5")
+	x := xmpp.NewElementNamespace("x", "vcard-temp:x:update")
+	photo := xmpp.NewElementName("photo")
+	photo.SetText("b7d050434f5441e377dc57f72ac5239e1f493fd0")
+	x.AppendElement(photo)
+	p.AppendElements([]xmpp.XElement{show, status, priority, x})
+
+	pr, _ := xmpp.NewPresenceFromElement(p, from, to)
+	return pr
+}